@@ -1,11 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"strings"
 
+	"github.com/kcp-dev/kcp/pkg/debug"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/apiimport"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
 	"github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/drain"
+	"github.com/kcp-dev/kcp/pkg/reconciler/eviction"
+	"github.com/kcp-dev/kcp/pkg/reconciler/negotiation"
+	"github.com/kcp-dev/kcp/pkg/reconciler/workspace"
+	"github.com/kcp-dev/kcp/pkg/sharding"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/controlplane/clientutils"
 )
 
@@ -15,9 +29,31 @@ var (
 	kubeconfigPath = flag.String("kubeconfig", "", "Path to kubeconfig")
 	syncerImage    = flag.String("syncer_image", "", "Syncer image to install on clusters")
 	pullModel      = flag.Bool("pull_model", true, "Deploy the syncer in registered physical clusters in POD, and have it sync resources from KCP")
+	metricsAddr    = flag.String("metrics_addr", ":8081", "Address to serve Prometheus metrics on; empty to disable")
+	profiling      = flag.Bool("profiling", false, "Serve the pprof profiles on /debug/pprof and the runtime metrics, such as the number of goroutines, on /debug/vars at --profiling_addr")
+	profilingAddr  = flag.String("profiling_addr", debug.DefaultAddr, "Loopback address to serve the debug endpoints of --profiling on")
+	driftMode      = flag.String("syncer_drift_mode", string(syncer.DriftRevert), "What the syncers do about synced objects changed on their cluster: revert, report or adopt")
+	syncerTokenTTL = flag.Duration("syncer_token_ttl", 0, "Issue the syncers installed with the pull model ServiceAccount tokens valid for this long, replaced before they expire, rather than the credentials of the kubeconfig; zero to disable")
+
+	tracingEndpoint     = flag.String("tracing_endpoint", "", "URL of the OTLP/HTTP collector to export the traces of the reconciles to, e.g. http://otel-collector:4318; empty to disable")
+	tracingSamplingRate = flag.Float64("tracing_sampling_rate", tracing.DefaultSamplingRate, "Fraction of the reconciles to trace, besides those that are part of a trace already")
+
+	syncerTransformers    = flag.String("syncer_transformers", "", "Comma-separated transformers the syncers run on the objects they sync, after the namespace and ServiceAccount mappings of their cluster's annotations")
+	syncerUpsync          = flag.String("syncer_upsync", "", "Comma-separated resources whose objects on each cluster the syncers mirror to kcp, in the namespaces synced objects are in, e.g. pods,replicasets,events")
+	syncerRBACVerbs       = flag.String("syncer_rbac_verbs", "", "Comma-separated verbs the Roles bound to the ServiceAccounts of synced objects may grant on each cluster, for the syncers to sync them along with their RoleBindings, e.g. get,list,watch; empty not to sync RBAC")
+	syncerServiceImports  = flag.Bool("syncer_service_imports", false, "Make the syncers program the ServiceImports derived from ServiceExports as Multi-Cluster Services API ServiceImports on each cluster, whose CRD must be installed there")
+	syncerNetworkPolicies = flag.Bool("syncer_network_policies", false, "Make the syncers of the clusters that enforce NetworkPolicies sync those selecting the pods of synced objects, whose CRD must be applied to kcp first")
+
+	importAPIGroups = flag.String("import_api_groups", "", "Comma-separated API groups to import the resources of from the registered clusters into kcp as CRDs, with core for the core group and * for every group; empty to disable")
+
+	evictionToleration = flag.Duration("eviction_toleration", eviction.DefaultToleration, "How long a cluster may stay NotReady before its workloads are moved to other clusters")
+
+	shardsKubeconfig = flag.String("shards_kubeconfig", "", "Path to a kubeconfig with a context per kcp shard, whose current one is that of --kubeconfig, to assign the Workspaces to; empty not to shard")
+	workspacesURL    = flag.String("workspaces_url", "", "URL the Workspaces are reached under, such as that of the front proxy of the shards; the server of --kubeconfig if empty")
 )
 
 func main() {
+	klog.InitFlags(nil)
 	flag.Parse()
 
 	configLoader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
@@ -26,12 +62,20 @@ func main() {
 
 	r, err := configLoader.ClientConfig()
 	if err != nil {
-		log.Fatal(err)
+		klog.Fatal(err)
 	}
-	clientutils.EnableMultiCluster(r, nil, "clusters", "customresourcedefinitions")
+	tracing.WrapConfig(r)
+	shutdownTracing, err := tracing.Setup("kcp-cluster-controller", *tracingEndpoint, *tracingSamplingRate)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+	// Workspaces are only set up in the admin logical cluster.
+	workspaceConfig := rest.CopyConfig(r)
+	clientutils.EnableMultiCluster(r, nil, "clusters", "customresourcedefinitions", "secrets", "negotiatedapiresources", "namespaces", "serviceaccounts")
 	kubeconfig, err := configLoader.RawConfig()
 	if err != nil {
-		log.Fatal(err)
+		klog.Fatal(err)
 	}
 
 	resourcesToSync := flag.Args()
@@ -39,5 +83,56 @@ func main() {
 		resourcesToSync = []string{"pods", "deployments"}
 	}
 
-	cluster.NewController(r, *syncerImage, kubeconfig, resourcesToSync, *pullModel).Start(numThreads)
+	mode, err := syncer.ParseDriftMode(*driftMode)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	var transformers []string
+	if *syncerTransformers != "" {
+		transformers = strings.Split(*syncerTransformers, ",")
+	}
+	if _, err := syncer.NewPipeline(transformers); err != nil {
+		klog.Fatal(err)
+	}
+	var upsync []string
+	if *syncerUpsync != "" {
+		upsync = strings.Split(*syncerUpsync, ",")
+	}
+	var rbacVerbs []string
+	if *syncerRBACVerbs != "" {
+		rbacVerbs = strings.Split(*syncerRBACVerbs, ",")
+	}
+
+	var shards *sharding.Shards
+	if *shardsKubeconfig != "" {
+		if shards, err = sharding.Load(*shardsKubeconfig); err != nil {
+			klog.Fatal(err)
+		}
+	}
+
+	if err := cluster.RegisterClusterCRD(workspaceConfig, nil); err != nil {
+		klog.Fatal(err)
+	}
+
+	metrics.Serve(*metricsAddr)
+	if *profiling {
+		if err := debug.Serve(*profilingAddr); err != nil {
+			klog.Fatal(err)
+		}
+	}
+	ctx := base.SignalContext()
+	go eviction.NewController(r, *evictionToleration, nil).Start(ctx, numThreads, base.DefaultDrainTimeout)
+	go drain.NewController(r, nil).Start(ctx, numThreads, base.DefaultDrainTimeout)
+	go negotiation.NewController(r, nil).Start(ctx, numThreads, base.DefaultDrainTimeout)
+	go workspace.NewController(workspaceConfig, *workspacesURL, shards).Start(ctx, numThreads, base.DefaultDrainTimeout)
+	if groups := apiimport.ParseGroups(*importAPIGroups); len(groups) > 0 {
+		go apiimport.NewController(r, groups, nil).Start(ctx, numThreads, base.DefaultDrainTimeout)
+	}
+	c := cluster.NewController(r, *syncerImage, kubeconfig, resourcesToSync, *pullModel, mode, *syncerTokenTTL, nil)
+	c.SetSyncerTransformers(transformers)
+	c.SetSyncerUpsync(upsync)
+	c.SetSyncerRBACVerbs(rbacVerbs)
+	c.SetSyncerServiceImports(*syncerServiceImports)
+	c.SetSyncerNetworkPolicies(*syncerNetworkPolicies)
+	c.Start(numThreads)
 }
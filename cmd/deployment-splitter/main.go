@@ -1,24 +1,264 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"strings"
+	"sync"
+	"time"
 
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	"github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
+	"github.com/kcp-dev/kcp/pkg/debug"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/batch"
 	"github.com/kcp-dev/kcp/pkg/reconciler/deployment"
+	"github.com/kcp-dev/kcp/pkg/reconciler/ingress"
+	"github.com/kcp-dev/kcp/pkg/reconciler/pullsecret"
+	"github.com/kcp-dev/kcp/pkg/reconciler/quota"
+	"github.com/kcp-dev/kcp/pkg/reconciler/secret"
+	"github.com/kcp-dev/kcp/pkg/reconciler/service"
+	"github.com/kcp-dev/kcp/pkg/reconciler/serviceexport"
+	"github.com/kcp-dev/kcp/pkg/reconciler/splitter"
+	"github.com/kcp-dev/kcp/pkg/reconciler/statefulset"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/sharding"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/controlplane/clientutils"
 )
 
 const numThreads = 2
 
-var kubeconfig = flag.String("kubeconfig", "", "Path to kubeconfig")
+var (
+	kubeconfig    = flag.String("kubeconfig", "", "Path to kubeconfig")
+	drainTimeout  = flag.Duration("drain_timeout", base.DefaultDrainTimeout, "How long to wait for queued work to finish on shutdown")
+	metricsAddr   = flag.String("metrics_addr", ":8080", "Address to serve Prometheus metrics on; empty to disable")
+	profiling     = flag.Bool("profiling", false, "Serve the pprof profiles on /debug/pprof and the runtime metrics, such as the number of goroutines, on /debug/vars at --profiling_addr")
+	profilingAddr = flag.String("profiling_addr", debug.DefaultAddr, "Loopback address to serve the debug endpoints of --profiling on")
+
+	tracingEndpoint     = flag.String("tracing_endpoint", "", "URL of the OTLP/HTTP collector to export the traces of the reconciles to, e.g. http://otel-collector:4318; empty to disable")
+	tracingSamplingRate = flag.Float64("tracing_sampling_rate", tracing.DefaultSamplingRate, "Fraction of the reconciles to trace, besides those that are part of a trace already")
+
+	maxRetries     = flag.Int("max_retries", base.DefaultRetryPolicy().MaxRequeues, "How many times to retry a failed reconcile before dropping it until the object changes")
+	retryBaseDelay = flag.Duration("retry_base_delay", base.DefaultRetryPolicy().BaseDelay, "Delay before the first retry of a failed reconcile, doubled on each further failure")
+	retryMaxDelay  = flag.Duration("retry_max_delay", base.DefaultRetryPolicy().MaxDelay, "Maximum delay between retries of a failed reconcile")
+
+	dryRun            = flag.Bool("dry_run", false, "Only preview where the root Deployments would be placed, in their experimental.kcp.dev/placement-decision annotation, without creating, changing or deleting any leaf")
+	hpaMode           = flag.String("hpa_mode", string(deployment.HPAModeOff), "How to handle HorizontalPodAutoscalers targeting root Deployments, whose CRD must be applied to kcp first: off, root to scale roots as they ask for, or mirror to also mirror them to each cluster")
+	splitStatefulSets = flag.Bool("split_statefulsets", true, "Also split StatefulSets, partitioning their ordinals across clusters")
+	splitJobs         = flag.Bool("split_jobs", false, "Also split Jobs and CronJobs, whose CRDs must be applied to kcp first")
+	splitIngresses    = flag.Bool("split_ingresses", false, "Also replicate Ingresses to the clusters their Services are mirrored to and aggregate their status, whose CRD must be applied to kcp first; requires --split_services")
+	ingressDNSTargets = flag.Bool("ingress_dns_targets", false, "Publish the load-balancer addresses of every cluster in the external-dns target annotation of root Ingresses")
+	networkPolicies   = flag.Bool("network_policies", false, "Warn root Deployments of the NetworkPolicies selecting their pods that clusters they're placed on don't enforce, whose CRD must be applied to kcp first")
+	splitServices     = flag.Bool("split_services", false, "Also mirror Services to the clusters their workloads are on and aggregate their EndpointSlices, whose CRD must be applied to kcp first")
+	exportServices    = flag.Bool("export_services", false, "Also derive a ServiceImport from each ServiceExport, for the syncers run with -service_imports to program on their cluster, whose CRDs must be applied to kcp first; requires --split_services")
+	pullSecrets       = flag.Bool("registry_credentials", false, "Copy the registry credentials of the RegistryCredentialPolicies, whose CRD must be applied to kcp first, to the namespaces they select, and add them to the image pull secrets of the leafs placed there")
+	distributeSecrets = flag.Bool("distribute_secrets", false, "Also distribute the Secrets selected by SecretDistributions to the clusters they select, whose CRD must be applied to kcp first")
+	splitParallelism  = flag.Int("split_parallelism", base.DefaultParallelism, "How many clusters to write the leafs of a root to at once, reporting those that failed in its status")
+
+	schedulerPlugins         = flag.String("scheduler_plugins", "", "Comma-separated scheduler plugins to filter and score clusters with after the PlacementPolicy, e.g. ClusterSelector,LeastRequested")
+	schedulerExtenderURL     = flag.String("scheduler_extender_url", "", "URL of an HTTP scheduler extender to POST each workload and its candidate clusters to; empty to disable")
+	schedulerExtenderTimeout = flag.Duration("scheduler_extender_timeout", scheduler.DefaultExtenderTimeout, "How long a call to the scheduler extender may take")
+	costPricingURL           = flag.String("cost_pricing_url", "", "URL the Cost scheduler plugin GETs the prices of clusters from, as a JSON object by cluster name; empty to only read their experimental.kcp.dev/cost annotation")
+	costRefreshInterval      = flag.Duration("cost_refresh_interval", scheduler.DefaultCostRefreshInterval, "How often the Cost scheduler plugin fetches the prices of clusters again from --cost_pricing_url")
+	latencyMatrix            = flag.String("latency_matrix", "", "JSON file of the latencies between regions, e.g. {\"eu-west-1\":{\"us-east-1\":\"80ms\"}}, for the Latency scheduler plugin to favor the clusters closest to the client regions of workloads")
+
+	leafTransforms    = flag.String("leaf_transforms", "", "Comma-separated transforms to apply to the leafs placed on each cluster, following the cluster's annotations, e.g. ImageRegistry,NodeSelector,Env,DefaultRequests")
+	workloadOverrides = flag.Bool("workload_overrides", false, "Apply the WorkloadOverrides of their namespace to the leafs placed on each cluster, after --leaf_transforms")
+
+	leaderElect          = flag.Bool("leader_elect", false, "Use leader election so that only one replica of the splitter reconciles at a time")
+	leaderElectNamespace = flag.String("leader_elect_namespace", "default", "Namespace of the Lease used for leader election")
+	leaderElectName      = flag.String("leader_elect_name", "deployment-splitter", "Name of the Lease used for leader election")
+
+	allWorkspaces = flag.Bool("all_workspaces", false, "Split the workloads of every workspace, each across its own clusters, rather than only those of the logical cluster the kubeconfig reaches, which must be the admin one")
+
+	shard            = flag.String("shard", "", "Name of the kcp shard the kubeconfig reaches, to only split the workloads of the workspaces assigned to it; requires --shards_kubeconfig")
+	shardsKubeconfig = flag.String("shards_kubeconfig", "", "Path to a kubeconfig with a context per kcp shard, whose current one is the root shard")
+
+	splits splitFlag
+)
+
+func init() {
+	flag.Var(&splits, "split", "Also split another resource, as <resource>.<version>.<group>=<strategy> where strategy is replicas, replicas:<field path>, duplicate or pin; may be repeated")
+}
+
+// controller is what the splitters have in common, from base.Controller.
+type controller interface {
+	SetWorkspaceFilter(owns func(workspace string) bool)
+	SetParallelism(n int)
+	Start(ctx context.Context, numThreads int, drainTimeout time.Duration)
+}
+
+// splitFlag collects repeated --split flags.
+type splitFlag []string
+
+func (f *splitFlag) String() string     { return strings.Join(*f, ",") }
+func (f *splitFlag) Set(v string) error { *f = append(*f, v); return nil }
 
 func main() {
+	klog.InitFlags(nil)
 	flag.Parse()
 
 	r, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
-		log.Fatal(err)
+		klog.Fatal(err)
+	}
+	tracing.WrapConfig(r)
+	shutdownTracing, err := tracing.Setup("kcp-deployment-splitter", *tracingEndpoint, *tracingSamplingRate)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+	if *allWorkspaces {
+		// Leases stay in the admin logical cluster.
+		resources := []string{"deployments", "statefulsets", "jobs", "cronjobs", "services", "endpointslices", "ingresses", "horizontalpodautoscalers", "secrets", "namespaces", "clusters", "placementpolicies", "workspacequotas", "workloadoverrides", "clusterdisruptionbudgets", "secretdistributions", "registrycredentialpolicies", "serviceexports", "serviceimports", "networkpolicies", "persistentvolumeclaims"}
+		for _, s := range splits {
+			if gvr, _, err := splitter.ParseSplit(s); err == nil {
+				resources = append(resources, gvr.Resource)
+			}
+		}
+		clientutils.EnableMultiCluster(r, nil, resources...)
 	}
 
-	deployment.NewController(r).Start(numThreads)
+	// Each controller holds its own lease, named after the resource it splits.
+	leaderElectionFor := func(resource string) *base.LeaderElectionConfig {
+		if !*leaderElect {
+			return nil
+		}
+		leaderElection := base.DefaultLeaderElectionConfig()
+		leaderElection.LeaseNamespace = *leaderElectNamespace
+		leaderElection.LeaseName = *leaderElectName
+		if resource != "" {
+			leaderElection.LeaseName += "-" + resource
+		}
+		return leaderElection
+	}
+
+	hpa, err := deployment.ParseHPAMode(*hpaMode)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	var extender *scheduler.Extender
+	if *schedulerExtenderURL != "" {
+		extender = scheduler.NewExtender(*schedulerExtenderURL, *schedulerExtenderTimeout)
+	}
+	var plugins []string
+	if *schedulerPlugins != "" {
+		plugins = strings.Split(*schedulerPlugins, ",")
+	}
+	scheduler.SetCostPricing(*costPricingURL, *costRefreshInterval)
+	if *latencyMatrix != "" {
+		m, err := scheduler.LoadLatencyMatrix(*latencyMatrix)
+		if err != nil {
+			klog.Fatal(err)
+		}
+		scheduler.SetLatencyMatrix(m)
+	}
+	sched, err := scheduler.New(plugins, extender)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	var transformNames []string
+	if *leafTransforms != "" {
+		transformNames = strings.Split(*leafTransforms, ",")
+	}
+	transforms, err := transform.New(transformNames)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	retry := base.DefaultRetryPolicy()
+	retry.MaxRequeues = *maxRetries
+	retry.BaseDelay = *retryBaseDelay
+	retry.MaxDelay = *retryMaxDelay
+
+	metrics.Serve(*metricsAddr)
+	if *profiling {
+		if err := debug.Serve(*profilingAddr); err != nil {
+			klog.Fatal(err)
+		}
+	}
+
+	ctx := base.SignalContext()
+
+	if *workloadOverrides {
+		csif := externalversions.NewSharedInformerFactory(clusterclient.NewForConfigOrDie(r), 0)
+		overrides := csif.Cluster().V1alpha1().WorkloadOverrides().Informer()
+		transforms = append(transforms, transform.Overrides(logicalcluster.IndexerFor(overrides)))
+		csif.Start(ctx.Done())
+		csif.WaitForCacheSync(ctx.Done())
+	}
+	if *pullSecrets {
+		sif := kubeinformers.NewSharedInformerFactory(kubernetes.NewForConfigOrDie(r), 0)
+		secrets := sif.Core().V1().Secrets().Informer()
+		transforms = append(transforms, transform.PullSecrets(logicalcluster.IndexerFor(secrets)))
+		sif.Start(ctx.Done())
+		sif.WaitForCacheSync(ctx.Done())
+	}
+
+	var owns func(workspace string) bool
+	if *shard != "" {
+		shards, err := sharding.Load(*shardsKubeconfig)
+		if err != nil {
+			klog.Fatal(err)
+		}
+		if owns, err = shards.Filter(ctx, *shard); err != nil {
+			klog.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	start := func(c controller) {
+		if owns != nil {
+			c.SetWorkspaceFilter(owns)
+		}
+		c.SetParallelism(*splitParallelism)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Start(ctx, numThreads, *drainTimeout)
+		}()
+	}
+	deployments := deployment.NewController(r, hpa, *networkPolicies, sched, transforms, leaderElectionFor(""), retry, nil)
+	deployments.SetDryRun(*dryRun)
+	start(deployments)
+	start(quota.NewController(r, leaderElectionFor("workspacequotas"), nil))
+	if *splitStatefulSets {
+		start(statefulset.NewController(r, sched, transforms, leaderElectionFor("statefulsets"), retry, nil))
+	}
+	if *splitJobs {
+		start(batch.NewJobController(r, sched, transforms, leaderElectionFor("jobs"), retry, nil))
+		start(batch.NewCronJobController(r, sched, transforms, leaderElectionFor("cronjobs"), retry, nil))
+	}
+	if *splitServices {
+		start(service.NewController(r, leaderElectionFor("services"), retry, nil))
+	}
+	if *exportServices {
+		start(serviceexport.NewController(r, leaderElectionFor("serviceexports"), retry, nil))
+	}
+	if *splitIngresses {
+		start(ingress.NewController(r, *ingressDNSTargets, leaderElectionFor("ingresses"), retry, nil))
+	}
+	if *pullSecrets {
+		start(pullsecret.NewController(r, leaderElectionFor("registrycredentialpolicies"), retry, nil))
+	}
+	if *distributeSecrets {
+		start(secret.NewController(r, leaderElectionFor("secrets"), retry, nil))
+	}
+	for _, s := range splits {
+		gvr, strategy, err := splitter.ParseSplit(s)
+		if err != nil {
+			klog.Fatal(err)
+		}
+		start(splitter.NewController(r, gvr, strategy, sched, transforms, leaderElectionFor(gvr.Resource), retry, nil))
+	}
+	wg.Wait()
 }
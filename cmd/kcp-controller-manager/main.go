@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/debug"
+	"github.com/kcp-dev/kcp/pkg/health"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/apiimport"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/batch"
+	"github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/deployment"
+	"github.com/kcp-dev/kcp/pkg/reconciler/descheduler"
+	"github.com/kcp-dev/kcp/pkg/reconciler/drain"
+	"github.com/kcp-dev/kcp/pkg/reconciler/eviction"
+	"github.com/kcp-dev/kcp/pkg/reconciler/ingress"
+	"github.com/kcp-dev/kcp/pkg/reconciler/negotiation"
+	"github.com/kcp-dev/kcp/pkg/reconciler/pullsecret"
+	"github.com/kcp-dev/kcp/pkg/reconciler/quota"
+	"github.com/kcp-dev/kcp/pkg/reconciler/secret"
+	"github.com/kcp-dev/kcp/pkg/reconciler/service"
+	"github.com/kcp-dev/kcp/pkg/reconciler/serviceexport"
+	"github.com/kcp-dev/kcp/pkg/reconciler/statefulset"
+	"github.com/kcp-dev/kcp/pkg/reconciler/usage"
+	"github.com/kcp-dev/kcp/pkg/reconciler/workspace"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/controlplane/clientutils"
+)
+
+const numThreads = 2
+
+// defaultControllers are the controllers run unless --controllers says
+// otherwise. The others need CRDs applied to kcp first, or more flags.
+var defaultControllers = map[string]bool{
+	"deployment":    true,
+	"statefulset":   true,
+	"quota":         true,
+	"cluster":       true,
+	"eviction":      true,
+	"drain":         true,
+	"negotiation":   true,
+	"workspace":     true,
+	"job":           false,
+	"cronjob":       false,
+	"service":       false,
+	"ingress":       false,
+	"apiimport":     false,
+	"secret":        false,
+	"pullsecret":    false,
+	"serviceexport": false,
+	"descheduler":   false,
+	"usage":         false,
+}
+
+var (
+	kubeconfigPath = flag.String("kubeconfig", "", "Path to a kubeconfig reaching the admin logical cluster of kcp")
+	controllers    = flag.String("controllers", "*", "Comma-separated controllers to run: * for those on by default, <name> to also run one, -<name> not to; "+controllerNames())
+	drainTimeout   = flag.Duration("drain_timeout", base.DefaultDrainTimeout, "How long to wait for queued work to finish on shutdown")
+	metricsAddr    = flag.String("metrics_addr", ":8080", "Address to serve Prometheus metrics on; empty to disable")
+	profiling      = flag.Bool("profiling", false, "Serve the pprof profiles on /debug/pprof and the runtime metrics, such as the number of goroutines, on /debug/vars at --profiling_addr")
+	profilingAddr  = flag.String("profiling_addr", debug.DefaultAddr, "Loopback address to serve the debug endpoints of --profiling on")
+	healthAddr     = flag.String("health_addr", ":8082", "Address to serve /healthz, /livez and /readyz on, reporting the liveness of the controllers and whether their caches have synced; empty to disable")
+
+	tracingEndpoint     = flag.String("tracing_endpoint", "", "URL of the OTLP/HTTP collector to export the traces of the reconciles to, e.g. http://otel-collector:4318; empty to disable")
+	tracingSamplingRate = flag.Float64("tracing_sampling_rate", tracing.DefaultSamplingRate, "Fraction of the reconciles to trace, besides those that are part of a trace already")
+
+	leaderElect          = flag.Bool("leader_elect", false, "Use leader election so that only one replica of the controller manager runs its controllers at a time")
+	leaderElectNamespace = flag.String("leader_elect_namespace", "default", "Namespace of the Lease used for leader election")
+	leaderElectName      = flag.String("leader_elect_name", "kcp-controller-manager", "Name of the Lease used for leader election")
+
+	maxRetries     = flag.Int("max_retries", base.DefaultRetryPolicy().MaxRequeues, "How many times to retry a failed reconcile before dropping it until the object changes")
+	retryBaseDelay = flag.Duration("retry_base_delay", base.DefaultRetryPolicy().BaseDelay, "Delay before the first retry of a failed reconcile, doubled on each further failure")
+	retryMaxDelay  = flag.Duration("retry_max_delay", base.DefaultRetryPolicy().MaxDelay, "Maximum delay between retries of a failed reconcile")
+
+	resyncPeriod  = flag.Duration("resync_period", 10*time.Hour, "How often the informers of the controllers resync, handing them every object again; zero never to, retrying the reconciles dropped after --max_retries every --retry_max_delay instead")
+	resyncPeriods = flag.String("resync_periods", "", "Comma-separated resource=period pairs overriding --resync_period for the informers of those resources, e.g. clusters=1h,deployments=0")
+	listPageSize  = flag.Int64("list_page_size", base.DefaultListPageSize, "How many objects the informers of the controllers list at a time, from etcd rather than the watch cache of the server; zero to list everything at once")
+
+	splitParallelism = flag.Int("split_parallelism", base.DefaultParallelism, "How many clusters the splitters write the leafs of a root to at once, reporting those that failed in its status")
+
+	dryRun            = flag.Bool("dry_run", false, "Only preview where the deployment controller would place the root Deployments, in their experimental.kcp.dev/placement-decision annotation")
+	networkPolicies   = flag.Bool("network_policies", false, "Make the deployment controller warn root Deployments of the NetworkPolicies selecting their pods that clusters they're placed on don't enforce, whose CRD must be applied to kcp first")
+	hpaMode           = flag.String("hpa_mode", string(deployment.HPAModeOff), "How the deployment controller handles HorizontalPodAutoscalers targeting root Deployments, whose CRD must be applied to kcp first: off, root or mirror")
+	schedulerPlugins  = flag.String("scheduler_plugins", "", "Comma-separated scheduler plugins to filter and score clusters with after the PlacementPolicy, e.g. ClusterSelector,LeastRequested")
+	costPricingURL    = flag.String("cost_pricing_url", "", "URL the Cost scheduler plugin GETs the prices of clusters from, as a JSON object by cluster name; empty to only read their experimental.kcp.dev/cost annotation")
+	costRefresh       = flag.Duration("cost_refresh_interval", scheduler.DefaultCostRefreshInterval, "How often the Cost scheduler plugin fetches the prices of clusters again from --cost_pricing_url")
+	latencyMatrix     = flag.String("latency_matrix", "", "JSON file of the latencies between regions, e.g. {\"eu-west-1\":{\"us-east-1\":\"80ms\"}}, for the Latency scheduler plugin to favor the clusters closest to the client regions of workloads")
+	leafTransforms    = flag.String("leaf_transforms", "", "Comma-separated transforms to apply to the leafs placed on each cluster, e.g. ImageRegistry,NodeSelector,Env,DefaultRequests")
+	workloadOverrides = flag.Bool("workload_overrides", false, "Apply the WorkloadOverrides of their namespace to the leafs placed on each cluster, after --leaf_transforms")
+	ingressDNSTargets = flag.Bool("ingress_dns_targets", false, "Publish the load-balancer addresses of every cluster in the external-dns target annotation of root Ingresses")
+
+	syncerImage     = flag.String("syncer_image", "", "Syncer image to install on clusters")
+	pullModel       = flag.Bool("pull_model", true, "Deploy the syncer in registered physical clusters in POD, and have it sync resources from KCP")
+	driftMode       = flag.String("syncer_drift_mode", string(syncer.DriftRevert), "What the syncers do about synced objects changed on their cluster: revert, report or adopt")
+	syncerTokenTTL  = flag.Duration("syncer_token_ttl", 0, "Issue the syncers installed with the pull model ServiceAccount tokens valid for this long, replaced before they expire, rather than the credentials of the kubeconfig; zero to disable")
+	resourcesToSync = flag.String("resources_to_sync", "pods,deployments", "Comma-separated resources the syncers sync")
+
+	syncerTransformers    = flag.String("syncer_transformers", "", "Comma-separated transformers the syncers run on the objects they sync, after the namespace and ServiceAccount mappings of their cluster's annotations")
+	syncerUpsync          = flag.String("syncer_upsync", "", "Comma-separated resources whose objects on each cluster the syncers mirror to kcp, in the namespaces synced objects are in, e.g. pods,replicasets,events")
+	syncerRBACVerbs       = flag.String("syncer_rbac_verbs", "", "Comma-separated verbs the Roles bound to the ServiceAccounts of synced objects may grant on each cluster, for the syncers to sync them along with their RoleBindings, e.g. get,list,watch; empty not to sync RBAC")
+	syncerServiceImports  = flag.Bool("syncer_service_imports", false, "Make the syncers program the ServiceImports derived from ServiceExports as Multi-Cluster Services API ServiceImports on each cluster, whose CRD must be installed there")
+	syncerNetworkPolicies = flag.Bool("syncer_network_policies", false, "Make the syncers of the clusters that enforce NetworkPolicies sync those selecting the pods of synced objects, whose CRD must be applied to kcp first")
+
+	evictionToleration = flag.Duration("eviction_toleration", eviction.DefaultToleration, "How long a cluster may stay NotReady before its workloads are moved to other clusters")
+	importAPIGroups    = flag.String("import_api_groups", "", "Comma-separated API groups for the apiimport controller to import the resources of from the registered clusters into kcp as CRDs, with core for the core group and * for every group")
+
+	descheduleInterval     = flag.Duration("deschedule_interval", descheduler.DefaultOptions().Interval, "How often the descheduler checks the load of the clusters, by the resources their pods request")
+	descheduleHigh         = flag.Float64("deschedule_high_utilization", descheduler.DefaultOptions().HighUtilization, "Fraction of the allocatable cpu or memory of a cluster requested above which the descheduler moves replicas of split Deployments off it")
+	descheduleLow          = flag.Float64("deschedule_low_utilization", descheduler.DefaultOptions().LowUtilization, "Fraction of the allocatable cpu and memory of a cluster requested below which the descheduler moves replicas to it, and lets them back")
+	descheduleMaxReplicas  = flag.Int("deschedule_max_replicas", int(descheduler.DefaultOptions().MaxReplicas), "How many replicas the descheduler moves off a cluster at most every --deschedule_interval")
+	descheduleReleaseAfter = flag.Int("deschedule_release_after", int(descheduler.DefaultOptions().ReleaseAfter), "How many checks in a row a cluster must be below --deschedule_low_utilization before the descheduler lets the replicas it moved back")
+	descheduleDryRun       = flag.Bool("deschedule_dry_run", false, "Only report, in Events on the root Deployments, the replicas the descheduler would move")
+
+	usageInterval = flag.Duration("usage_interval", usage.DefaultOptions().Interval, "How often the usage controller counts the objects of every workspace")
+	usageStatus   = flag.Bool("usage_status", false, "Make the usage controller also write what each workspace stores to the status.usage of its Workspace")
+
+	shardsKubeconfig = flag.String("shards_kubeconfig", "", "Path to a kubeconfig with a context per kcp shard, whose current one is that of --kubeconfig, to assign the Workspaces to; empty not to shard")
+	workspacesURL    = flag.String("workspaces_url", "", "URL the Workspaces are reached under, such as that of the front proxy of the shards; the server of --kubeconfig if empty")
+)
+
+// controller is what the controllers built on base.Controller have in common.
+type controller interface {
+	Start(ctx context.Context, numThreads int, drainTimeout time.Duration)
+	LivenessCheck() healthz.HealthChecker
+	ReadinessCheck() healthz.HealthChecker
+	SetDroppedRequeue(after time.Duration)
+	SetParallelism(n int)
+}
+
+func controllerNames() string {
+	var names []string
+	for name := range defaultControllers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseControllers returns the controllers enabled by the value of
+// --controllers.
+func parseControllers(value string) (map[string]bool, error) {
+	enabled := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case name == "*":
+			for name, on := range defaultControllers {
+				if on {
+					enabled[name] = true
+				}
+			}
+		case strings.HasPrefix(name, "-"):
+			if _, found := defaultControllers[name[1:]]; !found {
+				return nil, fmt.Errorf("unknown controller %q", name[1:])
+			}
+			delete(enabled, name[1:])
+		default:
+			if _, found := defaultControllers[name]; !found {
+				return nil, fmt.Errorf("unknown controller %q", name)
+			}
+			enabled[name] = true
+		}
+	}
+	return enabled, nil
+}
+
+func main() {
+	klog.InitFlags(nil)
+	flag.Parse()
+
+	o, err := parseOptions()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	enabled, transforms, retry, resync := o.enabled, o.transforms, o.retry, o.resync
+
+	configLoader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeconfigPath},
+		&clientcmd.ConfigOverrides{})
+	r, err := configLoader.ClientConfig()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	tracing.WrapConfig(r)
+	shutdownTracing, err := tracing.Setup("kcp-controller-manager", *tracingEndpoint, *tracingSamplingRate)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+	kubeconfig, err := configLoader.RawConfig()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	// Workspaces and the Lease are only in the admin logical cluster; the
+	// other controllers reconcile every workspace, each across its own
+	// clusters.
+	adminConfig := rest.CopyConfig(r)
+	clientutils.EnableMultiCluster(r, nil,
+		"clusters", "customresourcedefinitions", "secrets", "negotiatedapiresources", "namespaces", "serviceaccounts",
+		"deployments", "statefulsets", "jobs", "cronjobs", "services", "endpointslices", "ingresses", "horizontalpodautoscalers",
+		"placementpolicies", "workspacequotas", "workloadoverrides", "clusterdisruptionbudgets", "secretdistributions", "registrycredentialpolicies", "serviceexports", "serviceimports", "networkpolicies", "persistentvolumeclaims")
+
+	metrics.Serve(*metricsAddr)
+	if *profiling {
+		if err := debug.Serve(*profilingAddr); err != nil {
+			klog.Fatal(err)
+		}
+	}
+	// The checks of the controllers are added as they start, once the lease
+	// is acquired with --leader_elect; until then, the replica has none.
+	var live, ready health.Checks
+	health.Serve(*healthAddr, &live, &ready)
+
+	ctx := base.SignalContext()
+
+	run := func(ctx context.Context) {
+		// The controllers share the informers of each resource, started
+		// once they've all asked for theirs.
+		informers, err := base.NewInformersWithResync(r, resync, *listPageSize)
+		if err != nil {
+			klog.Fatal(err)
+		}
+		if *workloadOverrides {
+			overrides := informers.Cluster.Cluster().V1alpha1().WorkloadOverrides().Informer()
+			transforms = append(transforms, transform.Overrides(logicalcluster.IndexerFor(overrides)))
+		}
+		if enabled["pullsecret"] {
+			// The leafs pull with the credentials it copies.
+			secrets := informers.Kube.Core().V1().Secrets().Informer()
+			transforms = append(transforms, transform.PullSecrets(logicalcluster.IndexerFor(secrets)))
+		}
+
+		var started []controller
+		add := func(name string, newController func() controller) {
+			if enabled[name] {
+				klog.Infof("Starting the %s controller", name)
+				c := newController()
+				if resync.Period == 0 {
+					c.SetDroppedRequeue(retry.MaxDelay)
+				}
+				c.SetParallelism(*splitParallelism)
+				live.Add(c.LivenessCheck())
+				ready.Add(c.ReadinessCheck())
+				started = append(started, c)
+			}
+		}
+		add("deployment", func() controller {
+			c := deployment.NewController(r, o.hpa, *networkPolicies, o.sched, transforms, nil, retry, informers)
+			c.SetDryRun(*dryRun)
+			return c
+		})
+		add("statefulset", func() controller {
+			return statefulset.NewController(r, o.sched, transforms, nil, retry, informers)
+		})
+		add("job", func() controller { return batch.NewJobController(r, o.sched, transforms, nil, retry, informers) })
+		add("cronjob", func() controller { return batch.NewCronJobController(r, o.sched, transforms, nil, retry, informers) })
+		add("service", func() controller { return service.NewController(r, nil, retry, informers) })
+		add("serviceexport", func() controller { return serviceexport.NewController(r, nil, retry, informers) })
+		add("ingress", func() controller { return ingress.NewController(r, *ingressDNSTargets, nil, retry, informers) })
+		add("quota", func() controller { return quota.NewController(r, nil, informers) })
+		add("eviction", func() controller { return eviction.NewController(r, *evictionToleration, informers) })
+		add("drain", func() controller { return drain.NewController(r, informers) })
+		add("descheduler", func() controller {
+			return descheduler.NewController(r, descheduler.Options{
+				Interval:        *descheduleInterval,
+				HighUtilization: *descheduleHigh,
+				LowUtilization:  *descheduleLow,
+				MaxReplicas:     int32(*descheduleMaxReplicas),
+				ReleaseAfter:    int32(*descheduleReleaseAfter),
+				DryRun:          *descheduleDryRun,
+			}, informers)
+		})
+		// The Workspaces are those of the admin logical cluster, as they
+		// aren't watched across the workspaces.
+		add("usage", func() controller {
+			return usage.NewController(r, usage.Options{Interval: *usageInterval, UpdateStatus: *usageStatus}, informers)
+		})
+		add("negotiation", func() controller { return negotiation.NewController(r, informers) })
+		add("apiimport", func() controller { return apiimport.NewController(r, o.groups, informers) })
+		add("secret", func() controller { return secret.NewController(r, nil, retry, informers) })
+		add("pullsecret", func() controller { return pullsecret.NewController(r, nil, retry, informers) })
+		// The workspace controller watches the admin logical cluster alone.
+		add("workspace", func() controller { return workspace.NewController(adminConfig, *workspacesURL, o.shards) })
+		if enabled["cluster"] {
+			klog.Info("Starting the cluster controller")
+			// It stops with the process.
+			c := cluster.NewController(r, *syncerImage, kubeconfig, strings.Split(*resourcesToSync, ","), *pullModel, o.driftMode, *syncerTokenTTL, informers)
+			c.SetSyncerTransformers(o.transformers)
+			c.SetSyncerUpsync(o.upsync)
+			c.SetSyncerRBACVerbs(o.rbacVerbs)
+			c.SetSyncerServiceImports(*syncerServiceImports)
+			c.SetSyncerNetworkPolicies(*syncerNetworkPolicies)
+			if resync.Period == 0 {
+				c.SetDroppedRequeue(retry.MaxDelay)
+			}
+			live.Add(c.LivenessCheck())
+			ready.Add(c.ReadinessCheck())
+			go c.Start(numThreads)
+		}
+		informers.Start(ctx.Done())
+
+		var wg sync.WaitGroup
+		for _, c := range started {
+			wg.Add(1)
+			go func(c controller) {
+				defer wg.Done()
+				c.Start(ctx, numThreads, *drainTimeout)
+			}(c)
+		}
+		wg.Wait()
+		klog.Info("Stopped the controllers")
+	}
+
+	// Every replica registers the CRDs, for those of an upgraded build to
+	// update their schemas before the leader's controllers watch them.
+	if err := cluster.RegisterClusterCRD(adminConfig, nil); err != nil {
+		klog.Fatal(err)
+	}
+
+	if !*leaderElect {
+		run(ctx)
+		return
+	}
+
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	id += "_" + string(uuid.NewUUID())
+	client := kubernetes.NewForConfigOrDie(adminConfig)
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, *leaderElectNamespace, *leaderElectName,
+		client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: id})
+	if err != nil {
+		klog.Fatal(err)
+	}
+	leaderElection := base.DefaultLeaderElectionConfig()
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaderElection.LeaseDuration,
+		RenewDeadline:   leaderElection.RenewDeadline,
+		RetryPeriod:     leaderElection.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            *leaderElectName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("Acquired lease %s/%s", *leaderElectNamespace, *leaderElectName)
+				run(ctx)
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-ctx.Done():
+					klog.Info("Released leader election lease")
+				default:
+					// Another replica may already be reconciling; exit rather than fight over leafs.
+					klog.Fatalf("Lost leader election lease %s/%s", *leaderElectNamespace, *leaderElectName)
+				}
+			},
+			OnNewLeader: func(identity string) {
+				klog.Infof("New leader elected: %s", identity)
+			},
+		},
+	})
+}
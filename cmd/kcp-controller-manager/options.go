@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/reconciler/apiimport"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/deployment"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/sharding"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	"github.com/kcp-dev/kcp/pkg/transform"
+)
+
+// options are the flags of the controller manager, parsed and checked
+// before it connects to kcp.
+type options struct {
+	// enabled are the controllers to run, by name.
+	enabled map[string]bool
+
+	hpa        deployment.HPAMode
+	driftMode  syncer.DriftMode
+	sched      *scheduler.Scheduler
+	transforms transform.Chain
+
+	transformers []string
+	upsync       []string
+	rbacVerbs    []string
+	groups       []string
+	shards       *sharding.Shards
+
+	retry  *base.RetryPolicy
+	resync base.Resync
+}
+
+// splitList splits a comma-separated flag, empty for none.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// parseOptions parses the flags, and checks that the enabled controllers
+// have those they require.
+func parseOptions() (*options, error) {
+	o := &options{}
+	var err error
+	if o.enabled, err = parseControllers(*controllers); err != nil {
+		return nil, err
+	}
+	if o.hpa, err = deployment.ParseHPAMode(*hpaMode); err != nil {
+		return nil, err
+	}
+	if o.driftMode, err = syncer.ParseDriftMode(*driftMode); err != nil {
+		return nil, err
+	}
+
+	scheduler.SetCostPricing(*costPricingURL, *costRefresh)
+	if *latencyMatrix != "" {
+		m, err := scheduler.LoadLatencyMatrix(*latencyMatrix)
+		if err != nil {
+			return nil, err
+		}
+		scheduler.SetLatencyMatrix(m)
+	}
+	if o.sched, err = scheduler.New(splitList(*schedulerPlugins), nil); err != nil {
+		return nil, err
+	}
+	if o.transforms, err = transform.New(splitList(*leafTransforms)); err != nil {
+		return nil, err
+	}
+	o.transformers = splitList(*syncerTransformers)
+	if _, err := syncer.NewPipeline(o.transformers); err != nil {
+		return nil, err
+	}
+	o.upsync = splitList(*syncerUpsync)
+	o.rbacVerbs = splitList(*syncerRBACVerbs)
+
+	o.groups = apiimport.ParseGroups(*importAPIGroups)
+	if o.enabled["apiimport"] && len(o.groups) == 0 {
+		return nil, errors.New("the apiimport controller requires --import_api_groups")
+	}
+	if o.enabled["descheduler"] {
+		if *descheduleInterval <= 0 {
+			return nil, errors.New("--deschedule_interval must be positive")
+		}
+		if *descheduleLow <= 0 || *descheduleLow > *descheduleHigh || *descheduleHigh > 1 {
+			return nil, fmt.Errorf("--deschedule_low_utilization %v and --deschedule_high_utilization %v must be fractions, the low one no higher than the high one", *descheduleLow, *descheduleHigh)
+		}
+	}
+	if o.enabled["usage"] && *usageInterval <= 0 {
+		return nil, errors.New("--usage_interval must be positive")
+	}
+	if *shardsKubeconfig != "" {
+		if o.shards, err = sharding.Load(*shardsKubeconfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if *maxRetries < 0 {
+		return nil, errors.New("--max_retries must not be negative")
+	}
+	if *retryBaseDelay <= 0 || *retryBaseDelay > *retryMaxDelay {
+		return nil, fmt.Errorf("--retry_base_delay %v must be positive, and no longer than --retry_max_delay %v", *retryBaseDelay, *retryMaxDelay)
+	}
+	o.retry = base.DefaultRetryPolicy()
+	o.retry.MaxRequeues = *maxRetries
+	o.retry.BaseDelay = *retryBaseDelay
+	o.retry.MaxDelay = *retryMaxDelay
+	if o.resync, err = base.ParseResync(*resyncPeriod, *resyncPeriods); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
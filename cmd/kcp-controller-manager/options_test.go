@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/deployment"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+)
+
+// setFlags sets the flags to the values until the end of the test.
+func setFlags(t *testing.T, values map[string]string) {
+	for name, value := range values {
+		f := flag.Lookup(name)
+		if f == nil {
+			t.Fatalf("no flag --%s", name)
+		}
+		old := f.Value.String()
+		if err := f.Value.Set(value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = f.Value.Set(old) })
+	}
+}
+
+func defaultsWith(names ...string) map[string]bool {
+	enabled := map[string]bool{}
+	for name, on := range defaultControllers {
+		if on {
+			enabled[name] = true
+		}
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "-") {
+			delete(enabled, name[1:])
+		} else {
+			enabled[name] = true
+		}
+	}
+	return enabled
+}
+
+func TestParseControllers(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  map[string]bool
+		err   string
+	}{
+		{value: "*", want: defaultsWith()},
+		{value: " * , job ,-quota", want: defaultsWith("job", "-quota")},
+		{value: "job,cronjob", want: map[string]bool{"job": true, "cronjob": true}},
+		{value: "-deployment,*", want: defaultsWith()},
+		{value: "*,-deployment,-statefulset", want: defaultsWith("-deployment", "-statefulset")},
+		{value: "", want: map[string]bool{}},
+		{value: "*,replicaset", err: `unknown controller "replicaset"`},
+		{value: "*,-replicaset", err: `unknown controller "replicaset"`},
+	} {
+		got, err := parseControllers(tc.value)
+		switch {
+		case tc.err != "":
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q: got %v, want it to contain %q", tc.value, err, tc.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tc.value, err)
+		case !reflect.DeepEqual(got, tc.want):
+			t.Errorf("%q: got %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestDefaultControllers(t *testing.T) {
+	// Those needing CRDs applied to kcp first, or more flags, are off.
+	for _, name := range []string{"job", "cronjob", "service", "ingress", "apiimport", "secret", "pullsecret", "serviceexport", "descheduler", "usage"} {
+		if on, found := defaultControllers[name]; !found || on {
+			t.Errorf("%s: on by default %v, found %v", name, on, found)
+		}
+	}
+	if names := controllerNames(); !strings.HasPrefix(names, "apiimport, cluster, cronjob,") {
+		t.Errorf("got sorted names %s", names)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags map[string]string
+		err   string
+		check func(t *testing.T, o *options)
+	}{{
+		name: "defaults",
+		check: func(t *testing.T, o *options) {
+			if !reflect.DeepEqual(o.enabled, defaultsWith()) {
+				t.Errorf("enabled %v", o.enabled)
+			}
+			if o.hpa != deployment.HPAModeOff || o.driftMode != syncer.DriftRevert {
+				t.Errorf("got HPA mode %q, drift mode %q", o.hpa, o.driftMode)
+			}
+			if want := base.DefaultRetryPolicy(); o.retry.MaxRequeues != want.MaxRequeues || o.retry.BaseDelay != want.BaseDelay || o.retry.MaxDelay != want.MaxDelay || o.retry.RetryImmediately == nil {
+				t.Errorf("got retry policy %+v", o.retry)
+			}
+			if o.resync.Period != 10*time.Hour {
+				t.Errorf("got resync %+v", o.resync)
+			}
+			if o.upsync != nil || o.rbacVerbs != nil || o.transformers != nil || o.groups != nil || o.shards != nil {
+				t.Errorf("got options %+v", o)
+			}
+		},
+	}, {
+		name: "set",
+		flags: map[string]string{
+			"controllers":         "*,apiimport,-quota",
+			"import_api_groups":   "core,apps",
+			"hpa_mode":            "mirror",
+			"syncer_drift_mode":   "adopt",
+			"syncer_upsync":       "pods,events",
+			"syncer_rbac_verbs":   "get,list",
+			"max_retries":         "0",
+			"retry_base_delay":    "1s",
+			"retry_max_delay":     "1m",
+			"resync_period":       "0",
+			"resync_periods":      "clusters=1h",
+			"scheduler_plugins":   "ClusterSelector,LeastRequested",
+			"leaf_transforms":     "ImageRegistry",
+			"syncer_transformers": "",
+		},
+		check: func(t *testing.T, o *options) {
+			if !reflect.DeepEqual(o.enabled, defaultsWith("apiimport", "-quota")) {
+				t.Errorf("enabled %v", o.enabled)
+			}
+			if !reflect.DeepEqual(o.groups, []string{"", "apps"}) {
+				t.Errorf("got groups %q", o.groups)
+			}
+			if o.hpa != deployment.HPAModeMirror || o.driftMode != syncer.DriftAdopt {
+				t.Errorf("got HPA mode %q, drift mode %q", o.hpa, o.driftMode)
+			}
+			if !reflect.DeepEqual(o.upsync, []string{"pods", "events"}) || !reflect.DeepEqual(o.rbacVerbs, []string{"get", "list"}) {
+				t.Errorf("got upsync %q, RBAC verbs %q", o.upsync, o.rbacVerbs)
+			}
+			if o.retry.MaxRequeues != 0 || o.retry.BaseDelay != time.Second || o.retry.MaxDelay != time.Minute {
+				t.Errorf("got retry policy %+v", o.retry)
+			}
+			if o.resync.Period != 0 || o.resync.Resources["clusters"] != time.Hour {
+				t.Errorf("got resync %+v", o.resync)
+			}
+			if len(o.transforms) != 1 {
+				t.Errorf("got %d leaf transforms", len(o.transforms))
+			}
+		},
+	}, {
+		name:  "unknown controller",
+		flags: map[string]string{"controllers": "*,replicaset"},
+		err:   `unknown controller "replicaset"`,
+	}, {
+		name:  "apiimport without groups",
+		flags: map[string]string{"controllers": "*,apiimport"},
+		err:   "requires --import_api_groups",
+	}, {
+		name:  "groups without apiimport",
+		flags: map[string]string{"import_api_groups": "apps"},
+	}, {
+		name:  "invalid HPA mode",
+		flags: map[string]string{"hpa_mode": "sometimes"},
+		err:   "sometimes",
+	}, {
+		name:  "invalid drift mode",
+		flags: map[string]string{"syncer_drift_mode": "ignore"},
+		err:   "ignore",
+	}, {
+		name:  "unknown scheduler plugin",
+		flags: map[string]string{"scheduler_plugins": "Random"},
+		err:   "Random",
+	}, {
+		name:  "unknown leaf transform",
+		flags: map[string]string{"leaf_transforms": "Rename"},
+		err:   "Rename",
+	}, {
+		name:  "unknown syncer transformer",
+		flags: map[string]string{"syncer_transformers": "Rename"},
+		err:   `unknown transformer "Rename"`,
+	}, {
+		name:  "missing latency matrix",
+		flags: map[string]string{"latency_matrix": "/nonexistent/latencies.json"},
+		err:   "latencies.json",
+	}, {
+		name:  "negative retries",
+		flags: map[string]string{"max_retries": "-1"},
+		err:   "--max_retries",
+	}, {
+		name:  "zero retry delay",
+		flags: map[string]string{"retry_base_delay": "0"},
+		err:   "--retry_base_delay",
+	}, {
+		name:  "retry delay over the maximum",
+		flags: map[string]string{"retry_base_delay": "1h", "retry_max_delay": "1m"},
+		err:   "--retry_max_delay",
+	}, {
+		name:  "negative resync period",
+		flags: map[string]string{"resync_period": "-1h"},
+		err:   "negative",
+	}, {
+		name:  "invalid resync periods",
+		flags: map[string]string{"resync_periods": "clusters"},
+		err:   "clusters",
+	}, {
+		name:  "descheduler thresholds inverted",
+		flags: map[string]string{"controllers": "*,descheduler", "deschedule_low_utilization": "0.9", "deschedule_high_utilization": "0.5"},
+		err:   "--deschedule_low_utilization",
+	}, {
+		name:  "descheduler threshold over 1",
+		flags: map[string]string{"controllers": "*,descheduler", "deschedule_high_utilization": "1.5"},
+		err:   "--deschedule_high_utilization",
+	}, {
+		name:  "descheduler thresholds of a disabled descheduler",
+		flags: map[string]string{"deschedule_low_utilization": "0.9", "deschedule_high_utilization": "0.5"},
+	}, {
+		name:  "descheduler interval",
+		flags: map[string]string{"controllers": "*,descheduler", "deschedule_interval": "0"},
+		err:   "--deschedule_interval",
+	}, {
+		name:  "usage interval",
+		flags: map[string]string{"controllers": "*,usage", "usage_interval": "0"},
+		err:   "--usage_interval",
+	}, {
+		name:  "missing shards kubeconfig",
+		flags: map[string]string{"shards_kubeconfig": "/nonexistent/shards.kubeconfig"},
+		err:   "shards.kubeconfig",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			setFlags(t, tc.flags)
+			o, err := parseOptions()
+			switch {
+			case tc.err != "":
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("got %v, want it to contain %q", err, tc.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case tc.check != nil:
+				tc.check(t, o)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/sharding"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/klog/v2"
+)
+
+var (
+	shardsKubeconfig = flag.String("shards_kubeconfig", "", "Path to a kubeconfig with a context per kcp shard, whose current one is the root shard")
+	listenAddr       = flag.String("listen_addr", ":8443", "Address to serve the proxied kcp API on")
+	tlsCertFile      = flag.String("tls_cert_file", "", "Path to the certificate to serve the proxied kcp API with")
+	tlsKeyFile       = flag.String("tls_key_file", "", "Path to the private key of --tls_cert_file")
+	healthInterval   = flag.Duration("health_interval", 5*time.Second, "How often to check the /readyz of the endpoints of the shards")
+
+	endpoints = endpointsFlag{}
+)
+
+func init() {
+	flag.Var(endpoints, "endpoints", "Endpoints of a shard to spread its requests over, as <shard>=<url>[,<url>...], rather than the server of its context; may be repeated")
+}
+
+// endpointsFlag collects repeated --endpoints flags.
+type endpointsFlag map[string][]string
+
+func (f endpointsFlag) String() string {
+	var shards []string
+	for shard, urls := range f {
+		shards = append(shards, shard+"="+strings.Join(urls, ","))
+	}
+	return strings.Join(shards, " ")
+}
+
+func (f endpointsFlag) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i <= 0 || i == len(v)-1 {
+		return fmt.Errorf("expected <shard>=<url>[,<url>...], got %q", v)
+	}
+	f[v[:i]] = append(f[v[:i]], strings.Split(v[i+1:], ",")...)
+	return nil
+}
+
+func main() {
+	klog.InitFlags(nil)
+	flag.Parse()
+
+	// The clients' credentials are passed through, so they must not be sent in the clear.
+	if *tlsCertFile == "" || *tlsKeyFile == "" {
+		klog.Fatal("--tls_cert_file and --tls_key_file are required")
+	}
+	shards, err := sharding.Load(*shardsKubeconfig)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	// Cancelled on SIGTERM/SIGINT; a second signal exits immediately.
+	stop := genericapiserver.SetupSignalHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+	proxy, err := sharding.NewProxy(ctx, shards, endpoints, *healthInterval)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	server := &http.Server{Addr: *listenAddr, Handler: proxy}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	klog.Infof("Routing the requests to %s to the shards %v", *listenAddr, shards.Names())
+	if err := server.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile); err != http.ErrServerClosed {
+		klog.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/pki"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+)
+
+// adminCertValidity is how long the client certificate of admin.kubeconfig,
+// issued anew on every start, is valid for.
+const adminCertValidity = 365 * 24 * time.Hour
+
+// servingHosts returns the hosts the serving certificate signed by the CA of
+// kcp is for: those of this machine, and the address kcp listens on.
+func servingHosts(bindAddress net.IP) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname)
+	}
+	if bindAddress != nil && !bindAddress.IsUnspecified() && !bindAddress.IsLoopback() {
+		hosts = append(hosts, bindAddress.String())
+	}
+	return hosts
+}
+
+// adminKubeconfig returns the kubeconfig of the admin and user logical
+// clusters of the server at host, verifying it with the CA and
+// authenticating with a client certificate of the CA in system:masters, for
+// the clients of this machine.
+func adminKubeconfig(ca *pki.CA, host string) (*clientcmdapi.Config, error) {
+	certPEM, keyPEM, err := ca.ClientCert("kcp-admin", []string{user.SystemPrivilegedGroup}, adminCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	config := clientcmdapi.NewConfig()
+	for name, path := range map[string]string{"admin": "", "user": "/clusters/user"} {
+		config.Clusters[name] = &clientcmdapi.Cluster{Server: host + path, CertificateAuthorityData: ca.CertPEM()}
+		config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: "admin"}
+	}
+	config.AuthInfos["admin"] = &clientcmdapi.AuthInfo{ClientCertificateData: certPEM, ClientKeyData: keyPEM}
+	config.CurrentContext = "admin"
+	return config, nil
+}
+
+// reloader is a certificate, or CA bundle, reloaded from its files, as the
+// dynamic providers of the serving certificates and client CAs are.
+type reloader interface {
+	Name() string
+	RunOnce() error
+}
+
+// reloadCertificatesOnSIGHUP reloads the serving certificates and client CAs
+// of the server from their files on SIGHUP, until stopCh is closed. The server
+// already reloads them when their files change, and every minute; SIGHUP is
+// for the rotations that shouldn't wait, such as when the files are replaced
+// in a way their watch misses.
+func reloadCertificatesOnSIGHUP(info *genericapiserver.SecureServingInfo, stopCh <-chan struct{}) {
+	if info == nil {
+		return
+	}
+	var reloaders []reloader
+	add := func(provider interface{}) {
+		if r, ok := provider.(reloader); ok {
+			reloaders = append(reloaders, r)
+		}
+	}
+	add(info.Cert)
+	for _, sni := range info.SNICerts {
+		add(sni)
+	}
+	add(info.ClientCA)
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sighup)
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-sighup:
+			}
+			for _, r := range reloaders {
+				if err := r.RunOnce(); err != nil {
+					klog.Errorf("Error reloading %s on SIGHUP: %v", r.Name(), err)
+					continue
+				}
+				klog.Infof("Reloaded %s on SIGHUP", r.Name())
+			}
+		}
+	}()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kcp-dev/kcp/pkg/apis/config/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// applyConfig sets the flags of kcp start to the values of the configuration
+// file, but for those given on the command line, which override it. The
+// fields the file leaves empty keep their flags' defaults.
+func applyConfig(fs *pflag.FlagSet, c *v1alpha1.KCPConfiguration) error {
+	values := map[string][]string{}
+	str := func(flag, v string) {
+		if v != "" {
+			values[flag] = []string{v}
+		}
+	}
+	list := func(flag string, v []string) {
+		if len(v) > 0 {
+			values[flag] = v
+		}
+	}
+	boolean := func(flag string, v *bool) {
+		if v != nil {
+			values[flag] = []string{strconv.FormatBool(*v)}
+		}
+	}
+	integer := func(flag string, v *int32) {
+		if v != nil {
+			values[flag] = []string{strconv.Itoa(int(*v))}
+		}
+	}
+	duration := func(flag string, v *metav1.Duration) {
+		if v != nil {
+			values[flag] = []string{v.Duration.String()}
+		}
+	}
+
+	str("bind-address", c.Serving.BindAddress)
+	integer("secure-port", c.Serving.SecurePort)
+	str("tls-cert-file", c.Serving.TLSCertFile)
+	str("tls-private-key-file", c.Serving.TLSPrivateKeyFile)
+	str("workspaces_url", c.Serving.WorkspacesURL)
+
+	str("root_directory", c.Storage.RootDirectory)
+	list("etcd-servers", c.Storage.EtcdServers)
+	str("etcd-certfile", c.Storage.EtcdCertFile)
+	str("etcd-keyfile", c.Storage.EtcdKeyFile)
+	str("etcd-cafile", c.Storage.EtcdCAFile)
+	str("etcd_restore_from", c.Storage.RestoreFrom)
+	duration("etcd_snapshot_interval", c.Storage.SnapshotInterval)
+	str("etcd_snapshot_location", c.Storage.SnapshotLocation)
+	integer("etcd_snapshots_kept", c.Storage.SnapshotsKept)
+	duration("etcd_defrag_interval", c.Storage.DefragInterval)
+	str("encryption-provider-config", c.Storage.EncryptionProviderConfig)
+	integer("default-watch-cache-size", c.Storage.DefaultWatchCacheSize)
+	if len(c.Storage.WatchCacheSizes) > 0 {
+		var pairs []string
+		for resource, n := range c.Storage.WatchCacheSizes {
+			pairs = append(pairs, resource+"#"+strconv.Itoa(int(n)))
+		}
+		sort.Strings(pairs)
+		str("watch-cache-sizes", strings.Join(pairs, ","))
+	}
+
+	boolean("install_cluster_controller", c.Controllers.InstallClusterController)
+	list("disabled_controllers", c.Controllers.Disabled)
+	duration("resync_period", c.Controllers.ResyncPeriod)
+	if len(c.Controllers.ResyncPeriods) > 0 {
+		var pairs []string
+		for resource, d := range c.Controllers.ResyncPeriods {
+			pairs = append(pairs, resource+"="+d.Duration.String())
+		}
+		sort.Strings(pairs)
+		str("resync_periods", strings.Join(pairs, ","))
+	}
+	integer("list_page_size", c.Controllers.ListPageSize)
+	if len(c.Controllers.ImportAPIGroups) > 0 {
+		str("import_api_groups", strings.Join(c.Controllers.ImportAPIGroups, ","))
+	}
+
+	str("syncer_image", c.Syncer.Image)
+	boolean("pull_model", c.Syncer.PullModel)
+	list("resources_to_sync", c.Syncer.Resources)
+	str("syncer_drift_mode", c.Syncer.DriftMode)
+	duration("syncer_token_ttl", c.Syncer.TokenTTL)
+
+	duration("eviction_toleration", c.Scheduling.EvictionToleration)
+
+	for flag, vs := range values {
+		if fs.Changed(flag) {
+			continue
+		}
+		// The first value replaces the default of list flags, and the
+		// others are appended to it.
+		for _, v := range vs {
+			if err := fs.Set(flag, v); err != nil {
+				return fmt.Errorf("configuration of --%s: %w", flag, err)
+			}
+		}
+	}
+	return nil
+}
@@ -7,33 +7,94 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"go.etcd.io/etcd/clientv3"
 
+	"github.com/kcp-dev/kcp/pkg/admission"
+	"github.com/kcp-dev/kcp/pkg/apis/config/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/authorization"
 	"github.com/kcp-dev/kcp/pkg/cmd/help"
+	"github.com/kcp-dev/kcp/pkg/conversion"
 	"github.com/kcp-dev/kcp/pkg/etcd"
+	"github.com/kcp-dev/kcp/pkg/fairness"
+	"github.com/kcp-dev/kcp/pkg/health"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/pki"
+	"github.com/kcp-dev/kcp/pkg/podproxy"
+	"github.com/kcp-dev/kcp/pkg/reconciler/apiimport"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
 	"github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/drain"
+	"github.com/kcp-dev/kcp/pkg/reconciler/eviction"
+	"github.com/kcp-dev/kcp/pkg/reconciler/negotiation"
+	"github.com/kcp-dev/kcp/pkg/reconciler/workspace"
+	"github.com/kcp-dev/kcp/pkg/sharding"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	"github.com/kcp-dev/kcp/pkg/tunnel"
 
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
+	"k8s.io/apiserver/pkg/authentication/request/union"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/token/tokenfile"
+	"k8s.io/apiserver/pkg/authentication/user"
 	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/healthz"
 	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/controlplane"
 	"k8s.io/kubernetes/pkg/controlplane/clientutils"
 	"k8s.io/kubernetes/pkg/controlplane/options"
 )
 
+// inProcessControllers are the controllers run alongside the Cluster
+// Controller, which --disabled_controllers may leave out.
+var inProcessControllers = sets.NewString("eviction", "drain", "negotiation", "apiimport", "workspace")
+
+// controller is one of the controllers run in process, until its context is
+// canceled.
+type controller interface {
+	Start(ctx context.Context, numThreads int, drainTimeout time.Duration)
+	LivenessCheck() healthz.HealthChecker
+	ReadinessCheck() healthz.HealthChecker
+	SetDroppedRequeue(after time.Duration)
+}
+
 var (
 	syncerImage              string
 	resourcesToSync          []string
 	installClusterController bool
 	pullModel                bool
+	evictionToleration       time.Duration
+	importAPIGroups          string
+	disabledControllers      []string
+	resyncPeriod             time.Duration
+	resyncPeriods            string
+	listPageSize             int64
+	configFile               string
+	syncerDriftMode          string
+	leafViewerGroups         []string
+	syncerTokenTTL           time.Duration
+	shardsKubeconfig         string
+	workspacesURL            string
+	maxRequestsInflight      int
+	fairnessConfig           string
 )
 
 func main() {
 	help.FitTerminal()
+	// Created up front for the authentication and admission flags to set.
+	serverOptions := options.NewServerRunOptions()
+	admission.Configure(serverOptions.Admission)
 	cmd := &cobra.Command{
 		Use:   "kcp",
 		Short: "Kube for Control Plane (KCP)",
@@ -59,16 +120,60 @@ func main() {
 		Long: help.Doc(`
 			Start the control plane process
 
-			The server process listens on port 6443 and will act like a Kubernetes
-			API server. It will initialize any necessary data to the provided start
-			location or as a '.kcp' directory in the current directory. An admin
-			kubeconfig file will be generated at initialization time that may be
-			used to access the control plane.
+			The server process listens on port 6443, or --secure-port, and will act
+			like a Kubernetes API server. It will initialize any necessary data to
+			the provided start location or as a '.kcp' directory in the current
+			directory. An admin kubeconfig file will be generated at initialization
+			time that may be used to access the control plane.
+
+			Its flags may also be set by a configuration file given as --config,
+			such as contrib/examples/kcp-config.yaml; those given on the command
+			line override it.
 		`),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			//flag.CommandLine.Lookup("v").Value.Set("9")
 
-			dir := filepath.Join(".", ".kcp")
+			if configFile != "" {
+				config, err := v1alpha1.Load(configFile)
+				if err != nil {
+					return err
+				}
+				if err := applyConfig(cmd.Flags(), config); err != nil {
+					return err
+				}
+			}
+			if unknown := sets.NewString(disabledControllers...).Difference(inProcessControllers); unknown.Len() > 0 {
+				return fmt.Errorf("unknown controllers %s, must be among %s", strings.Join(unknown.List(), ", "), strings.Join(inProcessControllers.List(), ", "))
+			}
+			resync, err := base.ParseResync(resyncPeriod, resyncPeriods)
+			if err != nil {
+				return fmt.Errorf("--resync_period, --resync_periods: %w", err)
+			}
+
+			driftMode, err := syncer.ParseDriftMode(syncerDriftMode)
+			if err != nil {
+				return err
+			}
+
+			fairnessCfg := fairness.Default()
+			if fairnessConfig != "" {
+				if fairnessCfg, err = fairness.Load(fairnessConfig); err != nil {
+					return err
+				}
+			}
+
+			var shards *sharding.Shards
+			if shardsKubeconfig != "" {
+				if shards, err = sharding.Load(shardsKubeconfig); err != nil {
+					return err
+				}
+			}
+
+			dir := rootDirectory
+			storage, err := storageBackend(serverOptions.Etcd.StorageConfig.Transport, dir)
+			if err != nil {
+				return err
+			}
 			if fi, err := os.Stat(dir); err != nil {
 				if !os.IsNotExist(err) {
 					return err
@@ -81,12 +186,37 @@ func main() {
 					return fmt.Errorf("%q is a file, please delete or select another location", dir)
 				}
 			}
-			s := &etcd.Server{
-				Dir: filepath.Join(dir, "data"),
+			dataDir := filepath.Join(dir, "data")
+			// Unless given a serving certificate, kcp serves with one signed
+			// by a CA of its own, generated on its first start, which also
+			// signs the client certificate of admin.kubeconfig.
+			var ca *pki.CA
+			if serverOptions.SecureServing.ServerCert.CertKey.CertFile == "" {
+				if err := os.MkdirAll(dataDir, 0700); err != nil {
+					return err
+				}
+				if ca, err = pki.EnsureCA(dataDir); err != nil {
+					return err
+				}
+				certFile, keyFile, err := ca.EnsureServingCert(dataDir, servingHosts(serverOptions.SecureServing.BindAddress))
+				if err != nil {
+					return err
+				}
+				serverOptions.SecureServing.ServerCert.CertKey.CertFile = certFile
+				serverOptions.SecureServing.ServerCert.CertKey.KeyFile = keyFile
+				if clientCert := serverOptions.Authentication.ClientCert; clientCert != nil && clientCert.ClientCA == "" {
+					clientCert.ClientCA = ca.CertFile
+				}
+			}
+			if _, external := storage.(*etcd.External); external {
+				// Where the serving certificates are kept.
+				if err := os.MkdirAll(dataDir, 0700); err != nil {
+					return err
+				}
 			}
 			ctx := context.TODO()
 
-			return s.Run(func(cfg etcd.ClientInfo) error {
+			return storage.Run(func(cfg etcd.ClientInfo) error {
 				c, err := clientv3.New(clientv3.Config{
 					Endpoints: cfg.Endpoints,
 					TLS:       cfg.TLS,
@@ -103,8 +233,7 @@ func main() {
 					fmt.Fprintf(os.Stderr, "Connected to etcd %d %s\n", member.GetID(), member.GetName())
 				}
 
-				serverOptions := options.NewServerRunOptions()
-				serverOptions.SecureServing.ServerCert.CertDirectory = s.Dir
+				serverOptions.SecureServing.ServerCert.CertDirectory = dataDir
 				serverOptions.InsecureServing = nil
 				serverOptions.Etcd.StorageConfig.Transport = storagebackend.TransportConfig{
 					ServerList:    cfg.Endpoints,
@@ -121,6 +250,45 @@ func main() {
 				if err != nil {
 					return err
 				}
+				// The views, fairness, pod proxy and tunnels are in front of
+				// the server's own authentication, so requests are
+				// authenticated for them the same way, once; the controllers
+				// reach kcp with the loopback token, as its privileged user.
+				authnConfig, err := cpOptions.Authentication.ToAuthenticationConfig()
+				if err != nil {
+					return err
+				}
+				// The server looks ServiceAccount tokens up itself.
+				authnConfig.ServiceAccountLookup = false
+				serverAuthn, _, err := authnConfig.New()
+				if err != nil {
+					return err
+				}
+				loopbackAuthn := bearertoken.New(tokenfile.New(map[string]*user.DefaultInfo{
+					server.LoopbackClientConfig.BearerToken: {Name: user.APIServerUser, Groups: []string{user.SystemPrivilegedGroup}},
+				}))
+				authn := union.New(loopbackAuthn, serverAuthn)
+				server.Handler.FullHandlerChain = fairness.WithPriorityAndFairness(server.Handler.FullHandlerChain, fairnessCfg, maxRequestsInflight)
+				server.Handler.FullHandlerChain = authorization.WithLeafViews(server.Handler.FullHandlerChain, authorization.InGroups(leafViewerGroups))
+				// kubectl logs, exec and port-forward reach the pods where they
+				// run, through the tunnels of the syncers of the clusters kcp
+				// can't reach. Each syncer opens its Cluster's tunnel as the
+				// Cluster's ServiceAccount, in the Cluster's workspace.
+				privileged := authorization.InGroups([]string{user.SystemPrivilegedGroup})
+				tunnels := tunnel.NewServer(func(ctx context.Context, u user.Info, ws, clusterName string) bool {
+					if privileged(u) {
+						return true
+					}
+					kubeClient, err := kubernetes.NewForConfig(logicalcluster.Config(server.LoopbackClientConfig, ws))
+					return err == nil && tunnel.IsServiceAccount(ctx, kubeClient, u, cluster.SyncerNamespace, clusterName)
+				})
+				if server.Handler.FullHandlerChain, err = podproxy.WithPodProxy(server.Handler.FullHandlerChain, server.LoopbackClientConfig, tunnels, workspace.AdminWorkspace); err != nil {
+					return err
+				}
+				server.Handler.FullHandlerChain = tunnels.WithTunnels(server.Handler.FullHandlerChain)
+				server.Handler.FullHandlerChain = metrics.WithWatchCounts(server.Handler.FullHandlerChain, workspace.AdminWorkspace)
+				// Outermost, for the handlers above to share its user.
+				server.Handler.FullHandlerChain = authorization.WithAuthentication(server.Handler.FullHandlerChain, authn)
 
 				var clientConfig clientcmdapi.Config
 				clientConfig.AuthInfos = map[string]*clientcmdapi.AuthInfo{
@@ -145,19 +313,61 @@ func main() {
 					"user":  {Cluster: "user", AuthInfo: "loopback"},
 				}
 				clientConfig.CurrentContext = "admin"
-				if err := clientcmd.WriteToFile(clientConfig, filepath.Join(s.Dir, "admin.kubeconfig")); err != nil {
+				// The loopback token changes on every start, and is only
+				// trusted with the loopback certificate: the clients of
+				// admin.kubeconfig authenticate with a certificate of the CA,
+				// if the server authenticates those.
+				adminClientConfig := &clientConfig
+				if clientCert := serverOptions.Authentication.ClientCert; ca != nil && clientCert != nil && clientCert.ClientCA == ca.CertFile {
+					if adminClientConfig, err = adminKubeconfig(ca, server.LoopbackClientConfig.Host); err != nil {
+						return err
+					}
+				}
+				if err := clientcmd.WriteToFile(*adminClientConfig, filepath.Join(dataDir, "admin.kubeconfig")); err != nil {
+					return err
+				}
+
+				// The API server converts the Clusters between their versions
+				// with the webhook, which only it calls, on the loopback.
+				webhook, err := conversion.Serve(ctx)
+				if err != nil {
 					return err
 				}
+				// Register the cluster.example.dev CRDs in both the admin and user
+				// logical clusters, with or without the controllers watching them,
+				// and those of the webhook configurations in the admin one first,
+				// for the webhooks to be ready to admit the others.
+				registerCRDs := func() error {
+					for _, contextName := range []string{"admin", "user"} {
+						logicalClusterConfig, err := clientcmd.NewNonInteractiveClientConfig(clientConfig, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+						if err != nil {
+							return err
+						}
+						if contextName == "admin" {
+							if err := admission.RegisterCRDs(ctx, logicalClusterConfig); err != nil {
+								return fmt.Errorf("registering the CRDs of the webhook configurations: %w", err)
+							}
+						}
+						if err := cluster.RegisterClusterCRD(logicalClusterConfig, webhook); err != nil {
+							return fmt.Errorf("registering the CRDs in logical cluster %s: %w", contextName, err)
+						}
+					}
+					return nil
+				}
 
 				if installClusterController {
+					// The controllers report their health on /healthz, /livez
+					// and /readyz, next to etcd, once the hook starts them;
+					// the server's checks are fixed before it runs, on all
+					// three endpoints.
+					var live, ready health.Checks
+					if err := server.AddHealthChecks(live.Named("controllers"), ready.NamedReadyz("controller-caches")); err != nil {
+						return err
+					}
 					server.AddPostStartHook("Install Cluster Controller", func(context genericapiserver.PostStartHookContext) error {
-						// Register the `clusters` CRD in both the admin and user logical clusters
-						for contextName := range clientConfig.Contexts {
-							logicalClusterConfig, err := clientcmd.NewNonInteractiveClientConfig(clientConfig, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
-							if err != nil {
-								return err
-							}
-							cluster.RegisterClusterCRD(logicalClusterConfig)
+						// The CRDs are registered before the controllers watch them.
+						if err := registerCRDs(); err != nil {
+							return err
 						}
 						adminConfig, err := clientcmd.NewNonInteractiveClientConfig(clientConfig, "admin", &clientcmd.ConfigOverrides{}, nil).ClientConfig()
 						if err != nil {
@@ -174,30 +384,126 @@ func main() {
 							cluster.Server = hostURL.String()
 						}
 
-						clientutils.EnableMultiCluster(adminConfig, nil, "clusters", "customresourcedefinitions")
+						// Workspaces are only set up in the admin logical cluster.
+						workspaceConfig := rest.CopyConfig(adminConfig)
+						workspaceURL, err := url.Parse(adminConfig.Host)
+						if err != nil {
+							return err
+						}
+						workspaceURL.Host = server.ExternalAddress
+						if workspacesURL != "" {
+							if workspaceURL, err = url.Parse(workspacesURL); err != nil {
+								return err
+							}
+						}
+
+						clientutils.EnableMultiCluster(adminConfig, nil, "clusters", "customresourcedefinitions", "secrets", "negotiatedapiresources", "namespaces", "serviceaccounts")
+						// The controllers share the informers of each resource,
+						// started once they've all asked for theirs.
+						informers, err := base.NewInformersWithResync(adminConfig, resync, listPageSize)
+						if err != nil {
+							return err
+						}
 						clusterController := cluster.NewController(
 							adminConfig,
 							syncerImage,
 							*kubeconfig,
 							resourcesToSync,
 							pullModel,
+							driftMode,
+							syncerTokenTTL,
+							informers,
 						)
-						clusterController.Start(2)
+						disabled := sets.NewString(disabledControllers...)
+						var controllers []controller
+						add := func(name string, newController func() controller) {
+							if !disabled.Has(name) {
+								controllers = append(controllers, newController())
+							}
+						}
+						add("eviction", func() controller { return eviction.NewController(adminConfig, evictionToleration, informers) })
+						add("drain", func() controller { return drain.NewController(adminConfig, informers) })
+						add("negotiation", func() controller { return negotiation.NewController(adminConfig, informers) })
+						if groups := apiimport.ParseGroups(importAPIGroups); len(groups) > 0 {
+							add("apiimport", func() controller { return apiimport.NewController(adminConfig, groups, informers) })
+						}
+						// The workspace controller watches the admin logical cluster alone.
+						add("workspace", func() controller {
+							return workspace.NewController(workspaceConfig, workspaceURL.String(), shards)
+						})
+						if resync.Period == 0 {
+							// Nothing resyncs the keys they give up on.
+							clusterController.SetDroppedRequeue(base.DefaultRetryPolicy().MaxDelay)
+							for _, c := range controllers {
+								c.SetDroppedRequeue(base.DefaultRetryPolicy().MaxDelay)
+							}
+						}
+						live.Add(clusterController.LivenessCheck())
+						ready.Add(clusterController.ReadinessCheck())
+						for _, c := range controllers {
+							live.Add(c.LivenessCheck())
+							ready.Add(c.ReadinessCheck())
+						}
+						informers.Start(ctx.Done())
+						for _, c := range controllers {
+							go c.Start(ctx, 2, base.DefaultDrainTimeout)
+						}
+						// The hook must return for the server to be ready.
+						go clusterController.Start(2)
 						return nil
 					})
+				} else {
+					server.AddPostStartHook("Register CRDs", func(context genericapiserver.PostStartHookContext) error {
+						return registerCRDs()
+					})
 				}
 
+				reloadCertificatesOnSIGHUP(server.SecureServingInfo, ctx.Done())
 				prepared := server.PrepareRun()
 
 				return prepared.Run(ctx.Done())
 			})
 		},
 	}
-	startCmd.Flags().AddFlag(pflag.PFlagFromGoFlag(flag.CommandLine.Lookup("v")))
+	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	startCmd.Flags().AddFlag(pflag.PFlagFromGoFlag(klogFlags.Lookup("v")))
+	startCmd.Flags().AddFlag(pflag.PFlagFromGoFlag(klogFlags.Lookup("vmodule")))
+	startCmd.Flags().StringVar(&configFile, "config", "", "Path to a kcp.config.k8s.io/v1alpha1 KCPConfiguration file setting the flags of kcp start; the flags given on the command line override it")
+	startCmd.Flags().IPVar(&serverOptions.SecureServing.BindAddress, "bind-address", serverOptions.SecureServing.BindAddress, "The IP address on which to listen for the --secure-port port")
+	startCmd.Flags().IntVar(&serverOptions.SecureServing.BindPort, "secure-port", serverOptions.SecureServing.BindPort, "The port on which to serve HTTPS with authentication and authorization")
+	startCmd.Flags().StringVar(&serverOptions.SecureServing.ServerCert.CertKey.CertFile, "tls-cert-file", serverOptions.SecureServing.ServerCert.CertKey.CertFile, "File containing the default x509 certificate to serve HTTPS with, followed by any intermediate certificates; a self-signed one is generated in the data directory if empty. It's reloaded when it changes, and on SIGHUP")
+	startCmd.Flags().StringVar(&serverOptions.SecureServing.ServerCert.CertKey.KeyFile, "tls-private-key-file", serverOptions.SecureServing.ServerCert.CertKey.KeyFile, "File containing the x509 private key matching --tls-cert-file")
 	startCmd.Flags().StringVar(&syncerImage, "syncer_image", "quay.io/kcp-dev/kcp-syncer", "References a container image that contains syncer and will be used by the syncer POD in registered physical clusters.")
 	startCmd.Flags().StringArrayVar(&resourcesToSync, "resources_to_sync", []string{"pods", "deployments"}, "Provides the list of resources that should be synced from KCP logical cluster to underlying physical clusters")
 	startCmd.Flags().BoolVar(&installClusterController, "install_cluster_controller", false, "Registers the sample cluster custom resource, and the related controller to allow registering physical clusters")
 	startCmd.Flags().BoolVar(&pullModel, "pull_model", false, "Deploy the syncer in registered physical clusters in POD, and have it sync resources from KCP")
+	startCmd.Flags().DurationVar(&evictionToleration, "eviction_toleration", eviction.DefaultToleration, "How long a registered physical cluster may stay NotReady before its workloads are moved to other clusters")
+	startCmd.Flags().StringVar(&importAPIGroups, "import_api_groups", "", "Comma-separated API groups to import the resources of from the registered physical clusters as CRDs, with core for the core group and * for every group; empty to disable")
+	startCmd.Flags().StringSliceVar(&disabledControllers, "disabled_controllers", nil, "Comma-separated controllers run alongside the Cluster Controller not to run, among "+strings.Join(inProcessControllers.List(), ", "))
+	startCmd.Flags().DurationVar(&resyncPeriod, "resync_period", 10*time.Hour, "How often the informers of the controllers run in process resync, handing them every object again; zero never to, retrying what they gave up on instead")
+	startCmd.Flags().StringVar(&resyncPeriods, "resync_periods", "", "Comma-separated resource=period pairs overriding --resync_period for the informers of those resources, e.g. clusters=1h,deployments=0")
+	startCmd.Flags().Int64Var(&listPageSize, "list_page_size", base.DefaultListPageSize, "How many objects the informers of the controllers run in process list at a time, from etcd rather than the watch cache; zero to list everything at once")
+	startCmd.Flags().BoolVar(&serverOptions.Etcd.EnableWatchCache, "watch-cache", serverOptions.Etcd.EnableWatchCache, "Serve the watches from a cache of each resource, which sends the watch bookmarks that let the informers resume their watches, once disconnected, without listing everything again")
+	startCmd.Flags().IntVar(&serverOptions.Etcd.DefaultWatchCacheSize, "default-watch-cache-size", serverOptions.Etcd.DefaultWatchCacheSize, "How many of the latest changes of each resource the watch cache keeps, for the watches to resume from, along with the objects themselves; zero to serve the resources without a size of their own from etcd, without a cache")
+	startCmd.Flags().StringSliceVar(&serverOptions.Etcd.WatchCacheSizes, "watch-cache-sizes", serverOptions.Etcd.WatchCacheSizes, "Comma-separated resource[.group]#size pairs overriding --default-watch-cache-size for those resources, e.g. deployments.apps#1000; a size of zero serves that resource's watches from etcd, without a cache")
+	startCmd.Flags().StringVar(&syncerDriftMode, "syncer_drift_mode", string(syncer.DriftRevert), "What the syncers do about synced objects changed on their physical cluster: revert, report or adopt")
+	startCmd.Flags().StringSliceVar(&leafViewerGroups, "leaf_viewer_groups", []string{user.SystemPrivilegedGroup, serviceaccount.MakeNamespaceGroupName(cluster.SyncerNamespace)}, "Groups of the users who see the per-cluster leafs the splitters create, which are hidden from everyone else")
+	startCmd.Flags().DurationVar(&syncerTokenTTL, "syncer_token_ttl", 0, "Issue the syncers installed with the pull model ServiceAccount tokens valid for this long, replaced before they expire, rather than the admin credentials; zero to disable")
+	// The standard Kubernetes authentication flags: OIDC, client certificates, token files and ServiceAccount tokens.
+	serverOptions.Authentication.AddFlags(startCmd.Flags())
+	startCmd.Flags().StringVar(&serverOptions.ServiceAccountSigningKeyFile, "service-account-signing-key-file", serverOptions.ServiceAccountSigningKeyFile, "Path to the file that contains the current private key of the service account token issuer, which issues the tokens of --syncer_token_ttl")
+	addStorageFlags(startCmd.Flags(), serverOptions.Etcd)
+	startCmd.Flags().StringVar(&shardsKubeconfig, "shards_kubeconfig", "", "Path to a kubeconfig with a context per kcp shard, whose current one is this server, to assign the Workspaces created in it to; empty not to shard")
+	startCmd.Flags().IntVar(&maxRequestsInflight, "max_requests_inflight", fairness.DefaultMaxRequestsInflight, "How many requests, watches and the streaming of pods aside, to serve at once, shared out among the priority levels of --priority_and_fairness_config; zero not to limit them")
+	startCmd.Flags().StringVar(&fairnessConfig, "priority_and_fairness_config", "", "Path to a file of flowcontrol.apiserver.k8s.io/v1beta1 FlowSchemas and PriorityLevelConfigurations sharing out --max_requests_inflight among the clients; the syncers, the controllers and the users get 30%, 30% and 40% of it if empty")
+	startCmd.Flags().StringVar(&workspacesURL, "workspaces_url", "", "URL the Workspaces are reached under, such as that of the front proxy of the shards; the external address of this server if empty")
+	startCmd.Flags().DurationVar(&serverOptions.Etcd.StorageConfig.CompactionInterval, "etcd-compaction-interval", serverOptions.Etcd.StorageConfig.CompactionInterval, "The interval of compaction requests. If 0, the compaction request from apiserver is disabled.")
+	startCmd.Flags().StringVar(&serverOptions.Etcd.EncryptionProviderConfigFilepath, "encryption-provider-config", serverOptions.Etcd.EncryptionProviderConfigFilepath, "Path to an apiserver.config.k8s.io/v1 EncryptionConfiguration encrypting resources such as Secrets in etcd, e.g. with a KMS plugin; empty to store them as they are")
+	// The standard Kubernetes audit flags: the policy, and the log and webhook backends.
+	serverOptions.Audit.AddFlags(startCmd.Flags())
+	// The standard Kubernetes admission flags, with the plugins of kcp.
+	serverOptions.Admission.AddFlags(startCmd.Flags())
 	cmd.AddCommand(startCmd)
 
 	if err := cmd.Execute(); err != nil {
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kcp-dev/kcp/pkg/etcd"
+
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+)
+
+var (
+	rootDirectory        string
+	etcdRestoreFrom      string
+	etcdSnapshotInterval time.Duration
+	etcdSnapshotLocation string
+	etcdSnapshotsKept    int
+	etcdDefragInterval   time.Duration
+)
+
+// addStorageFlags adds the flags of the data directory and of the embedded
+// etcd, and the standard Kubernetes flags of an external etcd, setting those
+// of the options.
+func addStorageFlags(fs *pflag.FlagSet, opts *genericoptions.EtcdOptions) {
+	fs.StringVar(&rootDirectory, "root_directory", ".kcp", "Directory the data of the control plane, its embedded etcd and the admin kubeconfig included, is kept in")
+	fs.StringVar(&etcdRestoreFrom, "etcd_restore_from", "", "Restore the embedded etcd, which must have no data yet, from this snapshot, a local path or an s3://bucket/key URL, before starting")
+	fs.DurationVar(&etcdSnapshotInterval, "etcd_snapshot_interval", 0, "How often to save snapshots of the embedded etcd; zero to disable")
+	fs.StringVar(&etcdSnapshotLocation, "etcd_snapshot_location", "", "Local directory or s3://bucket/prefix URL to save the snapshots of the embedded etcd to; the snapshots directory of --root_directory if empty")
+	fs.IntVar(&etcdSnapshotsKept, "etcd_snapshots_kept", 5, "How many of the latest local snapshots of the embedded etcd to keep; zero to keep them all")
+	fs.DurationVar(&etcdDefragInterval, "etcd_defrag_interval", 0, "How often to defragment the embedded etcd, reclaiming the space of its compacted history; zero to disable")
+	// The standard Kubernetes flags of an external etcd, which is embedded without them.
+	fs.StringSliceVar(&opts.StorageConfig.Transport.ServerList, "etcd-servers", opts.StorageConfig.Transport.ServerList, "List of etcd servers to connect with (scheme://ip:port), comma separated; the embedded etcd is run if empty. Anything serving the etcd API, such as kine, will do.")
+	fs.StringVar(&opts.StorageConfig.Transport.CertFile, "etcd-certfile", opts.StorageConfig.Transport.CertFile, "SSL certification file used to secure etcd communication.")
+	fs.StringVar(&opts.StorageConfig.Transport.KeyFile, "etcd-keyfile", opts.StorageConfig.Transport.KeyFile, "SSL key file used to secure etcd communication.")
+	fs.StringVar(&opts.StorageConfig.Transport.TrustedCAFile, "etcd-cafile", opts.StorageConfig.Transport.TrustedCAFile, "SSL Certificate Authority file used to secure etcd communication.")
+}
+
+// storageBackend returns the storage of the flags: the external etcd of
+// --etcd-servers if given, or else the etcd embedded in dir.
+func storageBackend(transport storagebackend.TransportConfig, dir string) (etcd.Backend, error) {
+	if len(transport.ServerList) == 0 {
+		if transport.CertFile != "" || transport.KeyFile != "" || transport.TrustedCAFile != "" {
+			return nil, errors.New("--etcd-certfile, --etcd-keyfile and --etcd-cafile are those of --etcd-servers, which isn't set")
+		}
+		snapshotLocation := etcdSnapshotLocation
+		if snapshotLocation == "" {
+			snapshotLocation = filepath.Join(dir, "snapshots")
+		}
+		return &etcd.Server{
+			Dir:              filepath.Join(dir, "data"),
+			RestoreFrom:      etcdRestoreFrom,
+			SnapshotInterval: etcdSnapshotInterval,
+			SnapshotLocation: snapshotLocation,
+			SnapshotsKept:    etcdSnapshotsKept,
+			DefragInterval:   etcdDefragInterval,
+		}, nil
+	}
+	if etcdRestoreFrom != "" || etcdSnapshotInterval != 0 || etcdDefragInterval != 0 {
+		return nil, errors.New("only the embedded etcd can be restored, snapshotted and defragmented, not that of --etcd-servers")
+	}
+	if (transport.CertFile == "") != (transport.KeyFile == "") {
+		return nil, errors.New("--etcd-certfile and --etcd-keyfile must be given together")
+	}
+	return &etcd.External{
+		Endpoints:     transport.ServerList,
+		CertFile:      transport.CertFile,
+		KeyFile:       transport.KeyFile,
+		TrustedCAFile: transport.TrustedCAFile,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kcp-dev/kcp/pkg/apis/config/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/etcd"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+)
+
+func TestStorageBackend(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		config *v1alpha1.KCPConfiguration
+		want   etcd.Backend
+		err    string
+	}{{
+		name: "embedded",
+		want: &etcd.Server{Dir: ".kcp/data", SnapshotLocation: ".kcp/snapshots", SnapshotsKept: 5},
+	}, {
+		name: "embedded with maintenance",
+		args: []string{"--root_directory=/var/lib/kcp", "--etcd_restore_from=s3://backups/kcp", "--etcd_snapshot_interval=1h", "--etcd_snapshots_kept=0", "--etcd_defrag_interval=24h"},
+		want: &etcd.Server{Dir: "/var/lib/kcp/data", RestoreFrom: "s3://backups/kcp", SnapshotInterval: time.Hour, SnapshotLocation: "/var/lib/kcp/snapshots", DefragInterval: 24 * time.Hour},
+	}, {
+		name: "external",
+		args: []string{"--etcd-servers=https://etcd-0:2379,https://etcd-1:2379", "--etcd-certfile=client.crt", "--etcd-keyfile=client.key", "--etcd-cafile=ca.crt"},
+		want: &etcd.External{Endpoints: []string{"https://etcd-0:2379", "https://etcd-1:2379"}, CertFile: "client.crt", KeyFile: "client.key", TrustedCAFile: "ca.crt"},
+	}, {
+		name: "external without TLS",
+		args: []string{"--etcd-servers=http://kine:2379"},
+		want: &etcd.External{Endpoints: []string{"http://kine:2379"}},
+	}, {
+		name:   "external of the configuration",
+		config: &v1alpha1.KCPConfiguration{Storage: v1alpha1.Storage{EtcdServers: []string{"https://etcd-0:2379", "https://etcd-1:2379"}, EtcdCAFile: "ca.crt"}},
+		want:   &etcd.External{Endpoints: []string{"https://etcd-0:2379", "https://etcd-1:2379"}, TrustedCAFile: "ca.crt"},
+	}, {
+		name:   "external of the command line overriding the configuration",
+		args:   []string{"--etcd-servers=https://etcd-2:2379"},
+		config: &v1alpha1.KCPConfiguration{Storage: v1alpha1.Storage{EtcdServers: []string{"https://etcd-0:2379"}, EtcdCAFile: "ca.crt"}},
+		want:   &etcd.External{Endpoints: []string{"https://etcd-2:2379"}, TrustedCAFile: "ca.crt"},
+	}, {
+		name: "external restored",
+		args: []string{"--etcd-servers=https://etcd-0:2379", "--etcd_restore_from=snapshot.db"},
+		err:  "only the embedded etcd",
+	}, {
+		name:   "external snapshotted by the configuration",
+		args:   []string{"--etcd-servers=https://etcd-0:2379"},
+		config: &v1alpha1.KCPConfiguration{Storage: v1alpha1.Storage{SnapshotInterval: &metav1.Duration{Duration: time.Hour}}},
+		err:    "only the embedded etcd",
+	}, {
+		name: "external defragmented",
+		args: []string{"--etcd-servers=https://etcd-0:2379", "--etcd_defrag_interval=1h"},
+		err:  "only the embedded etcd",
+	}, {
+		name: "certificate without key",
+		args: []string{"--etcd-servers=https://etcd-0:2379", "--etcd-certfile=client.crt"},
+		err:  "must be given together",
+	}, {
+		name: "key without certificate",
+		args: []string{"--etcd-servers=https://etcd-0:2379", "--etcd-keyfile=client.key"},
+		err:  "must be given together",
+	}, {
+		name: "TLS without servers",
+		args: []string{"--etcd-cafile=ca.crt"},
+		err:  "--etcd-servers, which isn't set",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("start", pflag.ContinueOnError)
+			opts := genericoptions.NewEtcdOptions(storagebackend.NewDefaultConfig("/registry", nil))
+			addStorageFlags(fs, opts)
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			if tc.config != nil {
+				if err := applyConfig(fs, tc.config); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := storageBackend(opts.StorageConfig.Transport, rootDirectory)
+			switch {
+			case tc.err != "":
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("got %v, want it to contain %q", err, tc.err)
+				}
+			case err != nil:
+				t.Error(err)
+			case !reflect.DeepEqual(got, tc.want):
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
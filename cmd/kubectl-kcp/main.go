@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kcp-dev/kcp/pkg/cmd/cluster"
+	"github.com/kcp-dev/kcp/pkg/cmd/help"
+	"github.com/kcp-dev/kcp/pkg/cmd/workload"
+	"github.com/kcp-dev/kcp/pkg/cmd/workspace"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	help.FitTerminal()
+	cmd := &cobra.Command{
+		Use:   "kubectl kcp",
+		Short: "kubectl plugin for kcp",
+		Long: help.Doc(`
+					kubectl plugin for kcp
+					Install it on your PATH as kubectl-kcp to run it as "kubectl kcp".
+				`),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(cluster.New())
+	cmd.AddCommand(workload.New())
+	cmd.AddCommand(workspace.New())
+
+	if err := cmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
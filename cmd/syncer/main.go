@@ -1,157 +1,247 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
+	"io/ioutil"
+	"os"
 	"strings"
 	"time"
 
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	"github.com/kcp-dev/kcp/pkg/debug"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/registration"
 	"github.com/kcp-dev/kcp/pkg/syncer"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/dynamic/dynamicinformer"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	"github.com/kcp-dev/kcp/pkg/tunnel"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/workqueue"
-	"k8s.io/klog"
-	"k8s.io/kube-openapi/pkg/util/sets"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
 )
 
-const (
-	resyncPeriod = time.Hour
-	numThreads   = 2
-)
+const numThreads = 2
 
 var (
-	kubeconfig = flag.String("kubeconfig", "", "Config file for -from cluster")
-	clusterID  = flag.String("cluster", "", "ID of this cluster")
+	kubeconfig   = flag.String("kubeconfig", "", "Config file for -from cluster")
+	toKubeconfig = flag.String("to_kubeconfig", "", "Config file for the cluster to sync to; defaults to the in-cluster config when running in a Pod, and to -kubeconfig otherwise")
+	clusterID    = flag.String("cluster", "", "ID of this cluster")
+	resyncPeriod = flag.Duration("resync_period", syncer.DefaultResyncPeriod, "How often to sync every object again, even if it didn't change")
+	driftMode    = flag.String("drift_mode", string(syncer.DriftRevert), "What to do about synced objects changed on this cluster: revert, report or adopt")
+
+	namespaceMapping      = flag.String("namespace_mapping", "", "Comma-separated <kcp namespace>=<namespace> to sync the objects of kcp namespaces to other namespaces of this cluster")
+	namespaceStrategy     = flag.String("namespace_strategy", "identity", "Namespaces of this cluster to sync the objects of the kcp namespaces -namespace_mapping doesn't map to: identity, prefixed (kcp-<workspace>-<namespace>) or consolidated:<namespace>")
+	workspace             = flag.String("workspace", "", "Name of the logical cluster synced from, for -namespace_strategy=prefixed; defaults to the current context of -kubeconfig")
+	serviceAccountMapping = flag.String("service_account_mapping", "", "Comma-separated <kcp service account>=<service account> to run the pods of synced objects as other ServiceAccounts of this cluster, default for those that don't set one")
+	transformers          = flag.String("transformers", "", "Comma-separated transformers to run on the synced objects, after the mappings")
+	upsync                = flag.String("upsync", "", "Comma-separated resources whose objects on this cluster to mirror to kcp, in the namespaces synced objects are in, e.g. pods,replicasets,events")
+	rbacVerbs             = flag.String("rbac_verbs", "", "Comma-separated verbs the Roles bound to the ServiceAccounts of synced objects may grant on this cluster, to sync them along with their RoleBindings, e.g. get,list,watch; empty not to sync RBAC")
+	networkPolicies       = flag.Bool("network_policies", false, "Sync the NetworkPolicies selecting the pods of synced objects along with them, for a cluster whose network plugin enforces them")
+	serviceImports        = flag.Bool("service_imports", false, "Program the ServiceImports of kcp, derived from ServiceExports, as Multi-Cluster Services API ServiceImports on this cluster, whose CRD must be installed, with the endpoints of the exported Services on every cluster")
+
+	bootstrapTokenFile = flag.String("bootstrap_token_file", "", "File holding a bootstrap token to register this cluster with kcp with before syncing, as minted by kubectl kcp workload sync")
+	registerTimeout    = flag.Duration("register_timeout", 5*time.Minute, "How long to wait for kcp to accept this cluster's registration, with -bootstrap_token_file")
+
+	qps               = flag.Float64("qps", 0, "Requests per second the syncer makes to this cluster at most, sustained; defaults to the client default of 5")
+	burst             = flag.Int("burst", 0, "Requests the syncer makes to this cluster at once above -qps at most; defaults to the client default of 10")
+	adaptiveRateLimit = flag.Bool("adaptive_rate_limit", false, "Slow down, down to a tenth of -qps, while this cluster answers with 429 Too Many Requests, and speed back up as requests succeed again")
+
+	tunnelToKcp = flag.Bool("tunnel", false, "Open a tunnel to kcp, for it to reach the logs of the pods of this cluster, and exec, attach and port-forward to them, when it can't reach this cluster")
+
+	metricsAddr   = flag.String("metrics_addr", ":8080", "Address to serve Prometheus metrics on; empty to disable")
+	profiling     = flag.Bool("profiling", false, "Serve the pprof profiles on /debug/pprof and the runtime metrics, such as the number of goroutines, on /debug/vars at -profiling_addr")
+	profilingAddr = flag.String("profiling_addr", debug.DefaultAddr, "Loopback address to serve the debug endpoints of -profiling on")
+
+	tracingEndpoint     = flag.String("tracing_endpoint", "", "URL of the OTLP/HTTP collector to export the traces of the reconciles to, e.g. http://otel-collector:4318; empty to disable")
+	tracingSamplingRate = flag.Float64("tracing_sampling_rate", tracing.DefaultSamplingRate, "Fraction of the reconciles to trace, besides those that are part of a trace already")
+
+	manifests = flag.Bool("manifests", false, "Print the manifests to run the syncer inside a cluster kcp can't reach, syncing from the current context of -kubeconfig, and exit")
+	image     = flag.String("image", "quay.io/kcp-dev/kcp-syncer", "Syncer image to run, with -manifests")
+	token     = flag.String("token", "", "Bearer token the syncer authenticates to kcp with, instead of the credentials of -kubeconfig, with -manifests")
 )
 
 func main() {
+	klog.InitFlags(nil)
 	flag.Parse()
 	syncedResourceTypes := flag.Args()
 
-	// Create a client to dynamically watch "from".
-	fromConfig, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	mode, err := syncer.ParseDriftMode(*driftMode)
 	if err != nil {
 		klog.Fatal(err)
 	}
-	fromClient := dynamic.NewForConfigOrDie(fromConfig)
-	fromDSIF := dynamicinformer.NewFilteredDynamicSharedInformerFactory(fromClient, resyncPeriod, metav1.NamespaceAll, func(o *metav1.ListOptions) {
-		o.LabelSelector = fmt.Sprintf("cluster = %s", *clusterID)
-	})
-
-	// Create a client to modify "to".
-	toConfig, err := clientcmd.BuildConfigFromFlags("", *kubeconfig) // rest.InClusterConfig()
+	namespaces, err := syncer.ParseMapping(*namespaceMapping)
 	if err != nil {
 		klog.Fatal(err)
 	}
-	toClient := dynamic.NewForConfigOrDie(toConfig)
+	serviceAccounts, err := syncer.ParseMapping(*serviceAccountMapping)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	var transformerNames []string
+	if *transformers != "" {
+		transformerNames = strings.Split(*transformers, ",")
+	}
+	custom, err := syncer.NewPipeline(transformerNames)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	rateLimit := syncer.RateLimit{QPS: float32(*qps), Burst: *burst, Adaptive: *adaptiveRateLimit}
+	var upsyncedResources []string
+	if *upsync != "" {
+		upsyncedResources = strings.Split(*upsync, ",")
+	}
+	var verbs []string
+	if *rbacVerbs != "" {
+		verbs = strings.Split(*rbacVerbs, ",")
+	}
 
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	defer queue.ShutDown()
+	if *manifests {
+		if err := printManifests(syncedResourceTypes, mode, namespaces, serviceAccounts, transformerNames, upsyncedResources, verbs, *networkPolicies, *serviceImports, rateLimit, *tunnelToKcp); err != nil {
+			klog.Fatal(err)
+		}
+		return
+	}
 
-	c := syncer.Controller{
-		// TODO: should we have separate upstream and downstream sync workqueues?
-		Queue: queue,
+	if *workspace == "" {
+		if config, err := clientcmd.LoadFromFile(*kubeconfig); err == nil {
+			*workspace = config.CurrentContext
+		}
+	}
+	strategy, err := syncer.ParseNamespaceStrategy(*namespaceStrategy, *workspace)
+	if err != nil {
+		klog.Fatal(err)
+	}
 
-		FromDSIF: fromDSIF,
-		ToClient: toClient,
+	// Create a client to dynamically watch "from".
+	fromConfig, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		klog.Fatal(err)
 	}
 
-	// Get all types the upstream API server knows about.
-	// TODO: watch this and learn about new types, or forget about old ones.
-	gvrstrs, err := getAllGVRs(fromConfig, syncedResourceTypes...)
+	// Create a client to modify "to".
+	toConfig, err := rest.InClusterConfig()
+	if *toKubeconfig != "" || err == rest.ErrNotInCluster {
+		if *toKubeconfig == "" {
+			toKubeconfig = kubeconfig
+		}
+		toConfig, err = clientcmd.BuildConfigFromFlags("", *toKubeconfig)
+	}
 	if err != nil {
 		klog.Fatal(err)
 	}
-	for _, gvrstr := range gvrstrs {
-		gvr, _ := schema.ParseResourceArg(gvrstr)
+	toConfig = rateLimit.Config(toConfig)
+	tracing.WrapConfig(fromConfig)
+	tracing.WrapConfig(toConfig)
+	shutdownTracing, err := tracing.Setup("kcp-syncer", *tracingEndpoint, *tracingSamplingRate)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
 
-		if _, err := fromDSIF.ForResource(*gvr).Lister().List(labels.Everything()); err != nil {
-			klog.Infof("Failed to list all %q: %v", gvrstr, err)
-			continue
+	if *bootstrapTokenFile != "" {
+		if err := register(fromConfig); err != nil {
+			klog.Fatal(err)
 		}
+	}
 
-		fromDSIF.ForResource(*gvr).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc:    func(obj interface{}) { c.AddToQueue(*gvr, obj) },
-			UpdateFunc: func(_, obj interface{}) { c.AddToQueue(*gvr, obj) },
-			DeleteFunc: func(obj interface{}) { c.AddToQueue(*gvr, obj) },
-		})
-		klog.Infof("Set up informer for %v", gvr)
+	metrics.Register()
+	metrics.Serve(*metricsAddr)
+	if *profiling {
+		if err := debug.Serve(*profilingAddr); err != nil {
+			klog.Fatal(err)
+		}
 	}
-	stopCh := make(chan struct{})
-	fromDSIF.WaitForCacheSync(stopCh)
-	fromDSIF.Start(stopCh)
 
-	for i := 0; i < numThreads; i++ {
-		go wait.Until(c.StartWorker, time.Second, stopCh)
+	// TODO: watch the upstream API server's types and learn about new ones, or forget about old ones.
+	c, err := syncer.NewController(fromConfig, toConfig, *clusterID, syncedResourceTypes, *resyncPeriod, mode)
+	if err != nil {
+		klog.Fatal(err)
 	}
-	klog.Infoln("Starting workers")
-	<-stopCh
-	klog.Infoln("Stopping workers")
+	c.SetTransforms(syncer.Transforms{Namespaces: namespaces, NamespaceStrategy: strategy, ServiceAccounts: serviceAccounts, Custom: custom})
+	if err := c.SetUpsyncedResources(upsyncedResources); err != nil {
+		klog.Fatal(err)
+	}
+	c.SetRBACVerbs(verbs)
+	c.SetNetworkPolicies(*networkPolicies)
+	c.SetServiceImports(*serviceImports)
+	if *tunnelToKcp {
+		go func() {
+			if err := tunnel.Run(context.Background(), fromConfig, toConfig, *workspace, *clusterID); err != nil {
+				klog.Fatal(err)
+			}
+		}()
+	}
+	c.Start(numThreads)
 }
 
-func contains(ss []string, s string) bool {
-	for _, n := range ss {
-		if n == s {
-			return true
-		}
+// register registers this cluster with kcp, presenting the bootstrap token
+// in -bootstrap_token_file, and waits until it's accepted.
+func register(cfg *rest.Config) error {
+	token, err := ioutil.ReadFile(*bootstrapTokenFile)
+	if err != nil {
+		return err
+	}
+	client, err := clusterclient.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	clusters := client.ClusterV1alpha1().Clusters()
+	if err := registration.Register(ctx, clusters, *clusterID, strings.TrimSpace(string(token))); err != nil {
+		return err
 	}
-	return false
+	klog.Infof("Registered cluster %s, waiting to be accepted", *clusterID)
+	if err := registration.WaitForAccepted(ctx, clusters, *clusterID, *registerTimeout); err != nil {
+		return err
+	}
+	klog.Infof("Cluster %s accepted", *clusterID)
+	return nil
 }
 
-func getAllGVRs(config *rest.Config, resourcesToSync ...string) ([]string, error) {
-	toSyncSet := sets.NewString(resourcesToSync...)
-	willBeSyncedSet := sets.NewString()
-	dc, err := discovery.NewDiscoveryClientForConfig(config)
+// printManifests prints the manifests of a syncer dialing out to the current
+// context of -kubeconfig, whose name is the logical cluster to sync from.
+func printManifests(resources []string, mode syncer.DriftMode, namespaces, serviceAccounts syncer.Mapping, transformers, upsynced, rbacVerbs []string, syncPolicies, importServices bool, rateLimit syncer.RateLimit, openTunnel bool) error {
+	config, err := clientcmd.LoadFromFile(*kubeconfig)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := clientcmdapi.MinifyConfig(config); err != nil {
+		return err
 	}
-	rs, err := dc.ServerPreferredResources()
+	logicalCluster := config.CurrentContext
+	strategy, err := syncer.ParseNamespaceStrategy(*namespaceStrategy, logicalCluster)
 	if err != nil {
-		return nil, err
-	}
-	var gvrstrs []string
-	for _, r := range rs {
-		// v1 -> v1.
-		// apps/v1 -> v1.apps
-		// tekton.dev/v1beta1 -> v1beta1.tekton.dev
-		parts := strings.SplitN(r.GroupVersion, "/", 2)
-		vr := parts[0] + "."
-		if len(parts) == 2 {
-			vr = parts[1] + "." + parts[0]
-		}
-		for _, ai := range r.APIResources {
-			if !toSyncSet.Has(ai.Name) {
-				// We're not interested in this resource type
-				continue
-			}
-			if strings.Contains(ai.Name, "/") {
-				// foo/status, pods/exec, namespace/finalize, etc.
-				continue
-			}
-			if !ai.Namespaced {
-				// Ignore cluster-scoped things.
-				continue
-			}
-			if !contains(ai.Verbs, "watch") {
-				klog.Infof("resource %s %s is not watchable: %v", vr, ai.Name, ai.Verbs)
-				continue
-			}
-			gvrstrs = append(gvrstrs, fmt.Sprintf("%s.%s", ai.Name, vr))
-			willBeSyncedSet.Insert(ai.Name)
-		}
+		return err
+	}
+	if *token != "" {
+		authInfo := config.Contexts[logicalCluster].AuthInfo
+		config.AuthInfos[authInfo] = &clientcmdapi.AuthInfo{Token: *token}
+	}
+	b, err := clientcmd.Write(*config)
+	if err != nil {
+		return err
 	}
 
-	notFoundResourceTypes := toSyncSet.Difference(willBeSyncedSet)
-	if notFoundResourceTypes.Len() != 0 {
-		return nil, fmt.Errorf("The following resource types should be synced and we not found in the KCP logical cluster: %v", notFoundResourceTypes.List())
+	if len(resources) == 0 {
+		resources = []string{"pods", "deployments"}
 	}
-	return gvrstrs, nil
+	return syncer.WriteManifests(os.Stdout, syncer.InstallOptions{
+		Image:                 *image,
+		Kubeconfig:            string(b),
+		ClusterID:             *clusterID,
+		LogicalCluster:        logicalCluster,
+		Resources:             resources,
+		DriftMode:             mode,
+		NamespaceMapping:      namespaces,
+		NamespaceStrategy:     strategy,
+		ServiceAccountMapping: serviceAccounts,
+		Transformers:          transformers,
+		Upsync:                upsynced,
+		RBACVerbs:             rbacVerbs,
+		NetworkPolicies:       syncPolicies,
+		ServiceImports:        importServices,
+		Tunnel:                openTunnel,
+		RateLimit:             rateLimit,
+	})
 }
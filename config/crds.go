@@ -0,0 +1,173 @@
+// Package config embeds the CRDs of the cluster.example.dev group, for kcp
+// and its controllers to register them wherever they run, rather than
+// having them applied by hand from this directory.
+package config
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"sort"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed cluster.example.dev_*.yaml
+var manifests embed.FS
+
+// CRDs returns the embedded CRDs, in the order of their files.
+func CRDs() ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	files, err := manifests.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+
+	crds := make([]*apiextensionsv1.CustomResourceDefinition, 0, len(names))
+	for _, name := range names {
+		data, err := manifests.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(data, crd); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
+
+// WithConversionWebhook returns copies of the CRDs, those of which serve
+// several versions converting their objects with the given webhook.
+func WithConversionWebhook(crds []*apiextensionsv1.CustomResourceDefinition, webhook *apiextensionsv1.WebhookClientConfig) []*apiextensionsv1.CustomResourceDefinition {
+	copies := make([]*apiextensionsv1.CustomResourceDefinition, 0, len(crds))
+	for _, crd := range crds {
+		crd = crd.DeepCopy()
+		if len(crd.Spec.Versions) > 1 {
+			crd.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{
+				Strategy: apiextensionsv1.WebhookConverter,
+				Webhook: &apiextensionsv1.WebhookConversion{
+					ClientConfig:             webhook.DeepCopy(),
+					ConversionReviewVersions: []string{"v1"},
+				},
+			}
+		}
+		copies = append(copies, crd)
+	}
+	return copies
+}
+
+// ConversionWebhook returns the conversion webhook of the Clusters of the
+// logical cluster reached with client, nil if they have none, for those of
+// its other logical clusters to be registered with that of the kcp server
+// serving them.
+func ConversionWebhook(ctx context.Context, client apiextensionsv1client.CustomResourceDefinitionInterface) (*apiextensionsv1.WebhookClientConfig, error) {
+	crd, err := client.Get(ctx, "clusters.cluster.example.dev", metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c := crd.Spec.Conversion; c != nil && c.Strategy == apiextensionsv1.WebhookConverter && c.Webhook != nil && c.Webhook.ClientConfig != nil {
+		return c.Webhook.ClientConfig, nil
+	}
+	return nil, nil
+}
+
+// Bootstrap creates the given CRDs through client, and updates those whose
+// spec differs from that given, as when kcp was upgraded, so that they
+// serve the schemas this build of kcp expects. CRDs whose update would
+// conflict with what they serve, such as their scope or the versions their
+// objects are stored as, are left as they are, and failed with, as are
+// those serving several versions without a conversion webhook, which only
+// kcp serves: see WithConversionWebhook.
+func Bootstrap(ctx context.Context, client apiextensionsv1client.CustomResourceDefinitionInterface, crds ...*apiextensionsv1.CustomResourceDefinition) error {
+	var errs []error
+	for _, crd := range crds {
+		if err := bootstrap(ctx, client, crd); err != nil {
+			errs = append(errs, fmt.Errorf("CRD %s: %w", crd.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func bootstrap(ctx context.Context, client apiextensionsv1client.CustomResourceDefinitionInterface, crd *apiextensionsv1.CustomResourceDefinition) error {
+	existing, err := client.Get(ctx, crd.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if len(crd.Spec.Versions) > 1 && crd.Spec.Conversion == nil {
+			return fmt.Errorf("it serves several versions, which only kcp converts between: start kcp first")
+		}
+		_, err := client.Create(ctx, crd, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			// Created by a concurrent bootstrap since.
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	spec := crd.Spec.DeepCopy()
+	if spec.Conversion == nil {
+		// Defaulted by the API server.
+		spec.Conversion = existing.Spec.Conversion
+	}
+	if equality.Semantic.DeepEqual(&existing.Spec, spec) {
+		return nil
+	}
+	if len(spec.Versions) > 1 && (spec.Conversion == nil || spec.Conversion.Strategy != apiextensionsv1.WebhookConverter) {
+		return fmt.Errorf("it serves several versions, which only kcp converts between: start kcp first")
+	}
+	if err := conflicts(existing, spec); err != nil {
+		return err
+	}
+	klog.Infof("Updating the schema of CRD %s", crd.Name)
+	updated := existing.DeepCopy()
+	updated.Spec = *spec
+	for k, v := range crd.Annotations {
+		if updated.Annotations == nil {
+			updated.Annotations = map[string]string{}
+		}
+		updated.Annotations[k] = v
+	}
+	_, err = client.Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// conflicts returns why the CRD can't be updated to spec, if it can't: its
+// group, names and scope are those its objects are reached with, and the
+// versions its objects are stored as must still be served.
+func conflicts(existing *apiextensionsv1.CustomResourceDefinition, spec *apiextensionsv1.CustomResourceDefinitionSpec) error {
+	if existing.Spec.Group != spec.Group {
+		return fmt.Errorf("it's of group %s, not %s", existing.Spec.Group, spec.Group)
+	}
+	if existing.Spec.Names.Kind != spec.Names.Kind || existing.Spec.Names.Plural != spec.Names.Plural {
+		return fmt.Errorf("it's named %s (%s), not %s (%s)", existing.Spec.Names.Plural, existing.Spec.Names.Kind, spec.Names.Plural, spec.Names.Kind)
+	}
+	if existing.Spec.Scope != spec.Scope {
+		return fmt.Errorf("its scope is %s, not %s", existing.Spec.Scope, spec.Scope)
+	}
+	versions := sets.NewString()
+	for _, v := range spec.Versions {
+		versions.Insert(v.Name)
+	}
+	if missing := sets.NewString(existing.Status.StoredVersions...).Difference(versions); missing.Len() > 0 {
+		return fmt.Errorf("its objects are stored as versions %v, which the new schema doesn't serve", missing.List())
+	}
+	return nil
+}
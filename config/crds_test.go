@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCRDs(t *testing.T) {
+	crds, err := CRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, crd := range crds {
+		if crd.Spec.Group != "cluster.example.dev" {
+			t.Errorf("CRD %s is of group %s", crd.Name, crd.Spec.Group)
+		}
+		names[crd.Name] = true
+	}
+	for _, name := range []string{"clusters.cluster.example.dev", "workspaces.cluster.example.dev", "placementpolicies.cluster.example.dev"} {
+		if !names[name] {
+			t.Errorf("CRD %s isn't embedded", name)
+		}
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	ctx := context.Background()
+	crds, err := CRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset().ApiextensionsV1().CustomResourceDefinitions()
+
+	// The Clusters serve several versions, which need the webhook.
+	if err := Bootstrap(ctx, client, crds...); err == nil || !strings.Contains(err.Error(), "start kcp first") {
+		t.Errorf("got %v without a conversion webhook", err)
+	}
+	url := "https://127.0.0.1:6444/convert"
+	crds = WithConversionWebhook(crds, &apiextensionsv1.WebhookClientConfig{URL: &url})
+	if err := Bootstrap(ctx, client, crds...); err != nil {
+		t.Fatal(err)
+	}
+	list, err := client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != len(crds) {
+		t.Fatalf("got %d CRDs, want %d", len(list.Items), len(crds))
+	}
+
+	// A changed schema is updated.
+	changed := crds[0].DeepCopy()
+	changed.Spec.Versions[0].Schema.OpenAPIV3Schema.Description = "changed"
+	if err := Bootstrap(ctx, client, changed); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.Get(ctx, changed.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.Versions[0].Schema.OpenAPIV3Schema.Description != "changed" {
+		t.Error("the schema wasn't updated")
+	}
+
+	// A changed scope, or a stored version no longer served, conflicts.
+	scoped := crds[0].DeepCopy()
+	scoped.Spec.Scope = apiextensionsv1.NamespaceScoped
+	if scoped.Spec.Scope == crds[0].Spec.Scope {
+		scoped.Spec.Scope = apiextensionsv1.ClusterScoped
+	}
+	if err := Bootstrap(ctx, client, scoped); err == nil || !strings.Contains(err.Error(), "its scope is") {
+		t.Errorf("got %v for a changed scope", err)
+	}
+	got.Status.StoredVersions = []string{"v1alpha0"}
+	if _, err := client.UpdateStatus(ctx, got, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Bootstrap(ctx, client, crds[0]); err == nil || !strings.Contains(err.Error(), "v1alpha0") {
+		t.Errorf("got %v for a stored version no longer served", err)
+	}
+	if got, _ := client.Get(ctx, changed.Name, metav1.GetOptions{}); got.Spec.Versions[0].Schema.OpenAPIV3Schema.Description != "changed" {
+		t.Error("a conflicting CRD was updated")
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/endpoints/discovery"
+	clientdiscovery "k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// MigrateStorage rewrites the objects of the named CRD still stored as
+// versions other than its storage version, such as the v1alpha1 Clusters of
+// before v1beta1, for them to be stored as its storage version, and then
+// drops the other versions from its stored versions, for them to be
+// removed from its schema in a later release.
+func MigrateStorage(ctx context.Context, cfg *rest.Config, name string) error {
+	crdClient, err := apiextensionsv1client.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	crd, err := crdClient.CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	storage := ""
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			storage = v.Name
+		}
+	}
+	var old []string
+	for _, v := range crd.Status.StoredVersions {
+		if v != storage {
+			old = append(old, v)
+		}
+	}
+	if len(old) == 0 {
+		return nil
+	}
+
+	// The objects are only rewritten as the storage version once the API
+	// server serves the updated CRD, which its discovery tells. The hash is
+	// of the logical cluster of the CRD too.
+	dc, err := clientdiscovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	hash := discovery.StorageVersionHash(crd.ClusterName, crd.Spec.Group, storage, crd.Spec.Names.Kind)
+	if err := wait.PollImmediate(time.Second, 30*time.Second, func() (bool, error) {
+		resources, err := dc.ServerResourcesForGroupVersion(schema.GroupVersion{Group: crd.Spec.Group, Version: storage}.String())
+		if err != nil {
+			return false, nil
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == crd.Spec.Names.Plural {
+				return r.StorageVersionHash == hash, nil
+			}
+		}
+		return false, nil
+	}); err != nil {
+		return fmt.Errorf("waiting for %s to be stored as %s: %w", name, storage, err)
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	for _, version := range old {
+		// The objects are updated as the version they were stored as,
+		// which they're valid in, rather than converted.
+		if err := rewrite(ctx, client, crd, version); err != nil {
+			return fmt.Errorf("migrating the %s %s to %s: %w", version, name, storage, err)
+		}
+	}
+
+	klog.Infof("Migrated the objects of CRD %s stored as %v to %s", name, old, storage)
+	crd.Status.StoredVersions = []string{storage}
+	_, err = crdClient.CustomResourceDefinitions().UpdateStatus(ctx, crd, metav1.UpdateOptions{})
+	return err
+}
+
+// rewrite updates every object of the CRD, as version, unchanged, for the
+// API server to store it as the storage version.
+func rewrite(ctx context.Context, client dynamic.Interface, crd *apiextensionsv1.CustomResourceDefinition, version string) error {
+	resource := client.Resource(schema.GroupVersionResource{Group: crd.Spec.Group, Version: version, Resource: crd.Spec.Names.Plural})
+	list, err := resource.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		obj := &list.Items[i]
+		var ri dynamic.ResourceInterface = resource
+		if crd.Spec.Scope == apiextensionsv1.NamespaceScoped {
+			ri = resource.Namespace(obj.GetNamespace())
+		}
+		// Objects changed or deleted since were rewritten by then.
+		if _, err := ri.Update(ctx, obj, metav1.UpdateOptions{}); err != nil && !errors.IsConflict(err) && !errors.IsNotFound(err) {
+			return fmt.Errorf("%s: %w", obj.GetName(), err)
+		}
+	}
+	return nil
+}
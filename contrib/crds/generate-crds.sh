@@ -44,6 +44,21 @@ sed -i -e 's/^\(  name: [^.]*\)\.$/\1.core/' ${destination}/core/*.yaml
 echo "Generating apps/v1 CRDs"
 ${GOPATH}/bin/controller-gen crd:crdVersions=v1 paths=./apps/v1 output:crd:dir=${destination}/apps output:stdout
 
+echo "Generating batch/v1 CRDs"
+${GOPATH}/bin/controller-gen crd:crdVersions=v1 paths=./batch/v1 output:crd:dir=${destination}/batch output:stdout
+
+echo "Generating autoscaling/v1 CRDs"
+${GOPATH}/bin/controller-gen crd:crdVersions=v1 paths=./autoscaling/v1 output:crd:dir=${destination}/autoscaling output:stdout
+
+echo "Generating discovery/v1 CRDs"
+${GOPATH}/bin/controller-gen crd:crdVersions=v1 paths=./discovery/v1 output:crd:dir=${destination}/discovery output:stdout
+
+echo "Generating networking/v1 CRDs"
+${GOPATH}/bin/controller-gen crd:crdVersions=v1 paths=./networking/v1 output:crd:dir=${destination}/networking output:stdout
+
+echo "Removing unnecessary networking/v1 resources"
+rm $(ls ${destination}/networking/*.yaml | grep -v -E '.*(ingresses|networkpolicies)\.yaml')
+
 popd > /dev/null
 popd > /dev/null
 
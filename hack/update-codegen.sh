@@ -27,5 +27,11 @@ bash "${CODEGEN_PKG}"/generate-groups.sh "deepcopy,client,informer,lister" \
   "cluster:v1alpha1" \
   --go-header-file "${SCRIPT_ROOT}"/hack/boilerplate.go.txt
 
+# v1beta1 Clusters are only served through conversion, without clients.
+bash "${CODEGEN_PKG}"/generate-groups.sh "deepcopy" \
+  github.com/kcp-dev/kcp/pkg/client github.com/kcp-dev/kcp/pkg/apis \
+  "cluster:v1beta1" \
+  --go-header-file "${SCRIPT_ROOT}"/hack/boilerplate.go.txt
+
 # Update generated CRD YAML
 ${GOPATH}/bin/controller-gen crd:trivialVersions=true,preserveUnknownFields=false rbac:roleName=manager-role webhook paths="./..." output:crd:artifacts:config=config/
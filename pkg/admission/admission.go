@@ -0,0 +1,141 @@
+// Package admission configures the admission chain of the kcp server, that
+// of the API server serving its CRDs, and so the Deployments, Clusters and
+// other resources kcp serves as CRDs, with the plugins of kcp next to those
+// of Kubernetes, the validating and mutating webhooks among them.
+//
+// kcp doesn't serve the admissionregistration.k8s.io API itself: the
+// configurations of the webhooks are served by CRDs, which must be
+// registered in the admin logical cluster for the webhooks to admit
+// anything.
+package admission
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/kcp-dev/kcp/config"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/namespace/lifecycle"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/mutating"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/validating"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed admissionregistration.k8s.io_*.yaml
+var manifests embed.FS
+
+// Configure registers the plugins of kcp with the admission options of the
+// server, off unless enabled, along with those of Kubernetes. The webhooks
+// are on, and let the CRDs serving their configurations be written without
+// waiting for them, which they otherwise would.
+func Configure(opts *genericoptions.AdmissionOptions) {
+	opts.Plugins = admission.NewPlugins()
+	lifecycle.Register(opts.Plugins)
+	opts.Plugins.Register(mutating.PluginName, func(cfg io.Reader) (admission.Interface, error) {
+		p, err := mutating.NewMutatingWebhook(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &mutatingWebhook{p}, nil
+	})
+	opts.Plugins.Register(validating.PluginName, func(cfg io.Reader) (admission.Interface, error) {
+		p, err := validating.NewValidatingAdmissionWebhook(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &validatingWebhook{p}, nil
+	})
+	opts.Plugins.Register(ClusterRegistrationPluginName, func(io.Reader) (admission.Interface, error) {
+		return NewClusterRegistration(), nil
+	})
+	opts.Plugins.Register(PlacementAnnotationsPluginName, func(io.Reader) (admission.Interface, error) {
+		return NewPlacementAnnotations(), nil
+	})
+	opts.RecommendedPluginOrder = append(opts.RecommendedPluginOrder, ClusterRegistrationPluginName, PlacementAnnotationsPluginName)
+	opts.DefaultOffPlugins.Insert(ClusterRegistrationPluginName, PlacementAnnotationsPluginName)
+	// On as in Kubernetes, once their configurations are served.
+	opts.DefaultOffPlugins.Delete(mutating.PluginName, validating.PluginName)
+}
+
+type mutatingWebhook struct{ *mutating.Plugin }
+
+func (p *mutatingWebhook) Admit(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	if isWebhookConfigurationCRD(a) {
+		return nil
+	}
+	return p.Plugin.Admit(ctx, a, o)
+}
+
+type validatingWebhook struct{ *validating.Plugin }
+
+func (p *validatingWebhook) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	if isWebhookConfigurationCRD(a) {
+		return nil
+	}
+	return p.Plugin.Validate(ctx, a, o)
+}
+
+// isWebhookConfigurationCRD tells whether the CRD of a webhook
+// configuration is being written, which its webhooks can't admit before
+// it's served: their configurations are exempt from them for the same
+// reason.
+func isWebhookConfigurationCRD(a admission.Attributes) bool {
+	if a.GetResource().GroupResource() != apiextensionsv1.Resource("customresourcedefinitions") {
+		return false
+	}
+	switch a.GetName() {
+	case "mutatingwebhookconfigurations.admissionregistration.k8s.io", "validatingwebhookconfigurations.admissionregistration.k8s.io":
+		return true
+	}
+	return false
+}
+
+// CRDs returns the embedded CRDs serving the configurations of the
+// webhooks.
+func CRDs() ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	files, err := manifests.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+
+	crds := make([]*apiextensionsv1.CustomResourceDefinition, 0, len(names))
+	for _, name := range names {
+		data, err := manifests.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(data, crd); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
+
+// RegisterCRDs registers the CRDs serving the configurations of the
+// webhooks in the logical cluster of cfg, the admin one the webhooks read
+// them from.
+func RegisterCRDs(ctx context.Context, cfg *rest.Config) error {
+	crds, err := CRDs()
+	if err != nil {
+		return err
+	}
+	client, err := apiextensionsv1client.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	return config.Bootstrap(ctx, client.CustomResourceDefinitions(), crds...)
+}
@@ -0,0 +1,207 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/mutating"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/validating"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/server"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// serveWebhook denies all the objects it validates.
+func serveWebhook(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(req.Body).Decode(&review); err != nil {
+			t.Error(err)
+		}
+		review.Response = &admissionv1.AdmissionResponse{UID: review.Request.UID, Result: &metav1.Status{Message: "denied for testing"}}
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+}
+
+// newChain returns the admission chain of a server with the given plugins
+// enabled, and the webhook configuration, as the CRDs default them, in its
+// admin logical cluster.
+func newChain(t *testing.T, url string, caBundle []byte, plugins ...string) admission.ValidationInterface {
+	opts := genericoptions.NewAdmissionOptions()
+	Configure(opts)
+	opts.EnablePlugins = plugins
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	failurePolicy, matchPolicy, sideEffects := admissionregistrationv1.Fail, admissionregistrationv1.Equivalent, admissionregistrationv1.SideEffectClassNone
+	timeout := int32(10)
+	client := fake.NewSimpleClientset(&admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:                    "deny.example.com",
+			ClientConfig:            admissionregistrationv1.WebhookClientConfig{URL: &url, CABundle: caBundle},
+			Rules:                   []admissionregistrationv1.RuleWithOperations{{Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll}, Rule: admissionregistrationv1.Rule{APIGroups: []string{"*"}, APIVersions: []string{"*"}, Resources: []string{"*"}}}},
+			FailurePolicy:           &failurePolicy,
+			MatchPolicy:             &matchPolicy,
+			SideEffects:             &sideEffects,
+			TimeoutSeconds:          &timeout,
+			NamespaceSelector:       &metav1.LabelSelector{},
+			ObjectSelector:          &metav1.LabelSelector{MatchLabels: map[string]string{"deny": "true"}},
+			AdmissionReviewVersions: []string{"v1"},
+		}},
+	})
+	factory := informers.NewSharedInformerFactory(client, 0)
+	var config server.Config
+	if err := opts.ApplyTo(&config, factory, &rest.Config{Host: "https://kcp.invalid"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	factory.Start(stop)
+	factory.WaitForCacheSync(stop)
+	return config.AdmissionControl.(admission.ValidationInterface)
+}
+
+func object(apiVersion, kind, name string, labels, annotations map[string]string, fields map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: fields}
+	if u.Object == nil {
+		u.Object = map[string]interface{}{}
+	}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetName(name)
+	u.SetLabels(labels)
+	u.SetAnnotations(annotations)
+	return u
+}
+
+func TestAdmission(t *testing.T) {
+	webhook := serveWebhook(t)
+	defer webhook.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: webhook.Certificate().Raw})
+	chain := newChain(t, webhook.URL, caBundle, validating.PluginName, ClusterRegistrationPluginName, PlacementAnnotationsPluginName)
+
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	clusters := schema.GroupVersionResource{Group: "cluster.example.dev", Version: "v1alpha1", Resource: "clusters"}
+	crds := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	deny := map[string]string{"deny": "true"}
+	for _, tc := range []struct {
+		name        string
+		resource    schema.GroupVersionResource
+		subresource string
+		obj         *unstructured.Unstructured
+		want        string
+	}{{
+		name:     "allowed",
+		resource: deployments,
+		obj:      object("apps/v1", "Deployment", "web", nil, nil, nil),
+	}, {
+		name:     "denied by a webhook",
+		resource: deployments,
+		obj:      object("apps/v1", "Deployment", "web", deny, nil, nil),
+		want:     "denied for testing",
+	}, {
+		name:     "webhook configuration CRD",
+		resource: crds,
+		obj:      object("apiextensions.k8s.io/v1", "CustomResourceDefinition", "validatingwebhookconfigurations.admissionregistration.k8s.io", deny, nil, nil),
+	}, {
+		name:     "other CRD",
+		resource: crds,
+		obj:      object("apiextensions.k8s.io/v1", "CustomResourceDefinition", "widgets.example.com", deny, nil, nil),
+		want:     "denied for testing",
+	}, {
+		name:     "invalid placement",
+		resource: deployments,
+		obj:      object("apps/v1", "Deployment", "web", nil, map[string]string{placement.SchedulingModeAnnotation: "sometimes"}, nil),
+		want:     "forbidden",
+	}, {
+		name:        "placement of a status update",
+		resource:    deployments,
+		subresource: "status",
+		obj:         object("apps/v1", "Deployment", "web", nil, map[string]string{placement.SchedulingModeAnnotation: "sometimes"}, nil),
+	}, {
+		name:     "Cluster without a kubeconfig",
+		resource: clusters,
+		obj:      object("cluster.example.dev/v1alpha1", "Cluster", "east", nil, nil, nil),
+		want:     "spec.kubeconfig or spec.kubeconfigSecretRef must be set",
+	}, {
+		name:     "Cluster with an invalid kubeconfig",
+		resource: clusters,
+		obj:      object("cluster.example.dev/v1alpha1", "Cluster", "east", nil, nil, map[string]interface{}{"spec": map[string]interface{}{"kubeconfig": "{"}}),
+		want:     "invalid spec.kubeconfig",
+	}, {
+		name:     "Cluster with a kubeconfig Secret",
+		resource: clusters,
+		obj: object("cluster.example.dev/v1alpha1", "Cluster", "east", nil, nil, map[string]interface{}{"spec": map[string]interface{}{
+			"kubeconfigSecretRef": map[string]interface{}{"namespace": "kcp-clusters", "name": "east"},
+		}}),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			namespace := "default"
+			if tc.resource != deployments {
+				namespace = ""
+			}
+			a := admission.NewAttributesRecord(tc.obj, nil, tc.obj.GroupVersionKind(), namespace, tc.obj.GetName(), tc.resource, tc.subresource, admission.Create, &metav1.CreateOptions{}, false, &user.DefaultInfo{Name: "admin"})
+			err := chain.Validate(context.Background(), a, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+			switch {
+			case tc.want == "" && err != nil:
+				t.Errorf("got %v", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Errorf("got %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestDefaultPlugins(t *testing.T) {
+	opts := genericoptions.NewAdmissionOptions()
+	// Off in the options of the kcp server.
+	opts.DefaultOffPlugins.Insert(mutating.PluginName, validating.PluginName)
+	Configure(opts)
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for _, name := range []string{ClusterRegistrationPluginName, PlacementAnnotationsPluginName} {
+		if !opts.DefaultOffPlugins.Has(name) {
+			t.Errorf("%s is on by default", name)
+		}
+	}
+	for _, name := range []string{mutating.PluginName, validating.PluginName} {
+		if opts.DefaultOffPlugins.Has(name) {
+			t.Errorf("%s is off by default", name)
+		}
+	}
+}
+
+func TestCRDs(t *testing.T) {
+	crds, err := CRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, crd := range crds {
+		names[crd.Name] = true
+	}
+	for _, name := range []string{"mutatingwebhookconfigurations.admissionregistration.k8s.io", "validatingwebhookconfigurations.admissionregistration.k8s.io"} {
+		if !names[name] {
+			t.Errorf("CRD %s isn't embedded", name)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// The names the plugins of kcp are enabled with.
+const (
+	ClusterRegistrationPluginName  = "ClusterRegistration"
+	PlacementAnnotationsPluginName = "PlacementAnnotations"
+)
+
+// ClusterRegistration rejects the Clusters that couldn't be reached: those
+// with neither or both of a kubeconfig and a kubeconfig Secret reference,
+// with an invalid kubeconfig, or with an incomplete reference.
+type ClusterRegistration struct {
+	*admission.Handler
+}
+
+var _ admission.ValidationInterface = &ClusterRegistration{}
+
+// NewClusterRegistration returns the ClusterRegistration plugin, admitting
+// creates and updates.
+func NewClusterRegistration() *ClusterRegistration {
+	return &ClusterRegistration{Handler: admission.NewHandler(admission.Create, admission.Update)}
+}
+
+func (p *ClusterRegistration) Validate(_ context.Context, a admission.Attributes, _ admission.ObjectInterfaces) error {
+	if a.GetResource().GroupResource() != v1alpha1.SchemeGroupVersion.WithResource("clusters").GroupResource() || a.GetSubresource() != "" {
+		return nil
+	}
+	if err := validateCluster(a.GetObject()); err != nil {
+		return admission.NewForbidden(a, err)
+	}
+	return nil
+}
+
+func validateCluster(obj runtime.Object) error {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	var cl v1alpha1.Cluster
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, &cl); err != nil {
+		return fmt.Errorf("invalid Cluster: %w", err)
+	}
+	switch ref := cl.Spec.KubeConfigSecretRef; {
+	case cl.Spec.KubeConfig == "" && ref == nil:
+		return errors.New("spec.kubeconfig or spec.kubeconfigSecretRef must be set")
+	case cl.Spec.KubeConfig != "" && ref != nil:
+		return errors.New("only one of spec.kubeconfig and spec.kubeconfigSecretRef may be set")
+	case ref != nil && (ref.Namespace == "" || ref.Name == ""):
+		return errors.New("spec.kubeconfigSecretRef must have a namespace and a name")
+	case ref == nil:
+		if _, err := clientcmd.RESTConfigFromKubeConfig([]byte(cl.Spec.KubeConfig)); err != nil {
+			return fmt.Errorf("invalid spec.kubeconfig: %w", err)
+		}
+	}
+	return nil
+}
+
+// PlacementAnnotations rejects the objects whose placement annotations the
+// splitters couldn't parse, rather than leaving them unplaced: the
+// scheduling mode, the cluster tolerations and the cluster selector.
+type PlacementAnnotations struct {
+	*admission.Handler
+}
+
+var _ admission.ValidationInterface = &PlacementAnnotations{}
+
+// NewPlacementAnnotations returns the PlacementAnnotations plugin, admitting
+// creates and updates.
+func NewPlacementAnnotations() *PlacementAnnotations {
+	return &PlacementAnnotations{Handler: admission.NewHandler(admission.Create, admission.Update)}
+}
+
+func (p *PlacementAnnotations) Validate(_ context.Context, a admission.Attributes, _ admission.ObjectInterfaces) error {
+	if a.GetSubresource() != "" {
+		return nil
+	}
+	obj, err := meta.Accessor(a.GetObject())
+	if err != nil {
+		return nil
+	}
+	if err := validatePlacement(obj.GetAnnotations()); err != nil {
+		return admission.NewForbidden(a, err)
+	}
+	return nil
+}
+
+func validatePlacement(annotations map[string]string) error {
+	if _, err := placement.SchedulingModeFor(annotations); err != nil {
+		return err
+	}
+	if _, err := placement.TolerationsFor(annotations); err != nil {
+		return err
+	}
+	if s, found := annotations[scheduler.ClusterSelectorAnnotation]; found {
+		if _, err := labels.Parse(s); err != nil {
+			return fmt.Errorf("invalid %s: %w", scheduler.ClusterSelectorAnnotation, err)
+		}
+	}
+	return nil
+}
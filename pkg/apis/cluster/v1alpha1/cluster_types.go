@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -45,12 +46,158 @@ type Cluster struct {
 
 // ClusterSpec holds the desired state of the Cluster (from the client).
 type ClusterSpec struct {
-	KubeConfig string `json:"kubeconfig"`
+	// KubeConfig reaches the Cluster's API server. Prefer
+	// KubeConfigSecretRef, which keeps the credentials out of the Cluster.
+	// +optional
+	KubeConfig string `json:"kubeconfig,omitempty"`
+
+	// KubeConfigSecretRef refers to a Secret holding the kubeconfig to reach
+	// the Cluster's API server with, instead of KubeConfig. Changes to the
+	// Secret are picked up, so its credentials can be rotated.
+	// +optional
+	KubeConfigSecretRef *KubeConfigSecretReference `json:"kubeconfigSecretRef,omitempty"`
+
+	// Weight is this Cluster's share of split workloads' replicas, relative
+	// to the other Clusters. Unset or zero means the default weight of 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Weight int32 `json:"weight,omitempty"`
+
+	// Unschedulable cordons the Cluster: no new workloads are placed on it,
+	// as if it had a NoSchedule taint, but those already there stay.
+	// +optional
+	Unschedulable bool `json:"unschedulable,omitempty"`
+
+	// Taints keep workloads that don't tolerate them off the Cluster, like
+	// node taints do for pods: NoSchedule ones stop new workloads from being
+	// placed on it, and NoExecute ones also move the workloads already there.
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+
+	// SyncedResources are the resources the Cluster's syncer syncs, as
+	// <resource> or <resource>.<group>, e.g. deployments.apps. Unset means
+	// those the Cluster Controller syncs by default.
+	// +optional
+	SyncedResources []string `json:"syncedResources,omitempty"`
+
+	// SyncerRateLimit bounds the requests the Cluster's syncer makes to
+	// it, for the small clusters that can't take many.
+	// +optional
+	SyncerRateLimit *SyncerRateLimit `json:"syncerRateLimit,omitempty"`
+}
+
+// SyncerRateLimit bounds the requests a syncer makes to its Cluster.
+type SyncerRateLimit struct {
+	// QPS is the sustained number of requests per second. Unset or zero
+	// means the client default of 5.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	QPS int32 `json:"qps,omitempty"`
+
+	// Burst is how many requests may be made at once above QPS. Unset or
+	// zero means the client default of 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Burst int32 `json:"burst,omitempty"`
+
+	// Adaptive makes the syncer slow down, down to a tenth of QPS, while
+	// the Cluster answers with 429 Too Many Requests, and speed back up as
+	// its requests succeed again.
+	// +optional
+	Adaptive bool `json:"adaptive,omitempty"`
+}
+
+// KubeConfigSecretReference refers to a key of a Secret holding a kubeconfig.
+type KubeConfigSecretReference struct {
+	// Namespace of the Secret, in the logical cluster of the Cluster.
+	Namespace string `json:"namespace"`
+	// Name of the Secret.
+	Name string `json:"name"`
+	// Key of the kubeconfig in the Secret. Defaults to "kubeconfig".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// DefaultKubeConfigSecretKey is the key of the kubeconfig in the Secrets
+// referred to by Clusters that don't set one.
+const DefaultKubeConfigSecretKey = "kubeconfig"
+
+// DefaultClusterWeight is the weight of Clusters that don't set one.
+const DefaultClusterWeight = 1
+
+// EffectiveWeight returns the Cluster's weight, defaulted if unset.
+func (c *Cluster) EffectiveWeight() int32 {
+	if c.Spec.Weight <= 0 {
+		return DefaultClusterWeight
+	}
+	return c.Spec.Weight
 }
 
 // ClusterStatus communicates the observed state of the Cluster (from the controller).
 type ClusterStatus struct {
+	// ObservedGeneration is the generation of the Cluster the Cluster Controller last
+	// reconciled, which the rest of the status reflects.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	Conditions Conditions `json:"conditions,omitempty"`
+
+	// Allocatable is the sum of the allocatable resources of the Cluster's
+	// Ready, schedulable nodes, as last observed.
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+
+	// Requested is the sum of the resource requests of the pods running or
+	// pending on those nodes, as last observed.
+	// +optional
+	Requested corev1.ResourceList `json:"requested,omitempty"`
+
+	// Architectures are the CPU architectures of those nodes, as their
+	// kubernetes.io/arch labels have them, as last observed. Workloads
+	// selecting an architecture are only placed on the Clusters with a node
+	// of it.
+	// +optional
+	Architectures []string `json:"architectures,omitempty"`
+
+	// SyncedResources are the resources the Cluster's syncer was last set
+	// up to sync: those of its spec, or the defaults, that the cluster
+	// serves. Workloads of other resources aren't placed on the Cluster.
+	// Unset means unknown yet, and doesn't rule any out.
+	// +optional
+	SyncedResources []string `json:"syncedResources,omitempty"`
+
+	// StorageClasses are the StorageClasses the Cluster provisions volumes
+	// with, as last observed. Workloads with volume claims are only placed
+	// on the Clusters with a StorageClass for each of them.
+	// +optional
+	StorageClasses []ClusterStorageClass `json:"storageClasses,omitempty"`
+
+	// NetworkPolicies is whether the Cluster enforces NetworkPolicies, as
+	// its network plugin does, as last observed. NetworkPolicies are only
+	// synced to the Clusters that do.
+	// +optional
+	NetworkPolicies bool `json:"networkPolicies,omitempty"`
+}
+
+// ClusterStorageClass is a StorageClass of a Cluster.
+type ClusterStorageClass struct {
+	// Name of the StorageClass.
+	Name string `json:"name"`
+
+	// Provisioner of its volumes.
+	// +optional
+	Provisioner string `json:"provisioner,omitempty"`
+
+	// Default is whether it's the Cluster's default StorageClass, the one
+	// the volume claims that name none get.
+	// +optional
+	Default bool `json:"default,omitempty"`
+
+	// AccessModes are those its volumes can be claimed with, e.g.
+	// ReadWriteMany for those that can be mounted by the replicas of a
+	// workload on several nodes.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
 }
 
 // ClusterList is a list of Cluster resources
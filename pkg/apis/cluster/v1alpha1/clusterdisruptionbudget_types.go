@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterDisruptionBudget keeps the Deployments it selects in its namespace
+// on a minimum number of Clusters at all times, much like a
+// PodDisruptionBudget keeps a minimum number of pods: their replicas aren't
+// removed from a Cluster, as it's drained, evicted from or not allowed
+// anymore, until they're available on enough others.
+//
+// +crd
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced
+type ClusterDisruptionBudget struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec ClusterDisruptionBudgetSpec `json:"spec,omitempty"`
+}
+
+// ClusterDisruptionBudgetSpec holds the workloads to keep and on how many
+// Clusters.
+type ClusterDisruptionBudgetSpec struct {
+	// WorkloadSelector selects the workloads in the
+	// ClusterDisruptionBudget's namespace that it applies to. An empty
+	// selector selects all of them.
+	// +optional
+	WorkloadSelector *metav1.LabelSelector `json:"workloadSelector,omitempty"`
+
+	// MinClusters is the number of Clusters each selected workload must
+	// have available replicas on, for it to be moved off another one.
+	// +kubebuilder:validation:Minimum=1
+	MinClusters int32 `json:"minClusters"`
+}
+
+// ClusterDisruptionBudgetList is a list of ClusterDisruptionBudget resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ClusterDisruptionBudgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ClusterDisruptionBudget `json:"items"`
+}
@@ -23,41 +23,158 @@ import (
 
 type Conditions []Condition
 
+// Get returns the condition of the given type, or nil if there's none.
+func (c Conditions) Get(t ConditionType) *Condition {
+	for i := range c {
+		if c[i].Type == t {
+			return &c[i]
+		}
+	}
+	return nil
+}
+
 func (c Conditions) HasReady() bool {
-	for _, cond := range c {
-		if cond.Type == ClusterConditionReady {
-			return true
+	return c.Get(ClusterConditionReady) != nil
+}
+
+// IsReady returns true if the Ready condition is present and True.
+func (c Conditions) IsReady() bool {
+	cond := c.Get(ClusterConditionReady)
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
+
+// IsUnreachable returns true if the Unreachable condition is present and True.
+func (c Conditions) IsUnreachable() bool {
+	cond := c.Get(ClusterConditionUnreachable)
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
+
+// IsEvicted returns true if the Evicted condition is present and True.
+func (c Conditions) IsEvicted() bool {
+	cond := c.Get(ClusterConditionEvicted)
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
+
+// IsAccepted returns true if the Accepted condition is present and True.
+func (c Conditions) IsAccepted() bool {
+	cond := c.Get(ClusterConditionAccepted)
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
+
+func (c *Conditions) SetReady(status corev1.ConditionStatus, reason, message string) {
+	c.Set(ClusterConditionReady, status, reason, message)
+}
+
+// Set sets the condition of the given type, recording now as its heartbeat
+// time. The transition time only moves when the status changes. Controllers
+// set the conditions of their objects with the conditions package instead,
+// which also records the generation they were set from.
+func (c *Conditions) Set(t ConditionType, status corev1.ConditionStatus, reason, message string) {
+	now := metav1.Now()
+	cond := Condition{
+		Type:               t,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if prev := c.Get(t); prev != nil {
+		if prev.Status == status {
+			cond.LastTransitionTime = prev.LastTransitionTime
 		}
+		*prev = cond
+		return
 	}
-	return false
+	*c = append(*c, cond)
 }
 
-func (c Conditions) SetReady(status corev1.ConditionStatus, reason, message string) {
-	for idx, cond := range c {
-		if cond.Type == ClusterConditionReady {
-			c[idx] = Condition{
-				Type:               ClusterConditionReady,
-				Status:             status,
-				Reason:             reason,
-				Message:            message,
-				LastTransitionTime: metav1.Now(),
-			}
+// Remove removes the condition of the given type, if it's set.
+func (c *Conditions) Remove(t ConditionType) {
+	for i := range *c {
+		if (*c)[i].Type == t {
+			*c = append((*c)[:i:i], (*c)[i+1:]...)
 			return
 		}
 	}
-	c = append(c, Condition{
-		Type:               ClusterConditionReady,
-		Status:             status,
-		Reason:             reason,
-		Message:            message,
-		LastTransitionTime: metav1.Now(),
-	})
 }
 
+// GetConditions returns the conditions of the Cluster.
+func (c *Cluster) GetConditions() Conditions { return c.Status.Conditions }
+
+// SetConditions sets the conditions of the Cluster.
+func (c *Cluster) SetConditions(conditions Conditions) { c.Status.Conditions = conditions }
+
+// GetConditions returns the conditions of the NegotiatedAPIResource.
+func (r *NegotiatedAPIResource) GetConditions() Conditions { return r.Status.Conditions }
+
+// SetConditions sets the conditions of the NegotiatedAPIResource.
+func (r *NegotiatedAPIResource) SetConditions(conditions Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// GetConditions returns the conditions of the ServiceExport.
+func (e *ServiceExport) GetConditions() Conditions { return e.Status.Conditions }
+
+// SetConditions sets the conditions of the ServiceExport.
+func (e *ServiceExport) SetConditions(conditions Conditions) { e.Status.Conditions = conditions }
+
+// GetConditions returns the conditions of the Workspace.
+func (w *Workspace) GetConditions() Conditions { return w.Status.Conditions }
+
+// SetConditions sets the conditions of the Workspace.
+func (w *Workspace) SetConditions(conditions Conditions) { w.Status.Conditions = conditions }
+
+// GetConditions returns the conditions of the WorkspaceQuota.
+func (q *WorkspaceQuota) GetConditions() Conditions { return q.Status.Conditions }
+
+// SetConditions sets the conditions of the WorkspaceQuota.
+func (q *WorkspaceQuota) SetConditions(conditions Conditions) { q.Status.Conditions = conditions }
+
 type ConditionType string
 
 const (
 	ClusterConditionReady = ConditionType("Ready")
+
+	// ClusterConditionUnreachable is True when the Cluster's API server
+	// couldn't be reached the last time it was probed.
+	ClusterConditionUnreachable = ConditionType("Unreachable")
+
+	// ClusterConditionEvicted is True once the Cluster has stayed NotReady
+	// for longer than the eviction toleration, and workloads were moved off it.
+	ClusterConditionEvicted = ConditionType("Evicted")
+
+	// ClusterConditionDrained is set while the Cluster is being drained, and
+	// True once no workloads that must be moved off it are left on it.
+	ClusterConditionDrained = ConditionType("Drained")
+
+	// ClusterConditionAccepted is set on the Clusters registered by their
+	// syncer, and True once the bootstrap token it presented was valid.
+	ClusterConditionAccepted = ConditionType("Accepted")
+
+	// ClusterConditionAPIsImported is True once the schemas of the APIs the
+	// Cluster serves were imported into kcp as CRDs.
+	ClusterConditionAPIsImported = ConditionType("APIsImported")
+
+	// NegotiatedAPIResourceConditionCompatible is True when all the Clusters
+	// a resource was imported from are compatible with its published schema.
+	NegotiatedAPIResourceConditionCompatible = ConditionType("Compatible")
+
+	// NegotiatedAPIResourceConditionPublished is True once the negotiated
+	// schema of a resource was published as a CRD.
+	NegotiatedAPIResourceConditionPublished = ConditionType("Published")
+
+	// WorkspaceConditionInitialized is True once the Workspace's logical
+	// cluster was set up, and False with the reason it couldn't be.
+	WorkspaceConditionInitialized = ConditionType("Initialized")
+
+	// ServiceExportConditionValid is True when the exported Service exists
+	// and can be imported, as a ServiceImport of the same name.
+	ServiceExportConditionValid = ConditionType("Valid")
+
+	// WorkspaceQuotaConditionExceeded is True when the placed workloads of
+	// the workspace use more of a resource than the WorkspaceQuota allows.
+	WorkspaceQuotaConditionExceeded = ConditionType("Exceeded")
 )
 
 // TODO: Use metav1.Condition (available in v1.19+)
@@ -70,6 +187,18 @@ type Condition struct {
 	// +required
 	Status corev1.ConditionStatus `json:"status"`
 
+	// ObservedGeneration is the generation of the object the condition was
+	// last set from: when it's older than the object's, the condition may no
+	// longer hold.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastHeartbeatTime is the last time the condition changed, as its
+	// status, reason, message or observed generation.
+	// +optional
+	LastHeartbeatTime metav1.Time `json:"lastHeartbeatTime,omitempty"`
+
 	// LastTransitionTime is the last time the condition transitioned from one status to another.
 	// We use VolatileTime in place of metav1.Time to exclude this from creating equality.Semantic
 	// differences (all other things held constant).
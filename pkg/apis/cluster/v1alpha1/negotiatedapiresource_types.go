@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// NegotiatedAPIResource describes a resource imported from one or more
+// Clusters, which may serve it with different versions and schemas. It's
+// named like the CRD it's published as in its logical cluster.
+//
+// The Clusters' schemas are recorded in its spec as they're imported, and
+// the negotiation controller records in its status the version and schema
+// compatible with all of them that it publishes, and the Clusters it had to
+// reject because they weren't.
+//
+// +crd
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+type NegotiatedAPIResource struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the schemas imported from the Clusters.
+	// +optional
+	Spec NegotiatedAPIResourceSpec `json:"spec,omitempty"`
+
+	// Status holds the outcome of the negotiation.
+	// +optional
+	Status NegotiatedAPIResourceStatus `json:"status,omitempty"`
+}
+
+// NegotiatedAPIResourceSpec holds the schemas a resource was imported with.
+type NegotiatedAPIResourceSpec struct {
+	// Imports are the resource as served by each Cluster, in the order they
+	// were first imported. Earlier ones win conflicts.
+	// +optional
+	Imports []APIResourceImport `json:"imports,omitempty"`
+}
+
+// APIResourceImport describes a resource as served by a Cluster.
+type APIResourceImport struct {
+	// Cluster is the name of the Cluster serving the resource.
+	Cluster string `json:"cluster"`
+
+	// Group is the API group of the resource, "" for the core group.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Names are the names the resource is served with.
+	Names apiextensionsv1.CustomResourceDefinitionNames `json:"names"`
+
+	// Scope is whether the resource is Namespaced or Cluster scoped.
+	Scope apiextensionsv1.ResourceScope `json:"scope"`
+
+	// Versions are the versions of the resource the Cluster serves.
+	Versions []APIResourceVersion `json:"versions"`
+}
+
+// APIResourceVersion describes a version of a resource.
+type APIResourceVersion struct {
+	// Name is the name of the version, e.g. v1beta1.
+	Name string `json:"name"`
+
+	// Schema is the OpenAPI v3 schema of the version.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Schema runtime.RawExtension `json:"schema"`
+
+	// Subresources are the subresources served for the version.
+	// +optional
+	Subresources *apiextensionsv1.CustomResourceSubresources `json:"subresources,omitempty"`
+}
+
+// NegotiatedAPIResourceStatus is the outcome of the negotiation of a
+// resource's schema.
+type NegotiatedAPIResourceStatus struct {
+	// ObservedGeneration is the generation of the NegotiatedAPIResource the negotiation controller last
+	// reconciled, which the rest of the status reflects.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Version is the version of the resource that's published: the most
+	// recent one all Clusters serve, else the one most of them do.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Schema is the published schema, the fields of the Clusters' schemas for
+	// Version that all the compatible ones have.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Schema runtime.RawExtension `json:"schema,omitempty"`
+
+	// Clusters are whether each Cluster is compatible with the published
+	// schema, in the order of the Imports.
+	// +optional
+	Clusters []NegotiatedCluster `json:"clusters,omitempty"`
+
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
+}
+
+// NegotiatedCluster is whether a Cluster is compatible with the published
+// schema of a resource. Incompatible Clusters aren't among those it's
+// labeled as imported from.
+type NegotiatedCluster struct {
+	// Name is the name of the Cluster.
+	Name string `json:"name"`
+
+	// Compatible is whether the Cluster is compatible.
+	Compatible bool `json:"compatible"`
+
+	// Reason is why the Cluster isn't compatible.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// NegotiatedAPIResourceList is a list of NegotiatedAPIResource resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type NegotiatedAPIResourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []NegotiatedAPIResource `json:"items"`
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PlacementPolicy describes which Clusters the workloads it selects are
+// split across.
+//
+// +crd
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced
+
+type PlacementPolicy struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the desired placement.
+	// +optional
+	Spec PlacementPolicySpec `json:"spec,omitempty"`
+}
+
+// PlacementPolicySpec holds the desired placement of the selected workloads.
+type PlacementPolicySpec struct {
+	// WorkloadSelector selects the workloads in the PlacementPolicy's
+	// namespace that it applies to. An empty selector selects all of them.
+	// +optional
+	WorkloadSelector *metav1.LabelSelector `json:"workloadSelector,omitempty"`
+
+	// ClusterSelector restricts placement to the Clusters whose labels match.
+	// An empty selector selects all Clusters.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+
+	// MaxClusters is the maximum number of Clusters a workload is split
+	// across. Unset means no limit.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxClusters *int32 `json:"maxClusters,omitempty"`
+
+	// ClusterAffinity further restricts and ranks the Clusters workloads are
+	// placed on, by their labels.
+	// +optional
+	ClusterAffinity *ClusterAffinity `json:"clusterAffinity,omitempty"`
+
+	// ClusterAntiAffinity keeps workloads off the Clusters that other
+	// workloads of the same kind in the namespace, selected by any of the
+	// terms, are already placed on.
+	// +optional
+	ClusterAntiAffinity []ClusterAntiAffinityTerm `json:"clusterAntiAffinity,omitempty"`
+
+	// SpreadConstraints spread workloads across topology domains of Clusters.
+	// +optional
+	SpreadConstraints []SpreadConstraint `json:"spreadConstraints,omitempty"`
+
+	// Failback is when workloads return to the Clusters they were evicted
+	// from once these are Ready again. Unset, split workloads return right
+	// away and pinned ones stay on the Cluster they were moved to.
+	// +optional
+	Failback *Failback `json:"failback,omitempty"`
+}
+
+// ClusterAffinity selects Clusters by their labels, as required or preferred.
+type ClusterAffinity struct {
+	// Required restricts placement to the Clusters matched by at least one
+	// of the selectors, on top of the ClusterSelector. An empty list allows
+	// all Clusters.
+	// +optional
+	Required []metav1.LabelSelector `json:"required,omitempty"`
+
+	// Preferred ranks the allowed Clusters by the sum of the weights of the
+	// terms matching them. Higher ranked Clusters are kept first when
+	// MaxClusters applies, and chosen first for pinned workloads.
+	// +optional
+	Preferred []PreferredClusterTerm `json:"preferred,omitempty"`
+}
+
+// PreferredClusterTerm weighs the Clusters matched by a selector.
+type PreferredClusterTerm struct {
+	// Weight is added to the rank of the matching Clusters.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+
+	// Selector matches the labels of the preferred Clusters.
+	Selector metav1.LabelSelector `json:"selector"`
+}
+
+// ClusterAntiAffinityTerm selects the workloads whose Clusters are avoided.
+type ClusterAntiAffinityTerm struct {
+	// WorkloadSelector selects workloads of the same kind in the
+	// PlacementPolicy's namespace. The workload being placed is never
+	// selected itself.
+	WorkloadSelector metav1.LabelSelector `json:"workloadSelector"`
+}
+
+// SpreadConstraint spreads a workload's Clusters across the values of a
+// Cluster label.
+type SpreadConstraint struct {
+	// TopologyKey is the key of a Cluster label. Clusters with the same value
+	// for it are in the same domain; Clusters without it are not eligible.
+	TopologyKey string `json:"topologyKey"`
+
+	// MinDomains is the minimum number of distinct domains a workload must be
+	// placed in. If fewer are available the workload is not placed.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinDomains int32 `json:"minDomains,omitempty"`
+
+	// SpreadReplicas splits a workload's replicas evenly across the domains,
+	// rather than by the weights of their Clusters, which then only split
+	// the replicas of their domain. Only the first constraint that sets it
+	// applies.
+	// +optional
+	SpreadReplicas bool `json:"spreadReplicas,omitempty"`
+}
+
+// FailbackMode is when workloads return to a Cluster they were evicted from.
+// +kubebuilder:validation:Enum=Immediate;Delayed;Manual
+type FailbackMode string
+
+const (
+	// FailbackImmediate returns workloads as soon as the Cluster is Ready.
+	FailbackImmediate FailbackMode = "Immediate"
+	// FailbackDelayed returns workloads once the Cluster has stayed Ready
+	// for the Delay, so that a flapping Cluster doesn't get them back.
+	FailbackDelayed FailbackMode = "Delayed"
+	// FailbackManual returns workloads once the Cluster is annotated
+	// experimental.kcp.dev/failback with a time after it was Ready again.
+	FailbackManual FailbackMode = "Manual"
+)
+
+// Failback is when workloads return to the Clusters they were evicted from,
+// the first of them ranked by ClusterAffinity for pinned workloads.
+type Failback struct {
+	// Mode is when workloads return.
+	Mode FailbackMode `json:"mode"`
+
+	// Delay is how long a Cluster must stay Ready before workloads return
+	// to it in the Delayed mode, 5 minutes by default.
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+}
+
+// PlacementPolicyList is a list of PlacementPolicy resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PlacementPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PlacementPolicy `json:"items"`
+}
@@ -46,6 +46,28 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Cluster{},
 		&ClusterList{},
+		&ClusterDisruptionBudget{},
+		&ClusterDisruptionBudgetList{},
+		&PlacementPolicy{},
+		&PlacementPolicyList{},
+		&RegistryCredentialPolicy{},
+		&RegistryCredentialPolicyList{},
+		&SecretDistribution{},
+		&SecretDistributionList{},
+		&ServiceExport{},
+		&ServiceExportList{},
+		&ServiceImport{},
+		&ServiceImportList{},
+		&NegotiatedAPIResource{},
+		&NegotiatedAPIResourceList{},
+		&Workspace{},
+		&WorkspaceList{},
+		&WorkspaceQuota{},
+		&WorkspaceQuotaList{},
+		&WorkloadOverride{},
+		&WorkloadOverrideList{},
+		&WorkspaceType{},
+		&WorkspaceTypeList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
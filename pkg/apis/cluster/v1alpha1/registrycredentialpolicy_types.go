@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RegistryCredentialPolicy makes the registry credentials it refers to
+// available to the workloads of every namespace of the workspace it's created
+// in, on every Cluster they're placed on, so that their pods can pull their
+// images wherever they run. The credentials are copied to each namespace, and
+// added to the image pull secrets of the pods of the leafs placed there,
+// which syncs them to the Clusters along with the leafs.
+//
+// +crd
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+type RegistryCredentialPolicy struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec RegistryCredentialPolicySpec `json:"spec,omitempty"`
+}
+
+// RegistryCredentialPolicySpec holds the credentials and where to make them
+// available.
+type RegistryCredentialPolicySpec struct {
+	// SecretRefs refer to the Secrets holding the credentials, of type
+	// kubernetes.io/dockerconfigjson or kubernetes.io/dockercfg. Each is
+	// copied under its own name to the other namespaces, where a Secret of
+	// the same name it didn't copy is left alone.
+	SecretRefs []corev1.SecretReference `json:"secretRefs"`
+
+	// NamespaceSelector selects the namespaces the credentials are made
+	// available to. An empty selector selects all namespaces.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// RegistryCredentialPolicyList is a list of RegistryCredentialPolicy resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type RegistryCredentialPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []RegistryCredentialPolicy `json:"items"`
+}
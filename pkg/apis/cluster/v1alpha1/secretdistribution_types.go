@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SecretDistribution distributes the Secrets it selects in its namespace to
+// the Clusters it selects, whether or not a workload synced there references
+// them, and pushes them again whenever they're rotated. Each Cluster gets a
+// copy of its own in kcp, synced under the name of the Secret it's copied
+// from.
+//
+// +crd
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced
+type SecretDistribution struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec SecretDistributionSpec `json:"spec,omitempty"`
+}
+
+// SecretDistributionSpec holds the Secrets to distribute and where to.
+type SecretDistributionSpec struct {
+	// SecretSelector selects the Secrets in the SecretDistribution's
+	// namespace to distribute. An empty selector selects all of them, but
+	// for the tokens of ServiceAccounts, which are never distributed.
+	SecretSelector *metav1.LabelSelector `json:"secretSelector"`
+
+	// ClusterSelector selects the Clusters to distribute the Secrets to.
+	// An empty selector selects all Clusters.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+}
+
+// SecretDistributionList is a list of SecretDistribution resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SecretDistributionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []SecretDistribution `json:"items"`
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceExport exports the Service of the same name in its namespace to
+// every Cluster of its workspace, as a ServiceImport that kcp derives from
+// it, following the Kubernetes Multi-Cluster Services API: clients on any
+// Cluster reach the Service's endpoints on all the Clusters it's mirrored
+// to as <service>.<namespace>.svc.clusterset.local.
+//
+// +crd
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+type ServiceExport struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Status ServiceExportStatus `json:"status,omitempty"`
+}
+
+// ServiceExportStatus tells whether the Service was exported.
+type ServiceExportStatus struct {
+	// Conditions hold Valid, True once the Service is exported, with the
+	// DNS name it's reachable as, and False with the reason it can't be.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
+}
+
+// ServiceExportList is a list of ServiceExport resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ServiceExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ServiceExport `json:"items"`
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceImport is the multi-cluster Service that kcp derives from a
+// ServiceExport, with the same name, fields and semantics as that of the
+// Kubernetes Multi-Cluster Services API. Syncers run with
+// --service_imports program it on their Cluster, with the endpoints of
+// every Cluster the Service is on.
+//
+// +crd
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+type ServiceImport struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec ServiceImportSpec `json:"spec,omitempty"`
+	// +optional
+	Status ServiceImportStatus `json:"status,omitempty"`
+}
+
+// ServiceImportType is how a ServiceImport is reached.
+type ServiceImportType string
+
+const (
+	// ServiceImportTypeClusterSetIP imports are reached through a virtual
+	// IP of their own on each Cluster.
+	ServiceImportTypeClusterSetIP ServiceImportType = "ClusterSetIP"
+	// ServiceImportTypeHeadless imports, of headless Services, resolve to
+	// their endpoints.
+	ServiceImportTypeHeadless ServiceImportType = "Headless"
+)
+
+// ServiceImportSpec is what the exported Service serves, and how.
+type ServiceImportSpec struct {
+	// +listType=atomic
+	Ports []ServiceImportPort `json:"ports"`
+
+	// IPs are the virtual IPs the import is reached through, which each
+	// Cluster's Multi-Cluster Services implementation allocates; kcp
+	// leaves them empty.
+	// +optional
+	// +kubebuilder:validation:MaxItems=1
+	IPs []string `json:"ips,omitempty"`
+
+	// +kubebuilder:validation:Enum=ClusterSetIP;Headless
+	Type ServiceImportType `json:"type"`
+
+	// SessionAffinity is that of the exported Service.
+	// +optional
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+
+	// +optional
+	SessionAffinityConfig *corev1.SessionAffinityConfig `json:"sessionAffinityConfig,omitempty"`
+}
+
+// ServiceImportPort is a port of the exported Service.
+type ServiceImportPort struct {
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// +optional
+	AppProtocol *string `json:"appProtocol,omitempty"`
+
+	Port int32 `json:"port"`
+}
+
+// ServiceImportStatus is where the exported Service's endpoints are.
+type ServiceImportStatus struct {
+	// Clusters are those the exported Service has endpoints on.
+	// +optional
+	// +listType=map
+	// +listMapKey=cluster
+	Clusters []ServiceImportCluster `json:"clusters,omitempty"`
+}
+
+// ServiceImportCluster is a Cluster the exported Service has endpoints on.
+type ServiceImportCluster struct {
+	// Cluster is the name of the Cluster.
+	Cluster string `json:"cluster"`
+}
+
+// ServiceImportList is a list of ServiceImport resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ServiceImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ServiceImport `json:"items"`
+}
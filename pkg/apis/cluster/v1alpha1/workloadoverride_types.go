@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadOverride patches the leafs the workloads it selects in its
+// namespace are split into, on the Clusters it selects, before they're
+// synced: so that one root workload can differ per Cluster, e.g. pull its
+// images from a regional registry, or run more replicas in one region.
+//
+// The WorkloadOverrides that apply to a leaf are applied in the order of
+// their names, each with its patches in order.
+//
+// +crd
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced
+type WorkloadOverride struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec WorkloadOverrideSpec `json:"spec,omitempty"`
+}
+
+// WorkloadOverrideSpec holds the leafs to override and how.
+type WorkloadOverrideSpec struct {
+	// WorkloadSelector selects the workloads in the WorkloadOverride's
+	// namespace whose leafs it applies to. An empty selector selects all of
+	// them.
+	// +optional
+	WorkloadSelector *metav1.LabelSelector `json:"workloadSelector,omitempty"`
+
+	// Kinds restricts the WorkloadOverride to the workloads of these kinds,
+	// e.g. Deployment. An empty list allows all kinds.
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Clusters names the Clusters whose leafs are overridden.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// ClusterSelector selects the Clusters whose leafs are overridden, on
+	// top of those named by Clusters. When both are empty, the leafs on all
+	// Clusters are.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+
+	// Patches are applied to the leafs in order.
+	Patches []WorkloadPatch `json:"patches"`
+}
+
+// WorkloadPatchType is how a WorkloadPatch is applied.
+type WorkloadPatchType string
+
+const (
+	// WorkloadPatchJSON is an RFC 6902 JSON patch.
+	WorkloadPatchJSON WorkloadPatchType = "json"
+	// WorkloadPatchMerge is an RFC 7386 JSON merge patch.
+	WorkloadPatchMerge WorkloadPatchType = "merge"
+	// WorkloadPatchStrategicMerge is a strategic merge patch, like kubectl
+	// patch applies, for the built-in kinds. It's applied as a JSON merge
+	// patch to the others.
+	WorkloadPatchStrategicMerge WorkloadPatchType = "strategic"
+)
+
+// WorkloadPatch is a patch of the leafs of a workload.
+type WorkloadPatch struct {
+	// Type is how the patch is applied: json, merge or strategic.
+	// +kubebuilder:validation:Enum=json;merge;strategic
+	Type WorkloadPatchType `json:"type"`
+
+	// Patch is the patch, as JSON or YAML.
+	Patch string `json:"patch"`
+}
+
+// WorkloadOverrideList is a list of WorkloadOverride resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type WorkloadOverrideList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkloadOverride `json:"items"`
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Workspace is a logical cluster of its own, named like it, with an API
+// surface isolated from that of the other workspaces: its objects are
+// stored under their own keys, and the Clusters, PlacementPolicies and
+// workloads it holds are only placed and synced with one another.
+//
+// Workspaces are created in the admin logical cluster, and the workspace
+// controller sets them up to be reached at their URL.
+//
+// +crd
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+type Workspace struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec WorkspaceSpec `json:"spec,omitempty"`
+
+	// +optional
+	Status WorkspaceStatus `json:"status,omitempty"`
+}
+
+// WorkspaceSpec holds the desired state of the Workspace.
+type WorkspaceSpec struct {
+	// Description is what the Workspace is for.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Shard is the kcp shard to keep the Workspace on, when kcp is sharded,
+	// rather than one chosen by the workspace controller. It's only
+	// honored when the Workspace is assigned to a shard: Workspaces aren't
+	// moved between shards.
+	// +optional
+	Shard string `json:"shard,omitempty"`
+
+	// Type names the WorkspaceType the Workspace is set up from, if any.
+	// +optional
+	Type string `json:"type,omitempty"`
+}
+
+// WorkspacePhase is where a Workspace is in its lifecycle.
+type WorkspacePhase string
+
+const (
+	// WorkspacePhaseInitializing is the phase of the Workspaces not set up
+	// yet.
+	WorkspacePhaseInitializing WorkspacePhase = "Initializing"
+
+	// WorkspacePhaseActive is the phase of the Workspaces ready to be used.
+	WorkspacePhaseActive WorkspacePhase = "Active"
+)
+
+// WorkspaceStatus communicates the observed state of the Workspace.
+type WorkspaceStatus struct {
+	// ObservedGeneration is the generation of the Workspace the workspace controller last
+	// reconciled, which the rest of the status reflects.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Phase is where the Workspace is in its lifecycle.
+	// +optional
+	Phase WorkspacePhase `json:"phase,omitempty"`
+
+	// URL is the URL of the API server of the Workspace, to use in the
+	// kubeconfigs of its tenants.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Shard is the kcp shard the Workspace is assigned to, where its
+	// logical cluster is kept, when kcp is sharded.
+	// +optional
+	Shard string `json:"shard,omitempty"`
+
+	// Usage is what the logical cluster of the Workspace stores, as last
+	// counted by the usage controller, if it runs with its status updates.
+	// +optional
+	Usage *WorkspaceUsage `json:"usage,omitempty"`
+
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
+}
+
+// WorkspaceUsage is what the logical cluster of a Workspace stores, for
+// the resources the usage controller counts.
+type WorkspaceUsage struct {
+	// Objects is how many objects of each resource, such as
+	// deployments.apps, the Workspace has.
+	// +optional
+	Objects map[string]int64 `json:"objects,omitempty"`
+
+	// EstimatedStorageBytes estimates how many bytes those objects take from
+	// the size of their JSON encoding, not what etcd stores.
+	// +optional
+	EstimatedStorageBytes int64 `json:"estimatedStorageBytes,omitempty"`
+}
+
+// WorkspaceList is a list of Workspace resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type WorkspaceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Workspace `json:"items"`
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkspaceQuota limits the workloads of the workspace it's created in that
+// may be placed onto its Clusters, like a ResourceQuota does the objects of
+// a namespace. A root Deployment or StatefulSet that would take the
+// workspace over any of its WorkspaceQuotas isn't placed anywhere, and
+// stays Pending with a QuotaExceeded condition until it fits.
+//
+// Workloads are only checked when they are first placed: the later growth
+// of those already placed is counted against the quota, and reported by its
+// Exceeded condition, but doesn't move them off their Clusters.
+//
+// +crd
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+type WorkspaceQuota struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec WorkspaceQuotaSpec `json:"spec,omitempty"`
+
+	// +optional
+	Status WorkspaceQuotaStatus `json:"status,omitempty"`
+}
+
+// WorkspaceQuotaSpec holds the desired state of the WorkspaceQuota.
+type WorkspaceQuotaSpec struct {
+	// Hard is the most of each resource the placed workloads of the
+	// workspace may use together. The resources are those of a
+	// ResourceQuota: count/deployments.apps and count/statefulsets.apps
+	// count the root workloads, replicas their replicas, and requests.cpu
+	// and requests.memory the requests of all the replicas' pods.
+	// +optional
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+}
+
+// WorkspaceQuotaStatus communicates the observed state of the
+// WorkspaceQuota.
+type WorkspaceQuotaStatus struct {
+	// ObservedGeneration is the generation of the WorkspaceQuota the quota controller last
+	// reconciled, which the rest of the status reflects.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Used is how much of each resource of Hard the placed workloads of the
+	// workspace use.
+	// +optional
+	Used corev1.ResourceList `json:"used,omitempty"`
+
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
+}
+
+// WorkspaceQuotaList is a list of WorkspaceQuota resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type WorkspaceQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkspaceQuota `json:"items"`
+}
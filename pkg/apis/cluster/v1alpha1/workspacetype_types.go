@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// WorkspaceType is a template Workspaces are created from: the CRDs and
+// objects, such as RBAC roles and default namespaces, the workspace
+// controller puts in the logical cluster of a Workspace of that type as it
+// initializes it, so that its tenants start with a usable API surface.
+//
+// WorkspaceTypes are created in the admin logical cluster. Workspaces are
+// only set up from their type once: later changes to the type, or to what
+// it set up, aren't reconciled.
+//
+// +crd
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+type WorkspaceType struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec WorkspaceTypeSpec `json:"spec,omitempty"`
+}
+
+// WorkspaceTypeSpec holds what the Workspaces of the type are set up with.
+type WorkspaceTypeSpec struct {
+	// CustomResourceDefinitions names the CRDs of the admin logical cluster
+	// copied into the Workspaces, e.g. deployments.apps. They're installed
+	// before the objects are created, so that the objects may be of their
+	// kinds.
+	// +optional
+	CustomResourceDefinitions []string `json:"customResourceDefinitions,omitempty"`
+
+	// Objects are created in the Workspaces, in order, unless they already
+	// exist. Namespaced objects without a namespace are created in the
+	// default namespace.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Objects []runtime.RawExtension `json:"objects,omitempty"`
+}
+
+// WorkspaceTypeList is a list of WorkspaceType resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type WorkspaceTypeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkspaceType `json:"items"`
+}
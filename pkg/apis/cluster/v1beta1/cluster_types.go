@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Cluster describes a member cluster.
+//
+// +crd
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:storageversion
+type Cluster struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the desired state.
+	// +optional
+	Spec ClusterSpec `json:"spec,omitempty"`
+
+	// Status communicates the observed state.
+	// +optional
+	Status ClusterStatus `json:"status,omitempty"`
+}
+
+// ClusterSpec holds the desired state of the Cluster.
+type ClusterSpec struct {
+	// SecretRef refers to a Secret holding the kubeconfig to reach the
+	// Cluster's API server with. Changes to the Secret are picked up, so
+	// its credentials can be rotated.
+	// +optional
+	SecretRef *KubeConfigSecretReference `json:"secretRef,omitempty"`
+
+	// KubeConfig reaches the Cluster's API server, for the Clusters that
+	// don't set SecretRef. Deprecated: it keeps the credentials in the
+	// Cluster.
+	// +optional
+	KubeConfig string `json:"kubeconfig,omitempty"`
+
+	// Weight is this Cluster's share of split workloads' replicas, relative
+	// to the other Clusters. Unset or zero means the default weight of 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Weight int32 `json:"weight,omitempty"`
+
+	// Taints keep workloads that don't tolerate them off the Cluster, like
+	// node taints do for pods: NoSchedule ones stop new workloads from being
+	// placed on it, and NoExecute ones also move the workloads already there.
+	// A cordoned Cluster has the UnschedulableTaintKey NoSchedule taint.
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+
+	// SyncedResources are the resources the Cluster's syncer syncs, as
+	// <resource> or <resource>.<group>, e.g. deployments.apps. Unset means
+	// those the Cluster Controller syncs by default.
+	// +optional
+	SyncedResources []string `json:"syncedResources,omitempty"`
+
+	// SyncerRateLimit bounds the requests the Cluster's syncer makes to
+	// it, for the small clusters that can't take many.
+	// +optional
+	SyncerRateLimit *SyncerRateLimit `json:"syncerRateLimit,omitempty"`
+}
+
+// UnschedulableTaintKey is the key of the NoSchedule taint of cordoned
+// Clusters. A v1alpha1 Cluster with spec.unschedulable set converts to one
+// with this taint, and the splitters treat it as having the taint already.
+const UnschedulableTaintKey = "cluster.example.dev/unschedulable"
+
+// SyncerRateLimit bounds the requests a syncer makes to its Cluster.
+type SyncerRateLimit struct {
+	// QPS is the sustained number of requests per second. Unset or zero
+	// means the client default of 5.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	QPS int32 `json:"qps,omitempty"`
+
+	// Burst is how many requests may be made at once above QPS. Unset or
+	// zero means the client default of 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Burst int32 `json:"burst,omitempty"`
+
+	// Adaptive makes the syncer slow down, down to a tenth of QPS, while
+	// the Cluster answers with 429 Too Many Requests, and speed back up as
+	// its requests succeed again.
+	// +optional
+	Adaptive bool `json:"adaptive,omitempty"`
+}
+
+// KubeConfigSecretReference refers to a key of a Secret holding a kubeconfig.
+type KubeConfigSecretReference struct {
+	// Namespace of the Secret, in the logical cluster of the Cluster.
+	Namespace string `json:"namespace"`
+	// Name of the Secret.
+	Name string `json:"name"`
+	// Key of the kubeconfig in the Secret. Defaults to "kubeconfig".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ClusterStatus communicates the observed state of the Cluster.
+type ClusterStatus struct {
+	// ObservedGeneration is the generation of the Cluster the Cluster Controller last
+	// reconciled, which the rest of the status reflects.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions are the observations of the Cluster's state, such as
+	// whether it's Ready.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// Capacity is what the Cluster's Ready, schedulable nodes can run, as
+	// last observed.
+	// +optional
+	Capacity ClusterCapacity `json:"capacity,omitempty"`
+
+	// SyncedResources are the resources the Cluster's syncer was last set
+	// up to sync: those of its spec, or the defaults, that the cluster
+	// serves. Workloads of other resources aren't placed on the Cluster.
+	// Unset means unknown yet, and doesn't rule any out.
+	// +optional
+	SyncedResources []string `json:"syncedResources,omitempty"`
+
+	// StorageClasses are the StorageClasses the Cluster provisions volumes
+	// with, as last observed. Workloads with volume claims are only placed
+	// on the Clusters with a StorageClass for each of them.
+	// +optional
+	StorageClasses []ClusterStorageClass `json:"storageClasses,omitempty"`
+
+	// NetworkPolicies is whether the Cluster enforces NetworkPolicies, as
+	// its network plugin does, as last observed. NetworkPolicies are only
+	// synced to the Clusters that do.
+	// +optional
+	NetworkPolicies bool `json:"networkPolicies,omitempty"`
+}
+
+// Condition is an observation of a Cluster's state, of the shape of the
+// metav1.Condition of Kubernetes 1.19 and later.
+type Condition struct {
+	// Type of the condition, such as Ready.
+	Type string `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+
+	// ObservedGeneration is the generation of the Cluster the condition was
+	// last set from.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastTransitionTime is the last time the condition changed status.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+
+	// Reason is why the condition last changed status, in CamelCase.
+	Reason string `json:"reason"`
+
+	// Message is a human readable explanation of the condition.
+	Message string `json:"message"`
+}
+
+// ClusterStorageClass is a StorageClass of a Cluster.
+type ClusterStorageClass struct {
+	// Name of the StorageClass.
+	Name string `json:"name"`
+
+	// Provisioner of its volumes.
+	// +optional
+	Provisioner string `json:"provisioner,omitempty"`
+
+	// Default is whether it's the Cluster's default StorageClass, the one
+	// the volume claims that name none get.
+	// +optional
+	Default bool `json:"default,omitempty"`
+
+	// AccessModes are those its volumes can be claimed with, e.g.
+	// ReadWriteMany for those that can be mounted by the replicas of a
+	// workload on several nodes.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+}
+
+// ClusterCapacity is what the nodes of a Cluster can run.
+type ClusterCapacity struct {
+	// Allocatable is the sum of the allocatable resources of the nodes.
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+
+	// Requested is the sum of the resource requests of the pods running or
+	// pending on the nodes.
+	// +optional
+	Requested corev1.ResourceList `json:"requested,omitempty"`
+
+	// Architectures are the CPU architectures of the nodes, as their
+	// kubernetes.io/arch labels have them.
+	// +optional
+	Architectures []string `json:"architectures,omitempty"`
+}
+
+// ClusterList is a list of Cluster resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Cluster `json:"items"`
+}
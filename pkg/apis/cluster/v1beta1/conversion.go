@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FromV1alpha1 returns the v1beta1 Cluster of a v1alpha1 one. Its
+// spec.unschedulable becomes the UnschedulableTaintKey taint; the heartbeat
+// times of its conditions aren't kept.
+func FromV1alpha1(in *v1alpha1.Cluster) *Cluster {
+	out := &Cluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "Cluster"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+	}
+
+	spec := in.Spec.DeepCopy()
+	out.Spec = ClusterSpec{
+		KubeConfig:      spec.KubeConfig,
+		Weight:          spec.Weight,
+		Taints:          spec.Taints,
+		SyncedResources: spec.SyncedResources,
+	}
+	if ref := spec.KubeConfigSecretRef; ref != nil {
+		out.Spec.SecretRef = &KubeConfigSecretReference{Namespace: ref.Namespace, Name: ref.Name, Key: ref.Key}
+	}
+	if spec.Unschedulable && unschedulableTaint(out.Spec.Taints) < 0 {
+		out.Spec.Taints = append(out.Spec.Taints, corev1.Taint{Key: UnschedulableTaintKey, Effect: corev1.TaintEffectNoSchedule})
+	}
+	if l := spec.SyncerRateLimit; l != nil {
+		out.Spec.SyncerRateLimit = &SyncerRateLimit{QPS: l.QPS, Burst: l.Burst, Adaptive: l.Adaptive}
+	}
+
+	status := in.Status.DeepCopy()
+	out.Status = ClusterStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		Capacity:           ClusterCapacity{Allocatable: status.Allocatable, Requested: status.Requested, Architectures: status.Architectures},
+		SyncedResources:    status.SyncedResources,
+		NetworkPolicies:    status.NetworkPolicies,
+	}
+	for _, sc := range status.StorageClasses {
+		out.Status.StorageClasses = append(out.Status.StorageClasses, ClusterStorageClass{
+			Name:        sc.Name,
+			Provisioner: sc.Provisioner,
+			Default:     sc.Default,
+			AccessModes: sc.AccessModes,
+		})
+	}
+	for _, c := range status.Conditions {
+		out.Status.Conditions = append(out.Status.Conditions, Condition{
+			Type:               string(c.Type),
+			Status:             c.Status,
+			ObservedGeneration: c.ObservedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             c.Reason,
+			Message:            c.Message,
+		})
+	}
+	return out
+}
+
+// ToV1alpha1 returns the v1alpha1 Cluster of the v1beta1 one. Its
+// UnschedulableTaintKey taint becomes spec.unschedulable, and the heartbeat
+// times of its conditions are their transition times.
+func (c *Cluster) ToV1alpha1() *v1alpha1.Cluster {
+	out := &v1alpha1.Cluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "Cluster"},
+		ObjectMeta: *c.ObjectMeta.DeepCopy(),
+	}
+
+	spec := c.Spec.DeepCopy()
+	out.Spec = v1alpha1.ClusterSpec{
+		KubeConfig:      spec.KubeConfig,
+		Weight:          spec.Weight,
+		Taints:          spec.Taints,
+		SyncedResources: spec.SyncedResources,
+	}
+	if ref := spec.SecretRef; ref != nil {
+		out.Spec.KubeConfigSecretRef = &v1alpha1.KubeConfigSecretReference{Namespace: ref.Namespace, Name: ref.Name, Key: ref.Key}
+	}
+	if i := unschedulableTaint(out.Spec.Taints); i >= 0 {
+		out.Spec.Unschedulable = true
+		out.Spec.Taints = append(out.Spec.Taints[:i:i], out.Spec.Taints[i+1:]...)
+		if len(out.Spec.Taints) == 0 {
+			out.Spec.Taints = nil
+		}
+	}
+	if l := spec.SyncerRateLimit; l != nil {
+		out.Spec.SyncerRateLimit = &v1alpha1.SyncerRateLimit{QPS: l.QPS, Burst: l.Burst, Adaptive: l.Adaptive}
+	}
+
+	status := c.Status.DeepCopy()
+	out.Status = v1alpha1.ClusterStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		Allocatable:        status.Capacity.Allocatable,
+		Requested:          status.Capacity.Requested,
+		Architectures:      status.Capacity.Architectures,
+		SyncedResources:    status.SyncedResources,
+		NetworkPolicies:    status.NetworkPolicies,
+	}
+	for _, sc := range status.StorageClasses {
+		out.Status.StorageClasses = append(out.Status.StorageClasses, v1alpha1.ClusterStorageClass{
+			Name:        sc.Name,
+			Provisioner: sc.Provisioner,
+			Default:     sc.Default,
+			AccessModes: sc.AccessModes,
+		})
+	}
+	for _, cond := range status.Conditions {
+		out.Status.Conditions = append(out.Status.Conditions, v1alpha1.Condition{
+			Type:               v1alpha1.ConditionType(cond.Type),
+			Status:             cond.Status,
+			ObservedGeneration: cond.ObservedGeneration,
+			LastHeartbeatTime:  cond.LastTransitionTime,
+			LastTransitionTime: cond.LastTransitionTime,
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+		})
+	}
+	return out
+}
+
+// unschedulableTaint returns the index of the UnschedulableTaintKey
+// NoSchedule taint, or -1 if there's none.
+func unschedulableTaint(taints []corev1.Taint) int {
+	for i, t := range taints {
+		if t.Key == UnschedulableTaintKey && t.Effect == corev1.TaintEffectNoSchedule {
+			return i
+		}
+	}
+	return -1
+}
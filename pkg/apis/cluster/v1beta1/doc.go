@@ -0,0 +1,8 @@
+// Package v1beta1 is the v1beta1 version of the cluster.example.dev
+// Clusters, which their CRD stores them as. The v1alpha1 Clusters are still
+// served, converted by the conversion webhook of kcp, and the controllers
+// still work with them.
+//
+// +k8s:deepcopy-gen=package,register
+// +groupName=cluster.example.dev
+package v1beta1
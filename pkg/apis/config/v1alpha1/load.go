@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// Load reads the configuration file at path, in YAML or JSON, and validates
+// it. Unknown fields are errors, for typos not to be silently ignored.
+func Load(path string) (*KCPConfiguration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &KCPConfiguration{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.APIVersion != SchemeGroupVersion.String() || c.Kind != Kind {
+		return nil, fmt.Errorf("%s: unsupported apiVersion %q and kind %q, must be %s %s", path, c.APIVersion, c.Kind, SchemeGroupVersion, Kind)
+	}
+	if errs := Validate(c); len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", path, errs.ToAggregate())
+	}
+	return c, nil
+}
+
+// Validate returns what's wrong with the configuration, by the paths of its
+// fields. The values checked by the flags they set, such as the drift mode of
+// the syncers, are left to them.
+func Validate(c *KCPConfiguration) field.ErrorList {
+	var errs field.ErrorList
+
+	serving := field.NewPath("serving")
+	if a := c.Serving.BindAddress; a != "" && net.ParseIP(a) == nil {
+		errs = append(errs, field.Invalid(serving.Child("bindAddress"), a, "must be an IP address"))
+	}
+	if (c.Serving.TLSCertFile == "") != (c.Serving.TLSPrivateKeyFile == "") {
+		errs = append(errs, field.Required(serving.Child("tlsPrivateKeyFile"), "tlsCertFile and tlsPrivateKeyFile must be given together"))
+	}
+	if p := c.Serving.SecurePort; p != nil {
+		for _, msg := range validation.IsValidPortNum(int(*p)) {
+			errs = append(errs, field.Invalid(serving.Child("securePort"), *p, msg))
+		}
+	}
+
+	storage := field.NewPath("storage")
+	if len(c.Storage.EtcdServers) > 0 {
+		// Only the embedded etcd is restored, snapshotted and defragmented.
+		if c.Storage.RestoreFrom != "" {
+			errs = append(errs, field.Forbidden(storage.Child("restoreFrom"), "only the embedded etcd can be restored, not that of etcdServers"))
+		}
+		if nonZero(c.Storage.SnapshotInterval) {
+			errs = append(errs, field.Forbidden(storage.Child("snapshotInterval"), "only the embedded etcd can be snapshotted, not that of etcdServers"))
+		}
+		if nonZero(c.Storage.DefragInterval) {
+			errs = append(errs, field.Forbidden(storage.Child("defragInterval"), "only the embedded etcd can be defragmented, not that of etcdServers"))
+		}
+	}
+	errs = append(errs, validateDuration(storage.Child("snapshotInterval"), c.Storage.SnapshotInterval)...)
+	errs = append(errs, validateDuration(storage.Child("defragInterval"), c.Storage.DefragInterval)...)
+	if n := c.Storage.SnapshotsKept; n != nil && *n < 0 {
+		errs = append(errs, field.Invalid(storage.Child("snapshotsKept"), *n, "must be zero, to keep every snapshot, or more"))
+	}
+	if n := c.Storage.DefaultWatchCacheSize; n != nil && *n < 0 {
+		errs = append(errs, field.Invalid(storage.Child("defaultWatchCacheSize"), *n, "must not be negative"))
+	}
+	resources := make([]string, 0, len(c.Storage.WatchCacheSizes))
+	for resource := range c.Storage.WatchCacheSizes {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		if n := c.Storage.WatchCacheSizes[resource]; n < 0 {
+			errs = append(errs, field.Invalid(storage.Child("watchCacheSizes").Key(resource), n, "must not be negative"))
+		}
+	}
+
+	controllers := field.NewPath("controllers")
+	errs = append(errs, validateDuration(controllers.Child("resyncPeriod"), c.Controllers.ResyncPeriod)...)
+	resources = make([]string, 0, len(c.Controllers.ResyncPeriods))
+	for resource := range c.Controllers.ResyncPeriods {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		d := c.Controllers.ResyncPeriods[resource]
+		errs = append(errs, validateDuration(controllers.Child("resyncPeriods").Key(resource), &d)...)
+	}
+	if n := c.Controllers.ListPageSize; n != nil && *n < 0 {
+		errs = append(errs, field.Invalid(controllers.Child("listPageSize"), *n, "must be zero, to list everything at once, or more"))
+	}
+
+	errs = append(errs, validateDuration(field.NewPath("syncer", "tokenTTL"), c.Syncer.TokenTTL)...)
+	errs = append(errs, validateDuration(field.NewPath("scheduling", "evictionToleration"), c.Scheduling.EvictionToleration)...)
+	return errs
+}
+
+func nonZero(d *metav1.Duration) bool {
+	return d != nil && d.Duration != 0
+}
+
+func validateDuration(path *field.Path, d *metav1.Duration) field.ErrorList {
+	if d != nil && d.Duration < 0 {
+		return field.ErrorList{field.Invalid(path, d.Duration.String(), "must not be negative")}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package v1alpha1
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load(filepath.Join("..", "..", "..", "..", "contrib", "examples", "kcp-config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *c.Serving.SecurePort != 6443 || c.Controllers.ResyncPeriod.Duration != 10*time.Hour || len(c.Syncer.Resources) != 2 {
+		t.Errorf("got %+v", c)
+	}
+
+	dir := t.TempDir()
+	for name, tc := range map[string]struct {
+		config, wantErr string
+	}{
+		"unknown field": {
+			config:  "apiVersion: kcp.config.k8s.io/v1alpha1\nkind: KCPConfiguration\nserving:\n  port: 6443\n",
+			wantErr: `unknown field "port"`,
+		},
+		"other kind": {
+			config:  "apiVersion: kcp.config.k8s.io/v1alpha1\nkind: Config\n",
+			wantErr: "unsupported apiVersion",
+		},
+		"invalid values": {
+			config:  "apiVersion: kcp.config.k8s.io/v1alpha1\nkind: KCPConfiguration\nserving:\n  securePort: 0\n  bindAddress: localhost\nstorage:\n  snapshotsKept: -1\n",
+			wantErr: "serving.bindAddress",
+		},
+		"external etcd snapshots": {
+			config:  "apiVersion: kcp.config.k8s.io/v1alpha1\nkind: KCPConfiguration\nstorage:\n  etcdServers: [http://etcd:2379]\n  snapshotInterval: 1h\n",
+			wantErr: "storage.snapshotInterval",
+		},
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".yaml")
+		if err := ioutil.WriteFile(path, []byte(tc.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	port, kept := int32(70000), int32(-1)
+	c := &KCPConfiguration{Serving: Serving{SecurePort: &port}, Storage: Storage{SnapshotsKept: &kept}}
+	errs := Validate(c)
+	if len(errs) != 2 || errs[0].Field != "serving.securePort" || errs[1].Field != "storage.snapshotsKept" {
+		t.Errorf("got %v", errs)
+	}
+
+	c = &KCPConfiguration{Serving: Serving{TLSCertFile: "kcp.crt"}}
+	if errs := Validate(c); len(errs) != 1 || errs[0].Field != "serving.tlsPrivateKeyFile" {
+		t.Errorf("got %v", errs)
+	}
+
+	c = &KCPConfiguration{Storage: Storage{WatchCacheSizes: map[string]int32{"deployments.apps": -1, "pods": 0}}}
+	if errs := Validate(c); len(errs) != 1 || errs[0].Field != "storage.watchCacheSizes[deployments.apps]" {
+		t.Errorf("got %v", errs)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 holds the configuration file of the kcp server, given to
+// kcp start as --config. Each of its fields sets a flag of kcp start, unless
+// that flag is also given, and the fields it leaves empty keep the defaults
+// of their flags.
+package v1alpha1
+
+import (
+	"github.com/kcp-dev/kcp/pkg/apis/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersion is the version of the configuration files this package
+// reads.
+var SchemeGroupVersion = schema.GroupVersion{Group: config.GroupName, Version: "v1alpha1"}
+
+// Kind is the kind of the configuration files of the kcp server.
+const Kind = "KCPConfiguration"
+
+// KCPConfiguration configures the kcp server.
+type KCPConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Serving configures where the server listens.
+	// +optional
+	Serving Serving `json:"serving,omitempty"`
+
+	// Storage configures the etcd the server stores its objects in.
+	// +optional
+	Storage Storage `json:"storage,omitempty"`
+
+	// Controllers configures the controllers the server runs in process.
+	// +optional
+	Controllers Controllers `json:"controllers,omitempty"`
+
+	// Syncer configures the syncers of the registered physical clusters.
+	// +optional
+	Syncer Syncer `json:"syncer,omitempty"`
+
+	// Scheduling configures how workloads are moved between the registered
+	// physical clusters.
+	// +optional
+	Scheduling Scheduling `json:"scheduling,omitempty"`
+}
+
+// Serving configures where the server listens and what with, --bind-address,
+// --secure-port, --tls-cert-file, --tls-private-key-file and --workspaces_url.
+type Serving struct {
+	// BindAddress is the IP address to listen on.
+	// +optional
+	BindAddress string `json:"bindAddress,omitempty"`
+
+	// SecurePort is the port to serve HTTPS on.
+	// +optional
+	SecurePort *int32 `json:"securePort,omitempty"`
+
+	// TLSCertFile and TLSPrivateKeyFile are the files of the certificate to
+	// serve HTTPS with, reloaded when they change.
+	// +optional
+	TLSCertFile string `json:"tlsCertFile,omitempty"`
+	// +optional
+	TLSPrivateKeyFile string `json:"tlsPrivateKeyFile,omitempty"`
+
+	// WorkspacesURL is the URL the Workspaces are reached under.
+	// +optional
+	WorkspacesURL string `json:"workspacesURL,omitempty"`
+}
+
+// Storage configures the etcd of the server: an embedded one, kept under
+// RootDirectory, or the external one of EtcdServers.
+type Storage struct {
+	// RootDirectory is the directory the data of the control plane is kept
+	// in, --root_directory.
+	// +optional
+	RootDirectory string `json:"rootDirectory,omitempty"`
+
+	// EtcdServers are those of an external etcd, --etcd-servers, with the
+	// files of EtcdCertFile, EtcdKeyFile and EtcdCAFile to reach them with.
+	// +optional
+	EtcdServers []string `json:"etcdServers,omitempty"`
+	// +optional
+	EtcdCertFile string `json:"etcdCertFile,omitempty"`
+	// +optional
+	EtcdKeyFile string `json:"etcdKeyFile,omitempty"`
+	// +optional
+	EtcdCAFile string `json:"etcdCAFile,omitempty"`
+
+	// RestoreFrom is the snapshot to restore the embedded etcd from,
+	// --etcd_restore_from.
+	// +optional
+	RestoreFrom string `json:"restoreFrom,omitempty"`
+
+	// SnapshotInterval, SnapshotLocation and SnapshotsKept configure the
+	// snapshots of the embedded etcd, --etcd_snapshot_interval,
+	// --etcd_snapshot_location and --etcd_snapshots_kept.
+	// +optional
+	SnapshotInterval *metav1.Duration `json:"snapshotInterval,omitempty"`
+	// +optional
+	SnapshotLocation string `json:"snapshotLocation,omitempty"`
+	// +optional
+	SnapshotsKept *int32 `json:"snapshotsKept,omitempty"`
+
+	// DefragInterval is how often to defragment the embedded etcd,
+	// --etcd_defrag_interval.
+	// +optional
+	DefragInterval *metav1.Duration `json:"defragInterval,omitempty"`
+
+	// EncryptionProviderConfig is the path to the EncryptionConfiguration
+	// encrypting resources such as Secrets in etcd,
+	// --encryption-provider-config.
+	// +optional
+	EncryptionProviderConfig string `json:"encryptionProviderConfig,omitempty"`
+
+	// DefaultWatchCacheSize is how many of the latest changes of each
+	// resource the watch cache keeps, --default-watch-cache-size, and
+	// WatchCacheSizes overrides it for the resources of the given
+	// resource[.group] names, --watch-cache-sizes.
+	// +optional
+	DefaultWatchCacheSize *int32 `json:"defaultWatchCacheSize,omitempty"`
+	// +optional
+	WatchCacheSizes map[string]int32 `json:"watchCacheSizes,omitempty"`
+}
+
+// Controllers configures the controllers run in process.
+type Controllers struct {
+	// InstallClusterController runs the Cluster Controller and the
+	// controllers alongside it, --install_cluster_controller.
+	// +optional
+	InstallClusterController *bool `json:"installClusterController,omitempty"`
+
+	// Disabled are the controllers run alongside the Cluster Controller not
+	// to run, --disabled_controllers.
+	// +optional
+	Disabled []string `json:"disabled,omitempty"`
+
+	// ResyncPeriod is how often the informers of the controllers resync,
+	// never if zero, --resync_period.
+	// +optional
+	ResyncPeriod *metav1.Duration `json:"resyncPeriod,omitempty"`
+
+	// ResyncPeriods overrides ResyncPeriod for the informers of the given
+	// resources, by their plural names, --resync_periods.
+	// +optional
+	ResyncPeriods map[string]metav1.Duration `json:"resyncPeriods,omitempty"`
+
+	// ImportAPIGroups are the API groups to import the resources of from
+	// the registered physical clusters, --import_api_groups.
+	// +optional
+	ImportAPIGroups []string `json:"importAPIGroups,omitempty"`
+
+	// ListPageSize is how many objects the informers of the controllers
+	// list at a time, --list_page_size.
+	// +optional
+	ListPageSize *int32 `json:"listPageSize,omitempty"`
+}
+
+// Syncer configures the syncers of the registered physical clusters.
+type Syncer struct {
+	// Image is the image of the syncers installed with the pull model,
+	// --syncer_image.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// PullModel installs the syncers on the physical clusters, rather than
+	// running them in process, --pull_model.
+	// +optional
+	PullModel *bool `json:"pullModel,omitempty"`
+
+	// Resources are the resources the syncers sync, --resources_to_sync.
+	// +optional
+	Resources []string `json:"resources,omitempty"`
+
+	// DriftMode is what the syncers do about synced objects changed on their
+	// physical cluster, --syncer_drift_mode: revert, report or adopt.
+	// +optional
+	DriftMode string `json:"driftMode,omitempty"`
+
+	// TokenTTL is how long the ServiceAccount tokens of the syncers
+	// installed with the pull model are valid for, --syncer_token_ttl.
+	// +optional
+	TokenTTL *metav1.Duration `json:"tokenTTL,omitempty"`
+}
+
+// Scheduling configures how workloads are moved between the registered
+// physical clusters. Where they're placed in the first place is up to the
+// splitters, which are configured apart.
+type Scheduling struct {
+	// EvictionToleration is how long a physical cluster may stay NotReady
+	// before its workloads are moved to other clusters,
+	// --eviction_toleration.
+	// +optional
+	EvictionToleration *metav1.Duration `json:"evictionToleration,omitempty"`
+}
@@ -0,0 +1,22 @@
+package authorization
+
+import (
+	"net/http"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// WithAuthentication returns a handler authenticating the requests with
+// authn, once for all the handlers in front of the server's own
+// authentication, and passing them on to handler with their user in their
+// context, as request.UserFrom gets it. The requests that aren't
+// authenticated have none.
+func WithAuthentication(handler http.Handler, authn authenticator.Request) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if resp, ok, err := authn.AuthenticateRequest(req); err == nil && ok {
+			req = req.WithContext(request.WithUser(req.Context(), resp.User))
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
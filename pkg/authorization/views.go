@@ -0,0 +1,230 @@
+// Package authorization restricts what the users of a workspace see of kcp.
+//
+// The splitters place the workloads of a workspace onto its Clusters as
+// leafs, objects of the same kind labeled as owned by their root, which only
+// the syncers and the other controllers need to see and write. WithLeafViews
+// hides them from everyone else, so that users only see and change the
+// logical objects they created themselves.
+package authorization
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+var requestInfoFactory = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// InGroups returns a func reporting whether a user is in any of the given
+// groups, e.g. system:masters, to let them view leafs with.
+func InGroups(groups []string) func(user.Info) bool {
+	allowed := sets.NewString(groups...)
+	return func(u user.Info) bool {
+		return allowed.HasAny(u.GetGroups()...)
+	}
+}
+
+// WithLeafViews returns a handler hiding the leafs served by handler from
+// the users, as WithAuthentication sets them, that canViewLeafs doesn't let
+// view them, and from unauthenticated users: lists and watches only return the other
+// objects, and gets of leafs, and of their subresources, fail with NotFound,
+// as if they didn't exist. Gets are answered in JSON, for the leafs to be
+// told apart; what still isn't JSON fails with NotFound too.
+//
+// Leafs are hidden from writes the same way: updates, patches and deletions
+// of leafs, and of their subresources, fail with NotFound, and deletions of
+// collections leave them be. A write whose object can't be read is left to
+// handler, as is the authorization of the other writes.
+func WithLeafViews(handler http.Handler, canViewLeafs func(user.Info) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if u, ok := request.UserFrom(req.Context()); ok && canViewLeafs(u) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		path := req.URL.Path
+		if prefix := logicalcluster.Path(""); strings.HasPrefix(path, prefix) {
+			rest := strings.TrimPrefix(path, prefix)
+			i := strings.Index(rest, "/")
+			if i < 0 {
+				handler.ServeHTTP(w, req)
+				return
+			}
+			path = rest[i:]
+		}
+		infoReq := req.Clone(req.Context())
+		infoReq.URL.Path = path
+		info, err := requestInfoFactory.NewRequestInfo(infoReq)
+		if err != nil || !info.IsResourceRequest {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		gr := schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
+
+		switch info.Verb {
+		case "list", "watch", "deletecollection":
+			// Let the server filter the leafs out, so that watches only
+			// ever see the other objects.
+			query := req.URL.Query()
+			selector := "!" + base.LeafLabel
+			if s := query.Get("labelSelector"); s != "" {
+				selector = s + "," + selector
+			}
+			query.Set("labelSelector", selector)
+			req = req.Clone(req.Context())
+			req.URL.RawQuery = query.Encode()
+			handler.ServeHTTP(w, req)
+		case "get":
+			if info.Subresource != "" {
+				// The status, scale, log, etc. of an object don't carry
+				// its labels, so it's the object itself that's checked,
+				// before the subresource is served as is, streams
+				// included. It's hidden as well if it can't be read.
+				rec := getObject(handler, req, info)
+				if rec == nil || rec.status != http.StatusOK || isLeaf(rec.header, rec.body.Bytes()) {
+					writeError(w, errors.NewNotFound(gr, info.Name))
+					return
+				}
+				handler.ServeHTTP(w, req)
+				return
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Accept", jsonOnly(req.Header.Get("Accept")))
+			rec := &recorder{header: http.Header{}, status: http.StatusOK}
+			handler.ServeHTTP(rec, req)
+			if rec.status == http.StatusOK && isLeaf(rec.header, rec.body.Bytes()) {
+				writeError(w, errors.NewNotFound(gr, info.Name))
+				return
+			}
+			for k, v := range rec.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.status)
+			_, _ = w.Write(rec.body.Bytes())
+		case "update", "patch", "delete":
+			// The object written is read first, as it's stored: a leaf
+			// the write would label otherwise is still a leaf.
+			rec := getObject(handler, req, info)
+			if rec == nil {
+				writeError(w, errors.NewNotFound(gr, info.Name))
+				return
+			}
+			if rec.status == http.StatusOK && isLeaf(rec.header, rec.body.Bytes()) {
+				writeError(w, errors.NewNotFound(gr, info.Name))
+				return
+			}
+			handler.ServeHTTP(w, req)
+		default:
+			handler.ServeHTTP(w, req)
+		}
+	})
+}
+
+// getObject gets the object of a request, rather than its subresource, as
+// JSON through handler, for it to be told apart from leafs. It returns nil
+// if the request's path doesn't name the object.
+func getObject(handler http.Handler, req *http.Request, info *request.RequestInfo) *recorder {
+	path := req.URL.Path
+	if info.Subresource != "" {
+		object := "/" + info.Resource + "/" + info.Name
+		i := strings.Index(path, object+"/"+info.Subresource)
+		if i < 0 {
+			return nil
+		}
+		path = path[:i+len(object)]
+	}
+	get := req.Clone(req.Context())
+	get.Method = http.MethodGet
+	get.Body = http.NoBody
+	get.ContentLength = 0
+	get.URL.Path = path
+	get.URL.RawQuery = ""
+	get.Header.Set("Accept", "application/json")
+	get.Header.Del("Content-Type")
+	get.Header.Del("Upgrade")
+	get.Header.Del("Connection")
+	rec := &recorder{header: http.Header{}, status: http.StatusOK}
+	handler.ServeHTTP(rec, get)
+	return rec
+}
+
+// jsonOnly returns the JSON media types of an Accept header, Tables
+// included, or application/json if it has none, e.g. for protobuf or YAML.
+func jsonOnly(accept string) string {
+	var types []string
+	for _, t := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(t, ";", 2)[0])
+		if mediaType == "application/json" {
+			types = append(types, strings.TrimSpace(t))
+		}
+	}
+	if len(types) == 0 {
+		return "application/json"
+	}
+	return strings.Join(types, ",")
+}
+
+// isLeaf reports whether a response is that of a leaf, or a Table of
+// one. A response that isn't JSON, though asked to be, can't be told apart
+// from that of a leaf, so it's reported as one.
+func isLeaf(header http.Header, body []byte) bool {
+	if !strings.HasPrefix(header.Get("Content-Type"), "application/json") {
+		return true
+	}
+	var obj struct {
+		Kind     string            `json:"kind"`
+		Metadata metav1.ObjectMeta `json:"metadata"`
+		Rows     []struct {
+			Object struct {
+				Metadata metav1.ObjectMeta `json:"metadata"`
+			} `json:"object"`
+		} `json:"rows"`
+	}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return true
+	}
+	if obj.Kind == "Table" {
+		for _, row := range obj.Rows {
+			if _, found := row.Object.Metadata.Labels[base.LeafLabel]; found {
+				return true
+			}
+		}
+		return false
+	}
+	_, found := obj.Metadata.Labels[base.LeafLabel]
+	return found
+}
+
+// recorder buffers a response to be checked before it's written.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header         { return r.header }
+func (r *recorder) WriteHeader(status int)      { r.status = status }
+func (r *recorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+
+// writeError fails the request with the Status of err.
+func writeError(w http.ResponseWriter, err errors.APIStatus) {
+	status := err.Status()
+	status.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	body, _ := json.Marshal(status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,299 @@
+package authorization
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestWithLeafViews(t *testing.T) {
+	var selector string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		selector = req.URL.Query().Get("labelSelector")
+		if strings.HasSuffix(req.URL.Path, "/scale") {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"kind":"Scale","apiVersion":"autoscaling/v1","metadata":{"name":"web"}}`)
+			return
+		}
+		labels := `{}`
+		if strings.HasSuffix(req.URL.Path, "--us-east") {
+			labels = `{"owned-by":"web"}`
+		}
+		switch accept := req.Header.Get("Accept"); {
+		case strings.Contains(accept, "protobuf"):
+			w.Header().Set("Content-Type", "application/vnd.kubernetes.protobuf")
+			fmt.Fprint(w, "k8s\x00\n\x0e\n\x07apps/v1\x12\nDeployment")
+		case strings.Contains(accept, "yaml"):
+			w.Header().Set("Content-Type", "application/yaml")
+			fmt.Fprintf(w, "kind: Deployment\napiVersion: apps/v1\nmetadata:\n  name: web\n  labels: %s\n", labels)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"kind":"Deployment","apiVersion":"apps/v1","metadata":{"name":"web","labels":%s}}`, labels)
+		}
+	})
+	authn := authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+		if req.Header.Get("Authorization") != "Bearer admin" {
+			return nil, false, nil
+		}
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "admin", Groups: []string{user.SystemPrivilegedGroup}}}, true, nil
+	})
+	handler := WithAuthentication(WithLeafViews(backend, InGroups([]string{user.SystemPrivilegedGroup})), authn)
+
+	for _, tc := range []struct {
+		name         string
+		path         string
+		accept       string
+		admin        bool
+		wantStatus   int
+		wantSelector string
+	}{{
+		name:         "list",
+		path:         "/apis/apps/v1/namespaces/default/deployments",
+		wantStatus:   http.StatusOK,
+		wantSelector: "!owned-by",
+	}, {
+		name:         "watch with selector",
+		path:         "/clusters/tenant/apis/apps/v1/deployments?watch=true&labelSelector=app%3Dweb",
+		wantStatus:   http.StatusOK,
+		wantSelector: "app=web,!owned-by",
+	}, {
+		name:       "get root",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "get leaf",
+		path:       "/clusters/tenant/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "get leaf as protobuf",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		accept:     "application/vnd.kubernetes.protobuf",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "get leaf as YAML",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		accept:     "application/yaml",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "get root as protobuf",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web",
+		accept:     "application/vnd.kubernetes.protobuf,application/json",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "get scale of root",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web/scale",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "get scale of leaf",
+		path:       "/clusters/tenant/apis/apps/v1/namespaces/default/deployments/web--us-east/scale",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "get status of leaf",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east/status",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "admin gets scale of leaf",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east/scale",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "admin gets leaf as protobuf",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		accept:     "application/vnd.kubernetes.protobuf",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "admin gets leaf",
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "admin lists leafs",
+		path:       "/apis/apps/v1/namespaces/default/deployments",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			selector = ""
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			if tc.admin {
+				req.Header.Set("Authorization", "Bearer admin")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if selector != tc.wantSelector {
+				t.Errorf("label selector = %q, want %q", selector, tc.wantSelector)
+			}
+		})
+	}
+}
+
+func TestWithLeafViewsUndecodable(t *testing.T) {
+	// A backend answering in YAML whatever it's asked for: its objects can't
+	// be told apart from leafs, so none are shown.
+	backend := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		fmt.Fprint(w, "kind: Deployment\napiVersion: apps/v1\nmetadata:\n  name: web\n")
+	})
+	handler := WithLeafViews(backend, InGroups([]string{user.SystemPrivilegedGroup}))
+
+	for _, path := range []string{
+		"/apis/apps/v1/namespaces/default/deployments/web",
+		"/apis/apps/v1/namespaces/default/deployments/web/scale",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestWithLeafViewsWrites(t *testing.T) {
+	var written, selector string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			written, selector = req.Method, req.URL.Query().Get("labelSelector")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Success"}`)
+			return
+		}
+		if strings.HasSuffix(req.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		labels := `{}`
+		if strings.HasSuffix(req.URL.Path, "--us-east") {
+			labels = `{"owned-by":"web"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"kind":"Deployment","apiVersion":"apps/v1","metadata":{"name":"web","labels":%s}}`, labels)
+	})
+	authn := authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+		if req.Header.Get("Authorization") != "Bearer admin" {
+			return nil, false, nil
+		}
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "admin", Groups: []string{user.SystemPrivilegedGroup}}}, true, nil
+	})
+	handler := WithAuthentication(WithLeafViews(backend, InGroups([]string{user.SystemPrivilegedGroup})), authn)
+
+	for _, tc := range []struct {
+		name         string
+		method       string
+		path         string
+		admin        bool
+		wantStatus   int
+		wantSelector string
+	}{{
+		name:       "update root",
+		method:     http.MethodPut,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "update leaf",
+		method:     http.MethodPut,
+		path:       "/clusters/tenant/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "update status of leaf",
+		method:     http.MethodPut,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east/status",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "update missing object",
+		method:     http.MethodPut,
+		path:       "/apis/apps/v1/namespaces/default/deployments/missing",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "patch root",
+		method:     http.MethodPatch,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "patch leaf",
+		method:     http.MethodPatch,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "patch scale of leaf",
+		method:     http.MethodPatch,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east/scale",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "delete root",
+		method:     http.MethodDelete,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "delete leaf",
+		method:     http.MethodDelete,
+		path:       "/clusters/tenant/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:         "delete collection",
+		method:       http.MethodDelete,
+		path:         "/apis/apps/v1/namespaces/default/deployments?labelSelector=app%3Dweb",
+		wantStatus:   http.StatusOK,
+		wantSelector: "app=web,!owned-by",
+	}, {
+		name:       "create",
+		method:     http.MethodPost,
+		path:       "/apis/apps/v1/namespaces/default/deployments",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "admin updates leaf",
+		method:     http.MethodPut,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "admin patches leaf",
+		method:     http.MethodPatch,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "admin deletes leaf",
+		method:     http.MethodDelete,
+		path:       "/apis/apps/v1/namespaces/default/deployments/web--us-east",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "admin deletes leafs",
+		method:     http.MethodDelete,
+		path:       "/apis/apps/v1/namespaces/default/deployments",
+		admin:      true,
+		wantStatus: http.StatusOK,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			written, selector = "", ""
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"kind":"Deployment","apiVersion":"apps/v1","metadata":{"name":"web"}}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.admin {
+				req.Header.Set("Authorization", "Bearer admin")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if wantWritten := tc.wantStatus == http.StatusOK; (written == tc.method) != wantWritten {
+				t.Errorf("written with %q, want written: %v", written, wantWritten)
+			}
+			if selector != tc.wantSelector {
+				t.Errorf("label selector = %q, want %q", selector, tc.wantSelector)
+			}
+		})
+	}
+}
@@ -27,6 +27,17 @@ import (
 type ClusterV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	ClustersGetter
+	ClusterDisruptionBudgetsGetter
+	NegotiatedAPIResourcesGetter
+	PlacementPoliciesGetter
+	RegistryCredentialPoliciesGetter
+	SecretDistributionsGetter
+	ServiceExportsGetter
+	ServiceImportsGetter
+	WorkloadOverridesGetter
+	WorkspaceQuotasGetter
+	WorkspaceTypesGetter
+	WorkspacesGetter
 }
 
 // ClusterV1alpha1Client is used to interact with features provided by the cluster.example.dev group.
@@ -38,6 +49,50 @@ func (c *ClusterV1alpha1Client) Clusters() ClusterInterface {
 	return newClusters(c)
 }
 
+func (c *ClusterV1alpha1Client) ClusterDisruptionBudgets(namespace string) ClusterDisruptionBudgetInterface {
+	return newClusterDisruptionBudgets(c, namespace)
+}
+
+func (c *ClusterV1alpha1Client) NegotiatedAPIResources() NegotiatedAPIResourceInterface {
+	return newNegotiatedAPIResources(c)
+}
+
+func (c *ClusterV1alpha1Client) PlacementPolicies(namespace string) PlacementPolicyInterface {
+	return newPlacementPolicies(c, namespace)
+}
+
+func (c *ClusterV1alpha1Client) RegistryCredentialPolicies() RegistryCredentialPolicyInterface {
+	return newRegistryCredentialPolicies(c)
+}
+
+func (c *ClusterV1alpha1Client) SecretDistributions(namespace string) SecretDistributionInterface {
+	return newSecretDistributions(c, namespace)
+}
+
+func (c *ClusterV1alpha1Client) ServiceExports(namespace string) ServiceExportInterface {
+	return newServiceExports(c, namespace)
+}
+
+func (c *ClusterV1alpha1Client) ServiceImports(namespace string) ServiceImportInterface {
+	return newServiceImports(c, namespace)
+}
+
+func (c *ClusterV1alpha1Client) WorkloadOverrides(namespace string) WorkloadOverrideInterface {
+	return newWorkloadOverrides(c, namespace)
+}
+
+func (c *ClusterV1alpha1Client) WorkspaceQuotas() WorkspaceQuotaInterface {
+	return newWorkspaceQuotas(c)
+}
+
+func (c *ClusterV1alpha1Client) WorkspaceTypes() WorkspaceTypeInterface {
+	return newWorkspaceTypes(c)
+}
+
+func (c *ClusterV1alpha1Client) Workspaces() WorkspaceInterface {
+	return newWorkspaces(c)
+}
+
 // NewForConfig creates a new ClusterV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*ClusterV1alpha1Client, error) {
 	config := *c
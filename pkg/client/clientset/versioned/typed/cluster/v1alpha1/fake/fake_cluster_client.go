@@ -32,6 +32,50 @@ func (c *FakeClusterV1alpha1) Clusters() v1alpha1.ClusterInterface {
 	return &FakeClusters{c}
 }
 
+func (c *FakeClusterV1alpha1) ClusterDisruptionBudgets(namespace string) v1alpha1.ClusterDisruptionBudgetInterface {
+	return &FakeClusterDisruptionBudgets{c, namespace}
+}
+
+func (c *FakeClusterV1alpha1) NegotiatedAPIResources() v1alpha1.NegotiatedAPIResourceInterface {
+	return &FakeNegotiatedAPIResources{c}
+}
+
+func (c *FakeClusterV1alpha1) PlacementPolicies(namespace string) v1alpha1.PlacementPolicyInterface {
+	return &FakePlacementPolicies{c, namespace}
+}
+
+func (c *FakeClusterV1alpha1) RegistryCredentialPolicies() v1alpha1.RegistryCredentialPolicyInterface {
+	return &FakeRegistryCredentialPolicies{c}
+}
+
+func (c *FakeClusterV1alpha1) SecretDistributions(namespace string) v1alpha1.SecretDistributionInterface {
+	return &FakeSecretDistributions{c, namespace}
+}
+
+func (c *FakeClusterV1alpha1) ServiceExports(namespace string) v1alpha1.ServiceExportInterface {
+	return &FakeServiceExports{c, namespace}
+}
+
+func (c *FakeClusterV1alpha1) ServiceImports(namespace string) v1alpha1.ServiceImportInterface {
+	return &FakeServiceImports{c, namespace}
+}
+
+func (c *FakeClusterV1alpha1) WorkloadOverrides(namespace string) v1alpha1.WorkloadOverrideInterface {
+	return &FakeWorkloadOverrides{c, namespace}
+}
+
+func (c *FakeClusterV1alpha1) WorkspaceQuotas() v1alpha1.WorkspaceQuotaInterface {
+	return &FakeWorkspaceQuotas{c}
+}
+
+func (c *FakeClusterV1alpha1) WorkspaceTypes() v1alpha1.WorkspaceTypeInterface {
+	return &FakeWorkspaceTypes{c}
+}
+
+func (c *FakeClusterV1alpha1) Workspaces() v1alpha1.WorkspaceInterface {
+	return &FakeWorkspaces{c}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeClusterV1alpha1) RESTClient() rest.Interface {
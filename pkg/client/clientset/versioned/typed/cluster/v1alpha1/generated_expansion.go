@@ -19,3 +19,25 @@ limitations under the License.
 package v1alpha1
 
 type ClusterExpansion interface{}
+
+type ClusterDisruptionBudgetExpansion interface{}
+
+type NegotiatedAPIResourceExpansion interface{}
+
+type PlacementPolicyExpansion interface{}
+
+type RegistryCredentialPolicyExpansion interface{}
+
+type SecretDistributionExpansion interface{}
+
+type ServiceExportExpansion interface{}
+
+type ServiceImportExpansion interface{}
+
+type WorkloadOverrideExpansion interface{}
+
+type WorkspaceExpansion interface{}
+
+type WorkspaceQuotaExpansion interface{}
+
+type WorkspaceTypeExpansion interface{}
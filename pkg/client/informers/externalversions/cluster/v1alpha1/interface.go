@@ -26,6 +26,28 @@ import (
 type Interface interface {
 	// Clusters returns a ClusterInformer.
 	Clusters() ClusterInformer
+	// ClusterDisruptionBudgets returns a ClusterDisruptionBudgetInformer.
+	ClusterDisruptionBudgets() ClusterDisruptionBudgetInformer
+	// NegotiatedAPIResources returns a NegotiatedAPIResourceInformer.
+	NegotiatedAPIResources() NegotiatedAPIResourceInformer
+	// PlacementPolicies returns a PlacementPolicyInformer.
+	PlacementPolicies() PlacementPolicyInformer
+	// RegistryCredentialPolicies returns a RegistryCredentialPolicyInformer.
+	RegistryCredentialPolicies() RegistryCredentialPolicyInformer
+	// SecretDistributions returns a SecretDistributionInformer.
+	SecretDistributions() SecretDistributionInformer
+	// ServiceExports returns a ServiceExportInformer.
+	ServiceExports() ServiceExportInformer
+	// ServiceImports returns a ServiceImportInformer.
+	ServiceImports() ServiceImportInformer
+	// WorkloadOverrides returns a WorkloadOverrideInformer.
+	WorkloadOverrides() WorkloadOverrideInformer
+	// WorkspaceQuotas returns a WorkspaceQuotaInformer.
+	WorkspaceQuotas() WorkspaceQuotaInformer
+	// WorkspaceTypes returns a WorkspaceTypeInformer.
+	WorkspaceTypes() WorkspaceTypeInformer
+	// Workspaces returns a WorkspaceInformer.
+	Workspaces() WorkspaceInformer
 }
 
 type version struct {
@@ -43,3 +65,58 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) Clusters() ClusterInformer {
 	return &clusterInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
+
+// ClusterDisruptionBudgets returns a ClusterDisruptionBudgetInformer.
+func (v *version) ClusterDisruptionBudgets() ClusterDisruptionBudgetInformer {
+	return &clusterDisruptionBudgetInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// NegotiatedAPIResources returns a NegotiatedAPIResourceInformer.
+func (v *version) NegotiatedAPIResources() NegotiatedAPIResourceInformer {
+	return &negotiatedAPIResourceInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// PlacementPolicies returns a PlacementPolicyInformer.
+func (v *version) PlacementPolicies() PlacementPolicyInformer {
+	return &placementPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// RegistryCredentialPolicies returns a RegistryCredentialPolicyInformer.
+func (v *version) RegistryCredentialPolicies() RegistryCredentialPolicyInformer {
+	return &registryCredentialPolicyInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// SecretDistributions returns a SecretDistributionInformer.
+func (v *version) SecretDistributions() SecretDistributionInformer {
+	return &secretDistributionInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ServiceExports returns a ServiceExportInformer.
+func (v *version) ServiceExports() ServiceExportInformer {
+	return &serviceExportInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ServiceImports returns a ServiceImportInformer.
+func (v *version) ServiceImports() ServiceImportInformer {
+	return &serviceImportInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// WorkloadOverrides returns a WorkloadOverrideInformer.
+func (v *version) WorkloadOverrides() WorkloadOverrideInformer {
+	return &workloadOverrideInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// WorkspaceQuotas returns a WorkspaceQuotaInformer.
+func (v *version) WorkspaceQuotas() WorkspaceQuotaInformer {
+	return &workspaceQuotaInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// WorkspaceTypes returns a WorkspaceTypeInformer.
+func (v *version) WorkspaceTypes() WorkspaceTypeInformer {
+	return &workspaceTypeInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// Workspaces returns a WorkspaceInformer.
+func (v *version) Workspaces() WorkspaceInformer {
+	return &workspaceInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
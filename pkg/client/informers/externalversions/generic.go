@@ -55,6 +55,28 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=cluster.example.dev, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("clusters"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().Clusters().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("clusterdisruptionbudgets"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().ClusterDisruptionBudgets().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("negotiatedapiresources"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().NegotiatedAPIResources().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("placementpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().PlacementPolicies().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("registrycredentialpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().RegistryCredentialPolicies().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("secretdistributions"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().SecretDistributions().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("serviceexports"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().ServiceExports().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("serviceimports"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().ServiceImports().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("workloadoverrides"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().WorkloadOverrides().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("workspacequotas"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().WorkspaceQuotas().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("workspaces"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().Workspaces().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("workspacetypes"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cluster().V1alpha1().WorkspaceTypes().Informer()}, nil
 
 	}
 
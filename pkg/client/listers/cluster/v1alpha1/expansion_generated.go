@@ -21,3 +21,71 @@ package v1alpha1
 // ClusterListerExpansion allows custom methods to be added to
 // ClusterLister.
 type ClusterListerExpansion interface{}
+
+// ClusterDisruptionBudgetListerExpansion allows custom methods to be added to
+// ClusterDisruptionBudgetLister.
+type ClusterDisruptionBudgetListerExpansion interface{}
+
+// ClusterDisruptionBudgetNamespaceListerExpansion allows custom methods to be added to
+// ClusterDisruptionBudgetNamespaceLister.
+type ClusterDisruptionBudgetNamespaceListerExpansion interface{}
+
+// NegotiatedAPIResourceListerExpansion allows custom methods to be added to
+// NegotiatedAPIResourceLister.
+type NegotiatedAPIResourceListerExpansion interface{}
+
+// PlacementPolicyListerExpansion allows custom methods to be added to
+// PlacementPolicyLister.
+type PlacementPolicyListerExpansion interface{}
+
+// PlacementPolicyNamespaceListerExpansion allows custom methods to be added to
+// PlacementPolicyNamespaceLister.
+type PlacementPolicyNamespaceListerExpansion interface{}
+
+// RegistryCredentialPolicyListerExpansion allows custom methods to be added to
+// RegistryCredentialPolicyLister.
+type RegistryCredentialPolicyListerExpansion interface{}
+
+// SecretDistributionListerExpansion allows custom methods to be added to
+// SecretDistributionLister.
+type SecretDistributionListerExpansion interface{}
+
+// SecretDistributionNamespaceListerExpansion allows custom methods to be added to
+// SecretDistributionNamespaceLister.
+type SecretDistributionNamespaceListerExpansion interface{}
+
+// ServiceExportListerExpansion allows custom methods to be added to
+// ServiceExportLister.
+type ServiceExportListerExpansion interface{}
+
+// ServiceExportNamespaceListerExpansion allows custom methods to be added to
+// ServiceExportNamespaceLister.
+type ServiceExportNamespaceListerExpansion interface{}
+
+// ServiceImportListerExpansion allows custom methods to be added to
+// ServiceImportLister.
+type ServiceImportListerExpansion interface{}
+
+// ServiceImportNamespaceListerExpansion allows custom methods to be added to
+// ServiceImportNamespaceLister.
+type ServiceImportNamespaceListerExpansion interface{}
+
+// WorkloadOverrideListerExpansion allows custom methods to be added to
+// WorkloadOverrideLister.
+type WorkloadOverrideListerExpansion interface{}
+
+// WorkloadOverrideNamespaceListerExpansion allows custom methods to be added to
+// WorkloadOverrideNamespaceLister.
+type WorkloadOverrideNamespaceListerExpansion interface{}
+
+// WorkspaceListerExpansion allows custom methods to be added to
+// WorkspaceLister.
+type WorkspaceListerExpansion interface{}
+
+// WorkspaceQuotaListerExpansion allows custom methods to be added to
+// WorkspaceQuotaLister.
+type WorkspaceQuotaListerExpansion interface{}
+
+// WorkspaceTypeListerExpansion allows custom methods to be added to
+// WorkspaceTypeLister.
+type WorkspaceTypeListerExpansion interface{}
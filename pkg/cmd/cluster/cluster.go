@@ -0,0 +1,250 @@
+// Package cluster implements the "kubectl kcp cluster" subcommands, which
+// cordon, drain and uncordon Clusters, and let workloads fail back to them.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/cmd/help"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+)
+
+// pollInterval is how often drain checks whether a Cluster is drained.
+const pollInterval = 2 * time.Second
+
+// New returns the "cluster" command, with its cordon, drain, uncordon and
+// failback subcommands.
+func New() *cobra.Command {
+	var kubeconfig string
+	clientFor := func() (clusterv1alpha1.ClusterInterface, error) {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = kubeconfig
+		cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		client, err := clusterclient.NewForConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return client.ClusterV1alpha1().Clusters(), nil
+	}
+
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Manage the physical clusters registered with kcp",
+	}
+	cmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "kubeconfig file used to contact kcp; defaults to $KUBECONFIG or ~/.kube/config")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "cordon CLUSTER",
+		Short: "Mark a cluster as unschedulable",
+		Long: help.Doc(`
+					Mark a cluster as unschedulable
+					No new workloads are placed on the cluster, but those already
+					there stay.
+				`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusters, err := clientFor()
+			if err != nil {
+				return err
+			}
+			return Cordon(cmd.Context(), clusters, args[0], cmd.OutOrStdout())
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "uncordon CLUSTER",
+		Short: "Mark a cluster as schedulable again",
+		Long: help.Doc(`
+					Mark a cluster as schedulable again
+					This also stops draining it, so workloads are placed on it and
+					split to it again.
+				`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusters, err := clientFor()
+			if err != nil {
+				return err
+			}
+			return Uncordon(cmd.Context(), clusters, args[0], cmd.OutOrStdout())
+		},
+	})
+
+	var noWait bool
+	var timeout time.Duration
+	drain := &cobra.Command{
+		Use:   "drain CLUSTER",
+		Short: "Cordon a cluster and move its workloads to other clusters",
+		Long: help.Doc(`
+					Cordon a cluster and move its workloads to other clusters
+					The cluster is tainted with the experimental.kcp.dev/drain
+					NoExecute taint, which makes the splitters move the workloads
+					that don't tolerate it off the cluster. Unless --no-wait is
+					given, this waits until the cluster's Drained condition is True.
+				`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusters, err := clientFor()
+			if err != nil {
+				return err
+			}
+			if err := Drain(cmd.Context(), clusters, args[0], cmd.OutOrStdout()); err != nil || noWait {
+				return err
+			}
+			return WaitForDrained(cmd.Context(), clusters, args[0], timeout, cmd.OutOrStdout())
+		},
+	}
+	drain.Flags().BoolVar(&noWait, "no-wait", false, "Return once the cluster is tainted, without waiting for it to be drained")
+	drain.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "How long to wait for the cluster to be drained")
+	cmd.AddCommand(drain)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "failback CLUSTER",
+		Short: "Let workloads return to a cluster that recovered from an eviction",
+		Long: help.Doc(`
+					Let workloads return to a cluster that recovered from an eviction
+					The workloads whose PlacementPolicy has a Manual failback are
+					placed on the cluster again, once it's Ready.
+				`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusters, err := clientFor()
+			if err != nil {
+				return err
+			}
+			return Failback(cmd.Context(), clusters, args[0], cmd.OutOrStdout())
+		},
+	})
+
+	return cmd
+}
+
+// Cordon marks the named Cluster as unschedulable.
+func Cordon(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name string, out io.Writer) error {
+	return update(ctx, clusters, name, out, "cordoned", func(cl *v1alpha1.Cluster) bool {
+		if cl.Spec.Unschedulable {
+			return false
+		}
+		cl.Spec.Unschedulable = true
+		return true
+	})
+}
+
+// Uncordon marks the named Cluster as schedulable, and stops draining it.
+func Uncordon(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name string, out io.Writer) error {
+	return update(ctx, clusters, name, out, "uncordoned", func(cl *v1alpha1.Cluster) bool {
+		drain := placement.DrainTaint()
+		changed := cl.Spec.Unschedulable
+		cl.Spec.Unschedulable = false
+		var taints []corev1.Taint
+		for _, t := range cl.Spec.Taints {
+			if t.MatchTaint(&drain) {
+				changed = true
+				continue
+			}
+			taints = append(taints, t)
+		}
+		cl.Spec.Taints = taints
+		return changed
+	})
+}
+
+// Drain cordons the named Cluster and sets the drain taint on it.
+func Drain(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name string, out io.Writer) error {
+	return update(ctx, clusters, name, out, "cordoned and draining", func(cl *v1alpha1.Cluster) bool {
+		changed := !cl.Spec.Unschedulable
+		cl.Spec.Unschedulable = true
+		if !placement.Draining(cl) {
+			drain := placement.DrainTaint()
+			now := metav1.Now()
+			drain.TimeAdded = &now
+			cl.Spec.Taints = append(cl.Spec.Taints, drain)
+			changed = true
+		}
+		return changed
+	})
+}
+
+// Failback annotates the named Cluster for the workloads failing back
+// manually to return to it.
+func Failback(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name string, out io.Writer) error {
+	return update(ctx, clusters, name, out, "failed back to", func(cl *v1alpha1.Cluster) bool {
+		if cl.Annotations == nil {
+			cl.Annotations = map[string]string{}
+		}
+		cl.Annotations[placement.FailbackAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		return true
+	})
+}
+
+// WaitForDrained waits until the named Cluster's Drained condition is True,
+// for at most timeout.
+func WaitForDrained(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name string, timeout time.Duration, out io.Writer) error {
+	var last string
+	err := wait.PollImmediate(pollInterval, timeout, func() (bool, error) {
+		cl, err := clusters.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		cond := cl.Status.Conditions.Get(v1alpha1.ClusterConditionDrained)
+		if cond == nil {
+			return false, nil
+		}
+		if cond.Status == corev1.ConditionTrue {
+			return true, nil
+		}
+		if cond.Message != last {
+			fmt.Fprintf(out, "cluster/%s: %s\n", name, cond.Message)
+			last = cond.Message
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("cluster %s wasn't drained within %s", name, timeout)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "cluster/%s drained\n", name)
+	return nil
+}
+
+// update applies mutate to the named Cluster, retrying on conflicts, and
+// reports the outcome like kubectl does for nodes.
+func update(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name string, out io.Writer, done string, mutate func(*v1alpha1.Cluster) bool) error {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cl, err := clusters.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if changed = mutate(cl); !changed {
+			return nil
+		}
+		_, err = clusters.Update(ctx, cl, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Fprintf(out, "cluster/%s already %s\n", name, done)
+		return nil
+	}
+	fmt.Fprintf(out, "cluster/%s %s\n", name, done)
+	return nil
+}
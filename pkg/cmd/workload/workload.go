@@ -0,0 +1,114 @@
+// Package workload implements the "kubectl kcp workload" subcommands, which
+// register physical clusters with kcp.
+package workload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/cmd/help"
+	"github.com/kcp-dev/kcp/pkg/registration"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// New returns the "workload" command, with its sync subcommand.
+func New() *cobra.Command {
+	var kubeconfig string
+	cmd := &cobra.Command{
+		Use:   "workload",
+		Short: "Register physical clusters to run workloads on",
+	}
+	cmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "kubeconfig file used to contact kcp; defaults to $KUBECONFIG or ~/.kube/config")
+
+	var o syncer.InstallOptions
+	var driftMode string
+	var ttl time.Duration
+	sync := &cobra.Command{
+		Use:   "sync CLUSTER [RESOURCES...]",
+		Short: "Print the manifests of a syncer registering a cluster with kcp",
+		Long: help.Doc(`
+					Print the manifests of a syncer registering a cluster with kcp
+					This mints a bootstrap token for the cluster, valid for --ttl,
+					and prints the manifests of a syncer that presents it to kcp
+					when it starts, syncing the given resources (pods and
+					deployments by default) from the logical cluster of the
+					current context. Apply them to the cluster, e.g. with
+					"kubectl kcp workload sync my-cluster | kubectl --context
+					my-cluster apply -f -". The cluster's Cluster is created and
+					accepted once the syncer registers it; kcp never needs its
+					kubeconfig.
+				`),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := syncer.ParseDriftMode(driftMode)
+			if err != nil {
+				return err
+			}
+			o.DriftMode = mode
+			o.ClusterID = args[0]
+			o.Resources = args[1:]
+			if len(o.Resources) == 0 {
+				o.Resources = []string{"pods", "deployments"}
+			}
+
+			rules := clientcmd.NewDefaultClientConfigLoadingRules()
+			rules.ExplicitPath = kubeconfig
+			config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).RawConfig()
+			if err != nil {
+				return err
+			}
+			if err := Sync(cmd.Context(), config, o, ttl, cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "The bootstrap token for cluster %s expires in %s\n", o.ClusterID, ttl)
+			return nil
+		},
+	}
+	sync.Flags().StringVar(&o.Image, "syncer-image", "quay.io/kcp-dev/kcp-syncer", "Syncer image to run")
+	sync.Flags().StringVar(&driftMode, "drift-mode", string(syncer.DriftRevert), "What the syncer does about synced objects changed on the cluster: revert, report or adopt")
+	sync.Flags().DurationVar(&ttl, "ttl", registration.DefaultTokenTTL, "How long the bootstrap token is valid, for the syncer to register the cluster")
+	cmd.AddCommand(sync)
+
+	return cmd
+}
+
+// Sync mints a bootstrap token for the Cluster o.ClusterID in the logical
+// cluster of config's current context, and writes the manifests of a syncer
+// registering it with that token, reaching kcp with that context's
+// credentials.
+func Sync(ctx context.Context, config clientcmdapi.Config, o syncer.InstallOptions, ttl time.Duration, out io.Writer) error {
+	if err := clientcmdapi.MinifyConfig(&config); err != nil {
+		return err
+	}
+	// The syncer can't read the files the kubeconfig may refer to.
+	if err := clientcmdapi.FlattenConfig(&config); err != nil {
+		return err
+	}
+	cfg, err := clientcmd.NewDefaultClientConfig(config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	token, err := registration.CreateToken(ctx, client, o.ClusterID, ttl)
+	if err != nil {
+		return err
+	}
+
+	b, err := clientcmd.Write(config)
+	if err != nil {
+		return err
+	}
+	o.Kubeconfig = string(b)
+	o.LogicalCluster = config.CurrentContext
+	o.BootstrapToken = token
+	return syncer.WriteManifests(out, o)
+}
@@ -0,0 +1,192 @@
+// Package workspace implements the "kubectl kcp workspace" subcommands,
+// which mint the kubeconfigs of Workspaces.
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/cmd/help"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// TenantNamespace is the namespace of the ServiceAccounts the minted
+	// kubeconfigs authenticate as, in the logical cluster of their
+	// Workspace: one per Workspace, named after it.
+	TenantNamespace = "kcp-tenants"
+
+	// tenantRole is the name of the ClusterRole and ClusterRoleBinding
+	// granting that ServiceAccount everything in its logical cluster.
+	tenantRole = "kcp:tenant"
+)
+
+// New returns the "workspace" command, with its kubeconfig subcommand.
+func New() *cobra.Command {
+	var kubeconfig string
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Work with the Workspaces of kcp",
+	}
+	cmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "kubeconfig file used to contact the admin logical cluster of kcp; defaults to $KUBECONFIG or ~/.kube/config")
+
+	var output string
+	var duration time.Duration
+	kubeconfigCmd := &cobra.Command{
+		Use:   "kubeconfig WORKSPACE",
+		Short: "Write a kubeconfig reaching a workspace",
+		Long: help.Doc(`
+					Write a kubeconfig reaching a workspace
+					The kubeconfig reaches the Workspace at the URL of its status,
+					once it's Active, with the CA of the current context, which
+					must reach the admin logical cluster the Workspace is in. It
+					authenticates with a token, valid for --duration, of a
+					ServiceAccount of the workspace's own logical cluster, bound
+					to a ClusterRole granting everything there and nowhere else,
+					for the kubeconfig to be handed to the tenants of the
+					workspace. kcp must be started with a
+					--service-account-signing-key-file to issue it.
+				`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules := clientcmd.NewDefaultClientConfigLoadingRules()
+			rules.ExplicitPath = kubeconfig
+			loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+			cfg, err := loader.ClientConfig()
+			if err != nil {
+				return err
+			}
+			raw, err := loader.RawConfig()
+			if err != nil {
+				return err
+			}
+			client, err := clusterclient.NewForConfig(cfg)
+			if err != nil {
+				return err
+			}
+			kubeClient, err := kubernetes.NewForConfig(logicalcluster.Config(cfg, args[0]))
+			if err != nil {
+				return err
+			}
+			minted, err := Kubeconfig(cmd.Context(), client.ClusterV1alpha1().Workspaces(), kubeClient, raw, args[0], duration)
+			if err != nil {
+				return err
+			}
+			if output != "" {
+				if err := clientcmd.WriteToFile(*minted, output); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Wrote the kubeconfig of workspace %s to %s\n", args[0], output)
+				return nil
+			}
+			data, err := clientcmd.Write(*minted)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+	kubeconfigCmd.Flags().StringVarP(&output, "output", "o", "", "File to write the kubeconfig to, rather than stdout")
+	kubeconfigCmd.Flags().DurationVar(&duration, "duration", 24*time.Hour, "How long the token of the kubeconfig is valid for")
+	cmd.AddCommand(kubeconfigCmd)
+
+	return cmd
+}
+
+// Kubeconfig returns a kubeconfig reaching the named Workspace at its URL,
+// with the CA of the current context of admin, a kubeconfig reaching the
+// admin logical cluster, embedded. It authenticates with a token, valid for
+// duration, of the ServiceAccount of the Workspace in TenantNamespace of its
+// logical cluster, which kubeClient reaches, rather than with the
+// credentials of admin. Its only context is named like the Workspace.
+func Kubeconfig(ctx context.Context, workspaces clusterv1alpha1.WorkspaceInterface, kubeClient kubernetes.Interface, admin clientcmdapi.Config, name string, duration time.Duration) (*clientcmdapi.Config, error) {
+	ws, err := workspaces.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if ws.Status.Phase != v1alpha1.WorkspacePhaseActive || ws.Status.URL == "" {
+		return nil, fmt.Errorf("workspace %s isn't Active yet", name)
+	}
+
+	if err := clientcmdapi.FlattenConfig(&admin); err != nil {
+		return nil, err
+	}
+	current, found := admin.Contexts[admin.CurrentContext]
+	if !found {
+		return nil, fmt.Errorf("the kubeconfig has no current context")
+	}
+	cluster := admin.Clusters[current.Cluster]
+	if cluster == nil {
+		return nil, fmt.Errorf("the current context %s of the kubeconfig is missing its cluster", admin.CurrentContext)
+	}
+	token, err := tenantToken(ctx, kubeClient, name, duration)
+	if err != nil {
+		return nil, fmt.Errorf("issuing a token of workspace %s: %w", name, err)
+	}
+
+	minted := clientcmdapi.NewConfig()
+	c := cluster.DeepCopy()
+	c.Server = ws.Status.URL
+	c.LocationOfOrigin = ""
+	minted.Clusters[name] = c
+	minted.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: token}
+	minted.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	minted.CurrentContext = name
+	return minted, nil
+}
+
+// tenantToken returns a token, valid for duration, of the ServiceAccount of
+// the named Workspace, which it creates along with its ClusterRoleBinding
+// if needed. The ServiceAccount is named after the Workspace, so that the
+// bindings of one workspace don't grant anything to the tenants of another.
+func tenantToken(ctx context.Context, kubeClient kubernetes.Interface, name string, duration time.Duration) (string, error) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: TenantNamespace}}
+	if _, err := kubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: TenantNamespace, Name: name}}
+	if _, err := kubeClient.CoreV1().ServiceAccounts(TenantNamespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	role := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: tenantRole},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			{NonResourceURLs: []string{"*"}, Verbs: []string{"*"}},
+		},
+	}
+	if _, err := kubeClient.RbacV1().ClusterRoles().Create(ctx, role, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: tenantRole + ":" + name},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: tenantRole},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: TenantNamespace, Name: name}},
+	}
+	if _, err := kubeClient.RbacV1().ClusterRoleBindings().Create(ctx, binding, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+
+	seconds := int64(duration / time.Second)
+	tr, err := kubeClient.CoreV1().ServiceAccounts(TenantNamespace).CreateToken(ctx, name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &seconds},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return tr.Status.Token, nil
+}
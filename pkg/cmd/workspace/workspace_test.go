@@ -0,0 +1,95 @@
+package workspace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterfake "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/fake"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestKubeconfig(t *testing.T) {
+	clusterClient := clusterfake.NewSimpleClientset(&v1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"},
+		Status: v1alpha1.WorkspaceStatus{
+			Phase: v1alpha1.WorkspacePhaseActive,
+			URL:   "https://kcp:6443/clusters/tenant-a",
+		},
+	})
+	kube := kubefake.NewSimpleClientset()
+	var expiration int64
+	kube.PrependReactor("create", "serviceaccounts", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tr := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		expiration = *tr.Spec.ExpirationSeconds
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: "tenant-a-token"}}, nil
+	})
+
+	admin := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"admin": {Server: "https://kcp:6443/clusters/admin", CertificateAuthorityData: []byte("ca")},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			"admin": {ClientCertificateData: []byte("cert"), ClientKeyData: []byte("key"), Token: "admin-token"},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"admin": {Cluster: "admin", AuthInfo: "admin"},
+		},
+		CurrentContext: "admin",
+	}
+	minted, err := Kubeconfig(context.Background(), clusterClient.ClusterV1alpha1().Workspaces(), kube, admin, "tenant-a", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := minted.Clusters["tenant-a"]
+	if c == nil || c.Server != "https://kcp:6443/clusters/tenant-a" || string(c.CertificateAuthorityData) != "ca" {
+		t.Errorf("the cluster of the kubeconfig is %+v", c)
+	}
+	a := minted.AuthInfos["tenant-a"]
+	if a == nil {
+		t.Fatal("the kubeconfig has no user")
+	}
+	if a.Token != "tenant-a-token" {
+		t.Errorf("the token of the kubeconfig is %q, not the one issued", a.Token)
+	}
+	if len(a.ClientCertificateData) != 0 || len(a.ClientKeyData) != 0 || a.Token == "admin-token" {
+		t.Error("the kubeconfig carries the admin credentials")
+	}
+	if expiration != 3600 {
+		t.Errorf("the token was requested for %ds", expiration)
+	}
+
+	if _, err := kube.CoreV1().ServiceAccounts(TenantNamespace).Get(context.Background(), "tenant-a", metav1.GetOptions{}); err != nil {
+		t.Errorf("no ServiceAccount of the workspace: %v", err)
+	}
+	binding, err := kube.RbacV1().ClusterRoleBindings().Get(context.Background(), tenantRole+":tenant-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("no ClusterRoleBinding of the workspace: %v", err)
+	}
+	if s := binding.Subjects; len(s) != 1 || s[0].Namespace != TenantNamespace || s[0].Name != "tenant-a" {
+		t.Errorf("the ClusterRoleBinding binds %v", s)
+	}
+}
+
+func TestKubeconfigNotActive(t *testing.T) {
+	clusterClient := clusterfake.NewSimpleClientset(&v1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"},
+	})
+	kube := kubefake.NewSimpleClientset()
+	if _, err := Kubeconfig(context.Background(), clusterClient.ClusterV1alpha1().Workspaces(), kube, clientcmdapi.Config{}, "tenant-a", time.Hour); err == nil {
+		t.Error("minted a kubeconfig of a workspace that isn't Active")
+	}
+	if len(kube.Actions()) != 0 {
+		t.Error("issued a token for a workspace that isn't Active")
+	}
+}
@@ -0,0 +1,128 @@
+// Package conditions sets and reads the conditions of the cluster.example.dev
+// objects the way KEP-1623 standardizes them: the lastTransitionTime of a
+// condition only moves when its status changes, its observedGeneration is
+// the generation of the object it was set from, and setting it as it
+// already is changes nothing, so that reconciling an object whose state
+// didn't change doesn't update its status.
+package conditions
+
+import (
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Getter is an object with conditions.
+type Getter interface {
+	GetGeneration() int64
+	GetConditions() v1alpha1.Conditions
+}
+
+// Setter is an object whose conditions can be set.
+type Setter interface {
+	Getter
+	SetConditions(v1alpha1.Conditions)
+}
+
+// now is the time conditions are set at, replaced in tests.
+var now = metav1.Now
+
+// Get returns the condition of the given type of the object, or nil if it
+// has none.
+func Get(from Getter, t v1alpha1.ConditionType) *v1alpha1.Condition {
+	return from.GetConditions().Get(t)
+}
+
+// Has reports whether the object has the condition of the given type.
+func Has(from Getter, t v1alpha1.ConditionType) bool {
+	return Get(from, t) != nil
+}
+
+// IsTrue reports whether the condition of the given type is True.
+func IsTrue(from Getter, t v1alpha1.ConditionType) bool {
+	return hasStatus(from, t, corev1.ConditionTrue)
+}
+
+// IsFalse reports whether the condition of the given type is False.
+func IsFalse(from Getter, t v1alpha1.ConditionType) bool {
+	return hasStatus(from, t, corev1.ConditionFalse)
+}
+
+// IsUnknown reports whether the condition of the given type is Unknown, or
+// missing.
+func IsUnknown(from Getter, t v1alpha1.ConditionType) bool {
+	cond := Get(from, t)
+	return cond == nil || cond.Status == corev1.ConditionUnknown
+}
+
+// IsCurrent reports whether the condition of the given type was set from
+// the current generation of the object.
+func IsCurrent(from Getter, t v1alpha1.ConditionType) bool {
+	cond := Get(from, t)
+	return cond != nil && cond.ObservedGeneration == from.GetGeneration()
+}
+
+func hasStatus(from Getter, t v1alpha1.ConditionType, status corev1.ConditionStatus) bool {
+	cond := Get(from, t)
+	return cond != nil && cond.Status == status
+}
+
+// Set sets the condition of the given type of the object, as observed at
+// its current generation, and reports whether it changed. Its transition
+// time only moves when its status changes, and its heartbeat time whenever
+// it changes.
+func Set(to Setter, t v1alpha1.ConditionType, status corev1.ConditionStatus, reason, message string) bool {
+	conditions := to.GetConditions()
+	prev := conditions.Get(t)
+	if prev != nil && prev.Status == status && prev.Reason == reason && prev.Message == message && prev.ObservedGeneration == to.GetGeneration() {
+		return false
+	}
+
+	ts := now()
+	cond := v1alpha1.Condition{
+		Type:               t,
+		Status:             status,
+		ObservedGeneration: to.GetGeneration(),
+		LastHeartbeatTime:  ts,
+		LastTransitionTime: ts,
+		Reason:             reason,
+		Message:            message,
+	}
+	if prev != nil {
+		if prev.Status == status {
+			cond.LastTransitionTime = prev.LastTransitionTime
+		}
+		*prev = cond
+	} else {
+		conditions = append(conditions, cond)
+	}
+	to.SetConditions(conditions)
+	return true
+}
+
+// MarkTrue sets the condition of the given type to True.
+func MarkTrue(to Setter, t v1alpha1.ConditionType, reason, message string) bool {
+	return Set(to, t, corev1.ConditionTrue, reason, message)
+}
+
+// MarkFalse sets the condition of the given type to False.
+func MarkFalse(to Setter, t v1alpha1.ConditionType, reason, message string) bool {
+	return Set(to, t, corev1.ConditionFalse, reason, message)
+}
+
+// MarkUnknown sets the condition of the given type to Unknown.
+func MarkUnknown(to Setter, t v1alpha1.ConditionType, reason, message string) bool {
+	return Set(to, t, corev1.ConditionUnknown, reason, message)
+}
+
+// Remove removes the condition of the given type from the object, and
+// reports whether it had it.
+func Remove(to Setter, t v1alpha1.ConditionType) bool {
+	conditions := to.GetConditions()
+	if conditions.Get(t) == nil {
+		return false
+	}
+	conditions.Remove(t)
+	to.SetConditions(conditions)
+	return true
+}
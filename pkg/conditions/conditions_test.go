@@ -0,0 +1,69 @@
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSet(t *testing.T) {
+	start := metav1.NewTime(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	ts := start
+	now = func() metav1.Time { return ts }
+	defer func() { now = metav1.Now }()
+	tick := func() { ts = metav1.NewTime(ts.Add(time.Minute)) }
+
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Generation: 1}}
+	if !Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse, "Installing", "") {
+		t.Fatal("a new condition wasn't set")
+	}
+	if !IsFalse(cluster, v1alpha1.ClusterConditionReady) || !IsCurrent(cluster, v1alpha1.ClusterConditionReady) || IsUnknown(cluster, v1alpha1.ClusterConditionReady) {
+		t.Errorf("got %+v", cluster.Status.Conditions)
+	}
+
+	// Setting it again as it is changes nothing.
+	tick()
+	before := cluster.DeepCopy()
+	if Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse, "Installing", "") {
+		t.Error("an unchanged condition was set")
+	}
+	if cond := Get(cluster, v1alpha1.ClusterConditionReady); *cond != before.Status.Conditions[0] {
+		t.Errorf("got %+v, want %+v", *cond, before.Status.Conditions[0])
+	}
+
+	// A new reason, or generation, updates it, but doesn't transition it.
+	if !Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse, "Probing", "") {
+		t.Error("a new reason wasn't set")
+	}
+	tick()
+	cluster.Generation = 2
+	if IsCurrent(cluster, v1alpha1.ClusterConditionReady) {
+		t.Error("a condition of the previous generation is current")
+	}
+	if !Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse, "Probing", "") {
+		t.Error("a new generation wasn't set")
+	}
+	cond := Get(cluster, v1alpha1.ClusterConditionReady)
+	if cond.ObservedGeneration != 2 || !cond.LastTransitionTime.Equal(&start) || cond.LastHeartbeatTime.Equal(&start) {
+		t.Errorf("got %+v", *cond)
+	}
+
+	// A new status transitions it.
+	tick()
+	if !MarkTrue(cluster, v1alpha1.ClusterConditionReady, "SyncerReady", "") {
+		t.Error("a new status wasn't set")
+	}
+	if cond := Get(cluster, v1alpha1.ClusterConditionReady); !IsTrue(cluster, v1alpha1.ClusterConditionReady) || !cond.LastTransitionTime.Equal(&ts) {
+		t.Errorf("got %+v", *cond)
+	}
+	if len(cluster.Status.Conditions) != 1 {
+		t.Errorf("got %d conditions, want 1", len(cluster.Status.Conditions))
+	}
+
+	if !Remove(cluster, v1alpha1.ClusterConditionReady) || Remove(cluster, v1alpha1.ClusterConditionReady) || Has(cluster, v1alpha1.ClusterConditionReady) {
+		t.Errorf("got %+v after removing the condition", cluster.Status.Conditions)
+	}
+}
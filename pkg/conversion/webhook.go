@@ -0,0 +1,148 @@
+// Package conversion serves the conversion webhook of the CRDs of kcp that
+// serve several versions, such as the v1alpha1 and v1beta1 Clusters, which
+// the API server calls to convert their objects between the version they're
+// stored as and the version they're requested in.
+package conversion
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1beta1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+// Path is the path the webhook is served at.
+const Path = "/convert"
+
+// Convert returns the object converted to the given apiVersion, or itself if
+// it's already of that version.
+func Convert(obj *unstructured.Unstructured, apiVersion string) (*unstructured.Unstructured, error) {
+	if obj.GetAPIVersion() == apiVersion {
+		return obj, nil
+	}
+	if kind := obj.GetKind(); kind != "Cluster" {
+		return nil, fmt.Errorf("%s %s can't be converted", obj.GetAPIVersion(), kind)
+	}
+
+	// Every version is converted through v1beta1, which stores them.
+	hub := &v1beta1.Cluster{}
+	switch obj.GetAPIVersion() {
+	case v1beta1.SchemeGroupVersion.String():
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, hub); err != nil {
+			return nil, err
+		}
+	case v1alpha1.SchemeGroupVersion.String():
+		in := &v1alpha1.Cluster{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, in); err != nil {
+			return nil, err
+		}
+		hub = v1beta1.FromV1alpha1(in)
+	default:
+		return nil, fmt.Errorf("unknown version %s of Clusters", obj.GetAPIVersion())
+	}
+
+	var out runtime.Object
+	switch apiVersion {
+	case v1beta1.SchemeGroupVersion.String():
+		out = hub
+	case v1alpha1.SchemeGroupVersion.String():
+		out = hub.ToV1alpha1()
+	default:
+		return nil, fmt.Errorf("unknown version %s of Clusters", apiVersion)
+	}
+	converted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(out)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: converted}, nil
+}
+
+// Handler answers the ConversionReviews of the API server.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		review := &apiextensionsv1.ConversionReview{}
+		if err := json.NewDecoder(req.Body).Decode(review); err != nil || review.Request == nil {
+			http.Error(w, "expected a ConversionReview request", http.StatusBadRequest)
+			return
+		}
+		review.Response = convertReview(review.Request)
+		review.Request = nil
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			klog.Errorf("Error writing the ConversionReview response: %v", err)
+		}
+	})
+}
+
+func convertReview(req *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	resp := &apiextensionsv1.ConversionResponse{UID: req.UID}
+	for _, raw := range req.Objects {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+			resp.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return resp
+		}
+		converted, err := Convert(obj, req.DesiredAPIVersion)
+		if err != nil {
+			resp.Result = metav1.Status{Status: metav1.StatusFailure, Message: fmt.Sprintf("converting %s: %v", obj.GetName(), err)}
+			resp.ConvertedObjects = nil
+			return resp
+		}
+		data, err := converted.MarshalJSON()
+		if err != nil {
+			resp.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			resp.ConvertedObjects = nil
+			return resp
+		}
+		resp.ConvertedObjects = append(resp.ConvertedObjects, runtime.RawExtension{Raw: data})
+	}
+	resp.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return resp
+}
+
+// Serve serves the webhook on a port of the loopback interface until ctx is
+// done, with a self-signed certificate, and returns how the API server
+// reaches it: it's only for the API server of this process to call.
+func Serve(ctx context.Context) (*apiextensionsv1.WebhookClientConfig, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey("127.0.0.1", nil, nil)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	serving, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	server := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := server.Serve(tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{serving}})); err != http.ErrServerClosed {
+			klog.Errorf("The conversion webhook stopped: %v", err)
+		}
+	}()
+
+	url := "https://" + listener.Addr().String() + Path
+	return &apiextensionsv1.WebhookClientConfig{URL: &url, CABundle: certPEM}, nil
+}
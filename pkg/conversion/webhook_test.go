@@ -0,0 +1,86 @@
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandler(t *testing.T) {
+	now := metav1.Now().Rfc3339Copy()
+	alpha := &v1alpha1.Cluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "cluster.example.dev/v1alpha1", Kind: "Cluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "east", UID: "1234", Labels: map[string]string{"region": "east"}},
+		Spec: v1alpha1.ClusterSpec{
+			KubeConfigSecretRef: &v1alpha1.KubeConfigSecretReference{Namespace: "kcp", Name: "east"},
+			Weight:              2,
+			Unschedulable:       true,
+			Taints:              []corev1.Taint{{Key: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+		},
+		Status: v1alpha1.ClusterStatus{
+			Conditions:  v1alpha1.Conditions{{Type: v1alpha1.ClusterConditionReady, Status: corev1.ConditionTrue, Reason: "Synced", LastHeartbeatTime: now, LastTransitionTime: now}},
+			Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+		},
+	}
+
+	beta := review(t, "cluster.example.dev/v1beta1", alpha)
+	got := &v1beta1.Cluster{}
+	if err := json.Unmarshal(beta, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.APIVersion != "cluster.example.dev/v1beta1" || got.UID != "1234" || got.Spec.SecretRef == nil || got.Spec.SecretRef.Name != "east" {
+		t.Errorf("got %s", beta)
+	}
+	if len(got.Spec.Taints) != 2 || got.Spec.Taints[1].Key != v1beta1.UnschedulableTaintKey {
+		t.Errorf("got taints %v", got.Spec.Taints)
+	}
+	if len(got.Status.Conditions) != 1 || got.Status.Conditions[0].Status != corev1.ConditionTrue || got.Status.Capacity.Allocatable.Cpu().String() != "4" {
+		t.Errorf("got status %+v", got.Status)
+	}
+
+	// It converts back to what it was.
+	back := &v1alpha1.Cluster{}
+	if err := json.Unmarshal(review(t, "cluster.example.dev/v1alpha1", got), back); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(back.Spec, alpha.Spec) || !equality.Semantic.DeepEqual(back.Status.Conditions, alpha.Status.Conditions) {
+		t.Errorf("got back %+v, want %+v", back, alpha)
+	}
+}
+
+// review sends a ConversionReview of obj to the handler, and returns the
+// converted object.
+func review(t *testing.T, apiVersion string, obj runtime.Object) []byte {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(&apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request:  &apiextensionsv1.ConversionRequest{UID: "review", DesiredAPIVersion: apiVersion, Objects: []runtime.RawExtension{{Raw: raw}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path, bytes.NewReader(body)))
+	resp := &apiextensionsv1.ConversionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Response == nil || resp.Response.UID != "review" || resp.Response.Result.Status != metav1.StatusSuccess || len(resp.Response.ConvertedObjects) != 1 {
+		t.Fatalf("got %s", rec.Body.String())
+	}
+	return resp.Response.ConvertedObjects[0].Raw
+}
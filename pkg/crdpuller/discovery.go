@@ -22,6 +22,10 @@ type SchemaPuller interface {
 	// PullCRDs allows pulling the resources named by their plural names
 	// and make them available as CRDs in the output map.
 	PullCRDs(context context.Context, resourceNames ...string) (map[string]*apiextensionsv1.CustomResourceDefinition, error)
+	// PullGroupCRDs pulls every resource of the given API groups, "" being
+	// the core group and "*" every group, that can be listed and watched,
+	// and makes them available as CRDs in the output map, by CRD name.
+	PullGroupCRDs(context context.Context, groups ...string) (map[string]*apiextensionsv1.CustomResourceDefinition, error)
 }
 
 type schemaPuller struct {
@@ -61,9 +65,41 @@ func NewSchemaPuller(config *rest.Config) (SchemaPuller, error) {
 	}, nil
 }
 
-// PullCRDs allows pulling the resources named by their plural names
-// and make them available as CRDs in the output map.
+// PullCRDs allows pulling the resources named by their plural names,
+// optionally followed by their group, as in deployments.apps, and make them
+// available as CRDs in the output map.
 func (sp *schemaPuller) PullCRDs(context context.Context, resourceNames ...string) (map[string]*apiextensionsv1.CustomResourceDefinition, error) {
+	names := sets.NewString(resourceNames...)
+	pulled, err := sp.pull(context, func(gv schema.GroupVersion, apiResource metav1.APIResource) bool {
+		return names.Has(apiResource.Name) || names.Has(schema.GroupResource{Group: gv.Group, Resource: apiResource.Name}.String())
+	})
+	if err != nil {
+		return nil, err
+	}
+	crds := map[string]*apiextensionsv1.CustomResourceDefinition{}
+	for _, crd := range pulled {
+		crds[crd.Spec.Names.Plural] = crd
+	}
+	return crds, nil
+}
+
+// PullGroupCRDs pulls every resource of the given API groups, "" being the
+// core group and "*" every group, that can be listed and watched, and makes
+// them available as CRDs in the output map, by CRD name.
+func (sp *schemaPuller) PullGroupCRDs(context context.Context, groups ...string) (map[string]*apiextensionsv1.CustomResourceDefinition, error) {
+	wanted := sets.NewString(groups...)
+	return sp.pull(context, func(gv schema.GroupVersion, apiResource metav1.APIResource) bool {
+		if !wanted.Has("*") && !wanted.Has(gv.Group) {
+			return false
+		}
+		verbs := sets.NewString(apiResource.Verbs...)
+		return !strings.Contains(apiResource.Name, "/") && verbs.Has("list") && verbs.Has("watch")
+	})
+}
+
+// pull pulls the preferred version of every resource matching match, and
+// returns their CRDs by name.
+func (sp *schemaPuller) pull(context context.Context, match func(schema.GroupVersion, metav1.APIResource) bool) (map[string]*apiextensionsv1.CustomResourceDefinition, error) {
 	crds := map[string]*apiextensionsv1.CustomResourceDefinition{}
 	_, apiResourcesLists, err := sp.discoveryClient.ServerGroupsAndResources()
 	if err != nil {
@@ -95,123 +131,127 @@ func (sp *schemaPuller) PullCRDs(context context.Context, resourceNames ...strin
 		}
 
 		for _, apiResource := range apiResourcesList.APIResources {
-			for _, resourceName := range resourceNames {
-				if resourceName != apiResource.Name {
-					continue
-				}
-				CRDName := apiResource.Name
-				if gv.Group == "" {
-					CRDName = CRDName + ".core"
-				} else {
-					CRDName = CRDName + "." + gv.Group
-				}
+			if !match(gv, apiResource) {
+				continue
+			}
+			crd, err := sp.pullCRD(context, gv, apiResource, apiResourceNames)
+			if err != nil {
+				return nil, err
+			}
+			crds[crd.Name] = crd
+		}
+	}
+	return crds, nil
+}
 
-				objectMeta := metav1.ObjectMeta{
-					Name:        CRDName,
-					Labels:      map[string]string{},
-					Annotations: map[string]string{},
-				}
+// pullCRD returns the CRD of the given resource: the one the cluster defines
+// if any, or else one built from its OpenAPI schema.
+func (sp *schemaPuller) pullCRD(context context.Context, gv schema.GroupVersion, apiResource metav1.APIResource, apiResourceNames map[schema.GroupVersion]sets.String) (*apiextensionsv1.CustomResourceDefinition, error) {
+	CRDName := apiResource.Name
+	if gv.Group == "" {
+		CRDName = CRDName + ".core"
+	} else {
+		CRDName = CRDName + "." + gv.Group
+	}
 
-				typeMeta := metav1.TypeMeta{
-					Kind:       "CustomResourceDefinition",
-					APIVersion: "apiextensions.k8s.io/v1",
-				}
+	objectMeta := metav1.ObjectMeta{
+		Name:        CRDName,
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+	}
 
-				crd, err := sp.crdClient.CustomResourceDefinitions().Get(context, CRDName, metav1.GetOptions{})
-				if err == nil {
-					crds[apiResource.Name] = &apiextensionsv1.CustomResourceDefinition{
-						TypeMeta:   typeMeta,
-						ObjectMeta: objectMeta,
-						Spec:       crd.Spec,
-					}
-					continue
-				} else {
-					if !errors.IsNotFound(err) {
-						return nil, err
-					}
-				}
+	typeMeta := metav1.TypeMeta{
+		Kind:       "CustomResourceDefinition",
+		APIVersion: "apiextensions.k8s.io/v1",
+	}
 
-				var resourceScope apiextensionsv1.ResourceScope
-				if apiResource.Namespaced {
-					resourceScope = apiextensionsv1.NamespaceScoped
-				} else {
-					resourceScope = apiextensionsv1.ClusterScoped
-				}
-				swaggerSpecDefinitionName := gv.Group
-				if swaggerSpecDefinitionName == "" {
-					swaggerSpecDefinitionName = "core"
-				}
-				if !strings.Contains(swaggerSpecDefinitionName, ".") {
-					swaggerSpecDefinitionName = "io.k8s.api." + swaggerSpecDefinitionName
-				}
-				swaggerSpecDefinitionName = swaggerSpecDefinitionName + "." + gv.Version + "." + apiResource.Kind
-
-				protoSchema := sp.models.LookupModel(swaggerSpecDefinitionName)
-				schemaProps := apiextensionsv1.JSONSchemaProps{}
-				protoSchema.Accept(&SchemaConverter{
-					schemaProps: &schemaProps,
-					schemaName:  swaggerSpecDefinitionName,
-				})
-
-				hasSubResource := func(subResource string) bool {
-					groupResourceNames := apiResourceNames[gv]
-					if groupResourceNames != nil {
-						return groupResourceNames.Has(apiResource.Name + "/" + subResource)
-					}
-					return false
-				}
+	crd, err := sp.crdClient.CustomResourceDefinitions().Get(context, CRDName, metav1.GetOptions{})
+	if err == nil {
+		return &apiextensionsv1.CustomResourceDefinition{
+			TypeMeta:   typeMeta,
+			ObjectMeta: objectMeta,
+			Spec:       crd.Spec,
+		}, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
 
-				statusSubResource := &apiextensionsv1.CustomResourceSubresourceStatus{}
-				if !hasSubResource("status") {
-					statusSubResource = nil
-				}
+	var resourceScope apiextensionsv1.ResourceScope
+	if apiResource.Namespaced {
+		resourceScope = apiextensionsv1.NamespaceScoped
+	} else {
+		resourceScope = apiextensionsv1.ClusterScoped
+	}
+	swaggerSpecDefinitionName := gv.Group
+	if swaggerSpecDefinitionName == "" {
+		swaggerSpecDefinitionName = "core"
+	}
+	if !strings.Contains(swaggerSpecDefinitionName, ".") {
+		swaggerSpecDefinitionName = "io.k8s.api." + swaggerSpecDefinitionName
+	}
+	swaggerSpecDefinitionName = swaggerSpecDefinitionName + "." + gv.Version + "." + apiResource.Kind
 
-				scaleSubResource := &apiextensionsv1.CustomResourceSubresourceScale{
-					SpecReplicasPath:   ".spec.replicas",
-					StatusReplicasPath: ".status.replicas",
-				}
-				if !hasSubResource("scale") {
-					scaleSubResource = nil
-				}
+	protoSchema := sp.models.LookupModel(swaggerSpecDefinitionName)
+	schemaProps := apiextensionsv1.JSONSchemaProps{}
+	protoSchema.Accept(&SchemaConverter{
+		schemaProps: &schemaProps,
+		schemaName:  swaggerSpecDefinitionName,
+	})
 
-				crd = &apiextensionsv1.CustomResourceDefinition{
-					TypeMeta:   typeMeta,
-					ObjectMeta: objectMeta,
-					Spec: apiextensionsv1.CustomResourceDefinitionSpec{
-						Group: gv.Group,
-						Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
-							{
-								Name: gv.Version,
-								Schema: &apiextensionsv1.CustomResourceValidation{
-									OpenAPIV3Schema: &schemaProps,
-								},
-								Subresources: &apiextensionsv1.CustomResourceSubresources{
-									Status: statusSubResource,
-									Scale:  scaleSubResource,
-								},
-								Served:  true,
-								Storage: true,
-							},
-						},
-						Scope: resourceScope,
-						Names: apiextensionsv1.CustomResourceDefinitionNames{
-							Plural:     apiResource.Name,
-							Kind:       apiResource.Kind,
-							Categories: apiResource.Categories,
-							ShortNames: apiResource.ShortNames,
-							Singular:   apiResource.SingularName,
-						},
-					},
-				}
+	hasSubResource := func(subResource string) bool {
+		groupResourceNames := apiResourceNames[gv]
+		if groupResourceNames != nil {
+			return groupResourceNames.Has(apiResource.Name + "/" + subResource)
+		}
+		return false
+	}
 
-				apiextensionsv1.SetDefaults_CustomResourceDefinition(crd)
-				crds[apiResource.Name] = crd
+	statusSubResource := &apiextensionsv1.CustomResourceSubresourceStatus{}
+	if !hasSubResource("status") {
+		statusSubResource = nil
+	}
 
-				break
-			}
-		}
+	scaleSubResource := &apiextensionsv1.CustomResourceSubresourceScale{
+		SpecReplicasPath:   ".spec.replicas",
+		StatusReplicasPath: ".status.replicas",
 	}
-	return crds, nil
+	if !hasSubResource("scale") {
+		scaleSubResource = nil
+	}
+
+	crd = &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta:   typeMeta,
+		ObjectMeta: objectMeta,
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: gv.Group,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name: gv.Version,
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: &schemaProps,
+					},
+					Subresources: &apiextensionsv1.CustomResourceSubresources{
+						Status: statusSubResource,
+						Scale:  scaleSubResource,
+					},
+					Served:  true,
+					Storage: true,
+				},
+			},
+			Scope: resourceScope,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:     apiResource.Name,
+				Kind:       apiResource.Kind,
+				Categories: apiResource.Categories,
+				ShortNames: apiResource.ShortNames,
+				Singular:   apiResource.SingularName,
+			},
+		},
+	}
+
+	apiextensionsv1.SetDefaults_CustomResourceDefinition(crd)
+	return crd, nil
 }
 
 type SchemaConverter struct {
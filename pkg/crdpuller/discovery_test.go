@@ -0,0 +1,293 @@
+package crdpuller
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	openapitesting "k8s.io/kube-openapi/pkg/util/proto/testing"
+)
+
+// swagger holds the OpenAPI definitions of the built-in resources the
+// cluster of serveCluster serves.
+const swagger = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.18.0"},
+  "paths": {},
+  "definitions": {
+    "io.k8s.api.core.v1.ConfigMap": {
+      "description": "ConfigMap holds configuration data for pods to consume.",
+      "type": "object",
+      "properties": {
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "data": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "io.k8s.api.core.v1.Pod": {
+      "type": "object",
+      "properties": {
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"type": "object", "properties": {"nodeName": {"type": "string"}}}
+      }
+    },
+    "io.k8s.api.apps.v1.Deployment": {
+      "type": "object",
+      "properties": {
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"$ref": "#/definitions/io.k8s.api.apps.v1.DeploymentSpec"}
+      }
+    },
+    "io.k8s.api.apps.v1.DeploymentSpec": {
+      "type": "object",
+      "required": ["selector"],
+      "properties": {
+        "replicas": {"type": "integer", "format": "int32"},
+        "selector": {"type": "object", "additionalProperties": {"type": "string"}},
+        "minReadySeconds": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.Duration"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {"name": {"type": "string"}}
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.Duration": {
+      "type": "integer"
+    }
+  }
+}`
+
+// widgets is the CRD the cluster of serveCluster defines.
+var widgets = &apiextensionsv1.CustomResourceDefinition{
+	TypeMeta:   metav1.TypeMeta{Kind: "CustomResourceDefinition", APIVersion: "apiextensions.k8s.io/v1"},
+	ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com", Labels: map[string]string{"owner": "someone"}, ResourceVersion: "42"},
+	Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+		Group: "example.com",
+		Names: apiextensionsv1.CustomResourceDefinitionNames{Plural: "widgets", Kind: "Widget"},
+		Scope: apiextensionsv1.ClusterScoped,
+		Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+			Name:    "v1",
+			Served:  true,
+			Storage: true,
+			Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+				Type:       "object",
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{"size": {Type: "string"}},
+			}},
+		}},
+	},
+}
+
+// serveCluster serves the discovery and the CRDs of a cluster with
+// ConfigMaps, Pods, Bindings, Deployments and widgets.
+func serveCluster(t *testing.T) *rest.Config {
+	listWatch := metav1.Verbs{"get", "list", "watch", "create"}
+	responses := map[string]interface{}{
+		"/api": &metav1.APIVersions{Versions: []string{"v1"}},
+		"/apis": &metav1.APIGroupList{Groups: []metav1.APIGroup{{
+			Name:             "apps",
+			Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "apps/v1", Version: "v1"}},
+			PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+		}, {
+			Name:             "example.com",
+			Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "example.com/v1", Version: "v1"}},
+			PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "example.com/v1", Version: "v1"},
+		}}},
+		"/api/v1": &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", SingularName: "configmap", Namespaced: true, Kind: "ConfigMap", Verbs: listWatch, ShortNames: []string{"cm"}},
+			{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod", Verbs: listWatch},
+			{Name: "pods/status", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get", "update"}},
+			{Name: "bindings", Namespaced: true, Kind: "Binding", Verbs: metav1.Verbs{"create"}},
+		}},
+		"/apis/apps/v1": &metav1.APIResourceList{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Namespaced: true, Kind: "Deployment", Verbs: listWatch},
+			{Name: "deployments/status", Namespaced: true, Kind: "Deployment", Verbs: metav1.Verbs{"get", "update"}},
+			{Name: "deployments/scale", Namespaced: true, Group: "autoscaling", Version: "v1", Kind: "Scale", Verbs: metav1.Verbs{"get", "update"}},
+		}},
+		"/apis/example.com/v1": &metav1.APIResourceList{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{
+			{Name: "widgets", SingularName: "widget", Kind: "Widget", Verbs: listWatch},
+		}},
+		"/apis/apiextensions.k8s.io/v1/customresourcedefinitions/widgets.example.com": widgets,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		response, found := responses[req.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			response = &metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound}
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return &rest.Config{Host: server.URL}
+}
+
+// newPuller returns a SchemaPuller of the cluster of serveCluster, with its
+// OpenAPI definitions.
+func newPuller(t *testing.T) SchemaPuller {
+	path := filepath.Join(t.TempDir(), "swagger.json")
+	if err := ioutil.WriteFile(path, []byte(swagger), 0600); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := (&openapitesting.Fake{Path: path}).OpenAPISchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := serveCluster(t)
+	return &schemaPuller{
+		discoveryClient: discovery.NewDiscoveryClientForConfigOrDie(cfg),
+		crdClient:       apiextensionsv1client.NewForConfigOrDie(cfg),
+		models:          models,
+	}
+}
+
+func keys(crds map[string]*apiextensionsv1.CustomResourceDefinition) []string {
+	keys := make([]string, 0, len(crds))
+	for key := range crds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPullCRDs(t *testing.T) {
+	crds, err := newPuller(t).PullCRDs(context.Background(), "configmaps", "deployments.apps", "widgets.other.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(crds), []string{"configmaps", "deployments"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("pulled %v, want %v", got, want)
+	}
+
+	cm := crds["configmaps"]
+	if cm.Name != "configmaps.core" || cm.Spec.Group != "" || cm.Spec.Scope != apiextensionsv1.NamespaceScoped {
+		t.Errorf("got ConfigMaps CRD %s of group %q, scope %s", cm.Name, cm.Spec.Group, cm.Spec.Scope)
+	}
+	if names := cm.Spec.Names; names.Kind != "ConfigMap" || names.Singular != "configmap" || !reflect.DeepEqual(names.ShortNames, []string{"cm"}) {
+		t.Errorf("got ConfigMaps names %+v", names)
+	}
+	v := cm.Spec.Versions[0]
+	if v.Name != "v1" || !v.Served || !v.Storage || v.Subresources.Status != nil || v.Subresources.Scale != nil {
+		t.Errorf("got ConfigMaps version %+v", v)
+	}
+	schema := v.Schema.OpenAPIV3Schema
+	if schema.Description != "ConfigMap holds configuration data for pods to consume." {
+		t.Errorf("got ConfigMaps description %q", schema.Description)
+	}
+	if data := schema.Properties["data"]; data.Type != "object" || data.AdditionalProperties == nil || data.AdditionalProperties.Schema.Type != "string" {
+		t.Errorf("got ConfigMaps data schema %+v", data)
+	}
+	if metadata := schema.Properties["metadata"]; metadata.Type != "object" || len(metadata.Properties) != 0 {
+		t.Errorf("got ConfigMaps metadata schema %+v", metadata)
+	}
+}
+
+func TestPullDeployments(t *testing.T) {
+	crds, err := newPuller(t).PullCRDs(context.Background(), "deployments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := crds["deployments"]
+	if d == nil || d.Name != "deployments.apps" || d.Spec.Group != "apps" {
+		t.Fatalf("got Deployments CRD %v", d)
+	}
+	v := d.Spec.Versions[0]
+	if v.Subresources.Status == nil {
+		t.Error("no status subresource")
+	}
+	if scale := v.Subresources.Scale; scale == nil || scale.SpecReplicasPath != ".spec.replicas" || scale.StatusReplicasPath != ".status.replicas" {
+		t.Errorf("got scale subresource %v", scale)
+	}
+	spec := v.Schema.OpenAPIV3Schema.Properties["spec"]
+	if replicas := spec.Properties["replicas"]; replicas.Type != "integer" || replicas.Format != "int32" {
+		t.Errorf("got replicas schema %+v", replicas)
+	}
+	if !reflect.DeepEqual(spec.Required, []string{"selector"}) {
+		t.Errorf("got required fields %v", spec.Required)
+	}
+	// Known types have hard-coded schemas.
+	if minReadySeconds := spec.Properties["minReadySeconds"]; minReadySeconds.Type != "string" {
+		t.Errorf("got Duration schema %+v", minReadySeconds)
+	}
+}
+
+func TestPullGroupCRDs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		groups []string
+		want   []string
+	}{{
+		name:   "core",
+		groups: []string{""},
+		want:   []string{"configmaps.core", "pods.core"},
+	}, {
+		name:   "apps",
+		groups: []string{"apps"},
+		want:   []string{"deployments.apps"},
+	}, {
+		name:   "several",
+		groups: []string{"apps", "example.com"},
+		want:   []string{"deployments.apps", "widgets.example.com"},
+	}, {
+		name:   "every group",
+		groups: []string{"*"},
+		want:   []string{"configmaps.core", "deployments.apps", "pods.core", "widgets.example.com"},
+	}, {
+		name:   "unknown group",
+		groups: []string{"other.example.com"},
+		want:   []string{},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			crds, err := newPuller(t).PullGroupCRDs(context.Background(), tc.groups...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keys(crds); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("pulled %v, want %v", got, tc.want)
+			}
+			for name, crd := range crds {
+				if crd.Name != name {
+					t.Errorf("got CRD %s as %s", crd.Name, name)
+				}
+			}
+		})
+	}
+}
+
+// TestPullDefinedCRD checks that the CRDs the cluster defines are pulled as
+// they are, rather than from their OpenAPI definitions, without their
+// metadata.
+func TestPullDefinedCRD(t *testing.T) {
+	crds, err := newPuller(t).PullGroupCRDs(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crd := crds["widgets.example.com"]
+	if crd == nil {
+		t.Fatal("widgets weren't pulled")
+	}
+	if !reflect.DeepEqual(crd.Spec, widgets.Spec) {
+		t.Errorf("got spec %+v, want %+v", crd.Spec, widgets.Spec)
+	}
+	if len(crd.Labels) != 0 || crd.ResourceVersion != "" {
+		t.Errorf("kept the metadata of the cluster: %+v", crd.ObjectMeta)
+	}
+	if !strings.HasPrefix(crd.APIVersion, "apiextensions.k8s.io/") || crd.Kind != "CustomResourceDefinition" {
+		t.Errorf("got type %s", crd.TypeMeta)
+	}
+}
@@ -0,0 +1,73 @@
+// Package debug serves the pprof profiles and expvar runtime metrics of the
+// kcp processes on a debug port, for diagnosing them in production, such as
+// finding the goroutines the syncer or a controller leaks. As the profiles
+// tell a lot about a process and cost it to collect, they are only served on
+// the loopback interface, reached with kubectl port-forward or from the node.
+package debug
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// DefaultAddr is the address the debug endpoints are served on by default.
+const DefaultAddr = "localhost:6060"
+
+var publishOnce sync.Once
+
+// Handler serves the pprof profiles under /debug/pprof/ and the expvar
+// variables, among which the memory statistics of the Go runtime and the
+// number of goroutines, on /debug/vars.
+func Handler() http.Handler {
+	publishOnce.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() interface{} {
+			return runtime.NumGoroutine()
+		}))
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Serve serves Handler at addr in the background, which must be a loopback
+// address such as localhost:6060.
+func Serve(addr string) error {
+	if err := checkLoopback(addr); err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	klog.Infof("Serving the debug endpoints on %s", l.Addr())
+	go func() {
+		klog.Fatal(http.Serve(l, Handler()))
+	}()
+	return nil
+}
+
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("the debug endpoints are only served on loopback addresses, not %q", addr)
+}
@@ -0,0 +1,50 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/debug/pprof/goroutine?debug=1"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine profile") {
+		t.Errorf("got %d for the goroutine profile: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := get("/debug/vars")
+	vars := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := vars["goroutines"].(float64); !ok || n < 1 {
+		t.Errorf("got goroutines %v", vars["goroutines"])
+	}
+	if _, ok := vars["memstats"]; !ok {
+		t.Error("expected the memory statistics")
+	}
+
+	// Publishing the goroutines again would panic.
+	Handler()
+}
+
+func TestCheckLoopback(t *testing.T) {
+	for _, addr := range []string{"localhost:6060", "127.0.0.1:6060", "[::1]:6060"} {
+		if err := checkLoopback(addr); err != nil {
+			t.Errorf("%s: %v", addr, err)
+		}
+	}
+	for _, addr := range []string{":6060", "0.0.0.0:6060", "10.0.0.1:6060", "localhost"} {
+		if err := checkLoopback(addr); err == nil {
+			t.Errorf("%s: expected an error", addr)
+		}
+	}
+}
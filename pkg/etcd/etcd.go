@@ -2,6 +2,7 @@ package etcd
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -17,11 +18,43 @@ import (
 	"path/filepath"
 	"time"
 
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/clientv3/snapshot"
 	"go.etcd.io/etcd/embed"
 )
 
+// Backend is the storage kcp keeps its data in, which it reaches with the
+// etcd client API: an embedded etcd Server, an External etcd, or anything
+// serving the same API, such as kine in front of SQL databases.
+type Backend interface {
+	// Run starts the Backend, or connects to it, and runs fn with how to
+	// reach it until fn returns.
+	Run(fn func(ClientInfo) error) error
+}
+
+var (
+	_ Backend = &Server{}
+	_ Backend = &External{}
+)
+
+// Server is a Backend running etcd embedded in the process.
 type Server struct {
 	Dir string
+
+	// RestoreFrom is a snapshot, a local path or an s3://bucket/key URL, to
+	// restore the data of a Server that has none from before starting it.
+	RestoreFrom string
+
+	// SnapshotInterval is how often snapshots are saved to SnapshotLocation,
+	// a local directory or an s3://bucket/prefix URL; zero not to. Only the
+	// latest SnapshotsKept local snapshots are kept, all if zero.
+	SnapshotInterval time.Duration
+	SnapshotLocation string
+	SnapshotsKept    int
+
+	// DefragInterval is how often etcd is defragmented, to reclaim the space
+	// of the compacted history; zero not to.
+	DefragInterval time.Duration
 }
 
 type ClientInfo struct {
@@ -51,6 +84,11 @@ func (s *Server) Run(fn func(ClientInfo) error) error {
 	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
 		return err
 	}
+	if s.RestoreFrom != "" {
+		if err := s.restore(cfg); err != nil {
+			return fmt.Errorf("restoring %s: %w", s.RestoreFrom, err)
+		}
+	}
 
 	if err := generateClientAndServerCerts([]string{"localhost"}, filepath.Join(cfg.Dir, "secrets")); err != nil {
 		return err
@@ -80,6 +118,12 @@ func (s *Server) Run(fn func(ClientInfo) error) error {
 
 	select {
 	case <-e.Server.ReadyNotify():
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s.maintain(ctx, snapshot.NewV3(e.GetLogger()), clientv3.Config{
+			Endpoints: []string{cfg.ACUrls[0].String()},
+			TLS:       clientConfig,
+		})
 		return fn(ClientInfo{
 			Endpoints:     []string{cfg.ACUrls[0].String()},
 			TLS:           clientConfig,
@@ -0,0 +1,45 @@
+package etcd
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"go.etcd.io/etcd/pkg/transport"
+)
+
+// External is a Backend run outside of kcp, such as an etcd cluster, or
+// kine in front of a SQL database, that's reached at its Endpoints. Its
+// client certificate and CA are optional, in which case the endpoints are
+// reached without TLS unless they're https ones.
+type External struct {
+	Endpoints []string
+
+	CertFile      string
+	KeyFile       string
+	TrustedCAFile string
+}
+
+func (e *External) Run(fn func(ClientInfo) error) error {
+	if len(e.Endpoints) == 0 {
+		return fmt.Errorf("no endpoints for the external storage")
+	}
+	var tlsConfig *tls.Config
+	if e.CertFile != "" || e.KeyFile != "" || e.TrustedCAFile != "" {
+		var err error
+		tlsConfig, err = transport.TLSInfo{
+			CertFile:      e.CertFile,
+			KeyFile:       e.KeyFile,
+			TrustedCAFile: e.TrustedCAFile,
+		}.ClientConfig()
+		if err != nil {
+			return err
+		}
+	}
+	return fn(ClientInfo{
+		Endpoints:     e.Endpoints,
+		TLS:           tlsConfig,
+		CertFile:      e.CertFile,
+		KeyFile:       e.KeyFile,
+		TrustedCAFile: e.TrustedCAFile,
+	})
+}
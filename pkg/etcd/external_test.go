@@ -0,0 +1,82 @@
+package etcd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExternal(t *testing.T) {
+	dir := t.TempDir()
+	if err := generateClientAndServerCerts([]string{"localhost"}, dir); err != nil {
+		t.Fatal(err)
+	}
+	ca := filepath.Join(dir, "ca", "cert.pem")
+	cert, key := filepath.Join(dir, "client", "cert.pem"), filepath.Join(dir, "client", "key.pem")
+
+	for _, tc := range []struct {
+		name     string
+		external External
+		// tls is whether the endpoints are reached with TLS, and certificate
+		// whether with a client certificate.
+		tls, certificate bool
+		err              bool
+	}{{
+		name: "no endpoints",
+		err:  true,
+	}, {
+		name:     "without TLS",
+		external: External{Endpoints: []string{"http://kine:2379"}},
+	}, {
+		name:     "with a CA",
+		external: External{Endpoints: []string{"https://etcd:2379"}, TrustedCAFile: ca},
+		tls:      true,
+	}, {
+		name:        "with a client certificate",
+		external:    External{Endpoints: []string{"https://etcd:2379"}, CertFile: cert, KeyFile: key, TrustedCAFile: ca},
+		tls:         true,
+		certificate: true,
+	}, {
+		name:     "with a missing CA",
+		external: External{Endpoints: []string{"https://etcd:2379"}, TrustedCAFile: filepath.Join(dir, "missing.pem")},
+		err:      true,
+	}, {
+		name:     "with a certificate without its key",
+		external: External{Endpoints: []string{"https://etcd:2379"}, CertFile: cert},
+		err:      true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *ClientInfo
+			err := tc.external.Run(func(info ClientInfo) error {
+				got = &info
+				return nil
+			})
+			if tc.err {
+				if err == nil {
+					t.Errorf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := tc.external
+			if !reflect.DeepEqual(got.Endpoints, e.Endpoints) || got.CertFile != e.CertFile || got.KeyFile != e.KeyFile || got.TrustedCAFile != e.TrustedCAFile {
+				t.Errorf("got %+v for %+v", got, e)
+			}
+			if (got.TLS != nil) != tc.tls {
+				t.Fatalf("got TLS configuration %+v", got.TLS)
+			}
+			if !tc.tls {
+				return
+			}
+			if got.TLS.RootCAs == nil {
+				t.Error("got no CAs")
+			}
+			// The client certificate is loaded on each handshake.
+			if certificate := len(got.TLS.Certificates) > 0 || got.TLS.GetClientCertificate != nil; certificate != tc.certificate {
+				t.Errorf("got a client certificate: %v", certificate)
+			}
+		})
+	}
+}
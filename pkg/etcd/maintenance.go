@@ -0,0 +1,166 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/clientv3/snapshot"
+	"go.etcd.io/etcd/embed"
+	"k8s.io/klog/v2"
+)
+
+// snapshotPrefix starts the names of the snapshots Servers save, followed
+// by when they were saved, so that they sort by time.
+const snapshotPrefix = "kcp-etcd-"
+
+// maintain saves snapshots and defragments etcd in the background, as
+// configured, until ctx is done.
+func (s *Server) maintain(ctx context.Context, snapshots snapshot.Manager, cfg clientv3.Config) {
+	if s.SnapshotInterval > 0 {
+		go every(ctx, s.SnapshotInterval, func() {
+			if err := s.snapshot(ctx, snapshots, cfg, time.Now()); err != nil {
+				klog.Errorf("Failed to snapshot etcd: %v", err)
+			}
+		})
+	}
+	if s.DefragInterval > 0 {
+		go every(ctx, s.DefragInterval, func() {
+			if err := defragment(ctx, cfg); err != nil {
+				klog.Errorf("Failed to defragment etcd: %v", err)
+			}
+		})
+	}
+}
+
+// every calls fn every period until ctx is done.
+func every(ctx context.Context, period time.Duration, fn func()) {
+	t := time.NewTicker(period)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			fn()
+		}
+	}
+}
+
+// snapshot saves a snapshot of etcd to the SnapshotLocation. Snapshots
+// uploaded to S3 are first saved to the Server's Dir.
+func (s *Server) snapshot(ctx context.Context, snapshots snapshot.Manager, cfg clientv3.Config, now time.Time) error {
+	name := snapshotPrefix + now.UTC().Format("20060102T150405Z") + ".db"
+	object, remote := parseS3(s.SnapshotLocation)
+	dir := s.SnapshotLocation
+	if remote {
+		dir = s.Dir
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	if err := snapshots.Save(ctx, cfg, path); err != nil {
+		return err
+	}
+	if !remote {
+		klog.Infof("Saved etcd snapshot %s", path)
+		return prune(dir, s.SnapshotsKept)
+	}
+
+	defer os.Remove(path)
+	if object.key != "" && !strings.HasSuffix(object.key, "/") {
+		object.key += "/"
+	}
+	object.key += name
+	if err := object.put(ctx, path); err != nil {
+		return err
+	}
+	klog.Infof("Uploaded etcd snapshot %s", object)
+	return nil
+}
+
+// prune removes all but the latest kept snapshots of dir, none if kept is
+// zero.
+func prune(dir string, kept int) error {
+	if kept <= 0 {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, snapshotPrefix+"*.db"))
+	if err != nil {
+		return err
+	}
+	if len(paths) <= kept {
+		return nil
+	}
+	sort.Strings(paths)
+	for _, path := range paths[:len(paths)-kept] {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defragment defragments the etcd the client config is for.
+func defragment(ctx context.Context, cfg clientv3.Config) error {
+	c, err := clientv3.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for _, endpoint := range cfg.Endpoints {
+		if _, err := c.Defragment(ctx, endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore restores the data of the Server from its RestoreFrom snapshot.
+// Servers that already have data aren't restored over it: it must be moved
+// away first.
+func (s *Server) restore(cfg *embed.Config) error {
+	member := filepath.Join(s.Dir, "member")
+	if _, err := os.Stat(member); err == nil {
+		return fmt.Errorf("%s already has data", s.Dir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	path := s.RestoreFrom
+	if object, remote := parseS3(path); remote {
+		path = filepath.Join(s.Dir, "restore.db")
+		if err := object.get(context.Background(), path); err != nil {
+			return err
+		}
+		defer os.Remove(path)
+	}
+
+	// Restores require a new directory, in which the member is restored.
+	out := filepath.Join(s.Dir, "restore")
+	if err := os.RemoveAll(out); err != nil {
+		return err
+	}
+	defer os.RemoveAll(out)
+	if err := snapshot.NewV3(nil).Restore(snapshot.RestoreConfig{
+		SnapshotPath:        path,
+		Name:                cfg.Name,
+		OutputDataDir:       out,
+		PeerURLs:            []string{cfg.APUrls[0].String()},
+		InitialCluster:      cfg.InitialCluster,
+		InitialClusterToken: cfg.InitialClusterToken,
+	}); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(out, "member"), member); err != nil {
+		return err
+	}
+	klog.Infof("Restored etcd from %s", s.RestoreFrom)
+	return nil
+}
@@ -0,0 +1,72 @@
+package etcd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestS3Object(t *testing.T) {
+	if _, ok := parseS3("/var/backups/kcp"); ok {
+		t.Error("parsed a local path as an S3 object")
+	}
+	object, ok := parseS3("s3://backups/kcp/etcd snapshot.db")
+	if !ok || object.bucket != "backups" || object.key != "kcp/etcd snapshot.db" {
+		t.Fatalf("parsed %s as %+v", "s3://backups/kcp/etcd snapshot.db", object)
+	}
+
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+		switch req.Method {
+		case http.MethodPut:
+			objects[req.URL.EscapedPath()], _ = ioutil.ReadAll(req.Body)
+		case http.MethodGet:
+			body, found := objects[req.URL.EscapedPath()]
+			if !found {
+				http.NotFound(w, req)
+				return
+			}
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+	os.Setenv("AWS_ENDPOINT_URL", server.URL)
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	defer os.Unsetenv("AWS_ENDPOINT_URL")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.db")
+	if err := ioutil.WriteFile(path, []byte("snapshot"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := object.put(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := objects["/backups/kcp/etcd%20snapshot.db"]; !found {
+		t.Errorf("uploaded objects %v, want /backups/kcp/etcd%%20snapshot.db", objects)
+	}
+	restored := filepath.Join(dir, "restored.db")
+	if err := object.get(ctx, restored); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(restored); err != nil || string(b) != "snapshot" {
+		t.Errorf("downloaded %q, %v", b, err)
+	}
+	if err := (s3Object{bucket: "backups", key: "missing"}).get(ctx, restored); err == nil {
+		t.Error("downloaded a missing object")
+	}
+}
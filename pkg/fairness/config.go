@@ -0,0 +1,295 @@
+// Package fairness shares out how many requests kcp serves at once among
+// the kinds of clients reaching it, as the API Priority and Fairness of the
+// Kubernetes API server does: each request is assigned a priority level by
+// the first FlowSchema matching it, and each level serves up to its share of
+// the requests in flight, queuing or rejecting the others, so that a busy
+// syncer doesn't keep kubectl users from being served.
+package fairness
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	flowcontrolv1alpha1 "k8s.io/api/flowcontrol/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// defaultQueueLengthLimit is how many requests may wait for a level queuing
+// them that doesn't set its queuing configuration.
+const defaultQueueLengthLimit = 50
+
+// Config is the FlowSchemas and the priority levels they assign requests to.
+type Config struct {
+	// schemas are sorted by matchingPrecedence, then name.
+	schemas []flowcontrolv1alpha1.FlowSchema
+	levels  map[string]flowcontrolv1alpha1.PriorityLevelConfiguration
+}
+
+// Default returns the configuration kcp is served with unless given one:
+// the syncers, the privileged users kcp's own controllers run as, and
+// everyone else each get a level of their own, served up to 30%, 30% and
+// 40% of the requests in flight, queuing the others by user.
+func Default() *Config {
+	config := &Config{levels: map[string]flowcontrolv1alpha1.PriorityLevelConfiguration{}}
+	add := func(name string, precedence, shares int32, subjects ...flowcontrolv1alpha1.Subject) {
+		config.levels[name] = flowcontrolv1alpha1.PriorityLevelConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: flowcontrolv1alpha1.PriorityLevelConfigurationSpec{
+				Type: flowcontrolv1alpha1.PriorityLevelEnablementLimited,
+				Limited: &flowcontrolv1alpha1.LimitedPriorityLevelConfiguration{
+					AssuredConcurrencyShares: shares,
+					LimitResponse: flowcontrolv1alpha1.LimitResponse{
+						Type: flowcontrolv1alpha1.LimitResponseTypeQueue,
+						Queuing: &flowcontrolv1alpha1.QueuingConfiguration{
+							Queues:           64,
+							HandSize:         6,
+							QueueLengthLimit: defaultQueueLengthLimit,
+						},
+					},
+				},
+			},
+		}
+		config.schemas = append(config.schemas, flowcontrolv1alpha1.FlowSchema{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: flowcontrolv1alpha1.FlowSchemaSpec{
+				PriorityLevelConfiguration: flowcontrolv1alpha1.PriorityLevelConfigurationReference{Name: name},
+				MatchingPrecedence:         precedence,
+				DistinguisherMethod:        &flowcontrolv1alpha1.FlowDistinguisherMethod{Type: flowcontrolv1alpha1.FlowDistinguisherMethodByUserType},
+				Rules: []flowcontrolv1alpha1.PolicyRulesWithSubjects{{
+					Subjects: subjects,
+					ResourceRules: []flowcontrolv1alpha1.ResourcePolicyRule{{
+						Verbs:        []string{flowcontrolv1alpha1.VerbAll},
+						APIGroups:    []string{flowcontrolv1alpha1.APIGroupAll},
+						Resources:    []string{flowcontrolv1alpha1.ResourceAll},
+						ClusterScope: true,
+						Namespaces:   []string{flowcontrolv1alpha1.NamespaceEvery},
+					}},
+					NonResourceRules: []flowcontrolv1alpha1.NonResourcePolicyRule{{
+						Verbs:           []string{flowcontrolv1alpha1.VerbAll},
+						NonResourceURLs: []string{flowcontrolv1alpha1.NonResourceAll},
+					}},
+				}},
+			},
+		})
+	}
+	add("syncers", 500, 30, flowcontrolv1alpha1.Subject{
+		Kind:           flowcontrolv1alpha1.SubjectKindServiceAccount,
+		ServiceAccount: &flowcontrolv1alpha1.ServiceAccountSubject{Namespace: cluster.SyncerNamespace, Name: flowcontrolv1alpha1.NameAll},
+	})
+	add("controllers", 1000, 30, flowcontrolv1alpha1.Subject{
+		Kind:  flowcontrolv1alpha1.SubjectKindGroup,
+		Group: &flowcontrolv1alpha1.GroupSubject{Name: user.SystemPrivilegedGroup},
+	})
+	add("users", 10000, 40, flowcontrolv1alpha1.Subject{
+		Kind:  flowcontrolv1alpha1.SubjectKindGroup,
+		Group: &flowcontrolv1alpha1.GroupSubject{Name: user.AllAuthenticated},
+	}, flowcontrolv1alpha1.Subject{
+		Kind:  flowcontrolv1alpha1.SubjectKindGroup,
+		Group: &flowcontrolv1alpha1.GroupSubject{Name: user.AllUnauthenticated},
+	})
+	return config
+}
+
+// Load reads the flowcontrol.apiserver.k8s.io/v1alpha1 FlowSchemas and
+// PriorityLevelConfigurations in the given YAML or JSON file, separated by
+// "---".
+//
+// The requests of each flow, as the distinguisherMethod of their FlowSchema
+// tells them apart, are queued in the shortest of the handSize queues of
+// their level shuffle sharding deals the flow, those of a FlowSchema without
+// a distinguisherMethod being a single flow. A level queuing requests
+// without a queuing configuration has a single queue. Requests no FlowSchema
+// matches aren't limited.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config := &Config{levels: map[string]flowcontrolv1alpha1.PriorityLevelConfiguration{}}
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var doc runtime.RawExtension
+		if err := decoder.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(doc.Raw) == 0 || string(doc.Raw) == "null" {
+			continue
+		}
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(doc.Raw, &typeMeta); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if typeMeta.APIVersion != flowcontrolv1alpha1.SchemeGroupVersion.String() {
+			return nil, fmt.Errorf("%s: unsupported apiVersion %q, must be %s", path, typeMeta.APIVersion, flowcontrolv1alpha1.SchemeGroupVersion)
+		}
+		switch typeMeta.Kind {
+		case "FlowSchema":
+			var schema flowcontrolv1alpha1.FlowSchema
+			if err := json.Unmarshal(doc.Raw, &schema); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			config.schemas = append(config.schemas, schema)
+		case "PriorityLevelConfiguration":
+			var level flowcontrolv1alpha1.PriorityLevelConfiguration
+			if err := json.Unmarshal(doc.Raw, &level); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if err := validateLevel(level); err != nil {
+				return nil, fmt.Errorf("%s: PriorityLevelConfiguration %s: %w", path, level.Name, err)
+			}
+			config.levels[level.Name] = level
+		default:
+			return nil, fmt.Errorf("%s: unsupported kind %q, must be FlowSchema or PriorityLevelConfiguration", path, typeMeta.Kind)
+		}
+	}
+	for _, schema := range config.schemas {
+		if _, found := config.levels[schema.Spec.PriorityLevelConfiguration.Name]; !found {
+			return nil, fmt.Errorf("%s: FlowSchema %s: no PriorityLevelConfiguration %q", path, schema.Name, schema.Spec.PriorityLevelConfiguration.Name)
+		}
+	}
+	sort.SliceStable(config.schemas, func(i, j int) bool {
+		a, b := config.schemas[i], config.schemas[j]
+		if a.Spec.MatchingPrecedence != b.Spec.MatchingPrecedence {
+			return a.Spec.MatchingPrecedence < b.Spec.MatchingPrecedence
+		}
+		return a.Name < b.Name
+	})
+	return config, nil
+}
+
+func validateLevel(level flowcontrolv1alpha1.PriorityLevelConfiguration) error {
+	switch level.Spec.Type {
+	case flowcontrolv1alpha1.PriorityLevelEnablementExempt:
+		return nil
+	case flowcontrolv1alpha1.PriorityLevelEnablementLimited:
+	default:
+		return fmt.Errorf("unsupported type %q, must be Exempt or Limited", level.Spec.Type)
+	}
+	limited := level.Spec.Limited
+	if limited == nil || limited.AssuredConcurrencyShares <= 0 {
+		return fmt.Errorf("limited levels must have positive assuredConcurrencyShares")
+	}
+	switch limited.LimitResponse.Type {
+	case flowcontrolv1alpha1.LimitResponseTypeQueue:
+		if q := limited.LimitResponse.Queuing; q != nil && q.HandSize > q.Queues {
+			return fmt.Errorf("the handSize %d of the queuing configuration must be no more than its queues %d", q.HandSize, q.Queues)
+		}
+		return nil
+	case flowcontrolv1alpha1.LimitResponseTypeReject:
+		return nil
+	default:
+		return fmt.Errorf("unsupported limitResponse type %q, must be Queue or Reject", limited.LimitResponse.Type)
+	}
+}
+
+// levelFor returns the name of the priority level of the request, and its
+// flow, if any FlowSchema matches it.
+func (c *Config) levelFor(u user.Info, info *request.RequestInfo) (string, string, bool) {
+	for _, schema := range c.schemas {
+		for _, rule := range schema.Spec.Rules {
+			if matchesSubjects(rule.Subjects, u) && matchesRequest(rule, info) {
+				return schema.Spec.PriorityLevelConfiguration.Name, flowOf(schema, u, info), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// flowOf returns the flow of a request the FlowSchema matches: that of its
+// user or namespace, as its distinguisherMethod says, within the FlowSchema.
+func flowOf(schema flowcontrolv1alpha1.FlowSchema, u user.Info, info *request.RequestInfo) string {
+	flow := schema.Name
+	if d := schema.Spec.DistinguisherMethod; d != nil {
+		switch d.Type {
+		case flowcontrolv1alpha1.FlowDistinguisherMethodByUserType:
+			flow += "/" + u.GetName()
+		case flowcontrolv1alpha1.FlowDistinguisherMethodByNamespaceType:
+			flow += "/" + info.Namespace
+		}
+	}
+	return flow
+}
+
+func matchesSubjects(subjects []flowcontrolv1alpha1.Subject, u user.Info) bool {
+	for _, s := range subjects {
+		switch {
+		case s.Kind == flowcontrolv1alpha1.SubjectKindUser && s.User != nil:
+			if s.User.Name == flowcontrolv1alpha1.NameAll || s.User.Name == u.GetName() {
+				return true
+			}
+		case s.Kind == flowcontrolv1alpha1.SubjectKindGroup && s.Group != nil:
+			for _, group := range u.GetGroups() {
+				if s.Group.Name == flowcontrolv1alpha1.NameAll || s.Group.Name == group {
+					return true
+				}
+			}
+		case s.Kind == flowcontrolv1alpha1.SubjectKindServiceAccount && s.ServiceAccount != nil:
+			namespace, name, err := serviceaccount.SplitUsername(u.GetName())
+			if err == nil && namespace == s.ServiceAccount.Namespace &&
+				(s.ServiceAccount.Name == flowcontrolv1alpha1.NameAll || s.ServiceAccount.Name == name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesRequest(rule flowcontrolv1alpha1.PolicyRulesWithSubjects, info *request.RequestInfo) bool {
+	if !info.IsResourceRequest {
+		for _, r := range rule.NonResourceRules {
+			if matches(r.Verbs, info.Verb) && matchesURL(r.NonResourceURLs, info.Path) {
+				return true
+			}
+		}
+		return false
+	}
+	resource := info.Resource
+	if info.Subresource != "" {
+		resource += "/" + info.Subresource
+	}
+	for _, r := range rule.ResourceRules {
+		if !matches(r.Verbs, info.Verb) || !matches(r.APIGroups, info.APIGroup) || !matches(r.Resources, resource) {
+			continue
+		}
+		if info.Namespace == "" && r.ClusterScope || info.Namespace != "" && matches(r.Namespaces, info.Namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether values holds value or "*".
+func matches(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesURL reports whether urls holds path, "*", or a prefix of path
+// ending in "/*".
+func matchesURL(urls []string, path string) bool {
+	for _, u := range urls {
+		if u == flowcontrolv1alpha1.NonResourceAll || u == path ||
+			strings.HasSuffix(u, "/*") && strings.HasPrefix(path, strings.TrimSuffix(u, "*")) {
+			return true
+		}
+	}
+	return false
+}
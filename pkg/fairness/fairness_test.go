@@ -0,0 +1,268 @@
+package fairness
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/authorization"
+	"github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	flowcontrolv1alpha1 "k8s.io/api/flowcontrol/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const config = `
+apiVersion: flowcontrol.apiserver.k8s.io/v1alpha1
+kind: PriorityLevelConfiguration
+metadata:
+  name: deployments
+spec:
+  type: Limited
+  limited:
+    assuredConcurrencyShares: 1
+    limitResponse:
+      type: Reject
+---
+apiVersion: flowcontrol.apiserver.k8s.io/v1alpha1
+kind: FlowSchema
+metadata:
+  name: deployments
+spec:
+  priorityLevelConfiguration:
+    name: deployments
+  matchingPrecedence: 100
+  rules:
+  - subjects:
+    - kind: Group
+      group:
+        name: system:authenticated
+    resourceRules:
+    - verbs: ["*"]
+      apiGroups: [apps]
+      resources: [deployments]
+      namespaces: ["*"]
+`
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fairness.yaml")
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{user.AllAuthenticated}}
+	deployments := info(t, "/apis/apps/v1/namespaces/default/deployments")
+	if name, _, found := c.levelFor(alice, deployments); !found || name != "deployments" {
+		t.Errorf("levelFor(deployments) = %q, %t, want deployments", name, found)
+	}
+	if name, _, found := c.levelFor(alice, info(t, "/api/v1/namespaces/default/pods")); found {
+		t.Errorf("levelFor(pods) = %q, want none", name)
+	}
+
+	schemaOnly := strings.SplitN(config, "---", 2)[1]
+	if err := ioutil.WriteFile(path, []byte(schemaOnly), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() of a FlowSchema without its level didn't fail")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	syncer := &user.DefaultInfo{Name: serviceaccount.MakeUsername(cluster.SyncerNamespace, "east"), Groups: serviceaccount.MakeGroupNames(cluster.SyncerNamespace)}
+	admin := &user.DefaultInfo{Name: "admin", Groups: []string{user.SystemPrivilegedGroup, user.AllAuthenticated}}
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{user.AllAuthenticated}}
+	pods := info(t, "/api/v1/namespaces/default/pods")
+	for u, want := range map[user.Info]string{syncer: "syncers", admin: "controllers", alice: "users"} {
+		if name, _, _ := Default().levelFor(u, pods); name != want {
+			t.Errorf("levelFor(%s) = %q, want %q", u.GetName(), name, want)
+		}
+	}
+	// Each user is a flow of its own.
+	bob := &user.DefaultInfo{Name: "bob", Groups: []string{user.AllAuthenticated}}
+	_, aliceFlow, _ := Default().levelFor(alice, pods)
+	_, bobFlow, _ := Default().levelFor(bob, pods)
+	if aliceFlow != "users/alice" || bobFlow != "users/bob" {
+		t.Errorf("got flows %q and %q", aliceFlow, bobFlow)
+	}
+}
+
+func TestWithPriorityAndFairness(t *testing.T) {
+	// With a single seat, a second request is rejected while the first one
+	// is served, but watches and health checks aren't limited.
+	served, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("block") != "" {
+			served <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	c := Default()
+	users := c.levels["users"]
+	users.Spec.Limited.LimitResponse = flowcontrolv1alpha1.LimitResponse{Type: flowcontrolv1alpha1.LimitResponseTypeReject}
+	c.levels = map[string]flowcontrolv1alpha1.PriorityLevelConfiguration{"users": users}
+	c.schemas = c.schemas[2:]
+	authn := authenticator.RequestFunc(func(*http.Request) (*authenticator.Response, bool, error) {
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "alice", Groups: []string{user.AllAuthenticated}}}, true, nil
+	})
+	server := httptest.NewServer(authorization.WithAuthentication(WithPriorityAndFairness(handler, c, 1), authn))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if resp, err := http.Get(server.URL + "/api/v1/pods?block=true"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-served
+	for path, want := range map[string]int{
+		"/api/v1/pods":            http.StatusTooManyRequests,
+		"/api/v1/pods?watch=true": http.StatusOK,
+		"/healthz":                http.StatusOK,
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestQueuedFirst(t *testing.T) {
+	// With a single seat, the requests queued get it in turn as it's freed,
+	// before the requests coming in since.
+	l := &level{name: "users", seats: 1, queueLength: 2, handSize: 1, queues: make([]list.List, 1)}
+	served, release := make(chan string), make(chan struct{})
+	serve := func(name string) {
+		handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			served <- name
+			<-release
+		})
+		go l.serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil), "users/alice", handler)
+	}
+	queued := func(n int) { waitQueued(t, l, n) }
+
+	serve("first")
+	if got := <-served; got != "first" {
+		t.Fatalf("served %s", got)
+	}
+	serve("second")
+	queued(1)
+	serve("third")
+	queued(2)
+	for _, want := range []string{"second", "third"} {
+		release <- struct{}{}
+		if got := <-served; got != want {
+			t.Errorf("served %s, want %s", got, want)
+		}
+	}
+	release <- struct{}{}
+
+	// A queued request giving up leaves the queue.
+	serve("fourth")
+	<-served
+	ctx, cancel := context.WithCancel(context.Background())
+	go l.serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil).WithContext(ctx), "users/alice", http.NotFoundHandler())
+	queued(1)
+	cancel()
+	queued(0)
+	release <- struct{}{}
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return l.inflight == 0, nil
+	}); err != nil {
+		t.Error("the seat wasn't freed")
+	}
+}
+
+func TestFlows(t *testing.T) {
+	// With a single seat, a flow queuing requests behind a busy one is
+	// served in turn with it, rather than once it's done.
+	l := &level{name: "users", seats: 1, queueLength: 10, handSize: 1, queues: make([]list.List, 8)}
+	bob := "users/bob"
+	for i := 0; l.queueFor(bob) == l.queueFor("users/alice"); i++ {
+		bob = fmt.Sprintf("users/bob-%d", i)
+	}
+	served, release := make(chan string), make(chan struct{})
+	serve := func(flow string, n int) {
+		name := fmt.Sprintf("%s %d", flow, n)
+		handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			served <- name
+			<-release
+		})
+		go l.serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil), flow, handler)
+	}
+
+	serve("users/alice", 0)
+	<-served
+	for i := 1; i <= 3; i++ {
+		serve("users/alice", i)
+		waitQueued(t, l, i)
+	}
+	serve(bob, 1)
+	waitQueued(t, l, 4)
+	var got []string
+	for i := 0; i < 4; i++ {
+		release <- struct{}{}
+		got = append(got, <-served)
+	}
+	release <- struct{}{}
+	if i := indexOf(got, bob+" 1"); i < 0 || i > 1 {
+		t.Errorf("served %q, want %s served among the first two", got, bob)
+	}
+	if i, j := indexOf(got, "users/alice 1"), indexOf(got, "users/alice 3"); i < 0 || j < i {
+		t.Errorf("served %q, want the requests of alice in order", got)
+	}
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// waitQueued waits for n requests to be queued in the level.
+func waitQueued(t *testing.T, l *level, n int) {
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return l.waiting == n, nil
+	}); err != nil {
+		t.Fatalf("%d requests aren't queued", n)
+	}
+}
+
+func info(t *testing.T, path string) *request.RequestInfo {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	info, err := requestInfoFactory.NewRequestInfo(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
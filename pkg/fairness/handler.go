@@ -0,0 +1,273 @@
+package fairness
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	flowcontrolv1alpha1 "k8s.io/api/flowcontrol/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	// DefaultMaxRequestsInflight is how many requests kcp serves at once
+	// by default, shared out among the limited priority levels: as many as
+	// the Kubernetes API server does by default, mutating or not.
+	DefaultMaxRequestsInflight = 600
+
+	// queueTimeout is how long a queued request waits to be served before
+	// it's rejected.
+	queueTimeout = 15 * time.Second
+
+	// retryAfterSeconds is how long the rejected clients are told to wait
+	// before they retry.
+	retryAfterSeconds = 1
+)
+
+var requestInfoFactory = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// longRunning are the subresources streaming for as long as their clients
+// like, which aren't limited.
+var longRunning = sets.NewString("log", "exec", "attach", "portforward", "proxy")
+
+// probes are the paths of the health checks and metrics, which aren't
+// limited either, lest a busy server be restarted for it.
+var probes = sets.NewString("/healthz", "/livez", "/readyz", "/metrics")
+
+// level serves the requests of a limited priority level.
+type level struct {
+	name string
+	// seats is how many requests it serves at once, and queueLength how
+	// many more may wait for a seat in each of its queues, none for levels
+	// rejecting them. Each flow is dealt handSize of the queues.
+	seats, queueLength, handSize int
+
+	lock sync.Mutex
+	// inflight is how many requests are being served.
+	inflight int
+	// queues hold the *waiter of each queued request, in order, and next is
+	// the first one the seat of a request served may go to.
+	queues []list.List
+	next   int
+	// waiting is how many requests the queues hold.
+	waiting int
+}
+
+// waiter is a queued request, whose ready channel is closed once it's
+// handed the seat of a request that was served.
+type waiter struct {
+	ready  chan struct{}
+	queue  int
+	handed bool
+}
+
+// WithPriorityAndFairness returns a handler serving no more than
+// maxInflight requests at once, each priority level of config up to its
+// share of them, after its assuredConcurrencyShares: the requests beyond
+// it wait for a seat, for up to 15 seconds, or are rejected with
+// TooManyRequests as the level's limitResponse says. The seats freed go to
+// the queued requests first, taking turns among the queues, and in order
+// within each. The requests of a flow, as the distinguisherMethod of their
+// FlowSchema tells them apart, by user or namespace, are queued in the
+// shortest of the queues shuffle sharding deals it, so that a busy flow only
+// delays the few others sharing its queues. The users are those the context
+// of the requests has, as authorization.WithAuthentication sets them, the
+// others being in the system:unauthenticated group.
+//
+// Watches, the streaming subresources of pods and the health checks aren't
+// limited. Nor is anything if config is nil or maxInflight isn't positive.
+func WithPriorityAndFairness(handler http.Handler, config *Config, maxInflight int) http.Handler {
+	if config == nil || maxInflight <= 0 {
+		return handler
+	}
+	metrics.Register()
+
+	var total int32
+	for _, l := range config.levels {
+		if l.Spec.Type == flowcontrolv1alpha1.PriorityLevelEnablementLimited {
+			total += l.Spec.Limited.AssuredConcurrencyShares
+		}
+	}
+	levels := map[string]*level{}
+	for name, l := range config.levels {
+		if l.Spec.Type != flowcontrolv1alpha1.PriorityLevelEnablementLimited {
+			continue
+		}
+		seats := int(math.Ceil(float64(maxInflight) * float64(l.Spec.Limited.AssuredConcurrencyShares) / float64(total)))
+		lv := &level{name: name, seats: seats}
+		if response := l.Spec.Limited.LimitResponse; response.Type == flowcontrolv1alpha1.LimitResponseTypeQueue {
+			queues, handSize := 1, 1
+			lv.queueLength = defaultQueueLengthLimit
+			if q := response.Queuing; q != nil && q.Queues > 0 && q.QueueLengthLimit > 0 {
+				queues, lv.queueLength = int(q.Queues), int(q.QueueLengthLimit)
+				if q.HandSize > 0 && q.HandSize <= q.Queues {
+					handSize = int(q.HandSize)
+				}
+			}
+			lv.queues, lv.handSize = make([]list.List, queues), handSize
+		}
+		levels[name] = lv
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := req.URL.Path
+		if prefix := logicalcluster.Path(""); strings.HasPrefix(path, prefix) {
+			rest := strings.TrimPrefix(path, prefix)
+			path = "/"
+			if i := strings.Index(rest, "/"); i >= 0 {
+				path = rest[i:]
+			}
+		}
+		infoReq := req.Clone(req.Context())
+		infoReq.URL.Path = path
+		info, err := requestInfoFactory.NewRequestInfo(infoReq)
+		if err != nil || info.Verb == "watch" || longRunning.Has(info.Subresource) || !info.IsResourceRequest && probes.Has(strings.TrimSuffix(path, "/")) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		u, ok := request.UserFrom(req.Context())
+		if !ok {
+			u = &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}}
+		}
+		name, flow, found := config.levelFor(u, info)
+		l := levels[name]
+		if !found || l == nil {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		l.serve(w, req, flow, handler)
+	})
+}
+
+// serve serves the request of the flow once it gets a seat, if it does: a
+// free one if no request is queued, else that of a request served.
+func (l *level) serve(w http.ResponseWriter, req *http.Request, flow string, handler http.Handler) {
+	l.lock.Lock()
+	if l.inflight < l.seats && l.waiting == 0 {
+		l.inflight++
+		l.lock.Unlock()
+		defer l.release()
+		handler.ServeHTTP(w, req)
+		return
+	}
+	if l.queueLength == 0 {
+		l.lock.Unlock()
+		l.reject(w, "the priority level is serving as many requests as it may")
+		return
+	}
+	queue := l.queueFor(flow)
+	if l.queues[queue].Len() >= l.queueLength {
+		l.lock.Unlock()
+		l.reject(w, "the queue of the priority level is full")
+		return
+	}
+	wt := &waiter{ready: make(chan struct{}), queue: queue}
+	e := l.queues[queue].PushBack(wt)
+	l.waiting++
+	l.lock.Unlock()
+
+	timer := time.NewTimer(queueTimeout)
+	defer timer.Stop()
+	select {
+	case <-wt.ready:
+	case <-timer.C:
+		if l.dequeue(e) {
+			l.reject(w, "the request waited too long in the queue of the priority level")
+			return
+		}
+	case <-req.Context().Done():
+		if !l.dequeue(e) {
+			l.release()
+		}
+		return
+	}
+	// Handed a seat, if only just as it gave up waiting.
+	defer l.release()
+	handler.ServeHTTP(w, req)
+}
+
+// queueFor returns the shortest of the queues the flow is dealt, the first
+// of them if several are. The hand is the same for each request of the flow,
+// and likely a different one for another flow. l.lock must be held.
+func (l *level) queueFor(flow string) int {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(flow))
+	hash := h.Sum64()
+	deck := make([]int, len(l.queues))
+	for i := range deck {
+		deck[i] = i
+	}
+	queue := -1
+	for i := 0; i < l.handSize; i++ {
+		left := uint64(len(deck) - i)
+		j := i + int(hash%left)
+		hash /= left
+		deck[i], deck[j] = deck[j], deck[i]
+		if queue < 0 || l.queues[deck[i]].Len() < l.queues[queue].Len() {
+			queue = deck[i]
+		}
+	}
+	return queue
+}
+
+// release frees the seat of a request served, handing it to the first
+// request of the next queue holding any, if any is queued.
+func (l *level) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i := range l.queues {
+		queue := (l.next + i) % len(l.queues)
+		front := l.queues[queue].Front()
+		if front == nil {
+			continue
+		}
+		wt := l.queues[queue].Remove(front).(*waiter)
+		l.waiting--
+		l.next = queue + 1
+		wt.handed = true
+		close(wt.ready)
+		return
+	}
+	l.inflight--
+}
+
+// dequeue removes a queued request that gives up waiting, unless it was
+// handed a seat already, and reports whether it removed it.
+func (l *level) dequeue(e *list.Element) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	wt := e.Value.(*waiter)
+	if wt.handed {
+		return false
+	}
+	l.queues[wt.queue].Remove(e)
+	l.waiting--
+	return true
+}
+
+func (l *level) reject(w http.ResponseWriter, reason string) {
+	metrics.RejectedRequests.WithLabelValues(l.name).Inc()
+	status := errors.NewTooManyRequests(fmt.Sprintf("%s: %s, please try again later", l.name, reason), retryAfterSeconds).ErrStatus
+	status.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	body, _ := json.Marshal(status)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprint(retryAfterSeconds))
+	w.WriteHeader(int(status.Code))
+	_, _ = w.Write(body)
+}
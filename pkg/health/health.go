@@ -0,0 +1,186 @@
+// Package health serves the /healthz, /livez and /readyz endpoints of the
+// kcp processes, for the probes of their deployments, and holds the checks
+// the controllers report their health with: whether the caches of their
+// informers have synced, for readiness, and whether their workers are making
+// progress, for liveness.
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// DefaultStuckTimeout is how long a worker may process a single key before
+// its controller is reported not live.
+const DefaultStuckTimeout = 10 * time.Minute
+
+// Checks are health checks added as the components of a process start, such
+// as controllers started once their leader election lease is acquired. The
+// zero value is ready to use, and passes.
+type Checks struct {
+	lock   sync.RWMutex
+	checks []healthz.HealthChecker
+}
+
+// Add adds the given checks.
+func (c *Checks) Add(checks ...healthz.HealthChecker) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checks = append(c.checks, checks...)
+}
+
+// list returns the checks added so far.
+func (c *Checks) list() []healthz.HealthChecker {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append([]healthz.HealthChecker(nil), c.checks...)
+}
+
+// Named returns a single check of the given name running every check added,
+// even those added after it's returned, and failing with the failures of
+// those that fail. It's for servers whose checks are fixed before they
+// start, like the kcp server.
+func (c *Checks) Named(name string) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(req *http.Request) error {
+		var failed []string
+		for _, check := range c.list() {
+			if err := check.Check(req); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", check.Name(), err))
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%s", strings.Join(failed, "; "))
+		}
+		return nil
+	})
+}
+
+// NamedReadyz is Named for readiness checks: it only runs them on /readyz,
+// and passes on /healthz and /livez. It's for servers that can only add
+// checks to all three, like the kcp server.
+func (c *Checks) NamedReadyz(name string) healthz.HealthChecker {
+	named := c.Named(name)
+	return healthz.NamedCheck(name, func(req *http.Request) error {
+		if !strings.Contains(req.URL.Path, "/readyz") {
+			return nil
+		}
+		return named.Check(req)
+	})
+}
+
+// Serve serves the checks at addr in the background, unless addr is empty:
+// those of live on /healthz and /livez, and those of both on /readyz.
+func Serve(addr string, live, ready *Checks) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", handler("healthz", live))
+	mux.Handle("/livez", handler("livez", live))
+	mux.Handle("/readyz", handler("readyz", live, ready))
+	go func() {
+		klog.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
+
+// handler returns a handler running the given checks, answering ok if they
+// all pass or 500 with the failures otherwise, and the result of every
+// check with ?verbose, as the Kubernetes API servers do.
+func handler(name string, checks ...*Checks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var all []healthz.HealthChecker
+		for _, c := range checks {
+			all = append(all, c.list()...)
+		}
+		sort.SliceStable(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+
+		var out bytes.Buffer
+		failed := false
+		for _, check := range all {
+			if err := check.Check(req); err != nil {
+				fmt.Fprintf(&out, "[-]%s failed: %v\n", check.Name(), err)
+				klog.V(2).Infof("%s check %s failed: %v", name, check.Name(), err)
+				failed = true
+			} else {
+				fmt.Fprintf(&out, "[+]%s ok\n", check.Name())
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if failed {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(&out, "%s check failed\n", name)
+			_, _ = out.WriteTo(w)
+			return
+		}
+		if _, verbose := req.URL.Query()["verbose"]; !verbose {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		fmt.Fprintf(&out, "%s check passed\n", name)
+		_, _ = out.WriteTo(w)
+	})
+}
+
+// CacheSyncCheck returns a check of the given name failing until the caches
+// whose HasSynced funcs are given have synced.
+func CacheSyncCheck(name string, synced ...cache.InformerSynced) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(*http.Request) error {
+		for _, s := range synced {
+			if !s() {
+				return fmt.Errorf("the caches of the informers haven't synced")
+			}
+		}
+		return nil
+	})
+}
+
+// InFlight tracks the keys the workers of a controller are processing, for
+// its liveness to fail once one has been processed for too long, as when a
+// worker is stuck. The zero value is ready to use.
+type InFlight struct {
+	lock    sync.Mutex
+	started map[string]time.Time
+}
+
+// Begin records that a worker started processing key.
+func (f *InFlight) Begin(key string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.started == nil {
+		f.started = map[string]time.Time{}
+	}
+	f.started[key] = time.Now()
+}
+
+// End records that the worker processing key is done with it.
+func (f *InFlight) End(key string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.started, key)
+}
+
+// Check returns a check of the given name failing while a key has been
+// processed for longer than timeout.
+func (f *InFlight) Check(name string, timeout time.Duration) healthz.HealthChecker {
+	return healthz.NamedCheck(name, func(*http.Request) error {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		for key, started := range f.started {
+			if d := time.Since(started); d > timeout {
+				return fmt.Errorf("a worker has been processing %s for %s", key, d.Round(time.Second))
+			}
+		}
+		return nil
+	})
+}
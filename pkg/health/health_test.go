@@ -0,0 +1,73 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/healthz"
+)
+
+func TestHandler(t *testing.T) {
+	var live, ready Checks
+	synced := false
+	live.Add(healthz.NamedCheck("deployment", func(*http.Request) error { return nil }))
+	ready.Add(CacheSyncCheck("deployment", func() bool { return synced }))
+	h := handler("readyz", &live, &ready)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	rec := get("/readyz")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "[-]deployment failed") {
+		t.Errorf("got %d before the caches synced: %s", rec.Code, rec.Body.String())
+	}
+	synced = true
+	if rec := get("/readyz"); rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("got %d once the caches synced: %s", rec.Code, rec.Body.String())
+	}
+	if rec := get("/readyz?verbose"); !strings.Contains(rec.Body.String(), "[+]deployment ok") {
+		t.Errorf("got verbose %s", rec.Body.String())
+	}
+
+	// Checks added later are run too.
+	live.Add(healthz.NamedCheck("cluster", func(*http.Request) error { return errors.New("stuck") }))
+	if rec := get("/readyz"); rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "[-]cluster failed: stuck") {
+		t.Errorf("got %d with a failing check: %s", rec.Code, rec.Body.String())
+	}
+	if err := live.Named("controllers").Check(nil); err == nil || err.Error() != "cluster: stuck" {
+		t.Errorf("got %v", err)
+	}
+
+	// Readiness checks only fail /readyz.
+	synced = false
+	caches := ready.NamedReadyz("controller-caches")
+	if err := caches.Check(httptest.NewRequest(http.MethodGet, "/livez", nil)); err != nil {
+		t.Errorf("failed /livez: %v", err)
+	}
+	if err := caches.Check(httptest.NewRequest(http.MethodGet, "/readyz/controller-caches", nil)); err == nil {
+		t.Error("passed /readyz before the caches synced")
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	var f InFlight
+	check := f.Check("deployment", time.Minute)
+	f.Begin("admin|default/web")
+	if err := check.Check(nil); err != nil {
+		t.Errorf("failed while the key is processed: %v", err)
+	}
+	f.started["admin|default/web"] = time.Now().Add(-2 * time.Minute)
+	if err := check.Check(nil); err == nil {
+		t.Error("passed while a worker is stuck")
+	}
+	f.End("admin|default/web")
+	if err := check.Check(nil); err != nil {
+		t.Errorf("failed once the key is done: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Package logging carries structured loggers through contexts, so that
+// everything logged while reconciling an object is tagged with its key,
+// workspace and, where relevant, Cluster.
+package logging
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2/klogr"
+)
+
+// Log keys shared by the controllers.
+const (
+	ObjectKey    = "key"
+	WorkspaceKey = "workspace"
+	ClusterKey   = "cluster"
+)
+
+type contextKey struct{}
+
+// New returns a logger writing through klog, named after the given component.
+func New(name string) logr.Logger {
+	return klogr.New().WithName(name)
+}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger logr.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or an unnamed klog logger if
+// there's none.
+func FromContext(ctx context.Context) logr.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(logr.Logger); ok {
+		return logger
+	}
+	return klogr.New()
+}
+
+// WithValues returns a copy of ctx whose logger has the given key/value pairs added.
+func WithValues(ctx context.Context, keysAndValues ...interface{}) (context.Context, logr.Logger) {
+	logger := FromContext(ctx).WithValues(keysAndValues...)
+	return NewContext(ctx, logger), logger
+}
@@ -0,0 +1,47 @@
+package logicalcluster
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Path returns the path under which kcp serves the named logical cluster.
+func Path(name string) string {
+	return "/clusters/" + name
+}
+
+// Config returns a copy of cfg, which reaches the admin logical cluster,
+// reaching the named logical cluster instead.
+func Config(cfg *rest.Config, name string) *rest.Config {
+	cfg = rest.CopyConfig(cfg)
+	cfg.Host = strings.TrimSuffix(cfg.Host, "/") + Path(name)
+	return cfg
+}
+
+// Kubeconfig returns a copy of config with a context named like the logical
+// cluster to reach it with. Unless config already has one, it's derived from
+// the current context, which must reach the admin logical cluster.
+func Kubeconfig(config clientcmdapi.Config, name string) (*clientcmdapi.Config, error) {
+	out := config.DeepCopy()
+	if _, exists := out.Contexts[name]; exists {
+		return out, nil
+	}
+	current, exists := out.Contexts[out.CurrentContext]
+	if !exists {
+		return nil, fmt.Errorf("no context with the name of the logical cluster %s, nor a current context to derive it from", name)
+	}
+	admin, exists := out.Clusters[current.Cluster]
+	if !exists {
+		return nil, fmt.Errorf("no cluster %s for the current context %s", current.Cluster, out.CurrentContext)
+	}
+	cluster := admin.DeepCopy()
+	cluster.Server = strings.TrimSuffix(cluster.Server, "/") + Path(name)
+	context := current.DeepCopy()
+	context.Cluster = name
+	out.Clusters[name] = cluster
+	out.Contexts[name] = context
+	return out, nil
+}
@@ -0,0 +1,188 @@
+// Package logicalcluster lets controllers that watch the objects of several
+// logical clusters (workspaces) at once tell them apart. Objects of
+// different logical clusters may share a namespace and name, so the keys
+// those controllers queue carry the logical cluster, and the listers they
+// build for a workspace only see the objects of that workspace.
+package logicalcluster
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// KeyIndex indexes objects by their Key.
+	KeyIndex = "logicalClusterKey"
+
+	// ClusterIndex indexes objects by the name of their logical cluster.
+	ClusterIndex = "logicalCluster"
+
+	separator = "|"
+)
+
+// Indexers are the indexes the informers whose indexers are passed to
+// NewIndexer or Scoped must have.
+var Indexers = cache.Indexers{
+	KeyIndex:     indexByKey,
+	ClusterIndex: indexByCluster,
+}
+
+// Key returns the key of the named object of the given logical cluster,
+// "<cluster>|<namespace>/<name>", or "<cluster>|<name>" if it's not
+// namespaced.
+func Key(cluster, namespace, name string) string {
+	if namespace == "" {
+		return cluster + separator + name
+	}
+	return cluster + separator + namespace + "/" + name
+}
+
+// KeyFunc returns the Key of an object, or of the last known state of a
+// deleted object.
+func KeyFunc(obj interface{}) (string, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return Key(m.GetClusterName(), m.GetNamespace(), m.GetName()), nil
+}
+
+// ClusterName returns the name of the logical cluster of an object, or of
+// the last known state of a deleted object.
+func ClusterName(obj interface{}) (string, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return m.GetClusterName(), nil
+}
+
+// SplitKey returns the logical cluster, namespace and name of a Key.
+func SplitKey(key string) (cluster, namespace, name string, err error) {
+	i := strings.Index(key, separator)
+	if i < 0 {
+		return "", "", "", fmt.Errorf("unexpected key format: %q has no logical cluster", key)
+	}
+	namespace, name, err = cache.SplitMetaNamespaceKey(key[i+len(separator):])
+	return key[:i], namespace, name, err
+}
+
+func indexByKey(obj interface{}) ([]string, error) {
+	key, err := KeyFunc(obj)
+	if err != nil {
+		return nil, err
+	}
+	return []string{key}, nil
+}
+
+func indexByCluster(obj interface{}) ([]string, error) {
+	cluster, err := ClusterName(obj)
+	if err != nil {
+		return nil, err
+	}
+	return []string{cluster}, nil
+}
+
+// NewIndexer returns the given indexer, but looking objects up by their Key
+// in GetByKey, for controllers that queue Keys.
+func NewIndexer(indexer cache.Indexer) cache.Indexer {
+	return &keyIndexer{Indexer: indexer}
+}
+
+// IndexerFor adds the Indexers to the informer, unless it already has them,
+// and returns its NewIndexer. It must be called before the informer is
+// started.
+func IndexerFor(informer cache.SharedIndexInformer) cache.Indexer {
+	if _, found := informer.GetIndexer().GetIndexers()[KeyIndex]; !found {
+		// The informer isn't started, and the indexes are new.
+		_ = informer.AddIndexers(Indexers)
+	}
+	return NewIndexer(informer.GetIndexer())
+}
+
+type keyIndexer struct {
+	cache.Indexer
+}
+
+func (i *keyIndexer) GetByKey(key string) (interface{}, bool, error) {
+	objs, err := i.Indexer.ByIndex(KeyIndex, key)
+	if err != nil || len(objs) == 0 {
+		return nil, false, err
+	}
+	return objs[0], true, nil
+}
+
+// Scoped returns a read-only view of the given indexer that only has the
+// objects of the given logical cluster, keyed as usual within it, to build
+// the listers of that workspace from.
+func Scoped(indexer cache.Indexer, cluster string) cache.Indexer {
+	return &scoped{Indexer: indexer, cluster: cluster}
+}
+
+type scoped struct {
+	cache.Indexer
+	cluster string
+}
+
+func (s *scoped) List() []interface{} {
+	objs, err := s.Indexer.ByIndex(ClusterIndex, s.cluster)
+	if err != nil {
+		return nil
+	}
+	return objs
+}
+
+func (s *scoped) ListKeys() []string {
+	var keys []string
+	for _, obj := range s.List() {
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (s *scoped) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.GetByKey(key)
+}
+
+func (s *scoped) GetByKey(key string) (interface{}, bool, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	return NewIndexer(s.Indexer).GetByKey(Key(s.cluster, namespace, name))
+}
+
+func (s *scoped) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	objs, err := s.Indexer.Index(indexName, obj)
+	return s.filter(objs), err
+}
+
+func (s *scoped) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	objs, err := s.Indexer.ByIndex(indexName, indexedValue)
+	return s.filter(objs), err
+}
+
+func (s *scoped) filter(objs []interface{}) []interface{} {
+	var in []interface{}
+	for _, obj := range objs {
+		if m, err := meta.Accessor(obj); err == nil && m.GetClusterName() == s.cluster {
+			in = append(in, obj)
+		}
+	}
+	return in
+}
@@ -0,0 +1,70 @@
+package logicalcluster
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	appsv1lister "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func deployment(cluster, name string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{ClusterName: cluster, Namespace: "default", Name: name},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func TestScoped(t *testing.T) {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, fn := range Indexers {
+		indexers[name] = fn
+	}
+	indexer := cache.NewIndexer(KeyFunc, indexers)
+	for _, d := range []*appsv1.Deployment{
+		deployment("tenant-a", "web", 1),
+		deployment("tenant-b", "web", 2),
+		deployment("tenant-b", "db", 3),
+	} {
+		if err := indexer.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		cluster  string
+		count    int
+		replicas int32
+	}{
+		{cluster: "tenant-a", count: 1, replicas: 1},
+		{cluster: "tenant-b", count: 2, replicas: 2},
+	} {
+		lister := appsv1lister.NewDeploymentLister(Scoped(indexer, tc.cluster))
+		all, err := lister.Deployments("default").List(labels.Everything())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != tc.count {
+			t.Errorf("%s: listed %d deployments, want %d", tc.cluster, len(all), tc.count)
+		}
+		web, err := lister.Deployments("default").Get("web")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.cluster, err)
+		}
+		if *web.Spec.Replicas != tc.replicas {
+			t.Errorf("%s: got the web deployment with %d replicas, want %d", tc.cluster, *web.Spec.Replicas, tc.replicas)
+		}
+
+		key := Key(tc.cluster, "default", "web")
+		obj, exists, err := NewIndexer(indexer).GetByKey(key)
+		if err != nil || !exists || obj.(*appsv1.Deployment).ClusterName != tc.cluster {
+			t.Errorf("GetByKey(%q) = %v, %v, %v", key, obj, exists, err)
+		}
+		cluster, namespace, name, err := SplitKey(key)
+		if err != nil || cluster != tc.cluster || namespace != "default" || name != "web" {
+			t.Errorf("SplitKey(%q) = %q, %q, %q, %v", key, cluster, namespace, name, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package metrics defines the Prometheus metrics shared by the controllers
+// and serves them, along with the workqueue metrics, on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	// Registers depth, adds, latency and retries metrics for named workqueues.
+	_ "k8s.io/component-base/metrics/prometheus/workqueue"
+)
+
+const (
+	namespace = "kcp"
+	subsystem = "controller"
+)
+
+// Results of a single reconcile, as recorded by ReconcileTotal.
+const (
+	ResultSuccess = "success"
+	ResultRetry   = "retry"
+	ResultDropped = "dropped"
+)
+
+// Priorities of the keys of a workqueue, as recorded by QueueDepth.
+const (
+	PriorityHigh = "high"
+	PriorityLow  = "low"
+)
+
+var (
+	// ReconcileDuration is how long handling a single workqueue key took.
+	ReconcileDuration = metrics.NewHistogramVec(&metrics.HistogramOpts{
+		Namespace:      namespace,
+		Subsystem:      subsystem,
+		Name:           "reconcile_duration_seconds",
+		Help:           "Time taken to reconcile a single workqueue key, by controller and whether it succeeded.",
+		Buckets:        metrics.ExponentialBuckets(0.001, 2, 15),
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"controller", "success"})
+
+	// ReconcileTotal counts reconciles by controller and result.
+	ReconcileTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Namespace:      namespace,
+		Subsystem:      subsystem,
+		Name:           "reconcile_total",
+		Help:           "Number of reconciles, by controller and result: success, retry or dropped after too many retries.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"controller", "result"})
+
+	// SyncErrors counts failed writes of objects meant for a given Cluster.
+	SyncErrors = metrics.NewCounterVec(&metrics.CounterOpts{
+		Namespace:      namespace,
+		Subsystem:      subsystem,
+		Name:           "cluster_sync_errors_total",
+		Help:           "Number of errors creating, updating or deleting objects for a Cluster, by controller and Cluster.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"controller", "cluster"})
+
+	// SyncDrift counts synced objects found changed on their Cluster.
+	SyncDrift = metrics.NewCounterVec(&metrics.CounterOpts{
+		Namespace:      namespace,
+		Subsystem:      subsystem,
+		Name:           "cluster_sync_drift_total",
+		Help:           "Number of times the syncer found an object changed on its Cluster behind its back, by Cluster and how it was handled: revert, report or adopt.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"cluster", "mode"})
+
+	// QueueDepth is how many keys of each priority wait to be reconciled.
+	QueueDepth = metrics.NewGaugeVec(&metrics.GaugeOpts{
+		Namespace:      namespace,
+		Subsystem:      subsystem,
+		Name:           "workqueue_priority_depth",
+		Help:           "Number of keys waiting to be reconciled, by controller and priority: high for changes to the objects themselves, in the workqueue, or low for resyncs and rebalancing, waiting for room in it.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"controller", "priority"})
+
+	// RejectedRequests counts the requests the kcp server rejected as their
+	// priority level was serving as many as it may.
+	RejectedRequests = metrics.NewCounterVec(&metrics.CounterOpts{
+		Namespace:      namespace,
+		Subsystem:      "flowcontrol",
+		Name:           "rejected_requests_total",
+		Help:           "Number of requests rejected with TooManyRequests, by priority level, as the level and its queue were full or they waited too long in it.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"priority_level"})
+
+	// WorkspaceObjects is how many objects of each resource a workspace has.
+	WorkspaceObjects = metrics.NewGaugeVec(&metrics.GaugeOpts{
+		Namespace:      namespace,
+		Subsystem:      "workspace",
+		Name:           "objects",
+		Help:           "Number of objects of a workspace, by workspace and resource, for the resources the usage controller counts.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"workspace", "resource"})
+
+	// WorkspaceStorageEstimatedBytes estimates how many bytes the objects of
+	// a workspace take from the size of their JSON encoding, rather than
+	// measuring what etcd stores.
+	WorkspaceStorageEstimatedBytes = metrics.NewGaugeVec(&metrics.GaugeOpts{
+		Namespace:      namespace,
+		Subsystem:      "workspace",
+		Name:           "storage_estimated_bytes",
+		Help:           "Estimated number of bytes the objects of a workspace the usage controller counts take in etcd, by workspace, from the size of their JSON encoding.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"workspace"})
+
+	// WorkspaceWatches is how many watches of a workspace the kcp server
+	// serves.
+	WorkspaceWatches = metrics.NewGaugeVec(&metrics.GaugeOpts{
+		Namespace:      namespace,
+		Subsystem:      "workspace",
+		Name:           "watches",
+		Help:           "Number of watches the kcp server serves, by workspace they watch.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"workspace"})
+)
+
+var registerOnce sync.Once
+
+// Register registers the metrics with the legacy registry, which is also the
+// one served by the kcp server. It's safe to call from every controller.
+func Register() {
+	registerOnce.Do(func() {
+		legacyregistry.MustRegister(ReconcileDuration, ReconcileTotal, SyncErrors, SyncDrift, QueueDepth, RejectedRequests, WorkspaceObjects, WorkspaceStorageEstimatedBytes, WorkspaceWatches)
+	})
+}
+
+// ObserveReconcile records the duration of a reconcile that started at start.
+func ObserveReconcile(controller string, start time.Time, err error) {
+	success := "true"
+	if err != nil {
+		success = "false"
+	}
+	ReconcileDuration.WithLabelValues(controller, success).Observe(time.Since(start).Seconds())
+}
+
+// Serve serves the registered metrics on /metrics at addr in the background,
+// unless addr is empty.
+func Serve(addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", legacyregistry.Handler())
+	go func() {
+		klog.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
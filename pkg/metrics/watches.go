@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// clustersPrefix is the prefix of the paths the logical clusters are served
+// under, as logicalcluster.Path returns them.
+const clustersPrefix = "/clusters/"
+
+// WithWatchCounts returns a handler recording how many watches of each
+// workspace handler serves, in WorkspaceWatches. Requests to no logical
+// cluster are counted as those of defaultWorkspace. The workspaces no
+// longer watched are left out, rather than reported with none, so that the
+// requests to made-up workspaces don't add to the metric for good.
+func WithWatchCounts(handler http.Handler, defaultWorkspace string) http.Handler {
+	Register()
+	var lock sync.Mutex
+	watches := map[string]int{}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !isWatch(req) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		workspace := workspaceOf(req.URL.Path, defaultWorkspace)
+		lock.Lock()
+		watches[workspace]++
+		WorkspaceWatches.WithLabelValues(workspace).Set(float64(watches[workspace]))
+		lock.Unlock()
+		defer func() {
+			lock.Lock()
+			defer lock.Unlock()
+			if watches[workspace]--; watches[workspace] > 0 {
+				WorkspaceWatches.WithLabelValues(workspace).Set(float64(watches[workspace]))
+				return
+			}
+			delete(watches, workspace)
+			WorkspaceWatches.Delete(map[string]string{"workspace": workspace})
+		}()
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// isWatch reports whether the request is a watch, as those of the
+// informers are, or one of the deprecated /watch/ paths.
+func isWatch(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	switch req.URL.Query().Get("watch") {
+	case "true", "1":
+		return true
+	}
+	return strings.Contains(req.URL.Path, "/watch/")
+}
+
+// workspaceOf returns the name of the logical cluster of the given path.
+func workspaceOf(path, defaultWorkspace string) string {
+	if !strings.HasPrefix(path, clustersPrefix) {
+		return defaultWorkspace
+	}
+	name := strings.TrimPrefix(path, clustersPrefix)
+	if i := strings.Index(name, "/"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,243 @@
+// Package pki bootstraps the certificates of a kcp server on its first
+// start: a self-signed CA, kept in its data directory, the serving
+// certificate it signs, and the client certificates of the kubeconfigs the
+// server writes, so that the clients verify the server, and are
+// authenticated, without any further setup, as kind does for its clusters.
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+const (
+	caCertFile      = "ca.crt"
+	caKeyFile       = "ca.key"
+	servingCertFile = "serving.crt"
+	servingKeyFile  = "serving.key"
+
+	// caValidity is how long the CA is valid for. It isn't renewed: once it
+	// expires, it's deleted for a new one to be generated.
+	caValidity = 10 * 365 * 24 * time.Hour
+	// servingValidity is how long the serving certificates are valid for.
+	// They're renewed on start once they're valid for less than a
+	// tenth of that.
+	servingValidity = 365 * 24 * time.Hour
+)
+
+// CA is the certificate authority of a kcp server.
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+	// CertFile is the path of the CA's certificate, for the server to
+	// authenticate the clients it signed the certificates of.
+	CertFile string
+}
+
+// EnsureCA returns the CA kept in dir, generating it first if there's none.
+func EnsureCA(dir string) (*CA, error) {
+	certFile, keyFile := filepath.Join(dir, caCertFile), filepath.Join(dir, caKeyFile)
+	certs, key, err := load(certFile, keyFile)
+	if err == nil {
+		if time.Now().After(certs[0].NotAfter) {
+			return nil, fmt.Errorf("the CA of %s expired on %s; delete it, and the certificates it signed, for a new one to be generated", certFile, certs[0].NotAfter)
+		}
+		return &CA{Cert: certs[0], Key: key, CertFile: certFile}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err = newKey()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetInt64(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: "kcp-ca"},
+		NotBefore:             now.Add(-time.Hour).UTC(),
+		NotAfter:              now.Add(caValidity).UTC(),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	if err := write(certFile, keyFile, ca, key); err != nil {
+		return nil, err
+	}
+	return &CA{Cert: ca, Key: key, CertFile: certFile}, nil
+}
+
+// CertPEM returns the PEM of the CA's certificate, for clients to verify the
+// server with.
+func (ca *CA) CertPEM() []byte {
+	return encodeCertPEM(ca.Cert)
+}
+
+// EnsureServingCert returns the files of the serving certificate kept in
+// dir, for the given host names and IP addresses, signed by the CA. It's
+// generated first if there's none, and replaced if it's about to expire,
+// wasn't signed by the CA, or doesn't cover every host.
+func (ca *CA) EnsureServingCert(dir string, hosts []string) (certFile, keyFile string, err error) {
+	certFile, keyFile = filepath.Join(dir, servingCertFile), filepath.Join(dir, servingKeyFile)
+	certs, _, err := load(certFile, keyFile)
+	if err == nil && ca.current(certs[0], servingValidity/10) && covers(certs[0], hosts) {
+		return certFile, keyFile, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return "", "", err
+	}
+	template := ca.template(hosts[0], nil, servingValidity)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	serving, err := ca.sign(template, key)
+	if err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, write(certFile, keyFile, serving, key)
+}
+
+// ClientCert returns the PEM of a new client certificate signed by the CA,
+// and of its key, authenticating as the given user, in the given groups, for
+// validity.
+func (ca *CA) ClientCert(user string, groups []string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := newKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	template := ca.template(user, groups, validity)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client, err := ca.sign(template, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err = keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCertPEM(client), keyPEM, nil
+}
+
+// template returns the template of a certificate the CA signs.
+func (ca *CA) template(commonName string, organizations []string, validity time.Duration) *x509.Certificate {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: organizations},
+		NotBefore:    now.Add(-time.Hour).UTC(),
+		NotAfter:     now.Add(validity).UTC(),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+	}
+}
+
+func (ca *CA) sign(template *x509.Certificate, key crypto.Signer) (*x509.Certificate, error) {
+	if template.NotAfter.After(ca.Cert.NotAfter) {
+		template.NotAfter = ca.Cert.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// current reports whether the certificate was signed by the CA and is valid
+// for longer than margin.
+func (ca *CA) current(c *x509.Certificate, margin time.Duration) bool {
+	if !bytes.Equal(c.RawIssuer, ca.Cert.RawSubject) || c.CheckSignatureFrom(ca.Cert) != nil {
+		return false
+	}
+	return time.Now().Add(margin).Before(c.NotAfter)
+}
+
+// covers reports whether the certificate is valid for every host.
+func covers(c *x509.Certificate, hosts []string) bool {
+	for _, h := range hosts {
+		if c.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func newKey() (crypto.Signer, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// load reads a certificate and its key, failing with an os.IsNotExist error
+// if either is missing.
+func load(certFile, keyFile string) ([]*x509.Certificate, crypto.Signer, error) {
+	certs, err := cert.CertsFromFile(certFile)
+	if err != nil {
+		if _, statErr := os.Stat(certFile); os.IsNotExist(statErr) {
+			return nil, nil, statErr
+		}
+		return nil, nil, err
+	}
+	parsed, err := keyutil.PrivateKeyFromFile(keyFile)
+	if err != nil {
+		if _, statErr := os.Stat(keyFile); os.IsNotExist(statErr) {
+			return nil, nil, statErr
+		}
+		return nil, nil, err
+	}
+	key, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s isn't a signing key", keyFile)
+	}
+	return certs, key, nil
+}
+
+// write writes a certificate and its key, readable only by the user.
+func write(certFile, keyFile string, c *x509.Certificate, key crypto.Signer) error {
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(certFile, encodeCertPEM(c), 0600)
+}
+
+// encodeCertPEM returns the PEM of a certificate.
+func encodeCertPEM(c *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: cert.CertificateBlockType, Bytes: c.Raw})
+}
@@ -0,0 +1,73 @@
+package pki
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/cert"
+)
+
+func TestEnsureCA(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := EnsureCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := EnsureCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Cert.Equal(ca.Cert) {
+		t.Error("generated another CA on the second start")
+	}
+
+	hosts := []string{"localhost", "127.0.0.1"}
+	certFile, _, err := ca.EnsureServingCert(dir, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serving := readCert(t, certFile)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	for _, h := range hosts {
+		if _, err := serving.Verify(x509.VerifyOptions{DNSName: h, Roots: roots}); err != nil {
+			t.Errorf("%s: %v", h, err)
+		}
+	}
+	if _, _, err := ca.EnsureServingCert(dir, hosts); err != nil {
+		t.Fatal(err)
+	}
+	if !readCert(t, certFile).Equal(serving) {
+		t.Error("replaced a current serving certificate")
+	}
+	if _, _, err := ca.EnsureServingCert(dir, append(hosts, "kcp.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if err := readCert(t, certFile).VerifyHostname("kcp.example.com"); err != nil {
+		t.Errorf("didn't replace the serving certificate for a new host: %v", err)
+	}
+
+	certPEM, _, err := ca.ClientCert("kcp-admin", []string{"system:masters"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := cert.ParseCertsPEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Error(err)
+	}
+	if certs[0].Subject.CommonName != "kcp-admin" || certs[0].Subject.Organization[0] != "system:masters" {
+		t.Errorf("got subject %s", certs[0].Subject)
+	}
+}
+
+func readCert(t *testing.T, path string) *x509.Certificate {
+	certs, err := cert.CertsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certs[0]
+}
@@ -0,0 +1,200 @@
+// Package podproxy forwards the requests for the logs of the pods of kcp,
+// and to exec into, attach to and port-forward to them, to the physical
+// clusters they run on.
+//
+// kcp doesn't run pods itself: those synced to a Cluster, labeled with its
+// name, and those the syncers mirror from theirs run there. WithPodProxy
+// finds the Cluster of each pod and proxies its subresource requests to the
+// Cluster's API server, for kubectl logs, exec and port-forward to work
+// against kcp as they would against the cluster.
+package podproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	"github.com/kcp-dev/kcp/pkg/tunnel"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/apimachinery/pkg/util/sets"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// clusterLabel selects the objects synced to a Cluster, by its name.
+	clusterLabel = "cluster"
+	// upsyncedFromLabel is set by the syncer on the objects it mirrors from
+	// its Cluster, to the Cluster's name.
+	upsyncedFromLabel = "experimental.kcp.dev/upsynced-from"
+)
+
+// subresources are those of pods served by the kubelets of their cluster.
+var subresources = sets.NewString("log", "exec", "attach", "portforward")
+
+var requestInfoFactory = &genericapirequest.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// WithPodProxy returns a handler proxying the requests for the log, exec,
+// attach and portforward subresources of the pods of a Cluster to it, and
+// passing the other requests on to handler. The pods and Clusters are
+// looked up with loopback, which reaches the admin logical cluster, for the
+// users authorization.WithAuthentication authenticates; the cluster is reached with the Cluster's
+// kubeconfig, or through the tunnel its syncer opened to tunnels, if any, for
+// the clusters kcp can't reach. The requests to the logical clusters under
+// logicalcluster.Path are for the pods of that workspace, and the others for
+// those of defaultWorkspace.
+func WithPodProxy(handler http.Handler, loopback *rest.Config, tunnels *tunnel.Server, defaultWorkspace string) (http.Handler, error) {
+	kubeClient, err := kubernetes.NewForConfig(loopback)
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := clusterclient.NewForConfig(loopback)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.New("podproxy")
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		workspace, path := defaultWorkspace, req.URL.Path
+		if prefix := logicalcluster.Path(""); strings.HasPrefix(path, prefix) {
+			rest := strings.TrimPrefix(path, prefix)
+			i := strings.Index(rest, "/")
+			if i < 0 {
+				handler.ServeHTTP(w, req)
+				return
+			}
+			workspace, path = rest[:i], rest[i:]
+		}
+		infoReq := req.Clone(req.Context())
+		infoReq.URL.Path = path
+		info, err := requestInfoFactory.NewRequestInfo(infoReq)
+		if err != nil || !info.IsResourceRequest || info.APIGroup != "" || info.Resource != "pods" || !subresources.Has(info.Subresource) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		if _, ok := genericapirequest.UserFrom(req.Context()); !ok {
+			writeError(w, errors.NewUnauthorized("the pods of clusters are only reached by authenticated users"))
+			return
+		}
+
+		ctx := genericapirequest.WithCluster(req.Context(), genericapirequest.Cluster{Name: workspace})
+		log := logger.WithValues(logging.WorkspaceKey, workspace, logging.ObjectKey, info.Namespace+"/"+info.Name, "subresource", info.Subresource)
+		location, rt, err := podLocation(ctx, kubeClient, clusters, tunnels, workspace, info.Namespace, info.Name, info.Subresource)
+		if err != nil {
+			log.V(2).Info("Not proxying pod request", "reason", err.Error())
+			if status, ok := err.(errors.APIStatus); ok {
+				writeError(w, status)
+			} else {
+				writeError(w, errors.NewServiceUnavailable(err.Error()))
+			}
+			return
+		}
+		log.V(4).Info("Proxying pod request", "location", location.Host)
+
+		out := req.Clone(req.Context())
+		// The credentials of kcp mean nothing to the cluster, and mustn't
+		// leak to it; the Cluster's, or its syncer's, are used instead.
+		out.Header.Del("Authorization")
+		for k := range out.Header {
+			if strings.HasPrefix(k, "Impersonate-") {
+				out.Header.Del(k)
+			}
+		}
+		h := proxy.NewUpgradeAwareHandler(location, rt.transport, false, false, responder{})
+		h.UpgradeTransport = rt.upgrade
+		h.ServeHTTP(w, out)
+	}), nil
+}
+
+// roundTrippers reach a cluster, for plain requests and for upgraded ones,
+// like those of exec and port-forward.
+type roundTrippers struct {
+	transport http.RoundTripper
+	upgrade   proxy.UpgradeRequestRoundTripper
+}
+
+// podLocation returns the URL of the subresource of a pod of the workspace
+// in its Cluster, and how to reach it. The errors returned are API errors
+// for the pods whose Cluster isn't known.
+func podLocation(ctx context.Context, kubeClient kubernetes.Interface, clusters clusterclient.Interface, tunnels *tunnel.Server, workspace, namespace, name, subresource string) (*url.URL, roundTrippers, error) {
+	pod, err := kubeClient.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, roundTrippers{}, err
+	}
+	clusterName := pod.Labels[upsyncedFromLabel]
+	if clusterName == "" {
+		clusterName = pod.Labels[clusterLabel]
+	}
+	if clusterName == "" {
+		return nil, roundTrippers{}, errors.NewBadRequest(fmt.Sprintf("pod %s isn't placed on a cluster", name))
+	}
+	cl, err := clusters.ClusterV1alpha1().Clusters().Get(ctx, clusterName, metav1.GetOptions{})
+	if err != nil {
+		return nil, roundTrippers{}, err
+	}
+	// Mirrored pods are in the namespace of kcp their namespace is mapped
+	// from, so both kinds are mapped back the same way.
+	downstreamNamespace, err := cluster.DownstreamNamespace(cl, namespace)
+	if err != nil {
+		return nil, roundTrippers{}, err
+	}
+	subresourcePath := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/%s", downstreamNamespace, name, subresource)
+
+	if dial := tunnels.Dialer(workspace, clusterName); dial != nil {
+		// Upgraded connections are dialed with the transport's dialer too,
+		// and each stream is closed once its request is done.
+		rt := &http.Transport{DialContext: dial, DisableKeepAlives: true}
+		return &url.URL{Scheme: "http", Host: clusterName, Path: subresourcePath}, roundTrippers{transport: rt}, nil
+	}
+	kubeconfig, err := cluster.KubeConfigFor(ctx, kubeClient, cl)
+	if err != nil {
+		return nil, roundTrippers{}, err
+	}
+	if kubeconfig == "" {
+		return nil, roundTrippers{}, fmt.Errorf("cluster %s can't be reached from kcp, and has no tunnel open", clusterName)
+	}
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return nil, roundTrippers{}, fmt.Errorf("invalid kubeconfig of cluster %s: %w", clusterName, err)
+	}
+	location, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, roundTrippers{}, err
+	}
+	location.Path = strings.TrimSuffix(location.Path, "/") + subresourcePath
+	rt, upgrade, err := tunnel.TransportsFor(cfg)
+	if err != nil {
+		return nil, roundTrippers{}, err
+	}
+	return location, roundTrippers{transport: rt, upgrade: upgrade}, nil
+}
+
+// responder writes the errors reaching a cluster as API errors.
+type responder struct{}
+
+func (responder) Error(w http.ResponseWriter, req *http.Request, err error) {
+	writeError(w, errors.NewServiceUnavailable(fmt.Sprintf("error reaching the cluster: %v", err)))
+}
+
+// writeError fails the request with the Status of err.
+func writeError(w http.ResponseWriter, err errors.APIStatus) {
+	status := err.Status()
+	status.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	body, _ := json.Marshal(status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	_, _ = w.Write(body)
+}
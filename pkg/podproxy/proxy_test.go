@@ -0,0 +1,126 @@
+package podproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/authorization"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/rest"
+)
+
+func TestWithPodProxy(t *testing.T) {
+	var gotPath, gotAuthorization string
+	// Only clusters served over TLS are given the kubeconfig's credentials.
+	physical := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotPath, gotAuthorization = req.URL.Path+"?"+req.URL.RawQuery, req.Header.Get("Authorization")
+		fmt.Fprint(w, "hello from us-east")
+	}))
+	defer physical.Close()
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters: [{name: us-east, cluster: {server: %q, insecure-skip-tls-verify: true}}]
+users: [{name: us-east, user: {token: cluster-token}}]
+contexts: [{name: us-east, context: {cluster: us-east, user: us-east}}]
+current-context: us-east
+`, physical.URL)
+	kcp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch strings.TrimPrefix(req.URL.Path, "/clusters/admin") {
+		case "/api/v1/namespaces/web/pods/web-1":
+			fmt.Fprint(w, `{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web-1","namespace":"web","labels":{"cluster":"us-east"}}}`)
+		case "/apis/cluster.example.dev/v1alpha1/clusters/us-east":
+			cluster := map[string]interface{}{
+				"kind":       "Cluster",
+				"apiVersion": "cluster.example.dev/v1alpha1",
+				"metadata": map[string]interface{}{
+					"name":        "us-east",
+					"annotations": map[string]string{"experimental.kcp.dev/namespace-mapping": "web=team-web"},
+				},
+				"spec": map[string]interface{}{"kubeconfig": kubeconfig},
+			}
+			_ = json.NewEncoder(w).Encode(cluster)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+		}
+	}))
+	defer kcp.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "served by kcp")
+	})
+	authn := authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+		if req.Header.Get("Authorization") != "Bearer developer" {
+			return nil, false, nil
+		}
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "developer"}}, true, nil
+	})
+	proxy, err := WithPodProxy(next, &rest.Config{Host: kcp.URL}, nil, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := authorization.WithAuthentication(proxy, authn)
+
+	for _, tc := range []struct {
+		name, path, token string
+		wantStatus        int
+		wantBody          string
+	}{{
+		name:       "pod",
+		path:       "/api/v1/namespaces/web/pods/web-1",
+		token:      "developer",
+		wantStatus: http.StatusOK,
+		wantBody:   "served by kcp",
+	}, {
+		name:       "logs",
+		path:       "/clusters/admin/api/v1/namespaces/web/pods/web-1/log?container=web",
+		token:      "developer",
+		wantStatus: http.StatusOK,
+		wantBody:   "hello from us-east",
+	}, {
+		name:       "unauthenticated",
+		path:       "/api/v1/namespaces/web/pods/web-1/log",
+		wantStatus: http.StatusUnauthorized,
+	}, {
+		name:       "unknown pod",
+		path:       "/api/v1/namespaces/web/pods/other/log",
+		token:      "developer",
+		wantStatus: http.StatusNotFound,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			gotPath, gotAuthorization = "", ""
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+			}
+			if tc.wantBody != "" && rec.Body.String() != tc.wantBody {
+				t.Errorf("got body %q", rec.Body.String())
+			}
+		})
+	}
+	if gotPath != "" {
+		t.Errorf("the last request reached the cluster at %s", gotPath)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/web/pods/web-1/log?container=web", nil)
+	req.Header.Set("Authorization", "Bearer developer")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if gotPath != "/api/v1/namespaces/team-web/pods/web-1/log?container=web" {
+		t.Errorf("reached the cluster at %s", gotPath)
+	}
+	if gotAuthorization != "Bearer cluster-token" {
+		t.Errorf("reached the cluster with %q", gotAuthorization)
+	}
+}
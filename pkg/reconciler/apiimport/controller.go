@@ -0,0 +1,253 @@
+// Package apiimport imports the APIs of the physical clusters into kcp: once
+// a Cluster is registered, it discovers the API groups the cluster serves and
+// imports the schemas of their resources into the Cluster's logical cluster,
+// where the negotiation controller publishes them as CRDs, so that users can
+// create those resources there and have them synced down to the cluster.
+package apiimport
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/crdpuller"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	clusterreconciler "github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/negotiation"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	resyncPeriod = 10 * time.Hour
+
+	// pollInterval is how often the APIs of each Cluster are discovered
+	// again, to pick up the CRDs installed on it since.
+	pollInterval = 10 * time.Minute
+)
+
+// ParseGroups parses a comma-separated list of API groups, in which "core"
+// stands for the core group and "*" for every group.
+func ParseGroups(s string) []string {
+	var groups []string
+	for _, g := range strings.Split(s, ",") {
+		switch g = strings.TrimSpace(g); g {
+		case "":
+		case "core":
+			groups = append(groups, "")
+		default:
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// NewController returns a new Controller which imports the resources of the
+// given API groups, "" being the core group and "*" every group, from the
+// Clusters it can reach. Resources kcp itself serves are left alone, and the
+// others are imported like those the cluster controller pulls.
+func NewController(cfg *rest.Config, groups []string, shared *base.Informers) *Controller {
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	client := clusterclient.NewForConfigOrDie(cfg)
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	csif := shared.Cluster
+
+	c := &Controller{
+		client:     client.ClusterV1alpha1(),
+		indexer:    logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Clusters().Informer()),
+		kubeClient: kubeClient,
+		crdClient:  apiextensionsv1client.NewForConfigOrDie(cfg),
+		discovery:  discovery.NewDiscoveryClientForConfigOrDie(cfg),
+		groups:     groups,
+	}
+	c.Controller = base.New("apiimport", kubeClient, nil, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(csif.Cluster().V1alpha1().Clusters().Informer().HasSynced)
+	stopCh := c.StopCh()
+
+	csif.Cluster().V1alpha1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(oldObj, obj interface{}) {
+			old, cl := oldObj.(*v1alpha1.Cluster), obj.(*v1alpha1.Cluster)
+			// Import again once the Cluster has new credentials or becomes Ready.
+			if !equality.Semantic.DeepEqual(old.Spec.KubeConfig, cl.Spec.KubeConfig) ||
+				!equality.Semantic.DeepEqual(old.Spec.KubeConfigSecretRef, cl.Spec.KubeConfigSecretRef) ||
+				old.Status.Conditions.IsReady() != cl.Status.Conditions.IsReady() {
+				c.Enqueue(obj)
+			}
+		},
+	})
+	if own {
+		csif.Start(stopCh)
+	}
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	client     clusterv1alpha1.ClusterV1alpha1Interface
+	indexer    cache.Indexer
+	kubeClient kubernetes.Interface
+	crdClient  apiextensionsv1client.ApiextensionsV1Interface
+	discovery  discovery.DiscoveryInterface
+	groups     []string
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*v1alpha1.Cluster).DeepCopy()
+	previous := current.DeepCopy()
+
+	ctx, _ = logging.WithValues(ctx, logging.ClusterKey, current.Name)
+	if err := c.reconcile(ctx, current); err != nil {
+		return err
+	}
+	c.Queue().AddAfter(key, pollInterval)
+
+	// If the object being reconciled changed as a result, update it.
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (runtime.Object, error) {
+				return c.client.Clusters().Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+				return c.client.Clusters().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) reconcile(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	logger := logging.FromContext(ctx)
+	logicalClusterContext := genericapirequest.WithCluster(ctx, genericapirequest.Cluster{
+		Name: cluster.GetClusterName(),
+	})
+
+	// The cluster controller reports on the Clusters kcp can't reach, and on
+	// missing or invalid kubeconfigs.
+	if cluster.Spec.KubeConfig == "" && cluster.Spec.KubeConfigSecretRef == nil {
+		logger.V(2).Info("Cluster has no kubeconfig, not importing its APIs")
+		return nil
+	}
+	kubeconfig, err := clusterreconciler.KubeConfigFor(logicalClusterContext, c.kubeClient, cluster)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return nil
+	}
+
+	puller, err := crdpuller.NewSchemaPuller(cfg)
+	if err != nil {
+		logger.Error(err, "Error discovering cluster APIs")
+		conditions.MarkFalse(cluster, v1alpha1.ClusterConditionAPIsImported, "ErrorDiscoveringAPIs", fmt.Sprintf("Error discovering the APIs of cluster %s: %v", cluster.Name, err))
+		return nil
+	}
+	crds, err := puller.PullGroupCRDs(ctx, c.groups...)
+	if err != nil {
+		logger.Error(err, "Error pulling CRDs")
+		conditions.MarkFalse(cluster, v1alpha1.ClusterConditionAPIsImported, "ErrorPullingResourceSchemas", fmt.Sprintf("Error pulling API Resource Schemas from cluster %s: %v", cluster.Name, err))
+		return nil
+	}
+	native, err := c.served(logicalClusterContext)
+	if err != nil {
+		return err
+	}
+	for name := range crds {
+		if native.Has(name) {
+			delete(crds, name)
+		}
+	}
+	if err := negotiation.Import(logicalClusterContext, c.client, cluster, crds); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(crds))
+	for name := range crds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	logger.V(2).Info("Imported cluster APIs", "resources", names)
+	conditions.MarkTrue(cluster, v1alpha1.ClusterConditionAPIsImported, "APIsImported", fmt.Sprintf("Imported %d resources: %s", len(names), strings.Join(names, ", ")))
+	return nil
+}
+
+// served returns the names, as CRD names, of the resources kcp serves in the
+// logical cluster of the context other than through CRDs. Those aren't
+// imported, not to shadow them.
+func (c *Controller) served(ctx context.Context) (sets.String, error) {
+	lists, err := c.discovery.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	crds, err := c.crdClient.CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defined := sets.NewString()
+	for _, crd := range crds.Items {
+		defined.Insert(crd.Name)
+	}
+
+	native := sets.NewString()
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if name := crdName(gv.Group, r.Name); !defined.Has(name) {
+				native.Insert(name)
+			}
+		}
+	}
+	return native, nil
+}
+
+// crdName returns the name of the CRD of the given resource, as named by the
+// crdpuller.
+func crdName(group, resource string) string {
+	if group == "" {
+		group = "core"
+	}
+	return resource + "." + group
+}
@@ -0,0 +1,74 @@
+package apiimport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+func TestParseGroups(t *testing.T) {
+	for s, want := range map[string][]string{
+		"":                       nil,
+		"core":                   {""},
+		"apps, core,,batch":      {"apps", "", "batch"},
+		"*":                      {"*"},
+		"example.com,networking": {"example.com", "networking"},
+	} {
+		if got := ParseGroups(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %q, want %q", s, got, want)
+		}
+	}
+}
+
+// TestServed checks that the resources kcp serves, other than through CRDs,
+// aren't imported, not to shadow them.
+func TestServed(t *testing.T) {
+	responses := map[string]interface{}{
+		"/api": &metav1.APIVersions{Versions: []string{"v1"}},
+		"/apis": &metav1.APIGroupList{Groups: []metav1.APIGroup{{
+			Name:             "example.com",
+			Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "example.com/v1", Version: "v1"}},
+			PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "example.com/v1", Version: "v1"},
+		}}},
+		"/api/v1": &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"},
+		}},
+		"/apis/example.com/v1": &metav1.APIResourceList{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{
+			{Name: "clusters", Kind: "Cluster"},
+			{Name: "widgets", Namespaced: true, Kind: "Widget"},
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		response, found := responses[req.URL.Path]
+		if !found {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	c := &Controller{
+		discovery: discovery.NewDiscoveryClientForConfigOrDie(&rest.Config{Host: server.URL}),
+		crdClient: apiextensionsfake.NewSimpleClientset(&apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		}).ApiextensionsV1(),
+	}
+	native, err := c.served(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := native.List(), []string{"clusters.example.com", "configmaps.core"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got native resources %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+// Package basetest drives controllers built on base in tests, with fake
+// clients and informers whose caches are filled by hand rather than by
+// watching.
+package basetest
+
+import (
+	"testing"
+
+	clusterfake "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/fake"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Fixture holds the fake clients of kcp a controller under test is given,
+// and the Informers it reads them through. The Informers are never
+// started: tests fill their caches with Add and Replace.
+type Fixture struct {
+	T         *testing.T
+	Kube      *kubefake.Clientset
+	Clusters  *clusterfake.Clientset
+	Informers *base.Informers
+}
+
+// New returns a Fixture whose fake cluster client holds clusterObjs.
+func New(t *testing.T, clusterObjs ...runtime.Object) *Fixture {
+	kube := kubefake.NewSimpleClientset()
+	clusters := clusterfake.NewSimpleClientset(clusterObjs...)
+	return &Fixture{
+		T:         t,
+		Kube:      kube,
+		Clusters:  clusters,
+		Informers: base.NewInformersFor(kube, clusters, 0),
+	}
+}
+
+// Add adds the objects to the cache of the informer.
+func (f *Fixture) Add(informer cache.SharedIndexInformer, objs ...interface{}) {
+	for _, obj := range objs {
+		if err := informer.GetIndexer().Add(obj); err != nil {
+			f.T.Fatal(err)
+		}
+	}
+}
+
+// Replace makes the cache of the informer hold the items of list, such as
+// the objects the controller has written through a fake client so far.
+func (f *Fixture) Replace(informer cache.SharedIndexInformer, list runtime.Object) {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	objs := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		objs = append(objs, item)
+	}
+	if err := informer.GetIndexer().Replace(objs, ""); err != nil {
+		f.T.Fatal(err)
+	}
+}
@@ -0,0 +1,87 @@
+package base
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// batches are the workspaces whose objects are to be enqueued, in the order
+// they were requested, each once however many times it was.
+type batches struct {
+	lock    sync.Mutex
+	pending []string
+	queued  map[string]bool
+	wake    chan struct{}
+}
+
+func newBatches() *batches {
+	return &batches{queued: map[string]bool{}, wake: make(chan struct{}, 1)}
+}
+
+func (b *batches) add(workspace string) {
+	b.lock.Lock()
+	if !b.queued[workspace] {
+		b.queued[workspace] = true
+		b.pending = append(b.pending, workspace)
+	}
+	b.lock.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *batches) next() (string, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.pending) == 0 {
+		return "", false
+	}
+	workspace := b.pending[0]
+	b.pending = b.pending[1:]
+	delete(b.queued, workspace)
+	return workspace, true
+}
+
+// SetBatchLister has EnqueueWorkspace enqueue the objects list returns for
+// a workspace. It must be called before Start, for EnqueueWorkspace to be
+// used.
+func (c *Controller) SetBatchLister(list func(workspace string) ([]interface{}, error)) {
+	c.listWorkspace = list
+}
+
+// EnqueueWorkspace has every object of the workspace, as the lister of
+// SetBatchLister lists them, enqueued with low priority, as EnqueueLow does,
+// for an event that concerns them all, such as a Cluster going NotReady.
+// Requests for a workspace still waiting are coalesced into one, so that the
+// keys enqueued as their own objects change, such as user edits, get
+// processed before the thousands of a large workspace rather than after.
+func (c *Controller) EnqueueWorkspace(workspace string) {
+	c.batches.add(workspace)
+}
+
+// feedBatches enqueues the objects of the workspaces EnqueueWorkspace is
+// called for until ctx is canceled.
+func (c *Controller) feedBatches(ctx context.Context) {
+	for {
+		workspace, found := c.batches.next()
+		if !found {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.batches.wake:
+			}
+			continue
+		}
+		objs, err := c.listWorkspace(workspace)
+		if err != nil {
+			runtime.HandleError(err)
+			continue
+		}
+		for _, obj := range objs {
+			c.EnqueueLow(obj)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEnqueueWorkspace(t *testing.T) {
+	gate := make(chan struct{})
+	processed := make(chan string, 10)
+	c := New("test", fake.NewSimpleClientset(), nil, nil, func(_ context.Context, key string) error {
+		<-gate
+		processed <- key
+		return nil
+	})
+	var lists int32
+	c.SetBatchLister(func(workspace string) ([]interface{}, error) {
+		atomic.AddInt32(&lists, 1)
+		var objs []interface{}
+		for i := 0; i < 5; i++ {
+			objs = append(objs, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("cm-%d", i), Namespace: "default", ClusterName: workspace,
+			}})
+		}
+		return objs, nil
+	})
+	// Requests for a workspace still waiting are coalesced.
+	for i := 0; i < 3; i++ {
+		c.EnqueueWorkspace("admin")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx, 1, time.Second)
+
+	// The low-priority keys are only handed to the workqueue while it holds
+	// fewer than there are workers, so a key enqueued meanwhile waits behind
+	// no more than one of them.
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return c.Queue().Len() >= 1, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Enqueue(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "edited", Namespace: "default", ClusterName: "admin"}})
+	close(gate)
+
+	var order []string
+	for len(order) < 6 {
+		select {
+		case key := <-processed:
+			order = append(order, key)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only processed %v", order)
+		}
+	}
+	index := map[string]int{}
+	for i, key := range order {
+		index[key] = i
+	}
+	if index["admin|default/edited"] > 2 {
+		t.Errorf("processed %v, the edited key behind more than a low-priority one", order)
+	}
+	if n := atomic.LoadInt32(&lists); n != 1 {
+		t.Errorf("listed the workspace %d times, want once", n)
+	}
+}
@@ -0,0 +1,447 @@
+// Package base holds the workqueue, retry, leader election and shutdown
+// plumbing shared by the workload splitters and other kcp controllers.
+package base
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kcp-dev/kcp/pkg/health"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// DefaultDrainTimeout is how long Start waits for queued and in-flight
+// items to be processed once its context is canceled.
+const DefaultDrainTimeout = 30 * time.Second
+
+// SignalContext returns a context canceled on SIGTERM or SIGINT, for Start
+// to drain on; a second signal exits immediately. It may only be called
+// once.
+func SignalContext() context.Context {
+	stop := genericapiserver.SetupSignalHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+	return ctx
+}
+
+// LeaderElectionConfig configures resourcelock-based leader election for a
+// Controller, so that only one of several replicas reconciles at a time.
+type LeaderElectionConfig struct {
+	// LeaseNamespace and LeaseName identify the Lease used as the lock.
+	LeaseNamespace string
+	LeaseName      string
+
+	// Identity of this replica. If empty, the hostname and a random suffix are used.
+	Identity string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// DefaultLeaderElectionConfig returns a LeaderElectionConfig with the same
+// timings used by the Kubernetes controller manager.
+func DefaultLeaderElectionConfig() *LeaderElectionConfig {
+	return &LeaderElectionConfig{
+		LeaseNamespace: "default",
+		LeaseName:      "deployment-splitter",
+		LeaseDuration:  15 * time.Second,
+		RenewDeadline:  10 * time.Second,
+		RetryPeriod:    2 * time.Second,
+	}
+}
+
+// Controller runs workers that pass workqueue keys to a process func,
+// retrying failed keys according to its RetryPolicy.
+type Controller struct {
+	name        string
+	logger      logr.Logger
+	queue       workqueue.RateLimitingInterface
+	rateLimiter workqueue.RateLimiter
+	retry       *RetryPolicy
+	process     func(ctx context.Context, key string) error
+	stopCh      chan struct{}
+
+	kubeClient     kubernetes.Interface
+	leaderElection *LeaderElectionConfig
+
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+	indexer     cache.Indexer
+
+	periodic []periodicFunc
+	owns     func(workspace string) bool
+	synced   []cache.InformerSynced
+	inFlight health.InFlight
+
+	requeueDropped time.Duration
+	parallelism    int
+
+	low           *lowQueue
+	batches       *batches
+	listWorkspace func(workspace string) ([]interface{}, error)
+}
+
+type periodicFunc struct {
+	fn     func(context.Context)
+	period time.Duration
+}
+
+// New returns a Controller that calls process for every key it dequeues,
+// with a context carrying a logger tagged with the key. Keys are
+// logicalcluster Keys, and the context is that of the key's logical cluster,
+// so that the clients process uses reach the workspace of the object it
+// reconciles, whose indexer must be a logicalcluster one. kubeClient is only
+// used for leader election, which is disabled if leaderElection is nil, and
+// for recording Events. Failed keys are retried according to retry, or DefaultRetryPolicy if nil.
+func New(name string, kubeClient kubernetes.Interface, leaderElection *LeaderElectionConfig, retry *RetryPolicy, process func(ctx context.Context, key string) error) *Controller {
+	metrics.Register()
+	if retry == nil {
+		retry = DefaultRetryPolicy()
+	}
+	rateLimiter := retry.rateLimiter()
+	broadcaster := record.NewBroadcaster()
+	return &Controller{
+		name:        name,
+		logger:      logging.New(name),
+		queue:       workqueue.NewNamedRateLimitingQueue(rateLimiter, name),
+		rateLimiter: rateLimiter,
+		retry:       retry,
+		process:     process,
+		stopCh:      make(chan struct{}), // closed by Start once the workqueue is drained
+
+		kubeClient:     kubeClient,
+		leaderElection: leaderElection,
+
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: name}),
+
+		low:     newLowQueue(),
+		batches: newBatches(),
+	}
+}
+
+// Queue returns the Controller's workqueue.
+func (c *Controller) Queue() workqueue.RateLimitingInterface {
+	return c.queue
+}
+
+// StopCh returns a channel that is closed once Start returns, for stopping
+// informers that feed the Controller.
+func (c *Controller) StopCh() <-chan struct{} {
+	return c.stopCh
+}
+
+// Recorder returns the EventRecorder for reporting on reconciled objects,
+// whose Events are only written once Start is called.
+func (c *Controller) Recorder() record.EventRecorder {
+	return c.recorder
+}
+
+// SetIndexer sets the indexer that keys are looked up in, so that a Warning
+// Event can be recorded on objects whose retries are exhausted. It must be
+// called before Start.
+func (c *Controller) SetIndexer(indexer cache.Indexer) {
+	c.indexer = indexer
+}
+
+// AddPeriodic has fn called every period alongside the workers, for
+// housekeeping that isn't triggered by any single object. It must be called
+// before Start.
+func (c *Controller) AddPeriodic(fn func(context.Context), period time.Duration) {
+	c.periodic = append(c.periodic, periodicFunc{fn: fn, period: period})
+}
+
+// SetWorkspaceFilter has the Controller only process the keys of the
+// workspaces owns reports, such as those of its kcp shard, and drop the
+// others. It must be called before Start.
+func (c *Controller) SetWorkspaceFilter(owns func(workspace string) bool) {
+	c.owns = owns
+}
+
+// SetDroppedRequeue has the keys dropped after failing every retry processed
+// again after the given delay, from the object the informer then has, rather
+// than left until their object changes or is resynced, for Controllers whose
+// informers don't resync. It must be called before Start.
+func (c *Controller) SetDroppedRequeue(after time.Duration) {
+	c.requeueDropped = after
+}
+
+// Enqueue adds the key of the given object to the workqueue, its
+// logicalcluster.Key, with high priority, ahead of those EnqueueLow holds.
+func (c *Controller) Enqueue(obj interface{}) {
+	key, err := logicalcluster.KeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.low.remove(key)
+	c.queue.Add(key)
+	c.observeDepth()
+}
+
+// AddCacheSyncs has Start wait for the caches of the informers whose
+// HasSynced funcs are given to sync before processing any key, for the
+// objects looked up to be those of the API server rather than a partial
+// list. It must be called before Start.
+func (c *Controller) AddCacheSyncs(synced ...cache.InformerSynced) {
+	c.synced = append(c.synced, synced...)
+}
+
+// LivenessCheck returns the check of the Controller's liveness, which fails
+// while one of its workers has been processing a key for longer than
+// health.DefaultStuckTimeout.
+func (c *Controller) LivenessCheck() healthz.HealthChecker {
+	return c.inFlight.Check(c.name, health.DefaultStuckTimeout)
+}
+
+// ReadinessCheck returns the check of the Controller's readiness, which fails
+// until the caches given to AddCacheSyncs have synced.
+func (c *Controller) ReadinessCheck() healthz.HealthChecker {
+	return health.CacheSyncCheck(c.name, func() bool {
+		for _, synced := range c.synced {
+			if !synced() {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Start runs numThreads workers until ctx is canceled, once the caches given
+// to AddCacheSyncs have synced. When leader election is configured, workers
+// only run while the lease is held.
+//
+// On cancellation, Start stops accepting new work, waits up to drainTimeout
+// for queued and in-flight items (including their status updates) to finish,
+// and then closes StopCh before returning.
+func (c *Controller) Start(ctx context.Context, numThreads int, drainTimeout time.Duration) {
+	defer close(c.stopCh)
+
+	c.broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: c.kubeClient.CoreV1().Events("")})
+	defer c.broadcaster.Shutdown()
+
+	if c.leaderElection == nil {
+		c.run(ctx, numThreads, drainTimeout)
+		return
+	}
+
+	lock, err := c.newResourceLock()
+	if err != nil {
+		klog.Fatalf("Error creating %s leader election lock: %v", c.name, err)
+	}
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   c.leaderElection.LeaseDuration,
+		RenewDeadline:   c.leaderElection.RenewDeadline,
+		RetryPeriod:     c.leaderElection.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            c.leaderElection.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				c.logger.Info("Acquired lease", "namespace", c.leaderElection.LeaseNamespace, "name", c.leaderElection.LeaseName)
+				c.run(ctx, numThreads, drainTimeout)
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-ctx.Done():
+					c.logger.Info("Released leader election lease")
+				default:
+					// Another replica may already be reconciling; exit rather than fight over leafs.
+					klog.Fatalf("Lost leader election lease %s/%s", c.leaderElection.LeaseNamespace, c.leaderElection.LeaseName)
+				}
+			},
+			OnNewLeader: func(identity string) {
+				c.logger.Info("New leader elected", "identity", identity)
+			},
+		},
+	})
+}
+
+func (c *Controller) run(ctx context.Context, numThreads int, drainTimeout time.Duration) {
+	if !cache.WaitForNamedCacheSync(c.name, ctx.Done(), c.synced...) {
+		c.queue.ShutDown()
+		return
+	}
+	workersStopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < numThreads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.Until(c.startWorker, time.Second, workersStopCh)
+		}()
+	}
+	for _, p := range c.periodic {
+		wg.Add(1)
+		go func(p periodicFunc) {
+			defer wg.Done()
+			wait.UntilWithContext(logging.NewContext(ctx, c.logger), p.fn, p.period)
+		}(p)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.feedLow(ctx, numThreads)
+	}()
+	if c.listWorkspace != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.feedBatches(ctx)
+		}()
+	}
+	c.logger.Info("Starting workers", "count", numThreads)
+	<-ctx.Done()
+	c.logger.Info("Stopping workers")
+
+	// Once shut down, the queue rejects new items but still hands out the
+	// ones already queued, so workers exit after they've drained it.
+	c.queue.ShutDown()
+	close(workersStopCh)
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		c.logger.Info("Drained workqueue", "abandonedLow", c.low.len())
+	case <-time.After(drainTimeout):
+		c.logger.Info("Timed out draining workqueue", "timeout", drainTimeout, "abandoned", c.queue.Len())
+	}
+}
+
+func (c *Controller) newResourceLock() (resourcelock.Interface, error) {
+	id := c.leaderElection.Identity
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		id = hostname + "_" + string(uuid.NewUUID())
+	}
+	return resourcelock.New(resourcelock.LeasesResourceLock,
+		c.leaderElection.LeaseNamespace,
+		c.leaderElection.LeaseName,
+		c.kubeClient.CoreV1(),
+		c.kubeClient.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: id})
+}
+
+func (c *Controller) startWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	// Wait until there is a new item in the working queue
+	k, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	key := k.(string)
+	c.observeDepth()
+
+	// No matter what, tell the queue we're done with this key, to unblock
+	// other workers.
+	defer c.queue.Done(key)
+
+	logger := c.logger.WithValues(logging.ObjectKey, key)
+	ctx := context.Background()
+	if cluster, _, _, err := logicalcluster.SplitKey(key); err == nil {
+		if c.owns != nil && !c.owns(cluster) {
+			logger.V(4).Info("Skipping the key of a workspace of another shard")
+			c.queue.Forget(key)
+			return true
+		}
+		logger = logger.WithValues(logging.WorkspaceKey, cluster)
+		ctx = genericapirequest.WithCluster(ctx, genericapirequest.Cluster{Name: cluster})
+	}
+	c.inFlight.Begin(key)
+	defer c.inFlight.End(key)
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, c.name+" reconcile",
+		attribute.String("kcp.controller", c.name), attribute.String("kcp.key", key), attribute.Int("kcp.requeues", c.queue.NumRequeues(key)))
+	err := c.process(logging.NewContext(ctx, logger), key)
+	tracing.End(span, err)
+	metrics.ObserveReconcile(c.name, start, err)
+	c.handleErr(logger, err, key)
+	return true
+}
+
+func (c *Controller) handleErr(logger logr.Logger, err error, key string) {
+	// Reconcile worked, nothing else to do for this workqueue item.
+	if err == nil {
+		metrics.ReconcileTotal.WithLabelValues(c.name, metrics.ResultSuccess).Inc()
+		c.queue.Forget(key)
+		return
+	}
+
+	num := c.queue.NumRequeues(key)
+	if num < c.retry.MaxRequeues {
+		logger.Error(err, "Error reconciling, retrying", "retries", num)
+		metrics.ReconcileTotal.WithLabelValues(c.name, metrics.ResultRetry).Inc()
+		if c.retry.RetryImmediately != nil && c.retry.RetryImmediately(err) {
+			// Count the failure towards MaxRequeues, but skip the backoff.
+			c.rateLimiter.When(key)
+			c.queue.Add(key)
+			return
+		}
+		c.queue.AddRateLimited(key)
+		return
+	}
+
+	// Give up and report error elsewhere.
+	metrics.ReconcileTotal.WithLabelValues(c.name, metrics.ResultDropped).Inc()
+	c.queue.Forget(key)
+	runtime.HandleError(err)
+	c.recordDropped(key, err)
+	if c.requeueDropped > 0 {
+		logger.Error(err, "Dropping key after failed retries, until it's requeued", "after", c.requeueDropped)
+		c.queue.AddAfter(key, c.requeueDropped)
+		return
+	}
+	logger.Error(err, "Dropping key after failed retries")
+}
+
+func (c *Controller) recordDropped(key string, err error) {
+	if c.indexer == nil {
+		return
+	}
+	obj, exists, ierr := c.indexer.GetByKey(key)
+	if ierr != nil || !exists {
+		return
+	}
+	if robj, ok := obj.(apiruntime.Object); ok {
+		c.recorder.Eventf(robj, corev1.EventTypeWarning, "RetriesExhausted", "Gave up reconciling after %d retries: %v", c.retry.MaxRequeues, err)
+	}
+}
@@ -0,0 +1,47 @@
+package base
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStartWaitsForCacheSync(t *testing.T) {
+	processed := make(chan string, 1)
+	c := New("test", fake.NewSimpleClientset(), nil, nil, func(_ context.Context, key string) error {
+		processed <- key
+		return nil
+	})
+	var synced int32
+	c.AddCacheSyncs(func() bool { return atomic.LoadInt32(&synced) == 1 })
+	c.Queue().Add("admin|default/web")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx, 1, time.Second)
+
+	select {
+	case key := <-processed:
+		t.Fatalf("processed %s before the caches synced", key)
+	case <-time.After(300 * time.Millisecond):
+	}
+	atomic.StoreInt32(&synced, 1)
+	select {
+	case key := <-processed:
+		if key != "admin|default/web" {
+			t.Errorf("processed %s", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't process the key once the caches synced")
+	}
+
+	cancel()
+	select {
+	case <-c.StopCh():
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't stop")
+	}
+}
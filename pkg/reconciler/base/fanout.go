@@ -0,0 +1,93 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultParallelism is how many Clusters FanOut works on at once, unless
+// SetParallelism says otherwise.
+const DefaultParallelism = 8
+
+// ClusterErrors are the errors of the Clusters a FanOut failed for, by
+// Cluster name, while it worked for the others.
+type ClusterErrors map[string]error
+
+func (e ClusterErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, name := range e.Clusters() {
+		msgs = append(msgs, fmt.Sprintf("cluster %s: %v", name, e[name]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Clusters returns the names of the Clusters that failed, sorted.
+func (e ClusterErrors) Clusters() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PartialFailure returns the ClusterErrors of err, if it's one, for the
+// object reconciled to still be written, with the leafs that were.
+func PartialFailure(err error) (ClusterErrors, bool) {
+	var failed ClusterErrors
+	if errors.As(err, &failed) {
+		return failed, true
+	}
+	return nil, false
+}
+
+// SetParallelism sets how many Clusters FanOut works on at once, one if n
+// is below. It must be called before Start.
+func (c *Controller) SetParallelism(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.parallelism = n
+}
+
+// FanOut calls fn for each of the named Clusters, such as to create or
+// update their leafs, on as many at once as the Controller's parallelism,
+// and waits for every call to return. It returns the errors of the Clusters
+// fn failed for as ClusterErrors, or nil if it failed for none.
+func (c *Controller) FanOut(clusters []string, fn func(cluster string) error) error {
+	return fanOut(c.parallelism, clusters, fn)
+}
+
+func fanOut(parallelism int, clusters []string, fn func(cluster string) error) error {
+	if parallelism < 1 {
+		parallelism = DefaultParallelism
+	}
+	var lock sync.Mutex
+	failed := ClusterErrors{}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+	for _, name := range clusters {
+		name := name
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := fn(name); err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				failed[name] = err
+			}
+		}()
+	}
+	wg.Wait()
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
@@ -0,0 +1,55 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestFanOut(t *testing.T) {
+	var lock sync.Mutex
+	running, most := 0, 0
+	var clusters []string
+	for i := 0; i < 20; i++ {
+		clusters = append(clusters, fmt.Sprintf("cluster-%02d", i))
+	}
+	err := fanOut(3, clusters, func(cluster string) error {
+		lock.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		lock.Unlock()
+		defer func() {
+			lock.Lock()
+			running--
+			lock.Unlock()
+		}()
+		if cluster == "cluster-04" || cluster == "cluster-11" {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+	if most > 3 {
+		t.Errorf("ran %d at once, expected at most 3", most)
+	}
+
+	failed, partial := PartialFailure(fmt.Errorf("reconciling: %w", err))
+	if !partial {
+		t.Fatalf("expected a partial failure, got %v", err)
+	}
+	if names := failed.Clusters(); len(names) != 2 || names[0] != "cluster-04" || names[1] != "cluster-11" {
+		t.Errorf("got failed clusters %v", names)
+	}
+	if want := "cluster cluster-04: unreachable; cluster cluster-11: unreachable"; err.Error() != want {
+		t.Errorf("got %q, expected %q", err, want)
+	}
+
+	if err := fanOut(0, clusters, func(string) error { return nil }); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if _, partial := PartialFailure(errors.New("boom")); partial {
+		t.Error("expected another error not to be a partial failure")
+	}
+}
@@ -0,0 +1,173 @@
+package base
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterscheme "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/scheme"
+	"github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// DefaultListPageSize is how many objects the informers list at a time by
+// default.
+const DefaultListPageSize = 500
+
+// Informers are the informer factories of the controllers run together
+// with the same config, such as those of the controller manager, which
+// share them so that each resource is only watched once.
+//
+// Each NewController takes the Informers to read through as its last
+// argument. Given some, it only registers its informers with them, and the
+// caller starts them once every controller sharing them is built; given
+// nil, it makes its own and starts them itself.
+type Informers struct {
+	Kube    informers.SharedInformerFactory
+	Cluster externalversions.SharedInformerFactory
+}
+
+// Resync configures how often the informers of Informers resync, that is
+// hand every object they cache to the controllers again, as if it changed.
+// Their watches don't need it to catch up after a disconnection, as they
+// resume from the bookmarks the API server sends.
+type Resync struct {
+	// Period is how often the informers resync, never if zero.
+	Period time.Duration
+	// Resources overrides Period for the informers of the resources of the
+	// given plural names, such as deployments or clusters.
+	Resources map[string]time.Duration
+}
+
+// ParseResync returns the Resync of the given period, overridden for the
+// resources given as comma-separated resource=period pairs, such as
+// "clusters=1h,deployments=0".
+func ParseResync(period time.Duration, resources string) (Resync, error) {
+	resync := Resync{Period: period, Resources: map[string]time.Duration{}}
+	if period < 0 {
+		return resync, fmt.Errorf("the resync period must not be negative")
+	}
+	for _, pair := range strings.Split(resources, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return resync, fmt.Errorf("%q isn't a resource=period pair", pair)
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return resync, fmt.Errorf("resync period of %s: %w", parts[0], err)
+		}
+		if d < 0 {
+			return resync, fmt.Errorf("the resync period of %s must not be negative", parts[0])
+		}
+		resync.Resources[parts[0]] = d
+	}
+	return resync, nil
+}
+
+// PagedLists returns a tweak of the list options of informers having them
+// list pageSize objects at a time, from etcd, rather than everything at once
+// from the watch cache, which ignores the limit of the lists it serves, so
+// that neither the server nor the controllers hold a whole workspace of
+// 100k objects in a single response. It changes nothing if pageSize is
+// zero.
+func PagedLists(pageSize int64) func(*metav1.ListOptions) {
+	return func(o *metav1.ListOptions) {
+		// Watches have no limit, while the reflectors of the informers set
+		// one for each page of their lists.
+		if pageSize <= 0 || o.Limit == 0 {
+			return
+		}
+		o.Limit = pageSize
+		if o.ResourceVersion == "0" {
+			o.ResourceVersion = ""
+		}
+	}
+}
+
+// NewInformers returns Informers for cfg, resynced every resync.
+func NewInformers(cfg *rest.Config, resync time.Duration) *Informers {
+	return NewInformersFor(kubernetes.NewForConfigOrDie(cfg), clusterclient.NewForConfigOrDie(cfg), resync)
+}
+
+// NewInformersFor returns Informers watching with the given clients, such as
+// fake ones, resynced every resync, which list DefaultListPageSize objects
+// at a time.
+func NewInformersFor(kubeClient kubernetes.Interface, clusterClient clusterclient.Interface, resync time.Duration) *Informers {
+	paged := PagedLists(DefaultListPageSize)
+	return &Informers{
+		Kube:    informers.NewSharedInformerFactoryWithOptions(kubeClient, resync, informers.WithTweakListOptions(paged)),
+		Cluster: externalversions.NewSharedInformerFactoryWithOptions(clusterClient, resync, externalversions.WithTweakListOptions(paged)),
+	}
+}
+
+// NewInformersWithResync returns Informers for cfg, resynced as resync says,
+// which list pageSize objects at a time, as PagedLists does. It fails for
+// resources neither Kubernetes nor kcp have types of.
+func NewInformersWithResync(cfg *rest.Config, resync Resync, pageSize int64) (*Informers, error) {
+	kubeResync, kubeFound := resyncConfig(kubescheme.Scheme, resync.Resources)
+	clusterResync, clusterFound := resyncConfig(clusterscheme.Scheme, resync.Resources)
+	var unknown []string
+	for resource := range resync.Resources {
+		if !kubeFound.Has(resource) && !clusterFound.Has(resource) {
+			unknown = append(unknown, resource)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown resources %s to set the resync period of", strings.Join(unknown, ", "))
+	}
+	paged := PagedLists(pageSize)
+	return &Informers{
+		Kube:    informers.NewSharedInformerFactoryWithOptions(kubernetes.NewForConfigOrDie(cfg), resync.Period, informers.WithCustomResyncConfig(kubeResync), informers.WithTweakListOptions(paged)),
+		Cluster: externalversions.NewSharedInformerFactoryWithOptions(clusterclient.NewForConfigOrDie(cfg), resync.Period, externalversions.WithCustomResyncConfig(clusterResync), externalversions.WithTweakListOptions(paged)),
+	}, nil
+}
+
+// resyncConfig returns the resync periods of the types of scheme, which the
+// informer factories key them by, and the resources they were found for.
+func resyncConfig(scheme *runtime.Scheme, resources map[string]time.Duration) (map[metav1.Object]time.Duration, sets.String) {
+	config := map[metav1.Object]time.Duration{}
+	found := sets.NewString()
+	if len(resources) == 0 {
+		return config, found
+	}
+	for gvk, t := range scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		d, ok := resources[plural.Resource]
+		if !ok {
+			continue
+		}
+		obj, ok := reflect.New(t).Interface().(metav1.Object)
+		if !ok {
+			continue
+		}
+		config[obj] = d
+		found.Insert(plural.Resource)
+	}
+	return config, found
+}
+
+// Start starts the informers the controllers asked for, which must all have
+// been created, and waits for their caches to sync.
+func (i *Informers) Start(stopCh <-chan struct{}) {
+	i.Kube.Start(stopCh)
+	i.Cluster.Start(stopCh)
+	i.Kube.WaitForCacheSync(stopCh)
+	i.Cluster.WaitForCacheSync(stopCh)
+}
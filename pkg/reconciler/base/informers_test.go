@@ -0,0 +1,67 @@
+package base
+
+import (
+	"testing"
+	"time"
+
+	clusterscheme "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestParseResync(t *testing.T) {
+	resync, err := ParseResync(0, "clusters=1h, deployments=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resync.Period != 0 || len(resync.Resources) != 2 || resync.Resources["clusters"] != time.Hour || resync.Resources["deployments"] != 0 {
+		t.Errorf("got %+v", resync)
+	}
+
+	for _, resources := range []string{"clusters", "clusters=soon", "=1h", "clusters=-1h"} {
+		if _, err := ParseResync(time.Hour, resources); err == nil {
+			t.Errorf("%q: expected an error", resources)
+		}
+	}
+	if _, err := ParseResync(-time.Hour, ""); err == nil {
+		t.Error("expected an error for a negative period")
+	}
+}
+
+func TestResyncConfig(t *testing.T) {
+	resources := map[string]time.Duration{"deployments": time.Hour, "clusters": time.Minute}
+
+	kube, found := resyncConfig(kubescheme.Scheme, resources)
+	if !found.Has("deployments") || found.Has("clusters") || len(kube) == 0 {
+		t.Errorf("got %v for %v", kube, found.List())
+	}
+	for obj, d := range kube {
+		if d != time.Hour {
+			t.Errorf("%T: got %s", obj, d)
+		}
+	}
+
+	cluster, found := resyncConfig(clusterscheme.Scheme, resources)
+	if !found.Has("clusters") || found.Has("deployments") || len(cluster) != 1 {
+		t.Errorf("got %v for %v", cluster, found.List())
+	}
+}
+
+func TestPagedLists(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		pageSize int64
+		in, want metav1.ListOptions
+	}{
+		{"first page", 100, metav1.ListOptions{Limit: 500, ResourceVersion: "0"}, metav1.ListOptions{Limit: 100}},
+		{"next page", 100, metav1.ListOptions{Limit: 500, Continue: "c"}, metav1.ListOptions{Limit: 100, Continue: "c"}},
+		{"watch", 100, metav1.ListOptions{ResourceVersion: "42", AllowWatchBookmarks: true}, metav1.ListOptions{ResourceVersion: "42", AllowWatchBookmarks: true}},
+		{"disabled", 0, metav1.ListOptions{Limit: 500, ResourceVersion: "0"}, metav1.ListOptions{Limit: 500, ResourceVersion: "0"}},
+	} {
+		o := tc.in
+		PagedLists(tc.pageSize)(&o)
+		if o != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, o, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package base
+
+import (
+	"context"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/tools/cache"
+)
+
+// LeafLabel labels the leafs the splitters create with the name of their
+// root, in the same workspace and namespace.
+const LeafLabel = "owned-by"
+
+// LeafClient gets and deletes the objects of a resource on the API server of
+// the workspace of the context, for Orphans to check and delete leafs with.
+type LeafClient interface {
+	Get(ctx context.Context, namespace, name string) (metav1.Object, error)
+	Delete(ctx context.Context, namespace, name string) error
+}
+
+// Orphans deletes the leafs of a resource whose root is gone, or was
+// replaced by another object with the same name. kcp doesn't run the
+// garbage collector, so leafs' OwnerReferences alone don't get them deleted
+// along with their root. Once deleted from kcp, a leaf is removed from its
+// Cluster by the syncer, or when the syncer next starts if it's offline.
+type Orphans struct {
+	resource schema.GroupVersionResource
+	indexer  cache.Indexer
+	client   LeafClient
+}
+
+// NewOrphans returns the Orphans of the given resource, whose roots and
+// leafs are both held by indexer, a logicalcluster one.
+func NewOrphans(resource schema.GroupVersionResource, indexer cache.Indexer, client LeafClient) *Orphans {
+	return &Orphans{resource: resource, indexer: indexer, client: client}
+}
+
+// Collect deletes every orphaned leaf of every workspace. Leafs are also
+// checked whenever they're reconciled; this periodic sweep, for
+// Controller.AddPeriodic, catches the ones that aren't.
+func (o *Orphans) Collect(ctx context.Context) {
+	req, err := labels.NewRequirement(LeafLabel, selection.Exists, nil)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	objs, err := cache.NewGenericLister(o.indexer, o.resource.GroupResource()).List(labels.NewSelector().Add(*req))
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	for _, obj := range objs {
+		leaf, err := meta.Accessor(obj)
+		if err != nil {
+			runtime.HandleError(err)
+			continue
+		}
+		if _, err := o.DeleteIfOrphaned(ctx, leaf); err != nil {
+			runtime.HandleError(err)
+		}
+	}
+}
+
+// CollectLeafsOf deletes the orphaned leafs of the root with the given name,
+// such as once it's deleted.
+func (o *Orphans) CollectLeafsOf(ctx context.Context, workspace, namespace, name string) error {
+	sel := labels.SelectorFromSet(labels.Set{LeafLabel: name})
+	objs, err := cache.NewGenericLister(logicalcluster.Scoped(o.indexer, workspace), o.resource.GroupResource()).ByNamespace(namespace).List(sel)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		leaf, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := o.DeleteIfOrphaned(ctx, leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteIfOrphaned deletes the given leaf if it's orphaned, and reports
+// whether it did. A root missing from the cache is looked up on the API
+// server before its leaf is taken for an orphan, as the cache may lag.
+func (o *Orphans) DeleteIfOrphaned(ctx context.Context, leaf metav1.Object) (bool, error) {
+	ctx = genericapirequest.WithCluster(ctx, genericapirequest.Cluster{Name: leaf.GetClusterName()})
+	name := leaf.GetLabels()[LeafLabel]
+	var root metav1.Object
+	obj, err := cache.NewGenericLister(logicalcluster.Scoped(o.indexer, leaf.GetClusterName()), o.resource.GroupResource()).ByNamespace(leaf.GetNamespace()).Get(name)
+	if err == nil {
+		root, err = meta.Accessor(obj)
+	}
+	if errors.IsNotFound(err) {
+		root, err = o.client.Get(ctx, leaf.GetNamespace(), name)
+	}
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return false, err
+	case !o.ownedBy(leaf, root):
+	default:
+		return false, nil
+	}
+
+	if err := o.client.Delete(ctx, leaf.GetNamespace(), leaf.GetName()); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	logging.FromContext(ctx).Info("Deleted orphaned leaf", "resource", o.resource.Resource, "leaf", leaf.GetName(), "namespace", leaf.GetNamespace(), logging.ClusterKey, leaf.GetLabels()["cluster"])
+	return true, nil
+}
+
+// ownedBy reports whether leaf was split from root, as its OwnerReference to
+// an object of the resource's group with root's name tells. Leafs without an
+// OwnerReference UID are given the benefit of the doubt.
+func (o *Orphans) ownedBy(leaf, root metav1.Object) bool {
+	for _, ref := range leaf.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil || gv.Group != o.resource.Group || ref.Name != root.GetName() {
+			continue
+		}
+		return ref.UID == "" || ref.UID == root.GetUID()
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package base
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+var deploymentsResource = appsv1.SchemeGroupVersion.WithResource("deployments")
+
+// fakeLeafClient holds the Deployments of the API server, by name.
+type fakeLeafClient map[string]*appsv1.Deployment
+
+func (c fakeLeafClient) Get(_ context.Context, _, name string) (metav1.Object, error) {
+	d, found := c[name]
+	if !found {
+		return nil, errors.NewNotFound(deploymentsResource.GroupResource(), name)
+	}
+	return d, nil
+}
+
+func (c fakeLeafClient) Delete(_ context.Context, _, name string) error {
+	if _, found := c[name]; !found {
+		return errors.NewNotFound(deploymentsResource.GroupResource(), name)
+	}
+	delete(c, name)
+	return nil
+}
+
+func orphanTestDeployment(name string, uid types.UID, root string, rootUID types.UID) *appsv1.Deployment {
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ClusterName: "admin", UID: uid}}
+	if root != "" {
+		d.Labels = map[string]string{LeafLabel: root}
+		d.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: root, UID: rootUID}}
+	}
+	return d
+}
+
+func TestOrphans(t *testing.T) {
+	for _, c := range []struct {
+		desc    string
+		cached  []*appsv1.Deployment
+		live    []*appsv1.Deployment
+		leaf    *appsv1.Deployment
+		deleted bool
+	}{{
+		desc:    "root gone",
+		leaf:    orphanTestDeployment("web--east", "leaf", "web", "root"),
+		deleted: true,
+	}, {
+		desc:   "root cached",
+		cached: []*appsv1.Deployment{orphanTestDeployment("web", "root", "", "")},
+		leaf:   orphanTestDeployment("web--east", "leaf", "web", "root"),
+	}, {
+		desc: "root not cached yet",
+		live: []*appsv1.Deployment{orphanTestDeployment("web", "root", "", "")},
+		leaf: orphanTestDeployment("web--east", "leaf", "web", "root"),
+	}, {
+		desc:    "root replaced",
+		cached:  []*appsv1.Deployment{orphanTestDeployment("web", "other", "", "")},
+		leaf:    orphanTestDeployment("web--east", "leaf", "web", "root"),
+		deleted: true,
+	}, {
+		desc:   "leaf without an owner UID",
+		cached: []*appsv1.Deployment{orphanTestDeployment("web", "other", "", "")},
+		leaf:   orphanTestDeployment("web--east", "leaf", "web", ""),
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+			for name, fn := range logicalcluster.Indexers {
+				indexers[name] = fn
+			}
+			indexer := logicalcluster.NewIndexer(cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers))
+			client := fakeLeafClient{c.leaf.Name: c.leaf}
+			for _, d := range append(c.cached, c.leaf) {
+				if err := indexer.Add(d); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, d := range append(c.cached, c.live...) {
+				client[d.Name] = d
+			}
+
+			NewOrphans(deploymentsResource, indexer, client).Collect(context.Background())
+			if _, found := client["web--east"]; found == c.deleted {
+				t.Errorf("leaf found: %v, want %v", found, !c.deleted)
+			}
+			if len(c.cached)+len(c.live) > 0 && client["web"] == nil {
+				t.Error("deleted the root")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package base
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// lowPollInterval is how often the workqueue is checked for room for the
+// low-priority keys.
+const lowPollInterval = 100 * time.Millisecond
+
+// lowQueue holds the low-priority keys of a Controller, each once, in the
+// order they were added, until its workqueue has room for them.
+type lowQueue struct {
+	lock sync.Mutex
+	// keys may still hold keys removed since, which queued no longer has.
+	keys   []string
+	queued map[string]bool
+	wake   chan struct{}
+}
+
+func newLowQueue() *lowQueue {
+	return &lowQueue{queued: map[string]bool{}, wake: make(chan struct{}, 1)}
+}
+
+func (q *lowQueue) add(key string) {
+	q.lock.Lock()
+	if !q.queued[key] {
+		q.queued[key] = true
+		q.keys = append(q.keys, key)
+	}
+	q.lock.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// remove removes the key, if queued, as it was enqueued with high priority.
+func (q *lowQueue) remove(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.queued, key)
+	if len(q.queued) == 0 {
+		q.keys = nil
+	}
+}
+
+func (q *lowQueue) pop() (string, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.keys) > 0 {
+		key := q.keys[0]
+		q.keys = q.keys[1:]
+		if q.queued[key] {
+			delete(q.queued, key)
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func (q *lowQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.queued)
+}
+
+// EnqueueLow adds the key of the given object to the workqueue with low
+// priority, for bulk background work such as resyncs and rebalancing: it's
+// only handed to the workqueue while it holds fewer keys than there are
+// workers, so that the keys enqueued with Enqueue, such as those of user
+// edits and deletions, wait behind no more than that many of them.
+// Enqueueing a key with Enqueue while it waits here moves it there.
+func (c *Controller) EnqueueLow(obj interface{}) {
+	key, err := logicalcluster.KeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.low.add(key)
+	c.observeDepth()
+}
+
+// observeDepth records the number of keys waiting with each priority.
+func (c *Controller) observeDepth() {
+	metrics.QueueDepth.WithLabelValues(c.name, metrics.PriorityHigh).Set(float64(c.queue.Len()))
+	metrics.QueueDepth.WithLabelValues(c.name, metrics.PriorityLow).Set(float64(c.low.len()))
+}
+
+// feedLow hands the low-priority keys to the workqueue as it has room for
+// them, until ctx is canceled.
+func (c *Controller) feedLow(ctx context.Context, numThreads int) {
+	for {
+		if c.low.len() == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.low.wake:
+			}
+			continue
+		}
+		if err := wait.PollImmediateUntil(lowPollInterval, func() (bool, error) {
+			return c.queue.Len() < numThreads, nil
+		}, ctx.Done()); err != nil {
+			return
+		}
+		if key, found := c.low.pop(); found {
+			c.queue.Add(key)
+			c.observeDepth()
+		}
+	}
+}
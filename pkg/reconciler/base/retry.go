@@ -0,0 +1,44 @@
+package base
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// RetryPolicy configures how a Controller retries keys whose reconcile failed.
+type RetryPolicy struct {
+	// MaxRequeues is how many times a failed key is retried before it's
+	// dropped until its object next changes or is resynced.
+	MaxRequeues int
+
+	// BaseDelay is the delay before the first retry of a key, doubled on
+	// each further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// RetryImmediately reports whether err is worth retrying without any
+	// delay, such as a conflict from a stale cache that the next attempt is
+	// likely to see past. Immediate retries still count towards MaxRequeues.
+	// If nil, every error is retried with backoff.
+	RetryImmediately func(err error) bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with the same per-key backoff as
+// workqueue.DefaultControllerRateLimiter, which retries conflicts immediately.
+// Unlike that limiter, it doesn't also limit the retries of all keys to 10 per
+// second: each key only waits for its own backoff.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRequeues:      5,
+		BaseDelay:        5 * time.Millisecond,
+		MaxDelay:         1000 * time.Second,
+		RetryImmediately: errors.IsConflict,
+	}
+}
+
+// rateLimiter returns the per-key exponential backoff of the policy.
+func (p *RetryPolicy) rateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(p.BaseDelay, p.MaxDelay)
+}
@@ -0,0 +1,229 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+)
+
+// StatusClient reaches the object whose status PatchStatus patches, as a
+// typed or dynamic client does.
+type StatusClient struct {
+	// Get reads the object from the API server, rather than from a cache.
+	Get func(ctx context.Context) (runtime.Object, error)
+	// Patch applies a JSON merge patch to the status subresource of the
+	// object.
+	Patch func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error)
+}
+
+// FieldManager returns the name the Controller writes objects as, for their
+// managedFields to tell which controller wrote what.
+func (c *Controller) FieldManager() string {
+	return "kcp-" + c.name
+}
+
+// PatchStatus writes the status of current, which previous was reconciled
+// into, as a JSON merge patch of the fields that changed, made by
+// fieldManager, and returns the patched object. Unlike an UpdateStatus,
+// which fails whenever any other controller wrote the object since it was
+// cached, a patch only setting fields is sent unconditionally, as it can't
+// undo what was written meanwhile. A patch replacing a list, such as the
+// conditions, is only applied to the resourceVersion of current: on a
+// conflict, the changes are made again to the object read afresh, merging
+// the lists of conditions by type, so that those of other controllers are
+// kept.
+func PatchStatus(ctx context.Context, fieldManager string, previous, current runtime.Object, client StatusClient) (patched runtime.Object, err error) {
+	ctx, span := tracing.Start(ctx, "status patch", attribute.String("kcp.field_manager", fieldManager))
+	defer func() { tracing.End(span, err) }()
+
+	from, err := runtime.DefaultUnstructuredConverter.ToUnstructured(previous)
+	if err != nil {
+		return nil, err
+	}
+	to, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return nil, err
+	}
+	base, want := from["status"], to["status"]
+	resourceVersion, _, _ := unstructured.NestedString(to, "metadata", "resourceVersion")
+
+	patched = current
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		data, err := statusPatch(base, want, resourceVersion)
+		if err != nil || data == nil {
+			return err
+		}
+		obj, err := client.Patch(ctx, data, metav1.PatchOptions{FieldManager: fieldManager})
+		if err == nil {
+			patched = obj
+			return nil
+		}
+		if !errors.IsConflict(err) {
+			return err
+		}
+		latest, getErr := client.Get(ctx)
+		if getErr != nil {
+			return getErr
+		}
+		fresh, getErr := runtime.DefaultUnstructuredConverter.ToUnstructured(latest)
+		if getErr != nil {
+			return getErr
+		}
+		want = rebase(from["status"], to["status"], fresh["status"])
+		base = fresh["status"]
+		resourceVersion, _, _ = unstructured.NestedString(fresh, "metadata", "resourceVersion")
+		patched = latest
+		return err
+	})
+	return patched, err
+}
+
+// statusPatch returns the JSON merge patch turning the status base into
+// want, nil if they're the same. A patch replacing a list has the given
+// resourceVersion as a precondition.
+func statusPatch(base, want interface{}, resourceVersion string) ([]byte, error) {
+	original, err := json.Marshal(map[string]interface{}{"status": base})
+	if err != nil {
+		return nil, err
+	}
+	modified, err := json.Marshal(map[string]interface{}{"status": want})
+	if err != nil {
+		return nil, err
+	}
+	data, err := jsonpatch.CreateMergePatch(original, modified)
+	if err != nil {
+		return nil, err
+	}
+	patch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	if hasList(patch) {
+		patch["metadata"] = map[string]interface{}{"resourceVersion": resourceVersion}
+	}
+	return json.Marshal(patch)
+}
+
+func hasList(v interface{}) bool {
+	switch v := v.(type) {
+	case []interface{}:
+		return true
+	case map[string]interface{}:
+		for _, e := range v {
+			if hasList(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rebase returns fresh with the changes from prev to cur made to it. Lists
+// whose items all have a type, such as conditions, are merged by type;
+// other changed values are replaced with those of cur.
+func rebase(prev, cur, fresh interface{}) interface{} {
+	if equality.Semantic.DeepEqual(prev, cur) {
+		return fresh
+	}
+
+	if c, ok := cur.(map[string]interface{}); ok {
+		f, ok := fresh.(map[string]interface{})
+		if !ok {
+			return cur
+		}
+		p, _ := prev.(map[string]interface{})
+		out := make(map[string]interface{}, len(f))
+		for k, v := range f {
+			out[k] = v
+		}
+		for k := range p {
+			if _, found := c[k]; !found {
+				delete(out, k)
+			}
+		}
+		for k, v := range c {
+			out[k] = rebase(p[k], v, f[k])
+		}
+		return out
+	}
+
+	c, ok := byType(cur)
+	if !ok {
+		return cur
+	}
+	f, ok := byType(fresh)
+	if !ok {
+		return cur
+	}
+	p, ok := byType(prev)
+	if !ok {
+		return cur
+	}
+	out := make([]interface{}, 0, len(f.order)+len(c.order))
+	for _, t := range f.order {
+		if _, found := p.items[t]; found {
+			if _, found := c.items[t]; !found {
+				// Removed.
+				continue
+			}
+		}
+		if item, found := c.items[t]; found {
+			out = append(out, rebase(p.items[t], item, f.items[t]))
+			continue
+		}
+		out = append(out, f.items[t])
+	}
+	for _, t := range c.order {
+		if _, found := f.items[t]; !found && !equality.Semantic.DeepEqual(p.items[t], c.items[t]) {
+			// Added, or changed after another controller removed it.
+			out = append(out, c.items[t])
+		}
+	}
+	return out
+}
+
+type typedItems struct {
+	order []string
+	items map[string]interface{}
+}
+
+// byType returns the items of a list by their type, if they all have one.
+// A missing list has none.
+func byType(v interface{}) (typedItems, bool) {
+	l := typedItems{items: map[string]interface{}{}}
+	if v == nil {
+		return l, true
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return l, false
+	}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return l, false
+		}
+		t, ok := m["type"].(string)
+		if !ok || t == "" {
+			return l, false
+		}
+		if _, found := l.items[t]; found {
+			return l, false
+		}
+		l.order = append(l.order, t)
+		l.items[t] = item
+	}
+	return l, true
+}
@@ -0,0 +1,111 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeStatusClient patches a Deployment the way the API server does,
+// failing patches with a stale resourceVersion with a conflict.
+type fakeStatusClient struct {
+	t       *testing.T
+	stored  *appsv1.Deployment
+	patches []map[string]interface{}
+}
+
+func (f *fakeStatusClient) client() StatusClient {
+	return StatusClient{
+		Get: func(context.Context) (runtime.Object, error) {
+			return f.stored.DeepCopy(), nil
+		},
+		Patch: func(_ context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+			if opts.FieldManager != "kcp-test" {
+				f.t.Errorf("patched as %q", opts.FieldManager)
+			}
+			patch := map[string]interface{}{}
+			if err := json.Unmarshal(data, &patch); err != nil {
+				f.t.Fatal(err)
+			}
+			f.patches = append(f.patches, patch)
+			if md, found := patch["metadata"].(map[string]interface{}); found && md["resourceVersion"] != f.stored.ResourceVersion {
+				return nil, errors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, f.stored.Name, nil)
+			}
+			original, err := json.Marshal(f.stored)
+			if err != nil {
+				f.t.Fatal(err)
+			}
+			merged, err := jsonpatch.MergePatch(original, data)
+			if err != nil {
+				f.t.Fatal(err)
+			}
+			patched := &appsv1.Deployment{}
+			if err := json.Unmarshal(merged, patched); err != nil {
+				f.t.Fatal(err)
+			}
+			rv, _ := strconv.Atoi(f.stored.ResourceVersion)
+			patched.ResourceVersion = strconv.Itoa(rv + 1)
+			f.stored = patched
+			return patched.DeepCopy(), nil
+		},
+	}
+}
+
+func TestPatchStatus(t *testing.T) {
+	cached := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", ResourceVersion: "1"}}
+
+	t.Run("fields", func(t *testing.T) {
+		stored := cached.DeepCopy()
+		stored.ResourceVersion = "2"
+		stored.Status.Replicas = 3
+		f := &fakeStatusClient{t: t, stored: stored}
+
+		current := cached.DeepCopy()
+		current.Status.ReadyReplicas = 2
+		if _, err := PatchStatus(context.Background(), "kcp-test", cached, current, f.client()); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.patches) != 1 || f.patches[0]["metadata"] != nil {
+			t.Errorf("expected a single unconditional patch, got %v", f.patches)
+		}
+		if f.stored.Status.Replicas != 3 || f.stored.Status.ReadyReplicas != 2 {
+			t.Errorf("got status %+v", f.stored.Status)
+		}
+	})
+
+	t.Run("conditions", func(t *testing.T) {
+		stored := cached.DeepCopy()
+		stored.ResourceVersion = "2"
+		stored.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue}}
+		f := &fakeStatusClient{t: t, stored: stored}
+
+		current := cached.DeepCopy()
+		current.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue}}
+		obj, err := PatchStatus(context.Background(), "kcp-test", cached, current, f.client())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.patches) != 2 {
+			t.Errorf("expected a conflict, then a patch of the fresh object, got %v", f.patches)
+		}
+		var types []appsv1.DeploymentConditionType
+		for _, c := range f.stored.Status.Conditions {
+			types = append(types, c.Type)
+		}
+		if len(types) != 2 || types[0] != appsv1.DeploymentProgressing || types[1] != appsv1.DeploymentAvailable {
+			t.Errorf("expected the conditions of both controllers, got %v", types)
+		}
+		if obj.(*appsv1.Deployment).ResourceVersion != "3" {
+			t.Errorf("expected the patched object back, got %+v", obj)
+		}
+	})
+}
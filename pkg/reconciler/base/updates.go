@@ -0,0 +1,46 @@
+package base
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// StatusOnly reports whether the update of an object from oldObj to newObj
+// only changed its status, as its generation and metadata tell, for the
+// controllers placing objects by their spec and metadata, such as the
+// splitters, to skip it, rather than reconcile again from the status they
+// just wrote. Resyncs, which don't change the object, aren't status-only,
+// nor are the updates of objects whose generation isn't tracked.
+func StatusOnly(oldObj, newObj interface{}) bool {
+	o, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	n, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	if o.GetResourceVersion() == n.GetResourceVersion() || n.GetGeneration() == 0 || o.GetGeneration() != n.GetGeneration() {
+		return false
+	}
+	return equality.Semantic.DeepEqual(o.GetLabels(), n.GetLabels()) &&
+		equality.Semantic.DeepEqual(o.GetAnnotations(), n.GetAnnotations()) &&
+		equality.Semantic.DeepEqual(o.GetFinalizers(), n.GetFinalizers()) &&
+		equality.Semantic.DeepEqual(o.GetOwnerReferences(), n.GetOwnerReferences()) &&
+		equality.Semantic.DeepEqual(o.GetDeletionTimestamp(), n.GetDeletionTimestamp())
+}
+
+// Resynced reports whether the update of an object from oldObj to newObj is
+// an informer resync, which hands the same object again, for it to be
+// enqueued with low priority.
+func Resynced(oldObj, newObj interface{}) bool {
+	o, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	n, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return o.GetResourceVersion() == n.GetResourceVersion()
+}
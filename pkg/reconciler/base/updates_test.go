@@ -0,0 +1,34 @@
+package base
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStatusOnly(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		update func(old, updated *appsv1.Deployment)
+		want   bool
+	}{
+		{"status", func(_, d *appsv1.Deployment) { d.Status.ReadyReplicas = 3 }, true},
+		{"resync", func(_, d *appsv1.Deployment) { d.ResourceVersion = "1" }, false},
+		{"spec", func(_, d *appsv1.Deployment) { d.Generation = 3 }, false},
+		{"labels", func(_, d *appsv1.Deployment) { d.Labels = map[string]string{"app": "web", "cluster": "east"} }, false},
+		{"annotations", func(_, d *appsv1.Deployment) { d.Annotations = map[string]string{"kcp.dev/tolerations": "[]"} }, false},
+		{"deletion", func(_, d *appsv1.Deployment) { now := metav1.Now(); d.DeletionTimestamp = &now }, false},
+		{"untracked generation", func(old, d *appsv1.Deployment) { old.Generation, d.Generation = 0, 0 }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			old := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", ResourceVersion: "1", Generation: 2, Labels: map[string]string{"app": "web"}}}
+			updated := old.DeepCopy()
+			updated.ResourceVersion = "2"
+			tc.update(old, updated)
+			if got := StatusOnly(old, updated); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package batch
+
+import (
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/splitter"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// CronJobsResource is the resource split by NewCronJobController.
+var CronJobsResource = schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}
+
+// NewCronJobController returns a splitter for CronJobs, as described by
+// CronJobStrategy.
+func NewCronJobController(cfg *rest.Config, sched *scheduler.Scheduler, transforms transform.Chain, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *splitter.Controller {
+	return splitter.NewController(cfg, CronJobsResource, CronJobStrategy{}, sched, transforms, leaderElection, retry, shared)
+}
+
+// CronJobStrategy places a CronJob on every Cluster, so that each runs it on
+// the same schedule. The CompletionsAnnotation of the CronJob decides whether
+// each Cluster's Jobs run whole or with a share of the completions, as for
+// JobStrategy.
+type CronJobStrategy struct{}
+
+func (CronJobStrategy) Split(root *unstructured.Unstructured, cls []*v1alpha1.Cluster, _ []*unstructured.Unstructured) (map[string]*unstructured.Unstructured, error) {
+	cronJob := &batchv1beta1.CronJob{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(root.Object, cronJob); err != nil {
+		return nil, err
+	}
+	specs, err := splitJobSpec(cronJob.Spec.JobTemplate.Spec, cronJob.Annotations, cls)
+	if err != nil {
+		return nil, err
+	}
+
+	leafs := make(map[string]*unstructured.Unstructured, len(specs))
+	for name, spec := range specs {
+		leaf := cronJob.DeepCopy()
+		leaf.Spec.JobTemplate.Spec = spec
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(leaf)
+		if err != nil {
+			return nil, err
+		}
+		leafs[name] = &unstructured.Unstructured{Object: obj}
+	}
+	return leafs, nil
+}
+
+// AggregateStatus reports the latest schedule time across the leafs on the
+// root. The leafs' active Jobs only exist on their Clusters, so
+// they aren't listed.
+func (CronJobStrategy) AggregateStatus(root *unstructured.Unstructured, leafs []*unstructured.Unstructured) error {
+	cronJob := &batchv1beta1.CronJob{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(root.Object, cronJob); err != nil {
+		return err
+	}
+
+	status := batchv1beta1.CronJobStatus{}
+	for _, leaf := range leafs {
+		cj := &batchv1beta1.CronJob{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(leaf.Object, cj); err != nil {
+			return err
+		}
+		if t := cj.Status.LastScheduleTime; t != nil && (status.LastScheduleTime == nil || status.LastScheduleTime.Before(t)) {
+			status.LastScheduleTime = t.DeepCopy()
+		}
+	}
+
+	cronJob.Status = status
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cronJob)
+	if err != nil {
+		return err
+	}
+	root.Object["status"] = obj["status"]
+	return nil
+}
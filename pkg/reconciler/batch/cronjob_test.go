@@ -0,0 +1,83 @@
+package batch
+
+import (
+	"reflect"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func cronJob(mode CompletionsMode, completions, parallelism int32) *batchv1beta1.CronJob {
+	cj := &batchv1beta1.CronJob{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1beta1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule: "0 * * * *",
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "report"}},
+				Spec: batchv1.JobSpec{
+					Completions: &completions,
+					Parallelism: &parallelism,
+					Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+						RestartPolicy: corev1.RestartPolicyNever,
+						Containers:    []corev1.Container{{Name: "report", Image: "report:1"}},
+					}},
+				},
+			},
+		},
+	}
+	if mode != "" {
+		cj.Annotations = map[string]string{CompletionsAnnotation: string(mode)}
+	}
+	return cj
+}
+
+func TestCronJobSplit(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cronJob *batchv1beta1.CronJob
+		want    map[string]shares
+	}{{
+		name:    "duplicated by default",
+		cronJob: cronJob("", 4, 2),
+		want:    map[string]shares{"east": {4, 2}, "west": {4, 2}},
+	}, {
+		name:    "template split",
+		cronJob: cronJob(CompletionsSplit, 5, 3),
+		want:    map[string]shares{"east": {3, 2}, "west": {2, 1}},
+	}, {
+		name:    "template split with fewer completions than clusters",
+		cronJob: cronJob(CompletionsSplit, 1, 1),
+		want:    map[string]shares{"east": {1, 1}},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			leafs, err := CronJobStrategy{}.Split(toUnstructured(t, tc.cronJob), clusters("east", "west"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]shares{}
+			for name, leaf := range leafs {
+				cj := &batchv1beta1.CronJob{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(leaf.Object, cj); err != nil {
+					t.Fatal(err)
+				}
+				got[name] = sharesOf(cj.Spec.JobTemplate.Spec)
+
+				// Only the completions and parallelism of the template are
+				// split; each Cluster runs it on the same schedule.
+				want := tc.cronJob.Spec.DeepCopy()
+				want.JobTemplate.Spec.Completions, want.JobTemplate.Spec.Parallelism = cj.Spec.JobTemplate.Spec.Completions, cj.Spec.JobTemplate.Spec.Parallelism
+				if !reflect.DeepEqual(&cj.Spec, want) {
+					t.Errorf("%s: got spec %+v, want %+v", name, cj.Spec, *want)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got completions and parallelism %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
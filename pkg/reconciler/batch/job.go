@@ -0,0 +1,214 @@
+// Package batch splits batch/v1 Jobs and batch/v1beta1 CronJobs across Clusters with the
+// generic splitter, aggregating their status back into the root.
+package batch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/reconciler/splitter"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	clusterLabel = "cluster"
+
+	// CompletionsAnnotation sets how a Job's completions are placed onto
+	// Clusters, see CompletionsMode.
+	CompletionsAnnotation = "experimental.kcp.dev/completions"
+)
+
+// CompletionsMode is how a Job's completions are placed onto Clusters.
+type CompletionsMode string
+
+const (
+	// CompletionsDuplicate runs the whole Job on every Cluster. This is the default.
+	CompletionsDuplicate CompletionsMode = "duplicate"
+	// CompletionsSplit divides the Job's completions and parallelism across
+	// Clusters by weight, so that it completes once all of them have.
+	CompletionsSplit CompletionsMode = "split"
+)
+
+// JobsResource is the resource split by NewJobController.
+var JobsResource = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+
+// NewJobController returns a splitter for Jobs, as described by JobStrategy.
+func NewJobController(cfg *rest.Config, sched *scheduler.Scheduler, transforms transform.Chain, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *splitter.Controller {
+	return splitter.NewController(cfg, JobsResource, JobStrategy{}, sched, transforms, leaderElection, retry, shared)
+}
+
+// JobStrategy places a Job on every Cluster, either whole or with a share of
+// its completions depending on its CompletionsAnnotation, and aggregates the
+// leafs' pod counts and conditions into the root.
+type JobStrategy struct{}
+
+func (JobStrategy) Split(root *unstructured.Unstructured, cls []*v1alpha1.Cluster, _ []*unstructured.Unstructured) (map[string]*unstructured.Unstructured, error) {
+	job := &batchv1.Job{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(root.Object, job); err != nil {
+		return nil, err
+	}
+	specs, err := splitJobSpec(job.Spec, job.Annotations, cls)
+	if err != nil {
+		return nil, err
+	}
+
+	leafs := make(map[string]*unstructured.Unstructured, len(specs))
+	for name, spec := range specs {
+		leaf := job.DeepCopy()
+		leaf.Spec = spec
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(leaf)
+		if err != nil {
+			return nil, err
+		}
+		leafs[name] = &unstructured.Unstructured{Object: obj}
+	}
+	return leafs, nil
+}
+
+// splitJobSpec returns the JobSpec to run on each Cluster for the given
+// CompletionsAnnotation. When splitting, Clusters whose share of the
+// completions is zero are left out.
+func splitJobSpec(spec batchv1.JobSpec, annotations map[string]string, cls []*v1alpha1.Cluster) (map[string]batchv1.JobSpec, error) {
+	mode, err := completionsModeFor(annotations)
+	if err != nil {
+		return nil, &splitter.UnsatisfiableError{Message: err.Error()}
+	}
+
+	specs := make(map[string]batchv1.JobSpec, len(cls))
+	if mode == CompletionsDuplicate {
+		for _, cl := range cls {
+			specs[cl.Name] = *spec.DeepCopy()
+		}
+		return specs, nil
+	}
+
+	completions, parallelism := int32(1), int32(1)
+	if spec.Completions != nil {
+		completions = *spec.Completions
+	}
+	if spec.Parallelism != nil {
+		parallelism = *spec.Parallelism
+	}
+	completionShares := placement.DistributeReplicas(completions, cls)
+	parallelismShares := placement.DistributeReplicas(parallelism, cls)
+	for _, cl := range cls {
+		c := completionShares[cl.Name]
+		if c == 0 {
+			continue
+		}
+		// Every Cluster with completions to run needs at least one pod at a time.
+		p := parallelismShares[cl.Name]
+		if p < 1 {
+			p = 1
+		}
+		if p > c {
+			p = c
+		}
+		s := *spec.DeepCopy()
+		s.Completions, s.Parallelism = &c, &p
+		specs[cl.Name] = s
+	}
+	return specs, nil
+}
+
+func completionsModeFor(annotations map[string]string) (CompletionsMode, error) {
+	switch mode := CompletionsMode(annotations[CompletionsAnnotation]); mode {
+	case "", CompletionsDuplicate:
+		return CompletionsDuplicate, nil
+	case CompletionsSplit:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown %s %q, must be one of %q or %q", CompletionsAnnotation, mode, CompletionsDuplicate, CompletionsSplit)
+	}
+}
+
+// AggregateStatus sums the leafs' pod counts into the root. The root is
+// Complete once every leaf is, and Failed as soon as any leaf is.
+func (JobStrategy) AggregateStatus(root *unstructured.Unstructured, leafs []*unstructured.Unstructured) error {
+	job := &batchv1.Job{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(root.Object, job); err != nil {
+		return err
+	}
+	jobs := make([]*batchv1.Job, 0, len(leafs))
+	for _, leaf := range leafs {
+		j := &batchv1.Job{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(leaf.Object, j); err != nil {
+			return err
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Labels[clusterLabel] < jobs[j].Labels[clusterLabel] })
+
+	status := batchv1.JobStatus{}
+	complete := len(jobs) > 0
+	var failed []string
+	var failedCond *batchv1.JobCondition
+	for _, j := range jobs {
+		status.Active += j.Status.Active
+		status.Succeeded += j.Status.Succeeded
+		status.Failed += j.Status.Failed
+		if j.Status.StartTime != nil && (status.StartTime == nil || j.Status.StartTime.Before(status.StartTime)) {
+			status.StartTime = j.Status.StartTime.DeepCopy()
+		}
+		if j.Status.CompletionTime != nil && (status.CompletionTime == nil || status.CompletionTime.Before(j.Status.CompletionTime)) {
+			status.CompletionTime = j.Status.CompletionTime.DeepCopy()
+		}
+
+		if cond := getJobCondition(j.Status, batchv1.JobFailed); cond != nil && cond.Status == corev1.ConditionTrue {
+			if failedCond == nil {
+				failedCond = cond.DeepCopy()
+			}
+			failed = append(failed, fmt.Sprintf("%s: %s", j.Labels[clusterLabel], cond.Message))
+		}
+		if cond := getJobCondition(j.Status, batchv1.JobComplete); cond == nil || cond.Status != corev1.ConditionTrue {
+			complete = false
+		}
+	}
+
+	switch {
+	case failedCond != nil:
+		failedCond.Message = strings.Join(failed, "; ")
+		status.Conditions = []batchv1.JobCondition{*failedCond}
+		status.CompletionTime = nil
+	case complete:
+		cond := *getJobCondition(jobs[len(jobs)-1].Status, batchv1.JobComplete)
+		cond.Message = fmt.Sprintf("Complete on all %d clusters", len(jobs))
+		status.Conditions = []batchv1.JobCondition{cond}
+	default:
+		// The completion time is only set once the whole Job has completed.
+		status.CompletionTime = nil
+	}
+	for i := range status.Conditions {
+		if prev := getJobCondition(job.Status, status.Conditions[i].Type); prev != nil && prev.Status == status.Conditions[i].Status {
+			status.Conditions[i].LastTransitionTime = prev.LastTransitionTime
+		}
+	}
+
+	job.Status = status
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		return err
+	}
+	root.Object["status"] = obj["status"]
+	return nil
+}
+
+func getJobCondition(status batchv1.JobStatus, t batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == t {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
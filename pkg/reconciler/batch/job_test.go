@@ -0,0 +1,132 @@
+package batch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/splitter"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func clusters(names ...string) []*v1alpha1.Cluster {
+	cls := make([]*v1alpha1.Cluster, 0, len(names))
+	for _, name := range names {
+		cls = append(cls, &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return cls
+}
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func toUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: u}
+}
+
+func job(mode CompletionsMode, completions, parallelism *int32) *batchv1.Job {
+	j := &batchv1.Job{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{Name: "pi", Namespace: "default"},
+		Spec:       batchv1.JobSpec{Completions: completions, Parallelism: parallelism},
+	}
+	if mode != "" {
+		j.Annotations = map[string]string{CompletionsAnnotation: string(mode)}
+	}
+	return j
+}
+
+// shares are the completions and parallelism of a leaf's JobSpec, nil
+// counting as 0.
+type shares struct{ completions, parallelism int32 }
+
+func sharesOf(spec batchv1.JobSpec) shares {
+	var s shares
+	if spec.Completions != nil {
+		s.completions = *spec.Completions
+	}
+	if spec.Parallelism != nil {
+		s.parallelism = *spec.Parallelism
+	}
+	return s
+}
+
+func TestJobSplit(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		job      *batchv1.Job
+		clusters []*v1alpha1.Cluster
+		want     map[string]shares
+	}{{
+		name:     "duplicated by default",
+		job:      job("", int32Ptr(5), int32Ptr(2)),
+		clusters: clusters("east", "west"),
+		want:     map[string]shares{"east": {5, 2}, "west": {5, 2}},
+	}, {
+		name:     "duplicated",
+		job:      job(CompletionsDuplicate, nil, nil),
+		clusters: clusters("east", "west"),
+		want:     map[string]shares{"east": {}, "west": {}},
+	}, {
+		name:     "split",
+		job:      job(CompletionsSplit, int32Ptr(5), int32Ptr(4)),
+		clusters: clusters("east", "west"),
+		want:     map[string]shares{"east": {3, 2}, "west": {2, 2}},
+	}, {
+		name:     "split with a pod at a time on each cluster",
+		job:      job(CompletionsSplit, int32Ptr(4), int32Ptr(1)),
+		clusters: clusters("east", "west"),
+		want:     map[string]shares{"east": {2, 1}, "west": {2, 1}},
+	}, {
+		name:     "split with no more pods at a time than completions",
+		job:      job(CompletionsSplit, int32Ptr(2), int32Ptr(6)),
+		clusters: clusters("east", "west"),
+		want:     map[string]shares{"east": {1, 1}, "west": {1, 1}},
+	}, {
+		name:     "split with fewer completions than clusters",
+		job:      job(CompletionsSplit, int32Ptr(2), int32Ptr(2)),
+		clusters: clusters("east", "north", "west"),
+		want:     map[string]shares{"east": {1, 1}, "north": {1, 1}},
+	}, {
+		name:     "split without completions",
+		job:      job(CompletionsSplit, nil, nil),
+		clusters: clusters("east", "west"),
+		want:     map[string]shares{"east": {1, 1}},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			leafs, err := JobStrategy{}.Split(toUnstructured(t, tc.job), tc.clusters, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]shares{}
+			for name, leaf := range leafs {
+				j := &batchv1.Job{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(leaf.Object, j); err != nil {
+					t.Fatal(err)
+				}
+				if j.Name != "pi" || !reflect.DeepEqual(j.Annotations, tc.job.Annotations) {
+					t.Errorf("%s: got metadata %v", name, j.ObjectMeta)
+				}
+				got[name] = sharesOf(j.Spec)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got completions and parallelism %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJobSplitInvalidMode(t *testing.T) {
+	_, err := JobStrategy{}.Split(toUnstructured(t, job("sometimes", int32Ptr(2), nil)), clusters("east"), nil)
+	var unsatisfiable *splitter.UnsatisfiableError
+	if !errors.As(err, &unsatisfiable) {
+		t.Errorf("got %v, want an UnsatisfiableError", err)
+	}
+}
@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+)
+
+// capacityResources are the resources reported in a Cluster's status, along
+// with extended ones such as GPUs.
+var capacityResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourcePods}
+
+// reportCapacity sets the allocatable resources of the Cluster's Ready,
+// schedulable nodes in its status, how much of them the pods on those nodes,
+// or waiting for one, request, and the nodes' architectures.
+func reportCapacity(ctx context.Context, client kubernetes.Interface, cluster *v1alpha1.Cluster) error {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	allocatable := corev1.ResourceList{}
+	schedulable, architectures := sets.NewString(), sets.NewString()
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || !nodeReady(&node) {
+			continue
+		}
+		schedulable.Insert(node.Name)
+		if arch := node.Labels[corev1.LabelArchStable]; arch != "" {
+			architectures.Insert(arch)
+		}
+		for name, q := range node.Status.Allocatable {
+			if reportedResource(name) {
+				total := allocatable[name]
+				total.Add(q)
+				allocatable[name] = total
+			}
+		}
+	}
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=" + string(corev1.PodSucceeded) + ",status.phase!=" + string(corev1.PodFailed),
+	})
+	if err != nil {
+		return err
+	}
+	requested := corev1.ResourceList{}
+	var podCount int64
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" && !schedulable.Has(pod.Spec.NodeName) {
+			continue
+		}
+		for name, q := range placement.PodRequests(&pod.Spec) {
+			total := requested[name]
+			total.Add(q)
+			requested[name] = total
+		}
+		podCount++
+	}
+	requested[corev1.ResourcePods] = *resource.NewQuantity(podCount, resource.DecimalSI)
+
+	cluster.Status.Allocatable = allocatable
+	cluster.Status.Requested = requested
+	cluster.Status.Architectures = nil
+	if architectures.Len() > 0 {
+		cluster.Status.Architectures = architectures.List()
+	}
+	return nil
+}
+
+func reportedResource(name corev1.ResourceName) bool {
+	for _, r := range capacityResources {
+		if r == name {
+			return true
+		}
+	}
+	return placement.IsExtendedResource(name)
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -3,159 +3,382 @@ package cluster
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
 	"github.com/kcp-dev/kcp/pkg/crdpuller"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/negotiation"
+	"github.com/kcp-dev/kcp/pkg/registration"
+	"github.com/kcp-dev/kcp/pkg/syncer"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/runtime"
 	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/klog"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
-const pollInterval = time.Minute
+const (
+	pollInterval = time.Minute
 
-func clusterOriginLabel(clusterID string) string {
-	return "imported-from/" + clusterID
-}
+	// probeTimeout bounds each probe of a Cluster's API server.
+	probeTimeout = 10 * time.Second
+)
 
 func (c *Controller) reconcile(ctx context.Context, cluster *v1alpha1.Cluster) error {
-	log.Println("reconciling cluster", cluster.Name)
+	ctx, logger := logging.WithValues(ctx, logging.ClusterKey, cluster.Name)
+	logger.V(2).Info("Reconciling cluster")
 
 	logicalCluster := cluster.GetClusterName()
 	logicalClusterContext := genericapirequest.WithCluster(ctx, genericapirequest.Cluster{
 		Name: logicalCluster,
 	})
 
+	if token, found := cluster.Annotations[registration.TokenAnnotation]; found {
+		if err := c.admit(logicalClusterContext, cluster, token); err != nil {
+			return err
+		}
+	}
+	if cluster.Spec.KubeConfig == "" && cluster.Spec.KubeConfigSecretRef == nil && cluster.Status.Conditions.Get(v1alpha1.ClusterConditionAccepted) != nil {
+		// Registered by a syncer that dials out to kcp, which may not be
+		// able to reach the cluster itself.
+		if cluster.Status.Conditions.IsAccepted() {
+			// Whoever registered it runs the syncer, with the resources
+			// they chose, hopefully those of the Cluster.
+			if _, err := c.negotiateResources(nil, cluster); err != nil {
+				return err
+			}
+			recheck, err := c.checkHeartbeat(logicalClusterContext, cluster, time.Now())
+			if err != nil {
+				return err
+			}
+			c.enqueueAfter(cluster, recheck)
+		} else {
+			conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
+				"NotAccepted",
+				"The cluster's registration was not accepted")
+		}
+		return nil
+	}
+
+	kubeconfig, err := KubeConfigFor(logicalClusterContext, c.kubeClient, cluster)
+	if errors.IsNotFound(err) {
+		// Reconciled again once the Secret is created.
+		logger.Error(err, "Kubeconfig Secret not found")
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
+			"KubeConfigSecretNotFound",
+			fmt.Sprintf("Kubeconfig Secret not found: %v", err))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
 	// Get client from kubeconfig
-	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(cluster.Spec.KubeConfig))
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
 	if err != nil {
-		log.Printf("invalid kubeconfig: %v", err)
-		cluster.Status.Conditions.SetReady(corev1.ConditionFalse,
+		logger.Error(err, "Invalid kubeconfig")
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
 			"InvalidKubeConfig",
 			fmt.Sprintf("Invalid kubeconfig: %v", err))
 		return nil // Don't retry.
 	}
 	client, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
-		log.Printf("error creating client: %v", err)
-		cluster.Status.Conditions.SetReady(corev1.ConditionFalse,
+		logger.Error(err, "Error creating client")
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
 			"ErrorCreatingClient",
 			fmt.Sprintf("Error creating client from kubeconfig: %v", err))
 		return nil // Don't retry.
 	}
 
+	// Probe the Cluster first, and keep probing it until it's reachable
+	// again; its syncer and schemas can't be checked meanwhile.
+	if err := probe(ctx, client); err != nil {
+		logger.V(2).Info("Cluster unreachable", "reason", err.Error())
+		conditions.Set(cluster, v1alpha1.ClusterConditionUnreachable, corev1.ConditionTrue,
+			"ProbeFailed",
+			fmt.Sprintf("Error probing API server: %v", err))
+		c.enqueueAfter(cluster, pollInterval)
+		switch {
+		case !cluster.Status.Conditions.HasReady():
+			// A Cluster without the Ready condition yet still needs its syncer installed once reachable.
+		case c.pullModel:
+			// Its syncer dials out to kcp, and may well be up.
+			recheck, err := c.checkHeartbeat(logicalClusterContext, cluster, time.Now())
+			if err != nil {
+				return err
+			}
+			if recheck < pollInterval {
+				c.enqueueAfter(cluster, recheck)
+			}
+		default:
+			conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
+				"Unreachable",
+				"Cluster API server is unreachable")
+		}
+		return nil
+	}
+	conditions.Set(cluster, v1alpha1.ClusterConditionUnreachable, corev1.ConditionFalse,
+		"ProbeSucceeded",
+		"Cluster API server is reachable")
+
+	if err := reportCapacity(ctx, client, cluster); err != nil {
+		// Keep the capacity last reported; the splitters can live with it for a while.
+		logger.Error(err, "Error gathering cluster capacity")
+	}
+	if err := reportStorageClasses(ctx, client, cluster); err != nil {
+		// Likewise for the StorageClasses.
+		logger.Error(err, "Error listing cluster StorageClasses")
+	}
+	enforcing := cluster.Status.NetworkPolicies
+	if err := reportNetworkPolicies(ctx, client, cluster); err != nil {
+		// And whether it enforces NetworkPolicies.
+		logger.Error(err, "Error detecting cluster NetworkPolicy support")
+	}
+	policiesChanged := c.networkPolicies && cluster.Status.NetworkPolicies != enforcing
+	if err := labelTopology(ctx, client, cluster); err != nil {
+		// The Cluster is labeled on a later reconcile.
+		logger.Error(err, "Error labeling cluster topology")
+	}
+	resourcesChanged, err := c.negotiateResources(client.Discovery(), cluster)
+	if err != nil {
+		logger.Error(err, "Error negotiating synced resources")
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
+			"ErrorNegotiatingResources",
+			fmt.Sprintf("Error negotiating the resources to sync: %v", err))
+		c.enqueueAfter(cluster, pollInterval)
+		return nil
+	}
+
 	schemaPuller, err := crdpuller.NewSchemaPuller(cfg)
 	if err != nil {
-		log.Printf("error creating schemapuller: %v", err)
-		cluster.Status.Conditions.SetReady(corev1.ConditionFalse,
+		logger.Error(err, "Error creating schema puller")
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
 			"ErrorCreatingSchemaPuller",
 			fmt.Sprintf("Error creating schema puller client from kubeconfig: %v", err))
 		return nil // Don't retry.
 	}
 
-	crds, err := schemaPuller.PullCRDs(ctx, "pods", "deployments")
+	crds, err := schemaPuller.PullCRDs(ctx, c.syncedResources(cluster)...)
 	if err != nil {
-		log.Printf("error pulling CRDs: %v", err)
-		cluster.Status.Conditions.SetReady(corev1.ConditionFalse,
+		logger.Error(err, "Error pulling CRDs")
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
 			"ErrorPullingResourceSchemas",
 			fmt.Sprintf("Error pulling API Resource Schemas from cluster %s: %v", cluster.Name, err))
 		return nil // Don't retry.
 	}
 
-	for resourceName, pulledCrd := range crds {
-		pulledCrd.SetClusterName(logicalCluster)
-		pulledCrd.Labels[clusterOriginLabel(cluster.Name)] = ""
-		clusterCrd, err := c.crdClient.CustomResourceDefinitions().Create(logicalClusterContext, pulledCrd, v1.CreateOptions{})
-		if errors.IsAlreadyExists(err) {
-			clusterCrd, err = c.crdClient.CustomResourceDefinitions().Get(logicalClusterContext, pulledCrd.Name, v1.GetOptions{})
-			if err == nil {
-				if !equality.Semantic.DeepEqual(pulledCrd.Spec, clusterCrd.Spec) ||
-					!equality.Semantic.DeepEqual(pulledCrd.Annotations, clusterCrd.Annotations) ||
-					!equality.Semantic.DeepEqual(pulledCrd.Labels, clusterCrd.Labels) {
-					pulledCrd.ResourceVersion = clusterCrd.ResourceVersion
-					_, err = c.crdClient.CustomResourceDefinitions().Update(logicalClusterContext, pulledCrd, v1.UpdateOptions{})
-				}
-			}
-		}
-		if err != nil {
-			log.Printf("Error when applying CRD pulled from cluster %s for resource %s: %v\n", cluster.Name, resourceName, err)
-		}
+	if err := negotiation.Import(logicalClusterContext, c.client, cluster, crds); err != nil {
+		logger.Error(err, "Error importing pulled CRDs")
 	}
 
 	if !cluster.Status.Conditions.HasReady() {
-		kubeConfig := c.kubeconfig.DeepCopy()
-		if _, exists := kubeConfig.Contexts[logicalCluster]; !exists {
-			log.Printf("error installing syncer: no context with the name of the expected cluster: %s", logicalCluster)
-			cluster.Status.Conditions.SetReady(corev1.ConditionFalse,
+		kubeConfig, err := logicalcluster.Kubeconfig(c.kubeconfig, logicalCluster)
+		if err != nil {
+			logger.Error(err, "Error installing syncer: no kubeconfig context for the logical cluster")
+			conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
 				"ErrorInstallingSyncer",
-				fmt.Sprintf("Error installing syncer: no context with the name of the expected cluster: %s", logicalCluster))
+				fmt.Sprintf("Error installing syncer: %v", err))
 			return nil // Don't retry.
 		}
 
 		if c.pullModel {
-			kubeConfig.CurrentContext = logicalCluster
-			bytes, err := clientcmd.Write(*kubeConfig)
-			if err == nil {
-				err = installSyncer(ctx, client, c.syncerImage, string(bytes), cluster.Name, logicalCluster, c.resourcesToSync)
-			}
-			if err != nil {
-				log.Printf("error installing syncer: %v", err)
-				cluster.Status.Conditions.SetReady(corev1.ConditionFalse,
+			if err := c.installSyncer(ctx, logicalClusterContext, client, cluster, kubeConfig); err != nil {
+				logger.Error(err, "Error installing syncer")
+				metrics.SyncErrors.WithLabelValues(controllerName, cluster.Name).Inc()
+				conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
 					"ErrorInstallingSyncer",
 					fmt.Sprintf("Error installing syncer: %v", err))
 				return nil // Don't retry.
 			}
 
-			log.Println("syncer installing...")
-			cluster.Status.Conditions.SetReady(corev1.ConditionUnknown,
+			logger.Info("Syncer installing")
+			conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionUnknown,
 				"SyncerInstalling",
 				"Installing syncer on cluster")
 		} else {
-			log.Println("syncer ready!")
-			cluster.Status.Conditions.SetReady(corev1.ConditionTrue,
-				"SyncerReady",
-				"Syncer ready")
+			c.runSyncer(ctx, cluster, cfg, kubeconfig, logicalCluster)
 		}
 	} else {
 		if c.pullModel {
-			if err := healthcheckSyncer(ctx, client, logicalCluster); err != nil {
-				log.Println("syncer not yet ready")
-				cluster.Status.Conditions.SetReady(corev1.ConditionFalse,
-					"SyncerNotReady",
-					err.Error())
-			} else {
-				log.Println("syncer ready!")
-				cluster.Status.Conditions.SetReady(corev1.ConditionTrue,
-					"SyncerReady",
-					"Syncer ready")
+			if c.syncerTokenDue(cluster, time.Now()) || resourcesChanged || policiesChanged {
+				logger.Info("Reinstalling syncer, for a new token, synced resources or NetworkPolicy support", "resources", cluster.Status.SyncedResources, "networkPolicies", cluster.Status.NetworkPolicies)
+				kubeConfig, err := logicalcluster.Kubeconfig(c.kubeconfig, logicalCluster)
+				if err == nil {
+					err = c.installSyncer(ctx, logicalClusterContext, client, cluster, kubeConfig)
+				}
+				if err != nil {
+					// The syncer keeps its current token until it expires.
+					logger.Error(err, "Error reinstalling syncer")
+					metrics.SyncErrors.WithLabelValues(controllerName, cluster.Name).Inc()
+				}
+			}
+			recheck, err := c.checkHeartbeat(logicalClusterContext, cluster, time.Now())
+			if err != nil {
+				return err
+			}
+			if recheck < pollInterval {
+				c.enqueueAfter(cluster, recheck)
 			}
 		} else {
-			log.Println("syncer ready!")
-			cluster.Status.Conditions.SetReady(corev1.ConditionTrue,
-				"SyncerReady",
-				"Syncer ready")
+			c.runSyncer(ctx, cluster, cfg, kubeconfig, logicalCluster)
 		}
 	}
 
 	// Enqueue another check later
-	key, err := cache.MetaNamespaceKeyFunc(cluster)
+	c.enqueueAfter(cluster, pollInterval)
+	return nil
+}
+
+// installSyncer installs the syncer of the Cluster in it, with the given
+// kubeconfig minified to only reach its logical cluster, and with a token
+// of its own if the controller issues them, whose expiry it records once
+// installed. logicalClusterContext is that of the Cluster's logical cluster.
+func (c *Controller) installSyncer(ctx, logicalClusterContext context.Context, client kubernetes.Interface, cluster *v1alpha1.Cluster, kubeConfig *clientcmdapi.Config) error {
+	logicalCluster := cluster.GetClusterName()
+	kubeConfig.CurrentContext = logicalCluster
+	var tokenExpiry time.Time
+	if c.syncerTokenTTL > 0 {
+		var err error
+		if tokenExpiry, err = c.useSyncerToken(logicalClusterContext, cluster, kubeConfig); err != nil {
+			return fmt.Errorf("issuing syncer token: %w", err)
+		}
+	}
+	if err := clientcmdapi.MinifyConfig(kubeConfig); err != nil {
+		return err
+	}
+	bytes, err := clientcmd.Write(*kubeConfig)
 	if err != nil {
-		klog.Error(err)
-	} else {
-		c.queue.AddAfter(key, pollInterval)
+		return err
+	}
+	t, err := c.syncerTransforms(cluster, logicalCluster)
+	if err != nil {
+		return err
 	}
+	if err := syncer.Install(ctx, client, syncer.InstallOptions{
+		Image:                 c.syncerImage,
+		Kubeconfig:            string(bytes),
+		ClusterID:             cluster.Name,
+		LogicalCluster:        logicalCluster,
+		Resources:             c.syncedResources(cluster),
+		DriftMode:             c.driftMode,
+		NamespaceMapping:      t.Namespaces,
+		NamespaceStrategy:     t.NamespaceStrategy,
+		ServiceAccountMapping: t.ServiceAccounts,
+		Transformers:          c.transformers,
+		Upsync:                c.upsync,
+		RBACVerbs:             c.rbacVerbs,
+		ServiceImports:        c.serviceImports,
+		NetworkPolicies:       c.syncsNetworkPolicies(cluster),
+		RateLimit:             rateLimitFor(cluster),
+	}); err != nil {
+		return err
+	}
+	if !tokenExpiry.IsZero() {
+		setSyncerTokenExpiry(cluster, tokenExpiry)
+	}
+	return nil
+}
+
+// admit checks the bootstrap token presented by the syncer registering the
+// Cluster, sets its Accepted condition accordingly and removes the token from
+// it. The token is deleted by process once the Cluster is updated.
+func (c *Controller) admit(ctx context.Context, cluster *v1alpha1.Cluster, token string) error {
+	logger := logging.FromContext(ctx)
+	reason, err := registration.Validate(ctx, c.kubeClient, cluster.Name, token, time.Now())
+	if err != nil {
+		return err
+	}
+	delete(cluster.Annotations, registration.TokenAnnotation)
+	if reason != "" {
+		logger.Info("Rejecting cluster registration", "reason", reason)
+		conditions.Set(cluster, v1alpha1.ClusterConditionAccepted, corev1.ConditionFalse,
+			"InvalidBootstrapToken",
+			reason)
+		return nil
+	}
+	logger.Info("Accepting cluster registration")
+	conditions.Set(cluster, v1alpha1.ClusterConditionAccepted, corev1.ConditionTrue,
+		"BootstrapTokenValid",
+		"Syncer registered the cluster with a valid bootstrap token")
 	return nil
 }
 
-func (c *Controller) cleanup(ctx context.Context, deletedCluster *v1alpha1.Cluster) {
-	log.Println("cleanup resources for cluster", deletedCluster.Name)
+// KubeConfigFor returns the kubeconfig to reach the Cluster with, read from
+// its Secret if it refers to one. The context must be that of the Cluster's
+// logical cluster.
+func KubeConfigFor(ctx context.Context, kubeClient kubernetes.Interface, cluster *v1alpha1.Cluster) (string, error) {
+	ref := cluster.Spec.KubeConfigSecretRef
+	if ref == nil {
+		return cluster.Spec.KubeConfig, nil
+	}
+	secret, err := kubeClient.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	key := ref.Key
+	if key == "" {
+		key = v1alpha1.DefaultKubeConfigSecretKey
+	}
+	kubeconfig, found := secret.Data[key]
+	if !found {
+		return "", errors.NewNotFound(corev1.Resource("secrets"), fmt.Sprintf("%s/%s, key %s", ref.Namespace, ref.Name, key))
+	}
+	return string(kubeconfig), nil
+}
+
+// runSyncer makes sure a syncer pushes to the Cluster from this process, with
+// the given kubeconfig, and sets the Cluster's Ready condition accordingly.
+func (c *Controller) runSyncer(ctx context.Context, cluster *v1alpha1.Cluster, cfg *rest.Config, kubeconfig, logicalCluster string) {
+	logger := logging.FromContext(ctx)
+	t, err := c.syncerTransforms(cluster, logicalCluster)
+	if err == nil {
+		err = c.startSyncer(ctx, cfg, kubeconfig, cluster.Name, logicalCluster, c.syncedResources(cluster), t, rateLimitFor(cluster), c.syncsNetworkPolicies(cluster))
+	}
+	if err != nil {
+		logger.Error(err, "Error starting syncer")
+		metrics.SyncErrors.WithLabelValues(controllerName, cluster.Name).Inc()
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
+			"ErrorStartingSyncer",
+			fmt.Sprintf("Error starting syncer: %v", err))
+		return
+	}
+	logger.V(2).Info("Syncer ready")
+	conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionTrue,
+		"SyncerReady",
+		"Syncer ready")
+}
+
+// probe checks that the Cluster's API server is up and reachable.
+func probe(ctx context.Context, client kubernetes.Interface) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	return client.Discovery().RESTClient().Get().AbsPath("/healthz").Do(ctx).Error()
+}
+
+func (c *Controller) enqueueAfter(cluster *v1alpha1.Cluster, after time.Duration) {
+	key, err := logicalcluster.KeyFunc(cluster)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.queue.AddAfter(key, after)
+}
+
+// cleanup removes what kcp set up for a deleted Cluster, returning what
+// failed for it to be retried.
+func (c *Controller) cleanup(ctx context.Context, deletedCluster *v1alpha1.Cluster) error {
+	logger := logging.FromContext(ctx)
+	logger.Info("Cleaning up resources for cluster")
 
 	logicalCluster := deletedCluster.GetClusterName()
 
@@ -163,41 +386,71 @@ func (c *Controller) cleanup(ctx context.Context, deletedCluster *v1alpha1.Clust
 		Name: logicalCluster,
 	})
 
+	var errs []error
 	crds, err := c.crdClient.CustomResourceDefinitions().List(logicalClusterContext, v1.ListOptions{
-		LabelSelector: clusterOriginLabel(deletedCluster.Name),
+		LabelSelector: negotiation.OriginLabel(deletedCluster.Name),
 	})
 	if err != nil {
-		klog.Error(err)
-	}
-	for _, crd := range crds.Items {
-		if len(crd.Labels) == 1 {
-			if _, exists := crd.Labels[clusterOriginLabel(deletedCluster.Name)]; exists {
-				err := c.crdClient.CustomResourceDefinitions().Delete(logicalClusterContext, crd.Name, v1.DeleteOptions{})
+		errs = append(errs, fmt.Errorf("listing the CRDs pulled from the cluster: %w", err))
+	} else {
+		for _, crd := range crds.Items {
+			if len(crd.Labels) == 1 {
+				if _, exists := crd.Labels[negotiation.OriginLabel(deletedCluster.Name)]; exists {
+					err := c.crdClient.CustomResourceDefinitions().Delete(logicalClusterContext, crd.Name, v1.DeleteOptions{})
+					if err != nil && !errors.IsNotFound(err) {
+						errs = append(errs, fmt.Errorf("deleting CRD %s pulled from the cluster: %w", crd.Name, err))
+					}
+				}
+			} else {
+				updated := crd.DeepCopy()
+				delete(updated.Labels, negotiation.OriginLabel(deletedCluster.Name))
+				_, err := c.crdClient.CustomResourceDefinitions().Update(logicalClusterContext, updated, v1.UpdateOptions{})
 				if err != nil {
-					klog.Error(err)
+					errs = append(errs, fmt.Errorf("updating CRD %s pulled from the cluster: %w", crd.Name, err))
 				}
 			}
-		} else {
-			updated := crd.DeepCopy()
-			delete(updated.Labels, clusterOriginLabel(deletedCluster.Name))
-			_, err := c.crdClient.CustomResourceDefinitions().Update(logicalClusterContext, updated, v1.UpdateOptions{})
-			if err != nil {
-				klog.Error(err)
-			}
 		}
 	}
 
-	if c.pullModel {
-		// Get client from kubeconfig
-		cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(deletedCluster.Spec.KubeConfig))
-		if err != nil {
-			klog.Errorf("invalid kubeconfig: %v", err)
-		}
-		client, err := kubernetes.NewForConfig(cfg)
-		if err != nil {
-			klog.Errorf("error creating client: %v", err)
-		}
+	// Release what its syncer held once it's gone, for it not to hold them again.
+	if err := c.uninstallSyncer(ctx, logicalClusterContext, deletedCluster); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.releaseFinalizers(ctx, deletedCluster.Name, logicalCluster, c.syncedResources(deletedCluster)); err != nil {
+		errs = append(errs, fmt.Errorf("releasing the objects synced to the cluster: %w", err))
+	}
+	if err := c.kubeClient.CoordinationV1().Leases(syncer.LeaseNamespace).Delete(logicalClusterContext, deletedCluster.Name, v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		errs = append(errs, fmt.Errorf("deleting the syncer heartbeat lease: %w", err))
+	}
+	return utilerrors.NewAggregate(errs)
+}
 
-		uninstallSyncer(ctx, client, logicalCluster)
+// uninstallSyncer stops or uninstalls the syncer kcp runs for a deleted
+// Cluster, if it runs one.
+func (c *Controller) uninstallSyncer(ctx, logicalClusterContext context.Context, deletedCluster *v1alpha1.Cluster) error {
+	logicalCluster := deletedCluster.GetClusterName()
+	if deletedCluster.Spec.KubeConfig == "" && deletedCluster.Spec.KubeConfigSecretRef == nil {
+		// Its syncer was installed by whoever registered it.
+		return nil
 	}
+	if !c.pullModel {
+		c.stopSyncer(deletedCluster.Name, logicalCluster)
+		return nil
+	}
+	kubeconfig, err := KubeConfigFor(logicalClusterContext, c.kubeClient, deletedCluster)
+	if err != nil {
+		return fmt.Errorf("reading the kubeconfig to uninstall the syncer with: %w", err)
+	}
+	// Get client from kubeconfig
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	syncer.Uninstall(ctx, client, logicalCluster)
+	return nil
 }
@@ -0,0 +1,164 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func kubeconfigSecret(name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kcp-clusters", ClusterName: "admin"}, Data: map[string][]byte{}}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func secretCluster(name string, ref *v1alpha1.KubeConfigSecretReference) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: "admin"},
+		Spec:       v1alpha1.ClusterSpec{KubeConfigSecretRef: ref},
+	}
+}
+
+func TestKubeConfigFor(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		ref     *v1alpha1.KubeConfigSecretReference
+		secrets []apiruntime.Object
+		want    string
+		// notFound is whether the kubeconfig is reported as not found.
+		notFound bool
+	}{{
+		name: "inline",
+		want: "inline",
+	}, {
+		name:    "default key",
+		ref:     &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east"},
+		secrets: []apiruntime.Object{kubeconfigSecret("east", map[string]string{v1alpha1.DefaultKubeConfigSecretKey: "from secret"})},
+		want:    "from secret",
+	}, {
+		name:    "custom key",
+		ref:     &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east", Key: "value"},
+		secrets: []apiruntime.Object{kubeconfigSecret("east", map[string]string{"value": "from key", v1alpha1.DefaultKubeConfigSecretKey: "default"})},
+		want:    "from key",
+	}, {
+		name:     "missing Secret",
+		ref:      &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east"},
+		notFound: true,
+	}, {
+		name:     "missing key",
+		ref:      &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east", Key: "value"},
+		secrets:  []apiruntime.Object{kubeconfigSecret("east", map[string]string{v1alpha1.DefaultKubeConfigSecretKey: "default"})},
+		notFound: true,
+	}, {
+		name:     "missing default key",
+		ref:      &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east"},
+		secrets:  []apiruntime.Object{kubeconfigSecret("east", map[string]string{"value": "other"})},
+		notFound: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := secretCluster("east", tc.ref)
+			if tc.ref == nil {
+				cluster.Spec.KubeConfig = "inline"
+			}
+			got, err := KubeConfigFor(context.Background(), fake.NewSimpleClientset(tc.secrets...), cluster)
+			switch {
+			case tc.notFound && !apierrors.IsNotFound(err):
+				t.Errorf("got %v, want NotFound", err)
+			case !tc.notFound && err != nil:
+				t.Error(err)
+			case got != tc.want:
+				t.Errorf("got kubeconfig %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestReconcileSecretNotFound checks that Clusters whose kubeconfig Secret or
+// key is missing are reported not ready, rather than retried, until the
+// Secret changes.
+func TestReconcileSecretNotFound(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		secrets []apiruntime.Object
+		reason  string
+	}{{
+		name:   "missing Secret",
+		reason: "KubeConfigSecretNotFound",
+	}, {
+		name:    "missing key",
+		secrets: []apiruntime.Object{kubeconfigSecret("east", map[string]string{"value": "other"})},
+		reason:  "KubeConfigSecretNotFound",
+	}, {
+		name:    "invalid kubeconfig",
+		secrets: []apiruntime.Object{kubeconfigSecret("east", map[string]string{v1alpha1.DefaultKubeConfigSecretKey: "{"})},
+		reason:  "InvalidKubeConfig",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Controller{
+				logger:     logging.New(controllerName),
+				kubeClient: fake.NewSimpleClientset(tc.secrets...),
+			}
+			cluster := secretCluster("east", &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east"})
+			if err := c.reconcile(context.Background(), cluster); err != nil {
+				t.Fatal(err)
+			}
+			ready := cluster.Status.Conditions.Get(v1alpha1.ClusterConditionReady)
+			if ready == nil || ready.Status != corev1.ConditionFalse || ready.Reason != tc.reason {
+				t.Errorf("got Ready condition %v, want reason %s", ready, tc.reason)
+			}
+		})
+	}
+}
+
+// TestSecretRotation checks that the Clusters referring to a Secret are
+// reconciled again when it changes, and read the rotated kubeconfig.
+func TestSecretRotation(t *testing.T) {
+	secret := kubeconfigSecret("east", map[string]string{v1alpha1.DefaultKubeConfigSecretKey: "old"})
+	kubeClient := fake.NewSimpleClientset(secret)
+	c := &Controller{
+		logger:     logging.New(controllerName),
+		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		indexer:    cache.NewIndexer(logicalcluster.KeyFunc, cache.Indexers{byKubeConfigSecret: indexByKubeConfigSecret}),
+		kubeClient: kubeClient,
+	}
+	defer c.queue.ShutDown()
+
+	east := secretCluster("east", &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east"})
+	other := secretCluster("west", &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "west"})
+	elsewhere := secretCluster("east", &v1alpha1.KubeConfigSecretReference{Namespace: "kcp-clusters", Name: "east"})
+	elsewhere.ClusterName = "other"
+	for _, cl := range []*v1alpha1.Cluster{east, other, elsewhere, {ObjectMeta: metav1.ObjectMeta{Name: "inline", ClusterName: "admin"}}} {
+		if err := c.indexer.Add(cl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated := secret.DeepCopy()
+	rotated.Data[v1alpha1.DefaultKubeConfigSecretKey] = []byte("new")
+	if _, err := kubeClient.CoreV1().Secrets("kcp-clusters").Update(context.Background(), rotated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	c.enqueueClustersOfSecret(rotated)
+
+	if got := c.queue.Len(); got != 1 {
+		t.Fatalf("got %d queued Clusters", got)
+	}
+	if item, _ := c.queue.Get(); item != logicalcluster.Key("admin", "", "east") {
+		t.Errorf("got queued item %v", item)
+	}
+	if got, err := KubeConfigFor(context.Background(), kubeClient, east); err != nil || got != "new" {
+		t.Errorf("got kubeconfig %q, %v after rotation", got, err)
+	}
+}
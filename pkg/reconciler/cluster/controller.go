@@ -2,80 +2,226 @@ package cluster
 
 import (
 	"context"
-	"io/ioutil"
-	"log"
+	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/kcp-dev/kcp/config"
 	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
-	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
 	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
-	"github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
+	"github.com/kcp-dev/kcp/pkg/health"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/registration"
+	"github.com/kcp-dev/kcp/pkg/syncer"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/util/workqueue"
-	"k8s.io/klog"
-	"sigs.k8s.io/yaml"
 )
 
-const resyncPeriod = 10 * time.Hour
+const (
+	resyncPeriod   = 10 * time.Hour
+	controllerName = "cluster"
+
+	// byKubeConfigSecret indexes Clusters by the Secret holding their
+	// kubeconfig, by its logicalcluster.Key.
+	byKubeConfigSecret = "kubeconfigSecret"
+)
 
 // NewController returns a new Controller which reconciles Cluster resources in the API
 // server it reaches using the REST client.
 //
-// When new Clusters are found, the syncer will be run there using the given image.
-func NewController(cfg *rest.Config, syncerImage string, kubeconfig clientcmdapi.Config, resourcesToSync []string, pullModel bool) *Controller {
+// When new Clusters are found, the syncer will be run there using the given
+// image with the pull model, and otherwise in this process, pushing to them.
+// Either way, the syncers handle changes made to the synced objects on their
+// Cluster as set by driftMode.
+//
+// Clusters registered by their syncer, with a bootstrap token, are admitted
+// by setting their Accepted condition. They may have no kubeconfig, as kcp
+// doesn't need to reach them.
+//
+// Clusters may keep their kubeconfig in a Secret, in which case they're
+// reconciled again whenever it changes.
+//
+// If syncerTokenTTL isn't zero, the syncers installed with the pull model
+// reach kcp with tokens of ServiceAccounts of their own, in the
+// SyncerNamespace of their logical cluster, valid for that long and
+// replaced before they expire, rather than with the credentials of
+// kubeconfig.
+func NewController(cfg *rest.Config, syncerImage string, kubeconfig clientcmdapi.Config, resourcesToSync []string, pullModel bool, driftMode syncer.DriftMode, syncerTokenTTL time.Duration, shared *base.Informers) *Controller {
 	client := clusterv1alpha1.NewForConfigOrDie(cfg)
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	metrics.Register()
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerName)
 	stopCh := make(chan struct{}) // TODO: hook this up to SIGTERM/SIGINT
 
 	crdClient := apiextensionsv1client.NewForConfigOrDie(cfg)
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
 
 	c := &Controller{
+		logger:          logging.New(controllerName),
 		queue:           queue,
 		client:          client,
 		crdClient:       crdClient,
+		kubeClient:      kubeClient,
 		syncerImage:     syncerImage,
 		kubeconfig:      kubeconfig,
 		stopCh:          stopCh,
 		resourcesToSync: resourcesToSync,
 		pullModel:       pullModel,
+		driftMode:       driftMode,
+		syncerTokenTTL:  syncerTokenTTL,
+		syncers:         map[string]pushSyncer{},
+		deleted:         map[string]*v1alpha1.Cluster{},
 	}
 
-	sif := externalversions.NewSharedInformerFactoryWithOptions(clusterclient.NewForConfigOrDie(cfg), resyncPeriod)
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	sif, ksif := shared.Cluster, shared.Kube
 	sif.Cluster().V1alpha1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    func(obj interface{}) { c.enqueue(obj) },
 		UpdateFunc: func(_, obj interface{}) { c.enqueue(obj) },
 		DeleteFunc: func(obj interface{}) { c.deletedCluster(obj) },
 	})
-	c.indexer = sif.Cluster().V1alpha1().Clusters().Informer().GetIndexer()
-	sif.WaitForCacheSync(stopCh)
-	sif.Start(stopCh)
+	sif.Cluster().V1alpha1().Clusters().Informer().AddIndexers(cache.Indexers{byKubeConfigSecret: indexByKubeConfigSecret})
+	c.indexer = logicalcluster.IndexerFor(sif.Cluster().V1alpha1().Clusters().Informer())
+	c.synced = append(c.synced, sif.Cluster().V1alpha1().Clusters().Informer().HasSynced)
+
+	// Rotated credentials are picked up without waiting for the next poll.
+	ksif.Core().V1().Secrets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueClustersOfSecret(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueClustersOfSecret(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueClustersOfSecret(obj) },
+	})
+	c.synced = append(c.synced, ksif.Core().V1().Secrets().Informer().HasSynced)
+	if own {
+		sif.Start(stopCh)
+		ksif.Start(stopCh)
+	}
 
 	return c
 }
 
 type Controller struct {
+	logger          logr.Logger
 	queue           workqueue.RateLimitingInterface
 	client          clusterv1alpha1.ClusterV1alpha1Interface
 	indexer         cache.Indexer
 	crdClient       apiextensionsv1client.ApiextensionsV1Interface
+	kubeClient      kubernetes.Interface
 	syncerImage     string
 	kubeconfig      clientcmdapi.Config
 	stopCh          chan struct{}
 	resourcesToSync []string
 	pullModel       bool
+	driftMode       syncer.DriftMode
+	syncerTokenTTL  time.Duration
+	transformers    []string
+	upsync          []string
+	rbacVerbs       []string
+	serviceImports  bool
+	networkPolicies bool
+
+	// synced report whether the caches of the informers have synced, for
+	// Start to wait for.
+	synced []cache.InformerSynced
+	// inFlight tracks the keys the workers are processing, for
+	// LivenessCheck.
+	inFlight health.InFlight
+	// requeueDropped is how long after being dropped the keys that failed
+	// every retry are processed again, never if zero.
+	requeueDropped time.Duration
+
+	// syncers run in process when not using the pull model, by Cluster.
+	syncersLock sync.Mutex
+	syncers     map[string]pushSyncer
+
+	// deleted holds the last known state of the deleted Clusters whose key
+	// is queued, for what they left behind to be cleaned up.
+	deletedLock sync.Mutex
+	deleted     map[string]*v1alpha1.Cluster
+}
+
+// SetDroppedRequeue has the keys dropped after failing every retry processed
+// again after the given delay, rather than left until their Cluster changes
+// or is resynced, for when the informers don't resync. It must be called
+// before Start.
+func (c *Controller) SetDroppedRequeue(after time.Duration) {
+	c.requeueDropped = after
+}
+
+// SetSyncerTransformers makes the syncers run the named registered
+// syncer.Transformers, in order, after the mappings of their Cluster's
+// annotations. It must be called before Start.
+func (c *Controller) SetSyncerTransformers(names []string) {
+	c.transformers = names
+}
+
+// SetSyncerUpsync makes the syncers mirror the objects of the given
+// resources on their Cluster to kcp, e.g. its Pods. It must be called before
+// Start.
+func (c *Controller) SetSyncerUpsync(resources []string) {
+	c.upsync = resources
+}
+
+// SetSyncerRBACVerbs makes the syncers sync the Roles bound to the
+// ServiceAccounts of the objects they sync, granting only the given verbs on
+// their Cluster, see syncer.Controller.SetRBACVerbs. It must be called
+// before Start.
+func (c *Controller) SetSyncerRBACVerbs(verbs []string) {
+	c.rbacVerbs = verbs
+}
+
+// SetSyncerServiceImports makes the syncers program the ServiceImports of
+// kcp on their Cluster, see syncer.Controller.SetServiceImports. It must be
+// called before Start.
+func (c *Controller) SetSyncerServiceImports(enabled bool) {
+	c.serviceImports = enabled
+}
+
+// SetSyncerNetworkPolicies makes the syncers of the Clusters that enforce
+// NetworkPolicies sync those selecting the pods of the objects they sync,
+// see syncer.Controller.SetNetworkPolicies. It must be called before Start.
+func (c *Controller) SetSyncerNetworkPolicies(enabled bool) {
+	c.networkPolicies = enabled
+}
+
+// syncsNetworkPolicies reports whether the syncer of the Cluster syncs
+// NetworkPolicies, which it only does if the Cluster enforces them.
+func (c *Controller) syncsNetworkPolicies(cluster *v1alpha1.Cluster) bool {
+	return c.networkPolicies && cluster.Status.NetworkPolicies
+}
+
+// LivenessCheck returns the check of the Controller's liveness, which fails
+// while one of its workers has been processing a Cluster for longer than
+// health.DefaultStuckTimeout.
+func (c *Controller) LivenessCheck() healthz.HealthChecker {
+	return c.inFlight.Check(controllerName, health.DefaultStuckTimeout)
+}
+
+// ReadinessCheck returns the check of the Controller's readiness, which fails
+// until the caches of its informers have synced.
+func (c *Controller) ReadinessCheck() healthz.HealthChecker {
+	return health.CacheSyncCheck(controllerName, c.synced...)
 }
 
 func (c *Controller) enqueue(obj interface{}) {
-	key, err := cache.MetaNamespaceKeyFunc(obj)
+	key, err := logicalcluster.KeyFunc(obj)
 	if err != nil {
 		runtime.HandleError(err)
 		return
@@ -83,14 +229,46 @@ func (c *Controller) enqueue(obj interface{}) {
 	c.queue.Add(key)
 }
 
+func indexByKubeConfigSecret(obj interface{}) ([]string, error) {
+	cluster, ok := obj.(*v1alpha1.Cluster)
+	if !ok || cluster.Spec.KubeConfigSecretRef == nil {
+		return nil, nil
+	}
+	ref := cluster.Spec.KubeConfigSecretRef
+	return []string{logicalcluster.Key(cluster.GetClusterName(), ref.Namespace, ref.Name)}, nil
+}
+
+// enqueueClustersOfSecret enqueues the Clusters whose kubeconfig is held by
+// the given Secret, in its logical cluster.
+func (c *Controller) enqueueClustersOfSecret(obj interface{}) {
+	key, err := logicalcluster.KeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	clusters, err := c.indexer.ByIndex(byKubeConfigSecret, key)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	for _, cluster := range clusters {
+		c.enqueue(cluster)
+	}
+}
+
+// Start runs numThreads workers, once the caches of the informers have
+// synced, until the process exits.
 func (c *Controller) Start(numThreads int) {
 	defer c.queue.ShutDown()
+	if !cache.WaitForNamedCacheSync(controllerName, c.stopCh, c.synced...) {
+		return
+	}
 	for i := 0; i < numThreads; i++ {
 		go wait.Until(c.startWorker, time.Second, c.stopCh)
 	}
-	log.Println("Starting workers")
+	c.logger.Info("Starting workers", "count", numThreads)
 	<-c.stopCh
-	log.Println("Stopping workers")
+	c.logger.Info("Stopping workers")
 }
 
 func (c *Controller) startWorker() {
@@ -110,15 +288,21 @@ func (c *Controller) processNextWorkItem() bool {
 	// other workers.
 	defer c.queue.Done(key)
 
-	err := c.process(key)
-	c.handleErr(err, key)
+	logger := c.logger.WithValues(logging.ObjectKey, key)
+	c.inFlight.Begin(key)
+	defer c.inFlight.End(key)
+	start := time.Now()
+	err := c.process(logging.NewContext(context.Background(), logger), key)
+	metrics.ObserveReconcile(controllerName, start, err)
+	c.handleErr(logger, err, key)
 	return true
 }
 
-func (c *Controller) handleErr(err error, key string) {
+func (c *Controller) handleErr(logger logr.Logger, err error, key string) {
 	// Reconcile worked, nothing else to do for this workqueue item.
 	if err == nil {
-		log.Println("Successfully reconciled", key)
+		logger.V(2).Info("Successfully reconciled")
+		metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultSuccess).Inc()
 		c.queue.Forget(key)
 		return
 	}
@@ -126,81 +310,168 @@ func (c *Controller) handleErr(err error, key string) {
 	// Re-enqueue up to 5 times.
 	num := c.queue.NumRequeues(key)
 	if num < 5 {
-		log.Printf("Error reconciling key %q, retrying... (#%d): %v", key, num, err)
+		logger.Error(err, "Error reconciling, retrying", "retries", num)
+		metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultRetry).Inc()
 		c.queue.AddRateLimited(key)
 		return
 	}
 
 	// Give up and report error elsewhere.
+	metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultDropped).Inc()
 	c.queue.Forget(key)
 	runtime.HandleError(err)
-	log.Printf("Dropping key %q after failed retries: %v", key, err)
+	if c.requeueDropped > 0 {
+		logger.Error(err, "Dropping key after failed retries, until it's requeued", "after", c.requeueDropped)
+		c.queue.AddAfter(key, c.requeueDropped)
+		return
+	}
+	logger.Error(err, "Dropping key after failed retries")
+	// Nor is the deleted Cluster of the key, if any, cleaned up after.
+	c.forgetDeleted(key, c.lastDeleted(key))
 }
 
-func (c *Controller) process(key string) error {
+func (c *Controller) process(ctx context.Context, key string) error {
 	obj, exists, err := c.indexer.GetByKey(key)
 	if err != nil {
 		return err
 	}
 
+	deleted := c.lastDeleted(key)
 	if !exists {
-		log.Printf("Object with key %q was deleted", key)
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		if deleted == nil {
+			return nil
+		}
+		ctx, logger := logging.WithValues(ctx, logging.ClusterKey, deleted.Name, logging.WorkspaceKey, deleted.GetClusterName())
+		logger.V(4).Info("Deleting cluster")
+		if err := c.cleanup(ctx, deleted); err != nil {
+			// Retried with the same last known state.
+			return err
+		}
+		c.forgetDeleted(key, deleted)
 		return nil
 	}
-	current := obj.(*v1alpha1.Cluster)
+	// A Cluster created again under the same key before its deletion was
+	// cleaned up after takes over what it left behind.
+	c.forgetDeleted(key, deleted)
+	current := obj.(*v1alpha1.Cluster).DeepCopy()
 	previous := current.DeepCopy()
 
-	ctx := context.TODO()
-
+	ctx, logger := logging.WithValues(ctx, logging.WorkspaceKey, current.GetClusterName())
 	if err := c.reconcile(ctx, current); err != nil {
 		return err
 	}
+	current.Status.ObservedGeneration = current.Generation
 
-	// If the object being reconciled changed as a result, update it.
+	// If the object being reconciled changed as a result, update it. The
+	// status goes first, so that a bootstrap token is only removed once the
+	// Cluster's Accepted condition is recorded.
 	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
-		log.Println("saw update")
-		_, uerr := c.client.Clusters().UpdateStatus(ctx, current, metav1.UpdateOptions{})
-		return uerr
+		logger.V(4).Info("Updating status")
+		obj, err := base.PatchStatus(ctx, "kcp-"+controllerName, previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (apiruntime.Object, error) {
+				return c.client.Clusters().Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (apiruntime.Object, error) {
+				return c.client.Clusters().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		if err != nil {
+			return err
+		}
+		updated := obj.(*v1alpha1.Cluster)
+		updated.Labels = current.Labels
+		updated.Annotations = current.Annotations
+		current = updated
+	} else {
+		logger.V(4).Info("Status unchanged")
+	}
+	if !equality.Semantic.DeepEqual(previous.Labels, current.Labels) || !equality.Semantic.DeepEqual(previous.Annotations, current.Annotations) {
+		if _, err := c.client.Clusters().Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
 	}
-	log.Println("no update")
 
+	// Bootstrap tokens can only be used once.
+	if token, found := previous.Annotations[registration.TokenAnnotation]; found && current.Status.Conditions.IsAccepted() {
+		logicalClusterContext := genericapirequest.WithCluster(ctx, genericapirequest.Cluster{Name: current.GetClusterName()})
+		return registration.Consume(logicalClusterContext, c.kubeClient, token)
+	}
 	return nil
 }
 
+// deletedCluster enqueues the key of a deleted Cluster, keeping its last
+// known state for process to clean up after it. Like updates, deletions
+// arriving faster than they're processed are handled once.
 func (c *Controller) deletedCluster(obj interface{}) {
 	castObj, ok := obj.(*v1alpha1.Cluster)
 	if !ok {
 		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
 		if !ok {
-			klog.Errorf("Couldn't get object from tombstone %#v", obj)
+			c.logger.Error(nil, "Couldn't get object from tombstone", "object", obj)
 			return
 		}
 		castObj, ok = tombstone.Obj.(*v1alpha1.Cluster)
 		if !ok {
-			klog.Errorf("Tombstone contained object that is not expected %#v", obj)
+			c.logger.Error(nil, "Tombstone contained object that is not expected", "object", obj)
 			return
 		}
 	}
-	klog.V(4).Infof("Deleting cluster %q", castObj.Name)
-	ctx := context.TODO()
-	c.cleanup(ctx, castObj)
+	key, err := logicalcluster.KeyFunc(castObj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.deletedLock.Lock()
+	c.deleted[key] = castObj
+	c.deletedLock.Unlock()
+	c.queue.Add(key)
 }
 
-func RegisterClusterCRD(cfg *rest.Config) error {
-	bytes, err := ioutil.ReadFile("config/cluster.example.dev_clusters.yaml")
+// lastDeleted returns the last known state of the deleted Cluster with the
+// given key, if any, kept until it's cleaned up after.
+func (c *Controller) lastDeleted(key string) *v1alpha1.Cluster {
+	c.deletedLock.Lock()
+	defer c.deletedLock.Unlock()
+	return c.deleted[key]
+}
 
-	crdClient := apiextensionsv1client.NewForConfigOrDie(cfg)
+// forgetDeleted forgets the last known state of the deleted Cluster with the
+// given key, unless it was deleted again since that state was returned by
+// lastDeleted.
+func (c *Controller) forgetDeleted(key string, deleted *v1alpha1.Cluster) {
+	c.deletedLock.Lock()
+	defer c.deletedLock.Unlock()
+	if c.deleted[key] == deleted {
+		delete(c.deleted, key)
+	}
+}
 
-	crd := &apiextensionsv1.CustomResourceDefinition{}
-	err = yaml.Unmarshal(bytes, crd)
+// RegisterClusterCRD registers the CRDs of the cluster.example.dev group,
+// embedded in kcp, creating them or updating their schema to this build's:
+// Clusters, PlacementPolicies, NegotiatedAPIResources and Workspaces, among
+// others. Only the Workspaces of the admin logical cluster are set up.
+//
+// The Clusters serve v1alpha1 and v1beta1, which the API server converts
+// between with the given webhook, that of kcp; nil, outside of kcp, only
+// works once kcp registered them. The Clusters still stored as v1alpha1 are
+// then migrated to v1beta1.
+func RegisterClusterCRD(cfg *rest.Config, webhook *apiextensionsv1.WebhookClientConfig) error {
+	crds, err := config.CRDs()
 	if err != nil {
 		return err
 	}
-
-	_, err = crdClient.CustomResourceDefinitions().Create(context.TODO(), crd, metav1.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
+	if webhook != nil {
+		crds = config.WithConversionWebhook(crds, webhook)
+	}
+	crdClient := apiextensionsv1client.NewForConfigOrDie(cfg)
+	if err := config.Bootstrap(context.TODO(), crdClient.CustomResourceDefinitions(), crds...); err != nil {
 		return err
 	}
-
+	for _, crd := range crds {
+		if err := config.MigrateStorage(context.TODO(), cfg, crd.Name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
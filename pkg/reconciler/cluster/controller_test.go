@@ -0,0 +1,166 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterfake "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/fake"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/negotiation"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestEnqueueDedup(t *testing.T) {
+	c := &Controller{
+		logger:  logging.New(controllerName),
+		queue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		deleted: map[string]*v1alpha1.Cluster{},
+	}
+	defer c.queue.ShutDown()
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "us-east", ClusterName: "admin"}}
+	key := logicalcluster.Key("admin", "", "us-east")
+
+	for i := 0; i < 100; i++ {
+		updated := cluster.DeepCopy()
+		updated.ResourceVersion = string(rune('0' + i%10))
+		c.enqueue(updated)
+	}
+	c.deletedCluster(cache.DeletedFinalStateUnknown{Key: "us-east", Obj: cluster})
+	c.deletedCluster(cluster)
+	if got := c.queue.Len(); got != 1 {
+		t.Fatalf("got %d queued items", got)
+	}
+	item, _ := c.queue.Get()
+	if item != key {
+		t.Errorf("got queued item %v", item)
+	}
+	c.queue.Done(item)
+
+	deleted := c.lastDeleted(key)
+	if deleted == nil || deleted.Name != "us-east" {
+		t.Errorf("kept %v to clean up", deleted)
+	}
+	c.forgetDeleted(key, deleted)
+	if deleted := c.lastDeleted(key); deleted != nil {
+		t.Error("cleaned up twice")
+	}
+}
+
+// newCleanupController returns a Controller whose syncers reach an API
+// server serving nothing to sync, with the given pulled CRDs and the
+// heartbeat Lease of the Cluster us-east, whose deletion fails once.
+func newCleanupController(t *testing.T, crds ...*apiextensionsv1.CustomResourceDefinition) (*Controller, *fake.Clientset, *apiextensionsfake.Clientset) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body interface{} = &metav1.APIGroupList{}
+		if strings.HasSuffix(req.URL.Path, "/api") {
+			body = &metav1.APIVersions{}
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+
+	kubeClient := fake.NewSimpleClientset(&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: "us-east", Namespace: syncer.LeaseNamespace}})
+	failed := false
+	kubeClient.PrependReactor("delete", "leases", func(clienttesting.Action) (bool, apiruntime.Object, error) {
+		if failed {
+			return false, nil, nil
+		}
+		failed = true
+		return true, nil, errors.New("etcdserver: request timed out")
+	})
+	objs := make([]apiruntime.Object, 0, len(crds))
+	for _, crd := range crds {
+		objs = append(objs, crd)
+	}
+	crdClient := apiextensionsfake.NewSimpleClientset(objs...)
+	c := &Controller{
+		logger:     logging.New(controllerName),
+		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		indexer:    cache.NewIndexer(logicalcluster.KeyFunc, logicalcluster.Indexers),
+		crdClient:  crdClient.ApiextensionsV1(),
+		kubeClient: kubeClient,
+		kubeconfig: clientcmdapi.Config{
+			Clusters:       map[string]*clientcmdapi.Cluster{"admin": {Server: server.URL}},
+			Contexts:       map[string]*clientcmdapi.Context{"admin": {Cluster: "admin"}},
+			CurrentContext: "admin",
+		},
+		deleted: map[string]*v1alpha1.Cluster{},
+	}
+	t.Cleanup(c.queue.ShutDown)
+	return c, kubeClient, crdClient
+}
+
+func TestCleanupFailure(t *testing.T) {
+	pulled := &apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{
+		Name:   "widgets.example.com",
+		Labels: map[string]string{negotiation.OriginLabel("us-east"): ""},
+	}}
+	c, kubeClient, crdClient := newCleanupController(t, pulled)
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "us-east", ClusterName: "admin"}}
+	key := logicalcluster.Key("admin", "", "us-east")
+	c.deletedCluster(cluster)
+
+	if err := c.process(context.Background(), key); err == nil || !strings.Contains(err.Error(), "heartbeat lease") {
+		t.Fatalf("got %v, want the failure to delete the lease", err)
+	}
+	// What didn't fail is cleaned up already.
+	if _, err := crdClient.ApiextensionsV1().CustomResourceDefinitions().Get(context.Background(), pulled.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("kept the CRD pulled from the cluster: %v", err)
+	}
+	if _, err := kubeClient.CoordinationV1().Leases(syncer.LeaseNamespace).Get(context.Background(), "us-east", metav1.GetOptions{}); err != nil {
+		t.Errorf("lease: %v", err)
+	}
+
+	// The retry cleans up after the same Cluster.
+	if deleted := c.lastDeleted(key); deleted != cluster {
+		t.Fatalf("kept %v to clean up", deleted)
+	}
+	if err := c.process(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubeClient.CoordinationV1().Leases(syncer.LeaseNamespace).Get(context.Background(), "us-east", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("kept the lease: %v", err)
+	}
+	if deleted := c.lastDeleted(key); deleted != nil {
+		t.Errorf("kept %v to clean up once cleaned up after", deleted)
+	}
+}
+
+func TestCleanupRecreated(t *testing.T) {
+	c, kubeClient, _ := newCleanupController(t)
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "us-east", ClusterName: "admin"}}
+	key := logicalcluster.Key("admin", "", "us-east")
+	c.deletedCluster(cluster)
+	c.client = clusterfake.NewSimpleClientset(cluster.DeepCopy()).ClusterV1alpha1()
+	if err := c.indexer.Add(cluster.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Cluster created again takes over: neither the failed deletion of
+	// the lease nor the reconcile is retried for the deleted one.
+	_ = c.process(context.Background(), key)
+	if deleted := c.lastDeleted(key); deleted != nil {
+		t.Errorf("kept %v to clean up", deleted)
+	}
+	if _, err := kubeClient.CoordinationV1().Leases(syncer.LeaseNamespace).Get(context.Background(), "us-east", metav1.GetOptions{}); err != nil {
+		t.Errorf("lease: %v", err)
+	}
+}
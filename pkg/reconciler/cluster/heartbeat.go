@@ -0,0 +1,43 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkHeartbeat sets the Ready condition of a Cluster whose syncer dials out
+// to kcp from the Lease the syncer renews, and returns when to check it
+// again: once the heartbeat runs out, unless the syncer renews it before. A
+// Cluster whose syncer stopped renewing it isn't Ready, and is evicted from
+// in time. The context must be that of the Cluster's logical cluster.
+func (c *Controller) checkHeartbeat(ctx context.Context, cluster *v1alpha1.Cluster, now time.Time) (time.Duration, error) {
+	lease, err := c.kubeClient.CoordinationV1().Leases(syncer.LeaseNamespace).Get(ctx, cluster.Name, v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionUnknown,
+			"AwaitingSyncerHeartbeat",
+			"The syncer hasn't renewed its heartbeat lease yet")
+		return pollInterval, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	expiry := syncer.LeaseExpiry(lease)
+	if !expiry.After(now) {
+		conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionFalse,
+			"SyncerHeartbeatStale",
+			fmt.Sprintf("The syncer's heartbeat lease ran out %s ago", now.Sub(expiry).Round(time.Second)))
+		return pollInterval, nil
+	}
+	conditions.Set(cluster, v1alpha1.ClusterConditionReady, corev1.ConditionTrue,
+		"SyncerReady",
+		"Syncer renews its heartbeat lease")
+	return expiry.Sub(now), nil
+}
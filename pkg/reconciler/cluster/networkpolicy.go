@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// networkPoliciesAnnotation on a Cluster, true or false, says whether it
+// enforces NetworkPolicies, for the network plugins the Cluster Controller
+// doesn't know of.
+const networkPoliciesAnnotation = "experimental.kcp.dev/network-policies"
+
+// enforcingDaemonSets are the DaemonSets of the network plugins that enforce
+// NetworkPolicies, by name.
+var enforcingDaemonSets = map[string]bool{
+	"antrea-agent": true,
+	"calico-node":  true,
+	"canal":        true,
+	"cilium":       true,
+	"kube-router":  true,
+	"weave-net":    true,
+}
+
+// reportNetworkPolicies sets whether the Cluster enforces NetworkPolicies in
+// its status: as its networkPoliciesAnnotation says if set, and otherwise
+// whether it runs the DaemonSet of a network plugin that does. Kubernetes
+// accepts NetworkPolicies whatever its plugin, and those that don't enforce
+// them ignore them.
+func reportNetworkPolicies(ctx context.Context, client kubernetes.Interface, cluster *v1alpha1.Cluster) error {
+	if v, found := cluster.Annotations[networkPoliciesAnnotation]; found {
+		enforces, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s annotation: %w", networkPoliciesAnnotation, err)
+		}
+		cluster.Status.NetworkPolicies = enforces
+		return nil
+	}
+	list, err := client.AppsV1().DaemonSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	cluster.Status.NetworkPolicies = false
+	for _, ds := range list.Items {
+		if enforcingDaemonSets[ds.Name] {
+			cluster.Status.NetworkPolicies = true
+			break
+		}
+	}
+	return nil
+}
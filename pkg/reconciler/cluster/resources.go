@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+)
+
+// wantedResources returns the resources the Cluster's syncer is to sync, as
+// its spec declares, or the Controller's by default.
+func (c *Controller) wantedResources(cluster *v1alpha1.Cluster) []string {
+	if len(cluster.Spec.SyncedResources) > 0 {
+		return cluster.Spec.SyncedResources
+	}
+	return c.resourcesToSync
+}
+
+// syncedResources returns the resources the Cluster's syncer syncs, as last
+// negotiated, or those it's to sync if they weren't yet.
+func (c *Controller) syncedResources(cluster *v1alpha1.Cluster) []string {
+	if len(cluster.Status.SyncedResources) > 0 {
+		return cluster.Status.SyncedResources
+	}
+	return c.wantedResources(cluster)
+}
+
+// negotiateResources sets the SyncedResources of the Cluster's status to
+// those it's to sync that the cluster serves, as client discovers them, or
+// to all of them if client is nil, for the clusters kcp can't reach. It
+// returns whether they changed, and an error if the cluster serves none.
+func (c *Controller) negotiateResources(client discovery.DiscoveryInterface, cluster *v1alpha1.Cluster) (bool, error) {
+	wanted := c.wantedResources(cluster)
+	synced := wanted
+	if client != nil {
+		lists, err := client.ServerPreferredResources()
+		if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+			return false, err
+		}
+		served := sets.NewString()
+		for _, list := range lists {
+			gv, err := schema.ParseGroupVersion(list.GroupVersion)
+			if err != nil {
+				continue
+			}
+			for _, r := range list.APIResources {
+				served.Insert(r.Name, schema.GroupResource{Group: gv.Group, Resource: r.Name}.String())
+			}
+		}
+		synced = nil
+		for _, r := range wanted {
+			if served.Has(r) {
+				synced = append(synced, r)
+			}
+		}
+		if len(synced) == 0 {
+			return false, fmt.Errorf("the cluster serves none of %s", strings.Join(wanted, ", "))
+		}
+	}
+	if equality.Semantic.DeepEqual(cluster.Status.SyncedResources, synced) {
+		return false, nil
+	}
+	cluster.Status.SyncedResources = synced
+	return true, nil
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// SyncerNamespace is the namespace of the ServiceAccounts the syncers
+	// installed in physical clusters reach their logical cluster as, when
+	// they're issued tokens: one per Cluster, named after it. It's also
+	// that of the Leases they renew.
+	SyncerNamespace = syncer.LeaseNamespace
+
+	// SyncerTokenExpiryAnnotation records when the token issued to a
+	// Cluster's syncer expires, for it to be replaced in time.
+	SyncerTokenExpiryAnnotation = "experimental.kcp.dev/syncer-token-expiry"
+)
+
+// useSyncerToken makes the kubeconfig of the Cluster's syncer authenticate
+// with a token of the Cluster's ServiceAccount, valid for the controller's
+// syncerTokenTTL, rather than as the controller does, and returns when the
+// token expires. The ServiceAccount is created if needed.
+func (c *Controller) useSyncerToken(ctx context.Context, cluster *v1alpha1.Cluster, kubeConfig *clientcmdapi.Config) (time.Time, error) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: SyncerNamespace}}
+	if _, err := c.kubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return time.Time{}, err
+	}
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: SyncerNamespace, Name: cluster.Name}}
+	if _, err := c.kubeClient.CoreV1().ServiceAccounts(SyncerNamespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return time.Time{}, err
+	}
+
+	seconds := int64(c.syncerTokenTTL / time.Second)
+	tr, err := c.kubeClient.CoreV1().ServiceAccounts(SyncerNamespace).CreateToken(ctx, cluster.Name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &seconds},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	kubeContext, found := kubeConfig.Contexts[kubeConfig.CurrentContext]
+	if !found {
+		return time.Time{}, fmt.Errorf("no current context %q in the syncer's kubeconfig", kubeConfig.CurrentContext)
+	}
+	kubeContext = kubeContext.DeepCopy()
+	kubeContext.AuthInfo = "syncer"
+	kubeConfig.Contexts[kubeConfig.CurrentContext] = kubeContext
+	if kubeConfig.AuthInfos == nil {
+		kubeConfig.AuthInfos = map[string]*clientcmdapi.AuthInfo{}
+	}
+	kubeConfig.AuthInfos["syncer"] = &clientcmdapi.AuthInfo{Token: tr.Status.Token}
+	return tr.Status.ExpirationTimestamp.Time, nil
+}
+
+// setSyncerTokenExpiry records when the token of the syncer installed in
+// the Cluster expires, once it's installed with it: until then, the syncer
+// still has its previous token, which is to be replaced in time.
+func setSyncerTokenExpiry(cluster *v1alpha1.Cluster, expiry time.Time) {
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+	cluster.Annotations[SyncerTokenExpiryAnnotation] = expiry.UTC().Format(time.RFC3339)
+}
+
+// syncerTokenDue reports whether the token issued to the Cluster's syncer
+// should be replaced, once two thirds of its lifetime have passed.
+func (c *Controller) syncerTokenDue(cluster *v1alpha1.Cluster, now time.Time) bool {
+	if c.syncerTokenTTL == 0 {
+		return false
+	}
+	expiry, err := time.Parse(time.RFC3339, cluster.Annotations[SyncerTokenExpiryAnnotation])
+	if err != nil {
+		// Issued none yet, e.g. before tokens were enabled.
+		return true
+	}
+	return now.After(expiry.Add(-c.syncerTokenTTL / 3))
+}
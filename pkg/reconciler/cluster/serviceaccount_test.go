@@ -0,0 +1,107 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestSyncerTokenDue(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	expiringIn := func(d time.Duration) map[string]string {
+		return map[string]string{SyncerTokenExpiryAnnotation: now.Add(d).Format(time.RFC3339)}
+	}
+	for _, tc := range []struct {
+		desc        string
+		ttl         time.Duration
+		annotations map[string]string
+		want        bool
+	}{
+		{desc: "tokens disabled", annotations: expiringIn(-time.Hour)},
+		{desc: "none issued yet", ttl: 3 * time.Hour, want: true},
+		{desc: "unparsable expiry", ttl: 3 * time.Hour, annotations: map[string]string{SyncerTokenExpiryAnnotation: "soon"}, want: true},
+		{desc: "fresh", ttl: 3 * time.Hour, annotations: expiringIn(3 * time.Hour)},
+		{desc: "within two thirds", ttl: 3 * time.Hour, annotations: expiringIn(90 * time.Minute)},
+		{desc: "past two thirds", ttl: 3 * time.Hour, annotations: expiringIn(59 * time.Minute), want: true},
+		{desc: "expired", ttl: 3 * time.Hour, annotations: expiringIn(-time.Minute), want: true},
+	} {
+		c := &Controller{syncerTokenTTL: tc.ttl}
+		cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "us-east", Annotations: tc.annotations}}
+		if got := c.syncerTokenDue(cluster, now); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestRotateSyncerToken(t *testing.T) {
+	expiry := time.Date(2021, 6, 1, 15, 0, 0, 0, time.UTC)
+	kcp := kubefake.NewSimpleClientset()
+	var issued int
+	kcp.PrependReactor("create", "serviceaccounts", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		issued++
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{
+			Token:               "syncer-token",
+			ExpirationTimestamp: metav1.NewTime(expiry),
+		}}, nil
+	})
+	c := &Controller{kubeClient: kcp, syncerImage: "syncer", syncerTokenTTL: 3 * time.Hour}
+	kubeConfig := func() *clientcmdapi.Config {
+		return &clientcmdapi.Config{
+			Clusters:  map[string]*clientcmdapi.Cluster{"admin": {Server: "https://kcp/clusters/admin"}},
+			AuthInfos: map[string]*clientcmdapi.AuthInfo{"admin": {Token: "admin-token"}},
+			Contexts:  map[string]*clientcmdapi.Context{"admin": {Cluster: "admin", AuthInfo: "admin"}},
+		}
+	}
+	previous := expiry.Add(-3 * time.Hour).Format(time.RFC3339)
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "us-east",
+			ClusterName: "admin",
+			Annotations: map[string]string{SyncerTokenExpiryAnnotation: previous},
+		},
+		Status: v1alpha1.ClusterStatus{SyncedResources: []string{"deployments.apps"}},
+	}
+	ctx := context.Background()
+
+	// A syncer that fails to be installed keeps its previous token, so its
+	// expiry is still the one to replace it by.
+	failing := kubefake.NewSimpleClientset()
+	failing.PrependReactor("create", "deployments", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("cluster is down")
+	})
+	if err := c.installSyncer(ctx, ctx, failing, cluster, kubeConfig()); err == nil {
+		t.Fatal("no error installing the syncer")
+	}
+	if issued != 1 {
+		t.Errorf("issued %d tokens", issued)
+	}
+	if got := cluster.Annotations[SyncerTokenExpiryAnnotation]; got != previous {
+		t.Errorf("recorded the expiry %s of a token the syncer wasn't installed with", got)
+	}
+
+	physical := kubefake.NewSimpleClientset()
+	if err := c.installSyncer(ctx, ctx, physical, cluster, kubeConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cluster.Annotations[SyncerTokenExpiryAnnotation], expiry.Format(time.RFC3339); got != want {
+		t.Errorf("recorded the expiry %s, want %s", got, want)
+	}
+	if c.syncerTokenDue(cluster, expiry.Add(-3*time.Hour)) {
+		t.Error("the new token is due as soon as it's issued")
+	}
+	if _, err := kcp.CoreV1().ServiceAccounts(SyncerNamespace).Get(ctx, "us-east", metav1.GetOptions{}); err != nil {
+		t.Errorf("no ServiceAccount of the syncer: %v", err)
+	}
+}
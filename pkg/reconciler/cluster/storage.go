@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// accessModesAnnotation lists, comma-separated, the access modes the
+	// volumes of a StorageClass of a physical cluster can be claimed with,
+	// for the provisioners it doesn't know of.
+	accessModesAnnotation = "experimental.kcp.dev/access-modes"
+
+	defaultClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// sharedProvisioners are the provisioners of shared filesystems, whose
+// volumes can be mounted from several nodes at once.
+var sharedProvisioners = map[string]bool{
+	"cephfs.csi.ceph.com":           true,
+	"efs.csi.aws.com":               true,
+	"file.csi.azure.com":            true,
+	"filestore.csi.storage.gke.io":  true,
+	"kubernetes.io/azure-file":      true,
+	"kubernetes.io/cephfs":          true,
+	"nfs.csi.k8s.io":                true,
+	"cluster.local/nfs-provisioner": true,
+}
+
+// reportStorageClasses sets the StorageClasses of the Cluster in its
+// status, along with the access modes of their volumes.
+func reportStorageClasses(ctx context.Context, client kubernetes.Interface, cluster *v1alpha1.Cluster) error {
+	list, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var classes []v1alpha1.ClusterStorageClass
+	for i := range list.Items {
+		sc := &list.Items[i]
+		classes = append(classes, v1alpha1.ClusterStorageClass{
+			Name:        sc.Name,
+			Provisioner: sc.Provisioner,
+			Default:     sc.Annotations[defaultClassAnnotation] == "true" || sc.Annotations[betaDefaultClassAnnotation] == "true",
+			AccessModes: accessModesOf(sc),
+		})
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+	cluster.Status.StorageClasses = classes
+	return nil
+}
+
+// accessModesOf returns the access modes a StorageClass's volumes can be
+// claimed with: those of its accessModesAnnotation if set, all of them for
+// shared filesystems, and ReadWriteOnce otherwise.
+func accessModesOf(sc *storagev1.StorageClass) []corev1.PersistentVolumeAccessMode {
+	if v := sc.Annotations[accessModesAnnotation]; v != "" {
+		var modes []corev1.PersistentVolumeAccessMode
+		for _, m := range strings.Split(v, ",") {
+			modes = append(modes, corev1.PersistentVolumeAccessMode(strings.TrimSpace(m)))
+		}
+		return modes
+	}
+	if sharedProvisioners[sc.Provisioner] {
+		return []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany}
+	}
+	return []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+}
@@ -3,177 +3,169 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/klog"
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
+// numSyncerThreads is the number of workers of each syncer run in process,
+// for Clusters that aren't using the pull model.
+const numSyncerThreads = 2
+
 const (
-	syncerNS     = "syncer-system"
-	syncerSAName = "syncer"
-	syncerPrefix = "syncer"
+	// namespaceMappingAnnotation on a Cluster maps the namespaces of kcp to
+	// those of the cluster, as comma-separated <kcp namespace>=<namespace>.
+	namespaceMappingAnnotation = "experimental.kcp.dev/namespace-mapping"
+
+	// namespaceStrategyAnnotation on a Cluster decides the namespaces of
+	// the cluster of the namespaces of kcp namespaceMappingAnnotation
+	// doesn't map: identity, prefixed, for kcp-<workspace>-<namespace>, or
+	// consolidated:<namespace>.
+	namespaceStrategyAnnotation = "experimental.kcp.dev/namespace-strategy"
+
+	// serviceAccountMappingAnnotation on a Cluster maps the ServiceAccounts
+	// pods run as in kcp to those of the cluster, as comma-separated
+	// <kcp service account>=<service account>.
+	serviceAccountMappingAnnotation = "experimental.kcp.dev/service-account-mapping"
 )
 
-func syncerWorkloadName(logicalCluster string) string {
-	return syncerPrefix + "-from-" + logicalCluster
+// pushSyncer is a syncer run by the Cluster Controller itself, pushing to a
+// Cluster with the kubeconfig it was started with.
+type pushSyncer struct {
+	*syncer.Controller
+	kubeconfig string
+	mappings   string
 }
 
-func syncerConfigMapName(logicalCluster string) string {
-	return "kubeconfig-for-" + logicalCluster
+func pushSyncerKey(clusterID, logicalCluster string) string {
+	return logicalCluster + "|" + clusterID
 }
 
-// installSyncer installs the syncer image on the target cluster.
-//
-// It takes the syncer image name to run, and the kubeconfig of the kcp
-func installSyncer(ctx context.Context, client kubernetes.Interface, syncerImage, kubeconfig, clusterID, logicalCluster string, resourcesToSync []string) error {
-	// Create Namespace
-	if _, err := client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: syncerNS,
-		},
-	}, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
+// startSyncer runs a syncer in this process, pushing the given resources
+// from the given logical cluster to the Cluster reached with cfg,
+// transforming what it syncs with t and rate limited by limit, and syncing
+// NetworkPolicies if networkPolicies is set. It replaces the Cluster's
+// current syncer if its kubeconfig, resources, mappings, rate limit or
+// NetworkPolicy syncing changed, and otherwise leaves it running.
+func (c *Controller) startSyncer(ctx context.Context, cfg *rest.Config, kubeconfig, clusterID, logicalCluster string, resources []string, t syncer.Transforms, limit syncer.RateLimit, networkPolicies bool) error {
+	key := pushSyncerKey(clusterID, logicalCluster)
+	mappings := strings.Join(resources, ",") + "|" + t.Namespaces.String() + "|" + t.NamespaceStrategy.String() + "|" + t.ServiceAccounts.String() + "|" + limit.String() + "|" + strconv.FormatBool(networkPolicies)
+	c.syncersLock.Lock()
+	defer c.syncersLock.Unlock()
+	if s, ok := c.syncers[key]; ok {
+		if s.kubeconfig == kubeconfig && s.mappings == mappings {
+			return nil
+		}
+		logging.FromContext(ctx).Info("Restarting syncer with updated kubeconfig, resources, mappings, rate limit or NetworkPolicy syncing")
+		s.Stop()
+		delete(c.syncers, key)
 	}
 
-	// Create ServiceAccount.
-	if _, err := client.CoreV1().ServiceAccounts(syncerNS).Create(ctx, &corev1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: syncerNS,
-			Name:      syncerSAName,
-		},
-	}, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+	upstream, err := c.upstreamConfig(logicalCluster)
+	if err != nil {
 		return err
 	}
-
-	// TODO: Create or Update ClusterRole
-
-	// TODO: Create ClusterRoleBinding
-
-	// Populate a ConfigMap with the kubeconfig to reach the kcp, to be
-	// mounted into the syncer's Pod.
-	configMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: syncerNS,
-			Name:      syncerConfigMapName(logicalCluster),
-		},
-		Data: map[string]string{
-			"kubeconfig": kubeconfig,
-		},
+	s, err := syncer.NewController(upstream, limit.Config(cfg), clusterID, resources, syncer.DefaultResyncPeriod, c.driftMode)
+	if err != nil {
+		return err
 	}
-	if _, err := client.CoreV1().ConfigMaps(syncerNS).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
-		if k8serrors.IsAlreadyExists(err) {
-			if configMap, err = client.CoreV1().ConfigMaps(syncerNS).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+	s.SetTransforms(t)
+	if err := s.SetUpsyncedResources(c.upsync); err != nil {
+		return err
 	}
+	s.SetRBACVerbs(c.rbacVerbs)
+	s.SetServiceImports(c.serviceImports)
+	s.SetNetworkPolicies(networkPolicies)
+	c.syncers[key] = pushSyncer{Controller: s, kubeconfig: kubeconfig, mappings: mappings}
+	go s.Start(numSyncerThreads)
+	return nil
+}
 
-	args := []string{
-		"-cluster", clusterID,
-		"-kubeconfig", "/kcp/kubeconfig",
+// stopSyncer stops the syncer pushing to the Cluster, if any.
+func (c *Controller) stopSyncer(clusterID, logicalCluster string) {
+	key := pushSyncerKey(clusterID, logicalCluster)
+	c.syncersLock.Lock()
+	defer c.syncersLock.Unlock()
+	if s, ok := c.syncers[key]; ok {
+		s.Stop()
+		delete(c.syncers, key)
 	}
-	args = append(args, resourcesToSync...)
-
-	var one int32 = 1
-	// Create or Update Pod
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: syncerNS,
-			Name:      syncerWorkloadName(logicalCluster),
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &one,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": syncerWorkloadName(logicalCluster),
-				},
-			},
-			Strategy: appsv1.DeploymentStrategy{
-				Type: appsv1.RecreateDeploymentStrategyType,
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": syncerWorkloadName(logicalCluster),
-					},
-					Annotations: map[string]string{
-						"kubeconfig/version": configMap.ResourceVersion,
-					},
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{{
-						Name:  "syncer",
-						Image: syncerImage,
-						Args:  args,
-						VolumeMounts: []corev1.VolumeMount{{
-							Name:      "kubeconfig",
-							MountPath: "/kcp",
-							ReadOnly:  true,
-						}},
-						TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
-					}},
-					Volumes: []corev1.Volume{{
-						Name: "kubeconfig",
-						VolumeSource: corev1.VolumeSource{
-							ConfigMap: &corev1.ConfigMapVolumeSource{
-								LocalObjectReference: corev1.LocalObjectReference{
-									Name: syncerConfigMapName(logicalCluster),
-								},
-								Items: []corev1.KeyToPath{{
-									Key: "kubeconfig", Path: "kubeconfig",
-								}},
-							},
-						},
-					}},
-				},
-			},
-		},
+}
+
+// upstreamConfig returns the config syncers reach the logical cluster with.
+func (c *Controller) upstreamConfig(logicalCluster string) (*rest.Config, error) {
+	kubeConfig, err := logicalcluster.Kubeconfig(c.kubeconfig, logicalCluster)
+	if err != nil {
+		return nil, err
 	}
-	if _, err := client.AppsV1().Deployments(syncerNS).Create(ctx, deployment, metav1.CreateOptions{}); err != nil {
-		if k8serrors.IsAlreadyExists(err) {
-			// Update Deployment
-			if _, err := client.AppsV1().Deployments(syncerNS).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
-				klog.Error(err)
-				return err
-			}
-		} else {
-			return err
-		}
+	return clientcmd.NewNonInteractiveClientConfig(*kubeConfig, logicalCluster, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+}
+
+// releaseFinalizers removes the finalizer of the syncer of a deleted Cluster
+// from the objects of the given resources synced to it, whose deletion would
+// otherwise wait for it forever.
+func (c *Controller) releaseFinalizers(ctx context.Context, clusterID, logicalCluster string, resources []string) error {
+	upstream, err := c.upstreamConfig(logicalCluster)
+	if err != nil {
+		return err
 	}
-	return nil
+	return syncer.ReleaseFinalizers(ctx, upstream, clusterID, resources)
 }
 
-// uninstallSyncer uninstalls the syncer image from the target cluster.
-func uninstallSyncer(ctx context.Context, client kubernetes.Interface, logicalCluster string) {
-	if err := client.CoreV1().ConfigMaps(syncerNS).Delete(ctx, syncerConfigMapName(logicalCluster), metav1.DeleteOptions{}); err != nil {
-		klog.Error(err)
+// syncerTransforms returns what the syncer of the Cluster, syncing from the
+// given logical cluster, transforms: the mappings and namespace strategy of
+// its annotations, then the Controller's transformers.
+func (c *Controller) syncerTransforms(cluster *v1alpha1.Cluster, logicalCluster string) (syncer.Transforms, error) {
+	t, err := namespaceTransforms(cluster, logicalCluster)
+	if err != nil {
+		return syncer.Transforms{}, err
+	}
+	if t.ServiceAccounts, err = syncer.ParseMapping(cluster.Annotations[serviceAccountMappingAnnotation]); err != nil {
+		return syncer.Transforms{}, fmt.Errorf("%s annotation: %w", serviceAccountMappingAnnotation, err)
+	}
+	if t.Custom, err = syncer.NewPipeline(c.transformers); err != nil {
+		return syncer.Transforms{}, err
 	}
+	return t, nil
+}
 
-	if err := client.AppsV1().Deployments(syncerNS).Delete(ctx, syncerWorkloadName(logicalCluster), metav1.DeleteOptions{}); err != nil {
-		klog.Error(err)
+// rateLimitFor returns the rate limit of the syncer of the Cluster, as its
+// spec sets it.
+func rateLimitFor(cluster *v1alpha1.Cluster) syncer.RateLimit {
+	l := cluster.Spec.SyncerRateLimit
+	if l == nil {
+		return syncer.RateLimit{}
 	}
+	return syncer.RateLimit{QPS: float32(l.QPS), Burst: int(l.Burst), Adaptive: l.Adaptive}
 }
 
-func healthcheckSyncer(ctx context.Context, client kubernetes.Interface, logicalCluster string) error {
-	pods, err := client.CoreV1().Pods(syncerNS).List(ctx, metav1.ListOptions{LabelSelector: "app=" + syncerConfigMapName(logicalCluster)})
+// namespaceTransforms returns how the syncer of the Cluster maps namespaces,
+// as its annotations set.
+func namespaceTransforms(cluster *v1alpha1.Cluster, logicalCluster string) (syncer.Transforms, error) {
+	namespaces, err := syncer.ParseMapping(cluster.Annotations[namespaceMappingAnnotation])
 	if err != nil {
-		return err
+		return syncer.Transforms{}, fmt.Errorf("%s annotation: %w", namespaceMappingAnnotation, err)
 	}
-	if len(pods.Items) == 0 {
-		return fmt.Errorf("Syncer pod not ready: not syncer pod found")
-	}
-	if len(pods.Items) > 1 {
-		return fmt.Errorf("Syncer pod not ready: there should be only 1 syncer pod")
+	strategy, err := syncer.ParseNamespaceStrategy(cluster.Annotations[namespaceStrategyAnnotation], logicalCluster)
+	if err != nil {
+		return syncer.Transforms{}, fmt.Errorf("%s annotation: %w", namespaceStrategyAnnotation, err)
 	}
-	pod := pods.Items[0]
-	if pod.Status.Phase != corev1.PodRunning {
-		return fmt.Errorf("Syncer pod not ready: %s", pod.Status.Phase)
+	return syncer.Transforms{Namespaces: namespaces, NamespaceStrategy: strategy}, nil
+}
+
+// DownstreamNamespace returns the namespace of the Cluster its syncer syncs
+// the objects of a namespace of its logical cluster to, as its annotations
+// map them.
+func DownstreamNamespace(cluster *v1alpha1.Cluster, namespace string) (string, error) {
+	t, err := namespaceTransforms(cluster, cluster.GetClusterName())
+	if err != nil {
+		return "", err
 	}
-	return nil
+	return t.DownstreamNamespace(namespace), nil
 }
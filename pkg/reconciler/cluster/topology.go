@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+)
+
+// labelTopology sets the topology labels of placement of the Cluster that
+// aren't set already, to the region and zone all its nodes are labeled
+// with and the provider of their provider IDs, when they all agree. Labels
+// set by hand, or on an earlier reconcile, are kept.
+func labelTopology(ctx context.Context, client kubernetes.Interface, cluster *v1alpha1.Cluster) error {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(nodes.Items) == 0 {
+		return nil
+	}
+	regions, zones, providers := sets.NewString(), sets.NewString(), sets.NewString()
+	for _, node := range nodes.Items {
+		regions.Insert(node.Labels[placement.RegionLabel])
+		zones.Insert(node.Labels[placement.ZoneLabel])
+		providers.Insert(providerOf(&node))
+	}
+	for key, values := range map[string]sets.String{
+		placement.RegionLabel:   regions,
+		placement.ZoneLabel:     zones,
+		placement.ProviderLabel: providers,
+	} {
+		if _, found := cluster.Labels[key]; found || values.Len() != 1 || values.Has("") {
+			continue
+		}
+		if cluster.Labels == nil {
+			cluster.Labels = map[string]string{}
+		}
+		cluster.Labels[key] = values.List()[0]
+	}
+	return nil
+}
+
+// providerOf returns the cloud provider of the node, the scheme of its
+// provider ID, e.g. aws for aws:///us-east-1a/i-0123.
+func providerOf(node *corev1.Node) string {
+	if i := strings.Index(node.Spec.ProviderID, "://"); i > 0 {
+		return node.Spec.ProviderID[:i]
+	}
+	return ""
+}
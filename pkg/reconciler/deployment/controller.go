@@ -2,144 +2,401 @@ package deployment
 
 import (
 	"context"
-	"log"
 	"time"
 
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
 	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
-	"github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
 	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/transform"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
 	appsv1lister "k8s.io/client-go/listers/apps/v1"
+	autoscalingv1lister "k8s.io/client-go/listers/autoscaling/v1"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	networkingv1lister "k8s.io/client-go/listers/networking/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/util/workqueue"
 )
 
 const resyncPeriod = 10 * time.Hour
 
 // NewController returns a new Controller which splits new Deployment objects
-// into N virtual Deployments labeled for each Cluster that exists at the time
-// the Deployment is created.
-func NewController(cfg *rest.Config) *Controller {
-	client := appsv1client.NewForConfigOrDie(cfg)
-	kubeClient := kubernetes.NewForConfigOrDie(cfg)
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	sif := informers.NewSharedInformerFactoryWithOptions(kubeClient, resyncPeriod)
-	sif.Apps().V1().Deployments().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    func(obj interface{}) { queue.AddRateLimited(obj) },
-		UpdateFunc: func(_, obj interface{}) { queue.AddRateLimited(obj) },
+// into N virtual Deployments labeled for each Ready Cluster allowed by their
+// PlacementPolicy, and rebalances them as Clusters join, leave or change
+// readiness.
+//
+// Unless hpaMode is HPAModeOff, a root targeted by a HorizontalPodAutoscaler
+// is scaled as it asks for, and with HPAModeMirror the
+// HorizontalPodAutoscaler is also mirrored to each Cluster the root is on.
+// With networkPolicies, roots are warned of the NetworkPolicies selecting
+// their pods that Clusters they're on don't enforce, in their
+// DeploymentNetworkPolicyUnenforced condition.
+// The Clusters that are left are filtered and scored by sched, if not nil,
+// and the leafs are transformed for their Cluster by transforms.
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, hpaMode HPAMode, networkPolicies bool, sched *scheduler.Scheduler, transforms transform.Chain, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := New(Options{
+		KubeClient:      kubernetes.NewForConfigOrDie(cfg),
+		ClusterClient:   clusterclient.NewForConfigOrDie(cfg),
+		Informers:       shared,
+		HPAMode:         hpaMode,
+		NetworkPolicies: networkPolicies,
+		Scheduler:       sched,
+		Transforms:      transforms,
+		LeaderElection:  leaderElection,
+		Retry:           retry,
 	})
-	stopCh := make(chan struct{}) // TODO: hook this up to SIGTERM/SIGINT
-	sif.WaitForCacheSync(stopCh)
-	sif.Start(stopCh)
+	if own {
+		shared.Kube.Start(c.StopCh())
+		shared.Cluster.Start(c.StopCh())
+	}
+	return c
+}
+
+// Options are what a Controller reaches kcp with, and how it places
+// Deployments, as NewController describes.
+type Options struct {
+	KubeClient    kubernetes.Interface
+	ClusterClient clusterclient.Interface
+	// Informers fill the Controller's caches, and are started by the caller.
+	Informers *base.Informers
+
+	HPAMode         HPAMode
+	NetworkPolicies bool
+	Scheduler       *scheduler.Scheduler
+	Transforms      transform.Chain
+	LeaderElection  *base.LeaderElectionConfig
+	Retry           *base.RetryPolicy
+}
 
-	csif := externalversions.NewSharedInformerFactoryWithOptions(clusterclient.NewForConfigOrDie(cfg), resyncPeriod)
-	csif.WaitForCacheSync(stopCh)
-	csif.Start(stopCh)
+// New returns a Controller as NewController does, reaching kcp with the
+// clients of opts rather than a config, such as fake ones.
+func New(opts Options) *Controller {
+	sif, csif := opts.Informers.Kube, opts.Informers.Cluster
+	hpaMode, transforms := opts.HPAMode, opts.Transforms
 
-	return &Controller{
-		queue:         queue,
-		client:        client,
-		indexer:       sif.Apps().V1().Deployments().Informer().GetIndexer(),
-		lister:        sif.Apps().V1().Deployments().Lister(),
-		clusterLister: csif.Cluster().V1alpha1().Clusters().Lister(),
-		stopCh:        stopCh,
+	deployments := sif.Apps().V1().Deployments().Informer()
+	clusters := csif.Cluster().V1alpha1().Clusters().Informer()
+	policies := csif.Cluster().V1alpha1().PlacementPolicies().Informer()
+	quotas := csif.Cluster().V1alpha1().WorkspaceQuotas().Informer()
+	disruptionBudgets := csif.Cluster().V1alpha1().ClusterDisruptionBudgets().Informer()
+	claims := sif.Core().V1().PersistentVolumeClaims().Informer()
+	c := &Controller{
+		client:         opts.KubeClient.AppsV1(),
+		indexer:        logicalcluster.IndexerFor(deployments),
+		clusterIndexer: logicalcluster.IndexerFor(clusters),
+		policyIndexer:  logicalcluster.IndexerFor(policies),
+		quotaIndexer:   logicalcluster.IndexerFor(quotas),
+		budgetIndexer:  logicalcluster.IndexerFor(disruptionBudgets),
+		claimIndexer:   logicalcluster.IndexerFor(claims),
+		clusterClient:  opts.ClusterClient.ClusterV1alpha1(),
+		kubeClient:     opts.KubeClient,
+		hpaMode:        hpaMode,
+		scheduler:      opts.Scheduler,
+		transforms:     transforms,
 	}
+	c.orphans = base.NewOrphans(appsv1.SchemeGroupVersion.WithResource("deployments"), c.indexer, leafClient{c.client})
+	c.Controller = base.New("deployment", opts.KubeClient, opts.LeaderElection, opts.Retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.SetBatchLister(c.listRoots)
+	c.AddCacheSyncs(
+		deployments.HasSynced,
+		clusters.HasSynced,
+		policies.HasSynced,
+		quotas.HasSynced,
+		disruptionBudgets.HasSynced,
+		claims.HasSynced,
+	)
+	c.AddPeriodic(c.orphans.Collect, gcInterval)
+
+	deployments.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(oldObj, obj interface{}) {
+			// The status of roots is that the controller aggregates from
+			// their leafs, whose updates are what it follows.
+			if obj.(*appsv1.Deployment).Labels[base.LeafLabel] == "" && base.StatusOnly(oldObj, obj) {
+				return
+			}
+			if base.Resynced(oldObj, obj) {
+				c.EnqueueLow(obj)
+				return
+			}
+			c.Enqueue(obj)
+		},
+		DeleteFunc: func(obj interface{}) { c.deleted(obj) },
+	})
+	if hpaMode != HPAModeOff {
+		hpas := sif.Autoscaling().V1().HorizontalPodAutoscalers().Informer()
+		c.hpaIndexer = logicalcluster.IndexerFor(hpas)
+		c.AddCacheSyncs(hpas.HasSynced)
+		hpas.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueueTargetOf(obj) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueueTargetOf(obj) },
+			DeleteFunc: func(obj interface{}) { c.enqueueTargetOf(obj) },
+		})
+	}
+	if opts.NetworkPolicies {
+		// A NetworkPolicy's pod selector may match any root in its namespace.
+		networkPolicies := sif.Networking().V1().NetworkPolicies().Informer()
+		c.networkPolicyIndexer = logicalcluster.IndexerFor(networkPolicies)
+		c.AddCacheSyncs(networkPolicies.HasSynced)
+		networkPolicies.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+			DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		})
+	}
+
+	// Clusters joining, leaving or changing readiness, eviction, failback, weight, size, taints, cordon, storage,
+	// NetworkPolicy support or,
+	// when transforming leafs, labels and annotations affect every root Deployment's placement in
+	// their workspace, so rebalance all of them.
+	clusters.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCluster, newCluster := oldObj.(*v1alpha1.Cluster), newObj.(*v1alpha1.Cluster)
+			if oldCluster.Status.Conditions.IsReady() != newCluster.Status.Conditions.IsReady() ||
+				oldCluster.Status.Conditions.IsEvicted() != newCluster.Status.Conditions.IsEvicted() ||
+				oldCluster.Annotations[placement.FailbackAnnotation] != newCluster.Annotations[placement.FailbackAnnotation] ||
+				oldCluster.EffectiveWeight() != newCluster.EffectiveWeight() ||
+				oldCluster.Spec.Unschedulable != newCluster.Spec.Unschedulable ||
+				!equality.Semantic.DeepEqual(oldCluster.Spec.Taints, newCluster.Spec.Taints) ||
+				!equality.Semantic.DeepEqual(oldCluster.Status.Allocatable, newCluster.Status.Allocatable) ||
+				!equality.Semantic.DeepEqual(oldCluster.Status.Architectures, newCluster.Status.Architectures) ||
+				!equality.Semantic.DeepEqual(oldCluster.Status.StorageClasses, newCluster.Status.StorageClasses) ||
+				oldCluster.Status.NetworkPolicies != newCluster.Status.NetworkPolicies ||
+				// PlacementPolicies select Clusters by their labels, such as
+				// the topology ones the Cluster Controller sets.
+				!equality.Semantic.DeepEqual(oldCluster.Labels, newCluster.Labels) ||
+				len(transforms) > 0 && !equality.Semantic.DeepEqual(oldCluster.Annotations, newCluster.Annotations) {
+				c.enqueueRoots(newObj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	// A PlacementPolicy's selectors may match any root in its namespace.
+	policies.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	// Room freed in a WorkspaceQuota may let the roots pending on it be placed.
+	quotas.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	// A ClusterDisruptionBudget may hold back, or stop holding back, the
+	// removal of any root in its namespace from a Cluster.
+	disruptionBudgets.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	// The roots mounting a PersistentVolumeClaim are placed where its
+	// StorageClass is, and wait for it to be created.
+	claims.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	if len(transforms) > 0 {
+		// The WorkloadOverrides of a namespace may apply to any root in it.
+		csif.Cluster().V1alpha1().WorkloadOverrides().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+			DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		})
+	}
+
+	return c
 }
 
 type Controller struct {
-	queue         workqueue.RateLimitingInterface
-	client        *appsv1client.AppsV1Client
-	indexer       cache.Indexer
-	lister        appsv1lister.DeploymentLister
-	clusterLister clusterlisters.ClusterLister
-	kubeClient    kubernetes.Interface
-	stopCh        chan struct{}
+	*base.Controller
+
+	client         appsv1client.AppsV1Interface
+	indexer        cache.Indexer
+	clusterIndexer cache.Indexer
+	policyIndexer  cache.Indexer
+	quotaIndexer   cache.Indexer
+	budgetIndexer  cache.Indexer
+	claimIndexer   cache.Indexer
+	orphans        *base.Orphans
+	clusterClient  clusterv1alpha1.ClusterV1alpha1Interface
+	kubeClient     kubernetes.Interface
+	hpaMode        HPAMode
+	hpaIndexer     cache.Indexer
+	// Only set with NetworkPolicies.
+	networkPolicyIndexer cache.Indexer
+	scheduler            *scheduler.Scheduler
+	transforms           transform.Chain
+	dryRun               bool
 }
 
-func (c *Controller) Start(numThreads int) {
-	defer c.queue.ShutDown()
-	for i := 0; i < numThreads; i++ {
-		go wait.Until(c.startWorker, time.Second, c.stopCh)
-	}
-	log.Println("Starting workers")
-	<-c.stopCh
-	log.Println("Stopping workers")
+// SetDryRun makes the Controller only preview where every root would be
+// placed, as if each had the placement.DryRunAnnotation.
+func (c *Controller) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
 }
 
-func (c *Controller) startWorker() {
-	for c.processNextWorkItem() {
-	}
+// The listers of the objects of a workspace. Deployments are only placed
+// on the Clusters of their own workspace, following its PlacementPolicies,
+// within its WorkspaceQuotas and ClusterDisruptionBudgets, and where the
+// PersistentVolumeClaims they mount can be provisioned.
+
+func (c *Controller) deployments(workspace string) appsv1lister.DeploymentLister {
+	return appsv1lister.NewDeploymentLister(logicalcluster.Scoped(c.indexer, workspace))
 }
 
-func (c *Controller) processNextWorkItem() bool {
-	// Wait until there is a new item in the working queue
-	k, quit := c.queue.Get()
-	if quit {
-		return false
-	}
-	key := k.(string)
+func (c *Controller) clusters(workspace string) clusterlisters.ClusterLister {
+	return clusterlisters.NewClusterLister(logicalcluster.Scoped(c.clusterIndexer, workspace))
+}
+
+func (c *Controller) policies(workspace string) clusterlisters.PlacementPolicyLister {
+	return clusterlisters.NewPlacementPolicyLister(logicalcluster.Scoped(c.policyIndexer, workspace))
+}
 
-	// No matter what, tell the queue we're done with this key, to unblock
-	// other workers.
-	defer c.queue.Done(key)
+func (c *Controller) quotas(workspace string) clusterlisters.WorkspaceQuotaLister {
+	return clusterlisters.NewWorkspaceQuotaLister(logicalcluster.Scoped(c.quotaIndexer, workspace))
+}
+
+func (c *Controller) disruptionBudgets(workspace string) clusterlisters.ClusterDisruptionBudgetLister {
+	return clusterlisters.NewClusterDisruptionBudgetLister(logicalcluster.Scoped(c.budgetIndexer, workspace))
+}
 
-	err := c.process(key)
-	c.handleErr(err, key)
-	return true
+func (c *Controller) claims(workspace string) corev1lister.PersistentVolumeClaimLister {
+	return corev1lister.NewPersistentVolumeClaimLister(logicalcluster.Scoped(c.claimIndexer, workspace))
 }
 
-func (c *Controller) handleErr(err error, key string) {
-	// Reconcile worked, nothing else to do for this workqueue item.
-	if err == nil {
-		c.queue.Forget(key)
+func (c *Controller) hpas(workspace string) autoscalingv1lister.HorizontalPodAutoscalerLister {
+	return autoscalingv1lister.NewHorizontalPodAutoscalerLister(logicalcluster.Scoped(c.hpaIndexer, workspace))
+}
+
+func (c *Controller) networkPolicies(workspace string) networkingv1lister.NetworkPolicyLister {
+	return networkingv1lister.NewNetworkPolicyLister(logicalcluster.Scoped(c.networkPolicyIndexer, workspace))
+}
+
+// enqueueRoots enqueues every root Deployment, i.e. those not split from
+// another one, of the workspace of the given Cluster, PlacementPolicy,
+// WorkspaceQuota, ClusterDisruptionBudget, PersistentVolumeClaim or
+// NetworkPolicy.
+func (c *Controller) enqueueRoots(obj interface{}) {
+	workspace, err := logicalcluster.ClusterName(obj)
+	if err != nil {
+		runtime.HandleError(err)
 		return
 	}
+	c.EnqueueWorkspace(workspace)
+}
 
-	// Re-enqueue up to 5 times.
-	num := c.queue.NumRequeues(key)
-	if num < 5 {
-		log.Printf("Error reconciling key %q, retrying... (#%d): %v", key, num, err)
-		c.queue.AddRateLimited(key)
-		return
+// listRoots lists the root Deployments of the workspace, for
+// EnqueueWorkspace to enqueue.
+func (c *Controller) listRoots(workspace string) ([]interface{}, error) {
+	deployments, err := c.deployments(workspace).List(labels.Everything())
+	if err != nil {
+		return nil, err
 	}
+	var roots []interface{}
+	for _, d := range deployments {
+		if d.Labels[base.LeafLabel] == "" {
+			roots = append(roots, d)
+		}
+	}
+	return roots, nil
+}
 
-	// Give up and report error elsewhere.
-	c.queue.Forget(key)
-	runtime.HandleError(err)
-	log.Printf("Dropping key %q after failed retries: %v", key, err)
+// enqueueTargetOf enqueues the root Deployment a HorizontalPodAutoscaler
+// targets, or whose mirrored HorizontalPodAutoscaler it is.
+func (c *Controller) enqueueTargetOf(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	hpa, ok := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	if !ok || hpa.Spec.ScaleTargetRef.Kind != "Deployment" {
+		return
+	}
+	name := hpa.Spec.ScaleTargetRef.Name
+	if hpa.Labels[base.LeafLabel] != "" {
+		d, err := c.deployments(hpa.ClusterName).Deployments(hpa.Namespace).Get(name)
+		if err != nil {
+			return
+		}
+		if root := d.Labels[base.LeafLabel]; root != "" {
+			name = root
+		}
+	}
+	c.Queue().Add(logicalcluster.Key(hpa.ClusterName, hpa.Namespace, name))
 }
 
-func (c *Controller) process(key string) error {
+func (c *Controller) process(ctx context.Context, key string) error {
 	obj, exists, err := c.indexer.GetByKey(key)
 	if err != nil {
 		return err
 	}
 
 	if !exists {
-		log.Printf("Object with key %q was deleted", key)
-		return nil
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return c.collectLeafsOf(ctx, key)
 	}
 	current := obj.(*appsv1.Deployment)
 	previous := current.DeepCopy()
 
-	ctx := context.TODO()
-	if err := c.reconcile(ctx, current); err != nil {
-		return err
+	// After a partial failure, the root is still updated for the leafs that
+	// were written.
+	reconcileErr := c.reconcile(ctx, current)
+	if _, partial := base.PartialFailure(reconcileErr); reconcileErr != nil && !partial {
+		return reconcileErr
 	}
 
 	// If the object being reconciled changed as a result, update it.
+	if !equality.Semantic.DeepEqual(previous.Labels, current.Labels) ||
+		!equality.Semantic.DeepEqual(previous.Annotations, current.Annotations) ||
+		!equality.Semantic.DeepEqual(previous.Finalizers, current.Finalizers) ||
+		!equality.Semantic.DeepEqual(previous.Spec.Replicas, current.Spec.Replicas) {
+		updated, err := c.client.Deployments(current.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		if updated.DeletionTimestamp != nil && len(updated.Finalizers) == 0 {
+			// Its last finalizer was removed, so it's gone.
+			return nil
+		}
+		updated.Status = current.Status
+		current = updated
+	}
 	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
-		_, uerr := c.client.Deployments(current.Namespace).UpdateStatus(ctx, current, metav1.UpdateOptions{})
-		return uerr
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (apiruntime.Object, error) {
+				return c.client.Deployments(current.Namespace).Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (apiruntime.Object, error) {
+				return c.client.Deployments(current.Namespace).Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return err
+	return reconcileErr
 }
@@ -3,130 +3,849 @@ package deployment
 import (
 	"context"
 	"fmt"
-	"log"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/reconciler/quota"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	"github.com/kcp-dev/kcp/pkg/tracing"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const (
 	clusterLabel = "cluster"
-	ownedByLabel = "owned-by"
-	pollInterval = time.Minute
+	gcInterval   = time.Minute
 )
 
 func (c *Controller) reconcile(ctx context.Context, deployment *appsv1.Deployment) error {
-	log.Println("reconciling deployment", deployment.Name)
+	logging.FromContext(ctx).V(2).Info("Reconciling deployment")
 
-	if deployment.Labels == nil || deployment.Labels[clusterLabel] == "" {
-		// This is a root deployment; get its leafs.
-		sel, err := labels.Parse(fmt.Sprintf("%s=%s", ownedByLabel, deployment.Labels[deployment.Name]))
+	if deployment.Labels[base.LeafLabel] == "" {
+		if deployment.DeletionTimestamp != nil {
+			return c.finalizeRoot(ctx, deployment)
+		}
+		addRootFinalizer(deployment)
+		// This is a root deployment; make sure its leafs match the current set of Clusters.
+		return c.reconcileAutoscaledRoot(ctx, deployment)
+	}
+
+	if deployment.DeletionTimestamp != nil {
+		if syncer.ForceDeleted(deployment) {
+			syncer.RemoveFinalizers(deployment, "")
+		}
+	} else if deleted, err := c.orphans.DeleteIfOrphaned(ctx, deployment); err != nil || deleted {
+		return err
+	}
+
+	// A leaf deployment was updated; its root aggregates the status of all leafs.
+	c.Queue().Add(logicalcluster.Key(deployment.ClusterName, deployment.Namespace, deployment.Labels[base.LeafLabel]))
+	return nil
+}
+
+// reconcileAutoscaledRoot reconciles a root Deployment, first scaling it to
+// what the HorizontalPodAutoscaler targeting it asks for, unless
+// HorizontalPodAutoscalers are ignored, and then mirroring the
+// HorizontalPodAutoscaler to the Clusters it was placed on if enabled.
+func (c *Controller) reconcileAutoscaledRoot(ctx context.Context, root *appsv1.Deployment) error {
+	if c.hpaMode == HPAModeOff {
+		return c.reconcileRoot(ctx, root)
+	}
+	hpa, err := c.hpaFor(root)
+	if err != nil {
+		return err
+	}
+	if hpa != nil {
+		from := int32(1)
+		if root.Spec.Replicas != nil {
+			from = *root.Spec.Replicas
+		}
+		scaleTo(root, hpa)
+		c.recordScaled(root, hpa, from)
+	}
+	if err := c.reconcileRoot(ctx, root); err != nil {
+		return err
+	}
+	if c.hpaMode != HPAModeMirror {
+		return nil
+	}
+	return c.reconcileHPA(ctx, root, hpa)
+}
+
+// reconcileRoot places a root Deployment onto the Ready Clusters allowed by
+// its PlacementPolicy, if any, once it fits the WorkspaceQuotas of its
+// workspace. With a single Cluster and no existing leafs, or if the root is
+// pinned, the root itself is labeled for that Cluster; otherwise one leaf is
+// kept per Cluster, created, resized or deleted as Clusters join and leave.
+// Roots with scheduling disabled are left alone. Where the others are placed,
+// or would be in dry-run, is recorded on them, see recordDecision.
+func (c *Controller) reconcileRoot(ctx context.Context, root *appsv1.Deployment) error {
+	mode, err := placement.SchedulingModeFor(root.Annotations)
+	if err != nil {
+		// Retrying won't help until the annotation is fixed.
+		setNotProgressing(root, "InvalidSchedulingMode", err.Error())
+		return nil
+	}
+	if mode == placement.SchedulingModeDisabled {
+		return nil
+	}
+	dryRun := c.dryRun || placement.DryRun(root.Annotations)
+	tolerations, err := placement.TolerationsFor(root.Annotations)
+	if err != nil {
+		setNotProgressing(root, "InvalidTolerations", err.Error())
+		return nil
+	}
+	rollout, err := placement.RolloutFor(root.Annotations)
+	if err != nil {
+		setNotProgressing(root, "InvalidRolloutStrategy", err.Error())
+		return nil
+	}
+	if _, err := placement.BudgetFor(root.Annotations, replicasOf(root), 0); err != nil {
+		setNotProgressing(root, "InvalidMaxUnavailable", err.Error())
+		return nil
+	}
+	if _, err := placement.DescheduledFor(root.Annotations); err != nil {
+		setNotProgressing(root, "InvalidDescheduled", err.Error())
+		return nil
+	}
+
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+
+	decision := &placement.Decision{DryRun: dryRun}
+	all, err := c.clusters(root.ClusterName).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	cls, err := placement.ReadyClusters(c.clusters(root.ClusterName))
+	if err != nil {
+		return err
+	}
+	decision.Filter(all, cls, "not Ready")
+	syncing := placement.Syncing(cls, appsv1.SchemeGroupVersion.WithResource("deployments").GroupResource())
+	decision.Filter(cls, syncing, "doesn't sync Deployments")
+	cls = syncing
+	claims, msg, err := placement.ClaimsOf(&root.Spec.Template.Spec, c.claims(root.ClusterName).PersistentVolumeClaims(root.Namespace))
+	if err != nil {
+		return err
+	}
+	if msg != "" {
+		// The root is reconciled again once the claim is created.
+		setNotProgressing(root, "ClaimNotFound", msg)
+		return nil
+	}
+	if ready := cls; len(claims) > 0 && len(ready) > 0 {
+		if cls = placement.Storing(cls, claims); len(cls) == 0 {
+			// As with no Clusters, keep the current placement until one has the storage.
+			setNotProgressing(root, "NoCompatibleStorage", fmt.Sprintf("None of the %d Ready clusters has a StorageClass for the volume claims of the Deployment", len(ready)))
+			return nil
+		}
+		decision.Filter(ready, cls, "has no StorageClass for the Deployment's volume claims")
+	}
+	if ready := cls; len(ready) > 0 {
+		if cls = placement.Satisfying(cls, &root.Spec.Template.Spec); len(cls) == 0 {
+			// As with no Clusters, keep the current placement until one has the nodes.
+			setNotProgressing(root, "NoCompatibleNodes", fmt.Sprintf("None of the %d Ready clusters has nodes of the architecture or with the extended resources the Deployment's pods need", len(ready)))
+			return nil
+		}
+		decision.Filter(ready, cls, "has no nodes of the architecture or with the extended resources the Deployment's pods need")
+	}
+	if claim, unshared := placement.Unshared(claims); unshared && mode != placement.SchedulingModePinned {
+		// Its replicas all mount a volume only one node can write, so
+		// they can't be split across Clusters.
+		logging.FromContext(ctx).V(2).Info("Pinning Deployment whose volume claim isn't ReadWriteMany", "claim", claim.Name)
+		mode = placement.SchedulingModePinned
+	}
+	if ready := cls; len(ready) > 0 {
+		if cls = placement.Untainted(cls, tolerations); len(cls) == 0 {
+			// As with no Clusters, keep the current placement until a taint is lifted.
+			setNotProgressing(root, "ClustersTainted", fmt.Sprintf("All %d Ready clusters have taints the Deployment doesn't tolerate", len(ready)))
+			return nil
+		}
+		decision.Filter(ready, cls, "has taints the Deployment doesn't tolerate")
+	}
+
+	policy, err := placement.PolicyFor(c.policies(root.ClusterName), root.Namespace, root.Labels)
+	if err != nil {
+		return err
+	}
+	others, err := c.othersPlaced(root, policy)
+	if err != nil {
+		return err
+	}
+	if len(cls) > 0 {
+		var msg string
+		untainted := cls
+		cls, msg, err = placement.Place(policy, cls, others)
 		if err != nil {
 			return err
 		}
-		leafs, err := c.lister.List(sel)
+		if msg != "" {
+			// As with no Clusters, keep the current placement until the policy can be met.
+			setNotProgressing(root, "PlacementUnsatisfiable", msg)
+			return nil
+		}
+		if policy != nil {
+			decision.Filter(untainted, cls, "not allowed by PlacementPolicy "+policy.Name)
+		}
+		allowed := cls
+		var wait time.Duration
+		cls, wait = placement.FailingBack(policy, cls, placedOn(root, leafs), time.Now())
+		decision.Filter(allowed, cls, "recovering from an eviction, not failed back to yet")
+		if wait > 0 {
+			c.Queue().AddAfter(logicalcluster.Key(root.ClusterName, root.Namespace, root.Name), wait)
+		}
+	}
+
+	result, err := c.scheduler.Schedule(ctx, scheduler.Workload{Resource: appsv1.SchemeGroupVersion.WithResource("deployments"), Object: root}, cls)
+	if err != nil {
+		return err
+	}
+	if result.Message != "" {
+		setNotProgressing(root, "PlacementUnsatisfiable", result.Message)
+		return nil
+	}
+	decision.Filter(cls, result.Clusters, "filtered out by the scheduler")
+	cls = result.Clusters
+
+	if len(cls) == 0 {
+		// Keep whatever was placed before, it will be rebalanced once a Cluster is Ready again.
+		setNotProgressing(root, "NoRegisteredClusters", "kcp has no clusters registered to receive Deployments")
+		return nil
+	}
+
+	if len(leafs) == 0 && root.Labels[clusterLabel] != "" {
+		cl, err := placement.GetCluster(c.clusters(root.ClusterName), root.Labels[clusterLabel])
 		if err != nil {
 			return err
 		}
-
-		if len(leafs) == 0 {
-			if err := c.createLeafs(ctx, deployment); err != nil {
-				return err
-			}
+		if placement.Tolerated(cl, tolerations) {
+			// Wait for the Cluster to be Ready again, or evicted from, before moving the root off it.
+			return nil
 		}
+	}
 
-	} else {
-		// A leaf deployment was updated; get others and aggregate status.
-		sel, err := labels.Parse(fmt.Sprintf("%s=%s", ownedByLabel, deployment.Labels[ownedByLabel]))
+	if len(leafs) == 0 && root.Labels[clusterLabel] == "" {
+		// The root is placed for the first time, if its workspace has room for it.
+		want := quota.Usage(appsv1.SchemeGroupVersion.WithResource("deployments").GroupResource(), root.Spec.Replicas, &root.Spec.Template.Spec)
+		quotas, err := c.quotas(root.ClusterName).List(labels.Everything())
 		if err != nil {
 			return err
 		}
-		others, err := c.lister.List(sel)
-		if err != nil {
+		var msg string
+		if dryRun {
+			// Nothing is placed, so nothing is reserved.
+			msg = quota.Exceeds(quotas, want)
+		} else if msg, err = quota.Reserve(ctx, c.clusterClient, quotas, want); err != nil {
 			return err
 		}
+		if msg != "" {
+			setNotProgressing(root, "QuotaExceeded", msg)
+			return nil
+		}
+	}
 
-		// Aggregate .status from all leafs.
-		deployment.Status.Replicas = 0
-		deployment.Status.ReadyReplicas = 0
-		deployment.Status.AvailableReplicas = 0
-		deployment.Status.UnavailableReplicas = 0
-		for _, o := range others {
-			deployment.Status.Replicas += o.Status.Replicas
-			deployment.Status.ReadyReplicas += o.Status.ReadyReplicas
-			deployment.Status.AvailableReplicas += o.Status.AvailableReplicas
-			deployment.Status.UnavailableReplicas += o.Status.UnavailableReplicas
+	if mode == placement.SchedulingModePinned {
+		ranked, current := result.Ranked(placement.Rank(policy, cls)), root.Labels[clusterLabel]
+		if placement.FailsBack(policy, ranked, current) {
+			current = ""
+		}
+		cl, msg := placement.Pin(ranked, root.Annotations[placement.PinnedClusterAnnotation], current)
+		if msg != "" {
+			setNotProgressing(root, "PlacementUnsatisfiable", msg)
+			return nil
+		}
+		decision.Replicas = map[string]int32{cl.Name: replicasOf(root)}
+		c.recordDecision(root, leafs, decision)
+		if dryRun {
+			return nil
+		}
+		// The whole Deployment goes to one Cluster, so it must not be split anymore.
+		if err := c.deleteLeafs(ctx, leafs); err != nil {
+			return err
+		}
+		if root.Labels == nil {
+			root.Labels = map[string]string{}
+		}
+		c.recordScheduled(root, cl.Name)
+		root.Labels[clusterLabel] = cl.Name
+		return c.recordUnenforced(root, cl.Name)
+	}
 
+	if len(cls) == 1 && len(leafs) == 0 && len(c.transforms) == 0 {
+		decision.Replicas = map[string]int32{cls[0].Name: replicasOf(root)}
+		c.recordDecision(root, leafs, decision)
+		if dryRun {
+			return nil
+		}
+		// nothing to split or transform, just label Deployment for the only cluster.
+		if root.Labels == nil {
+			root.Labels = map[string]string{}
 		}
 
-		// Cheat and set the root .status.conditions to the first leaf's .status.conditions.
-		// TODO: do better.
-		deployment.Status.Conditions = others[0].Status.Conditions
+		// TODO: munge cluster name
+		c.recordScheduled(root, cls[0].Name)
+		root.Labels[clusterLabel] = cls[0].Name
+		return c.recordUnenforced(root, cls[0].Name)
+	}
+
+	dist := placement.Distribution{Overflow: mode == placement.SchedulingModeOverflow, SpreadKey: placement.ReplicaSpreadKey(policy)}
+	if dist.Overflow {
+		// The most preferred Clusters, as for pinned workloads, are filled first.
+		cls = result.Ranked(placement.Rank(policy, cls))
+	}
+	fitting, desired, leafClusters, err := c.desiredReplicas(root, leafs, cls, tolerations, dist)
+	if err != nil {
+		return err
+	}
+	decision.Filter(cls, fitting, "has no room for a replica")
+	decision.Replicas = desired
+	for _, leaf := range leafs {
+		// The replicas of tolerated leafs stay where they are.
+		clusterName := leaf.Labels[clusterLabel]
+		if _, placed := desired[clusterName]; !placed && placement.Tolerated(leafClusters[clusterName], tolerations) {
+			decision.Replicas[clusterName] = replicasOf(leaf)
+			delete(decision.Filtered, clusterName)
+		}
+	}
+	c.recordDecision(root, leafs, decision)
+	if dryRun {
+		return nil
 	}
 
+	// The root is split from now on, so it shouldn't be synced anywhere itself.
+	delete(root.Labels, clusterLabel)
+
+	current, changed, err := c.rebalance(ctx, root, leafs, cls, tolerations, rollout, dist)
+	failed, partial := base.PartialFailure(err)
+	if err != nil && !partial {
+		return err
+	}
+	if changed {
+		if err := c.recordUnready(root); err != nil {
+			return err
+		}
+	}
+	prev := getCondition(root.Status, DeploymentNetworkPolicyUnenforced)
+	aggregateStatus(root, current)
+	if err := c.setPoliciesUnenforced(root, placedOn(root, current), prev); err != nil {
+		return err
+	}
+	if partial {
+		setLeafsFailed(root, failed)
+		return err
+	}
 	return nil
 }
 
-func (c *Controller) createLeafs(ctx context.Context, root *appsv1.Deployment) error {
-	cls, err := c.clusterLister.List(labels.Everything())
+// rebalance makes sure there's exactly one leaf per Cluster, each with its
+// share of the root's replicas as dist says, capped by the room the Cluster
+// reports for them; Clusters with no room at all are skipped. Leafs on
+// Clusters that aren't Ready are left alone until the Cluster is evicted
+// from, and are then scaled to zero, their replicas moving to the Ready
+// Clusters. Leafs on cordoned Clusters are left alone too. Changes of the
+// pod template are rolled out to the leafs as the rollout says, and leafs
+// are scaled down, or deleted, no faster than the root's Budget allows, and
+// not removed from a Cluster while its Disruptions don't allow it. It
+// returns the leafs that were kept, so their status can be aggregated, and
+// whether any leaf was created, resized or deleted.
+func (c *Controller) rebalance(ctx context.Context, root *appsv1.Deployment, leafs []*appsv1.Deployment, cls []*v1alpha1.Cluster, tolerations []corev1.Toleration, rollout placement.Rollout, dist placement.Distribution) ([]*appsv1.Deployment, bool, error) {
+	logger := logging.FromContext(ctx)
+	fitting, desired, leafClusters, err := c.desiredReplicas(root, leafs, cls, tolerations, dist)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
-	if len(cls) == 0 {
-		root.Status.Conditions = []appsv1.DeploymentCondition{{
-			Type:    appsv1.DeploymentProgressing,
-			Status:  corev1.ConditionFalse,
-			Reason:  "NoRegisteredClusters",
-			Message: "kcp has no clusters registered to receive Deployments",
-		}}
-		return nil
+	var available int32
+	carrying := sets.NewString()
+	for _, leaf := range leafs {
+		cl := leafClusters[leaf.Labels[clusterLabel]]
+		if cl != nil && cl.Status.Conditions.IsReady() {
+			available += leaf.Status.AvailableReplicas
+		}
+		// As last reported, for those of Clusters that aren't Ready.
+		if cl != nil && replicasOf(leaf) > 0 && leaf.Status.AvailableReplicas > 0 {
+			carrying.Insert(cl.Name)
+		}
+	}
+	// Validated by reconcileRoot.
+	budget, _ := placement.BudgetFor(root.Annotations, replicasOf(root), available)
+	disruptions, err := placement.DisruptionsFor(c.disruptionBudgets(root.ClusterName), root.Namespace, root.Labels, carrying)
+	if err != nil {
+		return nil, false, err
 	}
 
-	if len(cls) == 1 {
-		// nothing to split, just label Deployment for the only cluster.
-		if root.Labels == nil {
-			root.Labels = map[string]string{}
+	changed := false
+	existing := map[string]*appsv1.Deployment{}
+	var kept []*appsv1.Deployment
+	for _, leaf := range leafs {
+		clusterName := leaf.Labels[clusterLabel]
+		_, placed := desired[clusterName]
+		switch cl := leafClusters[clusterName]; {
+		case existing[clusterName] != nil:
+			// A duplicate, deleted below.
+		case placed:
+			existing[clusterName] = leaf
+			continue
+		case placement.Tolerated(cl, tolerations):
+			existing[clusterName] = leaf
+			kept = append(kept, leaf)
+			continue
+		case placement.Evicted(cl, tolerations):
+			existing[clusterName] = leaf
+			kept = append(kept, leaf)
+			if ok, msg := disruptions.Remove(clusterName); !ok {
+				c.recordDisruptionHeld(ctx, root, clusterName, msg)
+				continue
+			}
+			evicted, err := c.evict(ctx, root, leaf)
+			if err != nil {
+				return nil, false, err
+			}
+			changed = changed || evicted
+			continue
 		}
 
-		// TODO: munge cluster name
-		root.Labels[clusterLabel] = cls[0].Name
+		// This leaf's Cluster is gone or not allowed anymore, or it's a duplicate.
+		if cl := leafClusters[clusterName]; cl != nil && existing[clusterName] == nil {
+			if ok, msg := disruptions.Remove(clusterName); !ok {
+				existing[clusterName] = leaf
+				kept = append(kept, leaf)
+				c.recordDisruptionHeld(ctx, root, clusterName, msg)
+				continue
+			}
+		}
+		if cl := leafClusters[clusterName]; cl != nil && cl.Status.Conditions.IsReady() && existing[clusterName] == nil {
+			if keep := budget.ScaleDown(replicasOf(leaf), leaf.Status.AvailableReplicas, 0); keep > 0 {
+				// Wait for the replicas placed elsewhere to be available before removing the rest.
+				existing[clusterName] = leaf
+				kept = append(kept, leaf)
+				resized, err := c.resize(ctx, leaf, keep)
+				if err != nil {
+					return nil, false, err
+				}
+				changed = changed || resized
+				continue
+			}
+		}
+		if err := c.kubeClient.AppsV1().Deployments(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil {
+			metrics.SyncErrors.WithLabelValues("deployment", clusterName).Inc()
+			return nil, false, err
+		}
+		logger.Info("Deleted child deployment", "child", leaf.Name, logging.ClusterKey, clusterName)
+		c.Recorder().Eventf(root, corev1.EventTypeNormal, "Unscheduled", "Removed replicas from cluster %s", clusterName)
+		changed = true
+	}
+
+	// The leafs are written to several Clusters at once, and those that
+	// fail don't hold up the others.
+	var lock sync.Mutex
+	fittingClusters := map[string]*v1alpha1.Cluster{}
+	names := make([]string, 0, len(fitting))
+	for _, cl := range fitting {
+		fittingClusters[cl.Name] = cl
+		names = append(names, cl.Name)
+	}
+	budgeted := make(map[string]int32, len(fitting))
+	for _, cl := range fitting {
+		budgeted[cl.Name] = desired[cl.Name]
+		if leaf, ok := existing[cl.Name]; ok {
+			budgeted[cl.Name] = budget.ScaleDown(replicasOf(leaf), leaf.Status.AvailableReplicas, desired[cl.Name])
+		}
+		if budgeted[cl.Name] != desired[cl.Name] {
+			logger.Info("Holding back the scale down of child deployment", logging.ClusterKey, cl.Name, "replicas", budgeted[cl.Name], "desired", desired[cl.Name])
+		}
+	}
+	wanted := map[string]*appsv1.Deployment{}
+	transformErrs := map[string]error{}
+	for _, cl := range fitting {
+		vd := newLeaf(root, cl.Name, budgeted[cl.Name])
+		if err := c.transforms.Apply(ctx, cl, vd); err != nil {
+			transformErrs[cl.Name] = err
+			continue
+		}
+		wanted[cl.Name] = vd
+	}
+	held := heldBack(rollout, existing, wanted)
+	if len(held) > 0 {
+		logger.Info("Holding back the rollout of the pod template", "clusters", len(held))
+	}
+	fanOutErr := c.FanOut(names, func(name string) error {
+		if err := transformErrs[name]; err != nil {
+			return err
+		}
+		cl := fittingClusters[name]
+		want := budgeted[cl.Name]
+		vd := wanted[cl.Name]
+		leaf, ok := existing[cl.Name]
+		if !ok {
+			// TODO: munge namespace
+			tracing.Inject(ctx, vd)
+			if _, err := c.kubeClient.AppsV1().Deployments(root.Namespace).Create(ctx, vd, metav1.CreateOptions{}); err != nil {
+				metrics.SyncErrors.WithLabelValues("deployment", cl.Name).Inc()
+				return err
+			}
+			logger.Info("Created child deployment", "child", vd.Name, logging.ClusterKey, cl.Name, "replicas", want)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Scheduled", "Scheduled %d replicas to cluster %s", want, cl.Name)
+			lock.Lock()
+			defer lock.Unlock()
+			changed = true
+			return nil
+		}
+
+		lock.Lock()
+		kept = append(kept, leaf)
+		lock.Unlock()
+
+		// Keep the leaf in line with its root, as transformed for its Cluster,
+		// but for the pod template while the rollout hasn't reached it.
+		resized := leaf.Spec.Replicas == nil || *leaf.Spec.Replicas != want
+		updated := leaf.DeepCopy()
+		updated.Spec = vd.Spec
+		if held[cl.Name] {
+			updated.Spec.Template = leaf.Spec.Template
+		}
+		rolling := !equality.Semantic.DeepEqual(leaf.Spec.Template, updated.Spec.Template)
+		placement.MergeMetadata(&updated.ObjectMeta, vd.ObjectMeta)
+		if equality.Semantic.DeepEqual(leaf, updated) {
+			return nil
+		}
+		tracing.Inject(ctx, updated)
+		if _, err := c.kubeClient.AppsV1().Deployments(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			metrics.SyncErrors.WithLabelValues("deployment", cl.Name).Inc()
+			return err
+		}
+		if rolling && rollout.Strategy == placement.RolloutStrategyClusterByCluster {
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "RollingOut", "Rolling the pod template out to cluster %s", cl.Name)
+		}
+		if !resized {
+			logger.Info("Updated child deployment", "child", updated.Name, logging.ClusterKey, cl.Name)
+			return nil
+		}
+		c.Recorder().Eventf(root, corev1.EventTypeNormal, "Scheduled", "Scheduled %d replicas to cluster %s", want, cl.Name)
+		logger.Info("Resized child deployment", "child", updated.Name, logging.ClusterKey, cl.Name, "replicas", want)
+		lock.Lock()
+		defer lock.Unlock()
+		changed = true
 		return nil
+	})
+
+	if changed && len(fitting) < len(cls) {
+		for _, cl := range cls {
+			if _, placed := desired[cl.Name]; !placed {
+				c.Recorder().Eventf(root, corev1.EventTypeWarning, "InsufficientCapacity", "Skipped cluster %s, which has no room for a replica", cl.Name)
+			}
+		}
 	}
+	return kept, changed, fanOutErr
+}
 
-	// If there are >1 Clusters, create a virtual Deployment labeled/named for each Cluster with a subset of replicas requested.
-	// TODO: assign replicas unevenly based on load/scheduling.
-	replicasEach := *root.Spec.Replicas / int32(len(cls))
-	for _, cl := range cls {
-		vd := root.DeepCopy()
+// recordDisruptionHeld records that the root's replicas are kept on the
+// named Cluster, for the reason given by its Disruptions.
+func (c *Controller) recordDisruptionHeld(ctx context.Context, root *appsv1.Deployment, clusterName, msg string) {
+	logging.FromContext(ctx).Info("Holding back the removal of child deployment", logging.ClusterKey, clusterName, "reason", msg)
+	c.Recorder().Eventf(root, corev1.EventTypeWarning, "DisruptionBudget", "Kept replicas on cluster %s: %s", clusterName, msg)
+}
 
-		// TODO: munge cluster name
-		vd.Name = fmt.Sprintf("%s--%s", root.Name, cl.Name)
+// placedOn returns the Clusters the root has replicas on, itself or through
+// its leafs.
+func placedOn(root *appsv1.Deployment, leafs []*appsv1.Deployment) sets.String {
+	placed := sets.NewString()
+	if root.Labels[clusterLabel] != "" {
+		placed.Insert(root.Labels[clusterLabel])
+	}
+	for _, leaf := range leafs {
+		if replicasOf(leaf) > 0 {
+			placed.Insert(leaf.Labels[clusterLabel])
+		}
+	}
+	return placed
+}
 
-		if vd.Labels == nil {
-			vd.Labels = map[string]string{}
+// heldBack returns the Clusters whose existing leaf must keep its pod
+// template for now, as the rollout hasn't reached them: those whose leaf is
+// outdated, compared to the wanted one, but not in the rollout's next wave,
+// which only moves on once the leafs rolled out to are available.
+func heldBack(rollout placement.Rollout, existing, wanted map[string]*appsv1.Deployment) map[string]bool {
+	var outdated []string
+	rolling := 0
+	for name, vd := range wanted {
+		leaf, ok := existing[name]
+		if !ok {
+			// New leafs have no earlier pod template to keep.
+			continue
 		}
-		vd.Labels[clusterLabel] = cl.Name
-		vd.Labels[ownedByLabel] = root.Name
+		if !equality.Semantic.DeepEqual(leaf.Spec.Template, vd.Spec.Template) {
+			outdated = append(outdated, name)
+		} else if !rolledOut(leaf) {
+			rolling++
+		}
+	}
+	held := map[string]bool{}
+	for _, name := range outdated {
+		held[name] = true
+	}
+	for _, name := range rollout.Wave(outdated, rolling) {
+		delete(held, name)
+	}
+	return held
+}
 
-		vd.Spec.Replicas = &replicasEach
+// rolledOut reports whether the pod template of leaf was rolled out to all
+// its replicas, and they are available, as its Cluster reports.
+func rolledOut(leaf *appsv1.Deployment) bool {
+	replicas := replicasOf(leaf)
+	return leaf.Status.ObservedGeneration >= leaf.Generation &&
+		leaf.Status.Replicas == replicas &&
+		leaf.Status.UpdatedReplicas == replicas &&
+		leaf.Status.AvailableReplicas == replicas
+}
 
-		// Set OwnerReference so deleting the Deployment deletes all virtual deployments.
-		vd.OwnerReferences = []metav1.OwnerReference{{
-			APIVersion: "apps/v1",
-			Kind:       "Deployment",
-			UID:        root.UID,
-		}}
+// desiredReplicas returns the Clusters with room for the root's replicas
+// and the replicas each should get, as rebalance places them, along with the
+// Clusters of the leafs, by name.
+func (c *Controller) desiredReplicas(root *appsv1.Deployment, leafs []*appsv1.Deployment, cls []*v1alpha1.Cluster, tolerations []corev1.Toleration, dist placement.Distribution) ([]*v1alpha1.Cluster, map[string]int32, map[string]*v1alpha1.Cluster, error) {
+	replicas := replicasOf(root)
 
-		// TODO: munge namespace
-		if _, err := c.kubeClient.AppsV1().Deployments(root.Namespace).Create(ctx, vd, metav1.CreateOptions{}); err != nil {
+	// Tolerated leafs' replicas aren't replaced elsewhere yet.
+	leafClusters := map[string]*v1alpha1.Cluster{}
+	for _, leaf := range leafs {
+		clusterName := leaf.Labels[clusterLabel]
+		cl, err := placement.GetCluster(c.clusters(root.ClusterName), clusterName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		leafClusters[clusterName] = cl
+		if placement.Tolerated(cl, tolerations) && leaf.Spec.Replicas != nil {
+			replicas -= *leaf.Spec.Replicas
+		}
+	}
+	if replicas < 0 {
+		replicas = 0
+	}
+	// Clusters without room for a single replica are skipped, and the others
+	// get no more replicas than they have room for, as long as some do.
+	perReplica := placement.PodRequests(&root.Spec.Template.Spec)
+	limits := map[string]int32{}
+	for _, cl := range cls {
+		var placed int32
+		for _, leaf := range leafs {
+			if leaf.Labels[clusterLabel] == cl.Name && leaf.Spec.Replicas != nil {
+				placed += *leaf.Spec.Replicas
+			}
+		}
+		if fit, limited := placement.ReplicasThatFit(cl, perReplica, placed); limited {
+			limits[cl.Name] = fit
+		}
+	}
+	// The descheduler moves replicas off overloaded Clusters.
+	caps, _ := placement.DescheduledFor(root.Annotations)
+	placement.Descheduled(limits, caps)
+	fitting := placement.Fitting(cls, limits)
+	return fitting, dist.Distribute(replicas, fitting, limits), leafClusters, nil
+}
+
+// replicasOf returns the replicas of the Deployment, 1 if unset.
+func replicasOf(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
+
+// evict scales a leaf on an evicted Cluster to zero, keeping it so that it
+// can be scaled back up once the Cluster is Ready again, and reports whether
+// it had to.
+func (c *Controller) evict(ctx context.Context, root, leaf *appsv1.Deployment) (bool, error) {
+	if leaf.Spec.Replicas != nil && *leaf.Spec.Replicas == 0 {
+		return false, nil
+	}
+	clusterName := leaf.Labels[clusterLabel]
+	updated := leaf.DeepCopy()
+	zero := int32(0)
+	updated.Spec.Replicas = &zero
+	if _, err := c.kubeClient.AppsV1().Deployments(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		metrics.SyncErrors.WithLabelValues("deployment", clusterName).Inc()
+		return false, err
+	}
+	logging.FromContext(ctx).Info("Evicted child deployment", "child", leaf.Name, logging.ClusterKey, clusterName)
+	c.Recorder().Eventf(root, corev1.EventTypeWarning, "Evicted", "Moved replicas off cluster %s, which has not been Ready for too long", clusterName)
+	return true, nil
+}
+
+// resize scales leaf to the given replicas, and reports whether it had to.
+func (c *Controller) resize(ctx context.Context, leaf *appsv1.Deployment, replicas int32) (bool, error) {
+	if replicasOf(leaf) == replicas {
+		return false, nil
+	}
+	clusterName := leaf.Labels[clusterLabel]
+	updated := leaf.DeepCopy()
+	updated.Spec.Replicas = &replicas
+	if _, err := c.kubeClient.AppsV1().Deployments(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		metrics.SyncErrors.WithLabelValues("deployment", clusterName).Inc()
+		return false, err
+	}
+	logging.FromContext(ctx).Info("Resized child deployment", "child", leaf.Name, logging.ClusterKey, clusterName, "replicas", replicas)
+	return true, nil
+}
+
+func (c *Controller) deleteLeafs(ctx context.Context, leafs []*appsv1.Deployment) error {
+	for _, leaf := range leafs {
+		if err := c.kubeClient.AppsV1().Deployments(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil {
+			metrics.SyncErrors.WithLabelValues("deployment", leaf.Labels[clusterLabel]).Inc()
 			return err
 		}
-		log.Printf("created child deployment %q", vd.Name)
+		logging.FromContext(ctx).Info("Deleted child deployment", "child", leaf.Name, logging.ClusterKey, leaf.Labels[clusterLabel])
 	}
+	return nil
+}
+
+// recordScheduled records an Event on a root that's about to be labeled for
+// a single Cluster, unless it already was.
+func (c *Controller) recordScheduled(root *appsv1.Deployment, clusterName string) {
+	if root.Labels[clusterLabel] != clusterName {
+		c.Recorder().Eventf(root, corev1.EventTypeNormal, "Scheduled", "Scheduled all replicas to cluster %s", clusterName)
+	}
+}
+
+// recordDecision records on a root where it's placed, in its
+// placement.DecisionAnnotation. In dry-run, an Event reports each change of
+// the decision instead of the leafs, which are left as they are.
+func (c *Controller) recordDecision(root *appsv1.Deployment, leafs []*appsv1.Deployment, decision *placement.Decision) {
+	recorded := decision.String()
+	if decision.DryRun && root.Annotations[placement.DecisionAnnotation] != recorded {
+		c.Recorder().Eventf(root, corev1.EventTypeNormal, "DryRun", "Would schedule %s", placement.FormatReplicas(decision.Replicas))
+	}
+	if root.Annotations == nil {
+		root.Annotations = map[string]string{}
+	}
+	root.Annotations[placement.DecisionAnnotation] = recorded
+	if decision.DryRun && len(leafs) == 0 && root.Labels[clusterLabel] == "" {
+		setNotProgressing(root, "DryRun", "Only previewing the placement, recorded in the "+placement.DecisionAnnotation+" annotation")
+	}
+}
 
+// recordUnready records an Event on a root whose placement just changed for
+// each Cluster it was kept off because the Cluster isn't Ready.
+func (c *Controller) recordUnready(root *appsv1.Deployment) error {
+	unready, err := placement.UnreadyClusters(c.clusters(root.ClusterName))
+	if err != nil {
+		return err
+	}
+	for _, cl := range unready {
+		c.Recorder().Eventf(root, corev1.EventTypeWarning, "ClusterNotReady", "Skipped cluster %s, which is not Ready", cl.Name)
+	}
 	return nil
 }
+
+// setNotProgressing reports on the root why it couldn't be placed.
+func setNotProgressing(root *appsv1.Deployment, reason, message string) {
+	root.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:    appsv1.DeploymentProgressing,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}}
+}
+
+// setLeafsFailed reports the Clusters whose leafs couldn't be created or
+// updated in the ReplicaFailure condition of the root, which the leafs of
+// the others were written for.
+func setLeafsFailed(root *appsv1.Deployment, failed base.ClusterErrors) {
+	now := metav1.Now()
+	cond := appsv1.DeploymentCondition{
+		Type:               appsv1.DeploymentReplicaFailure,
+		Status:             corev1.ConditionTrue,
+		Reason:             "LeafsFailed",
+		Message:            fmt.Sprintf("Failed to place the replicas of clusters %s: %v", strings.Join(failed.Clusters(), ", "), failed),
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	}
+	for i := range root.Status.Conditions {
+		if prev := root.Status.Conditions[i]; prev.Type == cond.Type {
+			if prev.Status == cond.Status {
+				cond.LastTransitionTime = prev.LastTransitionTime
+			}
+			root.Status.Conditions[i] = cond
+			return
+		}
+	}
+	root.Status.Conditions = append(root.Status.Conditions, cond)
+}
+
+// newLeaf returns a virtual Deployment for the given root, labeled/named for
+// the given Cluster.
+func newLeaf(root *appsv1.Deployment, clusterName string, replicas int32) *appsv1.Deployment {
+	vd := root.DeepCopy()
+
+	// TODO: munge cluster name
+	vd.Name = fmt.Sprintf("%s--%s", root.Name, clusterName)
+	vd.ResourceVersion = ""
+	vd.UID = ""
+
+	if vd.Labels == nil {
+		vd.Labels = map[string]string{}
+	}
+	vd.Labels[clusterLabel] = clusterName
+	vd.Labels[base.LeafLabel] = root.Name
+	// The root's finalizers are its own; the syncer of the Cluster adds its own.
+	vd.Finalizers = nil
+	// Where the root is placed is only recorded on the root.
+	delete(vd.Annotations, placement.DecisionAnnotation)
+	delete(vd.Annotations, placement.DescheduledAnnotation)
+
+	vd.Spec.Replicas = &replicas
+	vd.Status = appsv1.DeploymentStatus{}
+
+	// Set OwnerReference so deleting the Deployment deletes all virtual deployments.
+	vd.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       root.Name,
+		UID:        root.UID,
+	}}
+	return vd
+}
+
+// leafsFor returns the virtual Deployments split from the given root.
+func (c *Controller) leafsFor(root *appsv1.Deployment) ([]*appsv1.Deployment, error) {
+	sel, err := labels.Parse(fmt.Sprintf("%s=%s", base.LeafLabel, root.Name))
+	if err != nil {
+		return nil, err
+	}
+	return c.deployments(root.ClusterName).Deployments(root.Namespace).List(sel)
+}
+
+// othersPlaced returns the other Deployments in the root's namespace that
+// are on a Cluster, as roots or leafs, if its PlacementPolicy avoids them.
+func (c *Controller) othersPlaced(root *appsv1.Deployment, policy *v1alpha1.PlacementPolicy) ([]placement.Workload, error) {
+	if !placement.HasAntiAffinity(policy) {
+		return nil, nil
+	}
+	deployments, err := c.deployments(root.ClusterName).Deployments(root.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var others []placement.Workload
+	for _, d := range deployments {
+		if d.Name == root.Name || d.Labels[base.LeafLabel] == root.Name || d.Labels[clusterLabel] == "" {
+			continue
+		}
+		others = append(others, placement.Workload{Labels: d.Labels, Cluster: d.Labels[clusterLabel]})
+	}
+	return others, nil
+}
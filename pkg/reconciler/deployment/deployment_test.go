@@ -0,0 +1,403 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base/basetest"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const workspace = "admin"
+
+type fixture struct {
+	*basetest.Fixture
+	c *Controller
+}
+
+func newFixture(t *testing.T, clusters ...*v1alpha1.Cluster) *fixture {
+	f := &fixture{Fixture: basetest.New(t)}
+	f.c = New(Options{KubeClient: f.Kube, ClusterClient: f.Clusters, Informers: f.Informers})
+	for _, cl := range clusters {
+		f.Add(f.Informers.Cluster.Cluster().V1alpha1().Clusters().Informer(), cl)
+	}
+	return f
+}
+
+// addDeployments creates the Deployments, as the API server and the cache
+// of the Controller would have them.
+func (f *fixture) addDeployments(deployments ...*appsv1.Deployment) {
+	for _, d := range deployments {
+		if _, err := f.Kube.AppsV1().Deployments(d.Namespace).Create(context.Background(), d, metav1.CreateOptions{}); err != nil {
+			f.T.Fatal(err)
+		}
+		f.Add(f.Informers.Kube.Apps().V1().Deployments().Informer(), d)
+	}
+}
+
+func (f *fixture) process(name string) error {
+	return f.c.process(context.Background(), logicalcluster.Key(workspace, "default", name))
+}
+
+// replicas returns the replicas of the Deployments in kcp, by name.
+func (f *fixture) replicas() map[string]int32 {
+	list, err := f.Kube.AppsV1().Deployments("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	replicas := map[string]int32{}
+	for _, d := range list.Items {
+		replicas[d.Name] = replicasOf(&d)
+	}
+	return replicas
+}
+
+func (f *fixture) get(name string) *appsv1.Deployment {
+	d, err := f.Kube.AppsV1().Deployments("default").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	return d
+}
+
+func readyCluster(name string) *v1alpha1.Cluster {
+	cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: workspace}}
+	cl.Status.Conditions.SetReady(corev1.ConditionTrue, "SyncerReady", "")
+	return cl
+}
+
+func deployment(name string, replicas int32, labels map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ClusterName: workspace, Labels: labels},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func leaf(root, clusterName string, replicas int32) *appsv1.Deployment {
+	return deployment(root+"--"+clusterName, replicas, map[string]string{base.LeafLabel: root, clusterLabel: clusterName})
+}
+
+func TestSplit(t *testing.T) {
+	f := newFixture(t, readyCluster("east"), readyCluster("west"))
+	f.addDeployments(deployment("web", 3, nil))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.replicas()
+	if len(got) != 3 || got["web--east"]+got["web--west"] != 3 || got["web--east"] == 0 || got["web--west"] == 0 {
+		t.Errorf("split into %v", got)
+	}
+	root := f.get("web")
+	if root.Labels[clusterLabel] != "" {
+		t.Errorf("the root is synced to %s itself", root.Labels[clusterLabel])
+	}
+	if len(root.Finalizers) != 1 || root.Finalizers[0] != rootFinalizer {
+		t.Errorf("got finalizers %v", root.Finalizers)
+	}
+}
+
+func TestSingleCluster(t *testing.T) {
+	f := newFixture(t, readyCluster("east"))
+	f.addDeployments(deployment("web", 3, nil))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.replicas(); len(got) != 1 {
+		t.Errorf("split into %v", got)
+	}
+	if got := f.get("web").Labels[clusterLabel]; got != "east" {
+		t.Errorf("placed on %q", got)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	f := newFixture(t, readyCluster("east"), readyCluster("west"))
+	f.addDeployments(
+		deployment("web", 4, nil),
+		leaf("web", "east", 2),
+		leaf("web", "gone", 2),
+	)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.replicas()
+	if _, found := got["web--gone"]; found {
+		t.Error("kept the leaf of the Cluster that's gone")
+	}
+	if got["web--east"] != 2 || got["web--west"] != 2 {
+		t.Errorf("rebalanced to %v", got)
+	}
+}
+
+func TestNoClusters(t *testing.T) {
+	unready := readyCluster("east")
+	unready.Status.Conditions.SetReady(corev1.ConditionFalse, "Unreachable", "")
+	f := newFixture(t, unready)
+	f.addDeployments(deployment("web", 3, nil))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.replicas(); len(got) != 1 {
+		t.Errorf("split into %v", got)
+	}
+	conditions := f.get("web").Status.Conditions
+	if len(conditions) != 1 || conditions[0].Reason != "NoRegisteredClusters" {
+		t.Errorf("got conditions %v", conditions)
+	}
+}
+
+func TestCreateLeafError(t *testing.T) {
+	f := newFixture(t, readyCluster("east"), readyCluster("west"))
+	f.addDeployments(deployment("web", 3, nil))
+	f.Kube.PrependReactor("create", "deployments", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("kcp is down")
+	})
+	if err := f.process("web"); err == nil {
+		t.Fatal("no error creating the leafs")
+	}
+
+	// The root reports the clusters whose leafs failed.
+	cond := getCondition(f.get("web").Status, appsv1.DeploymentReplicaFailure)
+	if cond == nil || cond.Reason != "LeafsFailed" || !strings.Contains(cond.Message, "clusters east, west") {
+		t.Errorf("got condition %v", cond)
+	}
+}
+
+func TestRolloutClusterByCluster(t *testing.T) {
+	withImage := func(d *appsv1.Deployment, image string, available bool) *appsv1.Deployment {
+		d.Spec.Template.Spec.Containers = []corev1.Container{{Name: "web", Image: image}}
+		if available {
+			d.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+		}
+		return d
+	}
+	image := func(f *fixture, name string) string {
+		return f.get(name).Spec.Template.Spec.Containers[0].Image
+	}
+	root := func() *appsv1.Deployment {
+		d := withImage(deployment("web", 4, nil), "web:v2", false)
+		d.Annotations = map[string]string{placement.RolloutStrategyAnnotation: string(placement.RolloutStrategyClusterByCluster)}
+		return d
+	}
+
+	f := newFixture(t, readyCluster("east"), readyCluster("west"))
+	f.addDeployments(root(), withImage(leaf("web", "east", 2), "web:v1", true), withImage(leaf("web", "west", 2), "web:v1", true))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+	if east, west := image(f, "web--east"), image(f, "web--west"); east != "web:v2" || west != "web:v1" {
+		t.Errorf("expected only east to be rolled out to first, got %s and %s", east, west)
+	}
+
+	// The next Cluster waits for the rollout to the first one.
+	f = newFixture(t, readyCluster("east"), readyCluster("west"))
+	f.addDeployments(root(), withImage(leaf("web", "east", 2), "web:v2", false), withImage(leaf("web", "west", 2), "web:v1", true))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+	if west := image(f, "web--west"); west != "web:v1" {
+		t.Errorf("rolled out to west before east was available")
+	}
+}
+
+func TestRebalanceBudget(t *testing.T) {
+	root := func() *appsv1.Deployment {
+		d := deployment("web", 4, nil)
+		d.Annotations = map[string]string{placement.MaxUnavailableAnnotation: "0"}
+		return d
+	}
+	available := func(d *appsv1.Deployment) *appsv1.Deployment {
+		d.Status.AvailableReplicas = replicasOf(d)
+		return d
+	}
+	f := newFixture(t, readyCluster("east"), readyCluster("west"))
+	f.addDeployments(root(), available(leaf("web", "east", 4)))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.replicas(); got["web--east"] != 4 || got["web--west"] != 2 {
+		t.Errorf("expected east to keep its replicas until those of west are available, got %v", got)
+	}
+
+	// Once they are, east is scaled down.
+	f = newFixture(t, readyCluster("east"), readyCluster("west"))
+	f.addDeployments(root(), available(leaf("web", "east", 4)), available(leaf("web", "west", 2)))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.replicas(); got["web--east"] != 2 || got["web--west"] != 2 {
+		t.Errorf("rebalanced to %v", got)
+	}
+}
+
+func TestRebalanceDisruptionBudget(t *testing.T) {
+	evicted := readyCluster("south")
+	evicted.Status.Conditions.SetReady(corev1.ConditionFalse, "SyncerNotReady", "")
+	evicted.Status.Conditions.Set(v1alpha1.ClusterConditionEvicted, corev1.ConditionTrue, "NotReadyTooLong", "")
+	available := func(d *appsv1.Deployment) *appsv1.Deployment {
+		d.Status.AvailableReplicas = replicasOf(d)
+		return d
+	}
+	newFixtureWithBudget := func() *fixture {
+		f := newFixture(t, readyCluster("east"), readyCluster("west"), evicted)
+		budget := &v1alpha1.ClusterDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ClusterName: workspace},
+			Spec:       v1alpha1.ClusterDisruptionBudgetSpec{MinClusters: 2},
+		}
+		f.Add(f.Informers.Cluster.Cluster().V1alpha1().ClusterDisruptionBudgets().Informer(), budget)
+		return f
+	}
+
+	f := newFixtureWithBudget()
+	f.addDeployments(deployment("web", 4, nil), available(leaf("web", "east", 2)), available(leaf("web", "south", 2)))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.replicas(); got["web--south"] != 2 || got["web--west"] != 2 {
+		t.Errorf("expected south to keep its replicas until those of west are available, got %v", got)
+	}
+
+	// Once they are, the workload is on enough clusters to leave south.
+	f = newFixtureWithBudget()
+	f.addDeployments(deployment("web", 4, nil), available(leaf("web", "east", 2)), available(leaf("web", "west", 2)), available(leaf("web", "south", 2)))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.replicas(); got["web--south"] != 0 {
+		t.Errorf("expected south to be evicted from, got %v", got)
+	}
+}
+
+func TestVolumeClaims(t *testing.T) {
+	withStorage := func(name string, modes ...corev1.PersistentVolumeAccessMode) *v1alpha1.Cluster {
+		cl := readyCluster(name)
+		cl.Status.StorageClasses = []v1alpha1.ClusterStorageClass{{Name: "standard", Default: true, AccessModes: modes}}
+		return cl
+	}
+	newFixtureWithClaim := func(mode corev1.PersistentVolumeAccessMode) *fixture {
+		f := newFixture(t,
+			withStorage("east", corev1.ReadWriteOnce, corev1.ReadWriteMany),
+			withStorage("west", corev1.ReadWriteOnce, corev1.ReadWriteMany),
+			withStorage("edge", corev1.ReadWriteOnce),
+			readyCluster("diskless"))
+		claim := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default", ClusterName: workspace},
+			Spec:       corev1.PersistentVolumeClaimSpec{AccessModes: []corev1.PersistentVolumeAccessMode{mode}},
+		}
+		f.Add(f.Informers.Kube.Core().V1().PersistentVolumeClaims().Informer(), claim)
+		web := deployment("web", 4, nil)
+		web.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+		}}
+		f.addDeployments(web)
+		if err := f.process("web"); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	// A claim every replica writes to spreads them over the Clusters whose
+	// storage allows it.
+	f := newFixtureWithClaim(corev1.ReadWriteMany)
+	if got := f.replicas(); len(got) != 3 || got["web--east"] != 2 || got["web--west"] != 2 {
+		t.Errorf("split into %v", got)
+	}
+
+	// Otherwise they're all placed on a single Cluster with storage.
+	f = newFixtureWithClaim(corev1.ReadWriteOnce)
+	if got := f.replicas(); len(got) != 1 {
+		t.Errorf("split into %v", got)
+	}
+	if got := f.get("web").Labels[clusterLabel]; got == "" || got == "diskless" {
+		t.Errorf("placed on %q", got)
+	}
+}
+
+func TestNetworkPolicies(t *testing.T) {
+	enforcing := readyCluster("east")
+	enforcing.Status.NetworkPolicies = true
+	f := newFixture(t, enforcing, readyCluster("west"))
+	f.c = New(Options{KubeClient: f.Kube, ClusterClient: f.Clusters, Informers: f.Informers, NetworkPolicies: true})
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "default", ClusterName: workspace},
+		Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+	}
+	f.Add(f.Informers.Kube.Networking().V1().NetworkPolicies().Informer(), policy)
+	f.addDeployments(deployment("web", 4, nil))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+	// The root is warned once its leafs are seen, as they're created.
+	list, err := f.Kube.AppsV1().Deployments("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Replace(f.Informers.Kube.Apps().V1().Deployments().Informer(), list)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	cond := getCondition(f.get("web").Status, DeploymentNetworkPolicyUnenforced)
+	if cond == nil || cond.Status != corev1.ConditionTrue {
+		t.Fatalf("got condition %v", cond)
+	}
+	if want := "NetworkPolicies deny-all are not enforced on clusters west, whose network plugins don't support them"; cond.Message != want {
+		t.Errorf("got message %q, want %q", cond.Message, want)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	onPrem := readyCluster("a-on-prem")
+	onPrem.Status.Allocatable = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}
+	f := newFixture(t, onPrem, readyCluster("b-cloud"))
+	web := deployment("web", 5, nil)
+	web.Annotations = map[string]string{placement.SchedulingModeAnnotation: string(placement.SchedulingModeOverflow)}
+	web.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:      "web",
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+	}}
+	f.addDeployments(web)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first Cluster is filled up, and the rest spills over to the next.
+	if got := f.replicas(); got["web--a-on-prem"] != 2 || got["web--b-cloud"] != 3 {
+		t.Errorf("split into %v", got)
+	}
+}
+
+func TestDescheduled(t *testing.T) {
+	f := newFixture(t, readyCluster("hot"), readyCluster("cold"))
+	web := deployment("web", 6, nil)
+	web.Annotations = placement.SetDescheduled(nil, map[string]int32{"hot": 1})
+	f.addDeployments(web)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The replicas the descheduler moved off a Cluster go to the others.
+	if got := f.replicas(); got["web--hot"] != 1 || got["web--cold"] != 5 {
+		t.Errorf("split into %v", got)
+	}
+	if _, found := f.get("web--cold").Annotations[placement.DescheduledAnnotation]; found {
+		t.Error("leaf has the descheduled annotation of its root")
+	}
+}
@@ -0,0 +1,134 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/syncer"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// rootFinalizer holds a root Deployment being deleted until its leafs
+	// are gone from every Cluster.
+	rootFinalizer = "experimental.kcp.dev/deployment-splitter"
+
+	// deletionTimeout is how long a root Deployment is deleted for before
+	// it's reported as stuck on the Clusters that still have its replicas.
+	deletionTimeout = 10 * time.Minute
+)
+
+// addRootFinalizer makes sure a root can't be deleted before its leafs.
+func addRootFinalizer(root *appsv1.Deployment) {
+	for _, f := range root.Finalizers {
+		if f == rootFinalizer {
+			return
+		}
+	}
+	root.Finalizers = append(root.Finalizers, rootFinalizer)
+}
+
+// finalizeRoot deletes the leafs of a root being deleted, and lets the root
+// go once they're gone: the syncer of each Cluster holds its leaf until it's
+// removed from the Cluster, as it holds the root itself if placed as is.
+// With the syncer.ForceDeleteAnnotation, the syncers aren't waited for. A
+// deletion that takes longer than deletionTimeout is reported on the root,
+// with the Clusters it's waiting for.
+func (c *Controller) finalizeRoot(ctx context.Context, root *appsv1.Deployment) error {
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+	forced := syncer.ForceDeleted(root)
+	pending := sets.NewString()
+	for _, leaf := range leafs {
+		if leaf.DeletionTimestamp == nil {
+			if err := c.deleteLeafs(ctx, []*appsv1.Deployment{leaf}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		if forced {
+			if err := c.releaseLeaf(ctx, leaf); err != nil {
+				return err
+			}
+			continue
+		}
+		pending.Insert(leaf.Labels[clusterLabel])
+	}
+	if forced {
+		syncer.RemoveFinalizers(root, "")
+	}
+	pending.Insert(syncer.Finalizing(root)...)
+
+	if len(leafs) == 0 {
+		var kept []string
+		for _, f := range root.Finalizers {
+			if f != rootFinalizer {
+				kept = append(kept, f)
+			}
+		}
+		root.Finalizers = kept
+	}
+	if pending.Len() == 0 {
+		return nil
+	}
+
+	// The leafs going away enqueue the root again; only the timeout needs
+	// to be waited for.
+	if wait := deletionTimeout - time.Since(root.DeletionTimestamp.Time); wait > 0 {
+		c.Queue().AddAfter(logicalcluster.Key(root.ClusterName, root.Namespace, root.Name), wait)
+		return nil
+	}
+	setNotProgressing(root, "DeletionTimedOut", fmt.Sprintf("Still waiting for clusters %s to remove the Deployment; set the %s annotation to \"true\" to stop waiting",
+		strings.Join(pending.List(), ", "), syncer.ForceDeleteAnnotation))
+	return nil
+}
+
+// releaseLeaf removes the syncers' finalizers from a leaf whose deletion is
+// forced, so that it's deleted from kcp without waiting for its Cluster.
+func (c *Controller) releaseLeaf(ctx context.Context, leaf *appsv1.Deployment) error {
+	updated := leaf.DeepCopy()
+	if !syncer.RemoveFinalizers(updated, "") {
+		return nil
+	}
+	if _, err := c.kubeClient.AppsV1().Deployments(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil && !errors.IsNotFound(err) {
+		metrics.SyncErrors.WithLabelValues("deployment", leaf.Labels[clusterLabel]).Inc()
+		return err
+	}
+	logging.FromContext(ctx).Info("Stopped waiting for the cluster to delete child deployment", "child", leaf.Name, logging.ClusterKey, leaf.Labels[clusterLabel])
+	return nil
+}
+
+// deleted enqueues the root of a deleted leaf, which may be waiting for it
+// to go. A deleted root is enqueued itself, for its leafs to be deleted
+// without waiting for the periodic sweep of orphans, as are the other roots
+// of its workspace if it was placed, whose placement may have been kept off
+// the Clusters it was on.
+func (c *Controller) deleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	d, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return
+	}
+	if root := d.Labels[base.LeafLabel]; root != "" {
+		c.Queue().Add(logicalcluster.Key(d.ClusterName, d.Namespace, root))
+		return
+	}
+	c.Enqueue(d)
+	if d.Labels[clusterLabel] != "" || d.Annotations[placement.DecisionAnnotation] != "" {
+		c.enqueueRoots(d)
+	}
+}
@@ -0,0 +1,38 @@
+package deployment
+
+import (
+	"context"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+)
+
+// leafClient is the base.LeafClient orphaned leafs are checked and deleted
+// with.
+type leafClient struct {
+	appsv1client.DeploymentsGetter
+}
+
+func (c leafClient) Get(ctx context.Context, namespace, name string) (metav1.Object, error) {
+	d, err := c.Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (c leafClient) Delete(ctx context.Context, namespace, name string) error {
+	return c.Deployments(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// collectLeafsOf deletes the leafs of the deleted root with the given key.
+// Roots are held by rootFinalizer until their leafs are gone, but not those
+// deleted before they got it, or whose finalizers were removed by hand.
+func (c *Controller) collectLeafsOf(ctx context.Context, key string) error {
+	workspace, namespace, name, err := logicalcluster.SplitKey(key)
+	if err != nil {
+		return err
+	}
+	return c.orphans.CollectLeafsOf(ctx, workspace, namespace, name)
+}
@@ -0,0 +1,320 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// autoscaledAnnotation marks the Deployments whose replicas are left to the
+// HorizontalPodAutoscaler mirrored to their Cluster. The syncer only sets
+// their replicas when it creates them.
+const autoscaledAnnotation = "experimental.kcp.dev/autoscaled"
+
+// HPAMode is how the splitter handles HorizontalPodAutoscalers targeting root
+// Deployments.
+type HPAMode string
+
+const (
+	// HPAModeOff ignores HorizontalPodAutoscalers. This is the default.
+	HPAModeOff HPAMode = "off"
+	// HPAModeRoot takes the replicas a HorizontalPodAutoscaler targeting a
+	// root asks for, within its bounds, as the root's total.
+	HPAModeRoot HPAMode = "root"
+	// HPAModeMirror also mirrors the HorizontalPodAutoscaler to every
+	// Cluster the root was placed on, each with its share of the bounds,
+	// but those with no share of the maximum, and aggregates their status
+	// into the root's.
+	HPAModeMirror HPAMode = "mirror"
+)
+
+// ParseHPAMode parses an HPAMode, defaulting to HPAModeOff if empty.
+func ParseHPAMode(s string) (HPAMode, error) {
+	switch mode := HPAMode(s); mode {
+	case "":
+		return HPAModeOff, nil
+	case HPAModeOff, HPAModeRoot, HPAModeMirror:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown HPA mode %q, must be one of %q, %q or %q", s, HPAModeOff, HPAModeRoot, HPAModeMirror)
+	}
+}
+
+// hpaFor returns the HorizontalPodAutoscaler targeting the given root, if
+// any. HorizontalPodAutoscalers mirrored to Clusters don't count.
+func (c *Controller) hpaFor(root *appsv1.Deployment) (*autoscalingv1.HorizontalPodAutoscaler, error) {
+	hpas, err := c.hpas(root.ClusterName).HorizontalPodAutoscalers(root.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, hpa := range hpas {
+		if hpa.Labels[base.LeafLabel] == "" && targets(hpa, root.Name) {
+			return hpa, nil
+		}
+	}
+	return nil, nil
+}
+
+func targets(hpa *autoscalingv1.HorizontalPodAutoscaler, name string) bool {
+	ref := hpa.Spec.ScaleTargetRef
+	return ref.Kind == "Deployment" && ref.Name == name
+}
+
+// scaleTo sets the root's replicas to what its HorizontalPodAutoscaler asks
+// for, once it asked for anything, kept within the HorizontalPodAutoscaler's
+// bounds.
+func scaleTo(root *appsv1.Deployment, hpa *autoscalingv1.HorizontalPodAutoscaler) {
+	replicas := int32(1)
+	if root.Spec.Replicas != nil {
+		replicas = *root.Spec.Replicas
+	}
+	if hpa.Status.DesiredReplicas > 0 {
+		replicas = hpa.Status.DesiredReplicas
+	}
+	min := int32(1)
+	if hpa.Spec.MinReplicas != nil {
+		min = *hpa.Spec.MinReplicas
+	}
+	if replicas < min {
+		replicas = min
+	}
+	if replicas > hpa.Spec.MaxReplicas {
+		replicas = hpa.Spec.MaxReplicas
+	}
+	root.Spec.Replicas = &replicas
+}
+
+// reconcileHPA mirrors the HorizontalPodAutoscaler targeting the root, if
+// any, to each Cluster the root was placed on, targeting the Deployment
+// synced there, and aggregates their status. Clusters whose share of its
+// maximum is zero get no mirror, and their Deployment is left to the
+// splitter. Mirrored
+// HorizontalPodAutoscalers that aren't needed anymore are deleted, and the
+// Deployments they targeted given back to the splitter.
+func (c *Controller) reconcileHPA(ctx context.Context, root *appsv1.Deployment, hpa *autoscalingv1.HorizontalPodAutoscaler) error {
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+
+	// The Deployment synced to each Cluster: the root itself, or its leafs.
+	deployments := map[string]*appsv1.Deployment{}
+	if cluster := root.Labels[clusterLabel]; cluster != "" {
+		deployments[cluster] = root
+	} else {
+		for _, leaf := range leafs {
+			deployments[leaf.Labels[clusterLabel]] = leaf
+		}
+	}
+
+	// The share of the bounds of each Cluster. Those whose share of the
+	// maximum is zero, with fewer replicas than Clusters, get no mirror: it
+	// would scale to one replica at least, beyond the maximum.
+	var cls []*v1alpha1.Cluster
+	for cluster := range deployments {
+		cl, err := placement.GetCluster(c.clusters(root.ClusterName), cluster)
+		if err != nil {
+			return err
+		}
+		if cl != nil {
+			cls = append(cls, cl)
+		}
+	}
+	var mins, maxes map[string]int32
+	if hpa != nil {
+		min := int32(1)
+		if hpa.Spec.MinReplicas != nil {
+			min = *hpa.Spec.MinReplicas
+		}
+		maxes = placement.DistributeReplicas(hpa.Spec.MaxReplicas, cls)
+		var scaled []*v1alpha1.Cluster
+		for _, cl := range cls {
+			if maxes[cl.Name] > 0 {
+				scaled = append(scaled, cl)
+			}
+		}
+		mins = placement.DistributeReplicas(min, scaled)
+	}
+
+	// HorizontalPodAutoscalers mirrored for this root, by Cluster.
+	targetNames := sets.NewString(root.Name)
+	for _, leaf := range leafs {
+		targetNames.Insert(leaf.Name)
+	}
+	req, err := labels.NewRequirement(base.LeafLabel, selection.Exists, nil)
+	if err != nil {
+		return err
+	}
+	all, err := c.hpas(root.ClusterName).HorizontalPodAutoscalers(root.Namespace).List(labels.NewSelector().Add(*req))
+	if err != nil {
+		return err
+	}
+	mirrored := map[string]*autoscalingv1.HorizontalPodAutoscaler{}
+	for _, m := range all {
+		if !targetNames.Has(m.Spec.ScaleTargetRef.Name) {
+			continue
+		}
+		cluster := m.Labels[clusterLabel]
+		if hpa == nil || m.Labels[base.LeafLabel] != hpa.Name || deployments[cluster] == nil || maxes[cluster] == 0 {
+			if err := c.kubeClient.AutoscalingV1().HorizontalPodAutoscalers(m.Namespace).Delete(ctx, m.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			logging.FromContext(ctx).Info("Deleted child horizontalpodautoscaler", "child", m.Name, logging.ClusterKey, cluster)
+			continue
+		}
+		mirrored[cluster] = m
+	}
+
+	var current []*autoscalingv1.HorizontalPodAutoscaler
+	for cluster, d := range deployments {
+		autoscaled := hpa != nil && maxes[cluster] > 0
+		if err := c.setAutoscaled(ctx, d, autoscaled); err != nil {
+			return err
+		}
+		if !autoscaled {
+			continue
+		}
+		want := newLeafHPA(hpa, cluster, d.Name, mins[cluster], maxes[cluster])
+		m, found := mirrored[cluster]
+		if !found {
+			if _, err := c.kubeClient.AutoscalingV1().HorizontalPodAutoscalers(root.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			logging.FromContext(ctx).Info("Created child horizontalpodautoscaler", "child", want.Name, logging.ClusterKey, cluster, "minReplicas", *want.Spec.MinReplicas, "maxReplicas", want.Spec.MaxReplicas)
+			continue
+		}
+		current = append(current, m)
+		if equality.Semantic.DeepEqual(m.Spec, want.Spec) {
+			continue
+		}
+		updated := m.DeepCopy()
+		updated.Spec = want.Spec
+		if _, err := c.kubeClient.AutoscalingV1().HorizontalPodAutoscalers(m.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("Resized child horizontalpodautoscaler", "child", m.Name, logging.ClusterKey, cluster, "minReplicas", *want.Spec.MinReplicas, "maxReplicas", want.Spec.MaxReplicas)
+	}
+	if hpa == nil {
+		return nil
+	}
+
+	updated := hpa.DeepCopy()
+	aggregateHPAStatus(updated, current)
+	if equality.Semantic.DeepEqual(updated.Status, hpa.Status) {
+		return nil
+	}
+	_, err = c.kubeClient.AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// setAutoscaled marks a Deployment as autoscaled on its Cluster, or not. The
+// root is updated along with the rest of its changes.
+func (c *Controller) setAutoscaled(ctx context.Context, d *appsv1.Deployment, autoscaled bool) error {
+	if _, found := d.Annotations[autoscaledAnnotation]; found == autoscaled {
+		return nil
+	}
+	if d.Labels[base.LeafLabel] == "" {
+		setAutoscaled(d, autoscaled)
+		return nil
+	}
+	updated := d.DeepCopy()
+	setAutoscaled(updated, autoscaled)
+	_, err := c.kubeClient.AppsV1().Deployments(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+func setAutoscaled(d *appsv1.Deployment, autoscaled bool) {
+	if !autoscaled {
+		delete(d.Annotations, autoscaledAnnotation)
+		return
+	}
+	if d.Annotations == nil {
+		d.Annotations = map[string]string{}
+	}
+	d.Annotations[autoscaledAnnotation] = "true"
+}
+
+// newLeafHPA returns a HorizontalPodAutoscaler mirroring the given root's to
+// a Cluster, scaling the Deployment synced there within the given bounds.
+// Every Cluster gets at least one replica, as HorizontalPodAutoscalers can't
+// scale to zero.
+func newLeafHPA(root *autoscalingv1.HorizontalPodAutoscaler, clusterName, target string, min, max int32) *autoscalingv1.HorizontalPodAutoscaler {
+	vh := root.DeepCopy()
+
+	vh.Name = fmt.Sprintf("%s--%s", root.Name, clusterName)
+	vh.ResourceVersion = ""
+	vh.UID = ""
+
+	if vh.Labels == nil {
+		vh.Labels = map[string]string{}
+	}
+	vh.Labels[clusterLabel] = clusterName
+	vh.Labels[base.LeafLabel] = root.Name
+
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	vh.Spec.ScaleTargetRef.Name = target
+	vh.Spec.MinReplicas = &min
+	vh.Spec.MaxReplicas = max
+	vh.Status = autoscalingv1.HorizontalPodAutoscalerStatus{}
+
+	vh.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "autoscaling/v1",
+		Kind:       "HorizontalPodAutoscaler",
+		Name:       root.Name,
+		UID:        root.UID,
+	}}
+	return vh
+}
+
+// aggregateHPAStatus sets the root's status to the combination of the
+// status its mirrors report from their Clusters: replicas are summed, CPU
+// utilization is averaged across all replicas, and the latest scale is
+// reported.
+func aggregateHPAStatus(root *autoscalingv1.HorizontalPodAutoscaler, leafs []*autoscalingv1.HorizontalPodAutoscaler) {
+	status := autoscalingv1.HorizontalPodAutoscalerStatus{
+		ObservedGeneration: root.Status.ObservedGeneration,
+	}
+	var utilization, measured int64
+	for _, leaf := range leafs {
+		status.CurrentReplicas += leaf.Status.CurrentReplicas
+		status.DesiredReplicas += leaf.Status.DesiredReplicas
+		if t := leaf.Status.LastScaleTime; t != nil && (status.LastScaleTime == nil || status.LastScaleTime.Before(t)) {
+			status.LastScaleTime = t.DeepCopy()
+		}
+		if u := leaf.Status.CurrentCPUUtilizationPercentage; u != nil {
+			utilization += int64(*u) * int64(leaf.Status.CurrentReplicas)
+			measured += int64(leaf.Status.CurrentReplicas)
+		}
+	}
+	if measured > 0 {
+		average := int32(utilization / measured)
+		status.CurrentCPUUtilizationPercentage = &average
+	}
+	root.Status = status
+}
+
+// recordScaled records an Event on a root whose replicas its
+// HorizontalPodAutoscaler just changed.
+func (c *Controller) recordScaled(root *appsv1.Deployment, hpa *autoscalingv1.HorizontalPodAutoscaler, from int32) {
+	if root.Spec.Replicas != nil && *root.Spec.Replicas != from {
+		c.Recorder().Eventf(root, corev1.EventTypeNormal, "Autoscaled", "Scaled from %d to %d replicas for HorizontalPodAutoscaler %s", from, *root.Spec.Replicas, hpa.Name)
+	}
+}
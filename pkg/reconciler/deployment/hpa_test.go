@@ -0,0 +1,245 @@
+package deployment
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newHPAFixture(t *testing.T, mode HPAMode, hpa *autoscalingv1.HorizontalPodAutoscaler, clusters ...*v1alpha1.Cluster) *fixture {
+	f := newFixture(t, clusters...)
+	f.c = New(Options{KubeClient: f.Kube, ClusterClient: f.Clusters, Informers: f.Informers, HPAMode: mode})
+	if hpa != nil {
+		if _, err := f.Kube.AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace).Create(context.Background(), hpa, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		f.Add(f.Informers.Kube.Autoscaling().V1().HorizontalPodAutoscalers().Informer(), hpa)
+	}
+	return f
+}
+
+// processAll processes the root until its leafs and mirrors are in the
+// cache of the Controller, as it would once they're created.
+func (f *fixture) processAll(name string) {
+	for i := 0; i < 2; i++ {
+		if err := f.process(name); err != nil {
+			f.T.Fatal(err)
+		}
+		deployments, err := f.Kube.AppsV1().Deployments("default").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			f.T.Fatal(err)
+		}
+		f.Replace(f.Informers.Kube.Apps().V1().Deployments().Informer(), deployments)
+		hpas, err := f.Kube.AutoscalingV1().HorizontalPodAutoscalers("default").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			f.T.Fatal(err)
+		}
+		f.Replace(f.Informers.Kube.Autoscaling().V1().HorizontalPodAutoscalers().Informer(), hpas)
+	}
+}
+
+// mirrors returns the bounds of the HorizontalPodAutoscalers mirrored to
+// each Cluster, and the Deployments they scale.
+func (f *fixture) mirrors() map[string]bounds {
+	list, err := f.Kube.AutoscalingV1().HorizontalPodAutoscalers("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	mirrors := map[string]bounds{}
+	for _, hpa := range list.Items {
+		if hpa.Labels[base.LeafLabel] == "" {
+			continue
+		}
+		mirrors[hpa.Labels[clusterLabel]] = bounds{*hpa.Spec.MinReplicas, hpa.Spec.MaxReplicas, hpa.Spec.ScaleTargetRef.Name}
+	}
+	return mirrors
+}
+
+type bounds struct {
+	min, max int32
+	target   string
+}
+
+func hpa(min, max, desired int32) *autoscalingv1.HorizontalPodAutoscaler {
+	return &autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ClusterName: workspace, UID: "hpa-uid"},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MinReplicas:    &min,
+			MaxReplicas:    max,
+		},
+		Status: autoscalingv1.HorizontalPodAutoscalerStatus{DesiredReplicas: desired},
+	}
+}
+
+func TestParseHPAMode(t *testing.T) {
+	for s, want := range map[string]HPAMode{"": HPAModeOff, "off": HPAModeOff, "root": HPAModeRoot, "mirror": HPAModeMirror} {
+		if got, err := ParseHPAMode(s); err != nil || got != want {
+			t.Errorf("%q: got %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseHPAMode("leaf"); err == nil {
+		t.Error("parsed leaf")
+	}
+}
+
+func TestScaleTo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		replicas int32
+		hpa      *autoscalingv1.HorizontalPodAutoscaler
+		want     int32
+	}{
+		{"nothing asked for yet", 3, hpa(1, 10, 0), 3},
+		{"asked for", 3, hpa(1, 10, 6), 6},
+		{"below the minimum", 1, hpa(2, 10, 0), 2},
+		{"above the maximum", 3, hpa(1, 10, 12), 10},
+		{"no minimum", 0, &autoscalingv1.HorizontalPodAutoscaler{Spec: autoscalingv1.HorizontalPodAutoscalerSpec{MaxReplicas: 5}}, 1},
+	} {
+		root := deployment("web", tc.replicas, nil)
+		scaleTo(root, tc.hpa)
+		if got := *root.Spec.Replicas; got != tc.want {
+			t.Errorf("%s: scaled to %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHPARoot(t *testing.T) {
+	f := newHPAFixture(t, HPAModeRoot, hpa(1, 10, 6), readyCluster("east"), readyCluster("west"))
+	f.addDeployments(deployment("web", 2, nil))
+	f.processAll("web")
+
+	if got := f.replicas(); got["web--east"] != 3 || got["web--west"] != 3 {
+		t.Errorf("split into %v", got)
+	}
+	if got := f.mirrors(); len(got) > 0 {
+		t.Errorf("mirrored to %v", got)
+	}
+}
+
+func TestHPAMirror(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		clusters []*v1alpha1.Cluster
+		replicas int32
+		hpa      *autoscalingv1.HorizontalPodAutoscaler
+		// want are the mirrors by Cluster; the Deployments they scale are
+		// autoscaled, and the others aren't.
+		want map[string]bounds
+	}{{
+		name:     "split bounds",
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("west")},
+		replicas: 4,
+		hpa:      hpa(2, 7, 0),
+		want:     map[string]bounds{"east": {1, 4, "web--east"}, "west": {1, 3, "web--west"}},
+	}, {
+		name:     "minimum below the clusters",
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("west")},
+		replicas: 4,
+		hpa:      hpa(1, 6, 0),
+		want:     map[string]bounds{"east": {1, 3, "web--east"}, "west": {1, 3, "web--west"}},
+	}, {
+		name:     "fewer replicas than clusters",
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("north"), readyCluster("west")},
+		replicas: 1,
+		hpa:      hpa(1, 2, 0),
+		want:     map[string]bounds{"east": {1, 1, "web--east"}, "north": {1, 1, "web--north"}},
+	}, {
+		name:     "single cluster",
+		clusters: []*v1alpha1.Cluster{readyCluster("east")},
+		replicas: 2,
+		hpa:      hpa(1, 5, 0),
+		want:     map[string]bounds{"east": {1, 5, "web"}},
+	}, {
+		name:     "no HorizontalPodAutoscaler",
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("west")},
+		replicas: 2,
+		want:     map[string]bounds{},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newHPAFixture(t, HPAModeMirror, tc.hpa, tc.clusters...)
+			f.addDeployments(deployment("web", tc.replicas, nil))
+			f.processAll("web")
+
+			got := f.mirrors()
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got mirrors %v, want %v", got, tc.want)
+			}
+			var max int32
+			for _, b := range got {
+				max += b.max
+			}
+			if tc.hpa != nil && max > tc.hpa.Spec.MaxReplicas {
+				t.Errorf("the mirrors scale up to %d replicas, beyond %d", max, tc.hpa.Spec.MaxReplicas)
+			}
+			list, err := f.Kube.AppsV1().Deployments("default").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range list.Items {
+				cluster := d.Labels[clusterLabel]
+				if cluster == "" {
+					continue
+				}
+				_, mirrored := tc.want[cluster]
+				if _, autoscaled := d.Annotations[autoscaledAnnotation]; autoscaled != mirrored {
+					t.Errorf("%s: autoscaled %v, want %v", d.Name, autoscaled, mirrored)
+				}
+			}
+		})
+	}
+}
+
+func TestHPAMirrorRemoved(t *testing.T) {
+	f := newHPAFixture(t, HPAModeMirror, hpa(2, 6, 0), readyCluster("east"), readyCluster("west"))
+	f.addDeployments(deployment("web", 4, nil))
+	f.processAll("web")
+	if got := f.mirrors(); len(got) != 2 {
+		t.Fatalf("got mirrors %v", got)
+	}
+
+	if err := f.Kube.AutoscalingV1().HorizontalPodAutoscalers("default").Delete(context.Background(), "web", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.processAll("web")
+	if got := f.mirrors(); len(got) > 0 {
+		t.Errorf("kept mirrors %v", got)
+	}
+	for _, name := range []string{"web--east", "web--west"} {
+		if _, found := f.get(name).Annotations[autoscaledAnnotation]; found {
+			t.Errorf("%s is still autoscaled", name)
+		}
+	}
+}
+
+func TestAggregateHPAStatus(t *testing.T) {
+	utilization := func(u int32) *int32 { return &u }
+	earlier, later := metav1.NewTime(metav1.Now().Add(-time.Minute)), metav1.Now()
+	root := hpa(2, 6, 0)
+	root.Status.ObservedGeneration = new(int64)
+	aggregateHPAStatus(root, []*autoscalingv1.HorizontalPodAutoscaler{
+		{Status: autoscalingv1.HorizontalPodAutoscalerStatus{CurrentReplicas: 1, DesiredReplicas: 2, CurrentCPUUtilizationPercentage: utilization(90), LastScaleTime: &earlier}},
+		{Status: autoscalingv1.HorizontalPodAutoscalerStatus{CurrentReplicas: 3, DesiredReplicas: 3, CurrentCPUUtilizationPercentage: utilization(50), LastScaleTime: &later}},
+		{Status: autoscalingv1.HorizontalPodAutoscalerStatus{CurrentReplicas: 2, DesiredReplicas: 2}},
+	})
+
+	if root.Status.CurrentReplicas != 6 || root.Status.DesiredReplicas != 7 {
+		t.Errorf("got %d current and %d desired replicas", root.Status.CurrentReplicas, root.Status.DesiredReplicas)
+	}
+	// Averaged across the replicas measured.
+	if u := root.Status.CurrentCPUUtilizationPercentage; u == nil || *u != 60 {
+		t.Errorf("got utilization %v", u)
+	}
+	if !root.Status.LastScaleTime.Equal(&later) {
+		t.Errorf("got last scale time %v", root.Status.LastScaleTime)
+	}
+	if root.Status.ObservedGeneration == nil {
+		t.Error("lost the observed generation of the root")
+	}
+}
@@ -0,0 +1,102 @@
+package deployment
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// DeploymentNetworkPolicyUnenforced is the condition of a root split across
+// Clusters warning that NetworkPolicies selecting its pods aren't enforced
+// on some of them, whose network plugins don't support them.
+const DeploymentNetworkPolicyUnenforced appsv1.DeploymentConditionType = "NetworkPolicyUnenforced"
+
+// unenforcedPolicies returns a message naming the NetworkPolicies of the
+// root's namespace that select its pods and the given Clusters that don't
+// enforce them, if any.
+func (c *Controller) unenforcedPolicies(root *appsv1.Deployment, clusterNames sets.String) (string, error) {
+	if c.networkPolicyIndexer == nil {
+		return "", nil
+	}
+	list, err := c.networkPolicies(root.ClusterName).NetworkPolicies(root.Namespace).List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	policies := sets.NewString()
+	for _, p := range list {
+		selector, err := metav1.LabelSelectorAsSelector(&p.Spec.PodSelector)
+		if err == nil && selector.Matches(labels.Set(root.Spec.Template.Labels)) {
+			policies.Insert(p.Name)
+		}
+	}
+	if policies.Len() == 0 {
+		return "", nil
+	}
+
+	var unenforced []string
+	for _, name := range clusterNames.List() {
+		cl, err := placement.GetCluster(c.clusters(root.ClusterName), name)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if !cl.Status.NetworkPolicies {
+			unenforced = append(unenforced, name)
+		}
+	}
+	if len(unenforced) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("NetworkPolicies %s are not enforced on clusters %s, whose network plugins don't support them",
+		strings.Join(policies.List(), ", "), strings.Join(unenforced, ", ")), nil
+}
+
+// setPoliciesUnenforced reports the NetworkPolicies of a root split across
+// the given Clusters that aren't enforced on all of them in its
+// DeploymentNetworkPolicyUnenforced condition, given the one it had before
+// its status was aggregated, and leaves it out if there are none.
+func (c *Controller) setPoliciesUnenforced(root *appsv1.Deployment, clusterNames sets.String, prev *appsv1.DeploymentCondition) error {
+	msg, err := c.unenforcedPolicies(root, clusterNames)
+	if err != nil || msg == "" {
+		return err
+	}
+	now := metav1.Now()
+	cond := appsv1.DeploymentCondition{
+		Type:               DeploymentNetworkPolicyUnenforced,
+		Status:             corev1.ConditionTrue,
+		Reason:             "ClustersNotEnforcing",
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	}
+	if prev != nil && prev.Status == cond.Status {
+		cond.LastTransitionTime = prev.LastTransitionTime
+		if prev.Message == cond.Message {
+			// Not to update the root's status every time it's reconciled.
+			cond.LastUpdateTime = prev.LastUpdateTime
+		}
+	}
+	root.Status.Conditions = append(root.Status.Conditions, cond)
+	return nil
+}
+
+// recordUnenforced records an Event on a root labeled for a single Cluster
+// if it doesn't enforce the NetworkPolicies selecting its pods. Its status
+// is that of the Cluster, written back by the syncer.
+func (c *Controller) recordUnenforced(root *appsv1.Deployment, clusterName string) error {
+	msg, err := c.unenforcedPolicies(root, sets.NewString(clusterName))
+	if err != nil || msg == "" {
+		return err
+	}
+	c.Recorder().Event(root, corev1.EventTypeWarning, string(DeploymentNetworkPolicyUnenforced), msg)
+	return nil
+}
@@ -0,0 +1,111 @@
+package deployment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// conditionTypes are the Deployment conditions merged from leafs into the root,
+// in the order they're reported.
+var conditionTypes = []appsv1.DeploymentConditionType{
+	appsv1.DeploymentAvailable,
+	appsv1.DeploymentProgressing,
+	appsv1.DeploymentReplicaFailure,
+}
+
+// aggregateStatus sets the root's .status to the combination of its leafs'
+// statuses: replica counts are summed and conditions are merged so that any
+// unhealthy leaf makes the root unhealthy. Its observedGeneration is that of
+// the root, whose leafs were just placed from its current spec.
+func aggregateStatus(root *appsv1.Deployment, leafs []*appsv1.Deployment) {
+	sorted := make([]*appsv1.Deployment, len(leafs))
+	copy(sorted, leafs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Labels[clusterLabel] < sorted[j].Labels[clusterLabel] })
+
+	status := appsv1.DeploymentStatus{
+		ObservedGeneration: root.Generation,
+		CollisionCount:     root.Status.CollisionCount,
+	}
+	for _, leaf := range sorted {
+		status.Replicas += leaf.Status.Replicas
+		status.UpdatedReplicas += leaf.Status.UpdatedReplicas
+		status.ReadyReplicas += leaf.Status.ReadyReplicas
+		status.AvailableReplicas += leaf.Status.AvailableReplicas
+		status.UnavailableReplicas += leaf.Status.UnavailableReplicas
+	}
+
+	for _, t := range conditionTypes {
+		if cond := mergeCondition(t, sorted); cond != nil {
+			if prev := getCondition(root.Status, t); prev != nil && prev.Status == cond.Status {
+				// Only move the transition time when the merged status actually flips.
+				cond.LastTransitionTime = prev.LastTransitionTime
+			}
+			status.Conditions = append(status.Conditions, *cond)
+		}
+	}
+
+	root.Status = status
+}
+
+// mergeCondition merges the condition of the given type across leafs. If any
+// leaf reports the unhealthy status for it, so does the result, with a message
+// naming the offending clusters; the healthy status is reported only if every
+// leaf reports it. nil is returned if no leaf has the condition.
+func mergeCondition(t appsv1.DeploymentConditionType, leafs []*appsv1.Deployment) *appsv1.DeploymentCondition {
+	healthy := corev1.ConditionTrue
+	if t == appsv1.DeploymentReplicaFailure {
+		healthy = corev1.ConditionFalse
+	}
+
+	var merged *appsv1.DeploymentCondition
+	var unhealthy []string
+	seen := 0
+	for _, leaf := range leafs {
+		cond := getCondition(leaf.Status, t)
+		if cond == nil {
+			continue
+		}
+		seen++
+		if merged == nil {
+			merged = cond.DeepCopy()
+		}
+		if cond.LastUpdateTime.After(merged.LastUpdateTime.Time) {
+			merged.LastUpdateTime = cond.LastUpdateTime
+		}
+		if cond.Status != healthy {
+			if len(unhealthy) == 0 {
+				merged.Status = cond.Status
+				merged.Reason = cond.Reason
+			}
+			unhealthy = append(unhealthy, fmt.Sprintf("%s: %s", leaf.Labels[clusterLabel], cond.Message))
+		}
+	}
+	if merged == nil {
+		return nil
+	}
+
+	switch {
+	case len(unhealthy) > 0:
+		merged.Message = strings.Join(unhealthy, "; ")
+	case seen < len(leafs):
+		merged.Status = corev1.ConditionUnknown
+		merged.Reason = "ClustersNotReporting"
+		merged.Message = fmt.Sprintf("%d of %d clusters have not reported this condition yet", len(leafs)-seen, len(leafs))
+	default:
+		merged.Message = fmt.Sprintf("%s on all %d clusters", merged.Reason, len(leafs))
+	}
+	return merged
+}
+
+func getCondition(status appsv1.DeploymentStatus, t appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == t {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+// Package descheduler moves replicas of split Deployments off the Clusters
+// whose load, by the resources their pods request, stays high while other
+// Clusters of their workspace are lightly loaded, much like the Kubernetes
+// descheduler evicts pods from overutilized nodes. It caps the replicas the
+// roots may keep on the hot Clusters, in their DescheduledAnnotation, for
+// the deployment controller to place the others elsewhere within their
+// PlacementPolicy and disruption budgets, and lifts the caps once the load of
+// a Cluster has stayed low for a while.
+package descheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/kubernetes"
+	appsv1lister "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resyncPeriod = 10 * time.Hour
+	clusterLabel = "cluster"
+)
+
+// Options configure the descheduler.
+type Options struct {
+	// Interval is how often the load of every Cluster is checked.
+	Interval time.Duration
+	// HighUtilization is the Utilization above which a Cluster is
+	// overloaded, and replicas are moved off it.
+	HighUtilization float64
+	// LowUtilization is the Utilization below which a Cluster is lightly
+	// loaded, and replicas are moved to it. Clusters below it for
+	// ReleaseAfter checks in a row are no longer capped.
+	LowUtilization float64
+	// MaxReplicas is the most replicas moved off a Cluster every Interval.
+	MaxReplicas int32
+	// ReleaseAfter is how many checks in a row a Cluster must be below
+	// LowUtilization before its caps are lifted, lest the replicas let back
+	// overload it again right away.
+	ReleaseAfter int32
+	// DryRun only reports, in Events on the roots, the replicas that would be
+	// moved.
+	DryRun bool
+}
+
+// DefaultOptions are the Options the descheduler runs with unless set
+// otherwise.
+func DefaultOptions() Options {
+	return Options{
+		Interval:        5 * time.Minute,
+		HighUtilization: 0.8,
+		LowUtilization:  0.5,
+		MaxReplicas:     2,
+		ReleaseAfter:    3,
+	}
+}
+
+// NewController returns a new Controller which checks the load of every
+// Cluster each opts.Interval and moves up to opts.MaxReplicas of the split
+// Deployments on those above opts.HighUtilization to the Clusters below
+// opts.LowUtilization.
+func NewController(cfg *rest.Config, opts Options, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), opts, shared)
+	if own {
+		stopCh := c.StopCh()
+		shared.Cluster.Start(stopCh)
+		shared.Kube.Start(stopCh)
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, opts Options, shared *base.Informers) *Controller {
+	csif, sif := shared.Cluster, shared.Kube
+
+	c := &Controller{
+		opts:              opts,
+		kubeClient:        kubeClient,
+		indexer:           logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Clusters().Informer()),
+		policyIndexer:     logicalcluster.IndexerFor(csif.Cluster().V1alpha1().PlacementPolicies().Informer()),
+		deploymentIndexer: logicalcluster.IndexerFor(sif.Apps().V1().Deployments().Informer()),
+		lowChecks:         map[string]int32{},
+	}
+	c.Controller = base.New("descheduler", kubeClient, nil, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		csif.Cluster().V1alpha1().Clusters().Informer().HasSynced,
+		csif.Cluster().V1alpha1().PlacementPolicies().Informer().HasSynced,
+		sif.Apps().V1().Deployments().Informer().HasSynced,
+	)
+	// Clusters are only checked every Interval, not as their status changes,
+	// so that no more than MaxReplicas are moved off one in that time.
+	c.AddPeriodic(c.enqueueClusters, opts.Interval)
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	opts              Options
+	kubeClient        kubernetes.Interface
+	indexer           cache.Indexer
+	policyIndexer     cache.Indexer
+	deploymentIndexer cache.Indexer
+
+	// lowChecks counts the checks in a row each Cluster was below the
+	// LowUtilization, by key.
+	lowLock   sync.Mutex
+	lowChecks map[string]int32
+}
+
+// The listers of the objects of a workspace, whose workloads are only
+// placed on its own Clusters.
+
+func (c *Controller) clusters(workspace string) clusterlisters.ClusterLister {
+	return clusterlisters.NewClusterLister(logicalcluster.Scoped(c.indexer, workspace))
+}
+
+func (c *Controller) policies(workspace string) clusterlisters.PlacementPolicyLister {
+	return clusterlisters.NewPlacementPolicyLister(logicalcluster.Scoped(c.policyIndexer, workspace))
+}
+
+func (c *Controller) deployments(workspace string) appsv1lister.DeploymentLister {
+	return appsv1lister.NewDeploymentLister(logicalcluster.Scoped(c.deploymentIndexer, workspace))
+}
+
+// enqueueClusters enqueues every Cluster, for its load to be checked, with
+// low priority as it's background work.
+func (c *Controller) enqueueClusters(context.Context) {
+	for _, obj := range c.indexer.List() {
+		c.EnqueueLow(obj)
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		c.countLow(key, false)
+		return nil
+	}
+	cluster := obj.(*v1alpha1.Cluster)
+
+	ctx, _ = logging.WithValues(ctx, logging.ClusterKey, cluster.Name)
+	return c.reconcile(ctx, key, cluster)
+}
+
+func (c *Controller) reconcile(ctx context.Context, key string, cluster *v1alpha1.Cluster) error {
+	logger := logging.FromContext(ctx)
+
+	utilization, known := placement.Utilization(cluster)
+	low := known && utilization < c.opts.LowUtilization
+	checks := c.countLow(key, low)
+	switch {
+	case !known:
+		return nil
+	case low:
+		if checks < c.opts.ReleaseAfter {
+			logger.V(2).Info("Cluster is lightly loaded, but not for long enough to lift its caps", "utilization", utilization, "checks", checks)
+			return nil
+		}
+		return c.release(ctx, cluster)
+	case utilization <= c.opts.HighUtilization || !cluster.Status.Conditions.IsReady():
+		// NotReady Clusters are left to the eviction controller.
+		return nil
+	}
+
+	cold, err := c.coldClusters(cluster.GetClusterName())
+	if err != nil {
+		return err
+	}
+	if len(cold) == 0 {
+		logger.V(2).Info("Cluster is overloaded but no other cluster is lightly loaded", "utilization", utilization)
+		return nil
+	}
+
+	leafs, err := c.leafsOn(cluster)
+	if err != nil {
+		return err
+	}
+	remaining := c.opts.MaxReplicas
+	for _, leaf := range leafs {
+		if remaining <= 0 {
+			break
+		}
+		moved, err := c.deschedule(ctx, cluster, leaf, cold, remaining, utilization)
+		if err != nil {
+			return err
+		}
+		remaining -= moved
+	}
+	return nil
+}
+
+// countLow counts a check of the Cluster with the given key, and returns
+// how many in a row it was below the LowUtilization, starting over if it
+// isn't.
+func (c *Controller) countLow(key string, low bool) int32 {
+	c.lowLock.Lock()
+	defer c.lowLock.Unlock()
+	if !low {
+		delete(c.lowChecks, key)
+		return 0
+	}
+	c.lowChecks[key]++
+	return c.lowChecks[key]
+}
+
+// coldClusters returns the Ready Clusters of the workspace below the
+// LowUtilization, sorted by name.
+func (c *Controller) coldClusters(workspace string) ([]*v1alpha1.Cluster, error) {
+	ready, err := placement.ReadyClusters(c.clusters(workspace))
+	if err != nil {
+		return nil, err
+	}
+	var cold []*v1alpha1.Cluster
+	for _, cl := range ready {
+		if u, known := placement.Utilization(cl); known && u < c.opts.LowUtilization {
+			cold = append(cold, cl)
+		}
+	}
+	return cold, nil
+}
+
+// leafsOn returns the leafs split onto the Cluster, the largest first, so
+// that the replicas moved come from the fewest roots.
+func (c *Controller) leafsOn(cluster *v1alpha1.Cluster) ([]*appsv1.Deployment, error) {
+	owned, err := labels.NewRequirement(base.LeafLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	sel := labels.SelectorFromSet(labels.Set{clusterLabel: cluster.Name}).Add(*owned)
+	leafs, err := c.deployments(cluster.GetClusterName()).List(sel)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(leafs, func(i, j int) bool {
+		if ri, rj := replicasOf(leafs[i]), replicasOf(leafs[j]); ri != rj {
+			return ri > rj
+		}
+		return leafs[i].Namespace+"/"+leafs[i].Name < leafs[j].Namespace+"/"+leafs[j].Name
+	})
+	return leafs, nil
+}
+
+// deschedule caps the replicas the root of leaf may keep on the overloaded
+// Cluster to up to limit fewer than it has there, if its PlacementPolicy
+// allows it on any of the cold Clusters, and returns how many it moves.
+func (c *Controller) deschedule(ctx context.Context, cluster *v1alpha1.Cluster, leaf *appsv1.Deployment, cold []*v1alpha1.Cluster, limit int32, utilization float64) (int32, error) {
+	workspace := cluster.GetClusterName()
+	root, err := c.deployments(workspace).Deployments(leaf.Namespace).Get(leaf.Labels[base.LeafLabel])
+	if errors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// Only split roots have their replicas placed within limits.
+	if mode, err := placement.SchedulingModeFor(root.Annotations); err != nil ||
+		(mode != placement.SchedulingModeSplit && mode != placement.SchedulingModeOverflow) {
+		return 0, nil
+	}
+	caps, err := placement.DescheduledFor(root.Annotations)
+	if err != nil {
+		// The deployment controller reports it, so it's left alone.
+		return 0, nil
+	}
+	tolerations, err := placement.TolerationsFor(root.Annotations)
+	if err != nil {
+		return 0, nil
+	}
+
+	policy, err := placement.PolicyFor(c.policies(workspace), root.Namespace, root.Labels)
+	if err != nil {
+		return 0, err
+	}
+	targets, _, err := placement.Place(policy, placement.Untainted(cold, tolerations), nil)
+	if err != nil || len(targets) == 0 {
+		return 0, nil
+	}
+
+	allowed := replicasOf(leaf)
+	if n, found := caps[cluster.Name]; found && n < allowed {
+		// The replicas capped before aren't all moved yet.
+		return 0, nil
+	}
+	moved := limit
+	if moved > allowed {
+		moved = allowed
+	}
+	if moved == 0 {
+		return 0, nil
+	}
+	names := make([]string, 0, len(targets))
+	for _, cl := range targets {
+		names = append(names, cl.Name)
+	}
+	msg := fmt.Sprintf("%d replicas off cluster %s, whose utilization of %.0f%% is above %.0f%%, as clusters %s are lightly loaded",
+		moved, cluster.Name, 100*utilization, 100*c.opts.HighUtilization, strings.Join(names, ", "))
+	if c.opts.DryRun {
+		logging.FromContext(ctx).Info("Would deschedule replicas", "root", root.Name, "replicas", moved)
+		c.Recorder().Event(root, corev1.EventTypeNormal, "DescheduleDryRun", "Would move "+msg)
+		return moved, nil
+	}
+
+	if caps == nil {
+		caps = map[string]int32{}
+	}
+	caps[cluster.Name] = allowed - moved
+	updated := root.DeepCopy()
+	updated.Annotations = placement.SetDescheduled(updated.Annotations, caps)
+	if _, err := c.kubeClient.AppsV1().Deployments(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return 0, err
+	}
+	logging.FromContext(ctx).Info("Descheduled replicas", "root", root.Name, "replicas", moved)
+	c.Recorder().Event(root, corev1.EventTypeNormal, "Descheduled", "Moving "+msg)
+	return moved, nil
+}
+
+// release lifts the caps of the roots of the workspace on the Cluster, whose
+// load has been low for ReleaseAfter checks. In dry runs, it only reports
+// them.
+func (c *Controller) release(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	roots, err := c.deployments(cluster.GetClusterName()).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		caps, err := placement.DescheduledFor(root.Annotations)
+		if err != nil {
+			continue
+		}
+		if _, found := caps[cluster.Name]; !found {
+			continue
+		}
+		if c.opts.DryRun {
+			logging.FromContext(ctx).Info("Would lift the descheduling cap", "root", root.Name)
+			continue
+		}
+		delete(caps, cluster.Name)
+		updated := root.DeepCopy()
+		updated.Annotations = placement.SetDescheduled(updated.Annotations, caps)
+		if _, err := c.kubeClient.AppsV1().Deployments(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("Lifted the descheduling cap", "root", root.Name)
+	}
+	return nil
+}
+
+// replicasOf returns the replicas of the Deployment, 1 if unset.
+func replicasOf(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
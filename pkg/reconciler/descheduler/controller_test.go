@@ -0,0 +1,163 @@
+package descheduler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterfake "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/fake"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+const workspace = "admin"
+
+func cluster(name string, utilization float64) *v1alpha1.Cluster {
+	cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: workspace}}
+	cl.Status.Conditions.SetReady(corev1.ConditionTrue, "SyncerReady", "")
+	cl.Status.Allocatable = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10")}
+	cl.Status.Requested = corev1.ResourceList{corev1.ResourceCPU: *resource.NewMilliQuantity(int64(utilization*10000), resource.DecimalSI)}
+	return cl
+}
+
+func root(name string, caps map[string]int32) *appsv1.Deployment {
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ClusterName: workspace}}
+	d.Annotations = placement.SetDescheduled(nil, caps)
+	return d
+}
+
+func leaf(root, clusterName string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        root + "--" + clusterName,
+			Namespace:   "default",
+			ClusterName: workspace,
+			Labels:      map[string]string{base.LeafLabel: root, clusterLabel: clusterName},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	leafs := []*appsv1.Deployment{leaf("web", "hot", 5), leaf("api", "hot", 3), leaf("web", "cold", 1)}
+	opts := func(modify func(*Options)) Options {
+		o := DefaultOptions()
+		modify(&o)
+		return o
+	}
+
+	for _, tc := range []struct {
+		desc string
+		opts Options
+		// hot is the utilization of the hot Cluster at each check, the
+		// cold one's staying at 20%.
+		hot      []float64
+		roots    []*appsv1.Deployment
+		wantCaps map[string]map[string]int32
+	}{{
+		desc:     "moves up to MaxReplicas, from the largest leaf",
+		opts:     DefaultOptions(),
+		hot:      []float64{0.9},
+		roots:    []*appsv1.Deployment{root("web", nil), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"hot": 3}, "api": nil},
+	}, {
+		desc:     "moves the rest from the next leafs",
+		opts:     opts(func(o *Options) { o.MaxReplicas = 6 }),
+		hot:      []float64{0.9},
+		roots:    []*appsv1.Deployment{root("web", nil), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"hot": 0}, "api": {"hot": 2}},
+	}, {
+		desc:     "waits for the replicas capped to be moved",
+		opts:     DefaultOptions(),
+		hot:      []float64{0.9},
+		roots:    []*appsv1.Deployment{root("web", map[string]int32{"hot": 3}), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"hot": 3}, "api": {"hot": 1}},
+	}, {
+		desc:     "dry run",
+		opts:     opts(func(o *Options) { o.DryRun = true }),
+		hot:      []float64{0.9},
+		roots:    []*appsv1.Deployment{root("web", nil), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": nil, "api": nil},
+	}, {
+		desc:     "neither overloaded nor lightly loaded",
+		opts:     DefaultOptions(),
+		hot:      []float64{0.7},
+		roots:    []*appsv1.Deployment{root("web", map[string]int32{"hot": 3}), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"hot": 3}, "api": nil},
+	}, {
+		desc:     "not lightly loaded for long enough to release",
+		opts:     DefaultOptions(),
+		hot:      []float64{0.3, 0.3},
+		roots:    []*appsv1.Deployment{root("web", map[string]int32{"hot": 3, "cold": 1}), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"hot": 3, "cold": 1}, "api": nil},
+	}, {
+		desc:     "releases after ReleaseAfter checks in a row",
+		opts:     DefaultOptions(),
+		hot:      []float64{0.3, 0.3, 0.3},
+		roots:    []*appsv1.Deployment{root("web", map[string]int32{"hot": 3, "cold": 1}), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"cold": 1}, "api": nil},
+	}, {
+		desc:     "counts the checks again after one that isn't lightly loaded",
+		opts:     DefaultOptions(),
+		hot:      []float64{0.3, 0.3, 0.6, 0.3, 0.3},
+		roots:    []*appsv1.Deployment{root("web", map[string]int32{"hot": 3}), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"hot": 3}, "api": nil},
+	}, {
+		desc:     "dry run doesn't release",
+		opts:     opts(func(o *Options) { o.DryRun = true; o.ReleaseAfter = 1 }),
+		hot:      []float64{0.3},
+		roots:    []*appsv1.Deployment{root("web", map[string]int32{"hot": 3}), root("api", nil)},
+		wantCaps: map[string]map[string]int32{"web": {"hot": 3}, "api": nil},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			kube := kubefake.NewSimpleClientset()
+			informers := base.NewInformersFor(kube, clusterfake.NewSimpleClientset(), 0)
+			c := newController(kube, tc.opts, informers)
+			clusters := informers.Cluster.Cluster().V1alpha1().Clusters().Informer().GetIndexer()
+			if err := clusters.Add(cluster("cold", 0.2)); err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range append(append([]*appsv1.Deployment{}, tc.roots...), leafs...) {
+				if _, err := kube.AppsV1().Deployments(d.Namespace).Create(context.Background(), d, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+				if err := informers.Kube.Apps().V1().Deployments().Informer().GetIndexer().Add(d); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, u := range tc.hot {
+				if err := clusters.Update(cluster("hot", u)); err != nil {
+					t.Fatal(err)
+				}
+				if err := c.process(context.Background(), logicalcluster.Key(workspace, "", "hot")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for name, want := range tc.wantCaps {
+				d, err := kube.AppsV1().Deployments("default").Get(context.Background(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := placement.DescheduledFor(d.Annotations)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) == 0 && len(want) == 0 {
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s is capped to %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Package drain tracks the Clusters being drained, by the drain taint, and
+// reports in their Drained condition whether the workloads that must be moved
+// off them are gone, much like kubectl drain waits for a node's pods to be
+// evicted. The splitters do the moving, as they do for any NoExecute taint,
+// within the ClusterDisruptionBudgets of the workloads.
+package drain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	appsv1lister "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resyncPeriod = 10 * time.Hour
+	clusterLabel = "cluster"
+)
+
+// NewController returns a new Controller which sets the Drained condition of
+// the Clusters with the drain taint, counting the Deployments and
+// StatefulSets still labeled for them, and removes it once the taint is.
+func NewController(cfg *rest.Config, shared *base.Informers) *Controller {
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	client := clusterclient.NewForConfigOrDie(cfg)
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	csif, sif := shared.Cluster, shared.Kube
+
+	c := &Controller{
+		client:            client.ClusterV1alpha1(),
+		indexer:           logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Clusters().Informer()),
+		deploymentIndexer: logicalcluster.IndexerFor(sif.Apps().V1().Deployments().Informer()),
+		statefulIndexer:   logicalcluster.IndexerFor(sif.Apps().V1().StatefulSets().Informer()),
+	}
+	c.Controller = base.New("drain", kubeClient, nil, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		csif.Cluster().V1alpha1().Clusters().Informer().HasSynced,
+		sif.Apps().V1().Deployments().Informer().HasSynced,
+		sif.Apps().V1().StatefulSets().Informer().HasSynced,
+	)
+	stopCh := c.StopCh()
+
+	csif.Cluster().V1alpha1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.Enqueue(obj) },
+	})
+	// Workloads leaving a Cluster may complete its drain.
+	for _, informer := range []cache.SharedIndexInformer{
+		sif.Apps().V1().Deployments().Informer(),
+		sif.Apps().V1().StatefulSets().Informer(),
+	} {
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) { c.enqueueClusterOf(obj) },
+			UpdateFunc: func(oldObj, obj interface{}) {
+				c.enqueueClusterOf(oldObj)
+				c.enqueueClusterOf(obj)
+			},
+			DeleteFunc: func(obj interface{}) { c.enqueueClusterOf(obj) },
+		})
+	}
+	if own {
+		csif.Start(stopCh)
+		sif.Start(stopCh)
+	}
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	client            clusterv1alpha1.ClusterV1alpha1Interface
+	indexer           cache.Indexer
+	deploymentIndexer cache.Indexer
+	statefulIndexer   cache.Indexer
+}
+
+// The listers of the objects of a workspace, whose workloads are only
+// placed on its own Clusters.
+
+func (c *Controller) clusters(workspace string) clusterlisters.ClusterLister {
+	return clusterlisters.NewClusterLister(logicalcluster.Scoped(c.indexer, workspace))
+}
+
+func (c *Controller) deployments(workspace string) appsv1lister.DeploymentLister {
+	return appsv1lister.NewDeploymentLister(logicalcluster.Scoped(c.deploymentIndexer, workspace))
+}
+
+func (c *Controller) statefulSets(workspace string) appsv1lister.StatefulSetLister {
+	return appsv1lister.NewStatefulSetLister(logicalcluster.Scoped(c.statefulIndexer, workspace))
+}
+
+// enqueueClusterOf enqueues the Cluster the given workload is labeled for,
+// if it's being drained.
+func (c *Controller) enqueueClusterOf(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	name := m.GetLabels()[clusterLabel]
+	if name == "" {
+		return
+	}
+	cl, err := c.clusters(m.GetClusterName()).Get(name)
+	if errors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	if placement.Draining(cl) {
+		c.Enqueue(cl)
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*v1alpha1.Cluster).DeepCopy()
+	previous := current.DeepCopy()
+
+	ctx, _ = logging.WithValues(ctx, logging.ClusterKey, current.Name)
+	if err := c.reconcile(ctx, current); err != nil {
+		return err
+	}
+
+	// If the object being reconciled changed as a result, update it.
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (apiruntime.Object, error) {
+				return c.client.Clusters().Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (apiruntime.Object, error) {
+				return c.client.Clusters().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) reconcile(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	if !placement.Draining(cluster) {
+		conditions.Remove(cluster, v1alpha1.ClusterConditionDrained)
+		return nil
+	}
+
+	remaining, err := c.remaining(cluster.GetClusterName(), cluster.Name)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		conditions.MarkFalse(cluster, v1alpha1.ClusterConditionDrained, "Draining", fmt.Sprintf("%d workloads are still on the cluster", remaining))
+		return nil
+	}
+	const msg = "No workloads that must be moved off the cluster are left on it"
+	if !conditions.IsTrue(cluster, v1alpha1.ClusterConditionDrained) {
+		logging.FromContext(ctx).Info("Cluster is drained")
+		c.Recorder().Event(cluster, corev1.EventTypeNormal, "Drained", msg)
+	}
+	conditions.MarkTrue(cluster, v1alpha1.ClusterConditionDrained, "Drained", msg)
+	return nil
+}
+
+// remaining returns how many Deployments and StatefulSets of the workspace
+// labeled for the named Cluster must still be moved off it.
+func (c *Controller) remaining(workspace, name string) (int, error) {
+	sel := labels.SelectorFromSet(labels.Set{clusterLabel: name})
+	n := 0
+	deployments, err := c.deployments(workspace).List(sel)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range deployments {
+		if !placement.Drained(d.Annotations, d.Spec.Replicas) {
+			n++
+		}
+	}
+	statefulSets, err := c.statefulSets(workspace).List(sel)
+	if err != nil {
+		return 0, err
+	}
+	for _, ss := range statefulSets {
+		if !placement.Drained(ss.Annotations, ss.Spec.Replicas) {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,170 @@
+// Package eviction marks Clusters that stay NotReady beyond a grace period as
+// Evicted, and taints them, so that the splitters move the workloads that
+// don't tolerate it to healthy Clusters, much like the node lifecycle
+// controller does for pods on NotReady nodes.
+package eviction
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// DefaultToleration is how long a Cluster may stay NotReady before its
+// workloads are evicted.
+const DefaultToleration = 5 * time.Minute
+
+// NewController returns a new Controller which sets the Evicted condition and
+// the unreachable NoExecute taint on Clusters that have been NotReady for
+// longer than toleration, and clears them once they're Ready again.
+func NewController(cfg *rest.Config, toleration time.Duration, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), clusterclient.NewForConfigOrDie(cfg), toleration, shared)
+	if own {
+		shared.Cluster.Start(c.StopCh())
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, client clusterclient.Interface, toleration time.Duration, shared *base.Informers) *Controller {
+	csif := shared.Cluster
+
+	c := &Controller{
+		toleration: toleration,
+		client:     client.ClusterV1alpha1(),
+		indexer:    logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Clusters().Informer()),
+	}
+	c.Controller = base.New("eviction", kubeClient, nil, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(csif.Cluster().V1alpha1().Clusters().Informer().HasSynced)
+
+	csif.Cluster().V1alpha1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.Enqueue(obj) },
+	})
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	toleration time.Duration
+	client     clusterv1alpha1.ClusterV1alpha1Interface
+	indexer    cache.Indexer
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*v1alpha1.Cluster).DeepCopy()
+	previous := current.DeepCopy()
+
+	ctx, _ = logging.WithValues(ctx, logging.ClusterKey, current.Name)
+	c.reconcile(ctx, key, current)
+
+	// If the object being reconciled changed as a result, update it.
+	if !equality.Semantic.DeepEqual(previous.Spec, current.Spec) {
+		updated, err := c.client.Clusters().Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		updated.Status = current.Status
+		current = updated
+	}
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (runtime.Object, error) {
+				return c.client.Clusters().Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+				return c.client.Clusters().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) reconcile(ctx context.Context, key string, cluster *v1alpha1.Cluster) {
+	logger := logging.FromContext(ctx)
+
+	ready := conditions.Get(cluster, v1alpha1.ClusterConditionReady)
+	switch {
+	case ready == nil:
+		// The Cluster was never Ready, so nothing was placed on it.
+		return
+	case ready.Status == corev1.ConditionTrue:
+		if conditions.IsTrue(cluster, v1alpha1.ClusterConditionEvicted) {
+			logger.Info("Cluster is Ready again, lifting eviction")
+			conditions.Set(cluster, v1alpha1.ClusterConditionEvicted, corev1.ConditionFalse,
+				"ClusterReady",
+				"Cluster is Ready again")
+		}
+		setUnreachableTaint(cluster, false)
+		return
+	case conditions.IsTrue(cluster, v1alpha1.ClusterConditionEvicted):
+		setUnreachableTaint(cluster, true)
+		return
+	}
+
+	notReadyFor := time.Since(ready.LastTransitionTime.Time)
+	if notReadyFor < c.toleration {
+		// Check again once the toleration is up, unless the Cluster changes before.
+		c.Queue().AddAfter(key, c.toleration-notReadyFor)
+		return
+	}
+
+	logger.Info("Evicting workloads from cluster", "notReadyFor", notReadyFor.Round(time.Second).String())
+	msg := fmt.Sprintf("Cluster has not been Ready for %s, longer than the %s toleration", notReadyFor.Round(time.Second), c.toleration)
+	conditions.Set(cluster, v1alpha1.ClusterConditionEvicted, corev1.ConditionTrue, "NotReadyTooLong", msg)
+	setUnreachableTaint(cluster, true)
+	c.Recorder().Event(cluster, corev1.EventTypeWarning, "Evicted", msg)
+}
+
+// setUnreachableTaint adds or removes the unreachable taint on the Cluster,
+// which workloads tolerate to stay on it while it's evicted from.
+func setUnreachableTaint(cluster *v1alpha1.Cluster, set bool) {
+	unreachable := placement.UnreachableTaint()
+	for i, t := range cluster.Spec.Taints {
+		if t.MatchTaint(&unreachable) {
+			if !set {
+				cluster.Spec.Taints = append(cluster.Spec.Taints[:i:i], cluster.Spec.Taints[i+1:]...)
+			}
+			return
+		}
+	}
+	if set {
+		now := metav1.Now()
+		unreachable.TimeAdded = &now
+		cluster.Spec.Taints = append(cluster.Spec.Taints, unreachable)
+	}
+}
@@ -0,0 +1,118 @@
+package eviction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterfake "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/fake"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+var key = logicalcluster.Key("admin", "", "us-east")
+
+// notReadyFor returns a Cluster that was Ready, and has been NotReady for
+// the given time, as if since then.
+func notReadyFor(d time.Duration) *v1alpha1.Cluster {
+	cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "us-east", ClusterName: "admin"}}
+	cl.Status.Conditions.SetReady(corev1.ConditionFalse, "HeartbeatMissed", "")
+	cl.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-d))
+	return cl
+}
+
+func newTestController(t *testing.T, toleration time.Duration, cl *v1alpha1.Cluster) (*Controller, *clusterfake.Clientset) {
+	clusters := clusterfake.NewSimpleClientset(cl)
+	kube := kubefake.NewSimpleClientset()
+	informers := base.NewInformersFor(kube, clusters, 0)
+	c := newController(kube, clusters, toleration, informers)
+	if err := informers.Cluster.Cluster().V1alpha1().Clusters().Informer().GetIndexer().Add(cl); err != nil {
+		t.Fatal(err)
+	}
+	return c, clusters
+}
+
+func get(t *testing.T, clusters *clusterfake.Clientset) *v1alpha1.Cluster {
+	cl, err := clusters.ClusterV1alpha1().Clusters().Get(context.Background(), "us-east", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cl
+}
+
+func tainted(cl *v1alpha1.Cluster) bool {
+	unreachable := placement.UnreachableTaint()
+	for _, taint := range cl.Spec.Taints {
+		if taint.MatchTaint(&unreachable) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWithinToleration(t *testing.T) {
+	c, clusters := newTestController(t, 200*time.Millisecond, notReadyFor(0))
+	defer c.Queue().ShutDown()
+	if err := c.process(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if actions := clusters.Actions(); len(actions) != 0 {
+		t.Errorf("changed the cluster within its toleration: %v", actions)
+	}
+	if got := get(t, clusters); conditions.IsTrue(got, v1alpha1.ClusterConditionEvicted) || tainted(got) {
+		t.Error("evicted a cluster within its toleration")
+	}
+	// It's checked again once the toleration is up.
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return c.Queue().Len() == 1, nil
+	}); err != nil {
+		t.Fatal("the cluster wasn't requeued")
+	}
+	if item, _ := c.Queue().Get(); item != key {
+		t.Errorf("requeued %v", item)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c, clusters := newTestController(t, 5*time.Minute, notReadyFor(10*time.Minute))
+	defer c.Queue().ShutDown()
+	if err := c.process(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	got := get(t, clusters)
+	if evicted := conditions.Get(got, v1alpha1.ClusterConditionEvicted); evicted == nil || evicted.Status != corev1.ConditionTrue || evicted.Reason != "NotReadyTooLong" {
+		t.Errorf("the Evicted condition is %+v", evicted)
+	}
+	if !tainted(got) {
+		t.Error("the evicted cluster isn't tainted")
+	}
+}
+
+func TestReadyAgain(t *testing.T) {
+	cl := notReadyFor(10 * time.Minute)
+	cl.Status.Conditions.Set(v1alpha1.ClusterConditionEvicted, corev1.ConditionTrue, "NotReadyTooLong", "")
+	cl.Spec.Taints = []corev1.Taint{placement.UnreachableTaint(), {Key: "dedicated", Effect: corev1.TaintEffectNoSchedule}}
+	cl.Status.Conditions.SetReady(corev1.ConditionTrue, "SyncerReady", "")
+	c, clusters := newTestController(t, 5*time.Minute, cl)
+	defer c.Queue().ShutDown()
+	if err := c.process(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	got := get(t, clusters)
+	if conditions.IsTrue(got, v1alpha1.ClusterConditionEvicted) {
+		t.Error("the Ready cluster is still Evicted")
+	}
+	if tainted(got) {
+		t.Error("the Ready cluster is still tainted")
+	}
+	if len(got.Spec.Taints) != 1 || got.Spec.Taints[0].Key != "dedicated" {
+		t.Errorf("the cluster has the taints %v, want its own left alone", got.Spec.Taints)
+	}
+}
@@ -0,0 +1,163 @@
+package ingress
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	networkingv1beta1lister "k8s.io/client-go/listers/networking/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which replicates each root Ingress
+// to the Clusters its backing Services were mirrored to, as virtual Ingresses
+// labeled for each of those Clusters, and aggregates their load-balancer
+// status into the root's.
+//
+// If publishDNSTargets is true, the addresses of every Cluster's load
+// balancer are also published in the root's external-dns target annotation.
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, publishDNSTargets bool, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), publishDNSTargets, leaderElection, retry, shared)
+	if own {
+		shared.Kube.Start(c.StopCh())
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, publishDNSTargets bool, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	sif := shared.Kube
+
+	c := &Controller{
+		indexer:           logicalcluster.IndexerFor(sif.Networking().V1beta1().Ingresses().Informer()),
+		serviceIndexer:    logicalcluster.IndexerFor(sif.Core().V1().Services().Informer()),
+		kubeClient:        kubeClient,
+		publishDNSTargets: publishDNSTargets,
+	}
+	c.orphans = base.NewOrphans(networkingv1beta1.SchemeGroupVersion.WithResource("ingresses"), c.indexer, leafClient{kubeClient.NetworkingV1beta1()})
+	c.Controller = base.New("ingress", kubeClient, leaderElection, retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		sif.Networking().V1beta1().Ingresses().Informer().HasSynced,
+		sif.Core().V1().Services().Informer().HasSynced,
+	)
+	c.AddPeriodic(c.orphans.Collect, gcInterval)
+
+	sif.Networking().V1beta1().Ingresses().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.Enqueue(obj) },
+	})
+	// Services being mirrored to more or fewer Clusters change the Clusters
+	// the Ingresses routing to them must be on.
+	sif.Core().V1().Services().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRootsFor(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRootsFor(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRootsFor(obj) },
+	})
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	indexer           cache.Indexer
+	serviceIndexer    cache.Indexer
+	orphans           *base.Orphans
+	kubeClient        kubernetes.Interface
+	publishDNSTargets bool
+}
+
+// The listers of the objects of a workspace. Ingresses only route to the
+// Services of their own workspace.
+
+func (c *Controller) ingresses(workspace string) networkingv1beta1lister.IngressLister {
+	return networkingv1beta1lister.NewIngressLister(logicalcluster.Scoped(c.indexer, workspace))
+}
+
+func (c *Controller) services(workspace string) corev1lister.ServiceLister {
+	return corev1lister.NewServiceLister(logicalcluster.Scoped(c.serviceIndexer, workspace))
+}
+
+// enqueueRootsFor enqueues every root Ingress in the namespace of the given
+// Service, i.e. those not replicated from another one.
+func (c *Controller) enqueueRootsFor(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	ingresses, err := c.ingresses(m.GetClusterName()).Ingresses(m.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	for _, ing := range ingresses {
+		if ing.Labels[base.LeafLabel] == "" {
+			c.Enqueue(ing)
+		}
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*networkingv1beta1.Ingress)
+	previous := current.DeepCopy()
+
+	if err := c.reconcile(ctx, current); err != nil {
+		return err
+	}
+
+	// If the object being reconciled changed as a result, update it.
+	if !equality.Semantic.DeepEqual(previous.Annotations, current.Annotations) {
+		updated, err := c.kubeClient.NetworkingV1beta1().Ingresses(current.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		updated.Status = current.Status
+		current = updated
+	}
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (apiruntime.Object, error) {
+				return c.kubeClient.NetworkingV1beta1().Ingresses(current.Namespace).Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (apiruntime.Object, error) {
+				return c.kubeClient.NetworkingV1beta1().Ingresses(current.Namespace).Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		return err
+	}
+
+	return nil
+}
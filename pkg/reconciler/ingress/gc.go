@@ -0,0 +1,26 @@
+package ingress
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkingv1beta1client "k8s.io/client-go/kubernetes/typed/networking/v1beta1"
+)
+
+// leafClient is the base.LeafClient orphaned virtual Ingresses are checked
+// and deleted with.
+type leafClient struct {
+	networkingv1beta1client.IngressesGetter
+}
+
+func (c leafClient) Get(ctx context.Context, namespace, name string) (metav1.Object, error) {
+	ing, err := c.Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return ing, nil
+}
+
+func (c leafClient) Delete(ctx context.Context, namespace, name string) error {
+	return c.Ingresses(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
@@ -0,0 +1,225 @@
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	clusterLabel = "cluster"
+	gcInterval   = time.Minute
+)
+
+func (c *Controller) reconcile(ctx context.Context, ing *networkingv1beta1.Ingress) error {
+	logging.FromContext(ctx).V(2).Info("Reconciling ingress")
+
+	if ing.Labels[base.LeafLabel] == "" {
+		// This is a root Ingress; make sure it's on every Cluster its Services are on.
+		return c.reconcileRoot(ctx, ing)
+	}
+
+	if deleted, err := c.orphans.DeleteIfOrphaned(ctx, ing); err != nil || deleted {
+		return err
+	}
+
+	// A virtual Ingress was updated; its root aggregates the status of all of them.
+	c.Queue().Add(logicalcluster.Key(ing.ClusterName, ing.Namespace, ing.Labels[base.LeafLabel]))
+	return nil
+}
+
+// reconcileRoot keeps one virtual Ingress per Cluster that one of the root
+// Ingress's backing Services was mirrored to, routing to that Cluster's
+// virtual Services, and aggregates their status. A root labeled for a
+// Cluster is synced as it is.
+func (c *Controller) reconcileRoot(ctx context.Context, root *networkingv1beta1.Ingress) error {
+	logger := logging.FromContext(ctx)
+
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+
+	clusters := map[string]map[string]string{}
+	if root.Labels[clusterLabel] == "" {
+		if clusters, err = c.clustersFor(root); err != nil {
+			return err
+		}
+	}
+
+	existing := map[string]*networkingv1beta1.Ingress{}
+	for _, leaf := range leafs {
+		cluster := leaf.Labels[clusterLabel]
+		if _, found := clusters[cluster]; !found {
+			if err := c.kubeClient.NetworkingV1beta1().Ingresses(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			logger.Info("Deleted child ingress", "child", leaf.Name, logging.ClusterKey, cluster)
+			continue
+		}
+		existing[cluster] = leaf
+	}
+
+	current := make([]*networkingv1beta1.Ingress, 0, len(clusters))
+	for _, cluster := range sets.StringKeySet(clusters).List() {
+		want := newLeaf(root, cluster, clusters[cluster])
+		leaf, found := existing[cluster]
+		if !found {
+			if _, err := c.kubeClient.NetworkingV1beta1().Ingresses(root.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			logger.Info("Created child ingress", "child", want.Name, logging.ClusterKey, cluster)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Replicated", "Replicated to cluster %s", cluster)
+			continue
+		}
+		current = append(current, leaf)
+		if equality.Semantic.DeepEqual(leaf.Labels, want.Labels) &&
+			equality.Semantic.DeepEqual(leaf.Annotations, want.Annotations) &&
+			equality.Semantic.DeepEqual(leaf.Spec, want.Spec) {
+			continue
+		}
+		updated := leaf.DeepCopy()
+		updated.Labels = want.Labels
+		updated.Annotations = want.Annotations
+		updated.Spec = want.Spec
+		if _, err := c.kubeClient.NetworkingV1beta1().Ingresses(leaf.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logger.V(2).Info("Updated child ingress", "child", leaf.Name, logging.ClusterKey, cluster)
+	}
+
+	if root.Labels[clusterLabel] == "" {
+		aggregateStatus(root, current)
+	}
+	if c.publishDNSTargets {
+		setDNSTargets(root)
+	}
+	return nil
+}
+
+// clustersFor returns, for each Cluster that a Service the root Ingress
+// routes to is on, the name of each of those Services there: the name of its
+// virtual Service for that Cluster if it was mirrored, or its own if it's
+// labeled for the Cluster.
+func (c *Controller) clustersFor(root *networkingv1beta1.Ingress) (map[string]map[string]string, error) {
+	clusters := map[string]map[string]string{}
+	add := func(cluster, name, nameThere string) {
+		if clusters[cluster] == nil {
+			clusters[cluster] = map[string]string{}
+		}
+		clusters[cluster][name] = nameThere
+	}
+
+	for _, name := range backendServices(&root.Spec).List() {
+		svc, err := c.services(root.ClusterName).Services(root.Namespace).Get(name)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if cluster := svc.Labels[clusterLabel]; cluster != "" {
+			add(cluster, name, name)
+			continue
+		}
+		sel, err := labels.Parse(fmt.Sprintf("%s=%s", base.LeafLabel, name))
+		if err != nil {
+			return nil, err
+		}
+		leafs, err := c.services(root.ClusterName).Services(root.Namespace).List(sel)
+		if err != nil {
+			return nil, err
+		}
+		for _, leaf := range leafs {
+			add(leaf.Labels[clusterLabel], name, leaf.Name)
+		}
+	}
+	return clusters, nil
+}
+
+// backendServices returns the names of the Services an Ingress routes to.
+func backendServices(spec *networkingv1beta1.IngressSpec) sets.String {
+	names := sets.NewString()
+	forEachBackend(spec, func(b *networkingv1beta1.IngressBackend) {
+		if b.ServiceName != "" {
+			names.Insert(b.ServiceName)
+		}
+	})
+	return names
+}
+
+func forEachBackend(spec *networkingv1beta1.IngressSpec, fn func(*networkingv1beta1.IngressBackend)) {
+	if spec.Backend != nil {
+		fn(spec.Backend)
+	}
+	for i := range spec.Rules {
+		if spec.Rules[i].HTTP == nil {
+			continue
+		}
+		for j := range spec.Rules[i].HTTP.Paths {
+			fn(&spec.Rules[i].HTTP.Paths[j].Backend)
+		}
+	}
+}
+
+// newLeaf returns a virtual Ingress for the given root, labeled/named for the
+// given Cluster, routing to the Services named there as in services.
+// Backends whose Service isn't on the Cluster are left as they are.
+func newLeaf(root *networkingv1beta1.Ingress, clusterName string, services map[string]string) *networkingv1beta1.Ingress {
+	vi := root.DeepCopy()
+
+	vi.Name = fmt.Sprintf("%s--%s", root.Name, clusterName)
+	vi.ResourceVersion = ""
+	vi.UID = ""
+
+	if vi.Labels == nil {
+		vi.Labels = map[string]string{}
+	}
+	vi.Labels[clusterLabel] = clusterName
+	vi.Labels[base.LeafLabel] = root.Name
+	// Only the root's addresses are meant for DNS.
+	delete(vi.Annotations, dnsTargetAnnotation)
+
+	forEachBackend(&vi.Spec, func(b *networkingv1beta1.IngressBackend) {
+		if name, found := services[b.ServiceName]; found {
+			b.ServiceName = name
+		}
+	})
+	vi.Status = networkingv1beta1.IngressStatus{}
+
+	// Set OwnerReference so deleting the Ingress deletes all virtual Ingresses.
+	vi.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "networking.k8s.io/v1beta1",
+		Kind:       "Ingress",
+		Name:       root.Name,
+		UID:        root.UID,
+	}}
+	return vi
+}
+
+// leafsFor returns the virtual Ingresses replicated from the given root,
+// sorted by name.
+func (c *Controller) leafsFor(root *networkingv1beta1.Ingress) ([]*networkingv1beta1.Ingress, error) {
+	sel, err := labels.Parse(fmt.Sprintf("%s=%s", base.LeafLabel, root.Name))
+	if err != nil {
+		return nil, err
+	}
+	leafs, err := c.ingresses(root.ClusterName).Ingresses(root.Namespace).List(sel)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(leafs, func(i, j int) bool { return leafs[i].Name < leafs[j].Name })
+	return leafs, nil
+}
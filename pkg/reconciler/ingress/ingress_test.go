@@ -0,0 +1,233 @@
+package ingress
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base/basetest"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const workspace = "admin"
+
+type fixture struct {
+	*basetest.Fixture
+	c *Controller
+}
+
+func newFixture(t *testing.T, publishDNSTargets bool) *fixture {
+	f := &fixture{Fixture: basetest.New(t)}
+	f.c = newController(f.Kube, publishDNSTargets, nil, nil, f.Informers)
+	return f
+}
+
+// addIngresses creates the Ingresses, as the API server and the cache of
+// the Controller would have them.
+func (f *fixture) addIngresses(ingresses ...*networkingv1beta1.Ingress) {
+	for _, ing := range ingresses {
+		if _, err := f.Kube.NetworkingV1beta1().Ingresses(ing.Namespace).Create(context.Background(), ing, metav1.CreateOptions{}); err != nil {
+			f.T.Fatal(err)
+		}
+		f.Add(f.Informers.Kube.Networking().V1beta1().Ingresses().Informer(), ing)
+	}
+}
+
+// mirror adds the root Service of the given name to the cache of the
+// Controller, and a virtual Service of it for each Cluster.
+func (f *fixture) mirror(name string, clusters ...string) {
+	informer := f.Informers.Kube.Core().V1().Services().Informer()
+	f.Add(informer, service(name, nil))
+	for _, cluster := range clusters {
+		f.Add(informer, service(name+"--"+cluster, map[string]string{base.LeafLabel: name, clusterLabel: cluster}))
+	}
+}
+
+func (f *fixture) process(name string) error {
+	return f.c.process(context.Background(), logicalcluster.Key(workspace, "default", name))
+}
+
+// ingresses returns the Ingresses in kcp, by name.
+func (f *fixture) ingresses() map[string]*networkingv1beta1.Ingress {
+	list, err := f.Kube.NetworkingV1beta1().Ingresses("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	ingresses := map[string]*networkingv1beta1.Ingress{}
+	for i := range list.Items {
+		ingresses[list.Items[i].Name] = &list.Items[i]
+	}
+	return ingresses
+}
+
+func service(name string, labels map[string]string) *corev1.Service {
+	return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ClusterName: workspace, Labels: labels}}
+}
+
+func backend(service string) networkingv1beta1.IngressBackend {
+	return networkingv1beta1.IngressBackend{ServiceName: service, ServicePort: intstr.FromInt(80)}
+}
+
+// root returns a root Ingress routing /api to the given Service, and
+// everything else to web.
+func root(api string) *networkingv1beta1.Ingress {
+	web := backend("web")
+	return &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default", ClusterName: workspace, UID: "root-uid"},
+		Spec: networkingv1beta1.IngressSpec{
+			Backend: &web,
+			Rules: []networkingv1beta1.IngressRule{{
+				Host: "shop.example.com",
+				IngressRuleValue: networkingv1beta1.IngressRuleValue{HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+					Paths: []networkingv1beta1.HTTPIngressPath{{Path: "/api", Backend: backend(api)}},
+				}},
+			}},
+		},
+	}
+}
+
+// leafOf returns the virtual Ingress of the root for the Cluster, with the
+// given load-balancer IP.
+func leafOf(root *networkingv1beta1.Ingress, cluster, ip string) *networkingv1beta1.Ingress {
+	leaf := newLeaf(root, cluster, map[string]string{"web": "web--" + cluster})
+	if ip != "" {
+		leaf.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+	}
+	return leaf
+}
+
+func TestReplicate(t *testing.T) {
+	f := newFixture(t, false)
+	f.mirror("web", "east", "west")
+	f.mirror("api", "east")
+	f.addIngresses(root("api"))
+	if err := f.process("shop"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.ingresses()
+	if len(got) != 3 {
+		t.Fatalf("got ingresses %v", got)
+	}
+	for cluster, api := range map[string]string{"east": "api--east", "west": "api"} {
+		leaf, found := got["shop--"+cluster]
+		if !found {
+			t.Fatalf("not replicated to %s", cluster)
+		}
+		if leaf.Labels[clusterLabel] != cluster || leaf.Labels[base.LeafLabel] != "shop" {
+			t.Errorf("%s: got labels %v", cluster, leaf.Labels)
+		}
+		if got := leaf.Spec.Backend.ServiceName; got != "web--"+cluster {
+			t.Errorf("%s: the default backend routes to %s", cluster, got)
+		}
+		// Backends whose Service isn't on the Cluster are left as they are.
+		if got := leaf.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName; got != api {
+			t.Errorf("%s: /api routes to %s, want %s", cluster, got, api)
+		}
+		if refs := leaf.OwnerReferences; len(refs) != 1 || refs[0].UID != "root-uid" {
+			t.Errorf("%s: got owner references %v", cluster, refs)
+		}
+	}
+}
+
+func TestReplicateToLabeledService(t *testing.T) {
+	f := newFixture(t, false)
+	f.Add(f.Informers.Kube.Core().V1().Services().Informer(), service("web", map[string]string{clusterLabel: "east"}))
+	f.addIngresses(root("web"))
+	if err := f.process("shop"); err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, found := f.ingresses()["shop--east"]
+	if !found {
+		t.Fatalf("not replicated to the Cluster of the Service: %v", f.ingresses())
+	}
+	if got := leaf.Spec.Backend.ServiceName; got != "web" {
+		t.Errorf("routes to %s", got)
+	}
+}
+
+func TestUnreplicate(t *testing.T) {
+	f := newFixture(t, false)
+	f.mirror("web", "east")
+	r := root("web")
+	f.addIngresses(r, leafOf(r, "east", ""), leafOf(r, "west", ""))
+	if err := f.process("shop"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.ingresses()
+	if _, found := got["shop--west"]; found {
+		t.Error("kept the virtual Ingress of a Cluster its Services aren't on")
+	}
+	if _, found := got["shop--east"]; !found {
+		t.Error("deleted the virtual Ingress of a Cluster its Services are on")
+	}
+}
+
+func TestAggregateStatus(t *testing.T) {
+	for _, publishDNSTargets := range []bool{false, true} {
+		f := newFixture(t, publishDNSTargets)
+		f.mirror("web", "east", "west")
+		r := root("web")
+		east, west := leafOf(r, "east", "192.0.2.2"), leafOf(r, "west", "192.0.2.1")
+		west.Status.LoadBalancer.Ingress = append(west.Status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{IP: "192.0.2.2"})
+		f.addIngresses(r, east, west)
+		if err := f.process("shop"); err != nil {
+			t.Fatal(err)
+		}
+
+		got := f.ingresses()["shop"]
+		want := []corev1.LoadBalancerIngress{{IP: "192.0.2.1"}, {IP: "192.0.2.2"}}
+		if !reflect.DeepEqual(got.Status.LoadBalancer.Ingress, want) {
+			t.Errorf("got load-balancer ingress points %v, want %v", got.Status.LoadBalancer.Ingress, want)
+		}
+		targets, found := got.Annotations[dnsTargetAnnotation]
+		if publishDNSTargets && targets != "192.0.2.1,192.0.2.2" || !publishDNSTargets && found {
+			t.Errorf("publishDNSTargets %v: got DNS targets %q", publishDNSTargets, targets)
+		}
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	for _, c := range []struct {
+		desc    string
+		root    *networkingv1beta1.Ingress
+		deleted bool
+	}{{
+		desc:    "root gone",
+		deleted: true,
+	}, {
+		desc: "root replaced",
+		root: func() *networkingv1beta1.Ingress {
+			ing := root("web")
+			ing.UID = "other-uid"
+			return ing
+		}(),
+		deleted: true,
+	}, {
+		desc: "root there",
+		root: root("web"),
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			f := newFixture(t, false)
+			f.mirror("web", "east")
+			if c.root != nil {
+				f.addIngresses(c.root)
+			}
+			f.addIngresses(leafOf(root("web"), "east", ""))
+			if err := f.process("shop--east"); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, found := f.ingresses()["shop--east"]; found == c.deleted {
+				t.Errorf("leaf found: %v, want %v", found, !c.deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package ingress
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+)
+
+// dnsTargetAnnotation lists the addresses external-dns points the hosts of
+// an Ingress at, instead of those in its status.
+const dnsTargetAnnotation = "external-dns.alpha.kubernetes.io/target"
+
+// aggregateStatus sets the root's load-balancer ingress points to those of
+// every leaf, without duplicates and in a stable order.
+func aggregateStatus(root *networkingv1beta1.Ingress, leafs []*networkingv1beta1.Ingress) {
+	seen := map[corev1.LoadBalancerIngress]bool{}
+	var points []corev1.LoadBalancerIngress
+	for _, leaf := range leafs {
+		for _, point := range leaf.Status.LoadBalancer.Ingress {
+			key := corev1.LoadBalancerIngress{IP: point.IP, Hostname: point.Hostname}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			points = append(points, point)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].IP != points[j].IP {
+			return points[i].IP < points[j].IP
+		}
+		return points[i].Hostname < points[j].Hostname
+	})
+	root.Status.LoadBalancer.Ingress = points
+}
+
+// setDNSTargets publishes the root's load-balancer addresses in its
+// external-dns target annotation, so that external-dns watching kcp points
+// the root's hosts at every Cluster. The annotation is removed while there
+// are none.
+func setDNSTargets(root *networkingv1beta1.Ingress) {
+	var targets []string
+	for _, point := range root.Status.LoadBalancer.Ingress {
+		if point.IP != "" {
+			targets = append(targets, point.IP)
+		} else if point.Hostname != "" {
+			targets = append(targets, point.Hostname)
+		}
+	}
+	if len(targets) == 0 {
+		delete(root.Annotations, dnsTargetAnnotation)
+		return
+	}
+	if root.Annotations == nil {
+		root.Annotations = map[string]string{}
+	}
+	root.Annotations[dnsTargetAnnotation] = strings.Join(targets, ",")
+}
@@ -0,0 +1,289 @@
+// Package negotiation publishes the resources imported from the physical
+// clusters as CRDs, negotiating their schema when clusters serve them with
+// different versions or schemas.
+//
+// The cluster and apiimport controllers record the schemas each Cluster
+// serves a resource with in the resource's NegotiatedAPIResource, and this
+// controller publishes the version and schema compatible with all of them,
+// rejecting the Clusters it can't be made compatible with. Only the
+// compatible Clusters are labeled as those a CRD was imported from.
+package negotiation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which publishes the
+// NegotiatedAPIResources as CRDs.
+func NewController(cfg *rest.Config, shared *base.Informers) *Controller {
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	client := clusterclient.NewForConfigOrDie(cfg)
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	csif := shared.Cluster
+
+	c := &Controller{
+		client:         client.ClusterV1alpha1(),
+		indexer:        logicalcluster.IndexerFor(csif.Cluster().V1alpha1().NegotiatedAPIResources().Informer()),
+		clusterIndexer: logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Clusters().Informer()),
+		crdClient:      apiextensionsv1client.NewForConfigOrDie(cfg),
+	}
+	c.Controller = base.New("negotiation", kubeClient, nil, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		csif.Cluster().V1alpha1().NegotiatedAPIResources().Informer().HasSynced,
+		csif.Cluster().V1alpha1().Clusters().Informer().HasSynced,
+	)
+	stopCh := c.StopCh()
+
+	csif.Cluster().V1alpha1().NegotiatedAPIResources().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(oldObj, obj interface{}) {
+			old, nar := oldObj.(*v1alpha1.NegotiatedAPIResource), obj.(*v1alpha1.NegotiatedAPIResource)
+			// Skip the updates of the status made here, but not resyncs.
+			if old.ResourceVersion == nar.ResourceVersion || !equality.Semantic.DeepEqual(old.Spec, nar.Spec) {
+				c.Enqueue(obj)
+			}
+		},
+	})
+	// Drop the imports of the Clusters that are deleted.
+	csif.Cluster().V1alpha1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) { c.enqueueWorkspaceOf(obj) },
+	})
+	if own {
+		csif.Start(stopCh)
+	}
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	client         clusterv1alpha1.ClusterV1alpha1Interface
+	indexer        cache.Indexer
+	clusterIndexer cache.Indexer
+	crdClient      apiextensionsv1client.ApiextensionsV1Interface
+}
+
+// clusters returns the lister of the Clusters of a workspace, which its
+// resources are only imported from.
+func (c *Controller) clusters(workspace string) clusterlisters.ClusterLister {
+	return clusterlisters.NewClusterLister(logicalcluster.Scoped(c.clusterIndexer, workspace))
+}
+
+// enqueueWorkspaceOf enqueues the NegotiatedAPIResources of the workspace of
+// the given Cluster.
+func (c *Controller) enqueueWorkspaceOf(obj interface{}) {
+	workspace, err := logicalcluster.ClusterName(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, nar := range logicalcluster.Scoped(c.indexer, workspace).List() {
+		c.Enqueue(nar)
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*v1alpha1.NegotiatedAPIResource).DeepCopy()
+	previous := current.DeepCopy()
+
+	ctx, _ = logging.WithValues(ctx, "resource", current.Name)
+	if err := c.reconcile(ctx, current); err != nil {
+		return err
+	}
+	current.Status.ObservedGeneration = current.Generation
+
+	if len(current.Spec.Imports) == 0 {
+		// The CRD went with the last Cluster it was imported from.
+		err := c.client.NegotiatedAPIResources().Delete(ctx, current.Name, metav1.DeleteOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !equality.Semantic.DeepEqual(previous.Spec, current.Spec) {
+		updated, err := c.client.NegotiatedAPIResources().Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		current.ResourceVersion = updated.ResourceVersion
+	}
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (runtime.Object, error) {
+				return c.client.NegotiatedAPIResources().Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+				return c.client.NegotiatedAPIResources().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) reconcile(ctx context.Context, nar *v1alpha1.NegotiatedAPIResource) error {
+	logger := logging.FromContext(ctx)
+
+	var imports []v1alpha1.APIResourceImport
+	for _, imp := range nar.Spec.Imports {
+		_, err := c.clusters(nar.GetClusterName()).Get(imp.Cluster)
+		if errors.IsNotFound(err) {
+			logger.V(2).Info("Dropping the import of a deleted cluster", logging.ClusterKey, imp.Cluster)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		imports = append(imports, imp)
+	}
+	nar.Spec.Imports = imports
+	if len(imports) == 0 {
+		return nil
+	}
+
+	o := Negotiate(imports)
+	nar.Status.Version = o.Version
+	nar.Status.Clusters = o.Clusters
+	var incompatible []string
+	for _, cl := range o.Clusters {
+		if !cl.Compatible {
+			incompatible = append(incompatible, fmt.Sprintf("%s: %s", cl.Name, cl.Reason))
+		}
+	}
+	if len(incompatible) == 0 {
+		conditions.Set(nar, v1alpha1.NegotiatedAPIResourceConditionCompatible, corev1.ConditionTrue, "AllClustersCompatible", "All clusters are compatible with the published schema")
+	} else {
+		logger.V(2).Info("Rejected incompatible clusters", "clusters", incompatible)
+		conditions.Set(nar, v1alpha1.NegotiatedAPIResourceConditionCompatible, corev1.ConditionFalse, "IncompatibleClusters", fmt.Sprintf("Clusters incompatible with the published schema: %s", strings.Join(incompatible, "; ")))
+	}
+
+	if o.Schema == nil {
+		nar.Status.Schema = runtime.RawExtension{}
+		conditions.Set(nar, v1alpha1.NegotiatedAPIResourceConditionPublished, corev1.ConditionFalse, "NoCompatibleCluster", "No cluster serves a valid schema for the resource")
+		return nil
+	}
+	raw, err := canonicalJSON(o.Schema)
+	if err != nil {
+		return err
+	}
+	nar.Status.Schema = runtime.RawExtension{Raw: raw}
+
+	err = c.publish(ctx, nar, o)
+	if errors.IsInvalid(err) {
+		// Retrying won't help until the Clusters serve another schema.
+		logger.Error(err, "Error publishing CRD")
+		conditions.Set(nar, v1alpha1.NegotiatedAPIResourceConditionPublished, corev1.ConditionFalse, "InvalidSchema", fmt.Sprintf("Error publishing CRD: %v", err))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	conditions.Set(nar, v1alpha1.NegotiatedAPIResourceConditionPublished, corev1.ConditionTrue, "Published", fmt.Sprintf("Published version %s of the resource", o.Version))
+	return nil
+}
+
+// publish creates or updates the CRD of the resource, labeled as imported
+// from the compatible Clusters.
+func (c *Controller) publish(ctx context.Context, nar *v1alpha1.NegotiatedAPIResource, o Outcome) error {
+	labels := map[string]string{}
+	for _, cl := range o.Clusters {
+		if cl.Compatible {
+			labels[OriginLabel(cl.Name)] = ""
+		}
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nar.Name,
+			ClusterName: nar.GetClusterName(),
+			Labels:      labels,
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: o.Base.Group,
+			Names: *o.Base.Names.DeepCopy(),
+			Scope: o.Base.Scope,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:         o.Version,
+				Served:       true,
+				Storage:      true,
+				Schema:       &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: o.Schema},
+				Subresources: o.Subresources,
+			}},
+		},
+	}
+	apiextensionsv1.SetDefaults_CustomResourceDefinition(crd)
+
+	existing, err := c.crdClient.CustomResourceDefinitions().Get(ctx, crd.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = c.crdClient.CustomResourceDefinitions().Create(ctx, crd, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	// Keep the labels that aren't origin labels, and the versions objects
+	// may still be stored in, unserved.
+	for k, v := range existing.Labels {
+		if !strings.HasPrefix(k, originLabelPrefix) {
+			crd.Labels[k] = v
+		}
+	}
+	for _, stored := range existing.Status.StoredVersions {
+		if stored == o.Version {
+			continue
+		}
+		for _, v := range existing.Spec.Versions {
+			if v.Name == stored {
+				v.Served, v.Storage = false, false
+				crd.Spec.Versions = append(crd.Spec.Versions, v)
+			}
+		}
+	}
+	if equality.Semantic.DeepEqual(crd.Spec, existing.Spec) && equality.Semantic.DeepEqual(crd.Labels, existing.Labels) {
+		return nil
+	}
+	updated := existing.DeepCopy()
+	updated.Spec = crd.Spec
+	updated.Labels = crd.Labels
+	_, err = c.crdClient.CustomResourceDefinitions().Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,128 @@
+package negotiation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/retry"
+)
+
+const originLabelPrefix = "imported-from/"
+
+// OriginLabel labels the CRDs published for the resources imported from the
+// named Cluster, as long as it's compatible with them.
+func OriginLabel(cluster string) string {
+	return originLabelPrefix + cluster
+}
+
+// Import records the given CRDs, pulled from the Cluster, as its imports of
+// their NegotiatedAPIResources in its logical cluster, creating those that
+// don't exist yet. The context must be that of the logical cluster.
+func Import(ctx context.Context, client clusterv1alpha1.NegotiatedAPIResourcesGetter, cluster *v1alpha1.Cluster, crds map[string]*apiextensionsv1.CustomResourceDefinition) error {
+	var errs []error
+	for _, crd := range crds {
+		imp, err := importOf(cluster.Name, crd)
+		if err == nil {
+			err = submit(ctx, client.NegotiatedAPIResources(), cluster.GetClusterName(), crd.Name, imp)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resource %s: %w", crd.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func importOf(cluster string, crd *apiextensionsv1.CustomResourceDefinition) (v1alpha1.APIResourceImport, error) {
+	imp := v1alpha1.APIResourceImport{
+		Cluster: cluster,
+		Group:   crd.Spec.Group,
+		Names:   *crd.Spec.Names.DeepCopy(),
+		Scope:   crd.Spec.Scope,
+	}
+	for _, v := range crd.Spec.Versions {
+		if !v.Served {
+			continue
+		}
+		var schema interface{} = map[string]interface{}{
+			"type":                                 "object",
+			"x-kubernetes-preserve-unknown-fields": true,
+		}
+		if v.Schema != nil && v.Schema.OpenAPIV3Schema != nil {
+			schema = v.Schema.OpenAPIV3Schema
+		}
+		raw, err := canonicalJSON(schema)
+		if err != nil {
+			return imp, err
+		}
+		imp.Versions = append(imp.Versions, v1alpha1.APIResourceVersion{
+			Name:         v.Name,
+			Schema:       runtime.RawExtension{Raw: raw},
+			Subresources: v.Subresources.DeepCopy(),
+		})
+	}
+	return imp, nil
+}
+
+// canonicalJSON encodes v like the API server encodes the fields that
+// preserve unknown fields, with sorted keys, so that schemas that didn't
+// change compare equal to those read back.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// submit records imp as its Cluster's import of the named
+// NegotiatedAPIResource, replacing any previous one.
+func submit(ctx context.Context, client clusterv1alpha1.NegotiatedAPIResourceInterface, logicalCluster, name string, imp v1alpha1.APIResourceImport) error {
+	conflicting := func(err error) bool { return errors.IsConflict(err) || errors.IsAlreadyExists(err) }
+	return retry.OnError(retry.DefaultRetry, conflicting, func() error {
+		nar, err := client.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = client.Create(ctx, &v1alpha1.NegotiatedAPIResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					ClusterName: logicalCluster,
+				},
+				Spec: v1alpha1.NegotiatedAPIResourceSpec{
+					Imports: []v1alpha1.APIResourceImport{imp},
+				},
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for i := range nar.Spec.Imports {
+			if nar.Spec.Imports[i].Cluster != imp.Cluster {
+				continue
+			}
+			if equality.Semantic.DeepEqual(nar.Spec.Imports[i], imp) {
+				return nil
+			}
+			nar.Spec.Imports[i], found = imp, true
+		}
+		if !found {
+			nar.Spec.Imports = append(nar.Spec.Imports, imp)
+		}
+		_, err = client.Update(ctx, nar, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,217 @@
+package negotiation
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterfake "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/fake"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// crd returns the CRD of widgets, with the given served versions and their
+// schemas, "" for none.
+func crd(versions ...string) *apiextensionsv1.CustomResourceDefinition {
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Plural: "widgets", Kind: "Widget"},
+			Scope: apiextensionsv1.NamespaceScoped,
+		},
+	}
+	for i := 0; i < len(versions); i += 2 {
+		v := apiextensionsv1.CustomResourceDefinitionVersion{Name: versions[i], Served: true}
+		if versions[i+1] != "" {
+			v.Schema = &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{}}
+			if err := json.Unmarshal([]byte(versions[i+1]), v.Schema.OpenAPIV3Schema); err != nil {
+				panic(err)
+			}
+		}
+		crd.Spec.Versions = append(crd.Spec.Versions, v)
+	}
+	return crd
+}
+
+func cluster(name string) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: "admin"}}
+}
+
+func nar(imports ...v1alpha1.APIResourceImport) *v1alpha1.NegotiatedAPIResource {
+	return &v1alpha1.NegotiatedAPIResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com", ClusterName: "admin"},
+		Spec:       v1alpha1.NegotiatedAPIResourceSpec{Imports: imports},
+	}
+}
+
+// schemas returns the schemas of the imports of the NegotiatedAPIResource of
+// widgets, by cluster and version.
+func schemas(t *testing.T, client *clusterfake.Clientset) map[string]string {
+	nar, err := client.ClusterV1alpha1().NegotiatedAPIResources().Get(context.Background(), "widgets.example.com", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := map[string]string{}
+	for _, imp := range nar.Spec.Imports {
+		for _, v := range imp.Versions {
+			schemas[imp.Cluster+"/"+v.Name] = string(v.Schema.Raw)
+		}
+	}
+	return schemas
+}
+
+func TestImportOf(t *testing.T) {
+	c := crd("v1", `{"type":"object","properties":{"spec":{"type":"object"}}}`, "v2", "", "v3", sizeColor)
+	c.Spec.Versions[2].Served = false
+	c.Spec.Versions[0].Subresources = &apiextensionsv1.CustomResourceSubresources{Status: &apiextensionsv1.CustomResourceSubresourceStatus{}}
+	got, err := importOf("east", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cluster != "east" || got.Group != "example.com" || got.Names.Kind != "Widget" || got.Scope != apiextensionsv1.NamespaceScoped {
+		t.Errorf("got import %+v", got)
+	}
+	if len(got.Versions) != 2 {
+		t.Fatalf("got versions %+v, want the served ones", got.Versions)
+	}
+	if v := got.Versions[0]; v.Name != "v1" || string(v.Schema.Raw) != `{"properties":{"spec":{"type":"object"}},"type":"object"}` || v.Subresources == nil || v.Subresources.Status == nil {
+		t.Errorf("got version %s with schema %s and subresources %v", v.Name, v.Schema.Raw, v.Subresources)
+	}
+	// Versions without a schema preserve all their fields.
+	if v := got.Versions[1]; v.Name != "v2" || string(v.Schema.Raw) != `{"type":"object","x-kubernetes-preserve-unknown-fields":true}` {
+		t.Errorf("got version %s with schema %s", v.Name, v.Schema.Raw)
+	}
+}
+
+func TestImport(t *testing.T) {
+	const (
+		v1 = `{"type":"object","properties":{"spec":{"type":"object","properties":{"size":{"type":"integer"}}}}}`
+		v2 = `{"type":"object","properties":{"spec":{"type":"object","properties":{"size":{"type":"string"}}}}}`
+	)
+	canonical := func(schema string) string {
+		imp, err := importOf("", crd("v1", schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(imp.Versions[0].Schema.Raw)
+	}
+	importOf := func(cluster string, versions ...string) v1alpha1.APIResourceImport {
+		imp, err := importOf(cluster, crd(versions...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return imp
+	}
+
+	for _, tc := range []struct {
+		name     string
+		existing *v1alpha1.NegotiatedAPIResource
+		imported *apiextensionsv1.CustomResourceDefinition
+		want     map[string]string
+		// updated is whether an existing NegotiatedAPIResource is updated.
+		updated bool
+	}{{
+		name:     "new resource",
+		imported: crd("v1", v1),
+		want:     map[string]string{"east/v1": canonical(v1)},
+	}, {
+		name:     "import of another cluster",
+		existing: nar(importOf("west", "v1", v2)),
+		imported: crd("v1", v1),
+		want:     map[string]string{"west/v1": canonical(v2), "east/v1": canonical(v1)},
+		updated:  true,
+	}, {
+		name:     "changed import",
+		existing: nar(importOf("west", "v1", v2), importOf("east", "v1", v2)),
+		imported: crd("v1", v1, "v2", v2),
+		want:     map[string]string{"west/v1": canonical(v2), "east/v1": canonical(v1), "east/v2": canonical(v2)},
+		updated:  true,
+	}, {
+		name:     "unchanged import",
+		existing: nar(importOf("east", "v1", v1), importOf("west", "v1", v2)),
+		imported: crd("v1", v1),
+		want:     map[string]string{"east/v1": canonical(v1), "west/v1": canonical(v2)},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var objs []runtime.Object
+			if tc.existing != nil {
+				objs = append(objs, tc.existing)
+			}
+			client := clusterfake.NewSimpleClientset(objs...)
+			if err := Import(context.Background(), client.ClusterV1alpha1(), cluster("east"), map[string]*apiextensionsv1.CustomResourceDefinition{"widgets.example.com": tc.imported}); err != nil {
+				t.Fatal(err)
+			}
+			if got := schemas(t, client); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got schemas %v, want %v", got, tc.want)
+			}
+			updated := false
+			for _, action := range client.Actions() {
+				updated = updated || action.Matches("update", "negotiatedapiresources")
+			}
+			if updated != tc.updated {
+				t.Errorf("updated: %v, want %v", updated, tc.updated)
+			}
+		})
+	}
+}
+
+// TestImportConflicts checks that the imports of Clusters racing to import
+// the same resource are all recorded.
+func TestImportConflicts(t *testing.T) {
+	west, err := importOf("west", crd("v1", sizeShape))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		existing []runtime.Object
+		verb     string
+		// race records the import of west, as the conflicting writes do.
+		race func(client *clusterfake.Clientset) error
+	}{{
+		name: "created meanwhile",
+		verb: "create",
+		race: func(client *clusterfake.Clientset) error {
+			return client.Tracker().Add(nar(west))
+		},
+	}, {
+		name:     "updated meanwhile",
+		existing: []runtime.Object{nar()},
+		verb:     "update",
+		race: func(client *clusterfake.Clientset) error {
+			return client.Tracker().Update(v1alpha1.SchemeGroupVersion.WithResource("negotiatedapiresources"), nar(west), "")
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := clusterfake.NewSimpleClientset(tc.existing...)
+			raced := false
+			client.PrependReactor(tc.verb, "negotiatedapiresources", func(clienttesting.Action) (bool, runtime.Object, error) {
+				if raced {
+					return false, nil, nil
+				}
+				raced = true
+				if err := tc.race(client); err != nil {
+					t.Fatal(err)
+				}
+				if tc.verb == "create" {
+					return true, nil, apierrors.NewAlreadyExists(v1alpha1.Resource("negotiatedapiresources"), "widgets.example.com")
+				}
+				return true, nil, apierrors.NewConflict(v1alpha1.Resource("negotiatedapiresources"), "widgets.example.com", nil)
+			})
+
+			if err := Import(context.Background(), client.ClusterV1alpha1(), cluster("east"), map[string]*apiextensionsv1.CustomResourceDefinition{"widgets.example.com": crd("v1", sizeColor)}); err != nil {
+				t.Fatal(err)
+			}
+			got := schemas(t, client)
+			if _, found := got["east/v1"]; !found || len(got) != 2 {
+				t.Errorf("got schemas %v, want those of east and west", got)
+			}
+		})
+	}
+}
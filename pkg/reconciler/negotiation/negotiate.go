@@ -0,0 +1,265 @@
+package negotiation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// Outcome is the outcome of the negotiation of a resource's schema.
+type Outcome struct {
+	// Base is the import the group, names and scope of the resource are
+	// published with: the first one with a valid schema.
+	Base *v1alpha1.APIResourceImport
+	// Version is the version published.
+	Version string
+	// Schema is the schema published for Version, nil if no Cluster is
+	// compatible.
+	Schema *apiextensionsv1.JSONSchemaProps
+	// Subresources are those all the compatible Clusters serve.
+	Subresources *apiextensionsv1.CustomResourceSubresources
+	// Clusters are whether each Cluster is compatible, in import order.
+	Clusters []v1alpha1.NegotiatedCluster
+}
+
+// Negotiate negotiates the version and schema a resource imported from
+// several Clusters is published with.
+//
+// The version is the most recent one all the Clusters serve, else the one
+// most of them do. The schema only has the fields that the schemas of all
+// the Clusters serving that version have, with the values they all allow.
+// Clusters are considered in import order, and one that conflicts with the
+// schema negotiated so far, because it gives a field another type, or
+// requires one that the others don't have, is rejected rather than
+// narrowing it further.
+func Negotiate(imports []v1alpha1.APIResourceImport) Outcome {
+	var o Outcome
+	reasons := make([]string, len(imports))
+	schemas := make([]map[string]*apiextensionsv1.JSONSchemaProps, len(imports))
+	for i, imp := range imports {
+		schemas[i] = map[string]*apiextensionsv1.JSONSchemaProps{}
+		for _, v := range imp.Versions {
+			s := &apiextensionsv1.JSONSchemaProps{}
+			if err := json.Unmarshal(v.Schema.Raw, s); err != nil {
+				reasons[i] = fmt.Sprintf("invalid schema for version %s: %v", v.Name, err)
+				break
+			}
+			schemas[i][v.Name] = s
+		}
+		if reasons[i] != "" {
+			continue
+		}
+		if o.Base == nil {
+			o.Base = &imports[i]
+			continue
+		}
+		reasons[i] = conflict(o.Base, &imports[i])
+	}
+
+	o.Version = pickVersion(imports, reasons)
+	for i, imp := range imports {
+		if reasons[i] != "" {
+			continue
+		}
+		s, found := schemas[i][o.Version]
+		if !found {
+			reasons[i] = fmt.Sprintf("doesn't serve version %s", o.Version)
+			continue
+		}
+		if o.Schema == nil {
+			o.Schema, o.Subresources = s, subresourcesOf(imp, o.Version)
+			continue
+		}
+		merged, reason := intersect(o.Schema, s, "")
+		if reason != "" {
+			reasons[i] = reason
+			continue
+		}
+		o.Schema = merged
+		o.Subresources = commonSubresources(o.Subresources, subresourcesOf(imp, o.Version))
+	}
+
+	for i, imp := range imports {
+		o.Clusters = append(o.Clusters, v1alpha1.NegotiatedCluster{
+			Name:       imp.Cluster,
+			Compatible: reasons[i] == "",
+			Reason:     reasons[i],
+		})
+	}
+	return o
+}
+
+// conflict returns why imp can't be published like base, or "".
+func conflict(base, imp *v1alpha1.APIResourceImport) string {
+	if imp.Names.Kind != base.Names.Kind {
+		return fmt.Sprintf("kind is %s, not %s", imp.Names.Kind, base.Names.Kind)
+	}
+	if imp.Scope != base.Scope {
+		return fmt.Sprintf("scope is %s, not %s", imp.Scope, base.Scope)
+	}
+	return ""
+}
+
+// pickVersion returns the version served by the most imports that weren't
+// rejected, the most recent one on ties.
+func pickVersion(imports []v1alpha1.APIResourceImport, reasons []string) string {
+	counts := map[string]int{}
+	for i, imp := range imports {
+		if reasons[i] != "" {
+			continue
+		}
+		for _, v := range imp.Versions {
+			counts[v.Name]++
+		}
+	}
+	versions := make([]string, 0, len(counts))
+	for v := range counts {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if counts[versions[i]] != counts[versions[j]] {
+			return counts[versions[i]] > counts[versions[j]]
+		}
+		return version.CompareKubeAwareVersionStrings(versions[i], versions[j]) > 0
+	})
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[0]
+}
+
+func subresourcesOf(imp v1alpha1.APIResourceImport, version string) *apiextensionsv1.CustomResourceSubresources {
+	for _, v := range imp.Versions {
+		if v.Name == version {
+			return v.Subresources.DeepCopy()
+		}
+	}
+	return nil
+}
+
+// commonSubresources returns the subresources both a and b serve the same.
+func commonSubresources(a, b *apiextensionsv1.CustomResourceSubresources) *apiextensionsv1.CustomResourceSubresources {
+	if a == nil || b == nil {
+		return nil
+	}
+	out := a.DeepCopy()
+	if b.Status == nil {
+		out.Status = nil
+	}
+	if !equality.Semantic.DeepEqual(a.Scale, b.Scale) {
+		out.Scale = nil
+	}
+	return out
+}
+
+// intersect returns the schema of the values both a and b allow, or why
+// there's none that's useful, the schema at path being a's and b's.
+func intersect(a, b *apiextensionsv1.JSONSchemaProps, path string) (*apiextensionsv1.JSONSchemaProps, string) {
+	if a.Type != b.Type || a.XIntOrString != b.XIntOrString {
+		return nil, fmt.Sprintf("%s is %s, not %s", fieldName(path), typeOf(b), typeOf(a))
+	}
+	// An object that keeps its unknown fields allows the other's.
+	if len(a.Properties) == 0 && len(b.Properties) > 0 && preservesUnknownFields(a) {
+		return b.DeepCopy(), ""
+	}
+	if len(b.Properties) == 0 && len(a.Properties) > 0 && preservesUnknownFields(b) {
+		return a.DeepCopy(), ""
+	}
+
+	out := a.DeepCopy()
+	out.Properties = nil
+	for name, ap := range a.Properties {
+		bp, found := b.Properties[name]
+		if !found {
+			continue
+		}
+		p, reason := intersect(&ap, &bp, join(path, name))
+		if reason != "" {
+			return nil, reason
+		}
+		if out.Properties == nil {
+			out.Properties = map[string]apiextensionsv1.JSONSchemaProps{}
+		}
+		out.Properties[name] = *p
+	}
+	required := sets.NewString(a.Required...).Insert(b.Required...)
+	for _, name := range required.List() {
+		if _, found := out.Properties[name]; !found {
+			return nil, fmt.Sprintf("%s is required, but not served by all clusters", fieldName(join(path, name)))
+		}
+	}
+	out.Required = nil
+	if required.Len() > 0 {
+		out.Required = required.List()
+	}
+
+	if a.Items != nil && a.Items.Schema != nil && b.Items != nil && b.Items.Schema != nil {
+		items, reason := intersect(a.Items.Schema, b.Items.Schema, path+"[*]")
+		if reason != "" {
+			return nil, reason
+		}
+		out.Items = &apiextensionsv1.JSONSchemaPropsOrArray{Schema: items}
+	}
+	if a.AdditionalProperties != nil && a.AdditionalProperties.Schema != nil && b.AdditionalProperties != nil && b.AdditionalProperties.Schema != nil {
+		values, reason := intersect(a.AdditionalProperties.Schema, b.AdditionalProperties.Schema, path+"[*]")
+		if reason != "" {
+			return nil, reason
+		}
+		out.AdditionalProperties = &apiextensionsv1.JSONSchemaPropsOrBool{Allows: true, Schema: values}
+	}
+
+	if len(b.Enum) > 0 {
+		if len(a.Enum) == 0 {
+			out.Enum = b.DeepCopy().Enum
+		} else {
+			allowed := sets.NewString()
+			for _, v := range b.Enum {
+				allowed.Insert(string(v.Raw))
+			}
+			out.Enum = nil
+			for _, v := range a.Enum {
+				if allowed.Has(string(v.Raw)) {
+					out.Enum = append(out.Enum, *v.DeepCopy())
+				}
+			}
+			if len(out.Enum) == 0 {
+				return nil, fmt.Sprintf("%s has no allowed values in common", fieldName(path))
+			}
+		}
+	}
+	return out, ""
+}
+
+func preservesUnknownFields(s *apiextensionsv1.JSONSchemaProps) bool {
+	return s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields
+}
+
+func typeOf(s *apiextensionsv1.JSONSchemaProps) string {
+	switch {
+	case s.XIntOrString:
+		return "int-or-string"
+	case s.Type == "":
+		return "untyped"
+	}
+	return s.Type
+}
+
+func fieldName(path string) string {
+	if path == "" {
+		return "the resource"
+	}
+	return "field " + path
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
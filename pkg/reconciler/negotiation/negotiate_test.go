@@ -0,0 +1,119 @@
+package negotiation
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func imp(cluster string, versions ...string) v1alpha1.APIResourceImport {
+	i := v1alpha1.APIResourceImport{
+		Cluster: cluster,
+		Group:   "example.com",
+		Names:   apiextensionsv1.CustomResourceDefinitionNames{Plural: "widgets", Kind: "Widget"},
+		Scope:   apiextensionsv1.NamespaceScoped,
+	}
+	for j := 0; j < len(versions); j += 2 {
+		i.Versions = append(i.Versions, v1alpha1.APIResourceVersion{
+			Name:   versions[j],
+			Schema: runtime.RawExtension{Raw: []byte(versions[j+1])},
+		})
+	}
+	return i
+}
+
+func fields(s *apiextensionsv1.JSONSchemaProps) []string {
+	var names []string
+	for name := range s.Properties["spec"].Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const (
+	sizeColor    = `{"type":"object","properties":{"spec":{"type":"object","properties":{"size":{"type":"integer"},"color":{"type":"string"}}}}}`
+	sizeShape    = `{"type":"object","properties":{"spec":{"type":"object","properties":{"size":{"type":"integer"},"shape":{"type":"string"}}}}}`
+	sizeString   = `{"type":"object","properties":{"spec":{"type":"object","properties":{"size":{"type":"string"}}}}}`
+	shapeNeeded  = `{"type":"object","properties":{"spec":{"type":"object","required":["shape"],"properties":{"size":{"type":"integer"},"shape":{"type":"string"}}}}}`
+	invalidJSON  = `{"type":`
+	colorEnumRGB = `{"type":"object","properties":{"spec":{"type":"object","properties":{"color":{"type":"string","enum":["red","green"]}}}}}`
+	colorEnumCMY = `{"type":"object","properties":{"spec":{"type":"object","properties":{"color":{"type":"string","enum":["cyan"]}}}}}`
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		imports    []v1alpha1.APIResourceImport
+		version    string
+		fields     []string
+		compatible []bool
+	}{{
+		name:       "common fields",
+		imports:    []v1alpha1.APIResourceImport{imp("a", "v1", sizeColor), imp("b", "v1", sizeShape)},
+		version:    "v1",
+		fields:     []string{"size"},
+		compatible: []bool{true, true},
+	}, {
+		name:       "most recent common version",
+		imports:    []v1alpha1.APIResourceImport{imp("a", "v1beta1", sizeColor, "v1", sizeColor), imp("b", "v1beta1", sizeShape, "v1", sizeColor)},
+		version:    "v1",
+		fields:     []string{"color", "size"},
+		compatible: []bool{true, true},
+	}, {
+		name:       "version most clusters serve",
+		imports:    []v1alpha1.APIResourceImport{imp("a", "v1beta1", sizeColor), imp("b", "v1", sizeColor), imp("c", "v1beta1", sizeShape)},
+		version:    "v1beta1",
+		fields:     []string{"size"},
+		compatible: []bool{true, false, true},
+	}, {
+		name:       "conflicting type",
+		imports:    []v1alpha1.APIResourceImport{imp("a", "v1", sizeColor), imp("b", "v1", sizeString), imp("c", "v1", sizeShape)},
+		version:    "v1",
+		fields:     []string{"size"},
+		compatible: []bool{true, false, true},
+	}, {
+		name:       "required field others lack",
+		imports:    []v1alpha1.APIResourceImport{imp("a", "v1", sizeColor), imp("b", "v1", shapeNeeded)},
+		version:    "v1",
+		fields:     []string{"color", "size"},
+		compatible: []bool{true, false},
+	}, {
+		name:       "no enum values in common",
+		imports:    []v1alpha1.APIResourceImport{imp("a", "v1", colorEnumRGB), imp("b", "v1", colorEnumCMY)},
+		version:    "v1",
+		fields:     []string{"color"},
+		compatible: []bool{true, false},
+	}, {
+		name:       "invalid schema",
+		imports:    []v1alpha1.APIResourceImport{imp("a", "v1", invalidJSON), imp("b", "v1", sizeShape)},
+		version:    "v1",
+		fields:     []string{"shape", "size"},
+		compatible: []bool{false, true},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := Negotiate(tc.imports)
+			if o.Version != tc.version {
+				t.Errorf("version = %q, want %q", o.Version, tc.version)
+			}
+			if o.Schema == nil {
+				t.Fatal("no schema negotiated")
+			}
+			if got := fields(o.Schema); !reflect.DeepEqual(got, tc.fields) {
+				t.Errorf("spec fields = %v, want %v", got, tc.fields)
+			}
+			for i, cl := range o.Clusters {
+				if cl.Compatible != tc.compatible[i] {
+					t.Errorf("cluster %s compatible = %v (%s), want %v", cl.Name, cl.Compatible, cl.Reason, tc.compatible[i])
+				}
+				if !cl.Compatible && cl.Reason == "" {
+					t.Errorf("cluster %s has no reason to be incompatible", cl.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package placement
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Workload is another workload of the same kind as the one being placed, as
+// far as anti-affinity is concerned: its labels and the Cluster it's on.
+type Workload struct {
+	Labels  map[string]string
+	Cluster string
+}
+
+// HasAntiAffinity reports whether the given policy avoids the Clusters of
+// other workloads, which must then be passed to Place.
+func HasAntiAffinity(policy *v1alpha1.PlacementPolicy) bool {
+	return policy != nil && len(policy.Spec.ClusterAntiAffinity) > 0
+}
+
+// Rank orders Clusters by how much the given policy prefers them, most
+// preferred first, then by name. Preferred terms with an invalid selector,
+// which Place rejects, match nothing.
+func Rank(policy *v1alpha1.PlacementPolicy, cls []*v1alpha1.Cluster) []*v1alpha1.Cluster {
+	ranked := append([]*v1alpha1.Cluster{}, cls...)
+	scores := make(map[string]int32, len(cls))
+	for _, cl := range cls {
+		scores[cl.Name], _ = preference(policy, cl)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if si, sj := scores[ranked[i].Name], scores[ranked[j].Name]; si != sj {
+			return si > sj
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	return ranked
+}
+
+// preference returns the sum of the weights of the policy's preferred terms
+// matching the Cluster.
+func preference(policy *v1alpha1.PlacementPolicy, cl *v1alpha1.Cluster) (int32, error) {
+	if policy == nil || policy.Spec.ClusterAffinity == nil {
+		return 0, nil
+	}
+	var score int32
+	for _, term := range policy.Spec.ClusterAffinity.Preferred {
+		ok, err := selects(&term.Selector, cl.Labels)
+		if err != nil {
+			return 0, fmt.Errorf("invalid preferred clusterAffinity in PlacementPolicy %s/%s: %w", policy.Namespace, policy.Name, err)
+		}
+		if ok {
+			score += term.Weight
+		}
+	}
+	return score, nil
+}
+
+// required reports whether the Cluster matches one of the policy's required
+// cluster affinity terms, if it has any.
+func required(policy *v1alpha1.PlacementPolicy, cl *v1alpha1.Cluster) (bool, error) {
+	if policy.Spec.ClusterAffinity == nil || len(policy.Spec.ClusterAffinity.Required) == 0 {
+		return true, nil
+	}
+	for i := range policy.Spec.ClusterAffinity.Required {
+		ok, err := selects(&policy.Spec.ClusterAffinity.Required[i], cl.Labels)
+		if err != nil {
+			return false, fmt.Errorf("invalid required clusterAffinity in PlacementPolicy %s/%s: %w", policy.Namespace, policy.Name, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// avoided returns the Clusters that the workloads selected by the policy's
+// anti-affinity terms are on.
+func avoided(policy *v1alpha1.PlacementPolicy, others []Workload) (sets.String, error) {
+	clusters := sets.NewString()
+	for i := range policy.Spec.ClusterAntiAffinity {
+		term := &policy.Spec.ClusterAntiAffinity[i]
+		for _, w := range others {
+			ok, err := selects(&term.WorkloadSelector, w.Labels)
+			if err != nil {
+				return nil, fmt.Errorf("invalid clusterAntiAffinity in PlacementPolicy %s/%s: %w", policy.Namespace, policy.Name, err)
+			}
+			if ok && w.Cluster != "" {
+				clusters.Insert(w.Cluster)
+			}
+		}
+	}
+	return clusters, nil
+}
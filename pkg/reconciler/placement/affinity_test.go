@@ -0,0 +1,127 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func labeled(name string, l map[string]string) *v1alpha1.Cluster {
+	cl := cluster(name, 0)
+	cl.Labels = l
+	return cl
+}
+
+func names(cls []*v1alpha1.Cluster) []string {
+	out := make([]string, 0, len(cls))
+	for _, cl := range cls {
+		out = append(out, cl.Name)
+	}
+	return out
+}
+
+func TestPlaceAffinity(t *testing.T) {
+	one := int32(1)
+	two := int32(2)
+	cls := []*v1alpha1.Cluster{
+		labeled("a", map[string]string{"region": "eu", "tier": "gold"}),
+		labeled("b", map[string]string{"region": "eu"}),
+		labeled("c", map[string]string{"region": "us", "tier": "gold"}),
+		labeled("d", map[string]string{"region": "us"}),
+	}
+	for _, c := range []struct {
+		desc   string
+		spec   v1alpha1.PlacementPolicySpec
+		others []Workload
+		want   []string
+		msg    bool
+	}{{
+		desc: "required terms are ORed",
+		spec: v1alpha1.PlacementPolicySpec{ClusterAffinity: &v1alpha1.ClusterAffinity{
+			Required: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"tier": "gold"}},
+				{MatchLabels: map[string]string{"region": "us"}},
+			},
+		}},
+		want: []string{"a", "c", "d"},
+	}, {
+		desc: "preferred clusters are kept first",
+		spec: v1alpha1.PlacementPolicySpec{
+			MaxClusters: &two,
+			ClusterAffinity: &v1alpha1.ClusterAffinity{Preferred: []v1alpha1.PreferredClusterTerm{
+				{Weight: 10, Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}}},
+			}},
+		},
+		want: []string{"a", "c"},
+	}, {
+		desc: "preferred domains are kept first when spreading",
+		spec: v1alpha1.PlacementPolicySpec{
+			MaxClusters:       &one,
+			SpreadConstraints: []v1alpha1.SpreadConstraint{{TopologyKey: "region"}},
+			ClusterAffinity: &v1alpha1.ClusterAffinity{Preferred: []v1alpha1.PreferredClusterTerm{
+				{Weight: 10, Selector: metav1.LabelSelector{MatchLabels: map[string]string{"region": "us"}}},
+			}},
+		},
+		want: []string{"c"},
+	}, {
+		desc: "anti-affinity avoids the clusters of selected workloads",
+		spec: v1alpha1.PlacementPolicySpec{ClusterAntiAffinity: []v1alpha1.ClusterAntiAffinityTerm{
+			{WorkloadSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+		}},
+		others: []Workload{
+			{Labels: map[string]string{"app": "db"}, Cluster: "a"},
+			{Labels: map[string]string{"app": "web"}, Cluster: "b"},
+		},
+		want: []string{"b", "c", "d"},
+	}, {
+		desc: "anti-affinity avoiding every cluster is unsatisfiable",
+		spec: v1alpha1.PlacementPolicySpec{
+			ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"region": "eu"}},
+			ClusterAntiAffinity: []v1alpha1.ClusterAntiAffinityTerm{
+				{WorkloadSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+			},
+		},
+		others: []Workload{
+			{Labels: map[string]string{"app": "db"}, Cluster: "a"},
+			{Labels: map[string]string{"app": "db"}, Cluster: "b"},
+		},
+		msg: true,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			policy := &v1alpha1.PlacementPolicy{ObjectMeta: metav1.ObjectMeta{Name: "p"}, Spec: c.spec}
+			got, msg, err := Place(policy, cls, c.others)
+			if err != nil {
+				t.Fatalf("Place() failed: %v", err)
+			}
+			if (msg != "") != c.msg {
+				t.Fatalf("Place() message = %q, want one: %v", msg, c.msg)
+			}
+			if !c.msg && !reflect.DeepEqual(names(got), c.want) {
+				t.Errorf("Place() = %v, want %v", names(got), c.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	policy := &v1alpha1.PlacementPolicy{Spec: v1alpha1.PlacementPolicySpec{ClusterAffinity: &v1alpha1.ClusterAffinity{
+		Preferred: []v1alpha1.PreferredClusterTerm{
+			{Weight: 1, Selector: metav1.LabelSelector{MatchLabels: map[string]string{"region": "us"}}},
+			{Weight: 5, Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}}},
+		},
+	}}}
+	cls := []*v1alpha1.Cluster{
+		labeled("a", map[string]string{"region": "eu"}),
+		labeled("b", map[string]string{"region": "us"}),
+		labeled("c", map[string]string{"region": "eu", "tier": "gold"}),
+		labeled("d", map[string]string{"region": "us", "tier": "gold"}),
+	}
+	if got, want := names(Rank(policy, cls)), []string{"d", "c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rank() = %v, want %v", got, want)
+	}
+	if got, want := names(Rank(nil, cls)), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rank(nil) = %v, want %v", got, want)
+	}
+}
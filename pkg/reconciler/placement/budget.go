@@ -0,0 +1,60 @@
+package placement
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// MaxUnavailableAnnotation bounds how many of a workload's replicas may be
+// unavailable across its Clusters while they're moved between Clusters, as
+// a number or a percentage of its replicas, rounded down. Unset, moves
+// aren't bounded.
+const MaxUnavailableAnnotation = "experimental.kcp.dev/rebalance-max-unavailable"
+
+// Budget is how many available replicas of a workload may still be removed
+// from its Clusters, as they're moved, for as many to stay available as its
+// MaxUnavailableAnnotation asks. Replicas added elsewhere don't count until
+// they're available, so the moves wait for them.
+type Budget struct {
+	limited bool
+	left    int32
+}
+
+// BudgetFor returns the Budget of a workload, with the given annotations,
+// replicas and replicas available across its Clusters.
+func BudgetFor(annotations map[string]string, replicas, available int32) (*Budget, error) {
+	s, ok := annotations[MaxUnavailableAnnotation]
+	if !ok {
+		return &Budget{}, nil
+	}
+	v := intstr.Parse(s)
+	maxUnavailable, err := intstr.GetValueFromIntOrPercent(&v, int(replicas), false)
+	if err != nil || maxUnavailable < 0 {
+		return nil, fmt.Errorf("invalid %s %q, must be a number or a percentage of the replicas", MaxUnavailableAnnotation, s)
+	}
+	left := available - (replicas - int32(maxUnavailable))
+	if left < 0 {
+		left = 0
+	}
+	return &Budget{limited: true, left: left}, nil
+}
+
+// ScaleDown returns how many replicas a part of the workload on a Cluster,
+// with the given replicas available, may be scaled down to, from current
+// towards want, and spends the Budget for it. Unavailable replicas are taken
+// to be removed first, as the controllers of the Clusters do.
+func (b *Budget) ScaleDown(current, available, want int32) int32 {
+	if !b.limited || want >= current {
+		return want
+	}
+	cost := available - want
+	if cost <= 0 {
+		return want
+	}
+	if cost > b.left {
+		want, cost = available-b.left, b.left
+	}
+	b.left -= cost
+	return want
+}
@@ -0,0 +1,35 @@
+package placement
+
+import "testing"
+
+func TestBudget(t *testing.T) {
+	unbounded, err := BudgetFor(nil, 6, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unbounded.ScaleDown(3, 3, 0); got != 0 {
+		t.Errorf("got %d without a budget", got)
+	}
+
+	// 6 replicas, all available, of which 1 may be unavailable.
+	b, err := BudgetFor(map[string]string{MaxUnavailableAnnotation: "20%"}, 6, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.ScaleDown(3, 3, 1); got != 2 {
+		t.Errorf("expected to scale down by one replica only, got %d", got)
+	}
+	if got := b.ScaleDown(3, 3, 0); got != 3 {
+		t.Errorf("expected the budget to be spent, got %d", got)
+	}
+	if got := b.ScaleDown(3, 1, 1); got != 1 {
+		t.Errorf("expected unavailable replicas to be removed anyway, got %d", got)
+	}
+	if got := b.ScaleDown(1, 1, 3); got != 3 {
+		t.Errorf("expected scaling up to be free, got %d", got)
+	}
+
+	if _, err := BudgetFor(map[string]string{MaxUnavailableAnnotation: "one"}, 6, 6); err == nil {
+		t.Error("expected an invalid budget to be rejected")
+	}
+}
@@ -0,0 +1,179 @@
+package placement
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// capacityResources are the resources Clusters report capacity for and
+// workloads are fit by, along with extended resources.
+var capacityResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+// IsExtendedResource reports whether the named resource is an extended one,
+// advertised by the nodes' device plugins or administrators, such as
+// nvidia.com/gpu.
+func IsExtendedResource(name corev1.ResourceName) bool {
+	return strings.Contains(string(name), "/") &&
+		!strings.HasPrefix(string(name), corev1.ResourceDefaultNamespacePrefix) &&
+		!strings.HasPrefix(string(name), corev1.DefaultResourceRequestsPrefix)
+}
+
+// PodRequests returns the resources a pod with the given spec requests from
+// its node: the sum of its containers' requests, or the largest request of
+// any of its init containers if that's more, plus its overhead. Extended
+// resources are requested by their limits if they're only limited.
+func PodRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, name := range requestedResources(spec) {
+		total := requests[name]
+		for i := range spec.Containers {
+			if q, found := containerRequest(&spec.Containers[i], name); found {
+				total.Add(q)
+			}
+		}
+		for i := range spec.InitContainers {
+			if q, found := containerRequest(&spec.InitContainers[i], name); found && q.Cmp(total) > 0 {
+				total = q.DeepCopy()
+			}
+		}
+		if q, found := spec.Overhead[name]; found {
+			total.Add(q)
+		}
+		if !total.IsZero() {
+			requests[name] = total
+		}
+	}
+	return requests
+}
+
+// requestedResources returns the capacityResources and the extended
+// resources the containers of the pod spec request.
+func requestedResources(spec *corev1.PodSpec) []corev1.ResourceName {
+	names := append([]corev1.ResourceName{}, capacityResources...)
+	var extended []corev1.ResourceName
+	seen := map[corev1.ResourceName]bool{}
+	for _, containers := range [][]corev1.Container{spec.Containers, spec.InitContainers} {
+		for _, container := range containers {
+			for _, list := range []corev1.ResourceList{container.Resources.Requests, container.Resources.Limits} {
+				for name := range list {
+					if IsExtendedResource(name) && !seen[name] {
+						seen[name] = true
+						extended = append(extended, name)
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(extended, func(i, j int) bool { return extended[i] < extended[j] })
+	return append(names, extended...)
+}
+
+func containerRequest(container *corev1.Container, name corev1.ResourceName) (resource.Quantity, bool) {
+	if q, found := container.Resources.Requests[name]; found {
+		return q, true
+	}
+	if IsExtendedResource(name) {
+		// The API server defaults their requests to their limits, which
+		// must be equal, but pod templates aren't defaulted.
+		q, found := container.Resources.Limits[name]
+		return q, found
+	}
+	return resource.Quantity{}, false
+}
+
+// ReplicasThatFit returns how many replicas requesting perReplica each fit
+// on the Cluster, counting the placed replicas it already runs, and whether
+// that is limited at all. It isn't for Clusters that don't report their
+// capacity, nor for replicas that request nothing.
+func ReplicasThatFit(cl *v1alpha1.Cluster, perReplica corev1.ResourceList, placed int32) (int32, bool) {
+	if len(cl.Status.Allocatable) == 0 {
+		return 0, false
+	}
+	fit, limited := int64(math.MaxInt32), false
+	for name, request := range perReplica {
+		if request.IsZero() {
+			continue
+		}
+		free := cl.Status.Allocatable[name].DeepCopy()
+		free.Sub(cl.Status.Requested[name])
+		n := free.MilliValue() / request.MilliValue()
+		if n < 0 {
+			n = 0
+		}
+		if n < fit {
+			fit, limited = n, true
+		}
+	}
+	if !limited {
+		return 0, false
+	}
+	fit += int64(placed)
+	if fit > math.MaxInt32 {
+		fit = math.MaxInt32
+	}
+	return int32(fit), true
+}
+
+// DistributeReplicasWithin splits total replicas across clusters like
+// DistributeReplicas, except that no cluster gets more than its limit, if it
+// has one: the replicas it can't fit are split across the clusters with
+// room left instead. Replicas that fit nowhere are split across all clusters
+// by weight, to stay pending wherever capacity frees up first.
+func DistributeReplicasWithin(total int32, clusters []*v1alpha1.Cluster, limits map[string]int32) map[string]int32 {
+	out := make(map[string]int32, len(clusters))
+	for _, cl := range clusters {
+		out[cl.Name] = 0
+	}
+
+	remaining := total
+	open := append([]*v1alpha1.Cluster{}, clusters...)
+	for remaining > 0 && len(open) > 0 {
+		shares := DistributeReplicas(remaining, open)
+		var next []*v1alpha1.Cluster
+		for _, cl := range open {
+			share := shares[cl.Name]
+			if limit, limited := limits[cl.Name]; limited && out[cl.Name]+share >= limit {
+				share = limit - out[cl.Name]
+				if share < 0 {
+					share = 0
+				}
+			} else {
+				next = append(next, cl)
+			}
+			out[cl.Name] += share
+			remaining -= share
+		}
+		if len(next) == len(open) {
+			// Nobody hit their limit, so everything was placed.
+			break
+		}
+		open = next
+	}
+
+	if remaining > 0 {
+		for name, n := range DistributeReplicas(remaining, clusters) {
+			out[name] += n
+		}
+	}
+	return out
+}
+
+// Fitting returns the clusters at least one replica fits on, given each
+// cluster's limit. If none does, all of them are returned.
+func Fitting(clusters []*v1alpha1.Cluster, limits map[string]int32) []*v1alpha1.Cluster {
+	var fitting []*v1alpha1.Cluster
+	for _, cl := range clusters {
+		if limit, limited := limits[cl.Name]; !limited || limit > 0 {
+			fitting = append(fitting, cl)
+		}
+	}
+	if len(fitting) == 0 {
+		return clusters
+	}
+	return fitting
+}
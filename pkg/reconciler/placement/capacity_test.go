@@ -0,0 +1,55 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+func TestDistributeReplicasWithin(t *testing.T) {
+	abc := []*v1alpha1.Cluster{cluster("a", 1), cluster("b", 1), cluster("c", 1)}
+	for _, c := range []struct {
+		desc     string
+		total    int32
+		clusters []*v1alpha1.Cluster
+		limits   map[string]int32
+		want     map[string]int32
+	}{{
+		desc:     "no limits split like DistributeReplicas",
+		total:    6,
+		clusters: abc,
+		want:     map[string]int32{"a": 2, "b": 2, "c": 2},
+	}, {
+		desc:     "replicas over a limit go to the clusters with room",
+		total:    6,
+		clusters: abc,
+		limits:   map[string]int32{"a": 1},
+		want:     map[string]int32{"a": 1, "b": 3, "c": 2},
+	}, {
+		desc:     "replicas that fit nowhere are split by weight",
+		total:    4,
+		clusters: []*v1alpha1.Cluster{cluster("a", 1), cluster("b", 1)},
+		limits:   map[string]int32{"a": 1, "b": 1},
+		want:     map[string]int32{"a": 2, "b": 2},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			got := DistributeReplicasWithin(c.total, c.clusters, c.limits)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("DistributeReplicasWithin(%d, %v) = %v, want %v", c.total, c.limits, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFitting(t *testing.T) {
+	abc := []*v1alpha1.Cluster{cluster("a", 1), cluster("b", 1), cluster("c", 1)}
+
+	got := Fitting(abc, map[string]int32{"a": 0, "b": 2})
+	if len(got) != 2 || got[0].Name != "b" || got[1].Name != "c" {
+		t.Errorf("Fitting skipping a full cluster = %v", got)
+	}
+	if got := Fitting(abc, map[string]int32{"a": 0, "b": 0, "c": 0}); len(got) != len(abc) {
+		t.Errorf("Fitting with every cluster full returned %d clusters, want all %d", len(got), len(abc))
+	}
+}
@@ -0,0 +1,85 @@
+package placement
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+const (
+	// DryRunAnnotation, set to "true", makes the splitter only preview the
+	// placement of a workload: where it would be placed is recorded in its
+	// DecisionAnnotation, and nothing is created, changed or deleted.
+	DryRunAnnotation = "experimental.kcp.dev/dry-run"
+
+	// DecisionAnnotation records where a workload was placed, or would be
+	// in dry-run, as the JSON of a Decision.
+	DecisionAnnotation = "experimental.kcp.dev/placement-decision"
+)
+
+// DryRun reports whether the given workload annotations ask for its
+// placement to only be previewed.
+func DryRun(annotations map[string]string) bool {
+	return annotations[DryRunAnnotation] == "true"
+}
+
+// Decision is the outcome of placing a workload onto the Clusters of its
+// workspace, kept up to date in its DecisionAnnotation for tools to follow.
+type Decision struct {
+	// Replicas are the replicas placed on each Cluster, by name.
+	Replicas map[string]int32 `json:"replicas"`
+
+	// Filtered are the reasons the other Clusters were left out, by name.
+	Filtered map[string]string `json:"filtered,omitempty"`
+
+	// DryRun is set if the workload isn't placed as decided, only previewed.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// Filter records that the Clusters of before that aren't in after were left
+// out for reason, unless they already were for another.
+func (d *Decision) Filter(before, after []*v1alpha1.Cluster, reason string) {
+	kept := map[string]bool{}
+	for _, cl := range after {
+		kept[cl.Name] = true
+	}
+	for _, cl := range before {
+		if kept[cl.Name] {
+			continue
+		}
+		if d.Filtered == nil {
+			d.Filtered = map[string]string{}
+		}
+		if _, found := d.Filtered[cl.Name]; !found {
+			d.Filtered[cl.Name] = reason
+		}
+	}
+}
+
+// String returns the JSON of the Decision, with its Clusters sorted, so that
+// it only changes along with the Decision.
+func (d *Decision) String() string {
+	b, err := json.Marshal(d)
+	if err != nil {
+		// Maps of strings always marshal.
+		panic(err)
+	}
+	return string(b)
+}
+
+// FormatReplicas formats the replicas of a Decision as a comma-separated list
+// of <cluster>=<replicas>, sorted by Cluster.
+func FormatReplicas(replicas map[string]int32) string {
+	clusters := make([]string, 0, len(replicas))
+	for cl := range replicas {
+		clusters = append(clusters, cl)
+	}
+	sort.Strings(clusters)
+	for i, cl := range clusters {
+		clusters[i] = fmt.Sprintf("%s=%d", cl, replicas[cl])
+	}
+	return strings.Join(clusters, ",")
+}
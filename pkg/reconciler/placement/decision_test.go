@@ -0,0 +1,36 @@
+package placement
+
+import (
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+func TestDecision(t *testing.T) {
+	all := []*v1alpha1.Cluster{cluster("us-west", 0), cluster("us-east", 0), cluster("eu-west", 0), cluster("eu-north", 0)}
+	d := &Decision{DryRun: true}
+	d.Filter(all, all[:3], "not Ready")
+	d.Filter(all[:3], all[:1], "not allowed by the PlacementPolicy")
+	d.Filter(all, all[:1], "filtered out by the scheduler")
+	d.Replicas = map[string]int32{"us-west": 3}
+
+	want := `{"replicas":{"us-west":3},"filtered":{"eu-north":"not Ready","eu-west":"not allowed by the PlacementPolicy","us-east":"not allowed by the PlacementPolicy"},"dryRun":true}`
+	if got := d.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestFormatReplicas(t *testing.T) {
+	for _, c := range []struct {
+		replicas map[string]int32
+		want     string
+	}{
+		{nil, ""},
+		{map[string]int32{"us-east": 3}, "us-east=3"},
+		{map[string]int32{"us-west": 2, "eu-west": 0, "us-east": 3}, "eu-west=0,us-east=3,us-west=2"},
+	} {
+		if got := FormatReplicas(c.replicas); got != c.want {
+			t.Errorf("FormatReplicas(%v) = %q, want %q", c.replicas, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package placement
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+// DescheduledAnnotation holds, as a JSON object by Cluster name, the most
+// replicas of a split workload the descheduler lets stay on the Clusters it
+// found overloaded, e.g. {"us-east": 3}. The splitters place the others on
+// the workload's other Clusters, within its disruption budgets, and the
+// descheduler removes a Cluster from it once its load is low again.
+const DescheduledAnnotation = "experimental.kcp.dev/descheduled"
+
+// DescheduledFor returns the most replicas by Cluster the given workload
+// annotations let stay on the Clusters they were descheduled from.
+func DescheduledFor(annotations map[string]string) (map[string]int32, error) {
+	s, found := annotations[DescheduledAnnotation]
+	if !found {
+		return nil, nil
+	}
+	var caps map[string]int32
+	if err := json.Unmarshal([]byte(s), &caps); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", DescheduledAnnotation, err)
+	}
+	for name, n := range caps {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid %s: negative replicas %d for cluster %q", DescheduledAnnotation, n, name)
+		}
+	}
+	return caps, nil
+}
+
+// SetDescheduled records caps in the DescheduledAnnotation of annotations,
+// removing it if there are none, and returns them.
+func SetDescheduled(annotations map[string]string, caps map[string]int32) map[string]string {
+	if len(caps) == 0 {
+		delete(annotations, DescheduledAnnotation)
+		return annotations
+	}
+	data, _ := json.Marshal(caps)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[DescheduledAnnotation] = string(data)
+	return annotations
+}
+
+// Descheduled lowers limits, the most replicas each Cluster has room for,
+// to the most the descheduler lets stay on them.
+func Descheduled(limits, caps map[string]int32) {
+	for name, n := range caps {
+		if limit, limited := limits[name]; !limited || n < limit {
+			limits[name] = n
+		}
+	}
+}
+
+// Utilization returns the load of the Cluster: the highest fraction of its
+// allocatable cpu or memory that pods request, and whether it reports it.
+func Utilization(cl *v1alpha1.Cluster) (float64, bool) {
+	var highest float64
+	known := false
+	for _, name := range capacityResources {
+		allocatable, found := cl.Status.Allocatable[name]
+		if !found || allocatable.IsZero() {
+			continue
+		}
+		requested := cl.Status.Requested[name]
+		if u := float64(requested.MilliValue()) / float64(allocatable.MilliValue()); u > highest {
+			highest = u
+		}
+		known = true
+	}
+	return highest, known
+}
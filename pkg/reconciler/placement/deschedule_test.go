@@ -0,0 +1,58 @@
+package placement
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestDescheduledFor(t *testing.T) {
+	annotations := SetDescheduled(nil, map[string]int32{"a": 3, "b": 0})
+	got, err := DescheduledFor(annotations)
+	if err != nil {
+		t.Fatalf("DescheduledFor() error = %v", err)
+	}
+	if want := map[string]int32{"a": 3, "b": 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DescheduledFor() = %v, want %v", got, want)
+	}
+	if annotations = SetDescheduled(annotations, nil); len(annotations) != 0 {
+		t.Errorf("SetDescheduled() without caps left %v", annotations)
+	}
+
+	for _, s := range []string{`{"a": -1}`, `[1]`} {
+		if _, err := DescheduledFor(map[string]string{DescheduledAnnotation: s}); err == nil {
+			t.Errorf("DescheduledFor(%s) didn't fail", s)
+		}
+	}
+}
+
+func TestDescheduled(t *testing.T) {
+	limits := map[string]int32{"a": 5, "b": 1}
+	Descheduled(limits, map[string]int32{"a": 2, "b": 3, "c": 0})
+	if want := map[string]int32{"a": 2, "b": 1, "c": 0}; !reflect.DeepEqual(limits, want) {
+		t.Errorf("Descheduled() = %v, want %v", limits, want)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	cl := cluster("a", 1)
+	if _, known := Utilization(cl); known {
+		t.Error("Utilization() of a cluster not reporting its capacity is known")
+	}
+
+	cl.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	cl.Status.Requested = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("1"),
+		corev1.ResourceMemory: resource.MustParse("6Gi"),
+	}
+	u, known := Utilization(cl)
+	if !known || math.Abs(u-0.75) > 1e-9 {
+		t.Errorf("Utilization() = %v, %t, want the memory one of 0.75", u, known)
+	}
+}
@@ -0,0 +1,57 @@
+package placement
+
+import (
+	"fmt"
+	"sort"
+
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Disruptions is how many of the Clusters carrying a workload, those it has
+// available replicas on, it may still be removed from for as many to be left
+// as its ClusterDisruptionBudgets ask.
+type Disruptions struct {
+	budget   string
+	min      int
+	carrying sets.String
+}
+
+// DisruptionsFor returns the Disruptions of a workload, with the given labels
+// in namespace, carried by the given Clusters. Of the
+// ClusterDisruptionBudgets selecting it, the one with the most MinClusters
+// applies.
+func DisruptionsFor(lister clusterlisters.ClusterDisruptionBudgetLister, namespace string, workloadLabels map[string]string, carrying sets.String) (*Disruptions, error) {
+	budgets, err := lister.ClusterDisruptionBudgets(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Name < budgets[j].Name })
+
+	d := &Disruptions{carrying: carrying}
+	for _, b := range budgets {
+		ok, err := selects(b.Spec.WorkloadSelector, workloadLabels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workloadSelector in ClusterDisruptionBudget %s/%s: %w", b.Namespace, b.Name, err)
+		}
+		if ok && int(b.Spec.MinClusters) > d.min {
+			d.budget, d.min = b.Name, int(b.Spec.MinClusters)
+		}
+	}
+	return d, nil
+}
+
+// Remove reports whether the workload may be removed from the named Cluster,
+// and if so counts the Cluster as no longer carrying it. If not, it returns
+// why. Removing it from a Cluster that doesn't carry it is always allowed.
+func (d *Disruptions) Remove(cluster string) (bool, string) {
+	if !d.carrying.Has(cluster) {
+		return true, ""
+	}
+	if d.carrying.Len()-1 < d.min {
+		return false, fmt.Sprintf("ClusterDisruptionBudget %s needs the workload available on %d clusters, and it is on %d", d.budget, d.min, d.carrying.Len())
+	}
+	d.carrying.Delete(cluster)
+	return true, ""
+}
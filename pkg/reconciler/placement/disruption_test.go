@@ -0,0 +1,52 @@
+package placement
+
+import (
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDisruptions(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, b := range []*v1alpha1.ClusterDisruptionBudget{
+		{ObjectMeta: metav1.ObjectMeta{Name: "all", Namespace: "default"}, Spec: v1alpha1.ClusterDisruptionBudgetSpec{MinClusters: 1}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}, Spec: v1alpha1.ClusterDisruptionBudgetSpec{
+			WorkloadSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			MinClusters:      2,
+		}},
+	} {
+		if err := indexer.Add(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lister := clusterlisters.NewClusterDisruptionBudgetLister(indexer)
+
+	d, err := DisruptionsFor(lister, "default", map[string]string{"app": "web"}, sets.NewString("east", "west", "south"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := d.Remove("north"); !ok {
+		t.Error("expected the removal from a cluster not carrying the workload to be allowed")
+	}
+	if ok, _ := d.Remove("south"); !ok {
+		t.Error("expected the removal from one of three clusters to be allowed")
+	}
+	if ok, msg := d.Remove("west"); ok || msg == "" {
+		t.Errorf("expected the web budget to keep the workload on two clusters, got %t %q", ok, msg)
+	}
+
+	d, err = DisruptionsFor(lister, "default", map[string]string{"app": "db"}, sets.NewString("east", "west"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := d.Remove("west"); !ok {
+		t.Error("expected only the budget selecting all workloads to apply")
+	}
+	if ok, _ := d.Remove("east"); ok {
+		t.Error("expected the workload to be kept on one cluster")
+	}
+}
@@ -0,0 +1,86 @@
+package placement
+
+import (
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// FailbackAnnotation holds the RFC 3339 time at which an administrator
+	// let workloads whose PlacementPolicy fails back manually return to a
+	// Cluster. Only a time after the Cluster was Ready again counts.
+	FailbackAnnotation = "experimental.kcp.dev/failback"
+
+	// DefaultFailbackDelay is how long a Cluster must stay Ready before
+	// workloads fail back to it in the Delayed mode, unless set otherwise.
+	DefaultFailbackDelay = 5 * time.Minute
+)
+
+// FailingBack returns the Clusters of cls that a workload, already placed on
+// the given Clusters, may be placed on as the Failback of its policy says:
+// those it's on, those it was never evicted from, and those it's allowed
+// back on. It also returns how long until the next of the others is allowed,
+// for the workload to be placed again then, zero if none will be without
+// being annotated.
+func FailingBack(policy *v1alpha1.PlacementPolicy, cls []*v1alpha1.Cluster, placed sets.String, now time.Time) ([]*v1alpha1.Cluster, time.Duration) {
+	if policy == nil || policy.Spec.Failback == nil || policy.Spec.Failback.Mode == v1alpha1.FailbackImmediate {
+		return cls, 0
+	}
+	failback := policy.Spec.Failback
+	delay := DefaultFailbackDelay
+	if failback.Delay != nil {
+		delay = failback.Delay.Duration
+	}
+	var allowed []*v1alpha1.Cluster
+	var wait time.Duration
+	for _, cl := range cls {
+		since, recovered := recoveredAt(cl)
+		switch {
+		case !recovered || placed.Has(cl.Name):
+		case failback.Mode == v1alpha1.FailbackDelayed:
+			if left := since.Add(delay).Sub(now); left > 0 {
+				if wait == 0 || left < wait {
+					wait = left
+				}
+				continue
+			}
+		case failback.Mode == v1alpha1.FailbackManual:
+			approved, err := time.Parse(time.RFC3339, cl.Annotations[FailbackAnnotation])
+			if err != nil || approved.Before(since) {
+				continue
+			}
+		}
+		allowed = append(allowed, cl)
+	}
+	return allowed, wait
+}
+
+// FailsBack reports whether a pinned workload on the current Cluster should
+// move to the first, most preferred, of the allowed Clusters cls, as returned
+// by FailingBack: if its policy has a Failback and that Cluster recovered
+// from an eviction.
+func FailsBack(policy *v1alpha1.PlacementPolicy, cls []*v1alpha1.Cluster, current string) bool {
+	if policy == nil || policy.Spec.Failback == nil || len(cls) == 0 || current == "" || cls[0].Name == current {
+		return false
+	}
+	_, recovered := recoveredAt(cls[0])
+	return recovered
+}
+
+// recoveredAt returns when a Cluster that was evicted from is Ready again:
+// when the eviction was lifted, or when it last turned Ready since, as it
+// flapped.
+func recoveredAt(cl *v1alpha1.Cluster) (time.Time, bool) {
+	evicted := cl.Status.Conditions.Get(v1alpha1.ClusterConditionEvicted)
+	if evicted == nil || evicted.Status != corev1.ConditionFalse || !cl.Status.Conditions.IsReady() {
+		return time.Time{}, false
+	}
+	since := evicted.LastTransitionTime.Time
+	if ready := cl.Status.Conditions.Get(v1alpha1.ClusterConditionReady); ready.LastTransitionTime.After(since) {
+		since = ready.LastTransitionTime.Time
+	}
+	return since, true
+}
@@ -0,0 +1,73 @@
+package placement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestFailingBack(t *testing.T) {
+	now := time.Now()
+	recoveredCluster := func(name string, ago time.Duration) *v1alpha1.Cluster {
+		cl := cluster(name, 0)
+		at := metav1.NewTime(now.Add(-ago))
+		cl.Status.Conditions = v1alpha1.Conditions{
+			{Type: v1alpha1.ClusterConditionReady, Status: corev1.ConditionTrue, LastTransitionTime: at},
+			{Type: v1alpha1.ClusterConditionEvicted, Status: corev1.ConditionFalse, LastTransitionTime: at},
+		}
+		return cl
+	}
+	approved := recoveredCluster("approved", time.Minute)
+	approved.Annotations = map[string]string{FailbackAnnotation: now.Format(time.RFC3339)}
+	cls := []*v1alpha1.Cluster{cluster("healthy", 0), recoveredCluster("recent", time.Minute), recoveredCluster("old", time.Hour), approved}
+	policy := func(failback *v1alpha1.Failback) *v1alpha1.PlacementPolicy {
+		return &v1alpha1.PlacementPolicy{Spec: v1alpha1.PlacementPolicySpec{Failback: failback}}
+	}
+	for _, c := range []struct {
+		desc     string
+		policy   *v1alpha1.PlacementPolicy
+		placed   sets.String
+		want     []string
+		wantWait bool
+	}{{
+		desc: "without a policy, right away",
+		want: []string{"healthy", "recent", "old", "approved"},
+	}, {
+		desc:   "immediately",
+		policy: policy(&v1alpha1.Failback{Mode: v1alpha1.FailbackImmediate}),
+		want:   []string{"healthy", "recent", "old", "approved"},
+	}, {
+		desc:     "after the delay",
+		policy:   policy(&v1alpha1.Failback{Mode: v1alpha1.FailbackDelayed}),
+		want:     []string{"healthy", "old"},
+		wantWait: true,
+	}, {
+		desc:   "once approved",
+		policy: policy(&v1alpha1.Failback{Mode: v1alpha1.FailbackManual}),
+		want:   []string{"healthy", "approved"},
+	}, {
+		desc:   "where the workload still is",
+		policy: policy(&v1alpha1.Failback{Mode: v1alpha1.FailbackManual}),
+		placed: sets.NewString("recent"),
+		want:   []string{"healthy", "recent", "approved"},
+	}} {
+		got, wait := FailingBack(c.policy, cls, c.placed, now)
+		if !sets.NewString(names(got)...).Equal(sets.NewString(c.want...)) {
+			t.Errorf("%s: got %v, want %v", c.desc, names(got), c.want)
+		}
+		if (wait > 0) != c.wantWait || wait > DefaultFailbackDelay {
+			t.Errorf("%s: got a wait of %s", c.desc, wait)
+		}
+	}
+
+	if !FailsBack(policy(&v1alpha1.Failback{Mode: v1alpha1.FailbackImmediate}), []*v1alpha1.Cluster{recoveredCluster("old", time.Hour)}, "healthy") {
+		t.Error("expected a pinned workload to fail back to its preferred cluster")
+	}
+	if FailsBack(nil, []*v1alpha1.Cluster{recoveredCluster("old", time.Hour)}, "healthy") {
+		t.Error("expected a pinned workload to stay without a failback")
+	}
+}
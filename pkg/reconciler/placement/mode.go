@@ -0,0 +1,69 @@
+package placement
+
+import (
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+const (
+	// SchedulingModeAnnotation opts a workload out of splitting, see SchedulingMode.
+	SchedulingModeAnnotation = "experimental.kcp.dev/scheduling-mode"
+
+	// PinnedClusterAnnotation names the Cluster a pinned workload is placed
+	// on. If unset, one of the allowed Clusters is chosen and kept for as
+	// long as it stays allowed.
+	PinnedClusterAnnotation = "experimental.kcp.dev/pinned-cluster"
+)
+
+// SchedulingMode is how a workload is placed onto Clusters.
+type SchedulingMode string
+
+const (
+	// SchedulingModeSplit splits the workload across all allowed Clusters.
+	// This is the default.
+	SchedulingModeSplit SchedulingMode = "split"
+	// SchedulingModePinned places the whole workload on a single Cluster.
+	SchedulingModePinned SchedulingMode = "pinned"
+	// SchedulingModeDisabled leaves the workload alone entirely.
+	SchedulingModeDisabled SchedulingMode = "disabled"
+	// SchedulingModeOverflow places the workload's replicas on the most
+	// preferred Cluster until it has no room left for them, then spills
+	// the rest over to the next ones, as Overflow does. Only Deployments
+	// overflow; other workloads are split.
+	SchedulingModeOverflow SchedulingMode = "overflow"
+)
+
+// SchedulingModeFor returns the SchedulingMode requested by the given
+// workload annotations.
+func SchedulingModeFor(annotations map[string]string) (SchedulingMode, error) {
+	switch mode := SchedulingMode(annotations[SchedulingModeAnnotation]); mode {
+	case "", SchedulingModeSplit:
+		return SchedulingModeSplit, nil
+	case SchedulingModePinned, SchedulingModeDisabled, SchedulingModeOverflow:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown %s %q, must be one of %q, %q, %q or %q", SchedulingModeAnnotation, mode, SchedulingModeSplit, SchedulingModePinned, SchedulingModeOverflow, SchedulingModeDisabled)
+	}
+}
+
+// Pin chooses the Cluster a pinned workload is placed on, out of the allowed
+// Clusters as ordered by Rank: the requested one if set, otherwise the
+// current one if it's still allowed, otherwise the first, most preferred one. If the requested Cluster isn't
+// allowed, a message explaining why is returned instead.
+func Pin(cls []*v1alpha1.Cluster, requested, current string) (*v1alpha1.Cluster, string) {
+	if requested != "" {
+		for _, cl := range cls {
+			if cl.Name == requested {
+				return cl, ""
+			}
+		}
+		return nil, fmt.Sprintf("pinned cluster %q is not Ready or not allowed by the PlacementPolicy", requested)
+	}
+	for _, cl := range cls {
+		if cl.Name == current {
+			return cl, ""
+		}
+	}
+	return cls[0], ""
+}
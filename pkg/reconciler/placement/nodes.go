@@ -0,0 +1,58 @@
+package placement
+
+import (
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// betaArchLabel is the deprecated node label of kubernetes.io/arch, which
+// nodeSelectors may still select.
+const betaArchLabel = "beta.kubernetes.io/arch"
+
+// Satisfying returns the given Clusters whose nodes could run pods of the
+// pod spec, as their status has them: those with a node of the architecture
+// its nodeSelector selects, if any, and with each of the extended resources
+// it requests, such as GPUs. Clusters that don't report their architectures
+// or capacity aren't ruled out by them.
+func Satisfying(cls []*v1alpha1.Cluster, spec *corev1.PodSpec) []*v1alpha1.Cluster {
+	arch := spec.NodeSelector[corev1.LabelArchStable]
+	if arch == "" {
+		arch = spec.NodeSelector[betaArchLabel]
+	}
+	var extended []corev1.ResourceName
+	for name := range PodRequests(spec) {
+		if IsExtendedResource(name) {
+			extended = append(extended, name)
+		}
+	}
+
+	var satisfying []*v1alpha1.Cluster
+	for _, cl := range cls {
+		if arch != "" && len(cl.Status.Architectures) > 0 && !contains(cl.Status.Architectures, arch) {
+			continue
+		}
+		if len(cl.Status.Allocatable) > 0 && !allocates(cl, extended) {
+			continue
+		}
+		satisfying = append(satisfying, cl)
+	}
+	return satisfying
+}
+
+func allocates(cl *v1alpha1.Cluster, names []corev1.ResourceName) bool {
+	for _, name := range names {
+		if q, found := cl.Status.Allocatable[name]; !found || q.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSatisfying(t *testing.T) {
+	cluster := func(name string, gpus string, archs ...string) *v1alpha1.Cluster {
+		cl := &v1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1alpha1.ClusterStatus{
+				Allocatable:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")},
+				Architectures: archs,
+			},
+		}
+		if gpus != "" {
+			cl.Status.Allocatable["nvidia.com/gpu"] = resource.MustParse(gpus)
+		}
+		return cl
+	}
+	cls := []*v1alpha1.Cluster{
+		cluster("amd64", "", "amd64"),
+		cluster("mixed", "", "amd64", "arm64"),
+		cluster("gpu", "4", "amd64"),
+		{ObjectMeta: metav1.ObjectMeta{Name: "unreported"}},
+	}
+	gpu := corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}
+
+	for _, tc := range []struct {
+		desc string
+		spec corev1.PodSpec
+		want []string
+	}{
+		{"anywhere", corev1.PodSpec{Containers: []corev1.Container{{}}}, []string{"amd64", "mixed", "gpu", "unreported"}},
+		{"arm64", corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelArchStable: "arm64"}}, []string{"mixed", "unreported"}},
+		{"beta arch label", corev1.PodSpec{NodeSelector: map[string]string{betaArchLabel: "arm64"}}, []string{"mixed", "unreported"}},
+		{"GPU request", corev1.PodSpec{Containers: []corev1.Container{{Resources: corev1.ResourceRequirements{Requests: gpu}}}}, []string{"gpu", "unreported"}},
+		{"GPU limit", corev1.PodSpec{Containers: []corev1.Container{{Resources: corev1.ResourceRequirements{Limits: gpu}}}}, []string{"gpu", "unreported"}},
+	} {
+		var got []string
+		for _, cl := range Satisfying(cls, &tc.spec) {
+			got = append(got, cl.Name)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package placement
+
+import (
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+// Distribution is how the replicas of a split workload are distributed
+// across its Clusters, within the room they have for them.
+type Distribution struct {
+	// Overflow fills the Clusters in their order, as Overflow does.
+	Overflow bool
+	// SpreadKey, unless Overflow, spreads the replicas evenly across the
+	// values of that Cluster label, as DistributeReplicasAcross does.
+	SpreadKey string
+}
+
+// Distribute splits total replicas across clusters as d says, given each
+// cluster's limit. By default they're split by weight, as
+// DistributeReplicasWithin does.
+func (d Distribution) Distribute(total int32, clusters []*v1alpha1.Cluster, limits map[string]int32) map[string]int32 {
+	switch {
+	case d.Overflow:
+		return Overflow(total, clusters, limits)
+	case d.SpreadKey != "":
+		return DistributeReplicasAcross(total, clusters, limits, d.SpreadKey)
+	default:
+		return DistributeReplicasWithin(total, clusters, limits)
+	}
+}
+
+// Overflow places total replicas on the first of the ranked clusters, up to
+// its limit if it has one, then spills the rest over to the next, and so on.
+// The clusters that aren't needed get none. Replicas that fit nowhere stay
+// pending on the first cluster.
+func Overflow(total int32, ranked []*v1alpha1.Cluster, limits map[string]int32) map[string]int32 {
+	out := make(map[string]int32, len(ranked))
+	remaining := total
+	for _, cl := range ranked {
+		n := remaining
+		if limit, limited := limits[cl.Name]; limited && limit < n {
+			n = limit
+		}
+		if n < 0 {
+			n = 0
+		}
+		out[cl.Name] = n
+		remaining -= n
+	}
+	if remaining > 0 && len(ranked) > 0 {
+		out[ranked[0].Name] += remaining
+	}
+	return out
+}
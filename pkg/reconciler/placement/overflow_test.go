@@ -0,0 +1,27 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+func TestOverflow(t *testing.T) {
+	ranked := []*v1alpha1.Cluster{cluster("on-prem", 1), cluster("cloud-a", 1), cluster("cloud-b", 1)}
+	for _, tc := range []struct {
+		desc   string
+		total  int32
+		limits map[string]int32
+		want   map[string]int32
+	}{
+		{"fits on the primary", 4, map[string]int32{"on-prem": 6}, map[string]int32{"on-prem": 4, "cloud-a": 0, "cloud-b": 0}},
+		{"spills over in order", 8, map[string]int32{"on-prem": 6, "cloud-a": 1}, map[string]int32{"on-prem": 6, "cloud-a": 1, "cloud-b": 1}},
+		{"pending on the primary", 8, map[string]int32{"on-prem": 2, "cloud-a": 1, "cloud-b": 1}, map[string]int32{"on-prem": 6, "cloud-a": 1, "cloud-b": 1}},
+		{"unlimited primary", 8, nil, map[string]int32{"on-prem": 8, "cloud-a": 0, "cloud-b": 0}},
+	} {
+		if got := Overflow(tc.total, ranked, tc.limits); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package placement decides which Clusters split workloads are placed on, and
+// how their replicas are distributed across them.
+package placement
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ReadyClusters returns the Clusters that can currently receive workloads,
+// sorted by name so that replica assignment is stable.
+func ReadyClusters(lister clusterlisters.ClusterLister) ([]*v1alpha1.Cluster, error) {
+	all, err := lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var ready []*v1alpha1.Cluster
+	for _, cl := range all {
+		if cl.Status.Conditions.IsReady() {
+			ready = append(ready, cl)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].Name < ready[j].Name })
+	return ready, nil
+}
+
+// UnreadyClusters returns the Clusters that ReadyClusters leaves out, sorted
+// by name, so that skipping them can be reported.
+func UnreadyClusters(lister clusterlisters.ClusterLister) ([]*v1alpha1.Cluster, error) {
+	all, err := lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var unready []*v1alpha1.Cluster
+	for _, cl := range all {
+		if !cl.Status.Conditions.IsReady() {
+			unready = append(unready, cl)
+		}
+	}
+	sort.Slice(unready, func(i, j int) bool { return unready[i].Name < unready[j].Name })
+	return unready, nil
+}
+
+// GetCluster returns the named Cluster, or nil if it doesn't exist.
+func GetCluster(lister clusterlisters.ClusterLister, name string) (*v1alpha1.Cluster, error) {
+	cl, err := lister.Get(name)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return cl, err
+}
+
+// MergeMetadata sets the labels and annotations of desired on existing,
+// keeping those existing has that desired doesn't, which may have been set by
+// others.
+func MergeMetadata(existing *metav1.ObjectMeta, desired metav1.ObjectMeta) {
+	for k, v := range desired.Labels {
+		if existing.Labels == nil {
+			existing.Labels = map[string]string{}
+		}
+		existing.Labels[k] = v
+	}
+	for k, v := range desired.Annotations {
+		if existing.Annotations == nil {
+			existing.Annotations = map[string]string{}
+		}
+		existing.Annotations[k] = v
+	}
+}
+
+// Tolerated reports whether the workloads already placed on a Cluster should
+// be left there, though no new ones may be placed on it: because it isn't
+// Ready but hasn't been NotReady for long enough to be evicted from, or the
+// workload tolerates the UnreachableTaintKey taint, or because it's
+// Cordoned. Like pods on a NotReady or cordoned node, they aren't replaced
+// elsewhere in the meantime.
+func Tolerated(cl *v1alpha1.Cluster, tolerations []corev1.Toleration) bool {
+	if cl == nil {
+		return false
+	}
+	if cl.Status.Conditions.HasReady() && !cl.Status.Conditions.IsReady() {
+		return !cl.Status.Conditions.IsEvicted() || toleratesUnreachable(tolerations)
+	}
+	return Cordoned(cl, tolerations)
+}
+
+// Evicted reports whether the workloads placed on a Cluster must be moved
+// elsewhere because it's been NotReady for too long, unless they tolerate
+// the UnreachableTaintKey taint.
+func Evicted(cl *v1alpha1.Cluster, tolerations []corev1.Toleration) bool {
+	return cl != nil && !cl.Status.Conditions.IsReady() && cl.Status.Conditions.IsEvicted() && !toleratesUnreachable(tolerations)
+}
+
+// UnreachableTaint returns the taint the eviction controller sets on
+// Clusters it evicted workloads from.
+func UnreachableTaint() corev1.Taint {
+	return corev1.Taint{Key: UnreachableTaintKey, Effect: corev1.TaintEffectNoExecute}
+}
+
+func toleratesUnreachable(tolerations []corev1.Toleration) bool {
+	taint := UnreachableTaint()
+	return tolerates(tolerations, &taint)
+}
+
+// PolicyFor returns the PlacementPolicy that applies to a workload with the
+// given namespace and labels, or nil if there's none. If several policies in
+// the namespace select it, the first one by name wins.
+func PolicyFor(lister clusterlisters.PlacementPolicyLister, namespace string, workloadLabels map[string]string) (*v1alpha1.PlacementPolicy, error) {
+	policies, err := lister.PlacementPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+
+	for _, p := range policies {
+		ok, err := selects(p.Spec.WorkloadSelector, workloadLabels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workloadSelector in PlacementPolicy %s/%s: %w", p.Namespace, p.Name, err)
+		}
+		if ok {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// Place returns the Clusters, sorted by name, that the given policy allows a
+// workload to be split across, given the other workloads of the same kind
+// in its namespace for the policy's anti-affinity. If the policy can't be
+// satisfied by the given Clusters, a message explaining why is returned
+// instead.
+func Place(policy *v1alpha1.PlacementPolicy, cls []*v1alpha1.Cluster, others []Workload) ([]*v1alpha1.Cluster, string, error) {
+	if policy == nil {
+		return cls, "", nil
+	}
+	spec := policy.Spec
+
+	avoid, err := avoided(policy, others)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var candidates []*v1alpha1.Cluster
+	avoiding := false
+	for _, cl := range cls {
+		ok, err := selects(spec.ClusterSelector, cl.Labels)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid clusterSelector in PlacementPolicy %s/%s: %w", policy.Namespace, policy.Name, err)
+		}
+		if !ok || !hasTopologyKeys(cl, spec.SpreadConstraints) {
+			continue
+		}
+		if ok, err = required(policy, cl); err != nil {
+			return nil, "", err
+		} else if !ok {
+			continue
+		}
+		// Rank ignores invalid preferred terms, so reject them here.
+		if _, err := preference(policy, cl); err != nil {
+			return nil, "", err
+		}
+		if avoid.Has(cl.Name) {
+			avoiding = true
+			continue
+		}
+		candidates = append(candidates, cl)
+	}
+
+	// Preferred Clusters are the ones kept when only some of them can be.
+	candidates = Rank(policy, candidates)
+	if len(spec.SpreadConstraints) > 0 {
+		candidates = spread(policy, candidates, spec.SpreadConstraints[0].TopologyKey)
+	}
+	if spec.MaxClusters != nil && int(*spec.MaxClusters) < len(candidates) {
+		candidates = candidates[:*spec.MaxClusters]
+	}
+
+	for _, sc := range spec.SpreadConstraints {
+		if n := countDomains(candidates, sc.TopologyKey); n < int(sc.MinDomains) {
+			return nil, fmt.Sprintf("PlacementPolicy %q requires %d values of %q but only %d are available", policy.Name, sc.MinDomains, sc.TopologyKey, n), nil
+		}
+	}
+	if len(candidates) == 0 && avoiding {
+		return nil, fmt.Sprintf("PlacementPolicy %q selects only Ready clusters its clusterAntiAffinity avoids", policy.Name), nil
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Sprintf("PlacementPolicy %q selects none of the Ready clusters", policy.Name), nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Name < candidates[j].Name })
+	return candidates, "", nil
+}
+
+// spread orders ranked Clusters round-robin across the values of the given
+// label, so that truncating the result keeps as many domains as possible.
+// Domains are taken by the rank of their most preferred Cluster, then in
+// name order, and the Clusters within them in rank order.
+func spread(policy *v1alpha1.PlacementPolicy, cls []*v1alpha1.Cluster, key string) []*v1alpha1.Cluster {
+	domains := map[string][]*v1alpha1.Cluster{}
+	var values []string
+	for _, cl := range cls {
+		v := cl.Labels[key]
+		if _, ok := domains[v]; !ok {
+			values = append(values, v)
+		}
+		domains[v] = append(domains[v], cl)
+	}
+	best := make(map[string]int32, len(values))
+	for _, v := range values {
+		best[v], _ = preference(policy, domains[v][0])
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if bi, bj := best[values[i]], best[values[j]]; bi != bj {
+			return bi > bj
+		}
+		return values[i] < values[j]
+	})
+
+	out := make([]*v1alpha1.Cluster, 0, len(cls))
+	for i := 0; len(out) < len(cls); i++ {
+		for _, v := range values {
+			if i < len(domains[v]) {
+				out = append(out, domains[v][i])
+			}
+		}
+	}
+	return out
+}
+
+func countDomains(cls []*v1alpha1.Cluster, key string) int {
+	seen := map[string]bool{}
+	for _, cl := range cls {
+		seen[cl.Labels[key]] = true
+	}
+	return len(seen)
+}
+
+func hasTopologyKeys(cl *v1alpha1.Cluster, constraints []v1alpha1.SpreadConstraint) bool {
+	for _, sc := range constraints {
+		if _, ok := cl.Labels[sc.TopologyKey]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// selects reports whether the given selector matches the given labels. Unlike
+// metav1.LabelSelectorAsSelector, a nil selector matches everything.
+func selects(selector *metav1.LabelSelector, set map[string]string) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return sel.Matches(labels.Set(set)), nil
+}
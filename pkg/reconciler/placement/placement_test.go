@@ -0,0 +1,90 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPlace(t *testing.T) {
+	two := int32(2)
+	three := int32(3)
+	four := int32(4)
+	cls := []*v1alpha1.Cluster{
+		labeled("ap-1", map[string]string{"region": "ap"}),
+		labeled("bare", nil),
+		labeled("eu-1", map[string]string{"region": "eu"}),
+		labeled("eu-2", map[string]string{"region": "eu"}),
+		labeled("eu-3", map[string]string{"region": "eu"}),
+		labeled("us-1", map[string]string{"region": "us"}),
+	}
+	byRegion := func(minDomains int32) []v1alpha1.SpreadConstraint {
+		return []v1alpha1.SpreadConstraint{{TopologyKey: "region", MinDomains: minDomains}}
+	}
+	preferring := func(region string) *v1alpha1.ClusterAffinity {
+		return &v1alpha1.ClusterAffinity{Preferred: []v1alpha1.PreferredClusterTerm{
+			{Weight: 10, Selector: metav1.LabelSelector{MatchLabels: map[string]string{"region": region}}},
+		}}
+	}
+	for _, c := range []struct {
+		desc string
+		spec v1alpha1.PlacementPolicySpec
+		want []string
+		msg  bool
+	}{{
+		desc: "no constraints",
+		want: []string{"ap-1", "bare", "eu-1", "eu-2", "eu-3", "us-1"},
+	}, {
+		desc: "clusters without the topology key are left out",
+		spec: v1alpha1.PlacementPolicySpec{SpreadConstraints: byRegion(0)},
+		want: []string{"ap-1", "eu-1", "eu-2", "eu-3", "us-1"},
+	}, {
+		desc: "spreads across domains before truncating",
+		spec: v1alpha1.PlacementPolicySpec{MaxClusters: &three, SpreadConstraints: byRegion(3)},
+		want: []string{"ap-1", "eu-1", "us-1"},
+	}, {
+		desc: "takes a second cluster of a domain once each has one",
+		spec: v1alpha1.PlacementPolicySpec{MaxClusters: &four, SpreadConstraints: byRegion(3)},
+		want: []string{"ap-1", "eu-1", "eu-2", "us-1"},
+	}, {
+		desc: "MinDomains not met",
+		spec: v1alpha1.PlacementPolicySpec{SpreadConstraints: byRegion(4)},
+		msg:  true,
+	}, {
+		desc: "MinDomains not met once truncated",
+		spec: v1alpha1.PlacementPolicySpec{MaxClusters: &two, SpreadConstraints: byRegion(3)},
+		msg:  true,
+	}, {
+		desc: "MaxClusters truncates in name order",
+		spec: v1alpha1.PlacementPolicySpec{MaxClusters: &two},
+		want: []string{"ap-1", "bare"},
+	}, {
+		desc: "MaxClusters keeps preferred clusters first",
+		spec: v1alpha1.PlacementPolicySpec{MaxClusters: &two, ClusterAffinity: preferring("eu")},
+		want: []string{"eu-1", "eu-2"},
+	}, {
+		desc: "MaxClusters keeps preferred domains first, then the others in name order",
+		spec: v1alpha1.PlacementPolicySpec{MaxClusters: &two, SpreadConstraints: byRegion(0), ClusterAffinity: preferring("us")},
+		want: []string{"ap-1", "us-1"},
+	}, {
+		desc: "MaxClusters above the clusters available",
+		spec: v1alpha1.PlacementPolicySpec{MaxClusters: &three, ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"region": "us"}}},
+		want: []string{"us-1"},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			policy := &v1alpha1.PlacementPolicy{ObjectMeta: metav1.ObjectMeta{Name: "p"}, Spec: c.spec}
+			got, msg, err := Place(policy, cls, nil)
+			if err != nil {
+				t.Fatalf("Place() failed: %v", err)
+			}
+			if (msg != "") != c.msg {
+				t.Fatalf("Place() message = %q, want one: %v", msg, c.msg)
+			}
+			if !c.msg && !reflect.DeepEqual(names(got), c.want) {
+				t.Errorf("Place() = %v, want %v", names(got), c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package placement
+
+import (
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+// DistributeReplicas splits total replicas across clusters proportionally to
+// their weights, using the largest remainder method: each cluster first gets
+// the floor of its exact share, then the replicas left over go one each to the
+// clusters with the largest fractional remainders. Ties are broken by cluster
+// name, so the result only depends on the inputs and not on list order.
+func DistributeReplicas(total int32, clusters []*v1alpha1.Cluster) map[string]int32 {
+	out := make(map[string]int32, len(clusters))
+	if len(clusters) == 0 {
+		return out
+	}
+
+	var sum int64
+	for _, cl := range clusters {
+		sum += int64(cl.EffectiveWeight())
+	}
+
+	type share struct {
+		name      string
+		remainder int64
+	}
+	shares := make([]share, 0, len(clusters))
+	assigned := int32(0)
+	for _, cl := range clusters {
+		exact := int64(total) * int64(cl.EffectiveWeight())
+		out[cl.Name] = int32(exact / sum)
+		assigned += out[cl.Name]
+		shares = append(shares, share{name: cl.Name, remainder: exact % sum})
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].remainder != shares[j].remainder {
+			return shares[i].remainder > shares[j].remainder
+		}
+		return shares[i].name < shares[j].name
+	})
+	for i := int32(0); i < total-assigned; i++ {
+		out[shares[i].name]++
+	}
+	return out
+}
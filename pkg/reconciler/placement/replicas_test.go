@@ -0,0 +1,94 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func cluster(name string, weight int32) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1alpha1.ClusterSpec{Weight: weight},
+	}
+}
+
+func TestDistributeReplicas(t *testing.T) {
+	for _, c := range []struct {
+		desc     string
+		total    int32
+		clusters []*v1alpha1.Cluster
+		want     map[string]int32
+	}{{
+		desc:     "no clusters",
+		total:    3,
+		clusters: nil,
+		want:     map[string]int32{},
+	}, {
+		desc:     "unweighted clusters split evenly",
+		total:    6,
+		clusters: []*v1alpha1.Cluster{cluster("a", 0), cluster("b", 0), cluster("c", 0)},
+		want:     map[string]int32{"a": 2, "b": 2, "c": 2},
+	}, {
+		desc:     "remainder goes to clusters in name order",
+		total:    5,
+		clusters: []*v1alpha1.Cluster{cluster("c", 0), cluster("b", 0), cluster("a", 0)},
+		want:     map[string]int32{"a": 2, "b": 2, "c": 1},
+	}, {
+		desc:     "proportional to weights",
+		total:    10,
+		clusters: []*v1alpha1.Cluster{cluster("a", 3), cluster("b", 1), cluster("c", 1)},
+		want:     map[string]int32{"a": 6, "b": 2, "c": 2},
+	}, {
+		desc:     "largest remainder wins",
+		total:    7,
+		clusters: []*v1alpha1.Cluster{cluster("a", 2), cluster("b", 1)},
+		// exact shares are 4.67 and 2.33
+		want: map[string]int32{"a": 5, "b": 2},
+	}, {
+		desc:     "fewer replicas than clusters",
+		total:    1,
+		clusters: []*v1alpha1.Cluster{cluster("a", 1), cluster("b", 4)},
+		want:     map[string]int32{"a": 0, "b": 1},
+	}, {
+		desc:     "zero replicas",
+		total:    0,
+		clusters: []*v1alpha1.Cluster{cluster("a", 1), cluster("b", 1)},
+		want:     map[string]int32{"a": 0, "b": 0},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			got := DistributeReplicas(c.total, c.clusters)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("DistributeReplicas(%d) = %v, want %v", c.total, got, c.want)
+			}
+			var sum int32
+			for _, n := range got {
+				sum += n
+			}
+			if len(c.clusters) > 0 && sum != c.total {
+				t.Errorf("DistributeReplicas(%d) assigned %d replicas", c.total, sum)
+			}
+		})
+	}
+}
+
+func TestDistributeReplicasOnWeightChange(t *testing.T) {
+	before := DistributeReplicas(9, []*v1alpha1.Cluster{cluster("a", 1), cluster("b", 1), cluster("c", 1)})
+	if want := map[string]int32{"a": 3, "b": 3, "c": 3}; !reflect.DeepEqual(before, want) {
+		t.Fatalf("before weight change: got %v, want %v", before, want)
+	}
+
+	// Doubling a's weight moves replicas from b and c towards a.
+	after := DistributeReplicas(9, []*v1alpha1.Cluster{cluster("a", 2), cluster("b", 1), cluster("c", 1)})
+	if want := map[string]int32{"a": 5, "b": 2, "c": 2}; !reflect.DeepEqual(after, want) {
+		t.Errorf("after weight change: got %v, want %v", after, want)
+	}
+
+	// The same weights always produce the same placement, whatever the list order.
+	again := DistributeReplicas(9, []*v1alpha1.Cluster{cluster("c", 1), cluster("a", 2), cluster("b", 1)})
+	if !reflect.DeepEqual(after, again) {
+		t.Errorf("placement depends on list order: %v vs %v", after, again)
+	}
+}
@@ -0,0 +1,32 @@
+package placement
+
+import (
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Syncing returns the given Clusters whose syncer syncs the resource, as
+// their status lists, and those that list none yet.
+func Syncing(cls []*v1alpha1.Cluster, resource schema.GroupResource) []*v1alpha1.Cluster {
+	var syncing []*v1alpha1.Cluster
+	for _, cl := range cls {
+		if Syncs(cl, resource) {
+			syncing = append(syncing, cl)
+		}
+	}
+	return syncing
+}
+
+// Syncs reports whether the Cluster's syncer syncs the resource, named in
+// its status with or without its group.
+func Syncs(cl *v1alpha1.Cluster, resource schema.GroupResource) bool {
+	if len(cl.Status.SyncedResources) == 0 {
+		return true
+	}
+	for _, r := range cl.Status.SyncedResources {
+		if r == resource.Resource || r == resource.String() {
+			return true
+		}
+	}
+	return false
+}
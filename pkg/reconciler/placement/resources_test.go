@@ -0,0 +1,48 @@
+package placement
+
+import (
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSyncing(t *testing.T) {
+	cluster := func(name string, resources ...string) *v1alpha1.Cluster {
+		return &v1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     v1alpha1.ClusterStatus{SyncedResources: resources},
+		}
+	}
+	cls := []*v1alpha1.Cluster{
+		cluster("new"),
+		cluster("deployments", "deployments", "services"),
+		cluster("qualified", "deployments.apps"),
+		cluster("services", "services"),
+	}
+
+	for _, tc := range []struct {
+		resource schema.GroupResource
+		want     []string
+	}{
+		{schema.GroupResource{Group: "apps", Resource: "deployments"}, []string{"new", "deployments", "qualified"}},
+		{schema.GroupResource{Resource: "services"}, []string{"new", "deployments", "services"}},
+		{schema.GroupResource{Group: "batch", Resource: "jobs"}, []string{"new"}},
+	} {
+		var got []string
+		for _, cl := range Syncing(cls, tc.resource) {
+			got = append(got, cl.Name)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.resource, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: got %v, want %v", tc.resource, got, tc.want)
+				break
+			}
+		}
+	}
+}
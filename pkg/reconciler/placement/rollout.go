@@ -0,0 +1,108 @@
+package placement
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// RolloutStrategyAnnotation is how a workload rolls the changes of its
+	// pod template out to its Clusters, see RolloutStrategy.
+	RolloutStrategyAnnotation = "experimental.kcp.dev/rollout-strategy"
+
+	// RolloutMaxClustersAnnotation is how many Clusters a workload rolled
+	// out cluster by cluster is rolled out to at a time, 1 by default.
+	RolloutMaxClustersAnnotation = "experimental.kcp.dev/rollout-max-clusters"
+
+	// RolloutOrderAnnotation holds the comma-separated names of the Clusters
+	// a workload rolled out cluster by cluster is rolled out to first, in
+	// that order, such as canary Clusters. The others follow by name.
+	RolloutOrderAnnotation = "experimental.kcp.dev/rollout-order"
+)
+
+// RolloutStrategy is how a workload rolls the changes of its pod template out
+// to its Clusters.
+type RolloutStrategy string
+
+const (
+	// RolloutStrategyAll rolls changes out to all Clusters at once. This
+	// is the default.
+	RolloutStrategyAll RolloutStrategy = "all"
+	// RolloutStrategyClusterByCluster rolls changes out to a few Clusters
+	// at a time, moving on to the next ones once the workload is rolled
+	// out and available on them.
+	RolloutStrategyClusterByCluster RolloutStrategy = "cluster-by-cluster"
+)
+
+// Rollout is the rollout requested by a workload.
+type Rollout struct {
+	Strategy RolloutStrategy
+	// MaxClusters is how many Clusters are rolled out to at a time.
+	MaxClusters int
+	// Order lists the Clusters to roll out to first.
+	Order []string
+}
+
+// RolloutFor returns the Rollout requested by the given workload annotations.
+func RolloutFor(annotations map[string]string) (Rollout, error) {
+	r := Rollout{Strategy: RolloutStrategyAll, MaxClusters: 1}
+	switch strategy := RolloutStrategy(annotations[RolloutStrategyAnnotation]); strategy {
+	case "", RolloutStrategyAll:
+		return r, nil
+	case RolloutStrategyClusterByCluster:
+		r.Strategy = strategy
+	default:
+		return Rollout{}, fmt.Errorf("unknown %s %q, must be one of %q or %q", RolloutStrategyAnnotation, strategy, RolloutStrategyAll, RolloutStrategyClusterByCluster)
+	}
+	if s, ok := annotations[RolloutMaxClustersAnnotation]; ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return Rollout{}, fmt.Errorf("invalid %s %q, must be a positive number", RolloutMaxClustersAnnotation, s)
+		}
+		r.MaxClusters = n
+	}
+	for _, name := range strings.Split(annotations[RolloutOrderAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			r.Order = append(r.Order, name)
+		}
+	}
+	return r, nil
+}
+
+// Wave returns the Clusters, out of those whose workload is outdated, that
+// should be rolled out to now, given how many are still being rolled out
+// to: all of them, unless rolling out cluster by cluster, in which case as
+// many as MaxClusters allows, in Order then by name.
+func (r Rollout) Wave(outdated []string, rolling int) []string {
+	if r.Strategy != RolloutStrategyClusterByCluster {
+		return outdated
+	}
+	rank := make(map[string]int, len(r.Order))
+	for i, name := range r.Order {
+		if _, found := rank[name]; !found {
+			rank[name] = i
+		}
+	}
+	ordered := append([]string{}, outdated...)
+	sort.Slice(ordered, func(i, j int) bool {
+		ri, oki := rank[ordered[i]]
+		rj, okj := rank[ordered[j]]
+		if oki != okj {
+			return oki
+		}
+		if oki && ri != rj {
+			return ri < rj
+		}
+		return ordered[i] < ordered[j]
+	})
+	n := r.MaxClusters - rolling
+	if n < 0 {
+		n = 0
+	}
+	if n > len(ordered) {
+		n = len(ordered)
+	}
+	return ordered[:n]
+}
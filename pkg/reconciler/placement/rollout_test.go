@@ -0,0 +1,70 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRolloutFor(t *testing.T) {
+	for _, c := range []struct {
+		annotations map[string]string
+		want        Rollout
+		wantErr     bool
+	}{{
+		want: Rollout{Strategy: RolloutStrategyAll, MaxClusters: 1},
+	}, {
+		annotations: map[string]string{RolloutStrategyAnnotation: "cluster-by-cluster"},
+		want:        Rollout{Strategy: RolloutStrategyClusterByCluster, MaxClusters: 1},
+	}, {
+		annotations: map[string]string{
+			RolloutStrategyAnnotation:    "cluster-by-cluster",
+			RolloutMaxClustersAnnotation: "2",
+			RolloutOrderAnnotation:       "canary, us-east1",
+		},
+		want: Rollout{Strategy: RolloutStrategyClusterByCluster, MaxClusters: 2, Order: []string{"canary", "us-east1"}},
+	}, {
+		annotations: map[string]string{RolloutStrategyAnnotation: "blue-green"},
+		wantErr:     true,
+	}, {
+		annotations: map[string]string{RolloutStrategyAnnotation: "cluster-by-cluster", RolloutMaxClustersAnnotation: "0"},
+		wantErr:     true,
+	}} {
+		got, err := RolloutFor(c.annotations)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%v: got error %v", c.annotations, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %+v, want %+v", c.annotations, got, c.want)
+		}
+	}
+}
+
+func TestWave(t *testing.T) {
+	outdated := []string{"c", "b", "canary", "a"}
+	for _, c := range []struct {
+		desc    string
+		rollout Rollout
+		rolling int
+		want    []string
+	}{{
+		desc:    "everything at once",
+		rollout: Rollout{Strategy: RolloutStrategyAll, MaxClusters: 1},
+		want:    outdated,
+	}, {
+		desc:    "the canary first",
+		rollout: Rollout{Strategy: RolloutStrategyClusterByCluster, MaxClusters: 1, Order: []string{"canary"}},
+		want:    []string{"canary"},
+	}, {
+		desc:    "then by name",
+		rollout: Rollout{Strategy: RolloutStrategyClusterByCluster, MaxClusters: 3, Order: []string{"canary"}},
+		want:    []string{"canary", "a", "b"},
+	}, {
+		desc:    "waiting for those being rolled out to",
+		rollout: Rollout{Strategy: RolloutStrategyClusterByCluster, MaxClusters: 1},
+		rolling: 1,
+	}} {
+		if got := c.rollout.Wave(outdated, c.rolling); len(got) != len(c.want) || len(got) > 0 && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.desc, got, c.want)
+		}
+	}
+}
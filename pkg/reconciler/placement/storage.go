@@ -0,0 +1,110 @@
+package placement
+
+import (
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+)
+
+// Claim is a volume claim of the pod template of a workload.
+type Claim struct {
+	// Name is that of the PersistentVolumeClaim.
+	Name string
+	// StorageClass is the name of the claim's StorageClass. Nil means the
+	// default one of each Cluster, and empty a pre-provisioned volume.
+	StorageClass *string
+	// AccessModes are those the claim's volume must support.
+	AccessModes []corev1.PersistentVolumeAccessMode
+}
+
+// ClaimsOf returns the volume claims of a pod spec, those of its
+// PersistentVolumeClaim volumes as lister has them. If one isn't found, a
+// message naming it is returned instead.
+func ClaimsOf(spec *corev1.PodSpec, lister corev1lister.PersistentVolumeClaimNamespaceLister) ([]Claim, string, error) {
+	var claims []Claim
+	for _, v := range spec.Volumes {
+		if v.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc, err := lister.Get(v.PersistentVolumeClaim.ClaimName)
+		if errors.IsNotFound(err) {
+			return nil, fmt.Sprintf("PersistentVolumeClaim %s not found", v.PersistentVolumeClaim.ClaimName), nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		claims = append(claims, Claim{
+			Name:         pvc.Name,
+			StorageClass: pvc.Spec.StorageClassName,
+			AccessModes:  pvc.Spec.AccessModes,
+		})
+	}
+	return claims, "", nil
+}
+
+// Storing returns the given Clusters that, for each claim, have a
+// StorageClass of its name, or a default one, whose volumes support its
+// access modes, as their status lists. Claims of pre-provisioned volumes
+// rule none out.
+func Storing(cls []*v1alpha1.Cluster, claims []Claim) []*v1alpha1.Cluster {
+	var storing []*v1alpha1.Cluster
+	for _, cl := range cls {
+		if stores(cl, claims) {
+			storing = append(storing, cl)
+		}
+	}
+	return storing
+}
+
+func stores(cl *v1alpha1.Cluster, claims []Claim) bool {
+	for _, claim := range claims {
+		if claim.StorageClass != nil && *claim.StorageClass == "" {
+			continue
+		}
+		sc := storageClass(cl, claim.StorageClass)
+		if sc == nil {
+			return false
+		}
+		for _, mode := range claim.AccessModes {
+			if !hasAccessMode(sc.AccessModes, mode) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// storageClass returns the StorageClass of the Cluster of the given name, or
+// its default one if nil.
+func storageClass(cl *v1alpha1.Cluster, name *string) *v1alpha1.ClusterStorageClass {
+	for i, sc := range cl.Status.StorageClasses {
+		if name == nil && sc.Default || name != nil && sc.Name == *name {
+			return &cl.Status.StorageClasses[i]
+		}
+	}
+	return nil
+}
+
+func hasAccessMode(modes []corev1.PersistentVolumeAccessMode, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Unshared returns the first of the claims that every replica mounts but
+// that can't be written from several nodes, for which the replicas must
+// all run on the same Cluster, if any.
+func Unshared(claims []Claim) (Claim, bool) {
+	for _, claim := range claims {
+		if !hasAccessMode(claim.AccessModes, corev1.ReadWriteMany) {
+			return claim, true
+		}
+	}
+	return Claim{}, false
+}
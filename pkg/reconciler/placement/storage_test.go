@@ -0,0 +1,60 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStoring(t *testing.T) {
+	rwo := []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	rwx := []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadWriteMany}
+	cluster := func(name string, classes ...v1alpha1.ClusterStorageClass) *v1alpha1.Cluster {
+		return &v1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     v1alpha1.ClusterStatus{StorageClasses: classes},
+		}
+	}
+	cls := []*v1alpha1.Cluster{
+		cluster("none"),
+		cluster("block", v1alpha1.ClusterStorageClass{Name: "ssd", Default: true, AccessModes: rwo}),
+		cluster("shared", v1alpha1.ClusterStorageClass{Name: "ssd", AccessModes: rwo}, v1alpha1.ClusterStorageClass{Name: "nfs", Default: true, AccessModes: rwx}),
+	}
+	name := func(s string) *string { return &s }
+
+	for _, tc := range []struct {
+		desc  string
+		claim Claim
+		want  []string
+	}{
+		{"default class", Claim{Name: "data", AccessModes: rwo[:1]}, []string{"block", "shared"}},
+		{"named class", Claim{Name: "data", StorageClass: name("ssd"), AccessModes: rwo[:1]}, []string{"block", "shared"}},
+		{"ReadWriteMany", Claim{Name: "data", AccessModes: rwx[1:]}, []string{"shared"}},
+		{"unknown class", Claim{Name: "data", StorageClass: name("tape")}, nil},
+		{"pre-provisioned", Claim{Name: "data", StorageClass: name("")}, []string{"none", "block", "shared"}},
+	} {
+		var got []string
+		for _, cl := range Storing(cls, []Claim{tc.claim}) {
+			got = append(got, cl.Name)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestUnshared(t *testing.T) {
+	claims := []Claim{
+		{Name: "shared", AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}},
+	}
+	if claim, unshared := Unshared(claims); unshared {
+		t.Errorf("got %s unshared", claim.Name)
+	}
+	claims = append(claims, Claim{Name: "data", AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}})
+	if claim, unshared := Unshared(claims); !unshared || claim.Name != "data" {
+		t.Errorf("got %s unshared, want data", claim.Name)
+	}
+}
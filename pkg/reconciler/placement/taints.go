@@ -0,0 +1,134 @@
+package placement
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// TolerationsAnnotation holds the JSON list of corev1.Tolerations of a
+	// workload for the taints of Clusters.
+	TolerationsAnnotation = "experimental.kcp.dev/cluster-tolerations"
+
+	// UnreachableTaintKey is the key of the NoExecute taint set on Clusters
+	// that were evicted from. Workloads tolerating it stay on them.
+	UnreachableTaintKey = "experimental.kcp.dev/unreachable"
+
+	// UnschedulableTaintKey is the key of the NoSchedule taint that
+	// unschedulable Clusters are treated as having, the one they get once
+	// converted to v1beta1.
+	UnschedulableTaintKey = v1beta1.UnschedulableTaintKey
+
+	// DrainTaintKey is the key of the NoExecute taint set on Clusters being
+	// drained. Workloads tolerating it aren't moved off them.
+	DrainTaintKey = "experimental.kcp.dev/drain"
+)
+
+// DrainTaint returns the taint set on Clusters being drained.
+func DrainTaint() corev1.Taint {
+	return corev1.Taint{Key: DrainTaintKey, Effect: corev1.TaintEffectNoExecute}
+}
+
+// Draining reports whether the given Cluster is being drained.
+func Draining(cl *v1alpha1.Cluster) bool {
+	drain := DrainTaint()
+	for i := range cl.Spec.Taints {
+		if cl.Spec.Taints[i].MatchTaint(&drain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Drained reports whether a workload, with the given annotations, doesn't
+// have to be moved off a Cluster being drained anymore: because it
+// tolerates the drain, or it's scaled to zero.
+func Drained(annotations map[string]string, replicas *int32) bool {
+	if replicas != nil && *replicas == 0 {
+		return true
+	}
+	// Workloads with invalid tolerations aren't placed at all.
+	tolerations, _ := TolerationsFor(annotations)
+	drain := DrainTaint()
+	return tolerates(tolerations, &drain)
+}
+
+// TolerationsFor returns the Cluster tolerations requested by the given
+// workload annotations.
+func TolerationsFor(annotations map[string]string) ([]corev1.Toleration, error) {
+	s, found := annotations[TolerationsAnnotation]
+	if !found {
+		return nil, nil
+	}
+	var tolerations []corev1.Toleration
+	if err := json.Unmarshal([]byte(s), &tolerations); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", TolerationsAnnotation, err)
+	}
+	for _, t := range tolerations {
+		switch t.Operator {
+		case "", corev1.TolerationOpEqual, corev1.TolerationOpExists:
+		default:
+			return nil, fmt.Errorf("invalid %s: unknown operator %q", TolerationsAnnotation, t.Operator)
+		}
+	}
+	return tolerations, nil
+}
+
+// Untainted returns the Clusters whose NoSchedule and NoExecute taints are
+// all tolerated, in the same order. Clusters with a PreferNoSchedule taint
+// that isn't tolerated are only kept if none of the others is free of them.
+func Untainted(cls []*v1alpha1.Cluster, tolerations []corev1.Toleration) []*v1alpha1.Cluster {
+	var allowed, preferred []*v1alpha1.Cluster
+	for _, cl := range cls {
+		if untolerated(cl, tolerations, corev1.TaintEffectNoSchedule) || untolerated(cl, tolerations, corev1.TaintEffectNoExecute) {
+			continue
+		}
+		allowed = append(allowed, cl)
+		if !untolerated(cl, tolerations, corev1.TaintEffectPreferNoSchedule) {
+			preferred = append(preferred, cl)
+		}
+	}
+	if len(preferred) > 0 {
+		return preferred
+	}
+	return allowed
+}
+
+// Cordoned reports whether the given Cluster has a NoSchedule taint that
+// isn't tolerated, but no such NoExecute one: workloads already there stay,
+// but no new ones are placed.
+func Cordoned(cl *v1alpha1.Cluster, tolerations []corev1.Toleration) bool {
+	return cl != nil && untolerated(cl, tolerations, corev1.TaintEffectNoSchedule) && !untolerated(cl, tolerations, corev1.TaintEffectNoExecute)
+}
+
+// untolerated reports whether the Cluster has a taint with the given effect
+// that none of the tolerations tolerates.
+func untolerated(cl *v1alpha1.Cluster, tolerations []corev1.Toleration, effect corev1.TaintEffect) bool {
+	taints := cl.Spec.Taints
+	if cl.Spec.Unschedulable {
+		taints = append(taints[:len(taints):len(taints)], corev1.Taint{Key: UnschedulableTaintKey, Effect: corev1.TaintEffectNoSchedule})
+	}
+	for i := range taints {
+		taint := &taints[i]
+		if taint.Effect != effect {
+			continue
+		}
+		if !tolerates(tolerations, taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func tolerates(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func tainted(name string, taints ...corev1.Taint) *v1alpha1.Cluster {
+	cl := cluster(name, 0)
+	cl.Spec.Taints = taints
+	return cl
+}
+
+func TestUntainted(t *testing.T) {
+	maintenance := corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}
+	draining := corev1.Taint{Key: "draining", Effect: corev1.TaintEffectNoExecute}
+	spot := corev1.Taint{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule}
+	for _, c := range []struct {
+		desc        string
+		clusters    []*v1alpha1.Cluster
+		tolerations []corev1.Toleration
+		want        []string
+	}{{
+		desc:     "NoSchedule and NoExecute taints keep workloads off",
+		clusters: []*v1alpha1.Cluster{tainted("a"), tainted("b", maintenance), tainted("c", draining)},
+		want:     []string{"a"},
+	}, {
+		desc:        "tolerated taints don't",
+		clusters:    []*v1alpha1.Cluster{tainted("a"), tainted("b", maintenance), tainted("c", draining)},
+		tolerations: []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists}},
+		want:        []string{"a", "b"},
+	}, {
+		desc:     "PreferNoSchedule taints are avoided while there are others",
+		clusters: []*v1alpha1.Cluster{tainted("a", spot), tainted("b")},
+		want:     []string{"b"},
+	}, {
+		desc:     "PreferNoSchedule taints are used if there are no others",
+		clusters: []*v1alpha1.Cluster{tainted("a", spot), tainted("b", spot, maintenance)},
+		want:     []string{"a"},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := names(Untainted(c.clusters, c.tolerations)); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Untainted() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestToleratedAndEvicted(t *testing.T) {
+	cordoned := tainted("a", corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule})
+	cordoned.Status.Conditions.SetReady(corev1.ConditionTrue, "", "")
+	if !Tolerated(cordoned, nil) {
+		t.Error("workloads on a cordoned cluster should be left there")
+	}
+	if Tolerated(cordoned, []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists}}) {
+		t.Error("workloads tolerating the cordon should be placed as usual")
+	}
+
+	evicted := cluster("b", 0)
+	evicted.Status.Conditions.SetReady(corev1.ConditionFalse, "", "")
+	evicted.Status.Conditions.Set(v1alpha1.ClusterConditionEvicted, corev1.ConditionTrue, "", "")
+	if !Evicted(evicted, nil) || Tolerated(evicted, nil) {
+		t.Error("workloads on an evicted cluster should be moved")
+	}
+	stay := []corev1.Toleration{{Key: UnreachableTaintKey, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute}}
+	if Evicted(evicted, stay) || !Tolerated(evicted, stay) {
+		t.Error("workloads tolerating the unreachable taint should stay on an evicted cluster")
+	}
+}
+
+func TestTolerationsFor(t *testing.T) {
+	got, err := TolerationsFor(map[string]string{TolerationsAnnotation: `[{"key": "maintenance", "operator": "Exists"}]`})
+	if err != nil {
+		t.Fatalf("TolerationsFor() failed: %v", err)
+	}
+	if want := []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TolerationsFor() = %v, want %v", got, want)
+	}
+	for _, s := range []string{`{}`, `[{"key": "a", "operator": "Like"}]`} {
+		if _, err := TolerationsFor(map[string]string{TolerationsAnnotation: s}); err == nil {
+			t.Errorf("TolerationsFor(%s) should have failed", s)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package placement
+
+import (
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The topology labels of Clusters, for spreadConstraints and selectors to
+// use. The Cluster Controller sets those that aren't set already from the
+// labels and provider IDs of the clusters' nodes.
+const (
+	// RegionLabel is the region of the Cluster's nodes, if they're all in
+	// the same one.
+	RegionLabel = corev1.LabelZoneRegionStable
+	// ZoneLabel is the zone of the Cluster's nodes, if they're all in the
+	// same one.
+	ZoneLabel = corev1.LabelZoneFailureDomainStable
+	// ProviderLabel is the cloud provider of the Cluster's nodes, such as
+	// aws, azure or gce, as the scheme of their provider IDs has it.
+	ProviderLabel = "experimental.kcp.dev/provider"
+)
+
+// ReplicaSpreadKey returns the topology key of the first of the policy's
+// spreadConstraints that spreads replicas, if any.
+func ReplicaSpreadKey(policy *v1alpha1.PlacementPolicy) string {
+	if policy == nil {
+		return ""
+	}
+	for _, sc := range policy.Spec.SpreadConstraints {
+		if sc.SpreadReplicas {
+			return sc.TopologyKey
+		}
+	}
+	return ""
+}
+
+// DistributeReplicasAcross splits total replicas evenly across the domains
+// of the clusters, their values of the given label, and then the share of
+// each domain across its clusters like DistributeReplicasWithin. Extra
+// replicas go to the domains first in name order. Replicas that don't fit
+// in a domain stay pending there rather than move to another one, so that
+// the workload stays spread.
+func DistributeReplicasAcross(total int32, clusters []*v1alpha1.Cluster, limits map[string]int32, key string) map[string]int32 {
+	domains := map[string][]*v1alpha1.Cluster{}
+	var values []*v1alpha1.Cluster
+	for _, cl := range clusters {
+		v := cl.Labels[key]
+		if _, found := domains[v]; !found {
+			// Stand-ins of the default weight, to split total evenly.
+			values = append(values, &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: v}})
+		}
+		domains[v] = append(domains[v], cl)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+
+	out := make(map[string]int32, len(clusters))
+	for v, share := range DistributeReplicas(total, values) {
+		for name, n := range DistributeReplicasWithin(share, domains[v], limits) {
+			out[name] = n
+		}
+	}
+	return out
+}
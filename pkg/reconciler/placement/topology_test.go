@@ -0,0 +1,46 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+func TestDistributeReplicasAcross(t *testing.T) {
+	inRegion := func(name, region string, weight int32) *v1alpha1.Cluster {
+		cl := cluster(name, weight)
+		cl.Labels = map[string]string{RegionLabel: region}
+		return cl
+	}
+	cls := []*v1alpha1.Cluster{
+		inRegion("us-east", "us", 1),
+		inRegion("us-west", "us", 2),
+		inRegion("eu-west", "eu", 1),
+	}
+
+	// By weight, the eu region would only get a quarter of them.
+	got := DistributeReplicasAcross(6, cls, nil, RegionLabel)
+	if want := map[string]int32{"eu-west": 3, "us-east": 1, "us-west": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The replicas a region can't fit stay pending there.
+	got = DistributeReplicasAcross(7, cls, map[string]int32{"eu-west": 1}, RegionLabel)
+	if want := map[string]int32{"eu-west": 4, "us-east": 1, "us-west": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with a limit, got %v, want %v", got, want)
+	}
+}
+
+func TestReplicaSpreadKey(t *testing.T) {
+	policy := &v1alpha1.PlacementPolicy{Spec: v1alpha1.PlacementPolicySpec{SpreadConstraints: []v1alpha1.SpreadConstraint{
+		{TopologyKey: ProviderLabel, MinDomains: 2},
+		{TopologyKey: RegionLabel, SpreadReplicas: true},
+	}}}
+	if got := ReplicaSpreadKey(policy); got != RegionLabel {
+		t.Errorf("got %q, want %q", got, RegionLabel)
+	}
+	if got := ReplicaSpreadKey(nil); got != "" {
+		t.Errorf("got %q without a policy", got)
+	}
+}
@@ -0,0 +1,165 @@
+// Package pullsecret copies the registry credentials of the
+// RegistryCredentialPolicies of a workspace to each of its namespaces they
+// select, for the PullSecrets transform to add them to the image pull
+// secrets of the leafs placed there, and the syncers to sync them to every
+// Cluster along with the leafs.
+package pullsecret
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which copies the Secrets each
+// RegistryCredentialPolicy refers to into the namespaces of its workspace it
+// selects, keeps the copies in line with them as they're rotated, and
+// deletes those no RegistryCredentialPolicy wants anymore.
+//
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), leaderElection, retry, shared)
+	if own {
+		shared.Kube.Start(c.StopCh())
+		shared.Cluster.Start(c.StopCh())
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	sif, csif := shared.Kube, shared.Cluster
+	policies := csif.Cluster().V1alpha1().RegistryCredentialPolicies().Informer()
+	secrets := sif.Core().V1().Secrets().Informer()
+	namespaces := sif.Core().V1().Namespaces().Informer()
+
+	c := &Controller{
+		indexer:          logicalcluster.IndexerFor(policies),
+		secretIndexer:    logicalcluster.IndexerFor(secrets),
+		namespaceIndexer: logicalcluster.IndexerFor(namespaces),
+		kubeClient:       kubeClient,
+	}
+	c.Controller = base.New("pullsecret", kubeClient, leaderElection, retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		policies.HasSynced,
+		secrets.HasSynced,
+		namespaces.HasSynced,
+	)
+
+	policies.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.Enqueue(obj) },
+		DeleteFunc: func(obj interface{}) { c.Enqueue(obj) },
+	})
+	secrets.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueuePoliciesOf(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueuePoliciesOf(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueuePoliciesOf(obj) },
+	})
+	// A namespace created or relabeled may be selected by any
+	// RegistryCredentialPolicy of its workspace.
+	namespaces.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueuePoliciesOf(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNamespace, newNamespace := oldObj.(*corev1.Namespace), newObj.(*corev1.Namespace)
+			if !equality.Semantic.DeepEqual(oldNamespace.Labels, newNamespace.Labels) ||
+				(oldNamespace.DeletionTimestamp == nil) != (newNamespace.DeletionTimestamp == nil) {
+				c.enqueuePoliciesOf(newObj)
+			}
+		},
+	})
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	indexer          cache.Indexer
+	secretIndexer    cache.Indexer
+	namespaceIndexer cache.Indexer
+	kubeClient       kubernetes.Interface
+}
+
+// The listers of the objects of a workspace. Credentials are only copied
+// within their own workspace.
+
+func (c *Controller) policies(workspace string) clusterlisters.RegistryCredentialPolicyLister {
+	return clusterlisters.NewRegistryCredentialPolicyLister(logicalcluster.Scoped(c.indexer, workspace))
+}
+
+func (c *Controller) secrets(workspace string) corev1lister.SecretLister {
+	return corev1lister.NewSecretLister(logicalcluster.Scoped(c.secretIndexer, workspace))
+}
+
+func (c *Controller) namespaces(workspace string) corev1lister.NamespaceLister {
+	return corev1lister.NewNamespaceLister(logicalcluster.Scoped(c.namespaceIndexer, workspace))
+}
+
+// enqueuePoliciesOf enqueues the RegistryCredentialPolicy a Secret was
+// copied for, or those of the workspace of any other Secret or namespace,
+// which they may refer to or select.
+func (c *Controller) enqueuePoliciesOf(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	var workspace string
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		if policy := o.Labels[transform.RegistryCredentialPolicyLabel]; policy != "" {
+			c.Queue().Add(logicalcluster.Key(o.ClusterName, "", policy))
+			return
+		}
+		workspace = o.ClusterName
+	case *corev1.Namespace:
+		workspace = o.ClusterName
+	default:
+		return
+	}
+	policies, err := c.policies(workspace).List(labels.Everything())
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	for _, p := range policies {
+		c.Enqueue(p)
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		workspace, _, name, err := logicalcluster.SplitKey(key)
+		if err != nil {
+			return err
+		}
+		return c.deleteCopiesOf(ctx, workspace, name)
+	}
+	return c.reconcile(ctx, obj.(*v1alpha1.RegistryCredentialPolicy))
+}
@@ -0,0 +1,171 @@
+package pullsecret
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// copiedFromAnnotation records the <namespace>/<name> of the Secret a copy
+// of registry credentials was copied from.
+const copiedFromAnnotation = "experimental.kcp.dev/copied-from"
+
+// reconcile copies the Secrets the RegistryCredentialPolicy refers to into
+// the namespaces it selects, but for their own, and deletes the copies it
+// doesn't want anymore. Secrets of the same names it didn't copy are left
+// alone.
+func (c *Controller) reconcile(ctx context.Context, policy *v1alpha1.RegistryCredentialPolicy) error {
+	logger := logging.FromContext(ctx)
+	workspace := policy.ClusterName
+
+	sel := labels.Everything()
+	if policy.Spec.NamespaceSelector != nil {
+		var err error
+		if sel, err = metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+	}
+	namespaces, err := c.namespaces(workspace).List(sel)
+	if err != nil {
+		return err
+	}
+
+	// The copies wanted, by namespace and name.
+	wanted := map[string]*corev1.Secret{}
+	for _, ref := range policy.Spec.SecretRefs {
+		source, err := c.secrets(workspace).Secrets(ref.Namespace).Get(ref.Name)
+		if errors.IsNotFound(err) {
+			c.Recorder().Eventf(policy, corev1.EventTypeWarning, "SecretNotFound", "Secret %s/%s not found", ref.Namespace, ref.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if source.Type != corev1.SecretTypeDockerConfigJson && source.Type != corev1.SecretTypeDockercfg {
+			c.Recorder().Eventf(policy, corev1.EventTypeWarning, "InvalidSecretType", "Secret %s/%s is of type %s, not of registry credentials", ref.Namespace, ref.Name, source.Type)
+			continue
+		}
+		for _, ns := range namespaces {
+			if ns.Name == source.Namespace || ns.DeletionTimestamp != nil {
+				continue
+			}
+			wanted[ns.Name+"/"+source.Name] = newCopy(policy, source, ns.Name)
+		}
+	}
+
+	copies, err := c.copiesOf(workspace, policy.Name)
+	if err != nil {
+		return err
+	}
+	existing := map[string]*corev1.Secret{}
+	for _, s := range copies {
+		key := s.Namespace + "/" + s.Name
+		if wanted[key] == nil {
+			if err := c.kubeClient.CoreV1().Secrets(s.Namespace).Delete(ctx, s.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			logger.Info("Deleted copy of registry credentials", "namespace", s.Namespace, "secret", s.Name)
+			continue
+		}
+		existing[key] = s
+	}
+
+	for key, want := range wanted {
+		current, found := existing[key]
+		if !found {
+			other, err := c.secrets(workspace).Secrets(want.Namespace).Get(want.Name)
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				if other.Labels[transform.RegistryCredentialPolicyLabel] == "" {
+					c.Recorder().Eventf(policy, corev1.EventTypeWarning, "SecretExists", "Not copying %s to namespace %s, which has a Secret of that name already", want.Annotations[copiedFromAnnotation], want.Namespace)
+				}
+				// Or copied for another RegistryCredentialPolicy.
+				continue
+			}
+			if _, err := c.kubeClient.CoreV1().Secrets(want.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			logger.Info("Copied registry credentials", "namespace", want.Namespace, "secret", want.Name)
+			continue
+		}
+		if current.Type == want.Type &&
+			equality.Semantic.DeepEqual(current.Annotations, want.Annotations) &&
+			equality.Semantic.DeepEqual(current.Data, want.Data) {
+			continue
+		}
+		if current.Type != want.Type {
+			// The type of a Secret can't be changed, so replace the copy.
+			if err := c.kubeClient.CoreV1().Secrets(current.Namespace).Delete(ctx, current.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			if _, err := c.kubeClient.CoreV1().Secrets(want.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+		} else {
+			updated := current.DeepCopy()
+			updated.Annotations = want.Annotations
+			updated.Data = want.Data
+			updated.StringData = nil
+			if _, err := c.kubeClient.CoreV1().Secrets(current.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		logger.Info("Updated copy of registry credentials", "namespace", want.Namespace, "secret", want.Name)
+	}
+	return nil
+}
+
+// copiesOf returns the Secrets of the workspace copied for the named
+// RegistryCredentialPolicy.
+func (c *Controller) copiesOf(workspace, policy string) ([]*corev1.Secret, error) {
+	return c.secrets(workspace).List(labels.SelectorFromSet(labels.Set{transform.RegistryCredentialPolicyLabel: policy}))
+}
+
+// deleteCopiesOf deletes the copies of the named RegistryCredentialPolicy,
+// which is gone. The syncers then delete them from the Clusters once the
+// leafs referencing them are synced without them.
+func (c *Controller) deleteCopiesOf(ctx context.Context, workspace, name string) error {
+	copies, err := c.copiesOf(workspace, name)
+	if err != nil {
+		return err
+	}
+	for _, s := range copies {
+		if err := c.kubeClient.CoreV1().Secrets(s.Namespace).Delete(ctx, s.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		logging.FromContext(ctx).Info("Deleted orphaned copy of registry credentials", "namespace", s.Namespace, "secret", s.Name)
+	}
+	return nil
+}
+
+// newCopy returns the copy of the source Secret for the namespace.
+func newCopy(policy *v1alpha1.RegistryCredentialPolicy, source *corev1.Secret, namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        source.Name,
+			Namespace:   namespace,
+			ClusterName: source.ClusterName,
+			Labels:      map[string]string{transform.RegistryCredentialPolicyLabel: policy.Name},
+			Annotations: map[string]string{copiedFromAnnotation: source.Namespace + "/" + source.Name},
+			// Set OwnerReference so deleting the policy deletes its copies.
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: v1alpha1.SchemeGroupVersion.String(),
+				Kind:       "RegistryCredentialPolicy",
+				Name:       policy.Name,
+				UID:        policy.UID,
+			}},
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+}
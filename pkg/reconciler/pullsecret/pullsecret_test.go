@@ -0,0 +1,147 @@
+package pullsecret
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base/basetest"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const workspace = "admin"
+
+type fixture struct {
+	*basetest.Fixture
+	c *Controller
+}
+
+func newFixture(t *testing.T, namespaces ...*corev1.Namespace) *fixture {
+	f := &fixture{Fixture: basetest.New(t)}
+	f.c = newController(f.Kube, nil, nil, f.Informers)
+	for _, ns := range namespaces {
+		f.Add(f.Informers.Kube.Core().V1().Namespaces().Informer(), ns)
+	}
+	return f
+}
+
+func (f *fixture) addPolicy(policy *v1alpha1.RegistryCredentialPolicy) {
+	f.Add(f.Informers.Cluster.Cluster().V1alpha1().RegistryCredentialPolicies().Informer(), policy)
+}
+
+// sync makes the cache of the Controller hold the Secrets of kcp, then
+// processes the named RegistryCredentialPolicy.
+func (f *fixture) sync(name string) {
+	list, err := f.Kube.CoreV1().Secrets("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	f.Replace(f.Informers.Kube.Core().V1().Secrets().Informer(), list)
+	if err := f.c.process(context.Background(), logicalcluster.Key(workspace, "", name)); err != nil {
+		f.T.Fatal(err)
+	}
+}
+
+// copies returns the data of the copies in kcp, by namespace.
+func (f *fixture) copies() map[string]string {
+	list, err := f.Kube.CoreV1().Secrets("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	copies := map[string]string{}
+	for _, s := range list.Items {
+		if s.Labels[transform.RegistryCredentialPolicyLabel] != "" {
+			copies[s.Namespace] = string(s.Data[corev1.DockerConfigJsonKey])
+		}
+	}
+	return copies
+}
+
+func (f *fixture) create(s *corev1.Secret) {
+	if _, err := f.Kube.CoreV1().Secrets(s.Namespace).Create(context.Background(), s, metav1.CreateOptions{}); err != nil {
+		f.T.Fatal(err)
+	}
+}
+
+func namespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: workspace, Labels: labels}}
+}
+
+func credentials(namespace, name, config string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, ClusterName: workspace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(config)},
+	}
+}
+
+func policy(selector map[string]string) *v1alpha1.RegistryCredentialPolicy {
+	p := &v1alpha1.RegistryCredentialPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "registries", ClusterName: workspace},
+		Spec: v1alpha1.RegistryCredentialPolicySpec{
+			SecretRefs: []corev1.SecretReference{{Namespace: "registries", Name: "quay"}},
+		},
+	}
+	if selector != nil {
+		p.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: selector}
+	}
+	return p
+}
+
+func TestCopy(t *testing.T) {
+	f := newFixture(t,
+		namespace("registries", nil),
+		namespace("web", map[string]string{"team": "a"}),
+		namespace("db", map[string]string{"team": "a"}),
+		namespace("other", map[string]string{"team": "b"}))
+	f.create(credentials("registries", "quay", "v1"))
+	// Not theirs to overwrite.
+	f.create(credentials("db", "quay", "mine"))
+	f.addPolicy(policy(map[string]string{"team": "a"}))
+	f.sync("registries")
+
+	if got := f.copies(); len(got) != 1 || got["web"] != "v1" {
+		t.Fatalf("copied to %v", got)
+	}
+
+	// Rotating the credentials updates the copies.
+	rotated := credentials("registries", "quay", "v2")
+	if _, err := f.Kube.CoreV1().Secrets("registries").Update(context.Background(), rotated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.sync("registries")
+	if got := f.copies(); len(got) != 1 || got["web"] != "v2" {
+		t.Errorf("rotated to %v", got)
+	}
+
+	// Namespaces no longer selected lose their copy.
+	f.addPolicy(policy(map[string]string{"team": "b"}))
+	f.sync("registries")
+	if got := f.copies(); len(got) != 1 || got["other"] != "v2" {
+		t.Errorf("moved to %v", got)
+	}
+
+	// Deleting the policy deletes its copies.
+	if err := f.Informers.Cluster.Cluster().V1alpha1().RegistryCredentialPolicies().Informer().GetIndexer().Delete(policy(nil)); err != nil {
+		t.Fatal(err)
+	}
+	f.sync("registries")
+	if got := f.copies(); len(got) != 0 {
+		t.Errorf("left %v", got)
+	}
+}
+
+func TestOnlyRegistryCredentials(t *testing.T) {
+	f := newFixture(t, namespace("registries", nil), namespace("web", nil))
+	password := credentials("registries", "quay", "v1")
+	password.Type = corev1.SecretTypeOpaque
+	f.create(password)
+	f.addPolicy(policy(nil))
+	f.sync("registries")
+	if got := f.copies(); len(got) != 0 {
+		t.Errorf("copied to %v", got)
+	}
+}
@@ -0,0 +1,238 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	appsv1lister "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resyncPeriod = 10 * time.Hour
+
+	// The labels the splitters place workloads with.
+	clusterLabel = "cluster"
+)
+
+var (
+	deployments  = appsv1.SchemeGroupVersion.WithResource("deployments").GroupResource()
+	statefulSets = appsv1.SchemeGroupVersion.WithResource("statefulsets").GroupResource()
+)
+
+// NewController returns a new Controller which keeps the usage of the
+// WorkspaceQuotas up to date with the root Deployments and StatefulSets
+// placed in their workspace.
+func NewController(cfg *rest.Config, leaderElection *base.LeaderElectionConfig, shared *base.Informers) *Controller {
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	client := clusterclient.NewForConfigOrDie(cfg)
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	sif, csif := shared.Kube, shared.Cluster
+
+	quotas := csif.Cluster().V1alpha1().WorkspaceQuotas().Informer()
+	c := &Controller{
+		client:             client.ClusterV1alpha1(),
+		indexer:            logicalcluster.IndexerFor(quotas),
+		deploymentIndexer:  logicalcluster.IndexerFor(sif.Apps().V1().Deployments().Informer()),
+		statefulSetIndexer: logicalcluster.IndexerFor(sif.Apps().V1().StatefulSets().Informer()),
+	}
+	c.Controller = base.New("quota", kubeClient, leaderElection, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		quotas.HasSynced,
+		sif.Apps().V1().Deployments().Informer().HasSynced,
+		sif.Apps().V1().StatefulSets().Informer().HasSynced,
+	)
+	stopCh := c.StopCh()
+
+	quotas.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(oldObj, obj interface{}) {
+			old, q := oldObj.(*v1alpha1.WorkspaceQuota), obj.(*v1alpha1.WorkspaceQuota)
+			// Skip the updates of the status, made here or reserving room for a
+			// workload about to be placed, but not resyncs.
+			if old.ResourceVersion == q.ResourceVersion || !equality.Semantic.DeepEqual(old.Spec, q.Spec) {
+				c.Enqueue(obj)
+			}
+		},
+	})
+	// Any workload placed, resized or deleted changes the usage of the
+	// quotas of its workspace.
+	workloads := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueWorkspaceOf(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueWorkspaceOf(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueWorkspaceOf(obj) },
+	}
+	sif.Apps().V1().Deployments().Informer().AddEventHandler(workloads)
+	sif.Apps().V1().StatefulSets().Informer().AddEventHandler(workloads)
+	if own {
+		sif.Start(stopCh)
+		csif.Start(stopCh)
+	}
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	client             clusterv1alpha1.ClusterV1alpha1Interface
+	indexer            cache.Indexer
+	deploymentIndexer  cache.Indexer
+	statefulSetIndexer cache.Indexer
+}
+
+// The listers of the objects of a workspace, whose quotas only count its
+// own workloads.
+
+func (c *Controller) deployments(workspace string) appsv1lister.DeploymentLister {
+	return appsv1lister.NewDeploymentLister(logicalcluster.Scoped(c.deploymentIndexer, workspace))
+}
+
+func (c *Controller) statefulSets(workspace string) appsv1lister.StatefulSetLister {
+	return appsv1lister.NewStatefulSetLister(logicalcluster.Scoped(c.statefulSetIndexer, workspace))
+}
+
+// enqueueWorkspaceOf enqueues the WorkspaceQuotas of the workspace of the
+// given workload.
+func (c *Controller) enqueueWorkspaceOf(obj interface{}) {
+	workspace, err := logicalcluster.ClusterName(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, q := range logicalcluster.Scoped(c.indexer, workspace).List() {
+		c.Enqueue(q)
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*v1alpha1.WorkspaceQuota).DeepCopy()
+	previous := current.DeepCopy()
+
+	ctx, _ = logging.WithValues(ctx, "quota", current.Name)
+	if err := c.reconcile(ctx, current); err != nil {
+		return err
+	}
+	current.Status.ObservedGeneration = current.Generation
+
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (runtime.Object, error) {
+				return c.client.WorkspaceQuotas().Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+				return c.client.WorkspaceQuotas().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) reconcile(ctx context.Context, q *v1alpha1.WorkspaceQuota) error {
+	total, err := c.used(q.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	used := corev1.ResourceList{}
+	var exceeded []string
+	for _, name := range sortedNames(q.Spec.Hard) {
+		u, found := total[name]
+		if !found {
+			u = *resource.NewQuantity(0, resource.DecimalSI)
+		}
+		if hard := q.Spec.Hard[name]; u.Cmp(hard) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s: used %s, limited to %s", name, u.String(), hard.String()))
+		}
+		used[name] = u
+	}
+	// Keep the quantities that didn't change as they were formatted.
+	for name, u := range used {
+		if prev, found := q.Status.Used[name]; found && prev.Cmp(u) == 0 {
+			used[name] = prev
+		}
+	}
+	q.Status.Used = used
+
+	if len(exceeded) > 0 {
+		logging.FromContext(ctx).V(2).Info("Quota exceeded", "resources", exceeded)
+		conditions.Set(q, v1alpha1.WorkspaceQuotaConditionExceeded, corev1.ConditionTrue, "QuotaExceeded", fmt.Sprintf("The placed workloads use more than allowed of %s", strings.Join(exceeded, "; ")))
+	} else {
+		conditions.Set(q, v1alpha1.WorkspaceQuotaConditionExceeded, corev1.ConditionFalse, "WithinQuota", "The placed workloads fit the quota")
+	}
+	return nil
+}
+
+// used returns what the root Deployments and StatefulSets placed in the
+// workspace use together. Roots are placed once they are labeled for a
+// Cluster or split into leafs.
+func (c *Controller) used(workspace string) (corev1.ResourceList, error) {
+	total := corev1.ResourceList{}
+
+	ds, err := c.deployments(workspace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	split := map[string]bool{}
+	for _, d := range ds {
+		if root := d.Labels[base.LeafLabel]; root != "" {
+			split[d.Namespace+"/"+root] = true
+		}
+	}
+	for _, d := range ds {
+		if d.Labels[base.LeafLabel] == "" && (d.Labels[clusterLabel] != "" || split[d.Namespace+"/"+d.Name]) {
+			Add(total, Usage(deployments, d.Spec.Replicas, &d.Spec.Template.Spec))
+		}
+	}
+
+	sss, err := c.statefulSets(workspace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	split = map[string]bool{}
+	for _, ss := range sss {
+		if root := ss.Labels[base.LeafLabel]; root != "" {
+			split[ss.Namespace+"/"+root] = true
+		}
+	}
+	for _, ss := range sss {
+		if ss.Labels[base.LeafLabel] == "" && (ss.Labels[clusterLabel] != "" || split[ss.Namespace+"/"+ss.Name]) {
+			Add(total, Usage(statefulSets, ss.Spec.Replicas, &ss.Spec.Template.Spec))
+		}
+	}
+	return total, nil
+}
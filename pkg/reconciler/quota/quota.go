@@ -0,0 +1,128 @@
+// Package quota enforces the WorkspaceQuotas of workspaces on the
+// workloads placed onto their Clusters.
+//
+// The splitters check that a root workload fits the quotas of its
+// workspace before placing it for the first time, and reserve what it uses
+// in their status, so that the roots placed concurrently don't both fit the
+// same room. This controller then keeps the usage of every WorkspaceQuota
+// up to date with the workloads actually placed, releasing what the
+// deleted ones used.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourceReplicas is the resource counting the replicas of the placed
+// workloads.
+const ResourceReplicas corev1.ResourceName = "replicas"
+
+// ObjectCount returns the resource counting the placed root workloads of
+// the given resource, named as in a ResourceQuota, e.g.
+// count/deployments.apps.
+func ObjectCount(gr schema.GroupResource) corev1.ResourceName {
+	return corev1.ResourceName("count/" + gr.String())
+}
+
+// Usage returns what a root workload of the given resource, with the given
+// replicas, 1 if nil, each running a pod with the given spec, uses of a
+// WorkspaceQuota.
+func Usage(gr schema.GroupResource, specReplicas *int32, spec *corev1.PodSpec) corev1.ResourceList {
+	replicas := int32(1)
+	if specReplicas != nil {
+		replicas = *specReplicas
+	}
+	used := corev1.ResourceList{
+		ObjectCount(gr):  *resource.NewQuantity(1, resource.DecimalSI),
+		ResourceReplicas: *resource.NewQuantity(int64(replicas), resource.DecimalSI),
+	}
+	for name, q := range placement.PodRequests(spec) {
+		used[corev1.ResourceName("requests."+name)] = *resource.NewMilliQuantity(q.MilliValue()*int64(replicas), q.Format)
+	}
+	return used
+}
+
+// Add adds what delta uses to total.
+func Add(total, delta corev1.ResourceList) {
+	for name, q := range delta {
+		sum := total[name].DeepCopy()
+		sum.Add(q)
+		total[name] = sum
+	}
+}
+
+// Exceeds returns why a workload using want doesn't fit one of the given
+// WorkspaceQuotas, or "" if it fits all of them.
+func Exceeds(quotas []*v1alpha1.WorkspaceQuota, want corev1.ResourceList) string {
+	for _, q := range quotas {
+		if len(q.Spec.Hard) > 0 && q.Status.Used == nil {
+			// Until its usage is known, nothing is known to fit.
+			return fmt.Sprintf("The usage of WorkspaceQuota %s isn't known yet", q.Name)
+		}
+		var exceeded []string
+		for _, name := range sortedNames(q.Spec.Hard) {
+			requested, found := want[name]
+			if !found || requested.IsZero() {
+				continue
+			}
+			used, hard := q.Status.Used[name].DeepCopy(), q.Spec.Hard[name]
+			total := used.DeepCopy()
+			total.Add(requested)
+			if total.Cmp(hard) > 0 {
+				exceeded = append(exceeded, fmt.Sprintf("%s: requested %s, used %s, limited to %s", name, requested.String(), used.String(), hard.String()))
+			}
+		}
+		if len(exceeded) > 0 {
+			return fmt.Sprintf("Exceeded WorkspaceQuota %s: %s", q.Name, strings.Join(exceeded, "; "))
+		}
+	}
+	return ""
+}
+
+// Reserve records that a workload using want is placed in the usage of each
+// of the given WorkspaceQuotas, if it fits all of them, and otherwise
+// returns why it doesn't. A conflict means another workload was placed
+// meanwhile, and the quotas must be checked again. The context must be that
+// of the logical cluster of the quotas.
+func Reserve(ctx context.Context, client clusterv1alpha1.WorkspaceQuotasGetter, quotas []*v1alpha1.WorkspaceQuota, want corev1.ResourceList) (string, error) {
+	if msg := Exceeds(quotas, want); msg != "" {
+		return msg, nil
+	}
+	for _, q := range quotas {
+		reserved := false
+		updated := q.DeepCopy()
+		for name := range updated.Spec.Hard {
+			if requested, found := want[name]; found {
+				Add(updated.Status.Used, corev1.ResourceList{name: requested})
+				reserved = true
+			}
+		}
+		if !reserved {
+			continue
+		}
+		if _, err := client.WorkspaceQuotas().UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func sortedNames(l corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,74 @@
+package quota
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func resources(kv ...string) corev1.ResourceList {
+	l := corev1.ResourceList{}
+	for i := 0; i < len(kv); i += 2 {
+		l[corev1.ResourceName(kv[i])] = resource.MustParse(kv[i+1])
+	}
+	return l
+}
+
+func workspaceQuota(hard, used corev1.ResourceList) *v1alpha1.WorkspaceQuota {
+	return &v1alpha1.WorkspaceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       v1alpha1.WorkspaceQuotaSpec{Hard: hard},
+		Status:     v1alpha1.WorkspaceQuotaStatus{Used: used},
+	}
+}
+
+func TestExceeds(t *testing.T) {
+	replicas := int32(3)
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{
+		Resources: corev1.ResourceRequirements{Requests: resources("cpu", "500m")},
+	}}}
+	want := Usage(schema.GroupResource{Group: "apps", Resource: "deployments"}, &replicas, spec)
+
+	for _, tc := range []struct {
+		name     string
+		quota    *v1alpha1.WorkspaceQuota
+		exceeded string
+	}{{
+		name:  "fits",
+		quota: workspaceQuota(resources("count/deployments.apps", "2", "replicas", "5", "requests.cpu", "2"), resources("count/deployments.apps", "1", "replicas", "2", "requests.cpu", "500m")),
+	}, {
+		name:     "too many deployments",
+		quota:    workspaceQuota(resources("count/deployments.apps", "1"), resources("count/deployments.apps", "1")),
+		exceeded: "count/deployments.apps: requested 1, used 1, limited to 1",
+	}, {
+		name:     "too many replicas",
+		quota:    workspaceQuota(resources("replicas", "4"), resources("replicas", "2")),
+		exceeded: "replicas: requested 3, used 2, limited to 4",
+	}, {
+		name:     "too much cpu",
+		quota:    workspaceQuota(resources("requests.cpu", "1"), resources("requests.cpu", "0")),
+		exceeded: "requests.cpu: requested 1500m, used 0, limited to 1",
+	}, {
+		name:  "unlimited resource",
+		quota: workspaceQuota(resources("requests.memory", "1Gi"), resources("requests.memory", "1Gi")),
+	}, {
+		name:     "usage unknown",
+		quota:    workspaceQuota(resources("replicas", "10"), nil),
+		exceeded: "isn't known yet",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := Exceeds([]*v1alpha1.WorkspaceQuota{tc.quota}, want)
+			if tc.exceeded == "" && msg != "" {
+				t.Errorf("got %q, want the workload to fit", msg)
+			}
+			if tc.exceeded != "" && !strings.Contains(msg, tc.exceeded) {
+				t.Errorf("got %q, want it to contain %q", msg, tc.exceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package secret distributes the Secrets that SecretDistributions select to
+// the Clusters they select, as leaf Secrets labeled for each Cluster, which
+// their syncers sync under the name of the Secret they're copied from.
+// Rotating a Secret pushes it to all its Clusters again.
+package secret
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which copies each Secret selected
+// by a SecretDistribution of its namespace to a leaf Secret per Cluster the
+// SecretDistribution selects, keeps the leafs in line with their root as it
+// changes, and deletes those of the Clusters no SecretDistribution selects
+// anymore.
+//
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), leaderElection, retry, shared)
+	if own {
+		shared.Kube.Start(c.StopCh())
+		shared.Cluster.Start(c.StopCh())
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	sif, csif := shared.Kube, shared.Cluster
+	secrets := sif.Core().V1().Secrets().Informer()
+	distributions := csif.Cluster().V1alpha1().SecretDistributions().Informer()
+	clusters := csif.Cluster().V1alpha1().Clusters().Informer()
+
+	c := &Controller{
+		indexer:             logicalcluster.IndexerFor(secrets),
+		distributionIndexer: logicalcluster.IndexerFor(distributions),
+		clusterIndexer:      logicalcluster.IndexerFor(clusters),
+		kubeClient:          kubeClient,
+	}
+	c.Controller = base.New("secret", kubeClient, leaderElection, retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		secrets.HasSynced,
+		distributions.HasSynced,
+		clusters.HasSynced,
+	)
+
+	secrets.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRootOf(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRootOf(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRootOf(obj) },
+	})
+	// A SecretDistribution may select any Secret of its namespace.
+	distributions.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	// Clusters joining, leaving or being relabeled may be selected by any
+	// SecretDistribution of their workspace.
+	clusters.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCluster, newCluster := oldObj.(*v1alpha1.Cluster), newObj.(*v1alpha1.Cluster)
+			if !equality.Semantic.DeepEqual(oldCluster.Labels, newCluster.Labels) ||
+				!equality.Semantic.DeepEqual(oldCluster.Status.SyncedResources, newCluster.Status.SyncedResources) {
+				c.enqueueRoots(newObj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	indexer             cache.Indexer
+	distributionIndexer cache.Indexer
+	clusterIndexer      cache.Indexer
+	kubeClient          kubernetes.Interface
+}
+
+// The listers of the objects of a workspace. Secrets are only distributed
+// to the Clusters of their own workspace.
+
+func (c *Controller) secrets(workspace string) corev1lister.SecretLister {
+	return corev1lister.NewSecretLister(logicalcluster.Scoped(c.indexer, workspace))
+}
+
+func (c *Controller) distributions(workspace string) clusterlisters.SecretDistributionLister {
+	return clusterlisters.NewSecretDistributionLister(logicalcluster.Scoped(c.distributionIndexer, workspace))
+}
+
+func (c *Controller) clusters(workspace string) clusterlisters.ClusterLister {
+	return clusterlisters.NewClusterLister(logicalcluster.Scoped(c.clusterIndexer, workspace))
+}
+
+// enqueueRootOf enqueues the given Secret, or its root if it's a leaf.
+func (c *Controller) enqueueRootOf(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	if root := secret.Labels[base.LeafLabel]; root != "" {
+		c.Queue().Add(logicalcluster.Key(secret.ClusterName, secret.Namespace, root))
+		return
+	}
+	c.Enqueue(secret)
+}
+
+// enqueueRoots enqueues every root Secret, i.e. those not copied from
+// another one, of the namespace of the given SecretDistribution, or of the
+// workspace of the given Cluster.
+func (c *Controller) enqueueRoots(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	var secrets []*corev1.Secret
+	if m.GetNamespace() != "" {
+		secrets, err = c.secrets(m.GetClusterName()).Secrets(m.GetNamespace()).List(labels.Everything())
+	} else {
+		secrets, err = c.secrets(m.GetClusterName()).List(labels.Everything())
+	}
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	for _, s := range secrets {
+		if s.Labels[base.LeafLabel] == "" {
+			c.Enqueue(s)
+		}
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		workspace, namespace, name, err := logicalcluster.SplitKey(key)
+		if err != nil {
+			return err
+		}
+		return c.deleteLeafsOf(ctx, workspace, namespace, name)
+	}
+	return c.reconcile(ctx, obj.(*corev1.Secret))
+}
@@ -0,0 +1,243 @@
+package secret
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	clusterLabel = "cluster"
+
+	// DistributedFromAnnotation names the root Secret a leaf was copied
+	// from, for the syncer to sync the leaf under that name.
+	DistributedFromAnnotation = "experimental.kcp.dev/distributed-from"
+
+	// dataHashAnnotation records the hash of the type and data of the root
+	// Secret a leaf was last copied from, to tell rotations apart.
+	dataHashAnnotation = "experimental.kcp.dev/secret-hash"
+)
+
+var secretsResource = corev1.SchemeGroupVersion.WithResource("secrets").GroupResource()
+
+// reconcile keeps one leaf per Cluster that a SecretDistribution selecting
+// the root Secret selects. The tokens of ServiceAccounts, which mean nothing
+// elsewhere, and the Secrets labeled for a Cluster, which are synced there
+// as they are, aren't distributed.
+func (c *Controller) reconcile(ctx context.Context, root *corev1.Secret) error {
+	if root.Labels[base.LeafLabel] != "" {
+		// A leaf; its root is reconciled instead.
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+
+	clusters := sets.NewString()
+	if root.Type != corev1.SecretTypeServiceAccountToken && root.Labels[clusterLabel] == "" {
+		var err error
+		if clusters, err = c.clustersFor(root); err != nil {
+			return err
+		}
+	}
+
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+	existing := map[string]*corev1.Secret{}
+	for _, leaf := range leafs {
+		cluster := leaf.Labels[clusterLabel]
+		if !clusters.Has(cluster) || existing[cluster] != nil {
+			if err := c.kubeClient.CoreV1().Secrets(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			logger.Info("Deleted child secret", "child", leaf.Name, logging.ClusterKey, cluster)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Withdrawn", "Withdrawn from cluster %s", cluster)
+			continue
+		}
+		existing[cluster] = leaf
+	}
+
+	for _, cluster := range clusters.List() {
+		want := newLeaf(root, cluster)
+		leaf, found := existing[cluster]
+		if !found {
+			if _, err := c.kubeClient.CoreV1().Secrets(root.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			logger.Info("Created child secret", "child", want.Name, logging.ClusterKey, cluster)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Distributed", "Distributed to cluster %s", cluster)
+			continue
+		}
+		if leaf.Type == want.Type &&
+			equality.Semantic.DeepEqual(leaf.Labels, want.Labels) &&
+			equality.Semantic.DeepEqual(leaf.Annotations, want.Annotations) &&
+			equality.Semantic.DeepEqual(leaf.Data, want.Data) {
+			continue
+		}
+		if leaf.Type != want.Type {
+			// The type of a Secret can't be changed, so replace the leaf.
+			if err := c.kubeClient.CoreV1().Secrets(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			if _, err := c.kubeClient.CoreV1().Secrets(root.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+		} else {
+			updated := leaf.DeepCopy()
+			updated.Labels = want.Labels
+			updated.Annotations = want.Annotations
+			updated.Data = want.Data
+			updated.StringData = nil
+			if _, err := c.kubeClient.CoreV1().Secrets(leaf.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		if leaf.Annotations[dataHashAnnotation] != want.Annotations[dataHashAnnotation] {
+			logger.Info("Pushed rotated child secret", "child", leaf.Name, logging.ClusterKey, cluster)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Rotated", "Pushed the rotated secret to cluster %s", cluster)
+			continue
+		}
+		logger.V(2).Info("Updated child secret", "child", leaf.Name, logging.ClusterKey, cluster)
+	}
+	return nil
+}
+
+// clustersFor returns the Clusters of the root Secret's workspace selected
+// by the SecretDistributions of its namespace that select it, and whose
+// syncers sync Secrets.
+func (c *Controller) clustersFor(root *corev1.Secret) (sets.String, error) {
+	distributions, err := c.distributions(root.ClusterName).SecretDistributions(root.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var selecting []*v1alpha1.SecretDistribution
+	for _, d := range distributions {
+		if d.Spec.SecretSelector == nil {
+			continue
+		}
+		sel, err := metav1.LabelSelectorAsSelector(d.Spec.SecretSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secretSelector in SecretDistribution %s/%s: %w", d.Namespace, d.Name, err)
+		}
+		if sel.Matches(labels.Set(root.Labels)) {
+			selecting = append(selecting, d)
+		}
+	}
+	clusters := sets.NewString()
+	if len(selecting) == 0 {
+		return clusters, nil
+	}
+
+	all, err := c.clusters(root.ClusterName).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	all = placement.Syncing(all, secretsResource)
+	for _, d := range selecting {
+		sel := labels.Everything()
+		if d.Spec.ClusterSelector != nil {
+			if sel, err = metav1.LabelSelectorAsSelector(d.Spec.ClusterSelector); err != nil {
+				return nil, fmt.Errorf("invalid clusterSelector in SecretDistribution %s/%s: %w", d.Namespace, d.Name, err)
+			}
+		}
+		for _, cl := range all {
+			if sel.Matches(labels.Set(cl.Labels)) {
+				clusters.Insert(cl.Name)
+			}
+		}
+	}
+	return clusters, nil
+}
+
+// leafsFor returns the leafs copied from the root Secret.
+func (c *Controller) leafsFor(root *corev1.Secret) ([]*corev1.Secret, error) {
+	sel := labels.SelectorFromSet(labels.Set{base.LeafLabel: root.Name})
+	leafs, err := c.secrets(root.ClusterName).Secrets(root.Namespace).List(sel)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(leafs, func(i, j int) bool { return leafs[i].Name < leafs[j].Name })
+	return leafs, nil
+}
+
+// deleteLeafsOf deletes the leafs of the named root Secret, which is gone.
+// Once deleted from kcp, each is removed from its Cluster by the syncer.
+func (c *Controller) deleteLeafsOf(ctx context.Context, workspace, namespace, name string) error {
+	sel := labels.SelectorFromSet(labels.Set{base.LeafLabel: name})
+	leafs, err := c.secrets(workspace).Secrets(namespace).List(sel)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range leafs {
+		if err := c.kubeClient.CoreV1().Secrets(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		logging.FromContext(ctx).Info("Deleted orphaned child secret", "child", leaf.Name, logging.ClusterKey, leaf.Labels[clusterLabel])
+	}
+	return nil
+}
+
+// newLeaf returns the leaf of the root Secret for the given Cluster,
+// labeled and named for it.
+func newLeaf(root *corev1.Secret, clusterName string) *corev1.Secret {
+	leaf := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s--%s", root.Name, clusterName),
+			Namespace:   root.Namespace,
+			ClusterName: root.ClusterName,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+			// Set OwnerReference so deleting the Secret deletes all its leafs.
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Name:       root.Name,
+				UID:        root.UID,
+			}},
+		},
+		Type: root.Type,
+		Data: root.Data,
+	}
+	for k, v := range root.Labels {
+		leaf.Labels[k] = v
+	}
+	leaf.Labels[clusterLabel] = clusterName
+	leaf.Labels[base.LeafLabel] = root.Name
+	for k, v := range root.Annotations {
+		leaf.Annotations[k] = v
+	}
+	// The last applied configuration would hold the data in plain text.
+	delete(leaf.Annotations, corev1.LastAppliedConfigAnnotation)
+	leaf.Annotations[DistributedFromAnnotation] = root.Name
+	leaf.Annotations[dataHashAnnotation] = dataHash(root)
+	return leaf
+}
+
+// dataHash returns the hash of the type and data of a Secret.
+func dataHash(s *corev1.Secret) string {
+	keys := make([]string, 0, len(s.Data))
+	for k := range s.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", s.Type)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%d:", k, len(s.Data[k]))
+		h.Write(s.Data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
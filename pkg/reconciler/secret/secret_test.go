@@ -0,0 +1,153 @@
+package secret
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base/basetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const workspace = "admin"
+
+type fixture struct {
+	*basetest.Fixture
+	c *Controller
+}
+
+func newFixture(t *testing.T, clusters ...*v1alpha1.Cluster) *fixture {
+	f := &fixture{Fixture: basetest.New(t)}
+	f.c = newController(f.Kube, nil, nil, f.Informers)
+	for _, cl := range clusters {
+		f.Add(f.Informers.Cluster.Cluster().V1alpha1().Clusters().Informer(), cl)
+	}
+	return f
+}
+
+func (f *fixture) addDistribution(name string, secretLabels, clusterLabels map[string]string) {
+	d := &v1alpha1.SecretDistribution{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ClusterName: workspace},
+		Spec: v1alpha1.SecretDistributionSpec{
+			SecretSelector: &metav1.LabelSelector{MatchLabels: secretLabels},
+		},
+	}
+	if clusterLabels != nil {
+		d.Spec.ClusterSelector = &metav1.LabelSelector{MatchLabels: clusterLabels}
+	}
+	f.Add(f.Informers.Cluster.Cluster().V1alpha1().SecretDistributions().Informer(), d)
+}
+
+// sync makes the cache of the Controller hold the Secrets of kcp, then
+// processes the named one.
+func (f *fixture) sync(name string) {
+	list, err := f.Kube.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	f.Replace(f.Informers.Kube.Core().V1().Secrets().Informer(), list)
+	if err := f.c.process(context.Background(), logicalcluster.Key(workspace, "default", name)); err != nil {
+		f.T.Fatal(err)
+	}
+}
+
+// leafs returns the data of the leafs in kcp, by Cluster.
+func (f *fixture) leafs() map[string]string {
+	list, err := f.Kube.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	leafs := map[string]string{}
+	for _, s := range list.Items {
+		if s.Labels[base.LeafLabel] != "" {
+			leafs[s.Labels[clusterLabel]] = string(s.Data["password"])
+		}
+	}
+	return leafs
+}
+
+func cluster(name string, labels map[string]string) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: workspace, Labels: labels}}
+}
+
+func secret(name, password string, labels map[string]string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ClusterName: workspace, Labels: labels},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte(password)},
+	}
+}
+
+func TestDistribute(t *testing.T) {
+	f := newFixture(t,
+		cluster("east", map[string]string{"env": "prod"}),
+		cluster("west", map[string]string{"env": "prod"}),
+		cluster("dev", map[string]string{"env": "dev"}))
+	f.addDistribution("db", map[string]string{"app": "db"}, map[string]string{"env": "prod"})
+	ctx := context.Background()
+	if _, err := f.Kube.CoreV1().Secrets("default").Create(ctx, secret("db", "s3cr3t", map[string]string{"app": "db"}), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Kube.CoreV1().Secrets("default").Create(ctx, secret("other", "s3cr3t", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.sync("db")
+	f.sync("other")
+
+	if got := f.leafs(); len(got) != 2 || got["east"] != "s3cr3t" || got["west"] != "s3cr3t" {
+		t.Fatalf("distributed %v", got)
+	}
+	leaf, err := f.Kube.CoreV1().Secrets("default").Get(ctx, "db--east", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Annotations[DistributedFromAnnotation] != "db" || len(leaf.OwnerReferences) != 1 {
+		t.Errorf("got annotations %v and owners %v", leaf.Annotations, leaf.OwnerReferences)
+	}
+
+	// Rotating the Secret pushes it again.
+	rotated := secret("db", "n3w", map[string]string{"app": "db"})
+	if _, err := f.Kube.CoreV1().Secrets("default").Update(ctx, rotated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.sync("db")
+	if got := f.leafs(); len(got) != 2 || got["east"] != "n3w" || got["west"] != "n3w" {
+		t.Errorf("rotated to %v", got)
+	}
+
+	// Relabeling a Cluster withdraws the Secret from it.
+	relabeled := cluster("west", map[string]string{"env": "dev"})
+	if err := f.Informers.Cluster.Cluster().V1alpha1().Clusters().Informer().GetIndexer().Update(relabeled); err != nil {
+		t.Fatal(err)
+	}
+	f.sync("db")
+	if got := f.leafs(); len(got) != 1 || got["east"] != "n3w" {
+		t.Errorf("withdrawn to %v", got)
+	}
+
+	// Deleting the Secret deletes its leafs.
+	if err := f.Kube.CoreV1().Secrets("default").Delete(ctx, "db", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.sync("db")
+	if got := f.leafs(); len(got) != 0 {
+		t.Errorf("left %v", got)
+	}
+}
+
+func TestServiceAccountTokensStay(t *testing.T) {
+	f := newFixture(t, cluster("east", nil))
+	f.addDistribution("all", nil, nil)
+	token := secret("default-token", "t0ken", nil)
+	token.Type = corev1.SecretTypeServiceAccountToken
+	if _, err := f.Kube.CoreV1().Secrets("default").Create(context.Background(), token, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.sync("default-token")
+	if got := f.leafs(); len(got) != 0 {
+		t.Errorf("distributed %v", got)
+	}
+}
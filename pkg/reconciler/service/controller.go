@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	appsv1lister "k8s.io/client-go/listers/apps/v1"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	discoveryv1beta1lister "k8s.io/client-go/listers/discovery/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which mirrors each root Service to
+// the Clusters its selected Deployments and StatefulSets were placed on, as
+// virtual Services labeled for each of those Clusters, and aggregates the
+// EndpointSlices the syncers write back for them into EndpointSlices of the
+// root Service.
+//
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), leaderElection, retry, shared)
+	if own {
+		shared.Kube.Start(c.StopCh())
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	sif := shared.Kube
+
+	c := &Controller{
+		indexer:           logicalcluster.IndexerFor(sif.Core().V1().Services().Informer()),
+		sliceIndexer:      logicalcluster.IndexerFor(sif.Discovery().V1beta1().EndpointSlices().Informer()),
+		deploymentIndexer: logicalcluster.IndexerFor(sif.Apps().V1().Deployments().Informer()),
+		statefulIndexer:   logicalcluster.IndexerFor(sif.Apps().V1().StatefulSets().Informer()),
+		kubeClient:        kubeClient,
+	}
+	c.orphans = base.NewOrphans(corev1.SchemeGroupVersion.WithResource("services"), c.indexer, leafClient{kubeClient.CoreV1()})
+	c.Controller = base.New("service", kubeClient, leaderElection, retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		sif.Core().V1().Services().Informer().HasSynced,
+		sif.Discovery().V1beta1().EndpointSlices().Informer().HasSynced,
+		sif.Apps().V1().Deployments().Informer().HasSynced,
+		sif.Apps().V1().StatefulSets().Informer().HasSynced,
+	)
+	c.AddPeriodic(c.orphans.Collect, gcInterval)
+
+	sif.Core().V1().Services().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.Enqueue(obj) },
+		// A deleted root takes its aggregated EndpointSlices with it.
+		DeleteFunc: func(obj interface{}) { c.enqueueDeleted(obj) },
+	})
+	// Workloads being placed, moved or deleted change the Clusters their
+	// Services must be on.
+	workloadHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRootsFor(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRootsFor(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRootsFor(obj) },
+	}
+	sif.Apps().V1().Deployments().Informer().AddEventHandler(workloadHandler)
+	sif.Apps().V1().StatefulSets().Informer().AddEventHandler(workloadHandler)
+	sif.Discovery().V1beta1().EndpointSlices().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRootForSlice(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRootForSlice(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRootForSlice(obj) },
+	})
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	indexer           cache.Indexer
+	sliceIndexer      cache.Indexer
+	deploymentIndexer cache.Indexer
+	statefulIndexer   cache.Indexer
+	orphans           *base.Orphans
+	kubeClient        kubernetes.Interface
+}
+
+// The listers of the objects of a workspace. Services are only mirrored to
+// the Clusters their own workspace's workloads were placed on.
+
+func (c *Controller) services(workspace string) corev1lister.ServiceLister {
+	return corev1lister.NewServiceLister(logicalcluster.Scoped(c.indexer, workspace))
+}
+
+func (c *Controller) slices(workspace string) discoveryv1beta1lister.EndpointSliceLister {
+	return discoveryv1beta1lister.NewEndpointSliceLister(logicalcluster.Scoped(c.sliceIndexer, workspace))
+}
+
+func (c *Controller) deployments(workspace string) appsv1lister.DeploymentLister {
+	return appsv1lister.NewDeploymentLister(logicalcluster.Scoped(c.deploymentIndexer, workspace))
+}
+
+func (c *Controller) statefulSets(workspace string) appsv1lister.StatefulSetLister {
+	return appsv1lister.NewStatefulSetLister(logicalcluster.Scoped(c.statefulIndexer, workspace))
+}
+
+func (c *Controller) enqueueDeleted(obj interface{}) {
+	key, err := logicalcluster.KeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.Queue().Add(key)
+}
+
+// enqueueRootsFor enqueues every root Service in the namespace of the given
+// workload, i.e. those not mirrored from another one.
+func (c *Controller) enqueueRootsFor(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	workspace, err := logicalcluster.ClusterName(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	namespace, err := namespaceOf(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	services, err := c.services(workspace).Services(namespace).List(labels.Everything())
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	for _, svc := range services {
+		if svc.Labels[base.LeafLabel] == "" {
+			c.Enqueue(svc)
+		}
+	}
+}
+
+// enqueueRootForSlice enqueues the root Service of the virtual Service an
+// EndpointSlice written back by a syncer belongs to.
+func (c *Controller) enqueueRootForSlice(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	slice, ok := obj.(*discoveryv1beta1.EndpointSlice)
+	if !ok || slice.Labels[upsyncedFromLabel] == "" {
+		return
+	}
+	leaf, err := c.services(slice.ClusterName).Services(slice.Namespace).Get(slice.Labels[discoveryv1beta1.LabelServiceName])
+	if err != nil {
+		// The virtual Service is gone; its root will clean up when it's reconciled.
+		return
+	}
+	if root := leaf.Labels[base.LeafLabel]; root != "" {
+		c.Queue().Add(logicalcluster.Key(slice.ClusterName, slice.Namespace, root))
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		workspace, namespace, name, err := logicalcluster.SplitKey(key)
+		if err != nil {
+			return err
+		}
+		return c.deleteAggregatedSlices(ctx, workspace, namespace, name, nil)
+	}
+	current := obj.(*corev1.Service)
+
+	return c.reconcile(ctx, current)
+}
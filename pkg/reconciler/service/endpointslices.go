@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// upsyncedFromLabel is set by the syncer on the EndpointSlices it writes
+	// back to kcp for the Services synced to its cluster, by the cluster ID.
+	upsyncedFromLabel = "experimental.kcp.dev/upsynced-from"
+
+	// endpointsClusterLabel records, on each aggregated EndpointSlice, the
+	// Cluster its endpoints are on.
+	endpointsClusterLabel = "experimental.kcp.dev/endpoints-cluster"
+
+	// managedBy is the endpointslice.kubernetes.io/managed-by value of the
+	// aggregated EndpointSlices.
+	managedBy = "service-splitter.kcp.dev"
+)
+
+// aggregateSlices keeps one EndpointSlice of the root Service per
+// EndpointSlice written back for its virtual Services on the given Clusters,
+// with the same endpoints and ports, so that clients of the logical cluster
+// see the endpoints of every Cluster.
+func (c *Controller) aggregateSlices(ctx context.Context, root *corev1.Service, leafs []*corev1.Service, clusters sets.String) error {
+	logger := logging.FromContext(ctx)
+	client := c.kubeClient.DiscoveryV1beta1().EndpointSlices(root.Namespace)
+
+	want := map[string]*discoveryv1beta1.EndpointSlice{}
+	for _, leaf := range leafs {
+		cluster := leaf.Labels[clusterLabel]
+		if !clusters.Has(cluster) {
+			continue
+		}
+		sel := labels.SelectorFromSet(labels.Set{
+			discoveryv1beta1.LabelServiceName: leaf.Name,
+			upsyncedFromLabel:                 cluster,
+		})
+		sources, err := c.slices(root.ClusterName).EndpointSlices(root.Namespace).List(sel)
+		if err != nil {
+			return err
+		}
+		for _, source := range sources {
+			slice := aggregatedSlice(root, leaf, source)
+			want[slice.Name] = slice
+		}
+	}
+
+	for _, slice := range want {
+		current, err := c.slices(root.ClusterName).EndpointSlices(root.Namespace).Get(slice.Name)
+		if errors.IsNotFound(err) {
+			if _, err := client.Create(ctx, slice, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			logger.V(2).Info("Created aggregated endpointslice", "endpointslice", slice.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if current.AddressType == slice.AddressType &&
+			equality.Semantic.DeepEqual(current.Labels, slice.Labels) &&
+			equality.Semantic.DeepEqual(current.Endpoints, slice.Endpoints) &&
+			equality.Semantic.DeepEqual(current.Ports, slice.Ports) {
+			continue
+		}
+		if current.AddressType != slice.AddressType {
+			// The address type is immutable.
+			if err := client.Delete(ctx, current.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			if _, err := client.Create(ctx, slice, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			continue
+		}
+		updated := current.DeepCopy()
+		updated.Labels = slice.Labels
+		updated.Endpoints = slice.Endpoints
+		updated.Ports = slice.Ports
+		if _, err := client.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logger.V(4).Info("Updated aggregated endpointslice", "endpointslice", slice.Name)
+	}
+
+	keep := sets.NewString()
+	for name := range want {
+		keep.Insert(name)
+	}
+	return c.deleteAggregatedSlices(ctx, root.ClusterName, root.Namespace, root.Name, keep)
+}
+
+// deleteAggregatedSlices deletes the aggregated EndpointSlices of the named
+// root Service of the workspace, except those in keep.
+func (c *Controller) deleteAggregatedSlices(ctx context.Context, workspace, namespace, name string, keep sets.String) error {
+	sel := labels.SelectorFromSet(labels.Set{
+		discoveryv1beta1.LabelServiceName: name,
+		discoveryv1beta1.LabelManagedBy:   managedBy,
+	})
+	slices, err := c.slices(workspace).EndpointSlices(namespace).List(sel)
+	if err != nil {
+		return err
+	}
+	for _, slice := range slices {
+		if keep.Has(slice.Name) {
+			continue
+		}
+		if err := c.kubeClient.DiscoveryV1beta1().EndpointSlices(namespace).Delete(ctx, slice.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		logging.FromContext(ctx).V(2).Info("Deleted aggregated endpointslice", "endpointslice", slice.Name)
+	}
+	return nil
+}
+
+// aggregatedSlice returns the EndpointSlice of the root Service standing for
+// an EndpointSlice written back for one of its virtual Services. Slices are
+// named after their source, which the Cluster generated from the virtual
+// Service's name, e.g. web--us-east-x7k2p becomes web-us-east-x7k2p.
+func aggregatedSlice(root, leaf *corev1.Service, source *discoveryv1beta1.EndpointSlice) *discoveryv1beta1.EndpointSlice {
+	cluster := leaf.Labels[clusterLabel]
+	suffix := strings.TrimPrefix(source.Name, leaf.Name+"-")
+
+	slice := &discoveryv1beta1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: root.Namespace,
+			Name:      fmt.Sprintf("%s-%s-%s", root.Name, cluster, suffix),
+			Labels: map[string]string{
+				discoveryv1beta1.LabelServiceName: root.Name,
+				discoveryv1beta1.LabelManagedBy:   managedBy,
+				endpointsClusterLabel:             cluster,
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Service",
+				Name:       root.Name,
+				UID:        root.UID,
+			}},
+		},
+		AddressType: source.AddressType,
+		Ports:       source.DeepCopy().Ports,
+	}
+	for _, ep := range source.Endpoints {
+		ep := *ep.DeepCopy()
+		// Nodes and pods are only meaningful within their Cluster.
+		delete(ep.Topology, corev1.LabelHostname)
+		ep.TargetRef = nil
+		slice.Endpoints = append(slice.Endpoints, ep)
+	}
+	return slice
+}
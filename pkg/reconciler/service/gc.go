@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// leafClient is the base.LeafClient orphaned virtual Services are checked
+// and deleted with.
+type leafClient struct {
+	corev1client.ServicesGetter
+}
+
+func (c leafClient) Get(ctx context.Context, namespace, name string) (metav1.Object, error) {
+	svc, err := c.Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+func (c leafClient) Delete(ctx context.Context, namespace, name string) error {
+	return c.Services(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
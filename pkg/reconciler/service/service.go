@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	clusterLabel = "cluster"
+	gcInterval   = time.Minute
+)
+
+func (c *Controller) reconcile(ctx context.Context, svc *corev1.Service) error {
+	logging.FromContext(ctx).V(2).Info("Reconciling service")
+
+	if svc.Labels[base.LeafLabel] == "" {
+		// This is a root Service; make sure it's on every Cluster its workloads are on.
+		return c.reconcileRoot(ctx, svc)
+	}
+
+	if deleted, err := c.orphans.DeleteIfOrphaned(ctx, svc); err != nil || deleted {
+		return err
+	}
+
+	// A virtual Service was updated; its root aggregates the endpoints of all of them.
+	c.Queue().Add(logicalcluster.Key(svc.ClusterName, svc.Namespace, svc.Labels[base.LeafLabel]))
+	return nil
+}
+
+// reconcileRoot keeps one virtual Service per Cluster that a Deployment or
+// StatefulSet selected by the root Service was placed on, and aggregates
+// their EndpointSlices. A root labeled for a Cluster is synced as it is,
+// and Services without a selector, whose endpoints are managed by hand,
+// aren't mirrored.
+func (c *Controller) reconcileRoot(ctx context.Context, root *corev1.Service) error {
+	logger := logging.FromContext(ctx)
+
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+
+	clusters := sets.NewString()
+	if root.Labels[clusterLabel] == "" && len(root.Spec.Selector) > 0 {
+		if clusters, err = c.clustersFor(root); err != nil {
+			return err
+		}
+	}
+
+	existing := map[string]*corev1.Service{}
+	for _, leaf := range leafs {
+		cluster := leaf.Labels[clusterLabel]
+		if !clusters.Has(cluster) {
+			if err := c.kubeClient.CoreV1().Services(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			logger.Info("Deleted child service", "child", leaf.Name, logging.ClusterKey, cluster)
+			continue
+		}
+		existing[cluster] = leaf
+	}
+
+	for _, cluster := range clusters.List() {
+		want := newLeaf(root, cluster)
+		leaf, found := existing[cluster]
+		if !found {
+			if _, err := c.kubeClient.CoreV1().Services(root.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			logger.Info("Created child service", "child", want.Name, logging.ClusterKey, cluster)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Mirrored", "Mirrored to cluster %s", cluster)
+			continue
+		}
+		if equality.Semantic.DeepEqual(leaf.Labels, want.Labels) &&
+			equality.Semantic.DeepEqual(leaf.Annotations, want.Annotations) &&
+			equality.Semantic.DeepEqual(leaf.Spec, want.Spec) {
+			continue
+		}
+		updated := leaf.DeepCopy()
+		updated.Labels = want.Labels
+		updated.Annotations = want.Annotations
+		updated.Spec = want.Spec
+		if _, err := c.kubeClient.CoreV1().Services(leaf.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logger.V(2).Info("Updated child service", "child", leaf.Name, logging.ClusterKey, cluster)
+	}
+
+	return c.aggregateSlices(ctx, root, leafs, clusters)
+}
+
+// clustersFor returns the Clusters the root Deployments and StatefulSets in
+// the root Service's namespace whose pods it selects were placed on, either
+// as a whole or as leafs.
+func (c *Controller) clustersFor(root *corev1.Service) (sets.String, error) {
+	selector := labels.SelectorFromSet(root.Spec.Selector)
+	clusters := sets.NewString()
+
+	deployments, err := c.deployments(root.ClusterName).Deployments(root.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments {
+		if selector.Matches(labels.Set(d.Spec.Template.Labels)) && d.Labels[clusterLabel] != "" {
+			clusters.Insert(d.Labels[clusterLabel])
+		}
+	}
+	statefulSets, err := c.statefulSets(root.ClusterName).StatefulSets(root.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, ss := range statefulSets {
+		if selector.Matches(labels.Set(ss.Spec.Template.Labels)) && ss.Labels[clusterLabel] != "" {
+			clusters.Insert(ss.Labels[clusterLabel])
+		}
+	}
+	return clusters, nil
+}
+
+// newLeaf returns a virtual Service for the given root, labeled/named for
+// the given Cluster. Its cluster IPs and node ports are left for the Cluster
+// to allocate, since the root's may be taken there.
+func newLeaf(root *corev1.Service, clusterName string) *corev1.Service {
+	vs := root.DeepCopy()
+
+	vs.Name = fmt.Sprintf("%s--%s", root.Name, clusterName)
+	vs.ResourceVersion = ""
+	vs.UID = ""
+
+	if vs.Labels == nil {
+		vs.Labels = map[string]string{}
+	}
+	vs.Labels[clusterLabel] = clusterName
+	vs.Labels[base.LeafLabel] = root.Name
+
+	vs.Spec.ClusterIP = ""
+	vs.Spec.HealthCheckNodePort = 0
+	for i := range vs.Spec.Ports {
+		vs.Spec.Ports[i].NodePort = 0
+	}
+	vs.Status = corev1.ServiceStatus{}
+
+	// Set OwnerReference so deleting the Service deletes all virtual Services.
+	vs.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Name:       root.Name,
+		UID:        root.UID,
+	}}
+	return vs
+}
+
+// leafsFor returns the virtual Services mirrored from the given root, sorted
+// by name.
+func (c *Controller) leafsFor(root *corev1.Service) ([]*corev1.Service, error) {
+	sel, err := labels.Parse(fmt.Sprintf("%s=%s", base.LeafLabel, root.Name))
+	if err != nil {
+		return nil, err
+	}
+	leafs, err := c.services(root.ClusterName).Services(root.Namespace).List(sel)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(leafs, func(i, j int) bool { return leafs[i].Name < leafs[j].Name })
+	return leafs, nil
+}
+
+func namespaceOf(obj interface{}) (string, error) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return m.GetNamespace(), nil
+}
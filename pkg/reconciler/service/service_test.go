@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base/basetest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const workspace = "admin"
+
+type fixture struct {
+	*basetest.Fixture
+	c *Controller
+}
+
+func newFixture(t *testing.T) *fixture {
+	f := &fixture{Fixture: basetest.New(t)}
+	f.c = newController(f.Kube, nil, nil, f.Informers)
+	return f
+}
+
+// addServices creates the Services, as the API server and the cache of the
+// Controller would have them.
+func (f *fixture) addServices(services ...*corev1.Service) {
+	for _, svc := range services {
+		if _, err := f.Kube.CoreV1().Services(svc.Namespace).Create(context.Background(), svc, metav1.CreateOptions{}); err != nil {
+			f.T.Fatal(err)
+		}
+		f.Add(f.Informers.Kube.Core().V1().Services().Informer(), svc)
+	}
+}
+
+// addSlices creates the EndpointSlices, as the API server and the cache of
+// the Controller would have them.
+func (f *fixture) addSlices(slices ...*discoveryv1beta1.EndpointSlice) {
+	for _, slice := range slices {
+		if _, err := f.Kube.DiscoveryV1beta1().EndpointSlices(slice.Namespace).Create(context.Background(), slice, metav1.CreateOptions{}); err != nil {
+			f.T.Fatal(err)
+		}
+		f.Add(f.Informers.Kube.Discovery().V1beta1().EndpointSlices().Informer(), slice)
+	}
+}
+
+// placeOn adds a leaf Deployment of the pods labeled app=web to the cache of
+// the Controller for each Cluster.
+func (f *fixture) placeOn(clusters ...string) {
+	for _, cluster := range clusters {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "web--" + cluster,
+				Namespace:   "default",
+				ClusterName: workspace,
+				Labels:      map[string]string{base.LeafLabel: "web", clusterLabel: cluster},
+			},
+		}
+		d.Spec.Template.Labels = map[string]string{"app": "web"}
+		f.Add(f.Informers.Kube.Apps().V1().Deployments().Informer(), d)
+	}
+}
+
+func (f *fixture) process(name string) error {
+	return f.c.process(context.Background(), logicalcluster.Key(workspace, "default", name))
+}
+
+// services returns the Services in kcp, by name.
+func (f *fixture) services() map[string]*corev1.Service {
+	list, err := f.Kube.CoreV1().Services("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	services := map[string]*corev1.Service{}
+	for i := range list.Items {
+		services[list.Items[i].Name] = &list.Items[i]
+	}
+	return services
+}
+
+// slices returns the EndpointSlices in kcp, by name.
+func (f *fixture) slices() map[string]*discoveryv1beta1.EndpointSlice {
+	list, err := f.Kube.DiscoveryV1beta1().EndpointSlices("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	slices := map[string]*discoveryv1beta1.EndpointSlice{}
+	for i := range list.Items {
+		slices[list.Items[i].Name] = &list.Items[i]
+	}
+	return slices
+}
+
+func root() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ClusterName: workspace, UID: "root-uid"},
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeNodePort,
+			Selector:  map[string]string{"app": "web"},
+			ClusterIP: "10.0.0.1",
+			Ports:     []corev1.ServicePort{{Port: 80, NodePort: 30080}},
+		},
+	}
+}
+
+// upsynced returns an EndpointSlice the syncer of the Cluster wrote back for
+// the virtual Service of the root there.
+func upsynced(cluster, suffix, ip string) *discoveryv1beta1.EndpointSlice {
+	port := int32(8080)
+	return &discoveryv1beta1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web--" + cluster + "-" + suffix,
+			Namespace:   "default",
+			ClusterName: workspace,
+			Labels: map[string]string{
+				discoveryv1beta1.LabelServiceName: "web--" + cluster,
+				upsyncedFromLabel:                 cluster,
+			},
+		},
+		AddressType: discoveryv1beta1.AddressTypeIPv4,
+		Endpoints: []discoveryv1beta1.Endpoint{{
+			Addresses: []string{ip},
+			Topology:  map[string]string{corev1.LabelHostname: "node-1", corev1.LabelZoneFailureDomainStable: "a"},
+			TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-x7k2p"},
+		}},
+		Ports: []discoveryv1beta1.EndpointPort{{Port: &port}},
+	}
+}
+
+func TestMirror(t *testing.T) {
+	f := newFixture(t)
+	f.placeOn("east", "west")
+	f.addServices(root())
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.services()
+	if len(got) != 3 {
+		t.Fatalf("got services %v", got)
+	}
+	for _, cluster := range []string{"east", "west"} {
+		leaf, found := got["web--"+cluster]
+		if !found {
+			t.Fatalf("not mirrored to %s", cluster)
+		}
+		if leaf.Labels[clusterLabel] != cluster || leaf.Labels[base.LeafLabel] != "web" {
+			t.Errorf("%s: got labels %v", cluster, leaf.Labels)
+		}
+		if leaf.Spec.ClusterIP != "" || leaf.Spec.Ports[0].NodePort != 0 {
+			t.Errorf("%s: kept the cluster IP %q and node port %d of the root", cluster, leaf.Spec.ClusterIP, leaf.Spec.Ports[0].NodePort)
+		}
+		if refs := leaf.OwnerReferences; len(refs) != 1 || refs[0].UID != "root-uid" {
+			t.Errorf("%s: got owner references %v", cluster, refs)
+		}
+	}
+}
+
+func TestUnmirror(t *testing.T) {
+	f := newFixture(t)
+	f.placeOn("east")
+	f.addServices(root(), newLeaf(root(), "east"), newLeaf(root(), "west"))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.services()
+	if _, found := got["web--west"]; found {
+		t.Error("kept the virtual Service of a Cluster no workload is on")
+	}
+	if _, found := got["web--east"]; !found {
+		t.Error("deleted the virtual Service of a Cluster a workload is on")
+	}
+}
+
+func TestWithoutSelector(t *testing.T) {
+	f := newFixture(t)
+	f.placeOn("east")
+	svc := root()
+	svc.Spec.Selector = nil
+	f.addServices(svc)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.services(); len(got) != 1 {
+		t.Errorf("mirrored a Service without a selector: %v", got)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	for _, c := range []struct {
+		desc    string
+		root    *corev1.Service
+		deleted bool
+	}{{
+		desc:    "root gone",
+		deleted: true,
+	}, {
+		desc: "root replaced",
+		root: func() *corev1.Service {
+			svc := root()
+			svc.UID = "other-uid"
+			return svc
+		}(),
+		deleted: true,
+	}, {
+		desc: "root there",
+		root: root(),
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			f := newFixture(t)
+			f.placeOn("east")
+			if c.root != nil {
+				f.addServices(c.root)
+			}
+			f.addServices(newLeaf(root(), "east"))
+			if err := f.process("web--east"); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, found := f.services()["web--east"]; found == c.deleted {
+				t.Errorf("leaf found: %v, want %v", found, !c.deleted)
+			}
+		})
+	}
+}
+
+func TestAggregateSlices(t *testing.T) {
+	f := newFixture(t)
+	f.placeOn("east", "west")
+	f.addServices(root(), newLeaf(root(), "east"), newLeaf(root(), "west"))
+	f.addSlices(upsynced("east", "x7k2p", "10.1.0.1"), upsynced("west", "b9c3d", "10.2.0.1"))
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.slices()
+	for name, want := range map[string]string{"web-east-x7k2p": "10.1.0.1", "web-west-b9c3d": "10.2.0.1"} {
+		slice, found := got[name]
+		if !found {
+			t.Fatalf("no aggregated slice %s in %v", name, got)
+		}
+		if slice.Labels[discoveryv1beta1.LabelServiceName] != "web" || slice.Labels[discoveryv1beta1.LabelManagedBy] != managedBy {
+			t.Errorf("%s: got labels %v", name, slice.Labels)
+		}
+		if len(slice.Endpoints) != 1 || slice.Endpoints[0].Addresses[0] != want {
+			t.Fatalf("%s: got endpoints %v", name, slice.Endpoints)
+		}
+		ep := slice.Endpoints[0]
+		if _, found := ep.Topology[corev1.LabelHostname]; found || ep.TargetRef != nil {
+			t.Errorf("%s: kept the node %q and pod %v of the Cluster", name, ep.Topology[corev1.LabelHostname], ep.TargetRef)
+		}
+		if ep.Topology[corev1.LabelZoneFailureDomainStable] != "a" {
+			t.Errorf("%s: got topology %v", name, ep.Topology)
+		}
+	}
+}
+
+func TestAggregateSlicesUpdate(t *testing.T) {
+	f := newFixture(t)
+	f.placeOn("east")
+	f.addServices(root(), newLeaf(root(), "east"))
+	source := upsynced("east", "x7k2p", "10.1.0.2")
+	stale := aggregatedSlice(root(), newLeaf(root(), "east"), upsynced("east", "x7k2p", "10.1.0.1"))
+	stale.ClusterName = workspace
+	f.addSlices(source, stale)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.slices()["web-east-x7k2p"].Endpoints; len(got) != 1 || got[0].Addresses[0] != "10.1.0.2" {
+		t.Errorf("got endpoints %v", got)
+	}
+}
+
+func TestAggregateSlicesGC(t *testing.T) {
+	f := newFixture(t)
+	f.placeOn("east")
+	// The west slice's virtual Service was just deleted, as its Cluster no
+	// longer runs the workload.
+	west := aggregatedSlice(root(), newLeaf(root(), "west"), upsynced("west", "b9c3d", "10.2.0.1"))
+	west.ClusterName = workspace
+	f.addServices(root(), newLeaf(root(), "east"))
+	f.addSlices(upsynced("east", "x7k2p", "10.1.0.1"), west)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := f.slices()
+	if _, found := got["web-west-b9c3d"]; found {
+		t.Error("kept the aggregated slice of a Cluster no workload is on")
+	}
+	if _, found := got["web-east-x7k2p"]; !found {
+		t.Error("deleted the aggregated slice of a Cluster a workload is on")
+	}
+}
+
+func TestDeletedRoot(t *testing.T) {
+	f := newFixture(t)
+	slice := aggregatedSlice(root(), newLeaf(root(), "east"), upsynced("east", "x7k2p", "10.1.0.1"))
+	slice.ClusterName = workspace
+	f.addSlices(slice)
+	if err := f.process("web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.slices(); len(got) != 0 {
+		t.Errorf("kept the aggregated slices of a deleted root: %v", got)
+	}
+}
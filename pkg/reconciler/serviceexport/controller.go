@@ -0,0 +1,166 @@
+// Package serviceexport derives a ServiceImport from each ServiceExport,
+// following the Kubernetes Multi-Cluster Services API, for the Service of
+// the same name: it records the Clusters the Service has endpoints on, as
+// the service controller aggregates them, and the syncers program it on
+// their Cluster.
+package serviceexport
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	discoveryv1beta1lister "k8s.io/client-go/listers/discovery/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which keeps a ServiceImport per
+// ServiceExport, with the ports and type of the exported Service and the
+// Clusters it has endpoints on, and sets the Valid condition of the
+// ServiceExport.
+//
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), clusterclient.NewForConfigOrDie(cfg).ClusterV1alpha1(), leaderElection, retry, shared)
+	if own {
+		shared.Kube.Start(c.StopCh())
+		shared.Cluster.Start(c.StopCh())
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, client clusterv1alpha1.ClusterV1alpha1Interface, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	sif, csif := shared.Kube, shared.Cluster
+	exports := csif.Cluster().V1alpha1().ServiceExports().Informer()
+	imports := csif.Cluster().V1alpha1().ServiceImports().Informer()
+	services := sif.Core().V1().Services().Informer()
+	slices := sif.Discovery().V1beta1().EndpointSlices().Informer()
+
+	c := &Controller{
+		client:         client,
+		indexer:        logicalcluster.IndexerFor(exports),
+		importIndexer:  logicalcluster.IndexerFor(imports),
+		serviceIndexer: logicalcluster.IndexerFor(services),
+		sliceIndexer:   logicalcluster.IndexerFor(slices),
+	}
+	c.Controller = base.New("serviceexport", kubeClient, leaderElection, retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		exports.HasSynced,
+		imports.HasSynced,
+		services.HasSynced,
+		slices.HasSynced,
+	)
+
+	// A ServiceExport, its ServiceImport and its Service share their key.
+	sameKey := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.Enqueue(obj) },
+		DeleteFunc: func(obj interface{}) { c.Enqueue(obj) },
+	}
+	exports.AddEventHandler(sameKey)
+	imports.AddEventHandler(sameKey)
+	services.AddEventHandler(sameKey)
+	// The aggregated EndpointSlices tell which Clusters have endpoints.
+	slices.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueServiceOf(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueServiceOf(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueServiceOf(obj) },
+	})
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	client         clusterv1alpha1.ClusterV1alpha1Interface
+	indexer        cache.Indexer
+	importIndexer  cache.Indexer
+	serviceIndexer cache.Indexer
+	sliceIndexer   cache.Indexer
+}
+
+// The listers of the objects of a workspace. Services are only imported by
+// the Clusters of their own workspace.
+
+func (c *Controller) imports(workspace string) clusterlisters.ServiceImportLister {
+	return clusterlisters.NewServiceImportLister(logicalcluster.Scoped(c.importIndexer, workspace))
+}
+
+func (c *Controller) services(workspace string) corev1lister.ServiceLister {
+	return corev1lister.NewServiceLister(logicalcluster.Scoped(c.serviceIndexer, workspace))
+}
+
+func (c *Controller) slices(workspace string) discoveryv1beta1lister.EndpointSliceLister {
+	return discoveryv1beta1lister.NewEndpointSliceLister(logicalcluster.Scoped(c.sliceIndexer, workspace))
+}
+
+// enqueueServiceOf enqueues the ServiceExport of the Service an
+// EndpointSlice is for.
+func (c *Controller) enqueueServiceOf(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	slice, ok := obj.(*discoveryv1beta1.EndpointSlice)
+	if !ok || slice.Labels[discoveryv1beta1.LabelServiceName] == "" {
+		return
+	}
+	c.Queue().Add(logicalcluster.Key(slice.ClusterName, slice.Namespace, slice.Labels[discoveryv1beta1.LabelServiceName]))
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		workspace, namespace, name, err := logicalcluster.SplitKey(key)
+		if err != nil {
+			return err
+		}
+		return c.deleteImport(ctx, workspace, namespace, name)
+	}
+	current := obj.(*v1alpha1.ServiceExport).DeepCopy()
+	previous := current.DeepCopy()
+
+	if err := c.reconcile(ctx, current); err != nil {
+		return err
+	}
+
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		logging.FromContext(ctx).V(2).Info("Updating service export status")
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (runtime.Object, error) {
+				return c.client.ServiceExports(current.Namespace).Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+				return c.client.ServiceExports(current.Namespace).Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		return err
+	}
+	return nil
+}
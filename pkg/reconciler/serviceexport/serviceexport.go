@@ -0,0 +1,187 @@
+package serviceexport
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// upsyncedFromLabel is set by the syncer on the EndpointSlices it writes
+	// back to kcp, by the cluster ID, and endpointsClusterLabel by the
+	// service controller on those it aggregates them into.
+	upsyncedFromLabel     = "experimental.kcp.dev/upsynced-from"
+	endpointsClusterLabel = "experimental.kcp.dev/endpoints-cluster"
+
+	// clusterSetDomain is the domain the Multi-Cluster Services API
+	// resolves ServiceImports in.
+	clusterSetDomain = "svc.clusterset.local"
+)
+
+// DNSName returns the name that clients on any Cluster reach the named
+// exported Service as.
+func DNSName(namespace, name string) string {
+	return fmt.Sprintf("%s.%s.%s", name, namespace, clusterSetDomain)
+}
+
+// reconcile keeps the ServiceImport of the ServiceExport in line with the
+// exported Service, and deletes it when the Service is gone or can't be
+// imported. A ServiceImport the ServiceExport didn't derive is left alone.
+func (c *Controller) reconcile(ctx context.Context, export *v1alpha1.ServiceExport) error {
+	logger := logging.FromContext(ctx)
+
+	svc, err := c.services(export.ClusterName).Services(export.Namespace).Get(export.Name)
+	if errors.IsNotFound(err) {
+		conditions.MarkFalse(export, v1alpha1.ServiceExportConditionValid, "ServiceNotFound", fmt.Sprintf("Service %s not found", export.Name))
+		return c.deleteImport(ctx, export.ClusterName, export.Namespace, export.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		conditions.MarkFalse(export, v1alpha1.ServiceExportConditionValid, "ExternalNameService", "ExternalName Services can't be exported")
+		return c.deleteImport(ctx, export.ClusterName, export.Namespace, export.Name)
+	}
+
+	clusters, err := c.endpointClusters(svc)
+	if err != nil {
+		return err
+	}
+	want := newImport(export, svc, clusters)
+
+	current, err := c.imports(export.ClusterName).ServiceImports(export.Namespace).Get(export.Name)
+	switch {
+	case errors.IsNotFound(err):
+		if current, err = c.client.ServiceImports(export.Namespace).Create(ctx, want, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		logger.Info("Created service import")
+	case err != nil:
+		return err
+	case !metav1.IsControlledBy(current, export):
+		conditions.MarkFalse(export, v1alpha1.ServiceExportConditionValid, "Conflict", fmt.Sprintf("ServiceImport %s exists and wasn't derived from this ServiceExport", export.Name))
+		return nil
+	case !equality.Semantic.DeepEqual(current.Spec, want.Spec):
+		updated := current.DeepCopy()
+		updated.Spec = want.Spec
+		if current, err = c.client.ServiceImports(export.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logger.V(2).Info("Updated service import")
+	}
+	if err := c.patchImportStatus(ctx, current, want.Status); err != nil {
+		return err
+	}
+	conditions.MarkTrue(export, v1alpha1.ServiceExportConditionValid, "Exported", fmt.Sprintf("Service is reachable as %s", DNSName(export.Namespace, export.Name)))
+	return nil
+}
+
+// patchImportStatus sets the status of a ServiceImport, if it differs.
+func (c *Controller) patchImportStatus(ctx context.Context, current *v1alpha1.ServiceImport, status v1alpha1.ServiceImportStatus) error {
+	if equality.Semantic.DeepEqual(current.Status, status) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Status = status
+	_, err := base.PatchStatus(ctx, c.FieldManager(), current, updated, base.StatusClient{
+		Get: func(ctx context.Context) (runtime.Object, error) {
+			return c.client.ServiceImports(current.Namespace).Get(ctx, current.Name, metav1.GetOptions{})
+		},
+		Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+			return c.client.ServiceImports(current.Namespace).Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+		},
+	})
+	return err
+}
+
+// deleteImport deletes the named ServiceImport of the workspace, if it was
+// derived from a ServiceExport.
+func (c *Controller) deleteImport(ctx context.Context, workspace, namespace, name string) error {
+	current, err := c.imports(workspace).ServiceImports(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ref := metav1.GetControllerOf(current); ref == nil || ref.Kind != "ServiceExport" {
+		return nil
+	}
+	if err := c.client.ServiceImports(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	logging.FromContext(ctx).Info("Deleted service import")
+	return nil
+}
+
+// endpointClusters returns the Clusters that the EndpointSlices of the
+// Service in kcp have endpoints on, sorted: the aggregated ones of a root
+// mirrored to several Clusters, or those written back for a Service synced
+// as it is.
+func (c *Controller) endpointClusters(svc *corev1.Service) ([]string, error) {
+	sel := labels.SelectorFromSet(labels.Set{discoveryv1beta1.LabelServiceName: svc.Name})
+	slices, err := c.slices(svc.ClusterName).EndpointSlices(svc.Namespace).List(sel)
+	if err != nil {
+		return nil, err
+	}
+	clusters := sets.NewString()
+	for _, slice := range slices {
+		cluster := slice.Labels[endpointsClusterLabel]
+		if cluster == "" {
+			cluster = slice.Labels[upsyncedFromLabel]
+		}
+		if cluster != "" && len(slice.Endpoints) > 0 {
+			clusters.Insert(cluster)
+		}
+	}
+	return clusters.List(), nil
+}
+
+// newImport returns the ServiceImport derived from a ServiceExport, for the
+// given Service with endpoints on the given Clusters. Its IPs are left for
+// each Cluster to allocate.
+func newImport(export *v1alpha1.ServiceExport, svc *corev1.Service, clusters []string) *v1alpha1.ServiceImport {
+	imp := &v1alpha1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        export.Name,
+			Namespace:   export.Namespace,
+			ClusterName: export.ClusterName,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(export, v1alpha1.SchemeGroupVersion.WithKind("ServiceExport")),
+			},
+		},
+		Spec: v1alpha1.ServiceImportSpec{
+			Type:                  v1alpha1.ServiceImportTypeClusterSetIP,
+			SessionAffinity:       svc.Spec.SessionAffinity,
+			SessionAffinityConfig: svc.Spec.SessionAffinityConfig.DeepCopy(),
+		},
+	}
+	if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		imp.Spec.Type = v1alpha1.ServiceImportTypeHeadless
+	}
+	for _, p := range svc.Spec.Ports {
+		imp.Spec.Ports = append(imp.Spec.Ports, v1alpha1.ServiceImportPort{
+			Name:        p.Name,
+			Protocol:    p.Protocol,
+			AppProtocol: p.AppProtocol,
+			Port:        p.Port,
+		})
+	}
+	for _, cluster := range clusters {
+		imp.Status.Clusters = append(imp.Status.Clusters, v1alpha1.ServiceImportCluster{Cluster: cluster})
+	}
+	return imp
+}
@@ -0,0 +1,147 @@
+package serviceexport
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base/basetest"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const workspace = "admin"
+
+type fixture struct {
+	*basetest.Fixture
+	c *Controller
+}
+
+func newFixture(t *testing.T, export *v1alpha1.ServiceExport, objs ...runtime.Object) *fixture {
+	f := &fixture{Fixture: basetest.New(t, export)}
+	f.c = newController(f.Kube, f.Clusters.ClusterV1alpha1(), nil, nil, f.Informers)
+	f.Add(f.Informers.Cluster.Cluster().V1alpha1().ServiceExports().Informer(), export)
+	for _, obj := range objs {
+		switch obj := obj.(type) {
+		case *corev1.Service:
+			f.Add(f.Informers.Kube.Core().V1().Services().Informer(), obj)
+		case *discoveryv1beta1.EndpointSlice:
+			f.Add(f.Informers.Kube.Discovery().V1beta1().EndpointSlices().Informer(), obj)
+		}
+	}
+	return f
+}
+
+// sync makes the cache of the Controller hold the ServiceImports of kcp,
+// then processes the ServiceExport.
+func (f *fixture) sync() {
+	list, err := f.Clusters.ClusterV1alpha1().ServiceImports("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	f.Replace(f.Informers.Cluster.Cluster().V1alpha1().ServiceImports().Informer(), list)
+	if err := f.c.process(context.Background(), logicalcluster.Key(workspace, "default", "web")); err != nil {
+		f.T.Fatal(err)
+	}
+}
+
+func (f *fixture) export() *v1alpha1.ServiceExport {
+	export, err := f.Clusters.ClusterV1alpha1().ServiceExports("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	return export
+}
+
+func newExport() *v1alpha1.ServiceExport {
+	return &v1alpha1.ServiceExport{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ClusterName: workspace, UID: types.UID("web-export")},
+	}
+}
+
+func service(clusterIP string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ClusterName: workspace},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: clusterIP,
+			Selector:  map[string]string{"app": "web"},
+			Ports:     []corev1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}},
+		},
+	}
+}
+
+func slice(name string, labels map[string]string, addresses ...string) *discoveryv1beta1.EndpointSlice {
+	labels[discoveryv1beta1.LabelServiceName] = "web"
+	s := &discoveryv1beta1.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Namespace: "default", ClusterName: workspace, Labels: labels},
+		AddressType: discoveryv1beta1.AddressTypeIPv4,
+	}
+	if len(addresses) > 0 {
+		s.Endpoints = []discoveryv1beta1.Endpoint{{Addresses: addresses}}
+	}
+	return s
+}
+
+func TestExport(t *testing.T) {
+	f := newFixture(t, newExport(),
+		service("10.0.0.1"),
+		slice("web-us-east-abcde", map[string]string{endpointsClusterLabel: "us-east"}, "10.1.0.4"),
+		slice("web-eu-west-fghij", map[string]string{endpointsClusterLabel: "eu-west"}, "10.2.0.7"),
+		slice("web-ap-south-klmno", map[string]string{endpointsClusterLabel: "ap-south"}),
+	)
+	f.sync()
+
+	imp, err := f.Clusters.ClusterV1alpha1().ServiceImports("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Spec.Type != v1alpha1.ServiceImportTypeClusterSetIP {
+		t.Errorf("got type %s, want ClusterSetIP", imp.Spec.Type)
+	}
+	if want := []v1alpha1.ServiceImportPort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}}; !reflect.DeepEqual(imp.Spec.Ports, want) {
+		t.Errorf("got ports %v, want %v", imp.Spec.Ports, want)
+	}
+	// The Cluster without endpoints doesn't serve the Service.
+	if want := []v1alpha1.ServiceImportCluster{{Cluster: "eu-west"}, {Cluster: "us-east"}}; !reflect.DeepEqual(imp.Status.Clusters, want) {
+		t.Errorf("got clusters %v, want %v", imp.Status.Clusters, want)
+	}
+	cond := conditions.Get(f.export(), v1alpha1.ServiceExportConditionValid)
+	if cond == nil || cond.Status != corev1.ConditionTrue || cond.Message != "Service is reachable as web.default.svc.clusterset.local" {
+		t.Errorf("got Valid condition %v", cond)
+	}
+}
+
+func TestExportHeadless(t *testing.T) {
+	f := newFixture(t, newExport(), service(corev1.ClusterIPNone))
+	f.sync()
+
+	imp, err := f.Clusters.ClusterV1alpha1().ServiceImports("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Spec.Type != v1alpha1.ServiceImportTypeHeadless {
+		t.Errorf("got type %s, want Headless", imp.Spec.Type)
+	}
+}
+
+func TestExportWithoutService(t *testing.T) {
+	f := newFixture(t, newExport())
+	f.sync()
+
+	if !conditions.IsFalse(f.export(), v1alpha1.ServiceExportConditionValid) {
+		t.Errorf("got Valid condition %v, want False", conditions.Get(f.export(), v1alpha1.ServiceExportConditionValid))
+	}
+	list, err := f.Clusters.ClusterV1alpha1().ServiceImports("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("got %d service imports, want none", len(list.Items))
+	}
+}
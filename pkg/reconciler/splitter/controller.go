@@ -0,0 +1,262 @@
+// Package splitter splits objects of any namespaced resource across Clusters
+// with the dynamic client, according to a per-resource Strategy. Leafs'
+// status is only aggregated back into the root by Strategies that are also
+// StatusAggregators.
+package splitter
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which splits objects of the given
+// resource into leafs labeled for each Ready Cluster allowed by their
+// PlacementPolicy, as decided by strategy, and keeps them in line with the
+// root and the set of Clusters.
+//
+// The Clusters that are left are filtered and scored by sched, if not nil,
+// and the leafs are transformed for their Cluster by transforms.
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, gvr schema.GroupVersionResource, strategy Strategy, sched *scheduler.Scheduler, transforms transform.Chain, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	dynamicClient := dynamic.NewForConfigOrDie(cfg)
+	dsif := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, resyncPeriod)
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := New(Options{
+		KubeClient:       kubernetes.NewForConfigOrDie(cfg),
+		DynamicClient:    dynamicClient,
+		Informers:        shared,
+		DynamicInformers: dsif,
+		Resource:         gvr,
+		Strategy:         strategy,
+		Scheduler:        sched,
+		Transforms:       transforms,
+		LeaderElection:   leaderElection,
+		Retry:            retry,
+	})
+	dsif.Start(c.StopCh())
+	if own {
+		shared.Cluster.Start(c.StopCh())
+	}
+	return c
+}
+
+// Options are what a Controller reaches kcp with, and how it splits the
+// objects of Resource, as NewController describes.
+type Options struct {
+	KubeClient    kubernetes.Interface
+	DynamicClient dynamic.Interface
+	// Informers and DynamicInformers fill the Controller's caches, and are
+	// started by the caller.
+	Informers        *base.Informers
+	DynamicInformers dynamicinformer.DynamicSharedInformerFactory
+
+	Resource       schema.GroupVersionResource
+	Strategy       Strategy
+	Scheduler      *scheduler.Scheduler
+	Transforms     transform.Chain
+	LeaderElection *base.LeaderElectionConfig
+	Retry          *base.RetryPolicy
+}
+
+// New returns a Controller as NewController does, reaching kcp with the
+// clients of opts rather than a config, such as fake ones.
+func New(opts Options) *Controller {
+	gvr, transforms := opts.Resource, opts.Transforms
+	csif := opts.Informers.Cluster
+	informer := opts.DynamicInformers.ForResource(gvr)
+
+	c := &Controller{
+		gvr:            gvr,
+		strategy:       opts.Strategy,
+		client:         opts.DynamicClient.Resource(gvr),
+		indexer:        logicalcluster.IndexerFor(informer.Informer()),
+		clusterIndexer: logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Clusters().Informer()),
+		policyIndexer:  logicalcluster.IndexerFor(csif.Cluster().V1alpha1().PlacementPolicies().Informer()),
+		scheduler:      opts.Scheduler,
+		transforms:     transforms,
+	}
+	c.orphans = base.NewOrphans(gvr, c.indexer, leafClient{c.client})
+	c.Controller = base.New(gvr.Resource, opts.KubeClient, opts.LeaderElection, opts.Retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.SetBatchLister(c.listRoots)
+	c.AddCacheSyncs(
+		informer.Informer().HasSynced,
+		csif.Cluster().V1alpha1().Clusters().Informer().HasSynced,
+		csif.Cluster().V1alpha1().PlacementPolicies().Informer().HasSynced,
+	)
+	c.AddPeriodic(c.orphans.Collect, gcInterval)
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(oldObj, obj interface{}) {
+			// A root's status is only that aggregated from its leafs.
+			if u, ok := obj.(*unstructured.Unstructured); ok && u.GetLabels()[base.LeafLabel] == "" && base.StatusOnly(oldObj, obj) {
+				return
+			}
+			if base.Resynced(oldObj, obj) {
+				c.EnqueueLow(obj)
+				return
+			}
+			c.Enqueue(obj)
+		},
+	})
+
+	// Clusters joining, leaving or changing readiness, eviction, failback, weight, taints, cordon or, when
+	// transforming leafs, labels and annotations affect every root's placement in their workspace,
+	// so re-split all of them.
+	csif.Cluster().V1alpha1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCluster, newCluster := oldObj.(*v1alpha1.Cluster), newObj.(*v1alpha1.Cluster)
+			if oldCluster.Status.Conditions.IsReady() != newCluster.Status.Conditions.IsReady() ||
+				oldCluster.Status.Conditions.IsEvicted() != newCluster.Status.Conditions.IsEvicted() ||
+				oldCluster.Annotations[placement.FailbackAnnotation] != newCluster.Annotations[placement.FailbackAnnotation] ||
+				oldCluster.EffectiveWeight() != newCluster.EffectiveWeight() ||
+				oldCluster.Spec.Unschedulable != newCluster.Spec.Unschedulable ||
+				!equality.Semantic.DeepEqual(oldCluster.Spec.Taints, newCluster.Spec.Taints) ||
+				len(transforms) > 0 && (!equality.Semantic.DeepEqual(oldCluster.Labels, newCluster.Labels) ||
+					!equality.Semantic.DeepEqual(oldCluster.Annotations, newCluster.Annotations)) {
+				c.enqueueRoots(newObj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	// A PlacementPolicy's selectors may match any root in its namespace.
+	csif.Cluster().V1alpha1().PlacementPolicies().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	if len(transforms) > 0 {
+		// The WorkloadOverrides of a namespace may apply to any root in it.
+		csif.Cluster().V1alpha1().WorkloadOverrides().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+			DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		})
+	}
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	gvr      schema.GroupVersionResource
+	strategy Strategy
+
+	client         dynamic.NamespaceableResourceInterface
+	indexer        cache.Indexer
+	clusterIndexer cache.Indexer
+	policyIndexer  cache.Indexer
+	orphans        *base.Orphans
+	scheduler      *scheduler.Scheduler
+	transforms     transform.Chain
+}
+
+// The listers of the objects of a workspace. Roots are only split across
+// the Clusters of their own workspace, following its PlacementPolicies.
+
+func (c *Controller) objects(workspace string) cache.GenericLister {
+	return cache.NewGenericLister(logicalcluster.Scoped(c.indexer, workspace), c.gvr.GroupResource())
+}
+
+func (c *Controller) clusters(workspace string) clusterlisters.ClusterLister {
+	return clusterlisters.NewClusterLister(logicalcluster.Scoped(c.clusterIndexer, workspace))
+}
+
+func (c *Controller) policies(workspace string) clusterlisters.PlacementPolicyLister {
+	return clusterlisters.NewPlacementPolicyLister(logicalcluster.Scoped(c.policyIndexer, workspace))
+}
+
+// enqueueRoots enqueues every root object, i.e. those not split from another
+// one, of the workspace of the given Cluster or PlacementPolicy.
+func (c *Controller) enqueueRoots(obj interface{}) {
+	workspace, err := logicalcluster.ClusterName(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.EnqueueWorkspace(workspace)
+}
+
+// listRoots lists the root objects of the workspace, for EnqueueWorkspace to
+// enqueue.
+func (c *Controller) listRoots(workspace string) ([]interface{}, error) {
+	objs, err := c.objects(workspace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var roots []interface{}
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok && u.GetLabels()[base.LeafLabel] == "" {
+			roots = append(roots, u)
+		}
+	}
+	return roots, nil
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*unstructured.Unstructured).DeepCopy()
+	previous := current.DeepCopy()
+
+	// After a partial failure, the root is still updated for the leafs that
+	// were written.
+	reconcileErr := c.reconcile(ctx, current)
+	if _, partial := base.PartialFailure(reconcileErr); reconcileErr != nil && !partial {
+		return reconcileErr
+	}
+
+	// If the status of the object being reconciled changed as a result, update it.
+	if !equality.Semantic.DeepEqual(previous.Object["status"], current.Object["status"]) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (apiruntime.Object, error) {
+				return c.client.Namespace(current.GetNamespace()).Get(ctx, current.GetName(), metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (apiruntime.Object, error) {
+				return c.client.Namespace(current.GetNamespace()).Patch(ctx, current.GetName(), types.MergePatchType, data, opts, "status")
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return reconcileErr
+}
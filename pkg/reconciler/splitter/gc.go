@@ -0,0 +1,27 @@
+package splitter
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// leafClient is the base.LeafClient orphaned leafs are checked and deleted
+// with, as the Deployment splitter does, since kcp doesn't run the garbage
+// collector.
+type leafClient struct {
+	dynamic.NamespaceableResourceInterface
+}
+
+func (c leafClient) Get(ctx context.Context, namespace, name string) (metav1.Object, error) {
+	obj, err := c.Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (c leafClient) Delete(ctx context.Context, namespace, name string) error {
+	return c.Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
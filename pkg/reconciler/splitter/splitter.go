@@ -0,0 +1,362 @@
+package splitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	clusterLabel = "cluster"
+	gcInterval   = time.Minute
+)
+
+func (c *Controller) reconcile(ctx context.Context, obj *unstructured.Unstructured) error {
+	logging.FromContext(ctx).V(2).Info("Reconciling object")
+
+	if obj.GetLabels()[base.LeafLabel] == "" {
+		// This is a root; make sure its leafs match the current set of Clusters.
+		return c.reconcileRoot(ctx, obj)
+	}
+
+	if deleted, err := c.orphans.DeleteIfOrphaned(ctx, obj); err != nil || deleted {
+		return err
+	}
+	if _, ok := c.strategy.(StatusAggregator); ok {
+		// A leaf was updated; its root aggregates the status of all leafs.
+		c.Queue().Add(logicalcluster.Key(obj.GetClusterName(), obj.GetNamespace(), obj.GetLabels()[base.LeafLabel]))
+	}
+	return nil
+}
+
+// reconcileRoot splits a root across the Ready Clusters allowed by its
+// PlacementPolicy, if any, keeping one leaf per Cluster returned by the
+// Strategy. Unlike the Deployment splitter, even a root placed on a single
+// Cluster gets a leaf, so the root itself is never synced. Roots with
+// scheduling disabled are left alone, and pinned ones use the Pin Strategy.
+func (c *Controller) reconcileRoot(ctx context.Context, root *unstructured.Unstructured) error {
+	mode, err := placement.SchedulingModeFor(root.GetAnnotations())
+	if err != nil {
+		// Retrying won't help until the annotation is fixed.
+		c.Recorder().Event(root, corev1.EventTypeWarning, "InvalidSchedulingMode", err.Error())
+		return nil
+	}
+	if mode == placement.SchedulingModeDisabled {
+		return nil
+	}
+	tolerations, err := placement.TolerationsFor(root.GetAnnotations())
+	if err != nil {
+		c.Recorder().Event(root, corev1.EventTypeWarning, "InvalidTolerations", err.Error())
+		return nil
+	}
+
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+
+	cls, err := placement.ReadyClusters(c.clusters(root.GetClusterName()))
+	if err != nil {
+		return err
+	}
+	cls = placement.Syncing(cls, c.gvr.GroupResource())
+	if ready := len(cls); ready > 0 {
+		if cls = placement.Untainted(cls, tolerations); len(cls) == 0 {
+			// As with no Clusters, keep the current placement until a taint is lifted.
+			c.Recorder().Eventf(root, corev1.EventTypeWarning, "ClustersTainted", "All %d Ready clusters have taints the object doesn't tolerate", ready)
+			return nil
+		}
+	}
+
+	policy, err := placement.PolicyFor(c.policies(root.GetClusterName()), root.GetNamespace(), root.GetLabels())
+	if err != nil {
+		return err
+	}
+	others, err := c.othersPlaced(root, policy)
+	if err != nil {
+		return err
+	}
+	if len(cls) > 0 {
+		var msg string
+		cls, msg, err = placement.Place(policy, cls, others)
+		if err != nil {
+			return err
+		}
+		if msg != "" {
+			// Keep the current placement until the policy can be met.
+			c.Recorder().Event(root, corev1.EventTypeWarning, "PlacementUnsatisfiable", msg)
+			return nil
+		}
+		placed := sets.NewString()
+		for _, leaf := range leafs {
+			placed.Insert(leaf.GetLabels()[clusterLabel])
+		}
+		var wait time.Duration
+		cls, wait = placement.FailingBack(policy, cls, placed, time.Now())
+		if wait > 0 {
+			c.Queue().AddAfter(logicalcluster.Key(root.GetClusterName(), root.GetNamespace(), root.GetName()), wait)
+		}
+	}
+
+	result, err := c.scheduler.Schedule(ctx, scheduler.Workload{Resource: c.gvr, Object: root}, cls)
+	if err != nil {
+		return err
+	}
+	if result.Message != "" {
+		c.Recorder().Event(root, corev1.EventTypeWarning, "PlacementUnsatisfiable", result.Message)
+		return nil
+	}
+	cls = result.Clusters
+
+	if len(cls) == 0 {
+		// Keep whatever was placed before, it will be rebalanced once a Cluster is Ready again.
+		c.Recorder().Eventf(root, corev1.EventTypeWarning, "NoRegisteredClusters", "kcp has no clusters registered to receive %s", c.gvr.Resource)
+		return nil
+	}
+
+	strategy := c.strategy
+	if mode == placement.SchedulingModePinned {
+		cls = result.Ranked(placement.Rank(policy, cls))
+		pin := Pin{}
+		if len(leafs) > 0 {
+			pin.FailBack = placement.FailsBack(policy, cls, leafs[0].GetLabels()[clusterLabel])
+		}
+		strategy = pin
+	}
+	desired, err := strategy.Split(root, cls, leafs)
+	var unsatisfiable *UnsatisfiableError
+	if errors.As(err, &unsatisfiable) {
+		c.Recorder().Event(root, corev1.EventTypeWarning, "PlacementUnsatisfiable", unsatisfiable.Message)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := c.sync(ctx, root, leafs, desired, tolerations)
+	failed, partial := base.PartialFailure(err)
+	if err != nil && !partial {
+		return err
+	}
+	if partial {
+		// The status of unstructured roots has no conditions known to be
+		// theirs to report it in.
+		c.Recorder().Eventf(root, corev1.EventTypeWarning, "LeafsFailed", "Failed to write the leafs of clusters %s: %v", strings.Join(failed.Clusters(), ", "), failed)
+	}
+
+	// Pinned roots are aggregated by the configured Strategy too.
+	if aggregator, ok := c.strategy.(StatusAggregator); ok {
+		if aggErr := aggregator.AggregateStatus(root, current); aggErr != nil {
+			return aggErr
+		}
+	}
+	return err
+}
+
+// sync creates, updates and deletes leafs so that there's exactly one per
+// Cluster in desired, matching its content. Leafs on Clusters that aren't
+// Ready, but weren't evicted from yet, or that are cordoned, are left as they
+// are. It returns the leafs that were kept, so their status can be
+// aggregated.
+func (c *Controller) sync(ctx context.Context, root *unstructured.Unstructured, leafs []*unstructured.Unstructured, desired map[string]*unstructured.Unstructured, tolerations []corev1.Toleration) ([]*unstructured.Unstructured, error) {
+	logger := logging.FromContext(ctx)
+	client := c.client.Namespace(root.GetNamespace())
+
+	existing := map[string]*unstructured.Unstructured{}
+	var kept []*unstructured.Unstructured
+	for _, leaf := range leafs {
+		clusterName := leaf.GetLabels()[clusterLabel]
+		if _, ok := desired[clusterName]; !ok && existing[clusterName] == nil {
+			cl, err := placement.GetCluster(c.clusters(root.GetClusterName()), clusterName)
+			if err != nil {
+				return nil, err
+			}
+			if placement.Tolerated(cl, tolerations) {
+				// Leave it be until its Cluster is Ready again or evicted from.
+				existing[clusterName] = leaf
+				kept = append(kept, leaf)
+				continue
+			}
+		}
+		if _, ok := desired[clusterName]; !ok || existing[clusterName] != nil {
+			// This leaf's Cluster is gone, not Ready or not chosen anymore, or it's a duplicate.
+			if err := client.Delete(ctx, leaf.GetName(), metav1.DeleteOptions{}); err != nil {
+				metrics.SyncErrors.WithLabelValues(c.gvr.Resource, clusterName).Inc()
+				return nil, err
+			}
+			logger.Info("Deleted child", "child", leaf.GetName(), logging.ClusterKey, clusterName)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Unscheduled", "Removed from cluster %s", clusterName)
+			continue
+		}
+		existing[clusterName] = leaf
+	}
+
+	// The leafs are written to several Clusters at once, and those that
+	// fail don't hold up the others.
+	var lock sync.Mutex
+	names := make([]string, 0, len(desired))
+	for clusterName := range desired {
+		names = append(names, clusterName)
+	}
+	sort.Strings(names)
+	err := c.FanOut(names, func(clusterName string) error {
+		want := newLeaf(root, clusterName, desired[clusterName])
+		if len(c.transforms) > 0 {
+			cl, err := placement.GetCluster(c.clusters(root.GetClusterName()), clusterName)
+			if err != nil {
+				return err
+			}
+			if cl != nil {
+				if err := c.transforms.Apply(ctx, cl, want); err != nil {
+					return err
+				}
+			}
+		}
+		leaf, ok := existing[clusterName]
+		if !ok {
+			tracing.Inject(ctx, want)
+			if _, err := client.Create(ctx, want, metav1.CreateOptions{}); err != nil {
+				metrics.SyncErrors.WithLabelValues(c.gvr.Resource, clusterName).Inc()
+				return err
+			}
+			logger.Info("Created child", "child", want.GetName(), logging.ClusterKey, clusterName)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Scheduled", "Scheduled to cluster %s", clusterName)
+			return nil
+		}
+
+		lock.Lock()
+		kept = append(kept, leaf)
+		lock.Unlock()
+
+		if sameContent(leaf, want) {
+			return nil
+		}
+		want.SetResourceVersion(leaf.GetResourceVersion())
+		tracing.Inject(ctx, want)
+		if _, err := client.Update(ctx, want, metav1.UpdateOptions{}); err != nil {
+			metrics.SyncErrors.WithLabelValues(c.gvr.Resource, clusterName).Inc()
+			return err
+		}
+		logger.Info("Updated child", "child", want.GetName(), logging.ClusterKey, clusterName)
+		return nil
+	})
+	return kept, err
+}
+
+// newLeaf returns a leaf of root for the given Cluster with the given
+// content, labeled/named for that Cluster.
+func newLeaf(root *unstructured.Unstructured, clusterName string, content *unstructured.Unstructured) *unstructured.Unstructured {
+	leaf := content.DeepCopy()
+	unstructured.RemoveNestedField(leaf.Object, "status")
+
+	leaf.SetName(fmt.Sprintf("%s--%s", root.GetName(), clusterName))
+	leaf.SetResourceVersion("")
+	leaf.SetUID("")
+	leaf.SetCreationTimestamp(metav1.Time{})
+	leaf.SetManagedFields(nil)
+
+	l := leaf.GetLabels()
+	if l == nil {
+		l = map[string]string{}
+	}
+	l[clusterLabel] = clusterName
+	l[base.LeafLabel] = root.GetName()
+	leaf.SetLabels(l)
+
+	// Set OwnerReference so deleting the root deletes all its leafs.
+	leaf.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: root.GetAPIVersion(),
+		Kind:       root.GetKind(),
+		Name:       root.GetName(),
+		UID:        root.GetUID(),
+	}})
+	return leaf
+}
+
+// sameContent reports whether an existing leaf already matches want, ignoring
+// status and server-populated metadata.
+func sameContent(leaf, want *unstructured.Unstructured) bool {
+	strip := func(u *unstructured.Unstructured) map[string]interface{} {
+		out := u.DeepCopy().Object
+		delete(out, "metadata")
+		delete(out, "status")
+		return out
+	}
+	// The trace of the last write of the leaf isn't part of its content.
+	annotations := func(u *unstructured.Unstructured) map[string]string {
+		out := map[string]string{}
+		for k, v := range u.GetAnnotations() {
+			if k != tracing.ContextAnnotation {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	return equality.Semantic.DeepEqual(strip(leaf), strip(want)) &&
+		equality.Semantic.DeepEqual(leaf.GetLabels(), want.GetLabels()) &&
+		equality.Semantic.DeepEqual(annotations(leaf), annotations(want))
+}
+
+// leafsFor returns the leafs split from the given root.
+func (c *Controller) leafsFor(root *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	sel, err := labels.Parse(fmt.Sprintf("%s=%s", base.LeafLabel, root.GetName()))
+	if err != nil {
+		return nil, err
+	}
+	objs, err := c.objects(root.GetClusterName()).ByNamespace(root.GetNamespace()).List(sel)
+	if err != nil {
+		return nil, err
+	}
+	leafs := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			leafs = append(leafs, u)
+		}
+	}
+	return leafs, nil
+}
+
+// othersPlaced returns the leafs of the other roots in the root's namespace,
+// if its PlacementPolicy avoids them.
+func (c *Controller) othersPlaced(root *unstructured.Unstructured, policy *v1alpha1.PlacementPolicy) ([]placement.Workload, error) {
+	if !placement.HasAntiAffinity(policy) {
+		return nil, nil
+	}
+	objs, err := c.objects(root.GetClusterName()).ByNamespace(root.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var others []placement.Workload
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		l := u.GetLabels()
+		if l[base.LeafLabel] == "" || l[base.LeafLabel] == root.GetName() || l[clusterLabel] == "" {
+			// Roots are never synced themselves, only their leafs are.
+			continue
+		}
+		others = append(others, placement.Workload{Labels: l, Cluster: l[clusterLabel]})
+	}
+	return others, nil
+}
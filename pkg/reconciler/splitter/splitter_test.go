@@ -0,0 +1,252 @@
+package splitter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base/basetest"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const workspace = "admin"
+
+var widgetsResource = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+type fixture struct {
+	*basetest.Fixture
+	dynamic *dynamicfake.FakeDynamicClient
+	dsif    dynamicinformer.DynamicSharedInformerFactory
+	c       *Controller
+}
+
+func newFixture(t *testing.T, strategy Strategy, clusters ...*v1alpha1.Cluster) *fixture {
+	f := &fixture{Fixture: basetest.New(t), dynamic: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())}
+	f.dsif = dynamicinformer.NewDynamicSharedInformerFactory(f.dynamic, 0)
+	f.c = New(Options{
+		KubeClient:       f.Kube,
+		DynamicClient:    f.dynamic,
+		Informers:        f.Informers,
+		DynamicInformers: f.dsif,
+		Resource:         widgetsResource,
+		Strategy:         strategy,
+	})
+	for _, cl := range clusters {
+		f.Add(f.Informers.Cluster.Cluster().V1alpha1().Clusters().Informer(), cl)
+	}
+	return f
+}
+
+// addWidgets creates the objects, as the API server and the cache of the
+// Controller would have them.
+func (f *fixture) addWidgets(objs ...*unstructured.Unstructured) {
+	for _, obj := range objs {
+		if _, err := f.dynamic.Resource(widgetsResource).Namespace(obj.GetNamespace()).Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+			f.T.Fatal(err)
+		}
+		f.Add(f.dsif.ForResource(widgetsResource).Informer(), obj)
+	}
+}
+
+func (f *fixture) process(name string) error {
+	return f.c.process(context.Background(), logicalcluster.Key(workspace, "default", name))
+}
+
+// widgets returns the objects in kcp, by name.
+func (f *fixture) widgets() map[string]*unstructured.Unstructured {
+	list, err := f.dynamic.Resource(widgetsResource).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	widgets := map[string]*unstructured.Unstructured{}
+	for i := range list.Items {
+		widgets[list.Items[i].GetName()] = &list.Items[i]
+	}
+	return widgets
+}
+
+func readyCluster(name string) *v1alpha1.Cluster {
+	cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: workspace}}
+	cl.Status.Conditions.SetReady(corev1.ConditionTrue, "SyncerReady", "")
+	return cl
+}
+
+func notReadyCluster(name string) *v1alpha1.Cluster {
+	cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, ClusterName: workspace}}
+	cl.Status.Conditions.SetReady(corev1.ConditionFalse, "SyncerNotReady", "")
+	return cl
+}
+
+func widget(name string, replicas int64, labels, annotations map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": replicas},
+	}}
+	u.SetAPIVersion("example.com/v1")
+	u.SetKind("Widget")
+	u.SetName(name)
+	u.SetNamespace("default")
+	u.SetClusterName(workspace)
+	u.SetUID(types.UID(name + "-uid"))
+	u.SetLabels(labels)
+	u.SetAnnotations(annotations)
+	return u
+}
+
+func root(replicas int64, annotations map[string]string) *unstructured.Unstructured {
+	return widget("web", replicas, map[string]string{"app": "web"}, annotations)
+}
+
+func leaf(clusterName string, replicas int64, rootUID types.UID) *unstructured.Unstructured {
+	u := widget("web--"+clusterName, replicas, map[string]string{"app": "web", base.LeafLabel: "web", clusterLabel: clusterName}, nil)
+	u.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Widget", Name: "web", UID: rootUID}})
+	return u
+}
+
+func replicasOf(u *unstructured.Unstructured) int64 {
+	replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	return replicas
+}
+
+func TestReconcileRoot(t *testing.T) {
+	replicas := ReplicaDivide{Path: []string{"spec", "replicas"}}
+	for _, tc := range []struct {
+		name     string
+		strategy Strategy
+		clusters []*v1alpha1.Cluster
+		objs     []*unstructured.Unstructured
+		// want are the replicas of the objects in kcp afterwards, by name.
+		want map[string]int64
+	}{{
+		name:     "split by replicas",
+		strategy: replicas,
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("west")},
+		objs:     []*unstructured.Unstructured{root(3, nil)},
+		want:     map[string]int64{"web": 3, "web--east": 2, "web--west": 1},
+	}, {
+		name:     "split on a single cluster",
+		strategy: replicas,
+		clusters: []*v1alpha1.Cluster{readyCluster("east")},
+		objs:     []*unstructured.Unstructured{root(3, nil)},
+		want:     map[string]int64{"web": 3, "web--east": 3},
+	}, {
+		name:     "duplicated",
+		strategy: Duplicate{},
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("west")},
+		objs:     []*unstructured.Unstructured{root(3, nil)},
+		want:     map[string]int64{"web": 3, "web--east": 3, "web--west": 3},
+	}, {
+		name:     "pinned",
+		strategy: replicas,
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("west")},
+		objs: []*unstructured.Unstructured{root(3, map[string]string{
+			placement.SchedulingModeAnnotation: string(placement.SchedulingModePinned),
+			placement.PinnedClusterAnnotation:  "west",
+		})},
+		want: map[string]int64{"web": 3, "web--west": 3},
+	}, {
+		name:     "scheduling disabled",
+		strategy: replicas,
+		clusters: []*v1alpha1.Cluster{readyCluster("east")},
+		objs:     []*unstructured.Unstructured{root(3, map[string]string{placement.SchedulingModeAnnotation: string(placement.SchedulingModeDisabled)})},
+		want:     map[string]int64{"web": 3},
+	}, {
+		name:     "updated as the root changes",
+		strategy: replicas,
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), readyCluster("west")},
+		objs:     []*unstructured.Unstructured{root(4, nil), leaf("east", 2, "web-uid"), leaf("west", 1, "web-uid")},
+		want:     map[string]int64{"web": 4, "web--east": 2, "web--west": 2},
+	}, {
+		name:     "leaf of a cluster gone",
+		strategy: replicas,
+		clusters: []*v1alpha1.Cluster{readyCluster("east")},
+		objs:     []*unstructured.Unstructured{root(2, nil), leaf("east", 1, "web-uid"), leaf("gone", 1, "web-uid")},
+		want:     map[string]int64{"web": 2, "web--east": 2},
+	}, {
+		name:     "leaf of a cluster not ready kept",
+		strategy: replicas,
+		clusters: []*v1alpha1.Cluster{readyCluster("east"), notReadyCluster("west")},
+		objs:     []*unstructured.Unstructured{root(2, nil), leaf("east", 1, "web-uid"), leaf("west", 1, "web-uid")},
+		want:     map[string]int64{"web": 2, "web--east": 2, "web--west": 1},
+	}, {
+		name:     "no clusters",
+		strategy: replicas,
+		objs:     []*unstructured.Unstructured{root(2, nil), leaf("east", 2, "web-uid")},
+		want:     map[string]int64{"web": 2, "web--east": 2},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t, tc.strategy, tc.clusters...)
+			f.addWidgets(tc.objs...)
+			if err := f.process("web"); err != nil {
+				t.Fatal(err)
+			}
+
+			widgets := f.widgets()
+			got := map[string]int64{}
+			for name, u := range widgets {
+				got[name] = replicasOf(u)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got replicas %v, want %v", got, tc.want)
+			}
+			for name, u := range widgets {
+				if name == "web" {
+					continue
+				}
+				if l := u.GetLabels(); l[base.LeafLabel] != "web" || l[clusterLabel] != name[len("web--"):] || l["app"] != "web" {
+					t.Errorf("%s: got labels %v", name, l)
+				}
+				if refs := u.GetOwnerReferences(); len(refs) != 1 || refs[0].UID != "web-uid" {
+					t.Errorf("%s: got owner references %v", name, refs)
+				}
+			}
+		})
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		root    *unstructured.Unstructured
+		deleted bool
+	}{{
+		name:    "root gone",
+		deleted: true,
+	}, {
+		name: "root replaced",
+		root: func() *unstructured.Unstructured {
+			u := root(2, nil)
+			u.SetUID("other-uid")
+			return u
+		}(),
+		deleted: true,
+	}, {
+		name: "root there",
+		root: root(2, nil),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t, ReplicaDivide{Path: []string{"spec", "replicas"}}, readyCluster("east"))
+			if tc.root != nil {
+				f.addWidgets(tc.root)
+			}
+			f.addWidgets(leaf("east", 2, "web-uid"))
+			if err := f.process("web--east"); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, found := f.widgets()["web--east"]; found == tc.deleted {
+				t.Errorf("leaf found: %v, want %v", found, !tc.deleted)
+			}
+		})
+	}
+}
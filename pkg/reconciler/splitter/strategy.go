@@ -0,0 +1,134 @@
+package splitter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// A Strategy decides what a root object's leaf on each Cluster looks like.
+type Strategy interface {
+	// Split returns the leafs of root keyed by the name of the Cluster each
+	// is for, given the Ready Clusters allowed by the root's PlacementPolicy,
+	// sorted by name, and the root's current leafs. Leafs only need their
+	// content set; the Controller names, labels and owns them.
+	//
+	// If the root can't be split across the given Clusters, Split returns an
+	// *UnsatisfiableError, and the current leafs are left as they are.
+	Split(root *unstructured.Unstructured, cls []*v1alpha1.Cluster, current []*unstructured.Unstructured) (map[string]*unstructured.Unstructured, error)
+}
+
+// A StatusAggregator is a Strategy that also knows how to combine the status
+// of a root's leafs into the root's own.
+type StatusAggregator interface {
+	// AggregateStatus sets the status of root from that of its current leafs.
+	AggregateStatus(root *unstructured.Unstructured, leafs []*unstructured.Unstructured) error
+}
+
+// UnsatisfiableError is returned by a Strategy that can't place a root on
+// any of the Clusters it was given. Retrying won't help until the root or
+// the Clusters change.
+type UnsatisfiableError struct {
+	Message string
+}
+
+func (e *UnsatisfiableError) Error() string {
+	return e.Message
+}
+
+// ReplicaDivide splits a root across all Clusters, dividing the integer
+// field at Path between them by weight, e.g. spec.parallelism of a Job.
+type ReplicaDivide struct {
+	Path []string
+}
+
+func (s ReplicaDivide) Split(root *unstructured.Unstructured, cls []*v1alpha1.Cluster, _ []*unstructured.Unstructured) (map[string]*unstructured.Unstructured, error) {
+	replicas, found, err := unstructured.NestedInt64(root.Object, s.Path...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		replicas = 1
+	}
+
+	desired := placement.DistributeReplicas(int32(replicas), cls)
+	leafs := make(map[string]*unstructured.Unstructured, len(cls))
+	for _, cl := range cls {
+		leaf := root.DeepCopy()
+		if err := unstructured.SetNestedField(leaf.Object, int64(desired[cl.Name]), s.Path...); err != nil {
+			return nil, err
+		}
+		leafs[cl.Name] = leaf
+	}
+	return leafs, nil
+}
+
+// Duplicate places an unchanged copy of a root on every Cluster, e.g. for
+// CronJobs that must run everywhere.
+type Duplicate struct{}
+
+func (Duplicate) Split(root *unstructured.Unstructured, cls []*v1alpha1.Cluster, _ []*unstructured.Unstructured) (map[string]*unstructured.Unstructured, error) {
+	leafs := make(map[string]*unstructured.Unstructured, len(cls))
+	for _, cl := range cls {
+		leafs[cl.Name] = root.DeepCopy()
+	}
+	return leafs, nil
+}
+
+// Pin places a root on a single Cluster, chosen by placement.Pin. It's used
+// for every root with the pinned scheduling mode, whatever the configured
+// Strategy.
+type Pin struct {
+	// FailBack moves the root off its current Cluster, to the first one,
+	// as placement.FailsBack says.
+	FailBack bool
+}
+
+func (p Pin) Split(root *unstructured.Unstructured, cls []*v1alpha1.Cluster, current []*unstructured.Unstructured) (map[string]*unstructured.Unstructured, error) {
+	var currentCluster string
+	if len(current) > 0 && !p.FailBack {
+		currentCluster = current[0].GetLabels()[clusterLabel]
+	}
+	cl, msg := placement.Pin(cls, root.GetAnnotations()[placement.PinnedClusterAnnotation], currentCluster)
+	if msg != "" {
+		return nil, &UnsatisfiableError{Message: msg}
+	}
+	return map[string]*unstructured.Unstructured{cl.Name: root.DeepCopy()}, nil
+}
+
+// ParseSplit parses a resource to split and its Strategy from s, written as
+// "<resource>.<version>.<group>=<strategy>", where the strategy is one of
+// "replicas" (dividing spec.replicas), "replicas:<field path>", "duplicate"
+// or "pin". For example: "jobs.v1.batch=replicas:spec.parallelism".
+func ParseSplit(s string) (schema.GroupVersionResource, Strategy, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return schema.GroupVersionResource{}, nil, fmt.Errorf("invalid split %q, must be <resource>.<version>.<group>=<strategy>", s)
+	}
+
+	gvr, _ := schema.ParseResourceArg(parts[0])
+	if gvr == nil {
+		return schema.GroupVersionResource{}, nil, fmt.Errorf("invalid split %q, resource must be <resource>.<version>.<group>", s)
+	}
+
+	name, arg := parts[1], ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		name, arg = name[:i], name[i+1:]
+	}
+	switch {
+	case name == "replicas" && arg == "":
+		return *gvr, ReplicaDivide{Path: []string{"spec", "replicas"}}, nil
+	case name == "replicas":
+		return *gvr, ReplicaDivide{Path: strings.Split(arg, ".")}, nil
+	case name == "duplicate" && arg == "":
+		return *gvr, Duplicate{}, nil
+	case name == "pin" && arg == "":
+		return *gvr, Pin{}, nil
+	default:
+		return schema.GroupVersionResource{}, nil, fmt.Errorf("invalid split %q, strategy must be one of replicas, replicas:<field path>, duplicate or pin", s)
+	}
+}
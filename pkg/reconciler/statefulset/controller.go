@@ -0,0 +1,247 @@
+package statefulset
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/transform"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+	appsv1lister "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// NewController returns a new Controller which splits StatefulSet objects
+// into virtual StatefulSets labeled for each Ready Cluster allowed by their
+// PlacementPolicy, each owning a contiguous range of the root's ordinals.
+//
+// The Clusters that are left are filtered and scored by sched, if not nil,
+// and the leafs are transformed for their Cluster by transforms.
+// Failed reconciles are retried according to retry, or
+// base.DefaultRetryPolicy if nil.
+func NewController(cfg *rest.Config, sched *scheduler.Scheduler, transforms transform.Chain, leaderElection *base.LeaderElectionConfig, retry *base.RetryPolicy, shared *base.Informers) *Controller {
+	client := appsv1client.NewForConfigOrDie(cfg)
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	sif, csif := shared.Kube, shared.Cluster
+	clusterClient := clusterclient.NewForConfigOrDie(cfg)
+
+	c := &Controller{
+		client:         client,
+		indexer:        logicalcluster.IndexerFor(sif.Apps().V1().StatefulSets().Informer()),
+		clusterIndexer: logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Clusters().Informer()),
+		policyIndexer:  logicalcluster.IndexerFor(csif.Cluster().V1alpha1().PlacementPolicies().Informer()),
+		quotaIndexer:   logicalcluster.IndexerFor(csif.Cluster().V1alpha1().WorkspaceQuotas().Informer()),
+		clusterClient:  clusterClient.ClusterV1alpha1(),
+		kubeClient:     kubeClient,
+		scheduler:      sched,
+		transforms:     transforms,
+	}
+	c.orphans = base.NewOrphans(appsv1.SchemeGroupVersion.WithResource("statefulsets"), c.indexer, leafClient{client})
+	c.Controller = base.New("statefulset", kubeClient, leaderElection, retry, c.process)
+	c.SetIndexer(c.indexer)
+	c.SetBatchLister(c.listRoots)
+	c.AddCacheSyncs(
+		sif.Apps().V1().StatefulSets().Informer().HasSynced,
+		csif.Cluster().V1alpha1().Clusters().Informer().HasSynced,
+		csif.Cluster().V1alpha1().PlacementPolicies().Informer().HasSynced,
+		csif.Cluster().V1alpha1().WorkspaceQuotas().Informer().HasSynced,
+	)
+	c.AddPeriodic(c.orphans.Collect, gcInterval)
+	stopCh := c.StopCh()
+
+	sif.Apps().V1().StatefulSets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(oldObj, obj interface{}) {
+			// The status of roots is that the controller aggregates from
+			// their leafs, whose updates are what it follows.
+			if obj.(*appsv1.StatefulSet).Labels[base.LeafLabel] == "" && base.StatusOnly(oldObj, obj) {
+				return
+			}
+			if base.Resynced(oldObj, obj) {
+				c.EnqueueLow(obj)
+				return
+			}
+			c.Enqueue(obj)
+		},
+	})
+	if own {
+		sif.Start(stopCh)
+	}
+
+	// Clusters joining, leaving or changing readiness, eviction, failback, weight, taints, cordon or, when
+	// transforming leafs, labels and annotations change every root StatefulSet's ordinal ranges
+	// in their workspace, so rebalance all of them.
+	csif.Cluster().V1alpha1().Clusters().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCluster, newCluster := oldObj.(*v1alpha1.Cluster), newObj.(*v1alpha1.Cluster)
+			if oldCluster.Status.Conditions.IsReady() != newCluster.Status.Conditions.IsReady() ||
+				oldCluster.Status.Conditions.IsEvicted() != newCluster.Status.Conditions.IsEvicted() ||
+				oldCluster.Annotations[placement.FailbackAnnotation] != newCluster.Annotations[placement.FailbackAnnotation] ||
+				oldCluster.EffectiveWeight() != newCluster.EffectiveWeight() ||
+				oldCluster.Spec.Unschedulable != newCluster.Spec.Unschedulable ||
+				!equality.Semantic.DeepEqual(oldCluster.Spec.Taints, newCluster.Spec.Taints) ||
+				len(transforms) > 0 && (!equality.Semantic.DeepEqual(oldCluster.Labels, newCluster.Labels) ||
+					!equality.Semantic.DeepEqual(oldCluster.Annotations, newCluster.Annotations)) {
+				c.enqueueRoots(newObj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	csif.Cluster().V1alpha1().PlacementPolicies().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	// Room freed in a WorkspaceQuota may let the roots pending on it be placed.
+	csif.Cluster().V1alpha1().WorkspaceQuotas().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+	})
+	if len(transforms) > 0 {
+		// The WorkloadOverrides of a namespace may apply to any root in it.
+		csif.Cluster().V1alpha1().WorkloadOverrides().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueueRoots(obj) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueueRoots(obj) },
+			DeleteFunc: func(obj interface{}) { c.enqueueRoots(obj) },
+		})
+	}
+	if own {
+		csif.Start(stopCh)
+	}
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	client         *appsv1client.AppsV1Client
+	indexer        cache.Indexer
+	clusterIndexer cache.Indexer
+	policyIndexer  cache.Indexer
+	quotaIndexer   cache.Indexer
+	orphans        *base.Orphans
+	clusterClient  clusterv1alpha1.ClusterV1alpha1Interface
+	kubeClient     kubernetes.Interface
+	scheduler      *scheduler.Scheduler
+	transforms     transform.Chain
+}
+
+// The listers of the objects of a workspace. StatefulSets are only placed
+// on the Clusters of their own workspace, following its PlacementPolicies,
+// and within its WorkspaceQuotas.
+
+func (c *Controller) statefulSets(workspace string) appsv1lister.StatefulSetLister {
+	return appsv1lister.NewStatefulSetLister(logicalcluster.Scoped(c.indexer, workspace))
+}
+
+func (c *Controller) clusters(workspace string) clusterlisters.ClusterLister {
+	return clusterlisters.NewClusterLister(logicalcluster.Scoped(c.clusterIndexer, workspace))
+}
+
+func (c *Controller) policies(workspace string) clusterlisters.PlacementPolicyLister {
+	return clusterlisters.NewPlacementPolicyLister(logicalcluster.Scoped(c.policyIndexer, workspace))
+}
+
+func (c *Controller) quotas(workspace string) clusterlisters.WorkspaceQuotaLister {
+	return clusterlisters.NewWorkspaceQuotaLister(logicalcluster.Scoped(c.quotaIndexer, workspace))
+}
+
+// enqueueRoots enqueues every root StatefulSet, i.e. those not split from
+// another one, of the workspace of the given Cluster, PlacementPolicy or
+// WorkspaceQuota.
+func (c *Controller) enqueueRoots(obj interface{}) {
+	workspace, err := logicalcluster.ClusterName(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.EnqueueWorkspace(workspace)
+}
+
+// listRoots lists the root StatefulSets of the workspace, for
+// EnqueueWorkspace to enqueue.
+func (c *Controller) listRoots(workspace string) ([]interface{}, error) {
+	statefulSets, err := c.statefulSets(workspace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var roots []interface{}
+	for _, ss := range statefulSets {
+		if ss.Labels[base.LeafLabel] == "" {
+			roots = append(roots, ss)
+		}
+	}
+	return roots, nil
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*appsv1.StatefulSet)
+	previous := current.DeepCopy()
+
+	// After a partial failure, the root is still updated for the leafs that
+	// were written.
+	reconcileErr := c.reconcile(ctx, current)
+	if _, partial := base.PartialFailure(reconcileErr); reconcileErr != nil && !partial {
+		return reconcileErr
+	}
+
+	// If the object being reconciled changed as a result, update it.
+	if !equality.Semantic.DeepEqual(previous.Labels, current.Labels) {
+		updated, err := c.client.StatefulSets(current.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		updated.Status = current.Status
+		current = updated
+	}
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (apiruntime.Object, error) {
+				return c.client.StatefulSets(current.Namespace).Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (apiruntime.Object, error) {
+				return c.client.StatefulSets(current.Namespace).Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return reconcileErr
+}
@@ -0,0 +1,26 @@
+package statefulset
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+)
+
+// leafClient is the base.LeafClient orphaned leafs are checked and deleted
+// with.
+type leafClient struct {
+	appsv1client.StatefulSetsGetter
+}
+
+func (c leafClient) Get(ctx context.Context, namespace, name string) (metav1.Object, error) {
+	ss, err := c.StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (c leafClient) Delete(ctx context.Context, namespace, name string) error {
+	return c.StatefulSets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
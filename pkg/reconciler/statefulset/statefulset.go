@@ -0,0 +1,535 @@
+package statefulset
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	"github.com/kcp-dev/kcp/pkg/reconciler/quota"
+	"github.com/kcp-dev/kcp/pkg/scheduler"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	clusterLabel = "cluster"
+	gcInterval   = time.Minute
+
+	// ordinalsAnnotation records the range of the root's ordinals a leaf
+	// stands for, as "<first>-<last>". apps/v1 StatefulSets always number
+	// their pods from 0, so the syncer uses it to keep pod identities unique
+	// across Clusters. Leafs with no replicas don't have it.
+	ordinalsAnnotation = "experimental.kcp.dev/ordinals"
+
+	// progressingCondition is reported on the root when it can't be placed.
+	// StatefulSets have no standard condition types of their own.
+	progressingCondition appsv1.StatefulSetConditionType = "Progressing"
+	// replicaFailureCondition is reported on the root when some of its leafs
+	// couldn't be written, like the condition of Deployments.
+	replicaFailureCondition appsv1.StatefulSetConditionType = "ReplicaFailure"
+)
+
+func (c *Controller) reconcile(ctx context.Context, ss *appsv1.StatefulSet) error {
+	logging.FromContext(ctx).V(2).Info("Reconciling statefulset")
+
+	if ss.Labels[base.LeafLabel] == "" {
+		// This is a root StatefulSet; make sure its leafs match the current set of Clusters.
+		return c.reconcileRoot(ctx, ss)
+	}
+
+	if deleted, err := c.orphans.DeleteIfOrphaned(ctx, ss); err != nil || deleted {
+		return err
+	}
+
+	// A leaf StatefulSet was updated; its root aggregates the status of all leafs.
+	c.Queue().Add(logicalcluster.Key(ss.ClusterName, ss.Namespace, ss.Labels[base.LeafLabel]))
+	return nil
+}
+
+// reconcileRoot places a root StatefulSet onto the Ready Clusters allowed by
+// its PlacementPolicy, if any, once it fits the WorkspaceQuotas of its
+// workspace. With a single Cluster and no existing leafs, or if the root is
+// pinned, the root itself is labeled for that Cluster; otherwise one leaf is
+// kept per Cluster, each owning a contiguous range of ordinals. Roots with
+// scheduling disabled are left alone.
+func (c *Controller) reconcileRoot(ctx context.Context, root *appsv1.StatefulSet) error {
+	mode, err := placement.SchedulingModeFor(root.Annotations)
+	if err != nil {
+		// Retrying won't help until the annotation is fixed.
+		setNotProgressing(root, "InvalidSchedulingMode", err.Error())
+		return nil
+	}
+	if mode == placement.SchedulingModeDisabled {
+		return nil
+	}
+	tolerations, err := placement.TolerationsFor(root.Annotations)
+	if err != nil {
+		setNotProgressing(root, "InvalidTolerations", err.Error())
+		return nil
+	}
+
+	leafs, err := c.leafsFor(root)
+	if err != nil {
+		return err
+	}
+
+	cls, err := placement.ReadyClusters(c.clusters(root.ClusterName))
+	if err != nil {
+		return err
+	}
+	cls = placement.Syncing(cls, appsv1.SchemeGroupVersion.WithResource("statefulsets").GroupResource())
+	if ready := len(cls); ready > 0 {
+		if cls = placement.Untainted(cls, tolerations); len(cls) == 0 {
+			// As with no Clusters, keep the current placement until a taint is lifted.
+			setNotProgressing(root, "ClustersTainted", fmt.Sprintf("All %d Ready clusters have taints the StatefulSet doesn't tolerate", ready))
+			return nil
+		}
+	}
+
+	policy, err := placement.PolicyFor(c.policies(root.ClusterName), root.Namespace, root.Labels)
+	if err != nil {
+		return err
+	}
+	others, err := c.othersPlaced(root, policy)
+	if err != nil {
+		return err
+	}
+	if len(cls) > 0 {
+		var msg string
+		cls, msg, err = placement.Place(policy, cls, others)
+		if err != nil {
+			return err
+		}
+		if msg != "" {
+			setNotProgressing(root, "PlacementUnsatisfiable", msg)
+			return nil
+		}
+		var wait time.Duration
+		cls, wait = placement.FailingBack(policy, cls, placedOn(root, leafs), time.Now())
+		if wait > 0 {
+			c.Queue().AddAfter(logicalcluster.Key(root.ClusterName, root.Namespace, root.Name), wait)
+		}
+	}
+
+	result, err := c.scheduler.Schedule(ctx, scheduler.Workload{Resource: appsv1.SchemeGroupVersion.WithResource("statefulsets"), Object: root}, cls)
+	if err != nil {
+		return err
+	}
+	if result.Message != "" {
+		setNotProgressing(root, "PlacementUnsatisfiable", result.Message)
+		return nil
+	}
+	cls = result.Clusters
+
+	if len(cls) == 0 {
+		// Keep whatever was placed before, it will be rebalanced once a Cluster is Ready again.
+		setNotProgressing(root, "NoRegisteredClusters", "kcp has no clusters registered to receive StatefulSets")
+		return nil
+	}
+
+	if len(leafs) == 0 && root.Labels[clusterLabel] != "" {
+		cl, err := placement.GetCluster(c.clusters(root.ClusterName), root.Labels[clusterLabel])
+		if err != nil {
+			return err
+		}
+		if placement.Tolerated(cl, tolerations) {
+			// Wait for the Cluster to be Ready again, or evicted from, before moving the root off it.
+			return nil
+		}
+	}
+
+	if len(leafs) == 0 && root.Labels[clusterLabel] == "" {
+		// The root is placed for the first time, if its workspace has room for it.
+		want := quota.Usage(appsv1.SchemeGroupVersion.WithResource("statefulsets").GroupResource(), root.Spec.Replicas, &root.Spec.Template.Spec)
+		quotas, err := c.quotas(root.ClusterName).List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		msg, err := quota.Reserve(ctx, c.clusterClient, quotas, want)
+		if err != nil {
+			return err
+		}
+		if msg != "" {
+			setNotProgressing(root, "QuotaExceeded", msg)
+			return nil
+		}
+	}
+
+	if mode == placement.SchedulingModePinned {
+		ranked, current := result.Ranked(placement.Rank(policy, cls)), root.Labels[clusterLabel]
+		if placement.FailsBack(policy, ranked, current) {
+			current = ""
+		}
+		cl, msg := placement.Pin(ranked, root.Annotations[placement.PinnedClusterAnnotation], current)
+		if msg != "" {
+			setNotProgressing(root, "PlacementUnsatisfiable", msg)
+			return nil
+		}
+		// All ordinals go to one Cluster, so it must not be split anymore.
+		if err := c.deleteLeafs(ctx, leafs); err != nil {
+			return err
+		}
+		if root.Labels == nil {
+			root.Labels = map[string]string{}
+		}
+		c.recordScheduled(root, cl.Name)
+		root.Labels[clusterLabel] = cl.Name
+		root.Status.Conditions = nil
+		return nil
+	}
+
+	if len(cls) == 1 && len(leafs) == 0 && len(c.transforms) == 0 {
+		// nothing to split or transform, just label StatefulSet for the only cluster.
+		if root.Labels == nil {
+			root.Labels = map[string]string{}
+		}
+		c.recordScheduled(root, cls[0].Name)
+		root.Labels[clusterLabel] = cls[0].Name
+		root.Status.Conditions = nil
+		return nil
+	}
+
+	// The root is split from now on, so it shouldn't be synced anywhere itself.
+	delete(root.Labels, clusterLabel)
+
+	current, changed, err := c.rebalance(ctx, root, leafs, cls, tolerations)
+	failed, partial := base.PartialFailure(err)
+	if err != nil && !partial {
+		return err
+	}
+	if changed {
+		if err := c.recordUnready(root); err != nil {
+			return err
+		}
+	}
+	aggregateStatus(root, current)
+	if partial {
+		setLeafsFailed(root, failed)
+		return err
+	}
+	return nil
+}
+
+// rebalance makes sure there's exactly one leaf per Cluster, each with its
+// weighted share of the root's replicas and the matching ordinal range. While
+// any leaf is on a Cluster that isn't Ready or is cordoned, nothing is moved, and leafs on
+// Clusters that were evicted from are scaled to zero. It returns the leafs
+// that were kept, so their status can be aggregated, and whether any leaf was
+// created, resized or deleted.
+func (c *Controller) rebalance(ctx context.Context, root *appsv1.StatefulSet, leafs []*appsv1.StatefulSet, cls []*v1alpha1.Cluster, tolerations []corev1.Toleration) ([]*appsv1.StatefulSet, bool, error) {
+	leafClusters := map[string]*v1alpha1.Cluster{}
+	for _, leaf := range leafs {
+		clusterName := leaf.Labels[clusterLabel]
+		cl, err := placement.GetCluster(c.clusters(root.ClusterName), clusterName)
+		if err != nil {
+			return nil, false, err
+		}
+		if placement.Tolerated(cl, tolerations) {
+			// The Cluster may still be running pods with the leaf's ordinals,
+			// so moving them could run two pods with the same identity.
+			return leafs, false, nil
+		}
+		leafClusters[clusterName] = cl
+	}
+
+	replicas := int32(1)
+	if root.Spec.Replicas != nil {
+		replicas = *root.Spec.Replicas
+	}
+	desired := placement.DistributeReplicas(replicas, cls)
+	ranges := ordinalRanges(desired, cls)
+	logger := logging.FromContext(ctx)
+
+	changed := false
+	existing := map[string]*appsv1.StatefulSet{}
+	var kept []*appsv1.StatefulSet
+	for _, leaf := range leafs {
+		clusterName := leaf.Labels[clusterLabel]
+		if _, ok := desired[clusterName]; !ok && existing[clusterName] == nil && placement.Evicted(leafClusters[clusterName], tolerations) {
+			existing[clusterName] = leaf
+			kept = append(kept, leaf)
+			evicted, err := c.evict(ctx, root, leaf)
+			if err != nil {
+				return nil, false, err
+			}
+			changed = changed || evicted
+			continue
+		}
+		if _, ok := desired[clusterName]; !ok || existing[clusterName] != nil {
+			// This leaf's Cluster is gone or not Ready, or it's a duplicate.
+			if err := c.kubeClient.AppsV1().StatefulSets(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil {
+				metrics.SyncErrors.WithLabelValues("statefulset", clusterName).Inc()
+				return nil, false, err
+			}
+			logger.Info("Deleted child statefulset", "child", leaf.Name, logging.ClusterKey, clusterName)
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Unscheduled", "Removed replicas from cluster %s", clusterName)
+			changed = true
+			continue
+		}
+		existing[clusterName] = leaf
+	}
+
+	// The leafs are written to several Clusters at once, and those that
+	// fail don't hold up the others.
+	var lock sync.Mutex
+	byName := map[string]*v1alpha1.Cluster{}
+	names := make([]string, 0, len(cls))
+	for _, cl := range cls {
+		byName[cl.Name] = cl
+		names = append(names, cl.Name)
+	}
+	fanOutErr := c.FanOut(names, func(name string) error {
+		cl := byName[name]
+		want := desired[cl.Name]
+		vs := newLeaf(root, cl.Name, want, ranges[cl.Name])
+		if err := c.transforms.Apply(ctx, cl, vs); err != nil {
+			return err
+		}
+		leaf, ok := existing[cl.Name]
+		if !ok {
+			tracing.Inject(ctx, vs)
+			if _, err := c.kubeClient.AppsV1().StatefulSets(root.Namespace).Create(ctx, vs, metav1.CreateOptions{}); err != nil {
+				metrics.SyncErrors.WithLabelValues("statefulset", cl.Name).Inc()
+				return err
+			}
+			logger.Info("Created child statefulset", "child", vs.Name, logging.ClusterKey, cl.Name, "ordinals", ranges[cl.Name])
+			c.Recorder().Eventf(root, corev1.EventTypeNormal, "Scheduled", "Scheduled %d replicas to cluster %s", want, cl.Name)
+			lock.Lock()
+			defer lock.Unlock()
+			changed = true
+			return nil
+		}
+
+		lock.Lock()
+		kept = append(kept, leaf)
+		lock.Unlock()
+
+		// Keep the leaf in line with its root, as transformed for its Cluster.
+		resized := leaf.Spec.Replicas == nil || *leaf.Spec.Replicas != want || leaf.Annotations[ordinalsAnnotation] != ranges[cl.Name]
+		updated := leaf.DeepCopy()
+		updated.Spec = vs.Spec
+		placement.MergeMetadata(&updated.ObjectMeta, vs.ObjectMeta)
+		setOrdinals(updated, ranges[cl.Name])
+		if equality.Semantic.DeepEqual(leaf, updated) {
+			return nil
+		}
+		tracing.Inject(ctx, updated)
+		if _, err := c.kubeClient.AppsV1().StatefulSets(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			metrics.SyncErrors.WithLabelValues("statefulset", cl.Name).Inc()
+			return err
+		}
+		if !resized {
+			logger.Info("Updated child statefulset", "child", updated.Name, logging.ClusterKey, cl.Name)
+			return nil
+		}
+		c.Recorder().Eventf(root, corev1.EventTypeNormal, "Scheduled", "Scheduled %d replicas to cluster %s", want, cl.Name)
+		logger.Info("Resized child statefulset", "child", updated.Name, logging.ClusterKey, cl.Name, "ordinals", ranges[cl.Name])
+		lock.Lock()
+		defer lock.Unlock()
+		changed = true
+		return nil
+	})
+
+	return kept, changed, fanOutErr
+}
+
+// placedOn returns the Clusters the root has replicas on, itself or through
+// its leafs.
+func placedOn(root *appsv1.StatefulSet, leafs []*appsv1.StatefulSet) sets.String {
+	placed := sets.NewString()
+	if root.Labels[clusterLabel] != "" {
+		placed.Insert(root.Labels[clusterLabel])
+	}
+	for _, leaf := range leafs {
+		if leaf.Spec.Replicas == nil || *leaf.Spec.Replicas > 0 {
+			placed.Insert(leaf.Labels[clusterLabel])
+		}
+	}
+	return placed
+}
+
+// ordinalRanges assigns each Cluster a contiguous range of ordinals matching
+// its replica count, in Cluster name order: with 3 replicas each, cluster A
+// gets "0-2" and cluster B gets "3-5". Clusters with no replicas get "".
+func ordinalRanges(replicas map[string]int32, cls []*v1alpha1.Cluster) map[string]string {
+	out := make(map[string]string, len(cls))
+	next := int32(0)
+	for _, cl := range cls {
+		n := replicas[cl.Name]
+		if n == 0 {
+			out[cl.Name] = ""
+			continue
+		}
+		out[cl.Name] = fmt.Sprintf("%d-%d", next, next+n-1)
+		next += n
+	}
+	return out
+}
+
+func setOrdinals(ss *appsv1.StatefulSet, ordinals string) {
+	if ordinals == "" {
+		delete(ss.Annotations, ordinalsAnnotation)
+		return
+	}
+	if ss.Annotations == nil {
+		ss.Annotations = map[string]string{}
+	}
+	ss.Annotations[ordinalsAnnotation] = ordinals
+}
+
+// evict scales a leaf on an evicted Cluster to zero, keeping it so that it
+// can be scaled back up once the Cluster is Ready again, and reports whether
+// it had to. Its ordinals are handed to the Ready Clusters.
+func (c *Controller) evict(ctx context.Context, root, leaf *appsv1.StatefulSet) (bool, error) {
+	if leaf.Spec.Replicas != nil && *leaf.Spec.Replicas == 0 {
+		return false, nil
+	}
+	clusterName := leaf.Labels[clusterLabel]
+	updated := leaf.DeepCopy()
+	zero := int32(0)
+	updated.Spec.Replicas = &zero
+	setOrdinals(updated, "")
+	if _, err := c.kubeClient.AppsV1().StatefulSets(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		metrics.SyncErrors.WithLabelValues("statefulset", clusterName).Inc()
+		return false, err
+	}
+	logging.FromContext(ctx).Info("Evicted child statefulset", "child", leaf.Name, logging.ClusterKey, clusterName)
+	c.Recorder().Eventf(root, corev1.EventTypeWarning, "Evicted", "Moved replicas off cluster %s, which has not been Ready for too long", clusterName)
+	return true, nil
+}
+
+func (c *Controller) deleteLeafs(ctx context.Context, leafs []*appsv1.StatefulSet) error {
+	for _, leaf := range leafs {
+		if err := c.kubeClient.AppsV1().StatefulSets(leaf.Namespace).Delete(ctx, leaf.Name, metav1.DeleteOptions{}); err != nil {
+			metrics.SyncErrors.WithLabelValues("statefulset", leaf.Labels[clusterLabel]).Inc()
+			return err
+		}
+		logging.FromContext(ctx).Info("Deleted child statefulset", "child", leaf.Name, logging.ClusterKey, leaf.Labels[clusterLabel])
+	}
+	return nil
+}
+
+// recordScheduled records an Event on a root that's about to be labeled for
+// a single Cluster, unless it already was.
+func (c *Controller) recordScheduled(root *appsv1.StatefulSet, clusterName string) {
+	if root.Labels[clusterLabel] != clusterName {
+		c.Recorder().Eventf(root, corev1.EventTypeNormal, "Scheduled", "Scheduled all replicas to cluster %s", clusterName)
+	}
+}
+
+// recordUnready records an Event on a root whose placement just changed for
+// each Cluster it was kept off because the Cluster isn't Ready.
+func (c *Controller) recordUnready(root *appsv1.StatefulSet) error {
+	unready, err := placement.UnreadyClusters(c.clusters(root.ClusterName))
+	if err != nil {
+		return err
+	}
+	for _, cl := range unready {
+		c.Recorder().Eventf(root, corev1.EventTypeWarning, "ClusterNotReady", "Skipped cluster %s, which is not Ready", cl.Name)
+	}
+	return nil
+}
+
+// setNotProgressing reports on the root why it couldn't be placed.
+func setNotProgressing(root *appsv1.StatefulSet, reason, message string) {
+	root.Status.Conditions = []appsv1.StatefulSetCondition{{
+		Type:    progressingCondition,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}}
+}
+
+// setLeafsFailed reports the Clusters whose leafs couldn't be created or
+// updated in the ReplicaFailure condition of the root, which the leafs of
+// the others were written for.
+func setLeafsFailed(root *appsv1.StatefulSet, failed base.ClusterErrors) {
+	cond := appsv1.StatefulSetCondition{
+		Type:               replicaFailureCondition,
+		Status:             corev1.ConditionTrue,
+		Reason:             "LeafsFailed",
+		Message:            fmt.Sprintf("Failed to place the replicas of clusters %s: %v", strings.Join(failed.Clusters(), ", "), failed),
+		LastTransitionTime: metav1.Now(),
+	}
+	for i := range root.Status.Conditions {
+		if prev := root.Status.Conditions[i]; prev.Type == cond.Type {
+			if prev.Status == cond.Status {
+				cond.LastTransitionTime = prev.LastTransitionTime
+			}
+			root.Status.Conditions[i] = cond
+			return
+		}
+	}
+	root.Status.Conditions = append(root.Status.Conditions, cond)
+}
+
+// newLeaf returns a virtual StatefulSet for the given root, labeled/named for
+// the given Cluster.
+func newLeaf(root *appsv1.StatefulSet, clusterName string, replicas int32, ordinals string) *appsv1.StatefulSet {
+	vs := root.DeepCopy()
+
+	vs.Name = fmt.Sprintf("%s--%s", root.Name, clusterName)
+	vs.ResourceVersion = ""
+	vs.UID = ""
+
+	if vs.Labels == nil {
+		vs.Labels = map[string]string{}
+	}
+	vs.Labels[clusterLabel] = clusterName
+	vs.Labels[base.LeafLabel] = root.Name
+	setOrdinals(vs, ordinals)
+
+	vs.Spec.Replicas = &replicas
+	vs.Status = appsv1.StatefulSetStatus{}
+
+	// Set OwnerReference so deleting the StatefulSet deletes all virtual StatefulSets.
+	vs.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "apps/v1",
+		Kind:       "StatefulSet",
+		Name:       root.Name,
+		UID:        root.UID,
+	}}
+	return vs
+}
+
+// leafsFor returns the virtual StatefulSets split from the given root.
+func (c *Controller) leafsFor(root *appsv1.StatefulSet) ([]*appsv1.StatefulSet, error) {
+	sel, err := labels.Parse(fmt.Sprintf("%s=%s", base.LeafLabel, root.Name))
+	if err != nil {
+		return nil, err
+	}
+	return c.statefulSets(root.ClusterName).StatefulSets(root.Namespace).List(sel)
+}
+
+// othersPlaced returns the other StatefulSets in the root's namespace that
+// are on a Cluster, as roots or leafs, if its PlacementPolicy avoids them.
+func (c *Controller) othersPlaced(root *appsv1.StatefulSet, policy *v1alpha1.PlacementPolicy) ([]placement.Workload, error) {
+	if !placement.HasAntiAffinity(policy) {
+		return nil, nil
+	}
+	statefulSets, err := c.statefulSets(root.ClusterName).StatefulSets(root.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var others []placement.Workload
+	for _, ss := range statefulSets {
+		if ss.Name == root.Name || ss.Labels[base.LeafLabel] == root.Name || ss.Labels[clusterLabel] == "" {
+			continue
+		}
+		others = append(others, placement.Workload{Labels: ss.Labels, Cluster: ss.Labels[clusterLabel]})
+	}
+	return others, nil
+}
@@ -0,0 +1,25 @@
+package statefulset
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// aggregateStatus sets the root's .status to the sum of its leafs' replica
+// counts. Revisions are hashes computed by each physical cluster and can't be
+// combined, so the root keeps its own. Its observedGeneration is that of the
+// root, whose leafs were just placed from its current spec.
+func aggregateStatus(root *appsv1.StatefulSet, leafs []*appsv1.StatefulSet) {
+	status := appsv1.StatefulSetStatus{
+		ObservedGeneration: root.Generation,
+		CurrentRevision:    root.Status.CurrentRevision,
+		UpdateRevision:     root.Status.UpdateRevision,
+		CollisionCount:     root.Status.CollisionCount,
+	}
+	for _, leaf := range leafs {
+		status.Replicas += leaf.Status.Replicas
+		status.ReadyReplicas += leaf.Status.ReadyReplicas
+		status.CurrentReplicas += leaf.Status.CurrentReplicas
+		status.UpdatedReplicas += leaf.Status.UpdatedReplicas
+	}
+	root.Status = status
+}
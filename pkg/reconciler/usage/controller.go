@@ -0,0 +1,220 @@
+// Package usage reports what each workspace stores in kcp, so that platform
+// admins can spot the noisy tenants and size their WorkspaceQuotas: how many
+// objects of each resource it has and an estimate of how many bytes they
+// take, as the kcp_workspace_objects and kcp_workspace_storage_estimated_bytes
+// metrics and, optionally, in the status of its Workspace. The watches of a
+// workspace the kcp server serves are counted by the server itself.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 10 * time.Hour
+
+// Resources are the resources whose objects are counted: those of the
+// workloads kcp places and of what they depend on, which the controller
+// manager watches across the workspaces.
+var Resources = []schema.GroupVersionResource{
+	corev1.SchemeGroupVersion.WithResource("namespaces"),
+	corev1.SchemeGroupVersion.WithResource("secrets"),
+	corev1.SchemeGroupVersion.WithResource("serviceaccounts"),
+	corev1.SchemeGroupVersion.WithResource("services"),
+	corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"),
+	appsv1.SchemeGroupVersion.WithResource("deployments"),
+	appsv1.SchemeGroupVersion.WithResource("statefulsets"),
+	batchv1.SchemeGroupVersion.WithResource("jobs"),
+	discoveryv1beta1.SchemeGroupVersion.WithResource("endpointslices"),
+	networkingv1beta1.SchemeGroupVersion.WithResource("ingresses"),
+	networkingv1.SchemeGroupVersion.WithResource("networkpolicies"),
+	v1alpha1.SchemeGroupVersion.WithResource("clusters"),
+	v1alpha1.SchemeGroupVersion.WithResource("placementpolicies"),
+	v1alpha1.SchemeGroupVersion.WithResource("workloadoverrides"),
+}
+
+// Options configure the usage controller.
+type Options struct {
+	// Interval is how often the objects of every workspace are counted.
+	Interval time.Duration
+	// UpdateStatus also writes the usage of each workspace to the
+	// status.usage of its Workspace.
+	UpdateStatus bool
+}
+
+// DefaultOptions are the Options the usage controller runs with unless set
+// otherwise.
+func DefaultOptions() Options {
+	return Options{Interval: 5 * time.Minute}
+}
+
+// NewController returns a new Controller which counts the objects of
+// Resources of the workspace of each Workspace, which must be in the logical
+// cluster cfg reaches, every opts.Interval.
+func NewController(cfg *rest.Config, opts Options, shared *base.Informers) *Controller {
+	own := shared == nil
+	if own {
+		shared = base.NewInformers(cfg, resyncPeriod)
+	}
+	c := newController(kubernetes.NewForConfigOrDie(cfg), clusterclient.NewForConfigOrDie(cfg), opts, shared)
+	if own {
+		shared.Kube.Start(c.StopCh())
+		shared.Cluster.Start(c.StopCh())
+	}
+	return c
+}
+
+func newController(kubeClient kubernetes.Interface, client clusterclient.Interface, opts Options, shared *base.Informers) *Controller {
+	workspaces := shared.Cluster.Cluster().V1alpha1().Workspaces().Informer()
+	c := &Controller{
+		opts:    opts,
+		client:  client.ClusterV1alpha1(),
+		indexer: logicalcluster.IndexerFor(workspaces),
+		counted: map[schema.GroupResource]cache.Indexer{},
+	}
+	c.Controller = base.New("usage", kubeClient, nil, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(workspaces.HasSynced)
+	for _, gvr := range Resources {
+		informer, err := informerFor(shared, gvr)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		c.counted[gvr.GroupResource()] = logicalcluster.IndexerFor(informer)
+		c.AddCacheSyncs(informer.HasSynced)
+	}
+
+	workspaces.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		// Deleted Workspaces are no longer reported.
+		DeleteFunc: func(obj interface{}) { c.Enqueue(obj) },
+	})
+	// The objects are only counted every Interval, not as they change,
+	// which the busiest workspaces would have them counted all the time for.
+	c.AddPeriodic(c.enqueueWorkspaces, opts.Interval)
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	opts    Options
+	client  clusterv1alpha1.ClusterV1alpha1Interface
+	indexer cache.Indexer
+	// counted are the indexers of the objects of Resources.
+	counted map[schema.GroupResource]cache.Indexer
+}
+
+// informerFor returns the informer of shared of the given resource, of
+// Kubernetes or kcp.
+func informerFor(shared *base.Informers, gvr schema.GroupVersionResource) (cache.SharedIndexInformer, error) {
+	if gvr.Group == v1alpha1.SchemeGroupVersion.Group {
+		informer, err := shared.Cluster.ForResource(gvr)
+		if err != nil {
+			return nil, err
+		}
+		return informer.Informer(), nil
+	}
+	informer, err := shared.Kube.ForResource(gvr)
+	if err != nil {
+		return nil, err
+	}
+	return informer.Informer(), nil
+}
+
+// enqueueWorkspaces enqueues every Workspace, for its objects to be counted,
+// with low priority as it's background work.
+func (c *Controller) enqueueWorkspaces(context.Context) {
+	for _, obj := range c.indexer.List() {
+		c.EnqueueLow(obj)
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, _, name, err := logicalcluster.SplitKey(key)
+		if err != nil {
+			return err
+		}
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		for gr := range c.counted {
+			metrics.WorkspaceObjects.Delete(map[string]string{"workspace": name, "resource": gr.String()})
+		}
+		metrics.WorkspaceStorageEstimatedBytes.Delete(map[string]string{"workspace": name})
+		return nil
+	}
+	ws := obj.(*v1alpha1.Workspace)
+
+	usage, err := c.count(ws.Name)
+	if err != nil {
+		return err
+	}
+	for gr := range c.counted {
+		metrics.WorkspaceObjects.WithLabelValues(ws.Name, gr.String()).Set(float64(usage.Objects[gr.String()]))
+	}
+	metrics.WorkspaceStorageEstimatedBytes.WithLabelValues(ws.Name).Set(float64(usage.EstimatedStorageBytes))
+
+	if !c.opts.UpdateStatus || equality.Semantic.DeepEqual(ws.Status.Usage, usage) {
+		return nil
+	}
+	current := ws.DeepCopy()
+	current.Status.Usage = usage
+	_, err = base.PatchStatus(ctx, c.FieldManager(), ws, current, base.StatusClient{
+		Get: func(ctx context.Context) (runtime.Object, error) {
+			return c.client.Workspaces().Get(ctx, current.Name, metav1.GetOptions{})
+		},
+		Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+			return c.client.Workspaces().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+		},
+	})
+	return err
+}
+
+// count returns how many objects of each of Resources the workspace has,
+// and how many bytes their JSON encoding takes, as an estimate of what they
+// take in etcd.
+func (c *Controller) count(workspace string) (*v1alpha1.WorkspaceUsage, error) {
+	usage := &v1alpha1.WorkspaceUsage{Objects: map[string]int64{}}
+	for gr, indexer := range c.counted {
+		objs := logicalcluster.Scoped(indexer, workspace).List()
+		usage.Objects[gr.String()] = int64(len(objs))
+		for _, obj := range objs {
+			data, err := json.Marshal(obj)
+			if err != nil {
+				return nil, err
+			}
+			usage.EstimatedStorageBytes += int64(len(data))
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,49 @@
+package usage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterfake "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/fake"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProcess(t *testing.T) {
+	ws := &v1alpha1.Workspace{ObjectMeta: metav1.ObjectMeta{Name: "tenant", ClusterName: "admin"}}
+	clusters := clusterfake.NewSimpleClientset(ws)
+	kube := kubefake.NewSimpleClientset()
+	informers := base.NewInformersFor(kube, clusters, 0)
+	c := newController(kube, clusters, Options{UpdateStatus: true}, informers)
+
+	if err := informers.Cluster.Cluster().V1alpha1().Workspaces().Informer().GetIndexer().Add(ws); err != nil {
+		t.Fatal(err)
+	}
+	secrets := informers.Kube.Core().V1().Secrets().Informer().GetIndexer()
+	for _, s := range []*corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default", ClusterName: "tenant"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default", ClusterName: "tenant"}},
+		// Those of other workspaces aren't counted.
+		{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "default", ClusterName: "other"}},
+	} {
+		if err := secrets.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.process(context.Background(), logicalcluster.Key("admin", "", "tenant")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := clusters.ClusterV1alpha1().Workspaces().Get(context.Background(), "tenant", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := got.Status.Usage
+	if usage == nil || usage.Objects["secrets"] != 2 || usage.Objects["deployments.apps"] != 0 || usage.EstimatedStorageBytes <= 0 {
+		t.Errorf("status.usage = %+v, want 2 secrets and some bytes", usage)
+	}
+}
@@ -0,0 +1,222 @@
+// Package workspace sets up the logical clusters of the Workspaces created
+// in the admin logical cluster, registering the cluster.example.dev CRDs in
+// each, so that their tenants can register Clusters and place workloads on
+// them without seeing those of other workspaces, and pre-populating them
+// from their WorkspaceType.
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/conditions"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"github.com/kcp-dev/kcp/pkg/reconciler/base"
+	"github.com/kcp-dev/kcp/pkg/reconciler/cluster"
+	"github.com/kcp-dev/kcp/pkg/sharding"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resyncPeriod = 10 * time.Hour
+
+	// AdminWorkspace is the name of the admin logical cluster, which
+	// Workspaces are created in and which no Workspace may be named like.
+	AdminWorkspace = "admin"
+)
+
+// NewController returns a new Controller which sets up the logical clusters
+// of the Workspaces in the admin logical cluster, reached with cfg. The
+// URLs of the Workspaces are those of their logical clusters under
+// serverURL, which defaults to the host of cfg.
+//
+// If shards is not nil, each Workspace is assigned to one of them, whose
+// logical cluster is set up on that shard rather than with cfg; serverURL
+// should then be that of the sharding.Proxy in front of them.
+func NewController(cfg *rest.Config, serverURL string, shards *sharding.Shards) *Controller {
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	client := clusterclient.NewForConfigOrDie(cfg)
+	csif := externalversions.NewSharedInformerFactoryWithOptions(client, resyncPeriod, externalversions.WithTweakListOptions(base.PagedLists(base.DefaultListPageSize)))
+	if serverURL == "" {
+		serverURL = cfg.Host
+	}
+
+	c := &Controller{
+		cfg:            cfg,
+		serverURL:      strings.TrimSuffix(serverURL, "/"),
+		shards:         shards,
+		client:         client.ClusterV1alpha1(),
+		crdClient:      apiextensionsv1client.NewForConfigOrDie(cfg),
+		indexer:        logicalcluster.IndexerFor(csif.Cluster().V1alpha1().Workspaces().Informer()),
+		workspaceTypes: csif.Cluster().V1alpha1().WorkspaceTypes().Lister(),
+	}
+	c.Controller = base.New("workspace", kubeClient, nil, nil, c.process)
+	c.SetIndexer(c.indexer)
+	c.AddCacheSyncs(
+		csif.Cluster().V1alpha1().Workspaces().Informer().HasSynced,
+		csif.Cluster().V1alpha1().WorkspaceTypes().Informer().HasSynced,
+	)
+	stopCh := c.StopCh()
+
+	csif.Cluster().V1alpha1().Workspaces().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.Enqueue(obj) },
+	})
+	// The Workspaces waiting for their type to be created, or fixed, are
+	// set up once it is.
+	csif.Cluster().V1alpha1().WorkspaceTypes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueWorkspacesOfType(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueWorkspacesOfType(obj) },
+	})
+	csif.Start(stopCh)
+
+	return c
+}
+
+type Controller struct {
+	*base.Controller
+
+	cfg       *rest.Config
+	serverURL string
+	shards    *sharding.Shards
+	client    clusterv1alpha1.ClusterV1alpha1Interface
+	crdClient apiextensionsv1client.CustomResourceDefinitionsGetter
+	indexer   cache.Indexer
+
+	workspaceTypes clusterlisters.WorkspaceTypeLister
+}
+
+// enqueueWorkspacesOfType enqueues the Workspaces of the WorkspaceType that
+// are still initializing.
+func (c *Controller) enqueueWorkspacesOfType(obj interface{}) {
+	t, ok := obj.(*v1alpha1.WorkspaceType)
+	if !ok {
+		return
+	}
+	for _, o := range c.indexer.List() {
+		if ws := o.(*v1alpha1.Workspace); ws.Spec.Type == t.Name && ws.Status.Phase != v1alpha1.WorkspacePhaseActive {
+			c.Enqueue(ws)
+		}
+	}
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// Its logical cluster, and what's in it, is left as is.
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		return nil
+	}
+	current := obj.(*v1alpha1.Workspace).DeepCopy()
+	previous := current.DeepCopy()
+
+	reconcileErr := c.reconcile(ctx, current)
+	if reconcileErr == nil {
+		current.Status.ObservedGeneration = current.Generation
+	}
+
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		_, err := base.PatchStatus(ctx, c.FieldManager(), previous, current, base.StatusClient{
+			Get: func(ctx context.Context) (runtime.Object, error) {
+				return c.client.Workspaces().Get(ctx, current.Name, metav1.GetOptions{})
+			},
+			Patch: func(ctx context.Context, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+				return c.client.Workspaces().Patch(ctx, current.Name, types.MergePatchType, data, opts, "status")
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return reconcileErr
+}
+
+func (c *Controller) reconcile(ctx context.Context, ws *v1alpha1.Workspace) error {
+	logger := logging.FromContext(ctx)
+	if ws.Status.Phase == "" {
+		ws.Status.Phase = v1alpha1.WorkspacePhaseInitializing
+	}
+
+	if errs := validation.IsDNS1123Label(ws.Name); len(errs) > 0 {
+		logger.Info("Invalid workspace name", "reasons", errs)
+		conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "InvalidName", fmt.Sprintf("The workspace name is not a valid logical cluster name: %s", strings.Join(errs, "; ")))
+		return nil // Don't retry.
+	}
+	if ws.Name == AdminWorkspace {
+		conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "ReservedName", fmt.Sprintf("The name %s is reserved for the admin logical cluster", AdminWorkspace))
+		return nil // Don't retry.
+	}
+
+	cfg := c.cfg
+	if c.shards != nil {
+		shard := sharding.ShardOf(ws, c.shards.Names())
+		if cfg = c.shards.Config(shard); cfg == nil {
+			conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "UnknownShard", fmt.Sprintf("The workspace is assigned to the unknown shard %s", shard))
+			return nil // Don't retry.
+		}
+		ws.Status.Shard = shard
+	}
+
+	// The CRDs are registered with the conversion webhook of the kcp
+	// server the Workspace is on, that of its admin logical cluster.
+	webhook, err := conversionWebhook(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	wsCfg := logicalcluster.Config(cfg, ws.Name)
+	if err := cluster.RegisterClusterCRD(wsCfg, webhook); err != nil {
+		conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "ErrorRegisteringCRDs", fmt.Sprintf("Error registering the cluster.example.dev CRDs: %v", err))
+		return err
+	}
+
+	// Workspaces are only set up from their type as they're initialized,
+	// so that what their tenants change or delete isn't restored.
+	if ws.Spec.Type != "" && ws.Status.Phase == v1alpha1.WorkspacePhaseInitializing {
+		t, err := c.workspaceTypes.Get(ws.Spec.Type)
+		if errors.IsNotFound(err) {
+			conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "UnknownType", fmt.Sprintf("The workspace type %s doesn't exist", ws.Spec.Type))
+			return nil // Retried once it's created.
+		} else if err != nil {
+			return err
+		}
+		objects, err := decodeObjects(t.Spec.Objects)
+		if err != nil {
+			conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "InvalidType", fmt.Sprintf("The workspace type %s is invalid: %v", t.Name, err))
+			return nil // Retried once it's fixed.
+		}
+		if err := c.installCRDs(ctx, wsCfg, t.Spec.CustomResourceDefinitions); err != nil {
+			conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "ErrorInstallingCRDs", fmt.Sprintf("Error installing the CRDs of the workspace type %s: %v", t.Name, err))
+			return err
+		}
+		if err := createObjects(ctx, wsCfg, objects); err != nil {
+			conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionFalse, "ErrorCreatingObjects", fmt.Sprintf("Error creating the objects of the workspace type %s: %v", t.Name, err))
+			return err
+		}
+	}
+	ws.Status.URL = c.serverURL + logicalcluster.Path(ws.Name)
+	ws.Status.Phase = v1alpha1.WorkspacePhaseActive
+	conditions.Set(ws, v1alpha1.WorkspaceConditionInitialized, corev1.ConditionTrue, "Initialized", "The workspace's logical cluster is set up")
+	return nil
+}
@@ -0,0 +1,122 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kcp-dev/kcp/config"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// installCRDs copies the named CRDs of the admin logical cluster to the
+// logical cluster reached with cfg, leaving those it already has as they
+// are.
+func (c *Controller) installCRDs(ctx context.Context, cfg *rest.Config, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	crdClient, err := apiextensionsv1client.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		crd, err := c.crdClient.CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("getting CRD %s from the admin logical cluster: %w", name, err)
+		}
+		// Only its schema is copied: its labels, such as that of the
+		// Clusters it was imported from, are those of the admin logical
+		// cluster.
+		copied := &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: crd.Name},
+			Spec:       crd.Spec,
+		}
+		if _, err := crdClient.CustomResourceDefinitions().Create(ctx, copied, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("installing CRD %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// conversionWebhook returns the conversion webhook of the CRDs of the admin
+// logical cluster reached with cfg.
+func conversionWebhook(ctx context.Context, cfg *rest.Config) (*apiextensionsv1.WebhookClientConfig, error) {
+	crdClient, err := apiextensionsv1client.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return config.ConversionWebhook(ctx, crdClient.CustomResourceDefinitions())
+}
+
+// decodeObjects decodes the objects of a WorkspaceType, which must have an
+// apiVersion, a kind and a name.
+func decodeObjects(raws []runtime.RawExtension) ([]*unstructured.Unstructured, error) {
+	objects := make([]*unstructured.Unstructured, 0, len(raws))
+	for i, raw := range raws {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+			return nil, fmt.Errorf("object %d: %w", i, err)
+		}
+		if obj.GetAPIVersion() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("object %d: an apiVersion, kind and name are required", i)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// createObjects creates the objects in the logical cluster reached with cfg,
+// in order, unless they already exist. Namespaced objects without a
+// namespace are created in the default namespace.
+func createObjects(ctx context.Context, cfg *rest.Config, objects []*unstructured.Unstructured) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	// The mapper is built from discovery here, rather than once, as the
+	// CRDs just installed may serve the kinds of the objects.
+	groups, err := restmapper.GetAPIGroupResources(dc)
+	if err != nil {
+		return err
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groups)
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), err)
+		}
+		obj = obj.DeepCopy()
+		var resource dynamic.ResourceInterface = client.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(metav1.NamespaceDefault)
+			}
+			resource = client.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+		} else {
+			obj.SetNamespace("")
+		}
+		if _, err := resource.Create(ctx, obj, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("creating %s %s: %w", gvk.Kind, obj.GetName(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestDecodeObjects(t *testing.T) {
+	objects, err := decodeObjects([]runtime.RawExtension{
+		{Raw: []byte(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"apps"}}`)},
+		{Raw: []byte(`{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"deployer","namespace":"apps"},"rules":[]}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].GetKind() != "Namespace" || objects[1].GetNamespace() != "apps" || objects[1].GroupVersionKind().Group != "rbac.authorization.k8s.io" {
+		t.Errorf("got %v", objects)
+	}
+
+	for name, raw := range map[string]string{
+		"no kind":  `{"apiVersion":"v1","metadata":{"name":"apps"}}`,
+		"no name":  `{"apiVersion":"v1","kind":"Namespace","metadata":{"generateName":"apps-"}}`,
+		"not JSON": `apiVersion: v1`,
+	} {
+		if _, err := decodeObjects([]runtime.RawExtension{{Raw: []byte(raw)}}); err == nil || !strings.HasPrefix(err.Error(), "object 0:") {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package registration lets physical clusters register themselves with kcp,
+// instead of an admin hand-crafting their Cluster with an embedded kubeconfig.
+//
+// An admin mints a time-limited bootstrap token for a Cluster name, and
+// applies the manifests of a syncer carrying it to the physical cluster. The
+// syncer then registers its Cluster, presenting the token in the Cluster's
+// TokenAnnotation, and the cluster controller admits it by setting the
+// Cluster's Accepted condition if the token is valid. Each token can only be
+// used once.
+//
+// Bootstrap tokens are stored like Kubernetes' own, as Secrets of type
+// bootstrap.kubernetes.io/token in the TokenNamespace of the logical
+// cluster, but kcp doesn't authenticate requests with them: they only prove
+// that an admin approved the registration.
+package registration
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterv1alpha1 "github.com/kcp-dev/kcp/pkg/client/clientset/versioned/typed/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// TokenNamespace is the namespace bootstrap tokens are stored in.
+	TokenNamespace = "kube-system"
+
+	// TokenAnnotation holds the bootstrap token a registering syncer
+	// presents on its Cluster. The cluster controller removes it once it's
+	// checked.
+	TokenAnnotation = "experimental.kcp.dev/bootstrap-token"
+
+	// DefaultTokenTTL is how long bootstrap tokens are valid by default.
+	DefaultTokenTTL = time.Hour
+
+	// clusterLabel binds a bootstrap token to the Cluster it was minted for.
+	clusterLabel = "cluster"
+
+	// The keys of bootstrap token Secrets, as used by Kubernetes.
+	tokenIDKey      = "token-id"
+	tokenSecretKey  = "token-secret"
+	expirationKey   = "expiration"
+	descriptionKey  = "description"
+	authenticateKey = "usage-bootstrap-authentication"
+
+	tokenChars   = "abcdefghijklmnopqrstuvwxyz0123456789"
+	pollInterval = 2 * time.Second
+)
+
+// tokenRegexp matches bootstrap tokens, "<id>.<secret>".
+var tokenRegexp = regexp.MustCompile(`^([a-z0-9]{6})\.([a-z0-9]{16})$`)
+
+func secretName(id string) string {
+	return "bootstrap-token-" + id
+}
+
+// CreateToken mints a bootstrap token for the named Cluster, valid for ttl,
+// and returns it.
+func CreateToken(ctx context.Context, client kubernetes.Interface, cluster string, ttl time.Duration) (string, error) {
+	id, err := randomString(6)
+	if err != nil {
+		return "", err
+	}
+	secret, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: TokenNamespace}}
+	if _, err := client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	_, err = client.CoreV1().Secrets(TokenNamespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: TokenNamespace,
+			Name:      secretName(id),
+			Labels:    map[string]string{clusterLabel: cluster},
+		},
+		Type: corev1.SecretTypeBootstrapToken,
+		StringData: map[string]string{
+			tokenIDKey:      id,
+			tokenSecretKey:  secret,
+			expirationKey:   time.Now().Add(ttl).UTC().Format(time.RFC3339),
+			descriptionKey:  fmt.Sprintf("Registration of cluster %s", cluster),
+			authenticateKey: "true",
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return id + "." + secret, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		c, err := rand.Int(rand.Reader, big.NewInt(int64(len(tokenChars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = tokenChars[c.Int64()]
+	}
+	return string(b), nil
+}
+
+// Validate checks the bootstrap token presented for the named Cluster, and
+// returns why it's not valid, or "" if it is.
+func Validate(ctx context.Context, client kubernetes.Interface, cluster, token string, now time.Time) (string, error) {
+	m := tokenRegexp.FindStringSubmatch(token)
+	if m == nil {
+		return "malformed bootstrap token", nil
+	}
+	id, secret := m[1], m[2]
+
+	s, err := client.CoreV1().Secrets(TokenNamespace).Get(ctx, secretName(id), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Sprintf("bootstrap token %s not found; it may have expired or been used already", id), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if s.Type != corev1.SecretTypeBootstrapToken || subtle.ConstantTimeCompare(s.Data[tokenSecretKey], []byte(secret)) != 1 {
+		return fmt.Sprintf("bootstrap token %s is invalid", id), nil
+	}
+	expiration, err := time.Parse(time.RFC3339, string(s.Data[expirationKey]))
+	if err != nil {
+		return fmt.Sprintf("bootstrap token %s has an invalid expiration: %v", id, err), nil
+	}
+	if now.After(expiration) {
+		return fmt.Sprintf("bootstrap token %s expired at %s", id, expiration.Format(time.RFC3339)), nil
+	}
+	if s.Labels[clusterLabel] != cluster {
+		return fmt.Sprintf("bootstrap token %s was minted for cluster %q", id, s.Labels[clusterLabel]), nil
+	}
+	return "", nil
+}
+
+// Consume deletes the given bootstrap token, once it's been used.
+func Consume(ctx context.Context, client kubernetes.Interface, token string) error {
+	m := tokenRegexp.FindStringSubmatch(token)
+	if m == nil {
+		return nil
+	}
+	err := client.CoreV1().Secrets(TokenNamespace).Delete(ctx, secretName(m[1]), metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Register creates the named Cluster, or claims it if it exists, presenting
+// the given bootstrap token. Clusters that were already accepted are left
+// alone, so that a restarted syncer doesn't need a new token.
+func Register(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name, token string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cl, err := clusters.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = clusters.Create(ctx, &v1alpha1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Annotations: map[string]string{TokenAnnotation: token},
+				},
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if cl.Status.Conditions.IsAccepted() {
+			return nil
+		}
+		if cl.Annotations == nil {
+			cl.Annotations = map[string]string{}
+		}
+		cl.Annotations[TokenAnnotation] = token
+		_, err = clusters.Update(ctx, cl, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// WaitForAccepted waits until the cluster controller has checked the token
+// presented by Register, for at most timeout, and returns why the named
+// Cluster was rejected if it was.
+func WaitForAccepted(ctx context.Context, clusters clusterv1alpha1.ClusterInterface, name string, timeout time.Duration) error {
+	var rejected error
+	err := wait.PollImmediate(pollInterval, timeout, func() (bool, error) {
+		cl, err := clusters.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if cl.Status.Conditions.IsAccepted() {
+			return true, nil
+		}
+		if _, pending := cl.Annotations[TokenAnnotation]; pending {
+			return false, nil
+		}
+		if cond := cl.Status.Conditions.Get(v1alpha1.ClusterConditionAccepted); cond != nil {
+			rejected = fmt.Errorf("cluster %s was not accepted: %s", name, cond.Message)
+			return true, nil
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("cluster %s wasn't accepted within %s", name, timeout)
+	}
+	if err != nil {
+		return err
+	}
+	return rejected
+}
@@ -0,0 +1,89 @@
+package registration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	token, err := CreateToken(ctx, client, "east", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tokenRegexp.MatchString(token) {
+		t.Fatalf("CreateToken() = %q, not a bootstrap token", token)
+	}
+	// The fake clientset doesn't convert StringData to Data.
+	s, err := client.CoreV1().Secrets(TokenNamespace).Get(ctx, secretName(token[:6]), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Data = map[string][]byte{}
+	for k, v := range s.StringData {
+		s.Data[k] = []byte(v)
+	}
+	if _, err := client.CoreV1().Secrets(TokenNamespace).Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		desc    string
+		cluster string
+		token   string
+		now     time.Time
+		want    string
+	}{{
+		desc:    "valid",
+		cluster: "east",
+		token:   token,
+		now:     time.Now(),
+	}, {
+		desc:    "malformed",
+		cluster: "east",
+		token:   "not-a-token",
+		now:     time.Now(),
+		want:    "malformed",
+	}, {
+		desc:    "wrong secret",
+		cluster: "east",
+		token:   token[:7] + strings.Repeat("x", 16),
+		now:     time.Now(),
+		want:    "invalid",
+	}, {
+		desc:    "expired",
+		cluster: "east",
+		token:   token,
+		now:     time.Now().Add(2 * time.Hour),
+		want:    "expired",
+	}, {
+		desc:    "other cluster",
+		cluster: "west",
+		token:   token,
+		now:     time.Now(),
+		want:    `minted for cluster "east"`,
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := Validate(ctx, client, c.cluster, c.token, c.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.want == "" && got != "" || !strings.Contains(got, c.want) {
+				t.Errorf("Validate() = %q, want %q", got, c.want)
+			}
+		})
+	}
+
+	if err := Consume(ctx, client, token); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Validate(ctx, client, "east", token, time.Now()); err != nil || !strings.Contains(got, "not found") {
+		t.Errorf("Validate() after Consume() = %q, %v, want not found", got, err)
+	}
+}
@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logging"
+)
+
+const (
+	// CostAnnotation holds the cost of running on a Cluster, e.g. its hourly
+	// price per node, as a decimal number in any unit shared by all the
+	// Clusters, for the Cost plugin to favor the cheapest.
+	CostAnnotation = "experimental.kcp.dev/cost"
+
+	// DefaultCostRefreshInterval is how often the Cost plugin fetches the
+	// prices of Clusters from its pricing URL by default.
+	DefaultCostRefreshInterval = 5 * time.Minute
+)
+
+// costPricing is where the Cost plugins created from then on fetch prices
+// from, if anywhere, as SetCostPricing has it.
+var costPricing struct {
+	url      string
+	interval time.Duration
+}
+
+// SetCostPricing makes the Cost plugins that New creates from then on fetch
+// the prices of Clusters from the given URL, every interval, rather than
+// only read their CostAnnotation. The URL must answer GET requests with a
+// JSON object of the prices by Cluster name, e.g. {"us-east": 0.12}.
+func SetCostPricing(url string, interval time.Duration) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	costPricing.url, costPricing.interval = url, interval
+}
+
+// Cost favors the cheapest Clusters: those with the lowest price from the
+// pricing URL, if set and it has one, or CostAnnotation otherwise. Clusters
+// with neither score half the MaxScore. The PlacementPolicy has already
+// kept the Clusters its spreadConstraints allow, so it only chooses among
+// those.
+type Cost struct {
+	pricing *pricing
+}
+
+// newCost is registered in place of the zero Cost, for it to fetch prices
+// as SetCostPricing says. It's called with the registryLock held.
+func newCost() (Plugin, error) {
+	if costPricing.url == "" {
+		return Cost{}, nil
+	}
+	interval := costPricing.interval
+	if interval <= 0 {
+		interval = DefaultCostRefreshInterval
+	}
+	return Cost{pricing: &pricing{
+		url:      costPricing.url,
+		interval: interval,
+		client:   &http.Client{Timeout: DefaultExtenderTimeout},
+	}}, nil
+}
+
+func (Cost) Name() string { return "Cost" }
+
+// Score returns the cost of the Cluster, in thousandths, to be normalized.
+func (c Cost) Score(ctx context.Context, _ Workload, cl *v1alpha1.Cluster) (int64, error) {
+	if c.pricing != nil {
+		price, found, err := c.pricing.price(ctx, cl.Name)
+		if err != nil {
+			return 0, err
+		}
+		if found && price >= 0 {
+			return int64(math.Round(price * 1000)), nil
+		}
+	}
+	if v, found := cl.Annotations[CostAnnotation]; found {
+		if cost, err := strconv.ParseFloat(v, 64); err == nil && cost >= 0 {
+			return int64(math.Round(cost * 1000)), nil
+		}
+	}
+	return unknownScore, nil
+}
+
+// NormalizeScores gives the cheapest Clusters the MaxScore and the most
+// expensive none, the others in proportion.
+func (Cost) NormalizeScores(scores map[string]int64) {
+	favorLowest(scores)
+}
+
+// pricing caches the prices fetched from a pricing URL for an interval.
+type pricing struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	lock    sync.Mutex
+	fetched time.Time
+	prices  map[string]float64
+}
+
+// price returns the price of the named Cluster, fetching the prices again
+// if they were fetched longer than the interval ago. If that fails, the
+// prices fetched before are kept for another interval.
+func (p *pricing) price(ctx context.Context, name string) (float64, bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if time.Since(p.fetched) >= p.interval {
+		prices, err := p.fetch(ctx)
+		switch {
+		case err == nil:
+			p.prices = prices
+		case p.prices == nil:
+			return 0, false, err
+		default:
+			logging.FromContext(ctx).Error(err, "Error fetching cluster prices, keeping the last ones")
+		}
+		p.fetched = time.Now()
+	}
+	price, found := p.prices[name]
+	return price, found, nil
+}
+
+func (p *pricing) fetch(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cluster pricing: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cluster pricing: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cluster pricing: %s", resp.Status)
+	}
+	prices := map[string]float64{}
+	if err := json.Unmarshal(body, &prices); err != nil {
+		return nil, fmt.Errorf("cluster pricing: invalid response: %w", err)
+	}
+	return prices, nil
+}
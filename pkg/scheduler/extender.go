@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+)
+
+// DefaultExtenderTimeout is how long a call to an extender may take.
+const DefaultExtenderTimeout = 5 * time.Second
+
+// ExtenderArgs is the body of the requests sent to an extender.
+type ExtenderArgs struct {
+	// Resource is the workload's resource, e.g. deployments.v1.apps.
+	Resource string `json:"resource"`
+	// Workload is the root workload being placed.
+	Workload json.RawMessage `json:"workload"`
+	// Clusters are the Clusters it may be placed on so far.
+	Clusters []ExtenderCluster `json:"clusters"`
+}
+
+// ExtenderCluster is a Cluster as described to an extender. Its kubeconfig
+// is left out.
+type ExtenderCluster struct {
+	Name   string                 `json:"name"`
+	Labels map[string]string      `json:"labels,omitempty"`
+	Weight int32                  `json:"weight"`
+	Status v1alpha1.ClusterStatus `json:"status"`
+}
+
+// ExtenderResult is the body of an extender's responses.
+type ExtenderResult struct {
+	// FailedClusters maps the Clusters the workload must not be placed on
+	// to why.
+	FailedClusters map[string]string `json:"failedClusters,omitempty"`
+	// Scores are added to the scores of the Clusters.
+	Scores map[string]int64 `json:"scores,omitempty"`
+	// Error fails the scheduling of the workload, which is retried.
+	Error string `json:"error,omitempty"`
+}
+
+// Extender delegates filtering and scoring to an HTTP service, which is
+// POSTed an ExtenderArgs for each workload and answers an ExtenderResult.
+type Extender struct {
+	url    string
+	client *http.Client
+}
+
+// NewExtender returns an Extender calling the given URL, each call taking at
+// most timeout.
+func NewExtender(url string, timeout time.Duration) *Extender {
+	return &Extender{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Schedule asks the extender which of the given Clusters the workload may
+// be placed on, and how they score.
+func (e *Extender) Schedule(ctx context.Context, w Workload, cls []*v1alpha1.Cluster) (*ExtenderResult, error) {
+	workload, err := json.Marshal(w.Object)
+	if err != nil {
+		return nil, err
+	}
+	args := ExtenderArgs{
+		Resource: fmt.Sprintf("%s.%s.%s", w.Resource.Resource, w.Resource.Version, w.Resource.Group),
+		Workload: workload,
+	}
+	for _, cl := range cls {
+		args.Clusters = append(args.Clusters, ExtenderCluster{
+			Name:   cl.Name,
+			Labels: cl.Labels,
+			Weight: cl.EffectiveWeight(),
+			Status: cl.Status,
+		})
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("scheduler extender: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("scheduler extender: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scheduler extender: %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	var result ExtenderResult
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("scheduler extender: invalid response: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("scheduler extender: %s", result.Error)
+	}
+	for name, reason := range result.FailedClusters {
+		result.FailedClusters[name] = "extender: " + reason
+	}
+	return &result, nil
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClientRegionsAnnotation lists, comma-separated, the regions the
+	// clients of a workload are in, e.g. "eu-west-1,us-east-1", for the
+	// Latency plugin to favor the Clusters closest to them.
+	ClientRegionsAnnotation = "experimental.kcp.dev/client-regions"
+
+	// LatencyTargetAnnotation is the round-trip latency to its clients a
+	// workload is content with, e.g. "50ms". The Clusters within it of all
+	// the clients score the same.
+	LatencyTargetAnnotation = "experimental.kcp.dev/latency-target"
+)
+
+// LatencyMatrix is the round-trip latency between regions, by the region of
+// the clients, then that of the Clusters, e.g.
+// {"eu-west-1": {"us-east-1": "80ms"}}. A latency missing in one direction
+// is looked up in the other, and a region is assumed to be closest to
+// itself.
+type LatencyMatrix map[string]map[string]metav1.Duration
+
+// LoadLatencyMatrix reads a LatencyMatrix from a JSON file.
+func LoadLatencyMatrix(path string) (LatencyMatrix, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m LatencyMatrix
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid latency matrix %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// latency returns the latency between the regions, if known.
+func (m LatencyMatrix) latency(from, to string) (time.Duration, bool) {
+	if d, found := m[from][to]; found {
+		return d.Duration, true
+	}
+	if d, found := m[to][from]; found {
+		return d.Duration, true
+	}
+	if from == to {
+		return 0, true
+	}
+	return 0, false
+}
+
+// latencyMatrix is the LatencyMatrix of the Latency plugins created from
+// then on, as SetLatencyMatrix has it.
+var latencyMatrix LatencyMatrix
+
+// SetLatencyMatrix makes the Latency plugins that New creates from then on
+// use the given LatencyMatrix.
+func SetLatencyMatrix(m LatencyMatrix) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	latencyMatrix = m
+}
+
+// Latency favors the Clusters closest to the clients of a workload, in the
+// regions of its ClientRegionsAnnotation: those with the lowest latency to
+// the farthest of them, by the region label of the Clusters and the
+// LatencyMatrix. Without a matrix, the Clusters in a client region are
+// favored. Clusters whose latency isn't known, and every Cluster of the
+// workloads without client regions, score half the MaxScore.
+type Latency struct {
+	matrix LatencyMatrix
+}
+
+// newLatency is registered in place of the zero Latency, for it to use
+// the LatencyMatrix. It's called with the registryLock held.
+func newLatency() (Plugin, error) {
+	return Latency{matrix: latencyMatrix}, nil
+}
+
+func (Latency) Name() string { return "Latency" }
+
+// Score returns the latency of the Cluster to the farthest client region of
+// the workload, in milliseconds, to be normalized.
+func (l Latency) Score(_ context.Context, w Workload, cl *v1alpha1.Cluster) (int64, error) {
+	annotations := w.Object.GetAnnotations()
+	region := cl.Labels[placement.RegionLabel]
+	if annotations[ClientRegionsAnnotation] == "" || region == "" {
+		return unknownScore, nil
+	}
+	var farthest time.Duration
+	for _, client := range strings.Split(annotations[ClientRegionsAnnotation], ",") {
+		if client = strings.TrimSpace(client); client == "" {
+			continue
+		}
+		d, known := l.matrix.latency(client, region)
+		if !known {
+			return unknownScore, nil
+		}
+		if d > farthest {
+			farthest = d
+		}
+	}
+	// An invalid target is ignored, as if the workload had none.
+	if target, err := time.ParseDuration(annotations[LatencyTargetAnnotation]); err == nil && farthest < target {
+		farthest = target
+	}
+	return farthest.Milliseconds(), nil
+}
+
+// NormalizeScores gives the closest Clusters the MaxScore and the farthest
+// none, the others in proportion.
+func (Latency) NormalizeScores(scores map[string]int64) {
+	favorLowest(scores)
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/reconciler/placement"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func ms(n int) metav1.Duration { return metav1.Duration{Duration: time.Duration(n) * time.Millisecond} }
+
+func TestLatency(t *testing.T) {
+	inRegion := func(name, region string) *v1alpha1.Cluster {
+		return cluster(name, map[string]string{placement.RegionLabel: region})
+	}
+	cls := []*v1alpha1.Cluster{
+		inRegion("dublin", "eu-west"),
+		inRegion("frankfurt", "eu-central"),
+		inRegion("virginia", "us-east"),
+		cluster("unlabeled", nil),
+	}
+	// Only one direction of each pair, the other being looked up.
+	matrix := LatencyMatrix{
+		"eu-west":    {"eu-central": ms(20), "us-east": ms(80), "ap-south": ms(120)},
+		"eu-central": {"us-east": ms(90), "ap-south": ms(110)},
+		"us-east":    {"ap-south": ms(200)},
+	}
+
+	for _, tc := range []struct {
+		desc        string
+		matrix      LatencyMatrix
+		annotations map[string]string
+		want        map[string]int64
+	}{{
+		desc: "no client regions",
+		// Every Cluster scores the same.
+		matrix: matrix,
+		want:   map[string]int64{"dublin": 50, "frankfurt": 50, "virginia": 50, "unlabeled": 50},
+	}, {
+		desc:        "a single client region",
+		matrix:      matrix,
+		annotations: map[string]string{ClientRegionsAnnotation: "eu-west"},
+		want:        map[string]int64{"dublin": 100, "frankfurt": 75, "virginia": 0, "unlabeled": 50},
+	}, {
+		desc:        "the farthest of several client regions",
+		matrix:      matrix,
+		annotations: map[string]string{ClientRegionsAnnotation: "us-east, eu-west"},
+		want:        map[string]int64{"dublin": 100, "frankfurt": 0, "virginia": 100, "unlabeled": 50},
+	}, {
+		desc:        "a client region without a Cluster",
+		matrix:      matrix,
+		annotations: map[string]string{ClientRegionsAnnotation: "ap-south"},
+		want:        map[string]int64{"dublin": 88, "frankfurt": 100, "virginia": 0, "unlabeled": 50},
+	}, {
+		desc:        "within the latency target",
+		matrix:      matrix,
+		annotations: map[string]string{ClientRegionsAnnotation: "eu-west", LatencyTargetAnnotation: "50ms"},
+		want:        map[string]int64{"dublin": 100, "frankfurt": 100, "virginia": 0, "unlabeled": 50},
+	}, {
+		desc:        "an invalid latency target",
+		matrix:      matrix,
+		annotations: map[string]string{ClientRegionsAnnotation: "eu-west", LatencyTargetAnnotation: "soon"},
+		want:        map[string]int64{"dublin": 100, "frankfurt": 75, "virginia": 0, "unlabeled": 50},
+	}, {
+		desc:        "latencies missing from the matrix",
+		matrix:      LatencyMatrix{"eu-west": {"eu-central": ms(20)}},
+		annotations: map[string]string{ClientRegionsAnnotation: "eu-west"},
+		want:        map[string]int64{"dublin": 100, "frankfurt": 0, "virginia": 50, "unlabeled": 50},
+	}, {
+		desc:        "no matrix",
+		annotations: map[string]string{ClientRegionsAnnotation: "us-east"},
+		// Only the Clusters in the client region are known to be close.
+		want: map[string]int64{"dublin": 50, "frankfurt": 50, "virginia": 100, "unlabeled": 50},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			SetLatencyMatrix(tc.matrix)
+			defer SetLatencyMatrix(nil)
+			s, err := New([]string{"Latency"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := s.Schedule(context.Background(), workload(tc.annotations), cls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Scores, tc.want) {
+				t.Errorf("got scores %v, want %v", result.Scores, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadLatencyMatrix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latencies.json")
+	if err := os.WriteFile(path, []byte(`{"eu-west": {"us-east": "80ms"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadLatencyMatrix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, known := m.latency("us-east", "eu-west"); !known || d != 80*time.Millisecond {
+		t.Errorf("got %v, %t, want 80ms", d, known)
+	}
+}
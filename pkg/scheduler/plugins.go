@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// ClusterSelectorAnnotation holds a label selector, e.g.
+	// "region in (eu, us)", that the Clusters of a workload must match when
+	// the ClusterSelector plugin is enabled.
+	ClusterSelectorAnnotation = "experimental.kcp.dev/cluster-selector"
+
+	// MaxScore is the highest score the built-in score plugins give.
+	MaxScore = 100
+
+	// unknownScore is what the score plugins measuring a cost give the
+	// Clusters whose cost isn't known, until their scores are normalized.
+	unknownScore = -1
+)
+
+func init() {
+	Register(ClusterSelector{}.Name(), func() (Plugin, error) { return ClusterSelector{}, nil })
+	Register(LeastRequested{}.Name(), func() (Plugin, error) { return LeastRequested{}, nil })
+	Register(Cost{}.Name(), newCost)
+	Register(Latency{}.Name(), newLatency)
+}
+
+// ClusterSelector filters out the Clusters whose labels don't match the
+// workload's ClusterSelectorAnnotation, if set.
+type ClusterSelector struct{}
+
+func (ClusterSelector) Name() string { return "ClusterSelector" }
+
+func (ClusterSelector) Filter(_ context.Context, w Workload, cl *v1alpha1.Cluster) (string, error) {
+	s, found := w.Object.GetAnnotations()[ClusterSelectorAnnotation]
+	if !found {
+		return "", nil
+	}
+	sel, err := labels.Parse(s)
+	if err != nil {
+		// Retrying won't help until the annotation is fixed.
+		return fmt.Sprintf("invalid %s: %v", ClusterSelectorAnnotation, err), nil
+	}
+	if !sel.Matches(labels.Set(cl.Labels)) {
+		return fmt.Sprintf("labels don't match %q", s), nil
+	}
+	return "", nil
+}
+
+// LeastRequested favors the Clusters with the largest share of their
+// allocatable CPU and memory free, as reported in their status. Clusters
+// that don't report their capacity score half the MaxScore.
+type LeastRequested struct{}
+
+func (LeastRequested) Name() string { return "LeastRequested" }
+
+func (LeastRequested) Score(_ context.Context, _ Workload, cl *v1alpha1.Cluster) (int64, error) {
+	var total, n int64
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		allocatable, found := cl.Status.Allocatable[name]
+		if !found || allocatable.IsZero() {
+			continue
+		}
+		requested := cl.Status.Requested[name]
+		free := allocatable.MilliValue() - requested.MilliValue()
+		if free < 0 {
+			free = 0
+		}
+		total += free * MaxScore / allocatable.MilliValue()
+		n++
+	}
+	if n == 0 {
+		return MaxScore / 2, nil
+	}
+	return total / n, nil
+}
+
+// favorLowest normalizes the costs of Clusters, the lowest getting the
+// MaxScore and the highest none, the others in proportion. Clusters of an
+// unknownScore get half the MaxScore.
+func favorLowest(scores map[string]int64) {
+	lowest, highest := int64(math.MaxInt64), int64(unknownScore)
+	for _, cost := range scores {
+		if cost == unknownScore {
+			continue
+		}
+		if cost < lowest {
+			lowest = cost
+		}
+		if cost > highest {
+			highest = cost
+		}
+	}
+	for name, cost := range scores {
+		switch {
+		case cost == unknownScore:
+			scores[name] = MaxScore / 2
+		case highest == lowest:
+			scores[name] = MaxScore
+		default:
+			scores[name] = MaxScore * (highest - cost) / (highest - lowest)
+		}
+	}
+}
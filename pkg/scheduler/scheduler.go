@@ -0,0 +1,245 @@
+// Package scheduler lets the splitters' placement decisions be extended
+// without forking them: Filter plugins rule Clusters out for a workload and
+// Score plugins rank the rest, and an HTTP extender can do both from outside
+// the process.
+//
+// The splitters first place a workload as the Clusters' readiness, taints
+// and the workload's PlacementPolicy allow, then run the Scheduler on the
+// result. Split workloads are spread across every Cluster left, while pinned
+// ones go to the best scored.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Workload is the root workload being placed.
+type Workload struct {
+	// Resource is the workload's resource, e.g. deployments.v1.apps.
+	Resource schema.GroupVersionResource
+	// Object is the root itself, e.g. an *appsv1.Deployment or an
+	// *unstructured.Unstructured. Plugins must not modify it.
+	Object metav1.Object
+}
+
+// Plugin is a named scheduler plugin. It must implement FilterPlugin,
+// ScorePlugin or both.
+type Plugin interface {
+	Name() string
+}
+
+// FilterPlugin rules out the Clusters a workload must not be placed on.
+type FilterPlugin interface {
+	Plugin
+	// Filter returns why the Cluster can't take the workload, or "" if it
+	// can.
+	Filter(ctx context.Context, w Workload, cl *v1alpha1.Cluster) (string, error)
+}
+
+// ScorePlugin ranks the Clusters that passed the filters.
+type ScorePlugin interface {
+	Plugin
+	// Score returns how well the Cluster suits the workload; higher is
+	// better. The scores of all plugins are summed.
+	Score(ctx context.Context, w Workload, cl *v1alpha1.Cluster) (int64, error)
+}
+
+// NormalizeScorePlugin is a ScorePlugin whose scores only make sense
+// relative to one another's. Its scores of all the Clusters of a workload
+// are normalized, between 0 and MaxScore, before they're summed.
+type NormalizeScorePlugin interface {
+	ScorePlugin
+	// NormalizeScores replaces the scores of the Clusters, by name.
+	NormalizeScores(scores map[string]int64)
+}
+
+// Factory creates a Plugin.
+type Factory func() (Plugin, error)
+
+var (
+	registryLock sync.Mutex
+	registry     = map[string]Factory{}
+)
+
+// Register makes a plugin available to New under the given name. Plugins
+// compiled into a binary register themselves from an init function.
+func Register(name string, factory Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, found := registry[name]; found {
+		panic(fmt.Sprintf("scheduler plugin %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Scheduler runs the enabled plugins, then the extender if any, on the
+// Clusters a workload may be placed on. A nil Scheduler allows every
+// Cluster.
+type Scheduler struct {
+	filters  []FilterPlugin
+	scores   []ScorePlugin
+	extender *Extender
+}
+
+// New returns a Scheduler running the named registered plugins, in order,
+// and the given extender if not nil. It returns nil if there's nothing to
+// run.
+func New(plugins []string, extender *Extender) (*Scheduler, error) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	s := &Scheduler{extender: extender}
+	for _, name := range plugins {
+		factory, found := registry[name]
+		if !found {
+			return nil, fmt.Errorf("unknown scheduler plugin %q, must be one of %s", name, strings.Join(registered(), ", "))
+		}
+		p, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("scheduler plugin %q: %w", name, err)
+		}
+		filter, isFilter := p.(FilterPlugin)
+		score, isScore := p.(ScorePlugin)
+		if !isFilter && !isScore {
+			return nil, fmt.Errorf("scheduler plugin %q is neither a filter nor a score plugin", name)
+		}
+		if isFilter {
+			s.filters = append(s.filters, filter)
+		}
+		if isScore {
+			s.scores = append(s.scores, score)
+		}
+	}
+	if len(s.filters) == 0 && len(s.scores) == 0 && s.extender == nil {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func registered() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Result is the outcome of scheduling a workload.
+type Result struct {
+	// Clusters are the Clusters the workload may be placed on, in the order
+	// they were given.
+	Clusters []*v1alpha1.Cluster
+	// Scores are the summed scores of those Clusters.
+	Scores map[string]int64
+	// Message explains why no Cluster is left, if so.
+	Message string
+}
+
+// Ranked returns the given Clusters ordered by score, best first, keeping
+// the given order between equal scores.
+func (r *Result) Ranked(cls []*v1alpha1.Cluster) []*v1alpha1.Cluster {
+	ranked := append([]*v1alpha1.Cluster{}, cls...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return r.Scores[ranked[i].Name] > r.Scores[ranked[j].Name]
+	})
+	return ranked
+}
+
+// Schedule filters and scores the given Clusters for the workload. If every
+// Cluster is filtered out, the Result's Message says why.
+func (s *Scheduler) Schedule(ctx context.Context, w Workload, cls []*v1alpha1.Cluster) (*Result, error) {
+	result := &Result{Clusters: cls, Scores: map[string]int64{}}
+	if s == nil || len(cls) == 0 {
+		return result, nil
+	}
+
+	failed := map[string]string{}
+	var feasible []*v1alpha1.Cluster
+	for _, cl := range cls {
+		reason, err := s.filter(ctx, w, cl)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			failed[cl.Name] = reason
+			continue
+		}
+		feasible = append(feasible, cl)
+	}
+	for _, p := range s.scores {
+		scores := make(map[string]int64, len(feasible))
+		for _, cl := range feasible {
+			score, err := p.Score(ctx, w, cl)
+			if err != nil {
+				return nil, fmt.Errorf("scheduler plugin %q: %w", p.Name(), err)
+			}
+			scores[cl.Name] = score
+		}
+		if n, ok := p.(NormalizeScorePlugin); ok && len(scores) > 0 {
+			n.NormalizeScores(scores)
+		}
+		for name, score := range scores {
+			result.Scores[name] += score
+		}
+	}
+
+	if s.extender != nil && len(feasible) > 0 {
+		extended, err := s.extender.Schedule(ctx, w, feasible)
+		if err != nil {
+			return nil, err
+		}
+		var kept []*v1alpha1.Cluster
+		for _, cl := range feasible {
+			if reason, found := extended.FailedClusters[cl.Name]; found {
+				failed[cl.Name] = reason
+				continue
+			}
+			kept = append(kept, cl)
+			result.Scores[cl.Name] += extended.Scores[cl.Name]
+		}
+		feasible = kept
+	}
+
+	result.Clusters = feasible
+	if len(feasible) == 0 {
+		result.Message = unschedulable(failed)
+	}
+	return result, nil
+}
+
+// filter returns the reason of the first enabled filter that rules the
+// Cluster out, if any.
+func (s *Scheduler) filter(ctx context.Context, w Workload, cl *v1alpha1.Cluster) (string, error) {
+	for _, p := range s.filters {
+		reason, err := p.Filter(ctx, w, cl)
+		if err != nil {
+			return "", fmt.Errorf("scheduler plugin %q: %w", p.Name(), err)
+		}
+		if reason != "" {
+			return fmt.Sprintf("%s: %s", p.Name(), reason), nil
+		}
+	}
+	return "", nil
+}
+
+func unschedulable(failed map[string]string) string {
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, fmt.Sprintf("cluster %s (%s)", name, failed[name]))
+	}
+	return "The scheduler ruled out every cluster: " + strings.Join(reasons, ", ")
+}
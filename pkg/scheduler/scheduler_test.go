@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func cluster(name string, labels map[string]string) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func names(cls []*v1alpha1.Cluster) []string {
+	var names []string
+	for _, cl := range cls {
+		names = append(names, cl.Name)
+	}
+	return names
+}
+
+func workload(annotations map[string]string) Workload {
+	return Workload{
+		Resource: appsv1.SchemeGroupVersion.WithResource("deployments"),
+		Object:   &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Annotations: annotations}},
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	ctx := context.Background()
+	cls := []*v1alpha1.Cluster{
+		cluster("a", map[string]string{"region": "eu"}),
+		cluster("b", map[string]string{"region": "us"}),
+		cluster("c", map[string]string{"region": "eu"}),
+	}
+
+	s, err := New([]string{"ClusterSelector"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Schedule(ctx, workload(map[string]string{ClusterSelectorAnnotation: "region=eu"}), cls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(result.Clusters), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ClusterSelector kept %v, want %v", got, want)
+	}
+	result, err = s.Schedule(ctx, workload(map[string]string{ClusterSelectorAnnotation: "region=asia"}), cls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Clusters) != 0 || result.Message == "" {
+		t.Errorf("ClusterSelector kept %v with message %q, want none and a message", names(result.Clusters), result.Message)
+	}
+
+	extender := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var args ExtenderArgs
+		if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(ExtenderResult{
+			FailedClusters: map[string]string{"a": "full"},
+			Scores:         map[string]int64{"c": 10},
+		})
+	}))
+	defer extender.Close()
+	s, err = New(nil, NewExtender(extender.URL, DefaultExtenderTimeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = s.Schedule(ctx, workload(nil), cls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(result.Clusters), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extender kept %v, want %v", got, want)
+	}
+	if got, want := names(result.Ranked(result.Clusters)), []string{"c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ranked() = %v, want %v", got, want)
+	}
+
+	if _, err := New([]string{"Unknown"}, nil); err == nil {
+		t.Error("New() with an unknown plugin succeeded")
+	}
+	if s, err := New(nil, nil); s != nil || err != nil {
+		t.Errorf("New() with nothing to run = %v, %v, want nil, nil", s, err)
+	}
+}
+
+func TestCost(t *testing.T) {
+	ctx := context.Background()
+	priced := func(name, cost string) *v1alpha1.Cluster {
+		cl := cluster(name, nil)
+		cl.Annotations = map[string]string{CostAnnotation: cost}
+		return cl
+	}
+	cls := []*v1alpha1.Cluster{priced("a", "0.30"), priced("b", "0.10"), cluster("c", nil), priced("d", "0.20")}
+
+	s, err := New([]string{"Cost"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Schedule(ctx, workload(nil), cls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int64{"a": 0, "b": MaxScore, "c": MaxScore / 2, "d": MaxScore / 2}; !reflect.DeepEqual(result.Scores, want) {
+		t.Errorf("scores from annotations = %v, want %v", result.Scores, want)
+	}
+
+	// The pricing URL has the last say, and its prices are refreshed.
+	var lock sync.Mutex
+	price := 0.05
+	pricing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		json.NewEncoder(w).Encode(map[string]float64{"a": price})
+	}))
+	defer pricing.Close()
+	SetCostPricing(pricing.URL, time.Nanosecond)
+	defer SetCostPricing("", 0)
+	if s, err = New([]string{"Cost"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = s.Schedule(ctx, workload(nil), cls); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(result.Ranked(cls)); got[0] != "a" {
+		t.Errorf("ranked %v, want a first", got)
+	}
+	lock.Lock()
+	price = 1
+	lock.Unlock()
+	if result, err = s.Schedule(ctx, workload(nil), cls); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(result.Ranked(cls)); got[0] != "b" || got[3] != "a" {
+		t.Errorf("ranked %v once refreshed, want b first and a last", got)
+	}
+}
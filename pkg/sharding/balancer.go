@@ -0,0 +1,144 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// endpoint is one of the servers of a shard, which share its storage.
+type endpoint struct {
+	url     *url.URL
+	healthy int32
+}
+
+func (e *endpoint) isHealthy() bool { return atomic.LoadInt32(&e.healthy) == 1 }
+
+// setHealthy records whether the endpoint is healthy, and returns whether
+// it was.
+func (e *endpoint) setHealthy(healthy bool) bool {
+	v := int32(0)
+	if healthy {
+		v = 1
+	}
+	return atomic.SwapInt32(&e.healthy, v) == 1
+}
+
+// balancer is the transport of the requests to a shard, which it spreads
+// over the shard's healthy endpoints in turn. Requests that can safely be
+// sent again, those reading without a body, are retried on the next
+// endpoint when one can't be reached.
+type balancer struct {
+	shard     string
+	transport http.RoundTripper
+	endpoints []*endpoint
+
+	mu   sync.Mutex
+	next int
+}
+
+func newBalancer(shard string, transport http.RoundTripper, urls []string) (*balancer, error) {
+	b := &balancer{shard: shard, transport: transport}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint of shard %s: %w", shard, err)
+		}
+		// Healthy until checked otherwise.
+		b.endpoints = append(b.endpoints, &endpoint{url: parsed, healthy: 1})
+	}
+	return b, nil
+}
+
+// healthy returns the healthy endpoints, starting with the one the next
+// request goes to.
+func (b *balancer) healthy() []*endpoint {
+	b.mu.Lock()
+	start := b.next
+	b.next = (b.next + 1) % len(b.endpoints)
+	b.mu.Unlock()
+
+	var healthy []*endpoint
+	for i := range b.endpoints {
+		if e := b.endpoints[(start+i)%len(b.endpoints)]; e.isHealthy() {
+			healthy = append(healthy, e)
+		}
+	}
+	return healthy
+}
+
+func (b *balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoints := b.healthy()
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no healthy endpoint of shard %s", b.shard)
+	}
+	var err error
+	for i, e := range endpoints {
+		if i > 0 && !canRetry(req) {
+			break
+		}
+		out := req.Clone(req.Context())
+		out.URL.Scheme, out.URL.Host = e.url.Scheme, e.url.Host
+		out.Host = e.url.Host
+		var resp *http.Response
+		if resp, err = b.transport.RoundTrip(out); err == nil {
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			// The client went away.
+			return nil, err
+		}
+		if e.setHealthy(false) {
+			klog.Infof("Endpoint %s of shard %s is unreachable: %v", e.url.Host, b.shard, err)
+		}
+	}
+	return nil, err
+}
+
+// canRetry reports whether the request can be sent again after failing.
+func canRetry(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+// check checks the health of every endpoint, by its /readyz.
+func (b *balancer) check(ctx context.Context, timeout time.Duration) {
+	for _, e := range b.endpoints {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		healthy := false
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url.Scheme+"://"+e.url.Host+"/readyz", nil)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = b.transport.RoundTrip(req); err == nil {
+				resp.Body.Close()
+				healthy = resp.StatusCode == http.StatusOK
+				if !healthy {
+					err = fmt.Errorf("/readyz returned %s", resp.Status)
+				}
+			}
+		}
+		cancel()
+		if was := e.setHealthy(healthy); was != healthy {
+			if healthy {
+				klog.Infof("Endpoint %s of shard %s is healthy", e.url.Host, b.shard)
+			} else {
+				klog.Infof("Endpoint %s of shard %s is unhealthy: %v", e.url.Host, b.shard, err)
+			}
+		}
+	}
+}
+
+// run checks the health of the endpoints every interval until ctx is done.
+func (b *balancer) run(ctx context.Context, interval time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) { b.check(ctx, interval) }, interval)
+}
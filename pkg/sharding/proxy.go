@@ -0,0 +1,106 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewProxy returns a handler routing the requests to the logical cluster of
+// each Workspace, under its logicalcluster.Path, to its shard, and all the
+// others to the root shard, whose Workspaces are watched until ctx is done.
+//
+// The requests to a shard are spread over its endpoints, the servers
+// sharing its storage: those of endpoints, or else the server of its
+// context. Those whose /readyz fails, checked every healthInterval, or that
+// can't be reached are left out until they're healthy again.
+//
+// Requests are forwarded with the credentials of their clients, which the
+// shards must all accept, such as tokens; client certificates don't make it
+// past the proxy. Requests across every workspace can't be routed to one
+// shard, so they're rejected: the controllers that make them must reach
+// their shard directly.
+func NewProxy(ctx context.Context, s *Shards, endpoints map[string][]string, healthInterval time.Duration) (http.Handler, error) {
+	for name := range endpoints {
+		if s.Config(name) == nil {
+			return nil, fmt.Errorf("endpoints of the unknown shard %s", name)
+		}
+	}
+	proxies := map[string]*httputil.ReverseProxy{}
+	for _, name := range s.Names() {
+		// Only the shard's CA is kept, not its credentials.
+		cfg := rest.AnonymousClientConfig(s.Config(name))
+		transport, err := rest.TransportFor(cfg)
+		if err != nil {
+			return nil, err
+		}
+		urls := endpoints[name]
+		if len(urls) == 0 {
+			urls = []string{cfg.Host}
+		}
+		b, err := newBalancer(name, transport, urls)
+		if err != nil {
+			return nil, err
+		}
+		go b.run(ctx, healthInterval)
+
+		proxy := httputil.NewSingleHostReverseProxy(b.endpoints[0].url)
+		proxy.Transport = b
+		// Stream watches as they come.
+		proxy.FlushInterval = -1
+		proxies[name] = proxy
+	}
+	return &router{shards: s, workspaces: s.Workspaces(ctx), proxies: proxies}, nil
+}
+
+type router struct {
+	shards     *Shards
+	workspaces cache.Indexer
+	proxies    map[string]*httputil.ReverseProxy
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	shard, err := r.route(req.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if shard == "*" {
+		http.Error(w, "requests across every workspace must be made to each shard", http.StatusBadRequest)
+		return
+	}
+	proxy, found := r.proxies[shard]
+	if !found {
+		http.Error(w, "the workspace is assigned to the unknown shard "+shard, http.StatusBadGateway)
+		return
+	}
+	proxy.ServeHTTP(w, req)
+}
+
+// route returns the shard to forward the request to the path to, or * for
+// the requests across every workspace.
+func (r *router) route(path string) (string, error) {
+	prefix := logicalcluster.Path("")
+	if !strings.HasPrefix(path, prefix) {
+		return r.shards.Root, nil
+	}
+	workspace := strings.TrimPrefix(path, prefix)
+	if i := strings.Index(workspace, "/"); i >= 0 {
+		workspace = workspace[:i]
+	}
+	if workspace == "*" {
+		return "*", nil
+	}
+	shard, err := r.shards.shardOfName(r.workspaces, workspace)
+	if err != nil || shard != "" {
+		return shard, err
+	}
+	return r.shards.Root, nil
+}
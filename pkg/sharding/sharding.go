@@ -0,0 +1,137 @@
+// Package sharding spreads the workspaces of kcp over several kcp servers,
+// its shards, for scale.
+//
+// The shards are the contexts of a kubeconfig, named like them, whose
+// current context is the root shard: the one whose admin logical cluster
+// Workspaces are created in. The workspace controller, run on the root
+// shard, assigns each Workspace to a shard, where its logical cluster is
+// kept, and a Proxy in front of the shards routes the requests to each
+// workspace to its shard. The controllers running on a shard can be
+// restricted to the workspaces assigned to it with a Filter.
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	"github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Shards are the kcp servers workspaces are spread over.
+type Shards struct {
+	// Root is the name of the root shard.
+	Root string
+
+	configs map[string]*rest.Config
+}
+
+// Load returns the Shards of the kubeconfig at path.
+func Load(path string) (*Shards, error) {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := config.Contexts[config.CurrentContext]; !found {
+		return nil, fmt.Errorf("no current context in %s for the root shard", path)
+	}
+	shards := &Shards{Root: config.CurrentContext, configs: map[string]*rest.Config{}}
+	for name := range config.Contexts {
+		cfg, err := clientcmd.NewNonInteractiveClientConfig(*config, name, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		shards.configs[name] = cfg
+	}
+	return shards, nil
+}
+
+// Names returns the sorted names of the Shards.
+func (s *Shards) Names() []string {
+	names := make([]string, 0, len(s.configs))
+	for name := range s.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config returns the config reaching the admin logical cluster of the named
+// shard, or nil if there's no such shard.
+func (s *Shards) Config(name string) *rest.Config {
+	return s.configs[name]
+}
+
+// Assign returns the shard a workspace that isn't assigned to any yet goes
+// to: that whose hash with the workspace is the highest, so that adding a
+// shard only takes the workspaces it wins from the others.
+func Assign(workspace string, shards []string) string {
+	var best string
+	var bestHash uint64
+	for _, shard := range shards {
+		h := fnv.New64a()
+		h.Write([]byte(shard + "/" + workspace))
+		if sum := h.Sum64(); best == "" || sum > bestHash {
+			best, bestHash = shard, sum
+		}
+	}
+	return best
+}
+
+// ShardOf returns the shard of the Workspace: the one it's assigned to, if
+// any, else the one it asks for, else the one it will be assigned.
+func ShardOf(ws *v1alpha1.Workspace, shards []string) string {
+	if ws.Status.Shard != "" {
+		return ws.Status.Shard
+	}
+	if ws.Spec.Shard != "" {
+		return ws.Spec.Shard
+	}
+	return Assign(ws.Name, shards)
+}
+
+// Workspaces returns the indexer of an informer of the Workspaces of the
+// root shard, run until ctx is done, once it's synced.
+func (s *Shards) Workspaces(ctx context.Context) cache.Indexer {
+	client := clusterclient.NewForConfigOrDie(s.Config(s.Root))
+	csif := externalversions.NewSharedInformerFactory(client, 0)
+	workspaces := csif.Cluster().V1alpha1().Workspaces().Informer()
+	csif.Start(ctx.Done())
+	csif.WaitForCacheSync(ctx.Done())
+	return workspaces.GetIndexer()
+}
+
+// shardOfName returns the shard of the named workspace in the indexer of
+// Workspaces, or "" if there's no such Workspace, e.g. for the admin logical
+// cluster, which every shard has its own of.
+func (s *Shards) shardOfName(workspaces cache.Indexer, name string) (string, error) {
+	obj, exists, err := workspaces.GetByKey(name)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", nil
+	}
+	return ShardOf(obj.(*v1alpha1.Workspace), s.Names()), nil
+}
+
+// Filter returns a func reporting whether a workspace is kept on the named
+// shard, for the controllers running there to only reconcile those. The
+// logical clusters that aren't Workspaces, such as admin, are local to each
+// shard, so they're kept on all of them.
+func (s *Shards) Filter(ctx context.Context, shard string) (func(workspace string) bool, error) {
+	if s.Config(shard) == nil {
+		return nil, fmt.Errorf("no shard %s", shard)
+	}
+	workspaces := s.Workspaces(ctx)
+	return func(workspace string) bool {
+		owner, err := s.shardOfName(workspaces, workspace)
+		return err == nil && (owner == "" || owner == shard)
+	}, nil
+}
@@ -0,0 +1,117 @@
+package sharding
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestAssign(t *testing.T) {
+	shards := []string{"shard-a", "shard-b", "shard-c"}
+	moved := 0
+	for _, ws := range []string{"team-a", "team-b", "team-c", "team-d", "team-e", "team-f", "team-g", "team-h"} {
+		shard := Assign(ws, shards)
+		if again := Assign(ws, shards); again != shard {
+			t.Errorf("assigned %s to %s, then %s", ws, shard, again)
+		}
+		if added := Assign(ws, append(shards, "shard-d")); added != shard {
+			if added != "shard-d" {
+				t.Errorf("adding shard-d moved %s from %s to %s", ws, shard, added)
+			}
+			moved++
+		}
+	}
+	if moved == 8 {
+		t.Error("adding a shard moved every workspace to it")
+	}
+}
+
+func TestRoute(t *testing.T) {
+	shards := &Shards{Root: "root", configs: map[string]*rest.Config{"root": {}, "shard-a": {}, "shard-b": {}}}
+	workspaces := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ws := range []*v1alpha1.Workspace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "assigned"}, Status: v1alpha1.WorkspaceStatus{Shard: "shard-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pinned"}, Spec: v1alpha1.WorkspaceSpec{Shard: "shard-b"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "moved"}, Spec: v1alpha1.WorkspaceSpec{Shard: "shard-b"}, Status: v1alpha1.WorkspaceStatus{Shard: "shard-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "new"}},
+	} {
+		if err := workspaces.Add(ws); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &router{shards: shards, workspaces: workspaces}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/apis/cluster.example.dev/v1alpha1/workspaces", "root"},
+		{"/clusters/assigned/apis/apps/v1/deployments", "shard-a"},
+		{"/clusters/pinned/api/v1/namespaces", "shard-b"},
+		{"/clusters/moved/api/v1/namespaces", "shard-a"},
+		{"/clusters/new", Assign("new", shards.Names())},
+		{"/clusters/user/api/v1/namespaces", "root"},
+		{"/clusters/*/apis/apps/v1/deployments", "*"},
+	} {
+		got, err := r.route(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("routed %s to %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestBalancer(t *testing.T) {
+	var unready int32
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/readyz" && atomic.LoadInt32(&unready) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	b, err := newBalancer("shard-a", http.DefaultTransport, []string{down.URL, up.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, "http://shard-a/api", nil)
+		return b.RoundTrip(req)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := get()
+		if err != nil {
+			t.Fatalf("request %d wasn't retried on the healthy endpoint: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if b.endpoints[0].isHealthy() {
+		t.Error("the unreachable endpoint is still healthy")
+	}
+
+	post, _ := http.NewRequest(http.MethodPost, "http://shard-a/api", strings.NewReader("{}"))
+	b.endpoints[0].setHealthy(true)
+	b.next = 0
+	if _, err := b.RoundTrip(post); err == nil {
+		t.Error("a POST was retried on another endpoint")
+	}
+
+	atomic.StoreInt32(&unready, 1)
+	b.check(context.Background(), time.Second)
+	if _, err := get(); err == nil || !strings.Contains(err.Error(), "no healthy endpoint") {
+		t.Errorf("got %v with no healthy endpoint", err)
+	}
+}
@@ -0,0 +1,432 @@
+package syncer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// dependencyOwnerLabel marks the dependencies a syncer created
+	// downstream, by the ID of its cluster. Only those are updated and
+	// deleted again.
+	dependencyOwnerLabel = "experimental.kcp.dev/dependency-synced-for"
+
+	// requiredByAnnotation lists the synced objects that reference a
+	// dependency, as comma-separated <resource>.<group>/<name>. The
+	// dependency is deleted once none is left.
+	requiredByAnnotation = "experimental.kcp.dev/required-by"
+)
+
+var (
+	configMapsGVR      = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	secretsGVR         = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	serviceAccountsGVR = schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}
+
+	// dependencyGVRs are the resources that synced objects' pod templates
+	// reference, and are synced along with them, then the RBAC of their
+	// ServiceAccounts, see SetRBACVerbs, and the NetworkPolicies selecting
+	// their pods, see SetNetworkPolicies.
+	dependencyGVRs = []schema.GroupVersionResource{configMapsGVR, secretsGVR, serviceAccountsGVR, rolesGVR, roleBindingsGVR, networkPoliciesGVR}
+
+	// podSpecPaths are where the pod templates of synced objects other than
+	// Pods are, e.g. Deployments, StatefulSets and Jobs, then CronJobs.
+	podSpecPaths = [][]string{
+		{"spec", "template", "spec"},
+		{"spec", "jobTemplate", "spec", "template", "spec"},
+	}
+)
+
+// isDependency reports whether gvr is only synced as a dependency of other
+// synced objects, rather than for its own cluster label.
+func (c *Controller) isDependency(gvr schema.GroupVersionResource) bool {
+	if isRBAC(gvr) && c.rbacVerbs == nil || gvr == networkPoliciesGVR && !c.networkPolicies {
+		return false
+	}
+	for _, d := range dependencyGVRs {
+		if d == gvr {
+			return !c.isSynced(gvr)
+		}
+	}
+	return false
+}
+
+func (c *Controller) isSynced(gvr schema.GroupVersionResource) bool {
+	for _, g := range c.gvrs {
+		if g == gvr {
+			return true
+		}
+	}
+	return false
+}
+
+// watchDependency watches the upstream objects of a dependency resource,
+// whatever their labels, and those the syncer created downstream.
+func (c *Controller) watchDependency(gvr schema.GroupVersionResource) {
+	c.fromUnlabeledDSIF.ForResource(gvr).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) { c.enqueue(gvr, obj, toDownstream) },
+		DeleteFunc: func(obj interface{}) { c.enqueue(gvr, obj, toDownstream) },
+	})
+	c.toDependencyDSIF.ForResource(gvr).Informer()
+}
+
+// podSpecOf returns the pod spec of a synced object, if it has one.
+func podSpecOf(u *unstructured.Unstructured) (*corev1.PodSpec, error) {
+	paths := podSpecPaths
+	if u.GetKind() == "Pod" {
+		paths = [][]string{{"spec"}}
+	}
+	for _, path := range paths {
+		m, found, err := unstructured.NestedMap(u.Object, path...)
+		if err != nil || !found {
+			continue
+		}
+		spec := &corev1.PodSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, spec); err != nil {
+			return nil, err
+		}
+		return spec, nil
+	}
+	return nil, nil
+}
+
+// dependenciesOf returns the names of the objects a pod spec references, by
+// resource.
+func dependenciesOf(spec *corev1.PodSpec) map[schema.GroupVersionResource]sets.String {
+	configMaps, secrets, serviceAccounts := sets.NewString(), sets.NewString(), sets.NewString()
+	deps := map[schema.GroupVersionResource]sets.String{
+		configMapsGVR:      configMaps,
+		secretsGVR:         secrets,
+		serviceAccountsGVR: serviceAccounts,
+	}
+	if spec == nil {
+		return deps
+	}
+
+	// Every namespace has its own default ServiceAccount.
+	if sa := spec.ServiceAccountName; sa != "" && sa != "default" {
+		serviceAccounts.Insert(sa)
+	}
+	for _, s := range spec.ImagePullSecrets {
+		secrets.Insert(s.Name)
+	}
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			configMaps.Insert(v.ConfigMap.Name)
+		}
+		if v.Secret != nil {
+			secrets.Insert(v.Secret.SecretName)
+		}
+		if v.Projected != nil {
+			for _, source := range v.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMaps.Insert(source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					secrets.Insert(source.Secret.Name)
+				}
+			}
+		}
+	}
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, from := range container.EnvFrom {
+			if from.ConfigMapRef != nil {
+				configMaps.Insert(from.ConfigMapRef.Name)
+			}
+			if from.SecretRef != nil {
+				secrets.Insert(from.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps.Insert(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets.Insert(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	for _, names := range deps {
+		names.Delete("")
+	}
+	return deps
+}
+
+func dependentRef(gvr schema.GroupVersionResource, name string) string {
+	return gvr.GroupResource().String() + "/" + name
+}
+
+func requiredBy(u *unstructured.Unstructured) sets.String {
+	refs := sets.NewString()
+	if v := u.GetAnnotations()[requiredByAnnotation]; v != "" {
+		refs.Insert(strings.Split(v, ",")...)
+	}
+	return refs
+}
+
+// dependencyCopy returns the object to apply downstream for an upstream
+// dependency required by the given synced objects.
+func (c *Controller) dependencyCopy(gvr schema.GroupVersionResource, upstream *unstructured.Unstructured, refs sets.String) (*unstructured.Unstructured, error) {
+	want, err := c.downstreamCopy(gvr, upstream)
+	if err != nil {
+		return nil, err
+	}
+	// The ServiceAccount's token Secrets are specific to each cluster.
+	unstructured.RemoveNestedField(want.Object, "secrets")
+	if err := restrictRBAC(gvr, upstream.GetNamespace(), c.rbacVerbs, c.serviceAccounts, want); err != nil {
+		return nil, err
+	}
+
+	l := want.GetLabels()
+	if l == nil {
+		l = map[string]string{}
+	}
+	l[dependencyOwnerLabel] = c.clusterID
+	want.SetLabels(l)
+	annotations := want.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[requiredByAnnotation] = strings.Join(refs.List(), ",")
+	want.SetAnnotations(annotations)
+	return want, nil
+}
+
+// syncDependencies syncs the ConfigMaps, Secrets and ServiceAccounts that
+// the pod spec of a synced object references to its namespace downstream,
+// along with the Roles bound to its ServiceAccount and the NetworkPolicies
+// selecting its pods, of the given labels, if set, and releases those it
+// doesn't reference anymore, e.g. because it was deleted and spec is nil.
+// The namespace is the upstream one.
+func (c *Controller) syncDependencies(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string, spec *corev1.PodSpec, podLabels map[string]string) error {
+	ref := dependentRef(gvr, name)
+	deps := dependenciesOf(spec)
+	if c.rbacVerbs != nil && spec != nil && c.syncsRBACOf(namespace) {
+		bindings, roles, err := c.rbacFor(namespace, serviceAccountOf(spec))
+		if err != nil {
+			return err
+		}
+		deps[roleBindingsGVR], deps[rolesGVR] = bindings, roles
+	}
+	if c.networkPolicies && spec != nil {
+		policies, err := c.networkPoliciesFor(namespace, podLabels)
+		if err != nil {
+			return err
+		}
+		deps[networkPoliciesGVR] = policies
+	}
+	for _, depGVR := range dependencyGVRs {
+		if !c.isDependency(depGVR) {
+			continue
+		}
+		wanted := deps[depGVR]
+
+		current, err := c.toDependencyDSIF.ForResource(depGVR).Lister().ByNamespace(c.namespaces.downstream(namespace)).List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, obj := range current {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok || wanted.Has(u.GetName()) || !requiredBy(u).Has(ref) || c.collides(u, namespace) {
+				continue
+			}
+			if err := c.releaseDependency(ctx, depGVR, u, sets.NewString(ref)); err != nil {
+				return err
+			}
+		}
+
+		for _, depName := range wanted.List() {
+			if err := c.requireDependency(ctx, depGVR, namespace, depName, ref); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// requireDependency creates or updates a dependency downstream, recording
+// that the synced object ref requires it. Dependencies that already existed
+// downstream are left alone.
+func (c *Controller) requireDependency(ctx context.Context, gvr schema.GroupVersionResource, namespace, name, ref string) error {
+	key := namespace + "/" + name
+	obj, found, err := c.fromUnlabeledDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(key)
+	if err != nil || !found {
+		// Pods wait for missing dependencies as they would on any cluster.
+		return err
+	}
+	upstream, err := interfaceToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	if gvr == secretsGVR && upstream.Object["type"] == string(corev1.SecretTypeServiceAccountToken) {
+		// Tokens of upstream ServiceAccounts mean nothing downstream.
+		return nil
+	}
+
+	client := c.getClient(gvr, c.namespaces.downstream(namespace))
+	existing, exists, err := c.toDependencyDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(c.downstreamKey(key))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		want, err := c.dependencyCopy(gvr, upstream, sets.NewString(ref))
+		if err != nil {
+			return err
+		}
+		if _, err := client.Create(ctx, want, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		logging.FromContext(ctx).V(2).Info("Created dependency", "gvr", gvr.String(), "dependency", key)
+		return nil
+	}
+
+	current, err := interfaceToUnstructured(existing)
+	if err != nil {
+		return err
+	}
+	if c.collides(current, namespace) {
+		logging.FromContext(ctx).Info("Not syncing dependency colliding with that of another namespace", "gvr", gvr.String(), "dependency", key, "upstreamNamespace", c.upstreamNamespaceOf(current))
+		return nil
+	}
+	refs := requiredBy(current)
+	want, err := c.dependencyCopy(gvr, upstream, refs.Union(sets.NewString(ref)))
+	if err != nil {
+		return err
+	}
+	if refs.Has(ref) && matches(syncedContent(want), syncedContent(current)) {
+		return nil
+	}
+	want.SetResourceVersion(current.GetResourceVersion())
+	_, err = client.Update(ctx, want, metav1.UpdateOptions{})
+	return err
+}
+
+// releaseDependency records that the given synced objects don't require a
+// dependency anymore, and deletes it if nothing else does.
+func (c *Controller) releaseDependency(ctx context.Context, gvr schema.GroupVersionResource, current *unstructured.Unstructured, released sets.String) error {
+	client := c.getClient(gvr, current.GetNamespace())
+	refs := requiredBy(current).Difference(released)
+	if refs.Len() == 0 {
+		logging.FromContext(ctx).V(2).Info("Deleting dependency no synced object requires anymore", "gvr", gvr.String(), "dependency", keyFor(current))
+		if err := client.Delete(ctx, current.GetName(), metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	updated := current.DeepCopy()
+	annotations := updated.GetAnnotations()
+	annotations[requiredByAnnotation] = strings.Join(refs.List(), ",")
+	updated.SetAnnotations(annotations)
+	_, err := client.Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// syncDependency updates a dependency created downstream when it changes
+// upstream, deletes it when it's deleted upstream, and releases it from the
+// synced objects that don't exist anymore. The key is the upstream one.
+func (c *Controller) syncDependency(ctx context.Context, gvr schema.GroupVersionResource, key string) error {
+	existing, exists, err := c.toDependencyDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(c.downstreamKey(key))
+	if err != nil || !exists {
+		// Not required by any synced object.
+		return err
+	}
+	current, err := interfaceToUnstructured(existing)
+	if err != nil {
+		return err
+	}
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	if c.collides(current, namespace) {
+		// The dependency of another namespace's objects.
+		return nil
+	}
+
+	stale := sets.NewString()
+	for _, ref := range requiredBy(current).List() {
+		if !c.dependentExists(namespace, ref) {
+			stale.Insert(ref)
+		}
+	}
+
+	obj, found, err := c.fromUnlabeledDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// Deleted upstream, so delete it downstream too.
+		return c.releaseDependency(ctx, gvr, current, requiredBy(current))
+	}
+	if stale.Len() > 0 {
+		return c.releaseDependency(ctx, gvr, current, stale)
+	}
+
+	upstream, err := interfaceToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	want, err := c.dependencyCopy(gvr, upstream, requiredBy(current))
+	if err != nil {
+		return err
+	}
+	if matches(syncedContent(want), syncedContent(current)) {
+		return nil
+	}
+	logging.FromContext(ctx).V(2).Info("Updating dependency", "gvr", gvr.String(), "dependency", key)
+	want.SetResourceVersion(current.GetResourceVersion())
+	_, err = c.getClient(gvr, current.GetNamespace()).Update(ctx, want, metav1.UpdateOptions{})
+	return err
+}
+
+// dependentExists reports whether the synced object ref, in the given
+// namespace, still exists upstream.
+func (c *Controller) dependentExists(namespace, ref string) bool {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	gr := schema.ParseGroupResource(parts[0])
+	for _, gvr := range c.gvrs {
+		if gvr.GroupResource() != gr {
+			continue
+		}
+		_, exists, err := c.fromDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(namespace + "/" + parts[1])
+		// Keep the dependency if the cache can't tell.
+		return exists || err != nil
+	}
+	return false
+}
+
+// enqueueOwnedDependencies enqueues every dependency the syncer created
+// downstream, so that those required by synced objects that were deleted
+// while the syncer wasn't running are released.
+func (c *Controller) enqueueOwnedDependencies() error {
+	for _, gvr := range dependencyGVRs {
+		if !c.isDependency(gvr) {
+			continue
+		}
+		objs, err := c.toDependencyDSIF.ForResource(gvr).Lister().List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			c.enqueueUpstreamOf(gvr, obj)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package syncer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// dependencyFixture is a syncer for the Deployments of cluster us-east, whose
+// upstream and downstream caches are filled by hand.
+type dependencyFixture struct {
+	t    *testing.T
+	c    *Controller
+	from *dynamicfake.FakeDynamicClient
+	to   *dynamicfake.FakeDynamicClient
+}
+
+func newDependencyFixture(t *testing.T) *dependencyFixture {
+	f := &dependencyFixture{
+		t:    t,
+		from: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		to:   dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+	}
+	f.c = &Controller{
+		clusterID:         "us-east",
+		gvrs:              []schema.GroupVersionResource{deploymentsGVR},
+		fromClient:        f.from,
+		fromDSIF:          dynamicinformer.NewDynamicSharedInformerFactory(f.from, 0),
+		fromUnlabeledDSIF: dynamicinformer.NewDynamicSharedInformerFactory(f.from, 0),
+		toClient:          f.to,
+		toDependencyDSIF:  dynamicinformer.NewDynamicSharedInformerFactory(f.to, 0),
+	}
+	f.c.SetTransforms(Transforms{})
+	return f
+}
+
+// addUpstream adds objects to kcp, Deployments as synced to us-east, and the
+// others as the dependencies they may reference.
+func (f *dependencyFixture) addUpstream(gvr schema.GroupVersionResource, objs ...*unstructured.Unstructured) {
+	informer := f.c.fromUnlabeledDSIF.ForResource(gvr).Informer()
+	if gvr == deploymentsGVR {
+		informer = f.c.fromDSIF.ForResource(gvr).Informer()
+	}
+	for _, obj := range objs {
+		if err := informer.GetIndexer().Add(obj); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+}
+
+func (f *dependencyFixture) deleteUpstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) {
+	informer := f.c.fromUnlabeledDSIF.ForResource(gvr).Informer()
+	if gvr == deploymentsGVR {
+		informer = f.c.fromDSIF.ForResource(gvr).Informer()
+	}
+	if err := informer.GetIndexer().Delete(obj); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// downstream returns the objects of the cluster, by name, and refreshes the
+// cache of the dependencies the syncer created there.
+func (f *dependencyFixture) downstream(gvr schema.GroupVersionResource) map[string]*unstructured.Unstructured {
+	list, err := f.to.Resource(gvr).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	objs := map[string]*unstructured.Unstructured{}
+	var owned []interface{}
+	for i := range list.Items {
+		u := &list.Items[i]
+		objs[u.GetName()] = u
+		if u.GetLabels()[dependencyOwnerLabel] == f.c.clusterID {
+			owned = append(owned, u)
+		}
+	}
+	if err := f.c.toDependencyDSIF.ForResource(gvr).Informer().GetIndexer().Replace(owned, ""); err != nil {
+		f.t.Fatal(err)
+	}
+	return objs
+}
+
+// sync syncs the dependencies of the Deployment of the given name, as
+// configured with the given pod spec, or deleted if nil.
+func (f *dependencyFixture) sync(name string, spec *corev1.PodSpec) {
+	if err := f.c.syncDependencies(context.Background(), deploymentsGVR, "default", name, spec, nil); err != nil {
+		f.t.Fatal(err)
+	}
+	f.downstream(configMapsGVR)
+	f.downstream(secretsGVR)
+}
+
+func dependency(kind, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: fields}
+	if u.Object == nil {
+		u.Object = map[string]interface{}{}
+	}
+	u.SetAPIVersion("v1")
+	u.SetKind(kind)
+	u.SetName(name)
+	u.SetNamespace("default")
+	return u
+}
+
+func splitDeployment(name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("apps/v1")
+	u.SetKind("Deployment")
+	u.SetName(name)
+	u.SetNamespace("default")
+	return u
+}
+
+// podSpec references the ConfigMap config and the Secrets given, through
+// the environment, volumes and image pull secrets.
+func podSpec(secrets ...string) *corev1.PodSpec {
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{
+		Name:    "web",
+		EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}}},
+	}}}
+	for i, s := range secrets {
+		if i == 0 {
+			spec.ImagePullSecrets = append(spec.ImagePullSecrets, corev1.LocalObjectReference{Name: s})
+			continue
+		}
+		spec.Volumes = append(spec.Volumes, corev1.Volume{Name: s, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: s}}})
+	}
+	return spec
+}
+
+func requiredByOf(objs map[string]*unstructured.Unstructured, name string) []string {
+	u, found := objs[name]
+	if !found {
+		return nil
+	}
+	return requiredBy(u).List()
+}
+
+func TestDependenciesOf(t *testing.T) {
+	optional := true
+	spec := &corev1.PodSpec{
+		ServiceAccountName: "web",
+		ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "registry"}},
+		Volumes: []corev1.Volume{
+			{Name: "a", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "files"}}}},
+			{Name: "b", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "tls"}}},
+			{Name: "c", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+				{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "projected"}}},
+				{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "projected"}}},
+			}}}},
+		},
+		InitContainers: []corev1.Container{{
+			EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "init"}}}},
+		}},
+		Containers: []corev1.Container{{
+			EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env"}}}},
+			Env: []corev1.EnvVar{
+				{Name: "A", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "key"}, Key: "a", Optional: &optional}}},
+				{Name: "B", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "key"}, Key: "b"}}},
+				{Name: "C", Value: "c"},
+			},
+		}},
+	}
+	want := map[schema.GroupVersionResource][]string{
+		configMapsGVR:      {"env", "files", "key", "projected"},
+		secretsGVR:         {"init", "key", "projected", "registry", "tls"},
+		serviceAccountsGVR: {"web"},
+	}
+	for gvr, names := range dependenciesOf(spec) {
+		if got := names.List(); !reflect.DeepEqual(got, want[gvr]) {
+			t.Errorf("%s: got %v, want %v", gvr.Resource, got, want[gvr])
+		}
+	}
+
+	if got := dependenciesOf(&corev1.PodSpec{ServiceAccountName: "default"})[serviceAccountsGVR]; got.Len() > 0 {
+		t.Errorf("got the default ServiceAccount as a dependency: %v", got.List())
+	}
+}
+
+func TestSyncDependencies(t *testing.T) {
+	f := newDependencyFixture(t)
+	f.addUpstream(deploymentsGVR, splitDeployment("web--us-east"), splitDeployment("api--us-east"))
+	f.addUpstream(configMapsGVR, dependency("ConfigMap", "config", map[string]interface{}{"data": map[string]interface{}{"a": "1"}}))
+	f.addUpstream(secretsGVR,
+		dependency("Secret", "registry", map[string]interface{}{"type": string(corev1.SecretTypeDockerConfigJson)}),
+		dependency("Secret", "tls", nil),
+		dependency("Secret", "token", map[string]interface{}{"type": string(corev1.SecretTypeServiceAccountToken)}),
+	)
+
+	// The dependencies follow the split Deployment to its cluster, but for
+	// the missing ones and the ServiceAccount tokens.
+	f.sync("web--us-east", podSpec("registry", "tls", "missing", "token"))
+	configMaps, secrets := f.downstream(configMapsGVR), f.downstream(secretsGVR)
+	if len(configMaps) != 1 || len(secrets) != 2 {
+		t.Fatalf("synced ConfigMaps %v and Secrets %v", configMaps, secrets)
+	}
+	for _, u := range []*unstructured.Unstructured{configMaps["config"], secrets["registry"], secrets["tls"]} {
+		if u == nil {
+			t.Fatal("not synced")
+		}
+		if got := u.GetLabels()[dependencyOwnerLabel]; got != "us-east" {
+			t.Errorf("%s: owned by %q", u.GetName(), got)
+		}
+		if got := requiredBy(u).List(); !reflect.DeepEqual(got, []string{"deployments.apps/web--us-east"}) {
+			t.Errorf("%s: required by %v", u.GetName(), got)
+		}
+	}
+	if data, _, _ := unstructured.NestedStringMap(configMaps["config"].Object, "data"); data["a"] != "1" {
+		t.Errorf("synced the data %v", data)
+	}
+
+	// Another Deployment sharing a dependency.
+	f.sync("api--us-east", podSpec("registry"))
+	if got := requiredByOf(f.downstream(configMapsGVR), "config"); !reflect.DeepEqual(got, []string{"deployments.apps/api--us-east", "deployments.apps/web--us-east"}) {
+		t.Errorf("config required by %v", got)
+	}
+
+	// What a Deployment doesn't reference anymore is released, and deleted
+	// once nothing else requires it.
+	f.sync("web--us-east", podSpec("registry"))
+	secrets = f.downstream(secretsGVR)
+	if _, found := secrets["tls"]; found {
+		t.Error("kept the Secret no Deployment references anymore")
+	}
+	if got := requiredByOf(secrets, "registry"); !reflect.DeepEqual(got, []string{"deployments.apps/api--us-east", "deployments.apps/web--us-east"}) {
+		t.Errorf("registry required by %v", got)
+	}
+
+	// So is everything a deleted Deployment referenced.
+	f.deleteUpstream(deploymentsGVR, splitDeployment("web--us-east"))
+	f.sync("web--us-east", nil)
+	if got := requiredByOf(f.downstream(configMapsGVR), "config"); !reflect.DeepEqual(got, []string{"deployments.apps/api--us-east"}) {
+		t.Errorf("config required by %v", got)
+	}
+	f.deleteUpstream(deploymentsGVR, splitDeployment("api--us-east"))
+	f.sync("api--us-east", nil)
+	if configMaps, secrets := f.downstream(configMapsGVR), f.downstream(secretsGVR); len(configMaps)+len(secrets) > 0 {
+		t.Errorf("kept ConfigMaps %v and Secrets %v", configMaps, secrets)
+	}
+}
+
+func TestSyncDependenciesExisting(t *testing.T) {
+	f := newDependencyFixture(t)
+	// Created on the cluster itself.
+	existing := dependency("ConfigMap", "config", map[string]interface{}{"data": map[string]interface{}{"a": "cluster"}})
+	if _, err := f.to.Resource(configMapsGVR).Namespace("default").Create(context.Background(), existing, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.addUpstream(deploymentsGVR, splitDeployment("web--us-east"))
+	f.addUpstream(configMapsGVR, dependency("ConfigMap", "config", map[string]interface{}{"data": map[string]interface{}{"a": "kcp"}}))
+
+	f.sync("web--us-east", podSpec())
+	f.deleteUpstream(deploymentsGVR, splitDeployment("web--us-east"))
+	f.sync("web--us-east", nil)
+
+	got, found := f.downstream(configMapsGVR)["config"]
+	if !found {
+		t.Fatal("deleted the ConfigMap of the cluster")
+	}
+	if data, _, _ := unstructured.NestedStringMap(got.Object, "data"); data["a"] != "cluster" || got.GetLabels()[dependencyOwnerLabel] != "" {
+		t.Errorf("changed the ConfigMap of the cluster: %v", got.Object)
+	}
+}
+
+func TestSyncDependency(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// change changes kcp after the dependency is synced.
+		change func(f *dependencyFixture)
+		// want is the data of the dependency downstream afterwards, nil if
+		// deleted.
+		want map[string]string
+	}{{
+		name: "updated upstream",
+		change: func(f *dependencyFixture) {
+			f.addUpstream(configMapsGVR, dependency("ConfigMap", "config", map[string]interface{}{"data": map[string]interface{}{"a": "2"}}))
+		},
+		want: map[string]string{"a": "2"},
+	}, {
+		name: "deleted upstream",
+		change: func(f *dependencyFixture) {
+			f.deleteUpstream(configMapsGVR, dependency("ConfigMap", "config", nil))
+		},
+	}, {
+		name: "Deployment deleted while the syncer wasn't running",
+		change: func(f *dependencyFixture) {
+			f.deleteUpstream(deploymentsGVR, splitDeployment("web--us-east"))
+		},
+	}, {
+		name:   "unchanged",
+		change: func(*dependencyFixture) {},
+		want:   map[string]string{"a": "1"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newDependencyFixture(t)
+			f.addUpstream(deploymentsGVR, splitDeployment("web--us-east"))
+			f.addUpstream(configMapsGVR, dependency("ConfigMap", "config", map[string]interface{}{"data": map[string]interface{}{"a": "1"}}))
+			f.sync("web--us-east", podSpec())
+
+			tc.change(f)
+			if err := f.c.syncDependency(context.Background(), configMapsGVR, "default/config"); err != nil {
+				t.Fatal(err)
+			}
+			got, found := f.downstream(configMapsGVR)["config"]
+			if tc.want == nil {
+				if found {
+					t.Errorf("kept %v", got.Object)
+				}
+				return
+			}
+			if !found {
+				t.Fatal("deleted")
+			}
+			if data, _, _ := unstructured.NestedStringMap(got.Object, "data"); !reflect.DeepEqual(data, tc.want) {
+				t.Errorf("got data %v, want %v", data, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package syncer
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	"k8s.io/kube-openapi/pkg/util/sets"
+)
+
+func contains(ss []string, s string) bool {
+	for _, n := range ss {
+		if n == s {
+			return true
+		}
+	}
+	return false
+}
+
+// getAllGVRs returns the preferred version of each of the given namespaced
+// resources in the API server reached with config.
+func getAllGVRs(config *rest.Config, resourcesToSync ...string) ([]schema.GroupVersionResource, error) {
+	toSyncSet := sets.NewString(resourcesToSync...)
+	willBeSyncedSet := sets.NewString()
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := dc.ServerPreferredResources()
+	if err != nil {
+		return nil, err
+	}
+	var gvrs []schema.GroupVersionResource
+	for _, r := range rs {
+		gv, err := schema.ParseGroupVersion(r.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, ai := range r.APIResources {
+			name := ai.Name
+			if !toSyncSet.Has(name) {
+				// Or named with its group, e.g. deployments.apps.
+				name = schema.GroupResource{Group: gv.Group, Resource: ai.Name}.String()
+			}
+			if !toSyncSet.Has(name) {
+				// We're not interested in this resource type
+				continue
+			}
+			if strings.Contains(ai.Name, "/") {
+				// foo/status, pods/exec, namespace/finalize, etc.
+				continue
+			}
+			if !ai.Namespaced {
+				// Ignore cluster-scoped things.
+				continue
+			}
+			if !contains(ai.Verbs, "watch") {
+				klog.Infof("resource %s %s is not watchable: %v", r.GroupVersion, ai.Name, ai.Verbs)
+				continue
+			}
+			gvrs = append(gvrs, gv.WithResource(ai.Name))
+			willBeSyncedSet.Insert(name)
+		}
+	}
+
+	notFoundResourceTypes := toSyncSet.Difference(willBeSyncedSet)
+	if notFoundResourceTypes.Len() != 0 {
+		return nil, fmt.Errorf("The following resource types should be synced and we not found in the KCP logical cluster: %v", notFoundResourceTypes.List())
+	}
+	return gvrs, nil
+}
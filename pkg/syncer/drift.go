@@ -0,0 +1,234 @@
+package syncer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// specHashAnnotation records, on each downstream object, the hash of the
+// upstream content it was last synced from. A downstream object that no
+// longer matches that content while upstream didn't change has drifted.
+const specHashAnnotation = "experimental.kcp.dev/spec-hash"
+
+// autoscaledAnnotation marks the objects whose replicas a
+// HorizontalPodAutoscaler on their cluster owns. Their replicas are only
+// synced when they're created.
+const autoscaledAnnotation = "experimental.kcp.dev/autoscaled"
+
+func autoscaled(u *unstructured.Unstructured) bool {
+	return u.GetAnnotations()[autoscaledAnnotation] == "true"
+}
+
+// DriftMode is what the syncer does about downstream objects that were
+// changed behind its back.
+type DriftMode string
+
+const (
+	// DriftRevert applies the upstream object again. This is the default.
+	DriftRevert DriftMode = "revert"
+	// DriftReport only reports the drift, with an Event on the upstream
+	// object, and leaves the downstream object as it is.
+	DriftReport DriftMode = "report"
+	// DriftAdopt copies the downstream changes to the upstream object.
+	DriftAdopt DriftMode = "adopt"
+)
+
+// ParseDriftMode parses a DriftMode, defaulting to DriftRevert if empty.
+func ParseDriftMode(s string) (DriftMode, error) {
+	switch mode := DriftMode(s); mode {
+	case "":
+		return DriftRevert, nil
+	case DriftRevert, DriftReport, DriftAdopt:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown drift mode %q, must be one of %q, %q or %q", s, DriftRevert, DriftReport, DriftAdopt)
+	}
+}
+
+// syncedContent returns the parts of an object the syncer owns downstream:
+// everything but its status and metadata, and its labels and annotations.
+// The replicas of autoscaled objects are left out.
+func syncedContent(u *unstructured.Unstructured) map[string]interface{} {
+	out := u.DeepCopy().Object
+	delete(out, "status")
+	delete(out, "metadata")
+	if autoscaled(u) {
+		unstructured.RemoveNestedField(out, "spec", "replicas")
+	}
+
+	labels, annotations := u.GetLabels(), u.GetAnnotations()
+	delete(annotations, specHashAnnotation)
+	metadata := map[string]interface{}{}
+	if len(labels) > 0 {
+		metadata["labels"] = toInterfaceMap(labels)
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = toInterfaceMap(annotations)
+	}
+	out["metadata"] = metadata
+	return out
+}
+
+func toInterfaceMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// specHash returns the hash of the content synced from an upstream object.
+func specHash(u *unstructured.Unstructured) (string, error) {
+	b, err := json.Marshal(syncedContent(u))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+// matches reports whether every field set in want has the same value in got.
+// Fields only set in got, e.g. defaulted by the physical cluster's API
+// server, are ignored.
+func matches(want, got interface{}) bool {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		got, ok := got.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range want {
+			if !matches(v, got[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		got, ok := got.([]interface{})
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !matches(want[i], got[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return equality.Semantic.DeepEqual(want, got)
+	}
+}
+
+// ownedFields returns the fields of got that are set in want, as matches
+// compares them: those only set in got are left out, and those only set in
+// want are left out too, as unset.
+func ownedFields(want, got interface{}) interface{} {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		fields, ok := got.(map[string]interface{})
+		if !ok {
+			return got
+		}
+		out := make(map[string]interface{}, len(want))
+		for k, v := range want {
+			if g, found := fields[k]; found {
+				out[k] = ownedFields(v, g)
+			}
+		}
+		return out
+	case []interface{}:
+		items, ok := got.([]interface{})
+		if !ok || len(items) != len(want) {
+			return got
+		}
+		out := make([]interface{}, len(items))
+		for i := range items {
+			out[i] = ownedFields(want[i], items[i])
+		}
+		return out
+	default:
+		return got
+	}
+}
+
+// adoptFields sets the fields of upstream that are set in want to their
+// value in adopted, or unsets them if adopted doesn't have them. The other
+// fields of upstream, such as the replicas of autoscaled objects, are kept.
+func adoptFields(upstream, want, adopted map[string]interface{}) {
+	for k, v := range want {
+		a, found := adopted[k]
+		if !found {
+			delete(upstream, k)
+			continue
+		}
+		w, wantMap := v.(map[string]interface{})
+		am, adoptedMap := a.(map[string]interface{})
+		um, upstreamMap := upstream[k].(map[string]interface{})
+		if wantMap && adoptedMap && upstreamMap {
+			adoptFields(um, w, am)
+			continue
+		}
+		upstream[k] = a
+	}
+}
+
+// adoptedKeys returns the labels or annotations of an upstream object with
+// those the syncer owns downstream, the keys of want, as adopted has them.
+// The others are kept.
+func adoptedKeys(upstream, want, adopted map[string]string) map[string]string {
+	out := make(map[string]string, len(upstream))
+	for k, v := range upstream {
+		out[k] = v
+	}
+	for k := range want {
+		if k == specHashAnnotation {
+			continue
+		}
+		if v, found := adopted[k]; found {
+			out[k] = v
+		} else {
+			delete(out, k)
+		}
+	}
+	return out
+}
+
+// translateObservedGeneration sets the observedGeneration of status, that of
+// a downstream object of the given generation last synced from the content
+// of syncedHash, to the generation of its upstream copy if the downstream
+// controllers observed the content synced from it, and to what the status of
+// upstream says otherwise. The generations of the downstream and upstream
+// objects have nothing to do with each other, yet the splitters tell from
+// the observedGeneration of leafs whether their latest spec was rolled out.
+func (c *Controller) translateObservedGeneration(gvr schema.GroupVersionResource, status interface{}, generation int64, syncedHash string, upstream *unstructured.Unstructured) error {
+	fields, ok := status.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	observed, found, err := unstructured.NestedInt64(fields, "observedGeneration")
+	if err != nil || !found {
+		return nil
+	}
+	want, err := c.downstreamCopy(gvr, upstream)
+	if err != nil {
+		return err
+	}
+	hash, err := specHash(want)
+	if err != nil {
+		return err
+	}
+	if observed >= generation && syncedHash == hash {
+		fields["observedGeneration"] = upstream.GetGeneration()
+		return nil
+	}
+	if prev, found, _ := unstructured.NestedInt64(upstream.Object, "status", "observedGeneration"); found {
+		fields["observedGeneration"] = prev
+		return nil
+	}
+	delete(fields, "observedGeneration")
+	return nil
+}
@@ -0,0 +1,229 @@
+package syncer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+func driftTestDeployment(image string, labels, annotations map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":        "web",
+			"namespace":   "default",
+			"labels":      labels,
+			"annotations": annotations,
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"containers": []interface{}{
+					map[string]interface{}{"name": "web", "image": image},
+				}},
+			},
+		},
+	}}
+}
+
+func TestParseDriftMode(t *testing.T) {
+	for s, want := range map[string]DriftMode{"": DriftRevert, "revert": DriftRevert, "report": DriftReport, "adopt": DriftAdopt} {
+		if got, err := ParseDriftMode(s); err != nil || got != want {
+			t.Errorf("%q: got %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseDriftMode("ignore"); err == nil {
+		t.Error("parsed ignore")
+	}
+}
+
+func TestSpecHash(t *testing.T) {
+	base := driftTestDeployment("web:1", map[string]interface{}{"app": "web"}, nil)
+	hashOf := func(u *unstructured.Unstructured) string {
+		hash, err := specHash(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	want := hashOf(base)
+
+	for _, tc := range []struct {
+		name   string
+		change func(u *unstructured.Unstructured)
+		same   bool
+	}{{
+		name:   "status",
+		change: func(u *unstructured.Unstructured) { u.Object["status"] = map[string]interface{}{"replicas": int64(2)} },
+		same:   true,
+	}, {
+		name:   "resource version",
+		change: func(u *unstructured.Unstructured) { u.SetResourceVersion("42") },
+		same:   true,
+	}, {
+		name:   "spec hash annotation",
+		change: func(u *unstructured.Unstructured) { u.SetAnnotations(map[string]string{specHashAnnotation: "abc"}) },
+		same:   true,
+	}, {
+		name: "image",
+		change: func(u *unstructured.Unstructured) {
+			u.Object = driftTestDeployment("web:2", map[string]interface{}{"app": "web"}, nil).Object
+		},
+	}, {
+		name:   "label",
+		change: func(u *unstructured.Unstructured) { u.SetLabels(map[string]string{"app": "api"}) },
+	}, {
+		name:   "annotation",
+		change: func(u *unstructured.Unstructured) { u.SetAnnotations(map[string]string{"team": "a"}) },
+	}, {
+		name: "replicas",
+		change: func(u *unstructured.Unstructured) {
+			_ = unstructured.SetNestedField(u.Object, int64(3), "spec", "replicas")
+		},
+	}} {
+		u := base.DeepCopy()
+		tc.change(u)
+		if got := hashOf(u); (got == want) != tc.same {
+			t.Errorf("%s: hash unchanged: %v, want %v", tc.name, got == want, tc.same)
+		}
+	}
+
+	// The replicas of autoscaled objects belong to their cluster.
+	autoscaledBase := base.DeepCopy()
+	autoscaledBase.SetAnnotations(map[string]string{autoscaledAnnotation: "true"})
+	scaled := autoscaledBase.DeepCopy()
+	_ = unstructured.SetNestedField(scaled.Object, int64(5), "spec", "replicas")
+	if hashOf(scaled) != hashOf(autoscaledBase) {
+		t.Error("the hash of an autoscaled object changed with its replicas")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		want, got interface{}
+		matches   bool
+	}{
+		{"equal", map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "b"}, true},
+		{"defaulted field", map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "b", "c": "d"}, true},
+		{"changed field", map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "c"}, false},
+		{"missing field", map[string]interface{}{"a": "b"}, map[string]interface{}{}, false},
+		{"defaulted item field", []interface{}{map[string]interface{}{"a": "b"}}, []interface{}{map[string]interface{}{"a": "b", "c": "d"}}, true},
+		{"added item", []interface{}{"a"}, []interface{}{"a", "b"}, false},
+		{"changed type", map[string]interface{}{"a": "b"}, "a", false},
+	} {
+		if got := matches(tc.want, tc.got); got != tc.matches {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.matches)
+		}
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		upstream   *unstructured.Unstructured
+		downstream func(want *unstructured.Unstructured) *unstructured.Unstructured
+		check      func(t *testing.T, got *unstructured.Unstructured)
+	}{{
+		name: "changed fields",
+		upstream: driftTestDeployment("web:1", map[string]interface{}{"app": "web", "tier": "front"}, map[string]interface{}{
+			"team": "a",
+		}),
+		downstream: func(want *unstructured.Unstructured) *unstructured.Unstructured {
+			d := driftTestDeployment("web:2", map[string]interface{}{"app": "web2"}, map[string]interface{}{
+				"team":                              "b",
+				specHashAnnotation:                  want.GetAnnotations()[specHashAnnotation],
+				"deployment.kubernetes.io/revision": "3",
+			})
+			// Defaulted downstream.
+			_ = unstructured.SetNestedField(d.Object, int64(10), "spec", "revisionHistoryLimit")
+			_ = unstructured.SetNestedField(d.Object, "Always", "spec", "template", "spec", "restartPolicy")
+			d.Object["status"] = map[string]interface{}{"replicas": int64(2)}
+			return d
+		},
+		check: func(t *testing.T, got *unstructured.Unstructured) {
+			containers, _, _ := unstructured.NestedSlice(got.Object, "spec", "template", "spec", "containers")
+			if image := containers[0].(map[string]interface{})["image"]; image != "web:2" {
+				t.Errorf("got image %v", image)
+			}
+			for _, path := range [][]string{{"spec", "revisionHistoryLimit"}, {"spec", "template", "spec", "restartPolicy"}, {"status"}} {
+				if _, found, _ := unstructured.NestedFieldNoCopy(got.Object, path...); found {
+					t.Errorf("adopted %v", path)
+				}
+			}
+			if labels := got.GetLabels(); !reflect.DeepEqual(labels, map[string]string{"app": "web2"}) {
+				t.Errorf("got labels %v", labels)
+			}
+			if annotations := got.GetAnnotations(); !reflect.DeepEqual(annotations, map[string]string{"team": "b"}) {
+				t.Errorf("got annotations %v", annotations)
+			}
+		},
+	}, {
+		name: "metadata not synced",
+		upstream: driftTestDeployment("web:1", map[string]interface{}{"app": "web"}, map[string]interface{}{
+			"experimental.kcp.dev/trace-context": "00-abc-def-01",
+		}),
+		downstream: func(want *unstructured.Unstructured) *unstructured.Unstructured {
+			return driftTestDeployment("web:2", map[string]interface{}{"app": "web"}, map[string]interface{}{
+				specHashAnnotation: want.GetAnnotations()[specHashAnnotation],
+			})
+		},
+		check: func(t *testing.T, got *unstructured.Unstructured) {
+			if annotations := got.GetAnnotations(); !reflect.DeepEqual(annotations, map[string]string{"experimental.kcp.dev/trace-context": "00-abc-def-01"}) {
+				t.Errorf("got annotations %v", annotations)
+			}
+		},
+	}, {
+		name:     "autoscaled",
+		upstream: driftTestDeployment("web:1", nil, map[string]interface{}{autoscaledAnnotation: "true"}),
+		downstream: func(want *unstructured.Unstructured) *unstructured.Unstructured {
+			d := driftTestDeployment("web:2", nil, map[string]interface{}{autoscaledAnnotation: "true"})
+			_ = unstructured.SetNestedField(d.Object, int64(7), "spec", "replicas")
+			return d
+		},
+		check: func(t *testing.T, got *unstructured.Unstructured) {
+			if replicas, _, _ := unstructured.NestedInt64(got.Object, "spec", "replicas"); replicas != 2 {
+				t.Errorf("adopted the replicas of the cluster's autoscaler: %d", replicas)
+			}
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tc.upstream.DeepCopy())
+			c := &Controller{fromClient: client}
+			c.SetTransforms(Transforms{})
+
+			want, err := c.downstreamCopy(deploymentsGVR, tc.upstream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash, err := specHash(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			annotations := want.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[specHashAnnotation] = hash
+			want.SetAnnotations(annotations)
+
+			if err := c.adopt(context.Background(), deploymentsGVR, tc.upstream, want, tc.downstream(want)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := client.Resource(deploymentsGVR).Namespace("default").Get(context.Background(), "web", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, got)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// upsyncedFromLabel marks the EndpointSlices a syncer wrote back to kcp,
+	// by the ID of its cluster. Only those are updated and deleted again.
+	upsyncedFromLabel = "experimental.kcp.dev/upsynced-from"
+
+	serviceNameLabel = "kubernetes.io/service-name"
+)
+
+var (
+	servicesGVR       = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	endpointSlicesGVR = schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1beta1", Resource: "endpointslices"}
+)
+
+// syncsServices reports whether the syncer syncs Services, and so writes
+// their EndpointSlices back to kcp.
+func (c *Controller) syncsServices() bool {
+	for _, gvr := range c.gvrs {
+		if gvr == servicesGVR {
+			return true
+		}
+	}
+	return false
+}
+
+// upsyncEndpointSlice writes a downstream EndpointSlice of a synced Service
+// back to kcp, labeled with the syncer's cluster, so that the Service's
+// endpoints on every cluster can be aggregated there. Copies whose
+// downstream EndpointSlice or Service is gone are deleted.
+func (c *Controller) upsyncEndpointSlice(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+	downstreamNamespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	obj, exists, err := c.toEndpointSliceInformer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	var downstream *unstructured.Unstructured
+	namespace := ""
+	if exists {
+		if downstream, err = interfaceToUnstructured(obj); err != nil {
+			return err
+		}
+		// The Service it's for knows which upstream namespace it's from.
+		service := downstream.GetLabels()[serviceNameLabel]
+		svc, found, err := c.toDSIF.ForResource(servicesGVR).Informer().GetIndexer().GetByKey(downstreamNamespace + "/" + service)
+		if err != nil {
+			return err
+		}
+		if m, ok := svc.(metav1.Object); found && ok {
+			namespace = c.upstreamNamespaceOf(m)
+		}
+		if _, synced, err := c.fromDSIF.ForResource(servicesGVR).Informer().GetIndexer().GetByKey(namespace + "/" + service); err != nil {
+			return err
+		} else if !synced {
+			downstream = nil
+		}
+	}
+	if namespace == "" {
+		if namespace, err = c.upsyncedNamespace(ctx, downstreamNamespace, name); err != nil || namespace == "" {
+			return err
+		}
+	}
+	client := c.fromClient.Resource(endpointSlicesGVR).Namespace(namespace)
+
+	current, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+	if found && current.GetLabels()[upsyncedFromLabel] != c.clusterID {
+		// Not ours to touch.
+		return nil
+	}
+
+	if downstream == nil {
+		if !found {
+			return nil
+		}
+		logger.V(2).Info("Deleting endpointslice written back for a service that is gone")
+		if err := client.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	want, err := c.upstreamCopy(endpointSlicesGVR, downstream)
+	if err != nil {
+		return err
+	}
+	want.SetNamespace(namespace)
+	l := want.GetLabels()
+	l[upsyncedFromLabel] = c.clusterID
+	want.SetLabels(l)
+
+	if !found {
+		logger.V(4).Info("Writing endpointslice back")
+		if _, err := client.Create(ctx, want, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if equality.Semantic.DeepEqual(syncedContent(want), syncedContent(current)) {
+			return nil
+		}
+		updated := want.DeepCopy()
+		updated.SetResourceVersion(current.GetResourceVersion())
+		_, err := client.Update(ctx, updated, metav1.UpdateOptions{})
+		if k8serrors.IsConflict(err) {
+			if latest, getErr := client.Get(ctx, name, metav1.GetOptions{}); getErr == nil {
+				current = latest
+			}
+		}
+		return err
+	})
+}
+
+// upsyncedNamespace returns the upstream namespace of the EndpointSlices
+// written back from the given downstream namespace, or of the one with the
+// given name if the objects of several upstream namespaces are synced to it,
+// "" if there's none.
+func (c *Controller) upsyncedNamespace(ctx context.Context, downstreamNamespace, name string) (string, error) {
+	if namespace, ok := c.namespaces.upstream(downstreamNamespace); ok {
+		return namespace, nil
+	}
+	slices, err := c.fromClient.Resource(endpointSlicesGVR).List(ctx, metav1.ListOptions{
+		LabelSelector: upsyncedFromLabel + "=" + c.clusterID,
+		FieldSelector: "metadata.name=" + name,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, slice := range slices.Items {
+		if c.namespaces.downstream(slice.GetNamespace()) == downstreamNamespace {
+			return slice.GetNamespace(), nil
+		}
+	}
+	return "", nil
+}
+
+// enqueueUpsyncedEndpointSlices enqueues every EndpointSlice the syncer wrote
+// back to kcp, so that those whose Service is gone from its cluster are
+// deleted.
+func (c *Controller) enqueueUpsyncedEndpointSlices(ctx context.Context) error {
+	slices, err := c.fromClient.Resource(endpointSlicesGVR).List(ctx, metav1.ListOptions{
+		LabelSelector: upsyncedFromLabel + "=" + c.clusterID,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range slices.Items {
+		c.queue.Add(holder{gvr: endpointSlicesGVR, key: c.downstreamKey(keyFor(&slices.Items[i])), dir: toUpstream})
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package syncer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// finalizerPrefix, followed by the ID of its cluster, is the finalizer
+	// a syncer holds the objects it syncs with, so that deleting one from
+	// kcp only completes once it's been removed from the cluster.
+	finalizerPrefix = "experimental.kcp.dev/syncer-"
+
+	// ForceDeleteAnnotation, set to "true" on an object being deleted,
+	// lets its deletion complete without waiting for it to be removed from
+	// its clusters, e.g. when one won't be reachable again.
+	ForceDeleteAnnotation = "experimental.kcp.dev/force-delete"
+)
+
+// Finalizer returns the finalizer of the syncer of the cluster.
+func Finalizer(clusterID string) string {
+	return finalizerPrefix + clusterID
+}
+
+// HasFinalizer reports whether obj is held by the syncer of the cluster.
+func HasFinalizer(obj metav1.Object, clusterID string) bool {
+	return contains(obj.GetFinalizers(), Finalizer(clusterID))
+}
+
+// Finalizing returns the IDs of the clusters whose syncers hold obj.
+func Finalizing(obj metav1.Object) []string {
+	var clusterIDs []string
+	for _, f := range obj.GetFinalizers() {
+		if strings.HasPrefix(f, finalizerPrefix) {
+			clusterIDs = append(clusterIDs, strings.TrimPrefix(f, finalizerPrefix))
+		}
+	}
+	return clusterIDs
+}
+
+// ForceDeleted reports whether obj's deletion was forced, with
+// ForceDeleteAnnotation.
+func ForceDeleted(obj metav1.Object) bool {
+	return obj.GetDeletionTimestamp() != nil && obj.GetAnnotations()[ForceDeleteAnnotation] == "true"
+}
+
+// RemoveFinalizers removes the finalizer of the syncer of the cluster from
+// obj, or those of every syncer if clusterID is empty, and reports whether
+// there were any.
+func RemoveFinalizers(obj metav1.Object, clusterID string) bool {
+	var kept []string
+	for _, f := range obj.GetFinalizers() {
+		if f == Finalizer(clusterID) || clusterID == "" && strings.HasPrefix(f, finalizerPrefix) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if len(kept) == len(obj.GetFinalizers()) {
+		return false
+	}
+	obj.SetFinalizers(kept)
+	return true
+}
+
+// addFinalizer adds the syncer's finalizer to an upstream object before it's
+// synced, and reports whether it had to, in which case the object is synced
+// once the update is seen.
+func (c *Controller) addFinalizer(ctx context.Context, gvr schema.GroupVersionResource, upstream *unstructured.Unstructured) (bool, error) {
+	if HasFinalizer(upstream, c.clusterID) {
+		return false, nil
+	}
+	updated := upstream.DeepCopy()
+	updated.SetFinalizers(append(updated.GetFinalizers(), Finalizer(c.clusterID)))
+	_, err := c.fromClient.Resource(gvr).Namespace(upstream.GetNamespace()).Update(ctx, updated, metav1.UpdateOptions{})
+	return true, err
+}
+
+// finalize deletes the downstream copy of an upstream object being deleted,
+// and removes the syncer's finalizer from the upstream object for its
+// deletion to complete once the copy is gone, or right away if its deletion
+// was forced. The copy's deletion is seen by the downstream informer, which
+// enqueues the object again.
+func (c *Controller) finalize(ctx context.Context, gvr schema.GroupVersionResource, upstream *unstructured.Unstructured) error {
+	if !HasFinalizer(upstream, c.clusterID) {
+		return nil
+	}
+	namespace, name := upstream.GetNamespace(), upstream.GetName()
+	current, err := c.getClient(gvr, c.namespaces.downstream(namespace)).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case err == nil && c.collides(current, namespace):
+		// What's there is another object's copy; this one was never synced.
+	case err == nil:
+		if err := c.delete(ctx, gvr, c.namespaces.downstream(namespace), name); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		if !ForceDeleted(upstream) {
+			logging.FromContext(ctx).V(2).Info("Waiting for the object to be deleted downstream")
+			return nil
+		}
+	case !k8serrors.IsNotFound(err):
+		return err
+	}
+
+	if err := c.syncDependencies(ctx, gvr, namespace, name, nil, nil); err != nil {
+		return err
+	}
+	// The namespace may not be needed anymore.
+	c.enqueueNamespace(c.namespaces.downstream(namespace))
+
+	updated := upstream.DeepCopy()
+	RemoveFinalizers(updated, c.clusterID)
+	if _, err := c.fromClient.Resource(gvr).Namespace(namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	logging.FromContext(ctx).Info("Deleted object downstream")
+	return nil
+}
+
+// ReleaseFinalizers removes the finalizer of the syncer of the cluster from
+// every object of the given resources labeled for it in the kcp logical
+// cluster reached with from, for when the cluster is gone and its syncer
+// won't remove them anymore.
+func ReleaseFinalizers(ctx context.Context, from *rest.Config, clusterID string, resources []string) error {
+	gvrs, err := getAllGVRs(from, resources...)
+	if err != nil {
+		return err
+	}
+	client := dynamic.NewForConfigOrDie(from)
+	for _, gvr := range gvrs {
+		list, err := client.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: clusterLabel + "=" + clusterID})
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !RemoveFinalizers(obj, clusterID) {
+				continue
+			}
+			if _, err := client.Resource(gvr).Namespace(obj.GetNamespace()).Update(ctx, obj, metav1.UpdateOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package syncer
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRemoveFinalizers(t *testing.T) {
+	for _, tc := range []struct {
+		clusterID string
+		want      []string
+		removed   bool
+	}{
+		{"us-east", []string{"other", Finalizer("us-west")}, true},
+		{"eu-west", []string{"other", Finalizer("us-east"), Finalizer("us-west")}, false},
+		{"", []string{"other"}, true},
+	} {
+		obj := &metav1.ObjectMeta{Finalizers: []string{"other", Finalizer("us-east"), Finalizer("us-west")}}
+		if removed := RemoveFinalizers(obj, tc.clusterID); removed != tc.removed {
+			t.Errorf("removing the finalizer of %q reported %v, want %v", tc.clusterID, removed, tc.removed)
+		}
+		if !reflect.DeepEqual(obj.Finalizers, tc.want) {
+			t.Errorf("removing the finalizer of %q left %v, want %v", tc.clusterID, obj.Finalizers, tc.want)
+		}
+	}
+
+	obj := &metav1.ObjectMeta{Finalizers: []string{"other", Finalizer("us-east")}}
+	if got := Finalizing(obj); !reflect.DeepEqual(got, []string{"us-east"}) {
+		t.Errorf("got %v finalizing, want [us-east]", got)
+	}
+}
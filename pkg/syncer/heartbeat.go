@@ -0,0 +1,93 @@
+package syncer
+
+import (
+	"context"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// LeaseNamespace is the namespace of kcp the syncers renew the Lease of
+	// their Cluster in, named after it, as their heartbeat.
+	LeaseNamespace = "kcp-syncers"
+
+	// LeaseDuration is how long a heartbeat lasts: the syncers that didn't
+	// renew their Lease for that long are considered gone.
+	LeaseDuration = 40 * time.Second
+
+	// renewInterval is how often the syncers renew their Lease.
+	renewInterval = 10 * time.Second
+)
+
+// heartbeat renews the Lease of the syncer's Cluster every renewInterval,
+// until the syncer is stopped.
+func (c *Controller) heartbeat() {
+	client, err := kubernetes.NewForConfig(c.fromConfig)
+	if err != nil {
+		c.logger.Error(err, "Error creating heartbeat client")
+		return
+	}
+	wait.Until(func() {
+		if err := renewLease(context.TODO(), client, c.clusterID, time.Now()); err != nil {
+			c.logger.Error(err, "Error renewing heartbeat lease")
+		}
+	}, renewInterval, c.stopCh)
+}
+
+// renewLease renews the Lease of the Cluster, as of now, creating it, and
+// its namespace, as needed.
+func renewLease(ctx context.Context, client kubernetes.Interface, clusterID string, now time.Time) error {
+	leases := client.CoordinationV1().Leases(LeaseNamespace)
+	renewTime := metav1.NewMicroTime(now)
+	duration := int32(LeaseDuration / time.Second)
+	lease, err := leases.Get(ctx, clusterID, metav1.GetOptions{})
+	if err == nil {
+		lease.Spec.HolderIdentity = &clusterID
+		lease.Spec.LeaseDurationSeconds = &duration
+		lease.Spec.RenewTime = &renewTime
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		return err
+	}
+	if !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	lease = &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: LeaseNamespace, Name: clusterID},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &clusterID,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &renewTime,
+			RenewTime:            &renewTime,
+		},
+	}
+	_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+	if k8serrors.IsNotFound(err) {
+		// The first syncer of the logical cluster creates the namespace.
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: LeaseNamespace}}
+		if _, err := client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+	}
+	return err
+}
+
+// LeaseExpiry returns when the heartbeat of the syncer that renewed lease
+// runs out, which is in the past for a Lease never renewed.
+func LeaseExpiry(lease *coordinationv1.Lease) time.Time {
+	if lease.Spec.RenewTime == nil {
+		return time.Time{}
+	}
+	duration := LeaseDuration
+	if lease.Spec.LeaseDurationSeconds != nil {
+		duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	return lease.Spec.RenewTime.Add(duration)
+}
@@ -0,0 +1,32 @@
+package syncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRenewLease(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, now := range []time.Time{start, start.Add(renewInterval)} {
+		if err := renewLease(ctx, client, "us-east", now); err != nil {
+			t.Fatal(err)
+		}
+		lease, err := client.CoordinationV1().Leases(LeaseNamespace).Get(ctx, "us-east", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := LeaseExpiry(lease); !got.Equal(now.Add(LeaseDuration)) {
+			t.Errorf("heartbeat of %s runs out at %s", now, got)
+		}
+		if !lease.Spec.AcquireTime.Time.Equal(start) {
+			t.Errorf("lease acquired at %s", lease.Spec.AcquireTime)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// InstallNamespace is the namespace the syncer is installed in on
+	// physical clusters using the pull model.
+	InstallNamespace = "syncer-system"
+
+	syncerSAName   = "syncer"
+	syncerPrefix   = "syncer"
+	kubeconfigKey  = "kubeconfig"
+	tokenKey       = "bootstrap-token"
+	kubeconfigPath = "/kcp"
+)
+
+func syncerWorkloadName(logicalCluster string) string {
+	return syncerPrefix + "-from-" + logicalCluster
+}
+
+func syncerSecretName(logicalCluster string) string {
+	return "kubeconfig-for-" + logicalCluster
+}
+
+// InstallOptions describe a syncer to run inside a physical cluster, dialing
+// out to kcp rather than being pushed to.
+type InstallOptions struct {
+	// Image is the syncer image to run.
+	Image string
+	// Kubeconfig reaches the logical cluster to sync from, e.g. with a token.
+	Kubeconfig string
+	// ClusterID is the name of the Cluster the syncer syncs to.
+	ClusterID string
+	// LogicalCluster is the name of the logical cluster to sync from.
+	LogicalCluster string
+	// Resources are the resources to sync.
+	Resources []string
+	// DriftMode is how the syncer handles changes made to the synced objects
+	// in the physical cluster.
+	DriftMode DriftMode
+	// BootstrapToken, if set, makes the syncer register its Cluster with kcp
+	// when it starts, presenting this token.
+	BootstrapToken string
+	// NamespaceMapping, NamespaceStrategy and ServiceAccountMapping are
+	// those of the syncer's Transforms, and Transformers the names of its
+	// custom Transformers.
+	NamespaceMapping      Mapping
+	NamespaceStrategy     NamespaceStrategy
+	ServiceAccountMapping Mapping
+	Transformers          []string
+	// Upsync are the resources whose downstream objects the syncer
+	// mirrors to kcp.
+	Upsync []string
+	// RBACVerbs are the verbs the Roles the syncer syncs along with the
+	// ServiceAccounts of the synced objects may grant, none by default.
+	RBACVerbs []string
+	// ServiceImports makes the syncer program the ServiceImports of kcp
+	// as those of the Multi-Cluster Services API, whose CRD the cluster
+	// must have.
+	ServiceImports bool
+	// NetworkPolicies makes the syncer sync the NetworkPolicies selecting
+	// the pods of the synced objects, for clusters that enforce them.
+	NetworkPolicies bool
+	// Tunnel makes the syncer open a tunnel to kcp, for kcp to reach the
+	// logs of the pods of the cluster, and exec into them, when it can't
+	// reach the cluster itself.
+	Tunnel bool
+	// RateLimit bounds the requests the syncer makes to the cluster.
+	RateLimit RateLimit
+}
+
+// Objects returns the objects to create in the physical cluster to run the
+// syncer described by o: its Namespace, ServiceAccount and RBAC, the Secret
+// holding its kubeconfig and its Deployment.
+func Objects(o InstallOptions) []runtime.Object {
+	labels := map[string]string{"app": syncerWorkloadName(o.LogicalCluster)}
+
+	var resources []string
+	for _, r := range o.Resources {
+		// RBAC names resources without their group.
+		r = schema.ParseGroupResource(r).Resource
+		resources = append(resources, r, r+"/status")
+	}
+
+	rules := []rbacv1.PolicyRule{{
+		APIGroups: []string{"*"},
+		Resources: resources,
+		Verbs:     []string{"*"},
+	}, {
+		// The namespaces the synced resources are in, and what their pods need.
+		APIGroups: []string{""},
+		Resources: []string{"namespaces", "configmaps", "secrets", "serviceaccounts"},
+		Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
+	}}
+	for _, r := range o.Resources {
+		if schema.ParseGroupResource(r).Resource == "services" {
+			// The endpoints of synced Services are written back to kcp.
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{"discovery.k8s.io"},
+				Resources: []string{"endpointslices"},
+				Verbs:     []string{"get", "list", "watch"},
+			})
+		}
+	}
+	if len(o.Upsync) > 0 {
+		// The objects mirrored to kcp.
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"*"},
+			Resources: o.Upsync,
+			Verbs:     []string{"get", "list", "watch"},
+		})
+	}
+
+	if len(o.RBACVerbs) > 0 {
+		// The Roles and RoleBindings of the ServiceAccounts of synced
+		// objects, which it can only create if it may grant and bind what
+		// they do. What they grant is bounded by RBACVerbs.
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{rbacv1.GroupName},
+			Resources: []string{"roles", "rolebindings"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "delete", "escalate", "bind"},
+		})
+	}
+
+	if o.NetworkPolicies {
+		// The NetworkPolicies selecting the pods of synced objects.
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"networking.k8s.io"},
+			Resources: []string{"networkpolicies"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
+		})
+	}
+
+	if o.ServiceImports {
+		// The ServiceImports programmed, and the EndpointSlices of their
+		// endpoints on every cluster.
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"multicluster.x-k8s.io"},
+			Resources: []string{"serviceimports", "serviceimports/status"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
+		}, rbacv1.PolicyRule{
+			APIGroups: []string{"discovery.k8s.io"},
+			Resources: []string{"endpointslices"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
+		})
+	}
+
+	if o.Tunnel {
+		// What kcp reaches through the tunnel.
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"pods/log", "pods/exec", "pods/attach", "pods/portforward"},
+			Verbs:     []string{"get", "create"},
+		})
+	}
+
+	args := []string{
+		"-cluster", o.ClusterID,
+		"-kubeconfig", kubeconfigPath + "/" + kubeconfigKey,
+	}
+	if o.DriftMode != "" {
+		args = append(args, "-drift_mode", string(o.DriftMode))
+	}
+	if len(o.NamespaceMapping) > 0 {
+		args = append(args, "-namespace_mapping", o.NamespaceMapping.String())
+	}
+	if o.NamespaceStrategy != nil {
+		args = append(args, "-namespace_strategy", o.NamespaceStrategy.String(), "-workspace", o.LogicalCluster)
+	}
+	if len(o.ServiceAccountMapping) > 0 {
+		args = append(args, "-service_account_mapping", o.ServiceAccountMapping.String())
+	}
+	if len(o.Transformers) > 0 {
+		args = append(args, "-transformers", strings.Join(o.Transformers, ","))
+	}
+	if len(o.Upsync) > 0 {
+		args = append(args, "-upsync", strings.Join(o.Upsync, ","))
+	}
+	if len(o.RBACVerbs) > 0 {
+		args = append(args, "-rbac_verbs", strings.Join(o.RBACVerbs, ","))
+	}
+	if o.NetworkPolicies {
+		args = append(args, "-network_policies")
+	}
+	if o.ServiceImports {
+		args = append(args, "-service_imports")
+	}
+	if o.Tunnel {
+		args = append(args, "-tunnel")
+	}
+	if o.RateLimit.QPS > 0 {
+		args = append(args, "-qps", fmt.Sprintf("%g", o.RateLimit.QPS))
+	}
+	if o.RateLimit.Burst > 0 {
+		args = append(args, "-burst", fmt.Sprint(o.RateLimit.Burst))
+	}
+	if o.RateLimit.Adaptive {
+		args = append(args, "-adaptive_rate_limit")
+	}
+	data := map[string]string{kubeconfigKey: o.Kubeconfig}
+	items := []corev1.KeyToPath{{Key: kubeconfigKey, Path: kubeconfigKey}}
+	if o.BootstrapToken != "" {
+		args = append(args, "-bootstrap_token_file", kubeconfigPath+"/"+tokenKey)
+		data[tokenKey] = o.BootstrapToken
+		items = append(items, corev1.KeyToPath{Key: tokenKey, Path: tokenKey})
+	}
+	args = append(args, o.Resources...)
+
+	var one int32 = 1
+	return []runtime.Object{
+		&corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: InstallNamespace},
+		},
+		&corev1.ServiceAccount{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: InstallNamespace,
+				Name:      syncerSAName,
+			},
+		},
+		// The syncer applies the synced resources in every namespace, creating them as needed.
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: syncerWorkloadName(o.LogicalCluster)},
+			Rules:      rules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: syncerWorkloadName(o.LogicalCluster)},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     syncerWorkloadName(o.LogicalCluster),
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:      rbacv1.ServiceAccountKind,
+				Namespace: InstallNamespace,
+				Name:      syncerSAName,
+			}},
+		},
+		// The kubeconfig to reach kcp, and the bootstrap token if any, mounted into the syncer's Pod.
+		&corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: InstallNamespace,
+				Name:      syncerSecretName(o.LogicalCluster),
+			},
+			StringData: data,
+		},
+		&appsv1.Deployment{
+			TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: InstallNamespace,
+				Name:      syncerWorkloadName(o.LogicalCluster),
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &one,
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Strategy: appsv1.DeploymentStrategy{
+					Type: appsv1.RecreateDeploymentStrategyType,
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: labels,
+						Annotations: map[string]string{
+							// Restart the syncer when its kubeconfig changes.
+							"kubeconfig/version": fmt.Sprintf("%x", sha256.Sum256([]byte(o.Kubeconfig))),
+						},
+					},
+					Spec: corev1.PodSpec{
+						ServiceAccountName: syncerSAName,
+						Containers: []corev1.Container{{
+							Name:  "syncer",
+							Image: o.Image,
+							Args:  args,
+							VolumeMounts: []corev1.VolumeMount{{
+								Name:      "kubeconfig",
+								MountPath: kubeconfigPath,
+								ReadOnly:  true,
+							}},
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						}},
+						Volumes: []corev1.Volume{{
+							Name: "kubeconfig",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: syncerSecretName(o.LogicalCluster),
+									Items:      items,
+								},
+							},
+						}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// WriteManifests writes the objects returned by Objects as a multi-document
+// YAML stream, to be applied to clusters kcp can't reach.
+func WriteManifests(w io.Writer, o InstallOptions) error {
+	for _, obj := range Objects(o) {
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Install creates the objects returned by Objects in the physical cluster,
+// or updates them if they exist.
+func Install(ctx context.Context, client kubernetes.Interface, o InstallOptions) error {
+	for _, obj := range Objects(o) {
+		var err error
+		switch obj := obj.(type) {
+		case *corev1.Namespace:
+			_, err = client.CoreV1().Namespaces().Create(ctx, obj, metav1.CreateOptions{})
+		case *corev1.ServiceAccount:
+			_, err = client.CoreV1().ServiceAccounts(obj.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+		case *rbacv1.ClusterRole:
+			if _, err = client.RbacV1().ClusterRoles().Create(ctx, obj, metav1.CreateOptions{}); k8serrors.IsAlreadyExists(err) {
+				_, err = client.RbacV1().ClusterRoles().Update(ctx, obj, metav1.UpdateOptions{})
+			}
+		case *rbacv1.ClusterRoleBinding:
+			_, err = client.RbacV1().ClusterRoleBindings().Create(ctx, obj, metav1.CreateOptions{})
+		case *corev1.Secret:
+			if _, err = client.CoreV1().Secrets(obj.Namespace).Create(ctx, obj, metav1.CreateOptions{}); k8serrors.IsAlreadyExists(err) {
+				_, err = client.CoreV1().Secrets(obj.Namespace).Update(ctx, obj, metav1.UpdateOptions{})
+			}
+		case *appsv1.Deployment:
+			if _, err = client.AppsV1().Deployments(obj.Namespace).Create(ctx, obj, metav1.CreateOptions{}); k8serrors.IsAlreadyExists(err) {
+				_, err = client.AppsV1().Deployments(obj.Namespace).Update(ctx, obj, metav1.UpdateOptions{})
+			}
+		}
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Uninstall deletes the syncer installed by Install from the physical
+// cluster. The Namespace and ServiceAccount are kept, as they're shared with
+// the syncers of other logical clusters.
+func Uninstall(ctx context.Context, client kubernetes.Interface, logicalCluster string) {
+	logger := logging.FromContext(ctx)
+	if err := client.AppsV1().Deployments(InstallNamespace).Delete(ctx, syncerWorkloadName(logicalCluster), metav1.DeleteOptions{}); err != nil {
+		logger.Error(err, "Error deleting syncer")
+	}
+
+	if err := client.CoreV1().Secrets(InstallNamespace).Delete(ctx, syncerSecretName(logicalCluster), metav1.DeleteOptions{}); err != nil {
+		logger.Error(err, "Error deleting syncer kubeconfig")
+	}
+
+	if err := client.RbacV1().ClusterRoleBindings().Delete(ctx, syncerWorkloadName(logicalCluster), metav1.DeleteOptions{}); err != nil {
+		logger.Error(err, "Error deleting syncer cluster role binding")
+	}
+	if err := client.RbacV1().ClusterRoles().Delete(ctx, syncerWorkloadName(logicalCluster), metav1.DeleteOptions{}); err != nil {
+		logger.Error(err, "Error deleting syncer cluster role")
+	}
+}
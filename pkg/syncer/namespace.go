@@ -0,0 +1,180 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// namespaceOwnerLabel marks the namespaces a syncer created downstream, by
+// the ID of its cluster. Only those are updated and deleted again.
+const namespaceOwnerLabel = "experimental.kcp.dev/namespace-synced-for"
+
+var namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// skippedNamespaceAnnotations aren't copied from upstream namespaces.
+var skippedNamespaceAnnotations = map[string]bool{
+	"kubectl.kubernetes.io/last-applied-configuration": true,
+}
+
+func (c *Controller) enqueueNamespaceFor(obj interface{}) {
+	if ns, ok := obj.(*unstructured.Unstructured); ok {
+		c.enqueueNamespace(c.namespaces.downstream(ns.GetName()))
+	}
+}
+
+// enqueueNamespace enqueues the downstream namespace with the given name.
+func (c *Controller) enqueueNamespace(name string) {
+	if name != "" {
+		c.queue.Add(holder{gvr: namespacesGVR, key: name, dir: toDownstream})
+	}
+}
+
+// syncNamespace makes sure a namespace that synced objects are in exists
+// downstream, with the labels and annotations of the upstream namespace, and
+// deletes it once no synced object is left in it. Namespaces the syncer
+// didn't create are left alone, beyond creating them. The namespace is named
+// downstream; a namespace the objects of several upstream namespaces are
+// synced to gets the labels and annotations of none.
+func (c *Controller) syncNamespace(ctx context.Context, name string) error {
+	logger := logging.FromContext(ctx)
+	client := c.toClient.Resource(namespacesGVR)
+
+	current, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	owned := exists && current.GetLabels()[namespaceOwnerLabel] == c.clusterID
+
+	inUse, err := c.namespaceInUse(name)
+	if err != nil {
+		return err
+	}
+	if !inUse {
+		if !owned || current.GetDeletionTimestamp() != nil {
+			return nil
+		}
+		logger.Info("Deleting namespace that no synced object is in anymore")
+		if err := client.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	if exists && !owned {
+		return nil
+	}
+
+	var obj interface{}
+	found := false
+	if upstreamName, ok := c.namespaces.upstream(name); ok {
+		if obj, found, err = c.namespaceInformer.GetIndexer().GetByKey(upstreamName); err != nil {
+			return err
+		}
+	}
+	want := &unstructured.Unstructured{}
+	want.SetAPIVersion("v1")
+	want.SetKind("Namespace")
+	want.SetName(name)
+	l := map[string]string{}
+	annotations := map[string]string{}
+	if found {
+		upstream, err := interfaceToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		for k, v := range upstream.GetLabels() {
+			l[k] = v
+		}
+		for k, v := range upstream.GetAnnotations() {
+			if !skippedNamespaceAnnotations[k] {
+				annotations[k] = v
+			}
+		}
+	}
+	l[namespaceOwnerLabel] = c.clusterID
+	want.SetLabels(l)
+	want.SetAnnotations(annotations)
+
+	if !exists {
+		logger.Info("Creating namespace")
+		if _, err := client.Create(ctx, want, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+
+	// The physical cluster may add labels of its own, e.g. kubernetes.io/metadata.name.
+	if matches(toInterfaceMap(want.GetLabels()), toInterfaceMap(current.GetLabels())) &&
+		equality.Semantic.DeepEqual(want.GetAnnotations(), current.GetAnnotations()) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	merged := updated.GetLabels()
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	for k, v := range l {
+		merged[k] = v
+	}
+	updated.SetLabels(merged)
+	updated.SetAnnotations(annotations)
+	logger.V(2).Info("Updating namespace labels and annotations")
+	_, err = client.Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// namespaceInUse reports whether any synced object is in the downstream
+// namespace, or in an upstream namespace whose objects are synced to it.
+// Downstream objects may belong to the syncers of other logical clusters
+// syncing to the same cluster.
+func (c *Controller) namespaceInUse(name string) (bool, error) {
+	upstreamName, single := c.namespaces.upstream(name)
+	if single {
+		if synced, err := c.syncedIn(upstreamName); synced || err != nil {
+			return synced, err
+		}
+	}
+	for _, gvr := range c.gvrs {
+		if !single {
+			upstream, err := c.fromDSIF.ForResource(gvr).Lister().List(labels.Everything())
+			if err != nil {
+				return false, err
+			}
+			for _, obj := range upstream {
+				if m, ok := obj.(metav1.Object); ok && c.namespaces.downstream(m.GetNamespace()) == name {
+					return true, nil
+				}
+			}
+		}
+		downstream, err := c.toDSIF.ForResource(gvr).Lister().ByNamespace(name).List(labels.Everything())
+		if err != nil {
+			return false, err
+		}
+		if len(downstream) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// enqueueOwnedNamespaces enqueues every namespace the syncer created
+// downstream, so that those no synced object is in anymore are deleted.
+func (c *Controller) enqueueOwnedNamespaces(ctx context.Context) error {
+	namespaces, err := c.toClient.Resource(namespacesGVR).List(ctx, metav1.ListOptions{
+		LabelSelector: namespaceOwnerLabel + "=" + c.clusterID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces.Items {
+		c.enqueueNamespace(ns.GetName())
+	}
+	return nil
+}
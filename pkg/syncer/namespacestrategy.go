@@ -0,0 +1,208 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+)
+
+// upstreamNamespaceAnnotation records the upstream namespace of the
+// downstream objects synced to another namespace, to tell which upstream
+// object each is the copy of, and whether they collide.
+const upstreamNamespaceAnnotation = "experimental.kcp.dev/upstream-namespace"
+
+// NamespaceStrategy decides which namespace of its cluster a syncer syncs
+// the objects of each namespace of kcp to. The namespaces a Mapping maps
+// aren't left to it.
+type NamespaceStrategy interface {
+	// Downstream returns the namespace of the cluster the objects of a
+	// namespace of kcp are synced to.
+	Downstream(namespace string) string
+	// Upstream returns the namespace of kcp whose objects are synced to a
+	// namespace of the cluster, or false if none or several are.
+	Upstream(namespace string) (string, bool)
+	// String returns the strategy as ParseNamespaceStrategy parses it.
+	String() string
+}
+
+// ParseNamespaceStrategy parses a NamespaceStrategy: identity, the default,
+// keeps the namespaces; prefixed prefixes them with kcp-<workspace>-, for
+// the objects of several workspaces not to collide on the same cluster; and
+// consolidated:<namespace> syncs all the objects to that namespace.
+func ParseNamespaceStrategy(s, workspace string) (NamespaceStrategy, error) {
+	switch {
+	case s == "" || s == "identity":
+		return identityNamespaces{}, nil
+	case s == "prefixed":
+		if workspace == "" {
+			return nil, fmt.Errorf("the prefixed namespace strategy needs a workspace")
+		}
+		return prefixedNamespaces("kcp-" + workspace + "-"), nil
+	case strings.HasPrefix(s, "consolidated:"):
+		namespace := strings.TrimPrefix(s, "consolidated:")
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+		return consolidatedNamespace(namespace), nil
+	}
+	return nil, fmt.Errorf("unknown namespace strategy %q, must be identity, prefixed or consolidated:<namespace>", s)
+}
+
+type identityNamespaces struct{}
+
+func (identityNamespaces) Downstream(namespace string) string { return namespace }
+
+func (identityNamespaces) Upstream(namespace string) (string, bool) { return namespace, true }
+
+func (identityNamespaces) String() string { return "identity" }
+
+// prefixedNamespaces prefixes the namespaces with the prefix, including the
+// workspace's name. Namespaces whose prefixed names are too long can't be
+// created downstream.
+type prefixedNamespaces string
+
+func (p prefixedNamespaces) Downstream(namespace string) string { return string(p) + namespace }
+
+func (p prefixedNamespaces) Upstream(namespace string) (string, bool) {
+	if !strings.HasPrefix(namespace, string(p)) {
+		return "", false
+	}
+	return strings.TrimPrefix(namespace, string(p)), true
+}
+
+func (prefixedNamespaces) String() string { return "prefixed" }
+
+// consolidatedNamespace syncs the objects of every namespace to the same
+// one, where those with the same name collide.
+type consolidatedNamespace string
+
+func (n consolidatedNamespace) Downstream(string) string { return string(n) }
+
+func (consolidatedNamespace) Upstream(string) (string, bool) { return "", false }
+
+func (n consolidatedNamespace) String() string { return "consolidated:" + string(n) }
+
+// namespaceMapping moves namespaced objects to the namespaces they're mapped
+// to, or else to those the strategy decides, recording where they come from.
+type namespaceMapping struct {
+	mapping  Mapping
+	strategy NamespaceStrategy
+}
+
+func (m namespaceMapping) downstream(namespace string) string {
+	if to, found := m.mapping[namespace]; found {
+		return to
+	}
+	if m.strategy == nil {
+		return namespace
+	}
+	return m.strategy.Downstream(namespace)
+}
+
+// upstream returns the namespace of kcp whose objects are synced to a
+// namespace of the cluster, or false if none or several are.
+func (m namespaceMapping) upstream(namespace string) (string, bool) {
+	for from, to := range m.mapping {
+		if to == namespace {
+			return from, true
+		}
+	}
+	if m.strategy == nil {
+		return namespace, true
+	}
+	from, ok := m.strategy.Upstream(namespace)
+	if _, mapped := m.mapping[from]; ok && mapped {
+		// Its objects are synced elsewhere.
+		return "", false
+	}
+	return from, ok
+}
+
+func (m namespaceMapping) ToDownstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	ns := obj.GetNamespace()
+	if ns == "" {
+		return nil
+	}
+	if to := m.downstream(ns); to != ns {
+		obj.SetNamespace(to)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[upstreamNamespaceAnnotation] = ns
+		obj.SetAnnotations(annotations)
+	}
+	return nil
+}
+
+func (m namespaceMapping) ToUpstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	ns := obj.GetNamespace()
+	if ns == "" {
+		return nil
+	}
+	annotations := obj.GetAnnotations()
+	if from, found := annotations[upstreamNamespaceAnnotation]; found {
+		obj.SetNamespace(from)
+		delete(annotations, upstreamNamespaceAnnotation)
+		obj.SetAnnotations(annotations)
+	} else if from, ok := m.upstream(ns); ok {
+		obj.SetNamespace(from)
+	}
+	return nil
+}
+
+// upstreamNamespaceOf returns the upstream namespace of a downstream object,
+// as recorded when it was synced to another namespace.
+func (c *Controller) upstreamNamespaceOf(obj metav1.Object) string {
+	if from, found := obj.GetAnnotations()[upstreamNamespaceAnnotation]; found {
+		return from
+	}
+	if from, ok := c.namespaces.upstream(obj.GetNamespace()); ok {
+		return from
+	}
+	return obj.GetNamespace()
+}
+
+// upstreamKeyOf returns the key of the upstream object of a downstream one,
+// which may be a tombstone.
+func (c *Controller) upstreamKeyOf(obj interface{}) (string, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	name := m.GetName()
+	if upstream, found := m.GetAnnotations()[upstreamNameAnnotation]; found {
+		name = upstream
+	}
+	if m.GetNamespace() == "" {
+		return name, nil
+	}
+	return c.upstreamNamespaceOf(m) + "/" + name, nil
+}
+
+// collides reports whether a downstream object is the copy of an object of
+// another upstream namespace than the given one, synced to the same
+// namespace under the same name.
+func (c *Controller) collides(downstream metav1.Object, upstreamNamespace string) bool {
+	return downstream.GetNamespace() != "" && c.upstreamNamespaceOf(downstream) != upstreamNamespace
+}
+
+// reportCollision records that an upstream object isn't synced because
+// another one already is under its name downstream.
+func (c *Controller) reportCollision(ctx context.Context, upstream *unstructured.Unstructured, downstream metav1.Object) {
+	logging.FromContext(ctx).Info("Not syncing object colliding with that of another namespace", "namespace", downstream.GetNamespace(), "upstreamNamespace", c.upstreamNamespaceOf(downstream))
+	c.recorder.Eventf(upstream, corev1.EventTypeWarning, "NamespaceCollision", "Not synced to cluster %s, where namespace %s has the object of the same name from namespace %s",
+		c.clusterID, downstream.GetNamespace(), c.upstreamNamespaceOf(downstream))
+}
@@ -0,0 +1,86 @@
+package syncer
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseNamespaceStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		strategy, downstream string
+		single               bool
+	}{
+		{"", "web", true},
+		{"identity", "web", true},
+		{"prefixed", "kcp-admin-web", true},
+		{"consolidated:team-a", "team-a", false},
+	} {
+		s, err := ParseNamespaceStrategy(tc.strategy, "admin")
+		if err != nil {
+			t.Errorf("%q: %v", tc.strategy, err)
+			continue
+		}
+		if got := s.Downstream("web"); got != tc.downstream {
+			t.Errorf("%q: web is synced to %s", tc.strategy, got)
+		}
+		if got, single := s.Upstream(tc.downstream); single != tc.single || (single && got != "web") {
+			t.Errorf("%q: %s is synced from %s, %t", tc.strategy, tc.downstream, got, single)
+		}
+	}
+
+	for _, s := range []string{"other", "consolidated:", "consolidated:Team_A"} {
+		if _, err := ParseNamespaceStrategy(s, "admin"); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+	if _, err := ParseNamespaceStrategy("prefixed", ""); err == nil {
+		t.Error("parsed prefixed without a workspace")
+	}
+}
+
+func TestConsolidatedNamespace(t *testing.T) {
+	c := &Controller{}
+	c.SetTransforms(Transforms{
+		Namespaces:        Mapping{"ops": "team-a-ops"},
+		NamespaceStrategy: consolidatedNamespace("team-a"),
+	})
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	configMap := func(namespace string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetNamespace(namespace)
+		u.SetName("config")
+		return u
+	}
+
+	web, err := c.downstreamCopy(gvr, configMap("web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if web.GetNamespace() != "team-a" {
+		t.Errorf("synced to namespace %s", web.GetNamespace())
+	}
+	if ops := c.namespaces.downstream("ops"); ops != "team-a-ops" {
+		t.Errorf("ops is synced to %s", ops)
+	}
+	if key, _ := c.upstreamKeyOf(web); key != "web/config" {
+		t.Errorf("got key %s back", key)
+	}
+	if !c.collides(web, "default") || c.collides(web, "web") {
+		t.Error("collisions aren't told apart")
+	}
+
+	back, err := c.upstreamCopy(gvr, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.GetNamespace() != "web" {
+		t.Errorf("written back to namespace %s", back.GetNamespace())
+	}
+	if _, found := back.GetAnnotations()[upstreamNamespaceAnnotation]; found {
+		t.Error("kept the upstream namespace annotation")
+	}
+}
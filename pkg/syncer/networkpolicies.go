@@ -0,0 +1,100 @@
+package syncer
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+var networkPoliciesGVR = networkingv1.SchemeGroupVersion.WithResource("networkpolicies")
+
+// SetNetworkPolicies makes the syncer sync, along with each synced object,
+// the NetworkPolicies of its namespace that select its pods, for them to be
+// isolated on the cluster as they are meant to be in kcp. It's only meant
+// for the clusters whose network plugin enforces NetworkPolicies, and must
+// be called before Start.
+func (c *Controller) SetNetworkPolicies(enabled bool) {
+	if !enabled {
+		return
+	}
+	c.networkPolicies = true
+	if !c.isDependency(networkPoliciesGVR) {
+		return
+	}
+	c.watchDependency(networkPoliciesGVR)
+	// Unlike the other dependencies, NetworkPolicies select what they apply
+	// to, so the synced objects of their namespace are synced again for
+	// them to pick those created or changed.
+	c.fromUnlabeledDSIF.ForResource(networkPoliciesGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueSyncedIn(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueSyncedIn(obj) },
+	})
+}
+
+// enqueueSyncedIn enqueues the synced objects of the namespace of the given
+// object.
+func (c *Controller) enqueueSyncedIn(obj interface{}) {
+	m, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	for _, gvr := range c.gvrs {
+		objs, err := c.fromDSIF.ForResource(gvr).Lister().ByNamespace(m.GetNamespace()).List(labels.Everything())
+		if err != nil {
+			c.logger.Error(err, "Error listing synced objects", "gvr", gvr.String())
+			continue
+		}
+		for _, o := range objs {
+			c.enqueue(gvr, o, toDownstream)
+		}
+	}
+}
+
+// podLabelsOf returns the labels of the pods of a synced object: its own
+// for a Pod, and those of its pod template otherwise.
+func podLabelsOf(u *unstructured.Unstructured) map[string]string {
+	if u.GetKind() == "Pod" {
+		return u.GetLabels()
+	}
+	for _, path := range podSpecPaths {
+		// The template is where its spec is.
+		metadata := append(append([]string{}, path[:len(path)-1]...), "metadata", "labels")
+		if l, found, err := unstructured.NestedStringMap(u.Object, metadata...); err == nil && found {
+			return l
+		}
+	}
+	return nil
+}
+
+// networkPoliciesFor returns the names of the NetworkPolicies of the
+// upstream namespace that select pods of the given labels.
+func (c *Controller) networkPoliciesFor(namespace string, podLabels map[string]string) (sets.String, error) {
+	names := sets.NewString()
+	objs, err := c.fromUnlabeledDSIF.ForResource(networkPoliciesGVR).Lister().ByNamespace(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		u, err := interfaceToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		policy := &networkingv1.NetworkPolicy{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, policy); err != nil {
+			return nil, err
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil {
+			// Selects nothing in kcp either.
+			continue
+		}
+		if selector.Matches(labels.Set(podLabels)) {
+			names.Insert(policy.Name)
+		}
+	}
+	return names, nil
+}
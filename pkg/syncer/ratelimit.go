@@ -0,0 +1,166 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// minAdaptiveFraction is how far down, as a fraction of its QPS, an
+	// adaptive rate limit goes.
+	minAdaptiveFraction = 0.1
+	// recoverAfter is how many requests in a row must succeed for an
+	// adaptive rate limit to speed back up.
+	recoverAfter = 20
+)
+
+// RateLimit bounds the requests the syncer makes to its cluster, for those
+// small clusters that can't take many. The zero RateLimit leaves the
+// client-go defaults.
+type RateLimit struct {
+	// QPS is the sustained number of requests per second.
+	QPS float32
+	// Burst is how many requests may be made at once above QPS.
+	Burst int
+	// Adaptive makes the syncer slow down, down to a tenth of QPS, while
+	// the cluster answers with 429 Too Many Requests, and speed back up to
+	// QPS as its requests succeed again.
+	Adaptive bool
+}
+
+// String returns the rate limit as flags of the syncer would set it.
+func (l RateLimit) String() string {
+	return fmt.Sprintf("qps=%g,burst=%d,adaptive=%t", l.QPS, l.Burst, l.Adaptive)
+}
+
+// Config returns a copy of cfg whose clients are rate limited by l.
+func (l RateLimit) Config(cfg *rest.Config) *rest.Config {
+	cfg = rest.CopyConfig(cfg)
+	if l.QPS > 0 {
+		cfg.QPS = l.QPS
+	}
+	if l.Burst > 0 {
+		cfg.Burst = l.Burst
+	}
+	if !l.Adaptive {
+		return cfg
+	}
+	qps, burst := cfg.QPS, cfg.Burst
+	if qps <= 0 {
+		qps = rest.DefaultQPS
+	}
+	if burst <= 0 {
+		burst = rest.DefaultBurst
+	}
+	limiter := newAdaptiveRateLimiter(qps, burst)
+	// Every client of the cluster shares the limiter.
+	cfg.RateLimiter = limiter
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &throttleObserver{delegate: rt, limiter: limiter}
+	})
+	return cfg
+}
+
+// adaptiveRateLimiter is a token bucket whose rate is halved whenever the
+// cluster throttles requests, and raised by half again after recoverAfter
+// successful ones, up to its QPS.
+type adaptiveRateLimiter struct {
+	qps   float32
+	burst int
+
+	lock      sync.Mutex
+	current   float32
+	successes int
+	limiter   flowcontrol.RateLimiter
+}
+
+func newAdaptiveRateLimiter(qps float32, burst int) *adaptiveRateLimiter {
+	return &adaptiveRateLimiter{
+		qps:     qps,
+		burst:   burst,
+		current: qps,
+		limiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+	}
+}
+
+func (l *adaptiveRateLimiter) get() flowcontrol.RateLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.limiter
+}
+
+func (l *adaptiveRateLimiter) TryAccept() bool { return l.get().TryAccept() }
+func (l *adaptiveRateLimiter) Accept()         { l.get().Accept() }
+func (l *adaptiveRateLimiter) Stop()           { l.get().Stop() }
+func (l *adaptiveRateLimiter) QPS() float32    { return l.get().QPS() }
+
+func (l *adaptiveRateLimiter) Wait(ctx context.Context) error { return l.get().Wait(ctx) }
+
+// throttled slows the limiter down.
+func (l *adaptiveRateLimiter) throttled() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.successes = 0
+	qps := l.current / 2
+	if min := l.qps * minAdaptiveFraction; qps < min {
+		qps = min
+	}
+	l.set(qps)
+}
+
+// succeeded speeds the limiter back up, once enough requests succeeded.
+func (l *adaptiveRateLimiter) succeeded() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.current >= l.qps {
+		return
+	}
+	if l.successes++; l.successes < recoverAfter {
+		return
+	}
+	l.successes = 0
+	qps := l.current * 1.5
+	if qps > l.qps {
+		qps = l.qps
+	}
+	l.set(qps)
+}
+
+// set replaces the token bucket with one of the given rate, and a burst
+// scaled down alike. The lock must be held.
+func (l *adaptiveRateLimiter) set(qps float32) {
+	if qps == l.current {
+		return
+	}
+	burst := int(float32(l.burst) * qps / l.qps)
+	if burst < 1 {
+		burst = 1
+	}
+	l.current = qps
+	l.limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+}
+
+// throttleObserver tells an adaptiveRateLimiter how the cluster answers.
+type throttleObserver struct {
+	delegate http.RoundTripper
+	limiter  *adaptiveRateLimiter
+}
+
+func (t *throttleObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.delegate.RoundTrip(req)
+	switch {
+	case err != nil:
+	case resp.StatusCode == http.StatusTooManyRequests:
+		t.limiter.throttled()
+	case resp.StatusCode < http.StatusInternalServerError:
+		t.limiter.succeeded()
+	}
+	return resp, err
+}
+
+func (t *throttleObserver) WrappedRoundTripper() http.RoundTripper { return t.delegate }
@@ -0,0 +1,74 @@
+package syncer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestAdaptiveRateLimiter(t *testing.T) {
+	l := newAdaptiveRateLimiter(10, 20)
+	for i := 0; i < 10; i++ {
+		l.throttled()
+	}
+	if got := l.QPS(); got != 1 {
+		t.Errorf("slowed down to %g", got)
+	}
+	for i := 0; i < 1000; i++ {
+		l.succeeded()
+	}
+	if got := l.QPS(); got != 10 {
+		t.Errorf("sped back up to %g", got)
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	throttle := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if throttle {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := RateLimit{QPS: 100, Burst: 50, Adaptive: true}.Config(&rest.Config{Host: server.URL})
+	if cfg.QPS != 100 || cfg.Burst != 50 {
+		t.Errorf("got qps %g, burst %d", cfg.QPS, cfg.Burst)
+	}
+	limiter, ok := cfg.RateLimiter.(*adaptiveRateLimiter)
+	if !ok {
+		t.Fatalf("got rate limiter %T", cfg.RateLimiter)
+	}
+	rt, err := rest.TransportFor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	get()
+	if got := limiter.QPS(); got != 50 {
+		t.Errorf("a 429 slowed down to %g", got)
+	}
+	throttle = false
+	for i := 0; i < recoverAfter; i++ {
+		get()
+	}
+	if got := limiter.QPS(); got != 75 {
+		t.Errorf("successes sped back up to %g", got)
+	}
+
+	if cfg := (RateLimit{}).Config(&rest.Config{Host: server.URL}); cfg.RateLimiter != nil || cfg.QPS != 0 {
+		t.Error("the zero rate limit changed the config")
+	}
+}
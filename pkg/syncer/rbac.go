@@ -0,0 +1,176 @@
+package syncer
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	rolesGVR        = rbacv1.SchemeGroupVersion.WithResource("roles")
+	roleBindingsGVR = rbacv1.SchemeGroupVersion.WithResource("rolebindings")
+)
+
+// SetRBACVerbs makes the syncer sync, along with the ServiceAccount the pods
+// of a synced object run as, the RoleBindings of its namespace binding a
+// Role to it and those Roles, granting only the given verbs downstream.
+// Without verbs, the default, no RBAC is synced, nor is it for namespaces
+// synced to the same one downstream as others. It must be called before
+// Start.
+func (c *Controller) SetRBACVerbs(verbs []string) {
+	if len(verbs) == 0 {
+		return
+	}
+	c.rbacVerbs = sets.NewString(verbs...)
+	for _, gvr := range []schema.GroupVersionResource{rolesGVR, roleBindingsGVR} {
+		if c.isDependency(gvr) {
+			c.watchDependency(gvr)
+		}
+	}
+}
+
+func isRBAC(gvr schema.GroupVersionResource) bool {
+	return gvr == rolesGVR || gvr == roleBindingsGVR
+}
+
+// serviceAccountOf returns the name of the ServiceAccount the pods of a
+// pod spec run as.
+func serviceAccountOf(spec *corev1.PodSpec) string {
+	if spec.ServiceAccountName != "" {
+		return spec.ServiceAccountName
+	}
+	if spec.DeprecatedServiceAccount != "" {
+		return spec.DeprecatedServiceAccount
+	}
+	return "default"
+}
+
+// rbacFor returns the names of the RoleBindings of the upstream namespace
+// that bind a Role to the named ServiceAccount, and of those Roles. The
+// ClusterRoles bound in the namespace aren't synced, as they'd grant what
+// they grant downstream rather than what they do in kcp.
+func (c *Controller) rbacFor(namespace, serviceAccount string) (bindings, roles sets.String, err error) {
+	bindings, roles = sets.NewString(), sets.NewString()
+	objs, err := c.fromUnlabeledDSIF.ForResource(roleBindingsGVR).Lister().ByNamespace(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, obj := range objs {
+		u, err := interfaceToUnstructured(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		binding := &rbacv1.RoleBinding{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, binding); err != nil {
+			return nil, nil, err
+		}
+		if binding.RoleRef.APIGroup != rbacv1.GroupName || binding.RoleRef.Kind != "Role" {
+			continue
+		}
+		for _, s := range serviceAccountSubjects(binding.Subjects, namespace) {
+			if s.Name == serviceAccount {
+				bindings.Insert(binding.Name)
+				roles.Insert(binding.RoleRef.Name)
+				break
+			}
+		}
+	}
+	return bindings, roles, nil
+}
+
+// syncsRBACOf reports whether the RBAC of an upstream namespace is synced:
+// not when the objects of other namespaces are synced to its downstream one
+// too, e.g. with the consolidated namespace strategy, where its Roles and
+// RoleBindings would collide with theirs, and grant their ServiceAccounts,
+// named alike, what its own are granted.
+func (c *Controller) syncsRBACOf(namespace string) bool {
+	from, ok := c.namespaces.upstream(c.namespaces.downstream(namespace))
+	return ok && from == namespace
+}
+
+// serviceAccountSubjects returns the subjects that are ServiceAccounts of
+// the given namespace.
+func serviceAccountSubjects(subjects []rbacv1.Subject, namespace string) []rbacv1.Subject {
+	var out []rbacv1.Subject
+	for _, s := range subjects {
+		if s.Kind == rbacv1.ServiceAccountKind && (s.Namespace == namespace || s.Namespace == "") {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// allowedRules returns the rules granting only the allowed verbs, leaving
+// out those that grant none of them. A rule granting every verb grants
+// those allowed.
+func allowedRules(rules []rbacv1.PolicyRule, allowed sets.String) []rbacv1.PolicyRule {
+	var out []rbacv1.PolicyRule
+	for _, r := range rules {
+		verbs := sets.NewString()
+		for _, v := range r.Verbs {
+			switch {
+			case allowed.Has(v) || allowed.Has(rbacv1.VerbAll):
+				verbs.Insert(v)
+			case v == rbacv1.VerbAll:
+				verbs.Insert(allowed.List()...)
+			}
+		}
+		if verbs.Len() == 0 {
+			continue
+		}
+		r.Verbs = verbs.List()
+		out = append(out, r)
+	}
+	return out
+}
+
+// restrictRBAC makes the downstream copy of a Role grant only the allowed
+// verbs, and that of a RoleBinding bind only the ServiceAccounts of its
+// upstream namespace, in its downstream one, under the names serviceAccounts
+// maps them to, as the pods run as there. Users and groups mean other
+// things, if anything, downstream.
+func restrictRBAC(gvr schema.GroupVersionResource, upstreamNamespace string, allowed sets.String, serviceAccounts Mapping, obj *unstructured.Unstructured) error {
+	switch gvr {
+	case rolesGVR:
+		role := &rbacv1.Role{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, role); err != nil {
+			return err
+		}
+		role.Rules = allowedRules(role.Rules, allowed)
+		return setFieldFrom(obj, role, "rules")
+	case roleBindingsGVR:
+		binding := &rbacv1.RoleBinding{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, binding); err != nil {
+			return err
+		}
+		subjects := serviceAccountSubjects(binding.Subjects, upstreamNamespace)
+		for i := range subjects {
+			subjects[i].Namespace = obj.GetNamespace()
+			if mapped, found := serviceAccounts[subjects[i].Name]; found {
+				subjects[i].Name = mapped
+			}
+		}
+		binding.Subjects = subjects
+		return setFieldFrom(obj, binding, "subjects")
+	}
+	return nil
+}
+
+// setFieldFrom sets the top-level field of obj to that of in, or removes it
+// if in leaves it out.
+func setFieldFrom(obj *unstructured.Unstructured, in interface{}, field string) error {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(in)
+	if err != nil {
+		return err
+	}
+	if v, ok := m[field]; ok {
+		obj.Object[field] = v
+	} else {
+		delete(obj.Object, field)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package syncer
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestAllowedRules(t *testing.T) {
+	rules := []rbacv1.PolicyRule{
+		{Resources: []string{"configmaps"}, Verbs: []string{"get", "delete", "list"}},
+		{Resources: []string{"secrets"}, Verbs: []string{"*"}},
+		{Resources: []string{"roles"}, Verbs: []string{"escalate", "bind"}},
+	}
+	for _, tc := range []struct {
+		allowed []string
+		want    []rbacv1.PolicyRule
+	}{{
+		allowed: []string{"get", "list", "watch"},
+		want: []rbacv1.PolicyRule{
+			{Resources: []string{"configmaps"}, Verbs: []string{"get", "list"}},
+			{Resources: []string{"secrets"}, Verbs: []string{"get", "list", "watch"}},
+		},
+	}, {
+		allowed: []string{"*"},
+		want: []rbacv1.PolicyRule{
+			{Resources: []string{"configmaps"}, Verbs: []string{"delete", "get", "list"}},
+			{Resources: []string{"secrets"}, Verbs: []string{"*"}},
+			{Resources: []string{"roles"}, Verbs: []string{"bind", "escalate"}},
+		},
+	}, {
+		allowed: []string{"create"},
+		want: []rbacv1.PolicyRule{
+			{Resources: []string{"secrets"}, Verbs: []string{"create"}},
+		},
+	}} {
+		if got := allowedRules(rules, sets.NewString(tc.allowed...)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("allowing %v got %v, want %v", tc.allowed, got, tc.want)
+		}
+	}
+}
+
+func TestRestrictRoleBinding(t *testing.T) {
+	binding := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "RoleBinding",
+		"metadata":   map[string]interface{}{"name": "reader", "namespace": "team-a"},
+		"roleRef":    map[string]interface{}{"apiGroup": rbacv1.GroupName, "kind": "Role", "name": "reader"},
+		"subjects": []interface{}{
+			map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "default"},
+			map[string]interface{}{"kind": "ServiceAccount", "name": "other", "namespace": "kube-system"},
+			map[string]interface{}{"kind": "User", "apiGroup": rbacv1.GroupName, "name": "alice"},
+		},
+	}}
+	// Synced from the default namespace of kcp to team-a.
+	if err := restrictRBAC(roleBindingsGVR, "default", sets.NewString("get"), nil, binding); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "team-a"},
+	}
+	if got := binding.Object["subjects"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got subjects %v, want %v", got, want)
+	}
+}
+
+func TestRestrictRoleBindingMapsServiceAccounts(t *testing.T) {
+	binding := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "RoleBinding",
+		"metadata":   map[string]interface{}{"name": "reader", "namespace": "default"},
+		"roleRef":    map[string]interface{}{"apiGroup": rbacv1.GroupName, "kind": "Role", "name": "reader"},
+		"subjects": []interface{}{
+			map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "default"},
+			map[string]interface{}{"kind": "ServiceAccount", "name": "batch", "namespace": "default"},
+		},
+	}}
+	// The pods of web run as the cluster's restricted ServiceAccount.
+	if err := restrictRBAC(roleBindingsGVR, "default", sets.NewString("get"), Mapping{"web": "restricted"}, binding); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"kind": "ServiceAccount", "name": "restricted", "namespace": "default"},
+		map[string]interface{}{"kind": "ServiceAccount", "name": "batch", "namespace": "default"},
+	}
+	if got := binding.Object["subjects"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got subjects %v, want %v", got, want)
+	}
+}
+
+func TestSyncsRBACOf(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		namespaces namespaceMapping
+		want       bool
+	}{
+		{desc: "identity", want: true},
+		{desc: "prefixed", namespaces: namespaceMapping{strategy: prefixedNamespaces("kcp-admin-")}, want: true},
+		{desc: "mapped", namespaces: namespaceMapping{mapping: Mapping{"default": "apps"}}, want: true},
+		{desc: "consolidated", namespaces: namespaceMapping{strategy: consolidatedNamespace("apps")}},
+		{desc: "consolidated but mapped", namespaces: namespaceMapping{mapping: Mapping{"default": "web"}, strategy: consolidatedNamespace("apps")}, want: true},
+		{desc: "mapped to another's", namespaces: namespaceMapping{mapping: Mapping{"team-a": "default"}}},
+	} {
+		c := &Controller{namespaces: tc.namespaces}
+		if got := c.syncsRBACOf("default"); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.desc, got, tc.want)
+		}
+	}
+}
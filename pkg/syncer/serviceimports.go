@@ -0,0 +1,362 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// importedForLabel marks the ServiceImports and EndpointSlices a syncer
+	// programmed downstream for the ServiceImports of kcp, by the ID of its
+	// cluster. Only those are updated and deleted again.
+	importedForLabel = "experimental.kcp.dev/imported-for"
+
+	// The labels the Multi-Cluster Services API finds the EndpointSlices of
+	// a ServiceImport by.
+	mcsServiceNameLabel   = "multicluster.kubernetes.io/service-name"
+	mcsSourceClusterLabel = "multicluster.kubernetes.io/source-cluster"
+
+	// importManagedBy is the endpointslice.kubernetes.io/managed-by value of
+	// the EndpointSlices of the ServiceImports, and aggregatedManagedBy that
+	// of the service controller's aggregated EndpointSlices in kcp.
+	importManagedBy     = "syncer.kcp.dev"
+	aggregatedManagedBy = "service-splitter.kcp.dev"
+	// endpointsClusterLabel records, on each aggregated EndpointSlice, the
+	// Cluster its endpoints are on.
+	endpointsClusterLabel = "experimental.kcp.dev/endpoints-cluster"
+)
+
+var (
+	// serviceImportsGVR are the ServiceImports of kcp, and mcsServiceImportsGVR
+	// those of the Multi-Cluster Services API they're programmed as downstream.
+	serviceImportsGVR    = schema.GroupVersionResource{Group: "cluster.example.dev", Version: "v1alpha1", Resource: "serviceimports"}
+	mcsServiceImportsGVR = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1", Resource: "serviceimports"}
+)
+
+// SetServiceImports makes the syncer program each ServiceImport of kcp, as
+// derived from a ServiceExport, on its cluster: as a ServiceImport of the
+// Multi-Cluster Services API, whose CRD must be installed there, along with
+// EndpointSlices holding the exported Service's endpoints on every cluster,
+// for the cluster's implementation of the API, such as its DNS, to serve
+// <service>.<namespace>.svc.clusterset.local. Imports are only programmed
+// in the namespaces that exist downstream. It must be called before Start.
+func (c *Controller) SetServiceImports(enabled bool) {
+	if !enabled {
+		return
+	}
+	c.importsServices = true
+	c.fromUnlabeledDSIF.ForResource(serviceImportsGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueue(serviceImportsGVR, obj, toDownstream) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueue(serviceImportsGVR, obj, toDownstream) },
+		DeleteFunc: func(obj interface{}) { c.enqueue(serviceImportsGVR, obj, toDownstream) },
+	})
+	c.fromUnlabeledDSIF.ForResource(endpointSlicesGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueImportOf(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueImportOf(obj) },
+		DeleteFunc: func(obj interface{}) { c.enqueueImportOf(obj) },
+	})
+	// What's programmed downstream is programmed again when it's changed
+	// or deleted there.
+	for _, gvr := range []schema.GroupVersionResource{mcsServiceImportsGVR, endpointSlicesGVR} {
+		c.toImportDSIF.ForResource(gvr).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(_, obj interface{}) { c.enqueueImportFor(obj) },
+			DeleteFunc: func(obj interface{}) { c.enqueueImportFor(obj) },
+		})
+	}
+	c.toUnlabeledDSIF.ForResource(namespacesGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueueImportsIn(obj) },
+	})
+}
+
+// enqueueImportOf enqueues the ServiceImport of the Service that an
+// EndpointSlice of kcp holds endpoints of, either aggregated from several
+// clusters or written back from one.
+func (c *Controller) enqueueImportOf(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	slice, ok := obj.(*unstructured.Unstructured)
+	if !ok || sourceClusterOf(slice.GetLabels()) == "" {
+		return
+	}
+	c.queue.Add(holder{gvr: serviceImportsGVR, key: slice.GetNamespace() + "/" + slice.GetLabels()[serviceNameLabel], dir: toDownstream})
+}
+
+// enqueueImportFor enqueues the upstream ServiceImport of a downstream
+// ServiceImport or EndpointSlice the syncer programmed.
+func (c *Controller) enqueueImportFor(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	name := u.GetName()
+	if u.GetKind() == "EndpointSlice" {
+		name = u.GetLabels()[mcsServiceNameLabel]
+	}
+	c.queue.Add(holder{gvr: serviceImportsGVR, key: c.upstreamNamespaceOf(u) + "/" + name, dir: toDownstream})
+}
+
+// enqueueImportsIn enqueues the upstream ServiceImports of the namespaces
+// whose objects are synced to a downstream namespace that was created.
+func (c *Controller) enqueueImportsIn(obj interface{}) {
+	ns, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	imports, err := c.fromUnlabeledDSIF.ForResource(serviceImportsGVR).Lister().List(labels.Everything())
+	if err != nil {
+		c.logger.Error(err, "Error listing service imports")
+		return
+	}
+	for _, imp := range imports {
+		if m, ok := imp.(metav1.Object); ok && c.namespaces.downstream(m.GetNamespace()) == ns.GetName() {
+			c.enqueue(serviceImportsGVR, imp, toDownstream)
+		}
+	}
+}
+
+// enqueueProgrammedImports enqueues every ServiceImport the syncer
+// programmed downstream, so that those deleted upstream while the syncer
+// wasn't running are deleted.
+func (c *Controller) enqueueProgrammedImports() error {
+	objs, err := c.toImportDSIF.ForResource(mcsServiceImportsGVR).Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		c.enqueueImportFor(obj)
+	}
+	return nil
+}
+
+// sourceClusterOf returns the cluster that an EndpointSlice of kcp, with
+// the given labels, holds the endpoints of, "" for the others.
+func sourceClusterOf(l map[string]string) string {
+	if l[discoveryv1beta1.LabelManagedBy] == aggregatedManagedBy {
+		return l[endpointsClusterLabel]
+	}
+	return l[upsyncedFromLabel]
+}
+
+// syncServiceImport programs the upstream ServiceImport with the given key
+// downstream, with its EndpointSlices, and deletes them once it's gone or
+// no longer has endpoints on a cluster. What's programmed for the
+// ServiceImport of the same name of another upstream namespace synced to
+// the same downstream one is left alone.
+func (c *Controller) syncServiceImport(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	downstreamNamespace := c.namespaces.downstream(namespace)
+
+	existing, exists, err := c.toImportDSIF.ForResource(mcsServiceImportsGVR).Informer().GetIndexer().GetByKey(downstreamNamespace + "/" + name)
+	if err != nil {
+		return err
+	}
+	if m, ok := existing.(metav1.Object); exists && ok && c.collides(m, namespace) {
+		return nil
+	}
+
+	obj, found, err := c.fromUnlabeledDSIF.ForResource(serviceImportsGVR).Informer().GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if found {
+		_, found, err = c.toUnlabeledDSIF.ForResource(namespacesGVR).Informer().GetIndexer().GetByKey(downstreamNamespace)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		if !exists {
+			return c.deleteImportedSlices(ctx, namespace, downstreamNamespace, name, nil)
+		}
+		logging.FromContext(ctx).V(2).Info("Deleting service import")
+		if err := c.delete(ctx, mcsServiceImportsGVR, downstreamNamespace, name); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		return c.deleteImportedSlices(ctx, namespace, downstreamNamespace, name, nil)
+	}
+
+	upstream, err := interfaceToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	imp, err := c.applyImported(ctx, mcsServiceImportsGVR, c.importCopy(upstream, downstreamNamespace))
+	if err != nil {
+		return err
+	}
+
+	slices, err := c.importedSlices(namespace, name, imp)
+	if err != nil {
+		return err
+	}
+	keep := sets.NewString()
+	for _, slice := range slices {
+		if _, err := c.applyImported(ctx, endpointSlicesGVR, slice); err != nil {
+			return err
+		}
+		keep.Insert(slice.GetName())
+	}
+	return c.deleteImportedSlices(ctx, namespace, downstreamNamespace, name, keep)
+}
+
+// importCopy returns the ServiceImport of the Multi-Cluster Services API to
+// program downstream for an upstream one, whose fields are the same.
+func (c *Controller) importCopy(upstream *unstructured.Unstructured, downstreamNamespace string) *unstructured.Unstructured {
+	want := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	want.SetAPIVersion(mcsServiceImportsGVR.GroupVersion().String())
+	want.SetKind("ServiceImport")
+	want.SetNamespace(downstreamNamespace)
+	want.SetName(upstream.GetName())
+	want.SetLabels(map[string]string{importedForLabel: c.clusterID})
+	want.SetAnnotations(map[string]string{upstreamNamespaceAnnotation: upstream.GetNamespace()})
+	if spec, found := upstream.Object["spec"]; found {
+		want.Object["spec"] = runtime.DeepCopyJSONValue(spec)
+	}
+	if status, found := upstream.Object["status"]; found {
+		want.Object["status"] = runtime.DeepCopyJSONValue(status)
+	}
+	return want
+}
+
+// importedSlices returns the EndpointSlices to program downstream for the
+// ServiceImport imp, one per EndpointSlice of kcp holding endpoints of the
+// exported Service, labeled with the cluster they're on. Nodes and pods
+// are only meaningful within their cluster.
+func (c *Controller) importedSlices(namespace, name string, imp *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	objs, err := c.fromUnlabeledDSIF.ForResource(endpointSlicesGVR).Lister().ByNamespace(namespace).List(labels.SelectorFromSet(labels.Set{serviceNameLabel: name}))
+	if err != nil {
+		return nil, err
+	}
+	var slices []*unstructured.Unstructured
+	for _, obj := range objs {
+		u, err := interfaceToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		cluster := sourceClusterOf(u.GetLabels())
+		if cluster == "" {
+			continue
+		}
+		source := &discoveryv1beta1.EndpointSlice{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, source); err != nil {
+			return nil, err
+		}
+
+		slice := &discoveryv1beta1.EndpointSlice{
+			TypeMeta: metav1.TypeMeta{APIVersion: discoveryv1beta1.SchemeGroupVersion.String(), Kind: "EndpointSlice"},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: imp.GetNamespace(),
+				Name:      "imported-" + source.Name,
+				Labels: map[string]string{
+					mcsServiceNameLabel:             name,
+					mcsSourceClusterLabel:           cluster,
+					discoveryv1beta1.LabelManagedBy: importManagedBy,
+					importedForLabel:                c.clusterID,
+				},
+				Annotations: map[string]string{upstreamNamespaceAnnotation: namespace},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: imp.GetAPIVersion(),
+					Kind:       imp.GetKind(),
+					Name:       imp.GetName(),
+					UID:        imp.GetUID(),
+				}},
+			},
+			AddressType: source.AddressType,
+			Ports:       source.Ports,
+		}
+		for _, ep := range source.Endpoints {
+			ep := *ep.DeepCopy()
+			delete(ep.Topology, corev1.LabelHostname)
+			ep.TargetRef = nil
+			slice.Endpoints = append(slice.Endpoints, ep)
+		}
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(slice)
+		if err != nil {
+			return nil, err
+		}
+		slices = append(slices, &unstructured.Unstructured{Object: m})
+	}
+	return slices, nil
+}
+
+// applyImported creates or updates an object programmed downstream for a
+// ServiceImport, and returns it. The IPs of a ServiceImport are left to
+// the cluster to allocate.
+func (c *Controller) applyImported(ctx context.Context, gvr schema.GroupVersionResource, want *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	client := c.getClient(gvr, want.GetNamespace())
+	existing, exists, err := c.toImportDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(keyFor(want))
+	if err != nil {
+		return nil, err
+	}
+
+	var current *unstructured.Unstructured
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Programming service import", "gvr", gvr.String(), logging.ObjectKey, keyFor(want))
+		if current, err = client.Create(ctx, want, metav1.CreateOptions{}); err != nil {
+			// Retried once the informer has seen one that already exists.
+			return nil, err
+		}
+	} else {
+		if current, err = interfaceToUnstructured(existing); err != nil {
+			return nil, err
+		}
+		if ips, found, _ := unstructured.NestedFieldCopy(current.Object, "spec", "ips"); found && gvr == mcsServiceImportsGVR {
+			if err := unstructured.SetNestedField(want.Object, ips, "spec", "ips"); err != nil {
+				return nil, err
+			}
+		}
+		if !matches(syncedContent(want), syncedContent(current)) {
+			updated := want.DeepCopy()
+			updated.SetResourceVersion(current.GetResourceVersion())
+			updated.SetOwnerReferences(current.GetOwnerReferences())
+			if current, err = client.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	status, found := want.Object["status"]
+	if !found || equality.Semantic.DeepEqual(current.Object["status"], status) {
+		return current, nil
+	}
+	updated := current.DeepCopy()
+	updated.Object["status"] = status
+	return client.UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+}
+
+// deleteImportedSlices deletes the EndpointSlices programmed downstream for
+// the named ServiceImport of the upstream namespace, except those in keep.
+func (c *Controller) deleteImportedSlices(ctx context.Context, namespace, downstreamNamespace, name string, keep sets.String) error {
+	objs, err := c.toImportDSIF.ForResource(endpointSlicesGVR).Lister().ByNamespace(downstreamNamespace).List(labels.SelectorFromSet(labels.Set{mcsServiceNameLabel: name}))
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		m, ok := obj.(metav1.Object)
+		if !ok || keep.Has(m.GetName()) || c.collides(m, namespace) {
+			continue
+		}
+		if err := c.delete(ctx, endpointSlicesGVR, downstreamNamespace, m.GetName()); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,46 +3,304 @@ package syncer
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/metrics"
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 )
 
+const (
+	controllerName = "syncer"
+
+	// clusterLabel selects the objects to sync to a cluster, by its ID.
+	clusterLabel = "cluster"
+)
+
+// DefaultResyncPeriod is how often every synced object is synced again, even
+// if it didn't change, to undo changes that were missed.
+const DefaultResyncPeriod = time.Hour
+
+// direction is which way an object is synced.
+type direction string
+
+const (
+	// toDownstream applies an upstream object's spec to its cluster.
+	toDownstream direction = "downstream"
+	// toUpstream writes a downstream object's status back to kcp.
+	toUpstream direction = "upstream"
+	// upsync mirrors a downstream object to kcp, see SetUpsyncedResources.
+	upsync direction = "upsync"
+)
+
+// NewController returns a syncer that applies the objects of the given
+// resources labeled for clusterID in the kcp logical cluster reached with
+// from to the physical cluster reached with to, and writes their status
+// back. Every object is synced again at least once per resync period, and
+// changes made to them downstream are handled as set by driftMode.
+func NewController(from, to *rest.Config, clusterID string, resourcesToSync []string, resync time.Duration, driftMode DriftMode) (*Controller, error) {
+	gvrs, err := getAllGVRs(from, resourcesToSync...)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := fmt.Sprintf("%s = %s", clusterLabel, clusterID)
+	tweak := func(o *metav1.ListOptions) { o.LabelSelector = selector }
+	fromClient := dynamic.NewForConfigOrDie(from)
+	toClient := dynamic.NewForConfigOrDie(to)
+
+	metrics.Register()
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubernetes.NewForConfigOrDie(from).CoreV1().Events("")})
+	c := &Controller{
+		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerName+"-"+clusterID),
+		logger:      logging.New(controllerName).WithValues(logging.ClusterKey, clusterID),
+		clusterID:   clusterID,
+		selector:    selector,
+		gvrs:        gvrs,
+		fromConfig:  from,
+		fromClient:  fromClient,
+		fromDSIF:    dynamicinformer.NewFilteredDynamicSharedInformerFactory(fromClient, resync, metav1.NamespaceAll, tweak),
+		toClient:    toClient,
+		toDSIF:      dynamicinformer.NewFilteredDynamicSharedInformerFactory(toClient, resync, metav1.NamespaceAll, tweak),
+		driftMode:   driftMode,
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerName, Host: clusterID}),
+		transforms:  Pipeline{internalFields{}, distributedNames{}},
+		stopCh:      make(chan struct{}),
+	}
+	c.fromUnlabeledDSIF = dynamicinformer.NewDynamicSharedInformerFactory(fromClient, resync)
+	c.namespaceInformer = c.fromUnlabeledDSIF.ForResource(namespacesGVR).Informer()
+	c.namespaceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueNamespaceFor(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.enqueueNamespaceFor(obj) },
+	})
+	c.toDependencyDSIF = dynamicinformer.NewFilteredDynamicSharedInformerFactory(toClient, resync, metav1.NamespaceAll, func(o *metav1.ListOptions) {
+		o.LabelSelector = dependencyOwnerLabel + "=" + clusterID
+	})
+	for _, gvr := range dependencyGVRs {
+		if c.isDependency(gvr) {
+			c.watchDependency(gvr)
+		}
+	}
+
+	c.toUnlabeledDSIF = dynamicinformer.NewDynamicSharedInformerFactory(toClient, resync)
+	c.fromUpsyncedDSIF = dynamicinformer.NewFilteredDynamicSharedInformerFactory(fromClient, resync, metav1.NamespaceAll, func(o *metav1.ListOptions) {
+		o.LabelSelector = upsyncedFromLabel + "=" + clusterID
+	})
+	c.toImportDSIF = dynamicinformer.NewFilteredDynamicSharedInformerFactory(toClient, resync, metav1.NamespaceAll, func(o *metav1.ListOptions) {
+		o.LabelSelector = importedForLabel + "=" + clusterID
+	})
+	if c.syncsServices() {
+		// EndpointSlices are written back for every synced Service.
+		c.toEndpointSliceInformer = c.toUnlabeledDSIF.ForResource(endpointSlicesGVR).Informer()
+		c.toEndpointSliceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueue(endpointSlicesGVR, obj, toUpstream) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueue(endpointSlicesGVR, obj, toUpstream) },
+			DeleteFunc: func(obj interface{}) { c.enqueue(endpointSlicesGVR, obj, toUpstream) },
+		})
+	}
+
+	for _, gvr := range gvrs {
+		gvr := gvr
+		c.fromDSIF.ForResource(gvr).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueue(gvr, obj, toDownstream) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueue(gvr, obj, toDownstream) },
+			DeleteFunc: func(obj interface{}) { c.enqueue(gvr, obj, toDownstream) },
+		})
+		c.toDSIF.ForResource(gvr).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) { c.enqueue(gvr, obj, toUpstream) },
+			UpdateFunc: func(_, obj interface{}) {
+				c.enqueue(gvr, obj, toUpstream)
+				// Check whether the object drifted from upstream.
+				c.enqueueUpstreamOf(gvr, obj)
+			},
+			// Objects deleted downstream behind kcp's back are created again.
+			DeleteFunc: func(obj interface{}) { c.enqueueUpstreamOf(gvr, obj) },
+		})
+		c.logger.V(2).Info("Set up informers", "gvr", gvr.String())
+	}
+	return c, nil
+}
+
+// Controller syncs objects from a kcp logical cluster to a physical cluster
+// and their status back, using a single queue for both directions.
 type Controller struct {
-	Queue workqueue.RateLimitingInterface
+	queue     workqueue.RateLimitingInterface
+	logger    logr.Logger
+	clusterID string
+	selector  string
+	gvrs      []schema.GroupVersionResource
 
 	// Upstream
-	FromDSIF dynamicinformer.DynamicSharedInformerFactory
+	fromConfig *rest.Config
+	fromClient dynamic.Interface
+	fromDSIF   dynamicinformer.DynamicSharedInformerFactory
 
 	// Downstream
-	ToClient dynamic.Interface
+	toClient dynamic.Interface
+	toDSIF   dynamicinformer.DynamicSharedInformerFactory
+
+	// Upstream namespaces and dependencies of synced objects, whatever
+	// their labels, and the dependencies created downstream.
+	fromUnlabeledDSIF dynamicinformer.DynamicSharedInformerFactory
+	namespaceInformer cache.SharedIndexInformer
+	toDependencyDSIF  dynamicinformer.DynamicSharedInformerFactory
+	// The verbs the Roles synced as dependencies may grant, if any, and
+	// whether the NetworkPolicies selecting synced pods are synced.
+	rbacVerbs       sets.String
+	networkPolicies bool
+
+	// Downstream EndpointSlices of synced Services and objects to mirror,
+	// whatever their labels, and their copies upstream.
+	toUnlabeledDSIF         dynamicinformer.DynamicSharedInformerFactory
+	toEndpointSliceInformer cache.SharedIndexInformer
+	fromUpsyncedDSIF        dynamicinformer.DynamicSharedInformerFactory
+	upsyncGVRs              []schema.GroupVersionResource
+
+	// The ServiceImports and their EndpointSlices programmed downstream,
+	// see SetServiceImports.
+	toImportDSIF    dynamicinformer.DynamicSharedInformerFactory
+	importsServices bool
+
+	// What's changed in the objects on their way, see SetTransforms.
+	namespaces      namespaceMapping
+	serviceAccounts Mapping
+	transforms      Pipeline
+
+	driftMode   DriftMode
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
 type holder struct {
 	gvr schema.GroupVersionResource
-	obj interface{}
+	key string
+	dir direction
+}
+
+func (c *Controller) enqueue(gvr schema.GroupVersionResource, obj interface{}, dir direction) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(holder{gvr: gvr, key: key, dir: dir})
 }
 
-func (c *Controller) AddToQueue(gvr schema.GroupVersionResource, obj interface{}) {
-	c.Queue.AddRateLimited(holder{gvr: gvr, obj: obj})
+// enqueueUpstreamOf enqueues the upstream object of a downstream one, to be
+// synced down again.
+func (c *Controller) enqueueUpstreamOf(gvr schema.GroupVersionResource, obj interface{}) {
+	key, err := c.upstreamKeyOf(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(holder{gvr: gvr, key: key, dir: toDownstream})
 }
 
-func (c *Controller) StartWorker() {
+// Start syncs the informers, deletes the downstream objects that were deleted
+// upstream while the syncer wasn't running, and runs numThreads workers, and
+// the heartbeat renewing the Lease of the syncer's Cluster, until Stop is
+// called.
+func (c *Controller) Start(numThreads int) {
+	defer c.queue.ShutDown()
+
+	c.fromDSIF.Start(c.stopCh)
+	c.toDSIF.Start(c.stopCh)
+	c.fromUnlabeledDSIF.Start(c.stopCh)
+	c.toDependencyDSIF.Start(c.stopCh)
+	c.toUnlabeledDSIF.Start(c.stopCh)
+	c.fromUpsyncedDSIF.Start(c.stopCh)
+	c.toImportDSIF.Start(c.stopCh)
+	c.fromDSIF.WaitForCacheSync(c.stopCh)
+	c.toDSIF.WaitForCacheSync(c.stopCh)
+	c.fromUnlabeledDSIF.WaitForCacheSync(c.stopCh)
+	c.toDependencyDSIF.WaitForCacheSync(c.stopCh)
+	c.toUnlabeledDSIF.WaitForCacheSync(c.stopCh)
+	c.fromUpsyncedDSIF.WaitForCacheSync(c.stopCh)
+	c.toImportDSIF.WaitForCacheSync(c.stopCh)
+
+	// Objects deleted upstream while the syncer wasn't running never produce
+	// a delete event; remove them downstream now that the caches have synced.
+	for _, gvr := range c.gvrs {
+		if err := c.DeleteOrphans(context.TODO(), gvr); err != nil {
+			c.logger.Error(err, "Error deleting orphaned objects", "gvr", gvr.String())
+		}
+	}
+	if err := c.enqueueOwnedNamespaces(context.TODO()); err != nil {
+		c.logger.Error(err, "Error listing synced namespaces")
+	}
+	if err := c.enqueueOwnedDependencies(); err != nil {
+		c.logger.Error(err, "Error listing synced dependencies")
+	}
+	if c.syncsServices() {
+		if err := c.enqueueUpsyncedEndpointSlices(context.TODO()); err != nil {
+			c.logger.Error(err, "Error listing written back endpointslices")
+		}
+	}
+	if c.importsServices {
+		if err := c.enqueueProgrammedImports(); err != nil {
+			c.logger.Error(err, "Error listing programmed service imports")
+		}
+	}
+	c.enqueueMirrors()
+
+	// kcp tells the syncer is alive by its heartbeat.
+	go c.heartbeat()
+	for i := 0; i < numThreads; i++ {
+		go wait.Until(c.startWorker, time.Second, c.stopCh)
+	}
+	c.logger.Info("Starting workers", "count", numThreads)
+	<-c.stopCh
+	c.logger.Info("Stopping workers")
+}
+
+// Stop stops the syncer's informers and workers. It's safe to call more than
+// once.
+func (c *Controller) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+		c.broadcaster.Shutdown()
+	})
+}
+
+func (c *Controller) startWorker() {
 	for c.processNextWorkItem() {
 	}
 }
 
 func (c *Controller) processNextWorkItem() bool {
 	// Wait until there is a new item in the working queue
-	i, quit := c.Queue.Get()
+	i, quit := c.queue.Get()
 	if quit {
 		return false
 	}
@@ -50,68 +308,114 @@ func (c *Controller) processNextWorkItem() bool {
 
 	// No matter what, tell the queue we're done with this key, to unblock
 	// other workers.
-	defer c.Queue.Done(i)
+	defer c.queue.Done(i)
 
-	err := c.process(h.gvr, h.obj)
-	c.handleErr(err, i)
+	logger := c.logger.WithValues("gvr", h.gvr.String(), logging.ObjectKey, h.key, "direction", string(h.dir))
+	start := time.Now()
+	ctx, span := tracing.Start(context.Background(), controllerName+" sync",
+		attribute.String("kcp.gvr", h.gvr.String()), attribute.String("kcp.key", h.key), attribute.String("kcp.direction", string(h.dir)))
+	err := c.process(logging.NewContext(ctx, logger), h)
+	tracing.End(span, err)
+	metrics.ObserveReconcile(controllerName, start, err)
+	if err != nil {
+		metrics.SyncErrors.WithLabelValues(controllerName, c.clusterID).Inc()
+	}
+	c.handleErr(logger, err, i)
 	return true
 }
 
-func (c *Controller) handleErr(err error, i interface{}) {
+func (c *Controller) handleErr(logger logr.Logger, err error, i interface{}) {
 	// Reconcile worked, nothing else to do for this workqueue item.
 	if err == nil {
-		c.Queue.Forget(i)
+		metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultSuccess).Inc()
+		c.queue.Forget(i)
 		return
 	}
 
 	// Re-enqueue up to 5 times.
-	num := c.Queue.NumRequeues(i)
+	num := c.queue.NumRequeues(i)
 	if num < 5 {
-		log.Printf("Error reconciling key %q, retrying... (#%d): %v", i, num, err)
-		c.Queue.AddRateLimited(i)
+		logger.Error(err, "Error syncing, retrying", "retries", num)
+		metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultRetry).Inc()
+		c.queue.AddRateLimited(i)
 		return
 	}
 
 	// Give up and report error elsewhere.
-	c.Queue.Forget(i)
+	metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultDropped).Inc()
+	c.queue.Forget(i)
 	utilruntime.HandleError(err)
-	log.Printf("Dropping key %q after failed retries: %v", i, err)
+	logger.Error(err, "Dropping object after failed retries")
 }
 
-func (c *Controller) process(gvr schema.GroupVersionResource, obj interface{}) error {
-	obj, exists, err := c.FromDSIF.ForResource(gvr).Informer().GetIndexer().Get(obj)
+func (c *Controller) process(ctx context.Context, h holder) error {
+	if h.dir == upsync {
+		return c.mirror(ctx, h.gvr, h.key)
+	}
+	if h.dir == toUpstream && h.gvr == endpointSlicesGVR {
+		return c.upsyncEndpointSlice(ctx, h.key)
+	}
+	if h.dir == toUpstream {
+		return c.syncStatus(ctx, h.gvr, h.key)
+	}
+	if h.gvr == namespacesGVR {
+		return c.syncNamespace(ctx, h.key)
+	}
+	if h.gvr == serviceImportsGVR {
+		return c.syncServiceImport(ctx, h.key)
+	}
+	if c.isDependency(h.gvr) {
+		return c.syncDependency(ctx, h.gvr, h.key)
+	}
+
+	obj, exists, err := c.fromDSIF.ForResource(h.gvr).Informer().GetIndexer().GetByKey(h.key)
 	if err != nil {
 		return err
 	}
+	if !exists {
+		logging.FromContext(ctx).V(2).Info("Object was deleted")
+		namespace, name, err := cache.SplitMetaNamespaceKey(h.key)
+		if err != nil {
+			return err
+		}
+		if err := c.deleteDownstream(ctx, h.gvr, namespace, name); err != nil {
+			return err
+		}
+		if err := c.syncDependencies(ctx, h.gvr, namespace, name, nil, nil); err != nil {
+			return err
+		}
+		// The namespace may not be needed anymore.
+		c.enqueueNamespace(c.namespaces.downstream(namespace))
+		return nil
+	}
 
 	unstrob, err := interfaceToUnstructured(obj)
 	if err != nil {
 		return err
 	}
-	namespace, name := unstrob.GetNamespace(), unstrob.GetName()
-
-	ctx := context.TODO()
-	if !exists {
-		log.Printf("Object with gvr=%q was deleted", gvr, obj)
-		c.delete(ctx, gvr, namespace, name)
-		return nil
+	if unstrob.GetDeletionTimestamp() != nil {
+		return c.finalize(ctx, h.gvr, unstrob)
 	}
-	if err := c.upsert(ctx, gvr, namespace, unstrob); err != nil {
+	if added, err := c.addFinalizer(ctx, h.gvr, unstrob); added || err != nil {
 		return err
 	}
-
+	logging.FromContext(ctx).V(4).Info("Syncing object")
+	ctx, span := tracing.StartFor(ctx, unstrob, "syncer upsert", attribute.String("kcp.cluster", c.clusterID))
+	err = c.upsert(ctx, h.gvr, unstrob)
+	tracing.End(span, err)
 	return err
 }
 
 // getClient gets a dynamic client for the GVR, scoped to namespace if the namespace is not "".
 func (c *Controller) getClient(gvr schema.GroupVersionResource, namespace string) dynamic.ResourceInterface {
-	nri := c.ToClient.Resource(gvr)
+	nri := c.toClient.Resource(gvr)
 	if namespace != "" {
 		return nri.Namespace(namespace)
 	}
 	return nri
 }
 
+// delete deletes a downstream object, namespace being the downstream one.
 func (c *Controller) delete(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) error {
 	// TODO: get UID of just-deleted object and pass it as a precondition on this delete.
 	// This would avoid races where an object is deleted and another object with the same name is created immediately after.
@@ -119,25 +423,309 @@ func (c *Controller) delete(ctx context.Context, gvr schema.GroupVersionResource
 	return c.getClient(gvr, namespace).Delete(ctx, name, metav1.DeleteOptions{})
 }
 
-func (c *Controller) upsert(ctx context.Context, gvr schema.GroupVersionResource, namespace string, unstrob *unstructured.Unstructured) error {
-	client := c.getClient(gvr, namespace)
+// deleteDownstream deletes the downstream copy of the upstream object with
+// the given namespace and name, unless what's there under its name is that
+// of an object of another namespace.
+func (c *Controller) deleteDownstream(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) error {
+	downstreamNamespace := c.namespaces.downstream(namespace)
+	indexer := c.toDSIF.ForResource(gvr).Informer().GetIndexer()
+	if distributed := strings.TrimSuffix(name, "--"+c.clusterID); gvr == secretsGVR && distributed != name {
+		// A distributed Secret is synced under the name of its root.
+		obj, exists, err := indexer.GetByKey(downstreamNamespace + "/" + distributed)
+		if err != nil {
+			return err
+		}
+		if current, ok := obj.(metav1.Object); exists && ok && current.GetAnnotations()[upstreamNameAnnotation] == name {
+			name = distributed
+		}
+	}
+	key := name
+	if namespace != "" {
+		key = downstreamNamespace + "/" + name
+	}
+	obj, exists, err := indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if current, ok := obj.(metav1.Object); ok && c.collides(current, namespace) {
+			return nil
+		}
+	}
+	if err := c.delete(ctx, gvr, downstreamNamespace, name); err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// upsert creates or updates the downstream copy of an upstream object. Its
+// status belongs to the physical cluster, so it's left untouched. Updates
+// that conflict, e.g. with the cluster's controllers writing status, are
+// retried against the latest downstream copy. A downstream copy that was
+// changed while the upstream object wasn't has drifted, and is handled as set
+// by the syncer's DriftMode.
+func (c *Controller) upsert(ctx context.Context, gvr schema.GroupVersionResource, unstrob *unstructured.Unstructured) error {
+	want, err := c.downstreamCopy(gvr, unstrob)
+	if err != nil {
+		return err
+	}
+	client := c.getClient(gvr, want.GetNamespace())
+	hash, err := specHash(want)
+	if err != nil {
+		return err
+	}
+	annotations := want.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[specHashAnnotation] = hash
+	want.SetAnnotations(annotations)
+
+	existing, exists, err := c.toDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(keyFor(want))
+	if err != nil {
+		return err
+	}
+	if current, ok := existing.(metav1.Object); exists && ok && c.collides(current, unstrob.GetNamespace()) {
+		c.reportCollision(ctx, unstrob, current)
+		return nil
+	}
+	if !exists && unstrob.GetNamespace() != "" {
+		if err := c.syncNamespace(ctx, want.GetNamespace()); err != nil {
+			return err
+		}
+	}
+	// Make sure the ConfigMaps, Secrets and ServiceAccounts it references
+	// are there before it is, as they're named downstream, and that the
+	// NetworkPolicies selecting its pods isolate them from the start.
+	spec, err := podSpecOf(want)
+	if err != nil {
+		return err
+	}
+	if err := c.syncDependencies(ctx, gvr, unstrob.GetNamespace(), unstrob.GetName(), spec, podLabelsOf(want)); err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := client.Create(ctx, want, metav1.CreateOptions{}); !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		// The informer hasn't seen it yet; update it instead.
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var current *unstructured.Unstructured
+		if u, ok := existing.(*unstructured.Unstructured); ok {
+			current = u
+		} else if current, err = client.Get(ctx, want.GetName(), metav1.GetOptions{}); err != nil {
+			return err
+		}
+		existing = nil // Get the latest copy if this attempt conflicts.
+		if c.collides(current, unstrob.GetNamespace()) {
+			c.reportCollision(ctx, unstrob, current)
+			return nil
+		}
+
+		if current.GetAnnotations()[specHashAnnotation] == hash {
+			if matches(syncedContent(want), syncedContent(current)) {
+				return nil
+			}
+			switch c.driftMode {
+			case DriftReport:
+				c.reportDrift(ctx, unstrob, "Changed on cluster %s, leaving it as it is", c.clusterID)
+				return nil
+			case DriftAdopt:
+				c.reportDrift(ctx, unstrob, "Changed on cluster %s, adopting the changes", c.clusterID)
+				return c.adopt(ctx, gvr, unstrob, want, current)
+			}
+			c.reportDrift(ctx, unstrob, "Changed on cluster %s, reverting the changes", c.clusterID)
+		}
 
-	// Attempt to update the object; if the object isn't found, create it.
-	unstrob.SetResourceVersion("")
-	unstrob.SetUID("")
-	if _, err := client.Update(ctx, unstrob, metav1.UpdateOptions{}); err != nil {
-		if k8serrors.IsNotFound(err) {
-			if _, err := client.Create(ctx, unstrob, metav1.CreateOptions{}); err != nil {
-				return err
+		updated := want.DeepCopy()
+		updated.SetResourceVersion(current.GetResourceVersion())
+		if autoscaled(want) {
+			// The cluster's HorizontalPodAutoscaler owns the replicas.
+			if replicas, found, _ := unstructured.NestedFieldCopy(current.Object, "spec", "replicas"); found {
+				if err := unstructured.SetNestedField(updated.Object, replicas, "spec", "replicas"); err != nil {
+					return err
+				}
 			}
-		} else {
+		}
+		_, err := client.Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func (c *Controller) reportDrift(ctx context.Context, upstream *unstructured.Unstructured, messageFmt string, args ...interface{}) {
+	logging.FromContext(ctx).Info("Downstream object drifted", "mode", string(c.driftMode))
+	metrics.SyncDrift.WithLabelValues(c.clusterID, string(c.driftMode)).Inc()
+	c.recorder.Eventf(upstream, corev1.EventTypeWarning, "Drifted", messageFmt, args...)
+}
+
+// adopt copies the content of a drifted downstream object to its upstream
+// copy, which is then synced back down as usual. Only what the syncer owns,
+// the fields, labels and annotations of want, the downstream copy it last
+// applied, is copied: what the cluster added on its own, such as defaulted
+// fields or the annotations of its controllers, stays downstream.
+func (c *Controller) adopt(ctx context.Context, gvr schema.GroupVersionResource, upstream, want, downstream *unstructured.Unstructured) error {
+	content := syncedContent(want)
+	fields, _ := ownedFields(content, syncedContent(downstream)).(map[string]interface{})
+	adopted := downstream.DeepCopy()
+	for k := range adopted.Object {
+		if k != "metadata" && k != "status" {
+			delete(adopted.Object, k)
+		}
+	}
+	for k, v := range fields {
+		if k != "metadata" {
+			adopted.Object[k] = v
+		}
+	}
+	labels, _, _ := unstructured.NestedStringMap(fields, "metadata", "labels")
+	annotations, _, _ := unstructured.NestedStringMap(fields, "metadata", "annotations")
+	adopted.SetLabels(labels)
+	adopted.SetAnnotations(annotations)
+	adopted, err := c.upstreamCopy(gvr, adopted)
+	if err != nil {
+		return err
+	}
+
+	updated := upstream.DeepCopy()
+	delete(content, "metadata")
+	adoptFields(updated.Object, content, adopted.Object)
+	updated.SetLabels(adoptedKeys(updated.GetLabels(), want.GetLabels(), adopted.GetLabels()))
+	updated.SetAnnotations(adoptedKeys(updated.GetAnnotations(), want.GetAnnotations(), adopted.GetAnnotations()))
+	_, err = c.fromClient.Resource(gvr).Namespace(upstream.GetNamespace()).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// syncStatus writes the status of the downstream object with the given key
+// back to its upstream copy, retrying conflicts against the latest upstream copy.
+func (c *Controller) syncStatus(ctx context.Context, gvr schema.GroupVersionResource, key string) error {
+	obj, exists, err := c.toDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(key)
+	if err != nil || !exists {
+		return err
+	}
+	downstream, err := interfaceToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	generation, syncedHash := downstream.GetGeneration(), downstream.GetAnnotations()[specHashAnnotation]
+	if downstream, err = c.upstreamCopy(gvr, downstream); err != nil {
+		return err
+	}
+	status, found := downstream.Object["status"]
+	if !found {
+		return nil
+	}
+
+	obj, exists, err = c.fromDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(keyFor(downstream))
+	if err != nil || !exists {
+		// Deleted upstream; the downstream copy is about to go as well.
+		return err
+	}
+	upstream, err := interfaceToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	client := c.fromClient.Resource(gvr).Namespace(upstream.GetNamespace())
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.translateObservedGeneration(gvr, status, generation, syncedHash, upstream); err != nil {
 			return err
 		}
+		if equality.Semantic.DeepEqual(upstream.Object["status"], status) {
+			return nil
+		}
+		updated := upstream.DeepCopy()
+		updated.Object["status"] = status
+		_, err := client.UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+		if k8serrors.IsConflict(err) {
+			if latest, getErr := client.Get(ctx, upstream.GetName(), metav1.GetOptions{}); getErr == nil {
+				upstream = latest
+			}
+		}
+		return err
+	})
+	if k8serrors.IsNotFound(err) {
+		// Deleted upstream meanwhile, or the resource has no status subresource.
+		return nil
+	}
+	if err == nil {
+		logging.FromContext(ctx).V(4).Info("Synced status upstream")
 	}
-	_, err := client.UpdateStatus(ctx, unstrob, metav1.UpdateOptions{})
 	return err
 }
 
+// downstreamCopy returns the object to apply downstream for an upstream
+// object, as transformed by the syncer's Transforms.
+func (c *Controller) downstreamCopy(gvr schema.GroupVersionResource, unstrob *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	want := unstrob.DeepCopy()
+	if err := c.transforms.ToDownstream(gvr, want); err != nil {
+		return nil, err
+	}
+	return want, nil
+}
+
+// upstreamCopy returns the object to write back upstream for a downstream
+// object, as transformed by the syncer's Transforms.
+func (c *Controller) upstreamCopy(gvr schema.GroupVersionResource, downstream *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	want := downstream.DeepCopy()
+	if err := c.transforms.ToUpstream(gvr, want); err != nil {
+		return nil, err
+	}
+	return want, nil
+}
+
+func keyFor(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() != "" {
+		return obj.GetNamespace() + "/" + obj.GetName()
+	}
+	return obj.GetName()
+}
+
+// downstreamKey returns the key downstream of the object with the given key
+// upstream, in the namespace it's mapped to.
+func (c *Controller) downstreamKey(key string) string {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return c.namespaces.downstream(key[:i]) + key[i:]
+	}
+	return key
+}
+
+// DeleteOrphans deletes the downstream objects of the given type labeled for
+// the syncer's cluster that don't exist upstream anymore, e.g. because they
+// were deleted while the syncer was offline and it never saw them go. The
+// upstream informer for gvr must have synced.
+func (c *Controller) DeleteOrphans(ctx context.Context, gvr schema.GroupVersionResource) error {
+	informer := c.fromDSIF.ForResource(gvr).Informer()
+	if !informer.HasSynced() {
+		return fmt.Errorf("informer for %q has not synced", gvr)
+	}
+
+	downstream, err := c.toClient.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: c.selector})
+	if err != nil {
+		return err
+	}
+	for _, obj := range downstream.Items {
+		key := keyFor(&obj)
+		upstreamKey, err := c.upstreamKeyOf(&obj)
+		if err != nil {
+			return err
+		}
+		_, exists, err := informer.GetIndexer().GetByKey(upstreamKey)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		c.logger.Info("Deleting object that was deleted upstream", "gvr", gvr.String(), logging.ObjectKey, key)
+		if err := c.delete(ctx, gvr, obj.GetNamespace(), obj.GetName()); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func interfaceToUnstructured(i interface{}) (*unstructured.Unstructured, error) {
 	b, err := json.Marshal(i)
 	if err != nil {
@@ -0,0 +1,273 @@
+package syncer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kcp-dev/kcp/pkg/tracing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Transformer changes the objects a syncer syncs on their way to its cluster
+// and back: ToDownstream the copy of an upstream object about to be applied
+// downstream, and ToUpstream the copy of a downstream object whose status,
+// or the whole of it for EndpointSlices, is about to be written back to kcp.
+// Both change the copy in place, and give the same result for the same
+// object, for the syncer to tell when the downstream object drifted. An
+// error fails the sync, which is retried.
+type Transformer interface {
+	ToDownstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error
+	ToUpstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error
+}
+
+var (
+	registryLock sync.Mutex
+	registry     = map[string]Transformer{}
+)
+
+// RegisterTransformer makes a Transformer available to NewPipeline under the
+// given name.
+func RegisterTransformer(name string, t Transformer) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, found := registry[name]; found {
+		panic(fmt.Sprintf("transformer %q registered twice", name))
+	}
+	registry[name] = t
+}
+
+// Pipeline runs Transformers in order.
+type Pipeline []Transformer
+
+// NewPipeline returns a Pipeline running the named registered Transformers,
+// in order.
+func NewPipeline(names []string) (Pipeline, error) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	var p Pipeline
+	for _, name := range names {
+		t, found := registry[name]
+		if !found {
+			registered := make([]string, 0, len(registry))
+			for name := range registry {
+				registered = append(registered, name)
+			}
+			sort.Strings(registered)
+			return nil, fmt.Errorf("unknown transformer %q, must be one of %s", name, strings.Join(registered, ", "))
+		}
+		p = append(p, t)
+	}
+	return p, nil
+}
+
+func (p Pipeline) ToDownstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	for _, t := range p {
+		if err := t.ToDownstream(gvr, obj); err != nil {
+			return fmt.Errorf("transforming %s %s for downstream: %w", gvr.Resource, obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (p Pipeline) ToUpstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	for _, t := range p {
+		if err := t.ToUpstream(gvr, obj); err != nil {
+			return fmt.Errorf("transforming %s %s for upstream: %w", gvr.Resource, obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// Mapping renames what's in kcp to what's in the cluster, such as
+// namespaces. The names it doesn't map are kept.
+type Mapping map[string]string
+
+// ParseMapping parses a comma-separated list of <kcp name>=<cluster name>.
+// No two names may be mapped to the same one.
+func ParseMapping(s string) (Mapping, error) {
+	m := Mapping{}
+	targets := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid mapping %q, must be <kcp name>=<cluster name>", pair)
+		}
+		if other, found := targets[parts[1]]; found {
+			return nil, fmt.Errorf("both %s and %s are mapped to %s", other, parts[0], parts[1])
+		}
+		m[parts[0]] = parts[1]
+		targets[parts[1]] = parts[0]
+	}
+	return m, nil
+}
+
+// String returns the mapping as ParseMapping parses it, sorted.
+func (m Mapping) String() string {
+	pairs := make([]string, 0, len(m))
+	for from, to := range m {
+		pairs = append(pairs, from+"="+to)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Transforms are what a syncer changes in the objects it syncs, beyond
+// leaving out the metadata that only makes sense where they come from.
+type Transforms struct {
+	// Namespaces maps the namespaces of kcp to those of the cluster, e.g.
+	// for a cluster whose namespaces follow a convention of their own.
+	Namespaces Mapping
+	// NamespaceStrategy decides the namespaces of the cluster of those it
+	// doesn't map; nil keeps them.
+	NamespaceStrategy NamespaceStrategy
+	// ServiceAccounts maps the ServiceAccounts pods run as in kcp to those
+	// of the cluster, e.g. for the cluster's admission to accept them. An
+	// unset ServiceAccount is the default one.
+	ServiceAccounts Mapping
+	// Custom runs after those.
+	Custom Pipeline
+}
+
+// DownstreamNamespace returns the namespace of the cluster the objects of a
+// namespace of kcp are synced to.
+func (t Transforms) DownstreamNamespace(namespace string) string {
+	return namespaceMapping{mapping: t.Namespaces, strategy: t.NamespaceStrategy}.downstream(namespace)
+}
+
+// SetTransforms sets what the syncer changes in the objects it syncs; it
+// must be called before Start.
+func (c *Controller) SetTransforms(t Transforms) {
+	c.namespaces = namespaceMapping{mapping: t.Namespaces, strategy: t.NamespaceStrategy}
+	c.serviceAccounts = t.ServiceAccounts
+	c.transforms = append(Pipeline{internalFields{}, c.namespaces, distributedNames{}, serviceAccountMapping(t.ServiceAccounts)}, t.Custom...)
+}
+
+// internalFields leaves out the metadata and status that only make sense
+// where an object comes from, e.g. its resource version, owners and
+// finalizers, and the logical cluster of kcp's objects. The status of an
+// upstream object belongs to the cluster.
+type internalFields struct{}
+
+func (internalFields) ToDownstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	unstructured.RemoveNestedField(obj.Object, "status")
+	stripMetadata(obj)
+	return nil
+}
+
+func (internalFields) ToUpstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	stripMetadata(obj)
+	return nil
+}
+
+func stripMetadata(obj *unstructured.Unstructured) {
+	obj.SetClusterName("")
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	obj.SetSelfLink("")
+	obj.SetGeneration(0)
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetDeletionTimestamp(nil)
+	obj.SetDeletionGracePeriodSeconds(nil)
+	obj.SetManagedFields(nil)
+	// The owners only exist where the object comes from; elsewhere, the
+	// garbage collector would delete the object right away.
+	obj.SetOwnerReferences(nil)
+	// So do the finalizers, which only the controllers and syncers there
+	// remove.
+	obj.SetFinalizers(nil)
+	// The trace of the last write of an object carries on where it's synced
+	// to, through the syncer's own calls.
+	if annotations := obj.GetAnnotations(); annotations[tracing.ContextAnnotation] != "" {
+		delete(annotations, tracing.ContextAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// distributedFromAnnotation names the Secret of kcp that a Secret
+// distributed to a cluster was copied from, under a name of its own.
+const distributedFromAnnotation = "experimental.kcp.dev/distributed-from"
+
+// upstreamNameAnnotation records the upstream name of the downstream objects
+// synced under another name, to tell which upstream object each is the copy
+// of.
+const upstreamNameAnnotation = "experimental.kcp.dev/upstream-name"
+
+// distributedNames syncs the Secrets distributed to the cluster, named
+// <secret>--<cluster> in kcp, under the name of the Secret they're copied
+// from, as the pods referencing them expect.
+type distributedNames struct{}
+
+func (distributedNames) ToDownstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	annotations := obj.GetAnnotations()
+	from := annotations[distributedFromAnnotation]
+	if gvr != secretsGVR || from == "" || from == obj.GetName() {
+		return nil
+	}
+	annotations[upstreamNameAnnotation] = obj.GetName()
+	delete(annotations, distributedFromAnnotation)
+	obj.SetAnnotations(annotations)
+	obj.SetName(from)
+	return nil
+}
+
+func (distributedNames) ToUpstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	annotations := obj.GetAnnotations()
+	name, found := annotations[upstreamNameAnnotation]
+	if gvr != secretsGVR || !found {
+		return nil
+	}
+	annotations[distributedFromAnnotation] = obj.GetName()
+	delete(annotations, upstreamNameAnnotation)
+	obj.SetAnnotations(annotations)
+	obj.SetName(name)
+	return nil
+}
+
+// serviceAccountMapping makes the pods of synced objects run as the
+// ServiceAccounts theirs are mapped to.
+type serviceAccountMapping Mapping
+
+func (m serviceAccountMapping) ToDownstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	if len(m) == 0 {
+		return nil
+	}
+	paths := podSpecPaths
+	if obj.GetKind() == "Pod" {
+		paths = [][]string{{"spec"}}
+	}
+	for _, path := range paths {
+		spec, found, err := unstructured.NestedMap(obj.Object, path...)
+		if err != nil || !found {
+			continue
+		}
+		name, _ := spec["serviceAccountName"].(string)
+		if name == "" {
+			name = "default"
+		}
+		mapped, found := m[name]
+		if !found {
+			continue
+		}
+		spec["serviceAccountName"] = mapped
+		if _, set := spec["serviceAccount"]; set {
+			// The deprecated field must agree.
+			spec["serviceAccount"] = mapped
+		}
+		if err := unstructured.SetNestedMap(obj.Object, spec, path...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (serviceAccountMapping) ToUpstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	return nil
+}
@@ -0,0 +1,126 @@
+package syncer
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseMapping(t *testing.T) {
+	m, err := ParseMapping("web=team-a-web, default=team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.String(); got != "default=team-a,web=team-a-web" {
+		t.Errorf("got %s", got)
+	}
+	if got := m["web"]; got != "team-a-web" {
+		t.Errorf("web is mapped to %s", got)
+	}
+
+	for _, s := range []string{"web", "web=", "web=team-a,default=team-a"} {
+		if _, err := ParseMapping(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	c := &Controller{}
+	c.SetTransforms(Transforms{
+		Namespaces:      Mapping{"web": "team-a-web"},
+		ServiceAccounts: Mapping{"default": "workloads"},
+	})
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	upstream := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"namespace":       "web",
+			"clusterName":     "admin",
+			"resourceVersion": "42",
+			"finalizers":      []interface{}{Finalizer("us-east")},
+			"labels":          map[string]interface{}{"cluster": "us-east"},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"containers": []interface{}{}},
+			},
+		},
+		"status": map[string]interface{}{"replicas": int64(1)},
+	}}
+
+	want, err := c.downstreamCopy(gvr, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.GetNamespace() != "team-a-web" {
+		t.Errorf("synced to namespace %s", want.GetNamespace())
+	}
+	if want.GetClusterName() != "" || want.GetResourceVersion() != "" || len(want.GetFinalizers()) > 0 {
+		t.Errorf("kept the metadata of kcp: %v", want.Object["metadata"])
+	}
+	if _, found := want.Object["status"]; found {
+		t.Error("kept the status")
+	}
+	if sa, _, _ := unstructured.NestedString(want.Object, "spec", "template", "spec", "serviceAccountName"); sa != "workloads" {
+		t.Errorf("runs as %q", sa)
+	}
+	if upstream.GetNamespace() != "web" || upstream.GetResourceVersion() != "42" {
+		t.Error("changed the upstream object")
+	}
+
+	back, err := c.upstreamCopy(gvr, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.GetNamespace() != "web" {
+		t.Errorf("written back to namespace %s", back.GetNamespace())
+	}
+	if got, _ := c.upstreamKeyOf(want); got != "web/web" {
+		t.Errorf("got key %s back", got)
+	}
+	if got := c.downstreamKey("web/web"); got != "team-a-web/web" {
+		t.Errorf("got downstream key %s", got)
+	}
+	if !reflect.DeepEqual(back.GetLabels(), upstream.GetLabels()) {
+		t.Errorf("got labels %v back", back.GetLabels())
+	}
+}
+
+func TestDistributedNames(t *testing.T) {
+	c := &Controller{}
+	c.SetTransforms(Transforms{})
+	upstream := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":        "db--us-east",
+			"namespace":   "web",
+			"labels":      map[string]interface{}{"cluster": "us-east", "owned-by": "db"},
+			"annotations": map[string]interface{}{distributedFromAnnotation: "db"},
+		},
+		"data": map[string]interface{}{"password": "czNjcjN0"},
+	}}
+
+	want, err := c.downstreamCopy(secretsGVR, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.GetName() != "db" {
+		t.Errorf("synced as %s", want.GetName())
+	}
+	if got, _ := c.upstreamKeyOf(want); got != "web/db--us-east" {
+		t.Errorf("got key %s back", got)
+	}
+	back, err := c.upstreamCopy(secretsGVR, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.GetName() != "db--us-east" || !reflect.DeepEqual(back.GetAnnotations(), upstream.GetAnnotations()) {
+		t.Errorf("got %s annotated %v back", back.GetName(), back.GetAnnotations())
+	}
+}
@@ -0,0 +1,191 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SetUpsyncedResources makes the syncer mirror the downstream objects of the
+// given resources, e.g. the Pods, ReplicaSets and Events the cluster creates
+// for the synced objects, to kcp, for users to see there what runs where.
+// Only the objects of the namespaces synced objects are in are mirrored,
+// labeled with the syncer's cluster, and kept as they are downstream:
+// changing or deleting them in kcp is undone. It must be called before
+// Start.
+func (c *Controller) SetUpsyncedResources(resources []string) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	gvrs, err := getAllGVRs(c.fromConfig, resources...)
+	if err != nil {
+		return err
+	}
+	for _, gvr := range gvrs {
+		gvr := gvr
+		c.toUnlabeledDSIF.ForResource(gvr).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueue(gvr, obj, upsync) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueue(gvr, obj, upsync) },
+			DeleteFunc: func(obj interface{}) { c.enqueue(gvr, obj, upsync) },
+		})
+		c.fromUpsyncedDSIF.ForResource(gvr).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(_, obj interface{}) { c.enqueueMirrored(gvr, obj) },
+			DeleteFunc: func(obj interface{}) { c.enqueueMirrored(gvr, obj) },
+		})
+	}
+	c.upsyncGVRs = gvrs
+	return nil
+}
+
+// enqueueMirrored enqueues the downstream object of an upstream mirror, to
+// be mirrored again.
+func (c *Controller) enqueueMirrored(gvr schema.GroupVersionResource, obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(holder{gvr: gvr, key: c.downstreamKey(key), dir: upsync})
+}
+
+// mirror creates, updates or deletes the upstream mirror of the downstream
+// object with the given key. Objects synced from kcp aren't mirrored, and
+// neither are those of the namespaces the objects of several upstream
+// namespaces are synced to, which can't be told apart.
+func (c *Controller) mirror(ctx context.Context, gvr schema.GroupVersionResource, key string) error {
+	logger := logging.FromContext(ctx)
+	downstreamNamespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	namespace, single := c.namespaces.upstream(downstreamNamespace)
+	if !single {
+		return nil
+	}
+	client := c.fromClient.Resource(gvr).Namespace(namespace)
+
+	obj, exists, err := c.toUnlabeledDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	var downstream *unstructured.Unstructured
+	if exists {
+		if downstream, err = interfaceToUnstructured(obj); err != nil {
+			return err
+		}
+		if downstream.GetLabels()[clusterLabel] == c.clusterID {
+			// Synced from kcp.
+			downstream = nil
+		} else if synced, err := c.syncedIn(namespace); err != nil {
+			return err
+		} else if !synced {
+			downstream = nil
+		}
+	}
+
+	existing, found, err := c.fromUpsyncedDSIF.ForResource(gvr).Informer().GetIndexer().GetByKey(namespace + "/" + name)
+	if err != nil {
+		return err
+	}
+	if downstream == nil {
+		if !found {
+			return nil
+		}
+		logger.V(4).Info("Deleting mirror of object that is gone")
+		if err := client.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	want, err := c.upstreamCopy(gvr, downstream)
+	if err != nil {
+		return err
+	}
+	want.SetNamespace(namespace)
+	l := want.GetLabels()
+	if l == nil {
+		l = map[string]string{}
+	}
+	delete(l, clusterLabel)
+	l[upsyncedFromLabel] = c.clusterID
+	want.SetLabels(l)
+	if ns, found, _ := unstructured.NestedString(want.Object, "involvedObject", "namespace"); found && ns != "" {
+		// Events are about the objects of the upstream namespace.
+		if upstreamNamespace, ok := c.namespaces.upstream(ns); ok {
+			if err := unstructured.SetNestedField(want.Object, upstreamNamespace, "involvedObject", "namespace"); err != nil {
+				return err
+			}
+		}
+	}
+
+	var current *unstructured.Unstructured
+	if found {
+		if current, err = interfaceToUnstructured(existing); err != nil {
+			return err
+		}
+	}
+	switch {
+	case current == nil:
+		logger.V(4).Info("Mirroring object")
+		current, err = client.Create(ctx, want, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			// Not ours to touch.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	case !matches(syncedContent(want), syncedContent(current)):
+		updated := want.DeepCopy()
+		updated.SetResourceVersion(current.GetResourceVersion())
+		if current, err = client.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	status, found := want.Object["status"]
+	if !found || equality.Semantic.DeepEqual(current.Object["status"], status) {
+		return nil
+	}
+	updated := current.DeepCopy()
+	updated.Object["status"] = status
+	_, err = client.UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if k8serrors.IsNotFound(err) {
+		// The resource has no status subresource, so it was written with the rest.
+		return nil
+	}
+	return err
+}
+
+// syncedIn reports whether any synced object is in the upstream namespace.
+func (c *Controller) syncedIn(namespace string) (bool, error) {
+	for _, gvr := range c.gvrs {
+		upstream, err := c.fromDSIF.ForResource(gvr).Lister().ByNamespace(namespace).List(labels.Everything())
+		if err != nil {
+			return false, err
+		}
+		if len(upstream) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// enqueueMirrors enqueues the downstream object of every upstream mirror,
+// so that those whose object is gone are deleted.
+func (c *Controller) enqueueMirrors() {
+	for _, gvr := range c.upsyncGVRs {
+		for _, obj := range c.fromUpsyncedDSIF.ForResource(gvr).Informer().GetIndexer().List() {
+			c.enqueueMirrored(gvr, obj)
+		}
+	}
+}
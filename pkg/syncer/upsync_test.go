@@ -0,0 +1,356 @@
+package syncer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	podsGVR   = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	eventsGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+)
+
+// upsyncFixture is a syncer for the Deployments of cluster us-east mirroring
+// Pods and Events, whose upstream and downstream caches are filled by hand.
+type upsyncFixture struct {
+	t    *testing.T
+	c    *Controller
+	from *dynamicfake.FakeDynamicClient
+}
+
+func newUpsyncFixture(t *testing.T, transforms Transforms) *upsyncFixture {
+	f := &upsyncFixture{
+		t:    t,
+		from: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+	}
+	// As the API server does, creates leave the status out.
+	f.from.PrependReactor("create", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		// Reactors are given copies of the actions.
+		delete(action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured).Object, "status")
+		return false, nil, nil
+	})
+	to := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	f.c = &Controller{
+		clusterID:        "us-east",
+		gvrs:             []schema.GroupVersionResource{deploymentsGVR},
+		fromClient:       f.from,
+		fromDSIF:         dynamicinformer.NewDynamicSharedInformerFactory(f.from, 0),
+		fromUpsyncedDSIF: dynamicinformer.NewDynamicSharedInformerFactory(f.from, 0),
+		toClient:         to,
+		toUnlabeledDSIF:  dynamicinformer.NewDynamicSharedInformerFactory(to, 0),
+		upsyncGVRs:       []schema.GroupVersionResource{podsGVR, eventsGVR},
+	}
+	f.c.SetTransforms(transforms)
+	return f
+}
+
+// synced adds a Deployment synced to us-east to the namespace of kcp.
+func (f *upsyncFixture) synced(namespace string) {
+	d := splitDeployment("web--us-east")
+	d.SetNamespace(namespace)
+	if err := f.c.fromDSIF.ForResource(deploymentsGVR).Informer().GetIndexer().Add(d); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// addDownstream adds an object of the cluster to the cache.
+func (f *upsyncFixture) addDownstream(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) {
+	if err := f.c.toUnlabeledDSIF.ForResource(gvr).Informer().GetIndexer().Add(obj); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// addMirror adds a mirror to kcp and its cache, leaving its writes out of
+// those of the test.
+func (f *upsyncFixture) addMirror(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) {
+	client := f.from.Resource(gvr).Namespace(obj.GetNamespace())
+	created, err := client.Create(context.Background(), obj, metav1.CreateOptions{})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if status, found := obj.Object["status"]; found {
+		created.Object["status"] = status
+		if created, err = client.UpdateStatus(context.Background(), created, metav1.UpdateOptions{}); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+	if err := f.c.fromUpsyncedDSIF.ForResource(gvr).Informer().GetIndexer().Add(created); err != nil {
+		f.t.Fatal(err)
+	}
+	f.from.ClearActions()
+}
+
+// upstream returns the object of kcp, nil if not found.
+func (f *upsyncFixture) upstream(gvr schema.GroupVersionResource, namespace, name string) *unstructured.Unstructured {
+	u, err := f.from.Resource(gvr).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return u
+}
+
+// writes returns the verbs of the writes to kcp, e.g. "update/status".
+func (f *upsyncFixture) writes() []string {
+	var verbs []string
+	for _, a := range f.from.Actions() {
+		if a.GetVerb() == "get" || a.GetVerb() == "list" || a.GetVerb() == "watch" {
+			continue
+		}
+		verb := a.GetVerb()
+		if a.GetSubresource() != "" {
+			verb += "/" + a.GetSubresource()
+		}
+		verbs = append(verbs, verb)
+	}
+	return verbs
+}
+
+func upsyncPod(namespace, image, phase string, labels map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"containers": []interface{}{
+			map[string]interface{}{"name": "web", "image": image},
+		}},
+	}}
+	if phase != "" {
+		u.Object["status"] = map[string]interface{}{"phase": phase}
+	}
+	u.SetAPIVersion("v1")
+	u.SetKind("Pod")
+	u.SetName("web-1")
+	u.SetNamespace(namespace)
+	u.SetLabels(labels)
+	return u
+}
+
+// mirrorOf returns the mirror in kcp of the Pod of us-east.
+func mirrorOf(pod *unstructured.Unstructured) *unstructured.Unstructured {
+	u := pod.DeepCopy()
+	u.SetLabels(map[string]string{"app": "web", upsyncedFromLabel: "us-east"})
+	return u
+}
+
+func imageOf(u *unstructured.Unstructured) string {
+	containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "containers")
+	if len(containers) == 0 {
+		return ""
+	}
+	image, _, _ := unstructured.NestedString(containers[0].(map[string]interface{}), "image")
+	return image
+}
+
+func TestMirror(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// setup fills the caches and kcp before the Pod default/web-1 of the
+		// cluster is mirrored.
+		setup func(f *upsyncFixture)
+		// image and phase are those of the mirror afterwards, empty if none.
+		image, phase string
+		writes       []string
+	}{{
+		name: "created",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"}))
+		},
+		image:  "web:1",
+		phase:  "Running",
+		writes: []string{"create", "update/status"},
+	}, {
+		name: "created without status",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "", map[string]string{"app": "web"}))
+		},
+		image:  "web:1",
+		writes: []string{"create"},
+	}, {
+		name: "updated",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			f.addMirror(podsGVR, mirrorOf(upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"})))
+			f.addDownstream(podsGVR, upsyncPod("default", "web:2", "Running", map[string]string{"app": "web"}))
+		},
+		image:  "web:2",
+		phase:  "Running",
+		writes: []string{"update"},
+	}, {
+		name: "status updated",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			f.addMirror(podsGVR, mirrorOf(upsyncPod("default", "web:1", "Pending", map[string]string{"app": "web"})))
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"}))
+		},
+		image:  "web:1",
+		phase:  "Running",
+		writes: []string{"update/status"},
+	}, {
+		name: "unchanged",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			f.addMirror(podsGVR, mirrorOf(upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"})))
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"}))
+		},
+		image: "web:1",
+		phase: "Running",
+	}, {
+		name: "changed in kcp",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			changed := mirrorOf(upsyncPod("default", "debug:1", "Running", map[string]string{"app": "web"}))
+			f.addMirror(podsGVR, changed)
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"}))
+		},
+		image:  "web:1",
+		phase:  "Running",
+		writes: []string{"update"},
+	}, {
+		name: "gone",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			f.addMirror(podsGVR, mirrorOf(upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"})))
+		},
+		writes: []string{"delete"},
+	}, {
+		name: "synced from kcp",
+		setup: func(f *upsyncFixture) {
+			f.synced("default")
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{clusterLabel: "us-east"}))
+		},
+	}, {
+		name: "namespace without synced objects",
+		setup: func(f *upsyncFixture) {
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"}))
+		},
+	}, {
+		name: "namespace without synced objects anymore",
+		setup: func(f *upsyncFixture) {
+			f.addMirror(podsGVR, mirrorOf(upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"})))
+			f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"}))
+		},
+		writes: []string{"delete"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newUpsyncFixture(t, Transforms{})
+			tc.setup(f)
+			if err := f.c.mirror(context.Background(), podsGVR, "default/web-1"); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := strings.Join(f.writes(), ","), strings.Join(tc.writes, ","); got != want {
+				t.Errorf("got writes %s, want %s", got, want)
+			}
+			got := f.upstream(podsGVR, "default", "web-1")
+			if tc.image == "" {
+				if got != nil {
+					t.Errorf("got mirror %v", got.Object)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("got no mirror")
+			}
+			if image := imageOf(got); image != tc.image {
+				t.Errorf("got image %q, want %q", image, tc.image)
+			}
+			if phase, _, _ := unstructured.NestedString(got.Object, "status", "phase"); phase != tc.phase {
+				t.Errorf("got phase %q, want %q", phase, tc.phase)
+			}
+			if l := got.GetLabels(); l[upsyncedFromLabel] != "us-east" || l["app"] != "web" || l[clusterLabel] != "" {
+				t.Errorf("got labels %v", l)
+			}
+		})
+	}
+}
+
+func TestMirrorMapsNamespaces(t *testing.T) {
+	f := newUpsyncFixture(t, Transforms{Namespaces: Mapping{"default": "apps"}})
+	f.synced("default")
+	event := &unstructured.Unstructured{Object: map[string]interface{}{
+		"involvedObject": map[string]interface{}{"kind": "Pod", "namespace": "apps", "name": "web-1"},
+		"reason":         "Pulled",
+	}}
+	event.SetAPIVersion("v1")
+	event.SetKind("Event")
+	event.SetName("web-1.1")
+	event.SetNamespace("apps")
+	f.addDownstream(eventsGVR, event)
+
+	if err := f.c.mirror(context.Background(), eventsGVR, "apps/web-1.1"); err != nil {
+		t.Fatal(err)
+	}
+	got := f.upstream(eventsGVR, "default", "web-1.1")
+	if got == nil {
+		t.Fatal("got no mirror in namespace default")
+	}
+	if ns, _, _ := unstructured.NestedString(got.Object, "involvedObject", "namespace"); ns != "default" {
+		t.Errorf("got an Event about an object of namespace %q", ns)
+	}
+}
+
+func TestMirrorConflicts(t *testing.T) {
+	t.Run("object of kcp", func(t *testing.T) {
+		f := newUpsyncFixture(t, Transforms{})
+		f.synced("default")
+		// Created in kcp itself, so not among the mirrors.
+		if _, err := f.from.Resource(podsGVR).Namespace("default").Create(context.Background(), upsyncPod("default", "other:1", "", map[string]string{"app": "other"}), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		f.addDownstream(podsGVR, upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"}))
+
+		if err := f.c.mirror(context.Background(), podsGVR, "default/web-1"); err != nil {
+			t.Fatal(err)
+		}
+		got := f.upstream(podsGVR, "default", "web-1")
+		if imageOf(got) != "other:1" || got.GetLabels()[upsyncedFromLabel] != "" {
+			t.Errorf("changed the Pod of kcp: %v", got.Object)
+		}
+		if _, found := got.Object["status"]; found {
+			t.Errorf("set the status of the Pod of kcp: %v", got.Object)
+		}
+	})
+
+	t.Run("mirror changed meanwhile", func(t *testing.T) {
+		f := newUpsyncFixture(t, Transforms{})
+		f.synced("default")
+		f.addMirror(podsGVR, mirrorOf(upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"})))
+		f.addDownstream(podsGVR, upsyncPod("default", "web:2", "Running", map[string]string{"app": "web"}))
+		f.from.PrependReactor("update", "pods", func(clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewConflict(podsGVR.GroupResource(), "web-1", nil)
+		})
+
+		// Returned for the Pod to be mirrored again once the cache has caught up.
+		if err := f.c.mirror(context.Background(), podsGVR, "default/web-1"); !k8serrors.IsConflict(err) {
+			t.Errorf("got %v, want a conflict", err)
+		}
+	})
+}
+
+func TestEnqueueMirrors(t *testing.T) {
+	f := newUpsyncFixture(t, Transforms{Namespaces: Mapping{"default": "apps"}})
+	f.c.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	f.addMirror(podsGVR, mirrorOf(upsyncPod("default", "web:1", "Running", map[string]string{"app": "web"})))
+
+	f.c.enqueueMirrors()
+	if f.c.queue.Len() != 1 {
+		t.Fatalf("got %d keys queued", f.c.queue.Len())
+	}
+	item, _ := f.c.queue.Get()
+	if h := item.(holder); h.gvr != podsGVR || h.key != "apps/web-1" || h.dir != upsync {
+		t.Errorf("got %+v queued", h)
+	}
+}
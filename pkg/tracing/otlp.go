@@ -0,0 +1,280 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// exporter exports spans to an OTLP/HTTP collector, as the protobuf
+// encoding of an ExportTraceServiceRequest. Unlike the OTLP exporters of
+// OpenTelemetry, it needs no gRPC, whose versions supported by them the
+// etcd client of kcp doesn't build with.
+type exporter struct {
+	url    string
+	client *http.Client
+}
+
+var _ sdktrace.SpanExporter = &exporter{}
+
+func newExporter(endpoint *url.URL) *exporter {
+	u := *endpoint
+	u.Path = "/v1/traces"
+	return &exporter{url: u.String(), client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (e *exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(encodeSpans(spans)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("exporting %d spans to %s: %s: %s", len(spans), e.url, resp.Status, body)
+	}
+	return nil
+}
+
+func (e *exporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// encodeSpans returns the ExportTraceServiceRequest of spans, with a
+// ResourceSpans per resource and InstrumentationLibrarySpans per library.
+func encodeSpans(spans []sdktrace.ReadOnlySpan) []byte {
+	type library struct {
+		name, version string
+		spans         []sdktrace.ReadOnlySpan
+	}
+	type resourceSpans struct {
+		attrs     []attribute.KeyValue
+		libraries []*library
+	}
+	var order []*resourceSpans
+	byResource := map[string]*resourceSpans{}
+	for _, s := range spans {
+		var attrs []attribute.KeyValue
+		key := ""
+		if res := s.Resource(); res != nil {
+			attrs = res.Attributes()
+			key = res.String()
+		}
+		rs, found := byResource[key]
+		if !found {
+			rs = &resourceSpans{attrs: attrs}
+			byResource[key] = rs
+			order = append(order, rs)
+		}
+		il := s.InstrumentationLibrary()
+		var lib *library
+		for _, l := range rs.libraries {
+			if l.name == il.Name && l.version == il.Version {
+				lib = l
+			}
+		}
+		if lib == nil {
+			lib = &library{name: il.Name, version: il.Version}
+			rs.libraries = append(rs.libraries, lib)
+		}
+		lib.spans = append(lib.spans, s)
+	}
+
+	var req protoBuffer
+	for _, rs := range order {
+		req.message(1, func(b *protoBuffer) {
+			b.message(1, func(b *protoBuffer) {
+				for _, kv := range rs.attrs {
+					b.message(1, func(b *protoBuffer) { encodeKeyValue(b, kv) })
+				}
+			})
+			for _, lib := range rs.libraries {
+				b.message(2, func(b *protoBuffer) {
+					b.message(1, func(b *protoBuffer) {
+						b.string(1, lib.name)
+						b.string(2, lib.version)
+					})
+					for _, s := range lib.spans {
+						b.message(2, func(b *protoBuffer) { encodeSpan(b, s) })
+					}
+				})
+			}
+		})
+	}
+	return req.Bytes()
+}
+
+// encodeSpan encodes s as an opentelemetry.proto.trace.v1.Span.
+func encodeSpan(b *protoBuffer, s sdktrace.ReadOnlySpan) {
+	sc := s.SpanContext()
+	traceID, spanID := sc.TraceID(), sc.SpanID()
+	b.bytes(1, traceID[:])
+	b.bytes(2, spanID[:])
+	b.string(3, sc.TraceState().String())
+	if parent := s.Parent(); parent.SpanID().IsValid() {
+		parentID := parent.SpanID()
+		b.bytes(4, parentID[:])
+	}
+	b.string(5, s.Name())
+	// The span kinds of OpenTelemetry and OTLP have the same numbers.
+	b.varint(6, uint64(s.SpanKind()))
+	b.fixed64(7, uint64(s.StartTime().UnixNano()))
+	b.fixed64(8, uint64(s.EndTime().UnixNano()))
+	for _, kv := range s.Attributes() {
+		b.message(9, func(b *protoBuffer) { encodeKeyValue(b, kv) })
+	}
+	b.varint(10, uint64(s.DroppedAttributes()))
+	for _, e := range s.Events() {
+		b.message(11, func(b *protoBuffer) {
+			b.fixed64(1, uint64(e.Time.UnixNano()))
+			b.string(2, e.Name)
+			for _, kv := range e.Attributes {
+				b.message(3, func(b *protoBuffer) { encodeKeyValue(b, kv) })
+			}
+			b.varint(4, uint64(e.DroppedAttributeCount))
+		})
+	}
+	b.varint(12, uint64(s.DroppedEvents()))
+	for _, l := range s.Links() {
+		b.message(13, func(b *protoBuffer) {
+			traceID, spanID := l.SpanContext.TraceID(), l.SpanContext.SpanID()
+			b.bytes(1, traceID[:])
+			b.bytes(2, spanID[:])
+			b.string(3, l.SpanContext.TraceState().String())
+			for _, kv := range l.Attributes {
+				b.message(4, func(b *protoBuffer) { encodeKeyValue(b, kv) })
+			}
+		})
+	}
+	b.varint(14, uint64(s.DroppedLinks()))
+	b.message(15, func(b *protoBuffer) {
+		status := s.Status()
+		b.string(2, status.Description)
+		// Unlike those of OpenTelemetry, the status codes of OTLP are OK 1
+		// and ERROR 2.
+		switch status.Code {
+		case codes.Ok:
+			b.varint(3, 1)
+		case codes.Error:
+			b.varint(3, 2)
+		}
+	})
+}
+
+// encodeKeyValue encodes kv as an opentelemetry.proto.common.v1.KeyValue,
+// with the values of slices as strings.
+func encodeKeyValue(b *protoBuffer, kv attribute.KeyValue) {
+	b.string(1, string(kv.Key))
+	b.message(2, func(b *protoBuffer) {
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			v := uint64(0)
+			if kv.Value.AsBool() {
+				v = 1
+			}
+			b.varintAlways(2, v)
+		case attribute.INT64:
+			b.varintAlways(3, uint64(kv.Value.AsInt64()))
+		case attribute.FLOAT64:
+			b.fixed64Always(4, math.Float64bits(kv.Value.AsFloat64()))
+		case attribute.STRING:
+			b.stringAlways(1, kv.Value.AsString())
+		default:
+			b.stringAlways(1, kv.Value.Emit())
+		}
+	})
+}
+
+// protoBuffer appends the fields of a protobuf message to its buffer,
+// leaving out those with zero values, as proto3 does, unless told not to,
+// as the fields of a oneof can't be.
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func (b *protoBuffer) tag(field, wire int) {
+	b.uvarint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protoBuffer) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (b *protoBuffer) varint(field int, v uint64) {
+	if v != 0 {
+		b.varintAlways(field, v)
+	}
+}
+
+func (b *protoBuffer) varintAlways(field int, v uint64) {
+	b.tag(field, wireVarint)
+	b.uvarint(v)
+}
+
+func (b *protoBuffer) fixed64(field int, v uint64) {
+	if v != 0 {
+		b.fixed64Always(field, v)
+	}
+}
+
+func (b *protoBuffer) fixed64Always(field int, v uint64) {
+	b.tag(field, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	b.tag(field, wireBytes)
+	b.uvarint(uint64(len(v)))
+	b.Write(v)
+}
+
+func (b *protoBuffer) string(field int, v string) {
+	b.bytes(field, []byte(v))
+}
+
+func (b *protoBuffer) stringAlways(field int, v string) {
+	b.tag(field, wireBytes)
+	b.uvarint(uint64(len(v)))
+	b.WriteString(v)
+}
+
+// message encodes the message written by fn as the given field, even if
+// it's empty.
+func (b *protoBuffer) message(field int, fn func(b *protoBuffer)) {
+	var m protoBuffer
+	fn(&m)
+	b.tag(field, wireBytes)
+	b.uvarint(uint64(m.Len()))
+	b.Write(m.Bytes())
+}
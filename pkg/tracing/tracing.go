@@ -0,0 +1,168 @@
+// Package tracing traces the reconciles of the controllers and the syncer
+// with OpenTelemetry, from the dequeue of a key to the API calls it makes,
+// down to those the syncers make on the physical clusters, and exports the
+// spans over OTLP/HTTP. The traces of a rollout cross the kcp API server in
+// the annotation of the leafs written: the syncer picks their spans up from
+// there, as children of the span of the splitter that wrote them.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// ContextAnnotation holds the W3C traceparent of the span that last wrote an
+// object, such as a leaf, for those syncing it to carry on its trace. The
+// syncers don't copy it downstream.
+const ContextAnnotation = "experimental.kcp.dev/trace-context"
+
+// DefaultSamplingRate is the fraction of the traces started that are kept.
+const DefaultSamplingRate = 0.1
+
+const instrumentationName = "github.com/kcp-dev/kcp"
+
+var propagator = propagation.TraceContext{}
+
+// Setup makes the process export its spans, as service, to the OTLP/HTTP
+// collector at endpoint, such as http://otel-collector:4318, keeping the
+// given fraction of the traces it starts, and those of the traces it's
+// called as part of. It returns a function flushing the spans not exported
+// yet, to call before exiting. Nothing is traced without an endpoint.
+func Setup(service, endpoint string, samplingRate float64) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("the tracing endpoint %q isn't an http or https URL", endpoint)
+	}
+	if samplingRate < 0 || samplingRate > 1 {
+		return nil, fmt.Errorf("the tracing sampling rate must be between 0 and 1, not %v", samplingRate)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(newExporter(u)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplingRate))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+	return tp.Shutdown, nil
+}
+
+// Start starts a span of the given name, a child of that of ctx if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartFor starts a span of the given name for work done on obj, a child of
+// the span that wrote obj if its ContextAnnotation says which, linked to
+// that of ctx. Otherwise, it's a child of the span of ctx, as with Start.
+func StartFor(ctx context.Context, obj metav1.Object, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	writer := propagator.Extract(context.Background(), annotationCarrier{obj})
+	if !trace.SpanContextFromContext(writer).IsValid() {
+		return Start(ctx, name, attrs...)
+	}
+	opts := []trace.SpanStartOption{trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindConsumer)}
+	if current := trace.SpanContextFromContext(ctx); current.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: current}))
+	}
+	return otel.Tracer(instrumentationName).Start(writer, name, opts...)
+}
+
+// End ends span, marking it failed if err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject records the span of ctx in the ContextAnnotation of obj, about to
+// be written, if it's sampled.
+func Inject(ctx context.Context, obj metav1.Object) {
+	if !trace.SpanContextFromContext(ctx).IsSampled() {
+		return
+	}
+	propagator.Inject(ctx, annotationCarrier{obj})
+}
+
+// annotationCarrier carries the traceparent of a span in ContextAnnotation.
+type annotationCarrier struct {
+	obj metav1.Object
+}
+
+func (c annotationCarrier) Get(key string) string {
+	if key != "traceparent" {
+		return ""
+	}
+	return c.obj.GetAnnotations()[ContextAnnotation]
+}
+
+func (c annotationCarrier) Set(key, value string) {
+	if key != "traceparent" {
+		return
+	}
+	annotations := c.obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ContextAnnotation] = value
+	c.obj.SetAnnotations(annotations)
+}
+
+func (c annotationCarrier) Keys() []string {
+	return []string{"traceparent"}
+}
+
+// WrapConfig makes the clients of cfg trace the API calls made as part of a
+// trace, and pass it on to the API server in their traceparent header.
+func WrapConfig(cfg *rest.Config) {
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripper{next: rt}
+	})
+}
+
+type roundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(req.Context()).IsValid() {
+		// Such as the lists and watches of the informers.
+		return rt.next.RoundTrip(req)
+	}
+	ctx, span := otel.Tracer(instrumentationName).Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("http.method", req.Method), attribute.String("http.url", req.URL.String())))
+	defer span.End()
+
+	req = req.Clone(ctx)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
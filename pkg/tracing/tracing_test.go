@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	// The splitter writes a leaf as part of its reconcile.
+	ctx, reconcile := Start(context.Background(), "deployment reconcile")
+	leaf := &appsv1.Deployment{}
+	Inject(ctx, leaf)
+	want := trace.SpanContextFromContext(ctx)
+	traceparent := leaf.Annotations[ContextAnnotation]
+	if !strings.Contains(traceparent, want.TraceID().String()) || !strings.Contains(traceparent, want.SpanID().String()) {
+		t.Errorf("got %s annotated for %v", traceparent, want)
+	}
+	reconcile.End()
+
+	// The syncer picks the trace up where the splitter left it.
+	ctx, sync := Start(context.Background(), "syncer sync")
+	_, upsert := StartFor(ctx, leaf, "syncer upsert")
+	End(upsert, nil)
+	sync.End()
+
+	var upserted sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == "syncer upsert" {
+			upserted = s
+		}
+	}
+	if upserted == nil {
+		t.Fatalf("the upsert wasn't traced: %v", recorder.Ended())
+	}
+	if upserted.Parent().SpanID() != want.SpanID() || upserted.SpanContext().TraceID() != want.TraceID() {
+		t.Errorf("expected the upsert to be a child of the reconcile, got parent %v", upserted.Parent())
+	}
+	if links := upserted.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != sync.SpanContext().SpanID() {
+		t.Errorf("expected the upsert to link to the sync, got %v", links)
+	}
+
+	// The spans are exported as protobuf.
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("got %s %s as %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newExporter(u).ExportSpans(context.Background(), recorder.Ended()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"deployment reconcile", "syncer sync", "syncer upsert"} {
+		if !bytes.Contains(body, []byte(name)) {
+			t.Errorf("%q wasn't exported", name)
+		}
+	}
+	if id := want.TraceID(); !bytes.Contains(body, id[:]) {
+		t.Error("the trace ID wasn't exported")
+	}
+}
+
+func TestRoundTripper(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: roundTripper{next: http.DefaultTransport}}
+
+	get := func(ctx context.Context) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	get(context.Background())
+	ctx, span := Start(context.Background(), "deployment reconcile")
+	get(ctx)
+	span.End()
+
+	if len(traceparents) != 2 || traceparents[0] != "" || !strings.Contains(traceparents[1], span.SpanContext().TraceID().String()) {
+		t.Errorf("got traceparents %q", traceparents)
+	}
+	if ended := recorder.Ended(); len(ended) != 2 || ended[0].Name() != "HTTP GET" || ended[0].SpanKind() != trace.SpanKindClient {
+		t.Errorf("expected the call as part of the reconcile to be traced alone, got %v", ended)
+	}
+}
@@ -0,0 +1,200 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The annotations of a Cluster the built-in TransformFuncs read what to
+// change its leafs' pods with from. Leafs on Clusters without them are left
+// as they are.
+const (
+	// ImageRegistryAnnotation holds the registry, e.g.
+	// "registry.eu.example.com", the images of the pods of the Cluster's
+	// leafs are pulled from instead of their own, with ImageRegistry.
+	ImageRegistryAnnotation = "experimental.kcp.dev/image-registry"
+
+	// NodeSelectorAnnotation holds a JSON map of node labels the pods of the
+	// Cluster's leafs must run on nodes with, with NodeSelector.
+	NodeSelectorAnnotation = "experimental.kcp.dev/node-selector"
+
+	// EnvAnnotation holds a JSON list of corev1.EnvVars set in every
+	// container of the pods of the Cluster's leafs, with Env.
+	EnvAnnotation = "experimental.kcp.dev/env"
+
+	// DefaultRequestsAnnotation holds a JSON map of the resource requests,
+	// e.g. {"cpu":"100m"}, of the containers of the pods of the Cluster's
+	// leafs that don't request those resources, with DefaultRequests.
+	DefaultRequestsAnnotation = "experimental.kcp.dev/default-requests"
+)
+
+func init() {
+	Register("ImageRegistry", ImageRegistry)
+	Register("NodeSelector", NodeSelector)
+	Register("Env", Env)
+	Register("DefaultRequests", DefaultRequests)
+}
+
+// podSpecPaths are where the pod templates of workloads have their spec:
+// Deployments, StatefulSets, ReplicaSets, DaemonSets and Jobs, then
+// CronJobs.
+var podSpecPaths = [][]string{
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// containersOf returns the containers and init containers of the pods of
+// the leaf, to be changed in place, or nil if it has no pod template.
+func containersOf(leaf *unstructured.Unstructured) []map[string]interface{} {
+	spec := podSpecOf(leaf)
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		list, _ := spec[field].([]interface{})
+		for _, c := range list {
+			if container, ok := c.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
+
+// podSpecOf returns the spec of the pod template of the leaf, to be changed
+// in place, or nil if it has none.
+func podSpecOf(leaf *unstructured.Unstructured) map[string]interface{} {
+	for _, path := range podSpecPaths {
+		if spec, found, err := unstructured.NestedFieldNoCopy(leaf.Object, path...); err == nil && found {
+			if m, ok := spec.(map[string]interface{}); ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// ImageRegistry pulls the images of the leaf's pods from the Cluster's
+// ImageRegistryAnnotation, replacing their own registry if they name one.
+func ImageRegistry(_ context.Context, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) error {
+	registry := strings.TrimSuffix(cl.Annotations[ImageRegistryAnnotation], "/")
+	if registry == "" {
+		return nil
+	}
+	for _, c := range containersOf(leaf) {
+		if image, ok := c["image"].(string); ok && image != "" {
+			c["image"] = withRegistry(image, registry)
+		}
+	}
+	return nil
+}
+
+// withRegistry returns the image pulled from the given registry. Like
+// Docker, the first component of an image names its registry if it has a
+// dot or a port, or is localhost.
+func withRegistry(image, registry string) string {
+	if i := strings.Index(image, "/"); i >= 0 {
+		if first := image[:i]; strings.ContainsAny(first, ".:") || first == "localhost" {
+			image = image[i+1:]
+		}
+	}
+	return registry + "/" + image
+}
+
+// NodeSelector adds the Cluster's NodeSelectorAnnotation to the node
+// selector of the leaf's pods.
+func NodeSelector(_ context.Context, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) error {
+	s, found := cl.Annotations[NodeSelectorAnnotation]
+	if !found {
+		return nil
+	}
+	var selector map[string]string
+	if err := json.Unmarshal([]byte(s), &selector); err != nil {
+		return fmt.Errorf("invalid %s of cluster %s: %w", NodeSelectorAnnotation, cl.Name, err)
+	}
+	spec := podSpecOf(leaf)
+	if spec == nil || len(selector) == 0 {
+		return nil
+	}
+	nodeSelector, _ := spec["nodeSelector"].(map[string]interface{})
+	if nodeSelector == nil {
+		nodeSelector = map[string]interface{}{}
+	}
+	for k, v := range selector {
+		nodeSelector[k] = v
+	}
+	spec["nodeSelector"] = nodeSelector
+	return nil
+}
+
+// Env sets the Cluster's EnvAnnotation in every container of the leaf's
+// pods, replacing their own variables of the same name.
+func Env(_ context.Context, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) error {
+	s, found := cl.Annotations[EnvAnnotation]
+	if !found {
+		return nil
+	}
+	var vars []corev1.EnvVar
+	if err := json.Unmarshal([]byte(s), &vars); err != nil {
+		return fmt.Errorf("invalid %s of cluster %s: %w", EnvAnnotation, cl.Name, err)
+	}
+	for _, c := range containersOf(leaf) {
+		env, _ := c["env"].([]interface{})
+		for i := range vars {
+			v, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&vars[i])
+			if err != nil {
+				return err
+			}
+			replaced := false
+			for j, e := range env {
+				if m, ok := e.(map[string]interface{}); ok && m["name"] == vars[i].Name {
+					env[j], replaced = v, true
+				}
+			}
+			if !replaced {
+				env = append(env, v)
+			}
+		}
+		c["env"] = env
+	}
+	return nil
+}
+
+// DefaultRequests sets the requests of the Cluster's
+// DefaultRequestsAnnotation in the containers of the leaf's pods that don't
+// request those resources already.
+func DefaultRequests(_ context.Context, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) error {
+	s, found := cl.Annotations[DefaultRequestsAnnotation]
+	if !found {
+		return nil
+	}
+	var defaults corev1.ResourceList
+	if err := json.Unmarshal([]byte(s), &defaults); err != nil {
+		return fmt.Errorf("invalid %s of cluster %s: %w", DefaultRequestsAnnotation, cl.Name, err)
+	}
+	for _, c := range containersOf(leaf) {
+		resources, _ := c["resources"].(map[string]interface{})
+		if resources == nil {
+			resources = map[string]interface{}{}
+		}
+		requests, _ := resources["requests"].(map[string]interface{})
+		if requests == nil {
+			requests = map[string]interface{}{}
+		}
+		for name, q := range defaults {
+			if _, found := requests[string(name)]; !found {
+				requests[string(name)] = q.String()
+			}
+		}
+		if len(requests) > 0 {
+			resources["requests"] = requests
+			c["resources"] = resources
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterlisters "github.com/kcp-dev/kcp/pkg/client/listers/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// Overrides returns a TransformFunc applying the WorkloadOverrides of the
+// leaf's workspace and namespace that select it and the Cluster. The indexer
+// must be that of a WorkloadOverrides informer with the logicalcluster
+// Indexers, as returned by logicalcluster.IndexerFor.
+func Overrides(indexer cache.Indexer) TransformFunc {
+	return func(_ context.Context, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) error {
+		lister := clusterlisters.NewWorkloadOverrideLister(logicalcluster.Scoped(indexer, leaf.GetClusterName()))
+		overrides, err := lister.WorkloadOverrides(leaf.GetNamespace()).List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		sort.Slice(overrides, func(i, j int) bool { return overrides[i].Name < overrides[j].Name })
+		for _, o := range overrides {
+			ok, err := selects(o, cl, leaf)
+			if err != nil {
+				return fmt.Errorf("workload override %s: %w", o.Name, err)
+			}
+			if !ok {
+				continue
+			}
+			for i, p := range o.Spec.Patches {
+				if err := patch(leaf, p); err != nil {
+					return fmt.Errorf("patch %d of workload override %s: %w", i, o.Name, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// selects reports whether the WorkloadOverride applies to the leaf on the
+// Cluster.
+func selects(o *v1alpha1.WorkloadOverride, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) (bool, error) {
+	if len(o.Spec.Kinds) > 0 && !contains(o.Spec.Kinds, leaf.GetKind()) {
+		return false, nil
+	}
+	if o.Spec.WorkloadSelector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(o.Spec.WorkloadSelector)
+		if err != nil {
+			return false, err
+		}
+		if !sel.Matches(labels.Set(leaf.GetLabels())) {
+			return false, nil
+		}
+	}
+	if len(o.Spec.Clusters) == 0 && o.Spec.ClusterSelector == nil {
+		return true, nil
+	}
+	if contains(o.Spec.Clusters, cl.Name) {
+		return true, nil
+	}
+	if o.Spec.ClusterSelector == nil {
+		return false, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(o.Spec.ClusterSelector)
+	if err != nil {
+		return false, err
+	}
+	return sel.Matches(labels.Set(cl.Labels)), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// patch applies the WorkloadPatch to the leaf in place. The patch can't
+// rename the leaf or move it to another namespace or workspace.
+func patch(leaf *unstructured.Unstructured, p v1alpha1.WorkloadPatch) error {
+	original, err := leaf.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.YAMLToJSON([]byte(p.Patch))
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch p.Type {
+	case v1alpha1.WorkloadPatchJSON:
+		ops, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return err
+		}
+		patched, err = ops.Apply(original)
+		if err != nil {
+			return err
+		}
+	case v1alpha1.WorkloadPatchStrategicMerge:
+		schema, err := scheme.Scheme.New(leaf.GroupVersionKind())
+		if runtime.IsNotRegisteredError(err) {
+			// Only the built-in kinds have patch strategies.
+			patched, err = jsonpatch.MergePatch(original, data)
+		} else if err == nil {
+			patched, err = strategicpatch.StrategicMergePatch(original, data, schema)
+		}
+		if err != nil {
+			return err
+		}
+	case v1alpha1.WorkloadPatchMerge:
+		patched, err = jsonpatch.MergePatch(original, data)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown patch type %q", p.Type)
+	}
+
+	name, namespace, clusterName := leaf.GetName(), leaf.GetNamespace(), leaf.GetClusterName()
+	gvk := leaf.GroupVersionKind()
+	if err := leaf.UnmarshalJSON(patched); err != nil {
+		return err
+	}
+	leaf.SetName(name)
+	leaf.SetNamespace(namespace)
+	leaf.SetClusterName(clusterName)
+	leaf.SetGroupVersionKind(gvk)
+	return nil
+}
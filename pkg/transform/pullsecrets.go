@@ -0,0 +1,67 @@
+package transform
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RegistryCredentialPolicyLabel names the RegistryCredentialPolicy the
+// Secrets of registry credentials copied to a namespace were copied for.
+const RegistryCredentialPolicyLabel = "experimental.kcp.dev/registry-credential-policy"
+
+var copiedCredentials labels.Selector
+
+func init() {
+	r, err := labels.NewRequirement(RegistryCredentialPolicyLabel, selection.Exists, nil)
+	if err != nil {
+		panic(err)
+	}
+	copiedCredentials = labels.NewSelector().Add(*r)
+}
+
+// PullSecrets returns a TransformFunc adding the registry credentials that
+// RegistryCredentialPolicies copied to the leaf's namespace to the image
+// pull secrets of its pods, for the syncer to sync them to the Cluster along
+// with it. The indexer must be that of a Secrets informer with the
+// logicalcluster Indexers, as returned by logicalcluster.IndexerFor.
+func PullSecrets(indexer cache.Indexer) TransformFunc {
+	return func(_ context.Context, _ *v1alpha1.Cluster, leaf *unstructured.Unstructured) error {
+		spec := podSpecOf(leaf)
+		if spec == nil {
+			return nil
+		}
+		lister := corev1lister.NewSecretLister(logicalcluster.Scoped(indexer, leaf.GetClusterName()))
+		secrets, err := lister.Secrets(leaf.GetNamespace()).List(copiedCredentials)
+		if err != nil {
+			return err
+		}
+		if len(secrets) == 0 {
+			return nil
+		}
+		sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+
+		refs, _ := spec["imagePullSecrets"].([]interface{})
+		has := map[string]bool{}
+		for _, ref := range refs {
+			if m, ok := ref.(map[string]interface{}); ok {
+				name, _ := m["name"].(string)
+				has[name] = true
+			}
+		}
+		for _, s := range secrets {
+			if !has[s.Name] {
+				refs = append(refs, map[string]interface{}{"name": s.Name})
+			}
+		}
+		spec["imagePullSecrets"] = refs
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+// Package transform lets the leafs the splitters place on each Cluster
+// differ from their root without forking the splitters: a Chain of
+// TransformFuncs is run on every leaf before it's created or updated, with
+// the Cluster it's for, e.g. to pull images from the Cluster's own registry
+// or to add the node selector its nodes need.
+//
+// TransformFuncs compiled into a binary register themselves from an init
+// function, and are enabled by name. Leafs are transformed every time their
+// root is reconciled, from a fresh copy of the root, so TransformFuncs must
+// give the same result for the same leaf and Cluster.
+package transform
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// TransformFunc changes a leaf for the Cluster it's placed on. An error
+// fails the reconcile of its root, which is retried.
+type TransformFunc func(ctx context.Context, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) error
+
+var (
+	registryLock sync.Mutex
+	registry     = map[string]TransformFunc{}
+)
+
+// Register makes a TransformFunc available to New under the given name.
+func Register(name string, fn TransformFunc) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, found := registry[name]; found {
+		panic(fmt.Sprintf("transform %q registered twice", name))
+	}
+	registry[name] = fn
+}
+
+// Chain runs TransformFuncs in order. An empty Chain leaves leafs as they
+// are.
+type Chain []TransformFunc
+
+// New returns a Chain running the named registered TransformFuncs, in
+// order.
+func New(names []string) (Chain, error) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	var c Chain
+	for _, name := range names {
+		fn, found := registry[name]
+		if !found {
+			return nil, fmt.Errorf("unknown transform %q, must be one of %s", name, strings.Join(registered(), ", "))
+		}
+		c = append(c, fn)
+	}
+	return c, nil
+}
+
+func registered() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply transforms the leaf, either an *unstructured.Unstructured or a
+// typed object like an *appsv1.Deployment, in place for the Cluster.
+func (c Chain) Apply(ctx context.Context, cl *v1alpha1.Cluster, leaf runtime.Object) error {
+	if len(c) == 0 {
+		return nil
+	}
+	if u, ok := leaf.(*unstructured.Unstructured); ok {
+		return c.apply(ctx, cl, u)
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(leaf)
+	if err != nil {
+		return err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	// Objects from listers have no kind, but TransformFuncs may need it.
+	untyped := u.GetKind() == ""
+	if untyped {
+		gvks, _, err := scheme.Scheme.ObjectKinds(leaf)
+		if err != nil {
+			return err
+		}
+		u.SetGroupVersionKind(gvks[0])
+	}
+	if err := c.apply(ctx, cl, u); err != nil {
+		return err
+	}
+	if untyped {
+		// Leave the leaf comparable to those from listers.
+		u.SetAPIVersion("")
+		u.SetKind("")
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, leaf)
+}
+
+func (c Chain) apply(ctx context.Context, cl *v1alpha1.Cluster, leaf *unstructured.Unstructured) error {
+	for _, fn := range c {
+		if err := fn(ctx, cl, leaf); err != nil {
+			return fmt.Errorf("transforming %s for cluster %s: %w", leaf.GetName(), cl.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package transform
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func leaf(image string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{ClusterName: "tenant", Namespace: "default", Name: "web--eu", Labels: map[string]string{"app": "web"}},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "web",
+						Image: image,
+						Env:   []corev1.EnvVar{{Name: "MODE", Value: "prod"}, {Name: "REGION", Value: "us"}},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestChain(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		image       string
+		want        func(*corev1.PodSpec)
+		wantErr     bool
+	}{{
+		name:  "no annotations",
+		image: "nginx",
+		want:  func(*corev1.PodSpec) {},
+	}, {
+		name:        "registry prefixed",
+		annotations: map[string]string{ImageRegistryAnnotation: "registry.eu.example.com/"},
+		image:       "library/nginx:1.21",
+		want: func(s *corev1.PodSpec) {
+			s.Containers[0].Image = "registry.eu.example.com/library/nginx:1.21"
+		},
+	}, {
+		name:        "registry replaced",
+		annotations: map[string]string{ImageRegistryAnnotation: "registry.eu.example.com"},
+		image:       "quay.io/app/web",
+		want: func(s *corev1.PodSpec) {
+			s.Containers[0].Image = "registry.eu.example.com/app/web"
+		},
+	}, {
+		name: "node selector, env and requests",
+		annotations: map[string]string{
+			NodeSelectorAnnotation:    `{"pool":"eu"}`,
+			EnvAnnotation:             `[{"name":"REGION","value":"eu"},{"name":"DEBUG","value":"1"}]`,
+			DefaultRequestsAnnotation: `{"cpu":"100m","memory":"64Mi"}`,
+		},
+		image: "nginx",
+		want: func(s *corev1.PodSpec) {
+			s.NodeSelector = map[string]string{"pool": "eu"}
+			s.Containers[0].Env = []corev1.EnvVar{{Name: "MODE", Value: "prod"}, {Name: "REGION", Value: "eu"}, {Name: "DEBUG", Value: "1"}}
+			s.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("100m")
+		},
+	}, {
+		name:        "invalid annotation",
+		annotations: map[string]string{NodeSelectorAnnotation: `pool=eu`},
+		image:       "nginx",
+		wantErr:     true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			chain, err := New([]string{"ImageRegistry", "NodeSelector", "Env", "DefaultRequests"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "eu", Annotations: tc.annotations}}
+			got := leaf(tc.image)
+			err = chain.Apply(context.Background(), cl, got)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("transformed a leaf for a cluster with an invalid annotation")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := leaf(tc.image)
+			tc.want(&want.Spec.Template.Spec)
+			if !reflect.DeepEqual(got.Spec.Template.Spec.NodeSelector, want.Spec.Template.Spec.NodeSelector) ||
+				!reflect.DeepEqual(got.Spec.Template.Spec.Containers[0].Env, want.Spec.Template.Spec.Containers[0].Env) ||
+				got.Spec.Template.Spec.Containers[0].Image != want.Spec.Template.Spec.Containers[0].Image {
+				t.Errorf("got pod spec %+v, want %+v", got.Spec.Template.Spec, want.Spec.Template.Spec)
+			}
+			for name, q := range want.Spec.Template.Spec.Containers[0].Resources.Requests {
+				if g := got.Spec.Template.Spec.Containers[0].Resources.Requests[name]; g.Cmp(q) != 0 {
+					t.Errorf("request of %s = %s, want %s", name, g.String(), q.String())
+				}
+			}
+		})
+	}
+
+	if _, err := New([]string{"Unknown"}); err == nil {
+		t.Error("New accepted an unknown transform")
+	}
+}
+
+func override(name string, spec v1alpha1.WorkloadOverrideSpec) *v1alpha1.WorkloadOverride {
+	return &v1alpha1.WorkloadOverride{
+		ObjectMeta: metav1.ObjectMeta{ClusterName: "tenant", Namespace: "default", Name: name},
+		Spec:       spec,
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, fn := range logicalcluster.Indexers {
+		indexers[name] = fn
+	}
+	indexer := cache.NewIndexer(logicalcluster.KeyFunc, indexers)
+	for _, o := range []*v1alpha1.WorkloadOverride{
+		override("1-registry", v1alpha1.WorkloadOverrideSpec{
+			Kinds:           []string{"Deployment"},
+			ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"region": "eu"}},
+			Patches: []v1alpha1.WorkloadPatch{{
+				Type:  v1alpha1.WorkloadPatchStrategicMerge,
+				Patch: "spec:\n  template:\n    spec:\n      containers:\n      - name: web\n        image: registry.eu.example.com/web\n",
+			}},
+		}),
+		override("2-replicas", v1alpha1.WorkloadOverrideSpec{
+			WorkloadSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Clusters:         []string{"eu"},
+			Patches: []v1alpha1.WorkloadPatch{{
+				Type:  v1alpha1.WorkloadPatchJSON,
+				Patch: `[{"op": "replace", "path": "/spec/replicas", "value": 3}, {"op": "replace", "path": "/metadata/name", "value": "renamed"}]`,
+			}},
+		}),
+		override("3-other-kind", v1alpha1.WorkloadOverrideSpec{
+			Kinds:   []string{"StatefulSet"},
+			Patches: []v1alpha1.WorkloadPatch{{Type: v1alpha1.WorkloadPatchMerge, Patch: `{"spec":{"replicas":9}}`}},
+		}),
+		override("4-us", v1alpha1.WorkloadOverrideSpec{
+			Clusters: []string{"us"},
+			Patches:  []v1alpha1.WorkloadPatch{{Type: v1alpha1.WorkloadPatchMerge, Patch: `{"spec":{"replicas":9}}`}},
+		}),
+	} {
+		if err := indexer.Add(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chain := Chain{Overrides(indexer)}
+	cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "eu", Labels: map[string]string{"region": "eu"}}}
+	got := leaf("nginx")
+	one := int32(1)
+	got.Spec.Replicas = &one
+	if err := chain.Apply(context.Background(), cl, got); err != nil {
+		t.Fatal(err)
+	}
+	if image := got.Spec.Template.Spec.Containers[0].Image; image != "registry.eu.example.com/web" {
+		t.Errorf("image = %q, want the registry.eu.example.com one", image)
+	}
+	if len(got.Spec.Template.Spec.Containers[0].Env) != 2 {
+		t.Errorf("strategic merge patch dropped the env of the container: %+v", got.Spec.Template.Spec.Containers[0])
+	}
+	if *got.Spec.Replicas != 3 {
+		t.Errorf("replicas = %d, want 3", *got.Spec.Replicas)
+	}
+	if got.Name != "web--eu" || got.Kind != "" {
+		t.Errorf("override changed the leaf's name to %q and kind to %q", got.Name, got.Kind)
+	}
+}
+
+func TestPullSecrets(t *testing.T) {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, fn := range logicalcluster.Indexers {
+		indexers[name] = fn
+	}
+	indexer := cache.NewIndexer(logicalcluster.KeyFunc, indexers)
+	for _, s := range []*corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{ClusterName: "tenant", Namespace: "default", Name: "quay", Labels: map[string]string{RegistryCredentialPolicyLabel: "registries"}}},
+		{ObjectMeta: metav1.ObjectMeta{ClusterName: "tenant", Namespace: "default", Name: "docker-hub", Labels: map[string]string{RegistryCredentialPolicyLabel: "registries"}}},
+		{ObjectMeta: metav1.ObjectMeta{ClusterName: "tenant", Namespace: "default", Name: "db-password"}},
+		{ObjectMeta: metav1.ObjectMeta{ClusterName: "tenant", Namespace: "other", Name: "gcr", Labels: map[string]string{RegistryCredentialPolicyLabel: "registries"}}},
+	} {
+		if err := indexer.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := leaf("nginx")
+	got.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "quay"}}
+	cl := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "eu"}}
+	if err := (Chain{PullSecrets(indexer)}).Apply(context.Background(), cl, got); err != nil {
+		t.Fatal(err)
+	}
+	want := []corev1.LocalObjectReference{{Name: "quay"}, {Name: "docker-hub"}}
+	if !reflect.DeepEqual(got.Spec.Template.Spec.ImagePullSecrets, want) {
+		t.Errorf("got image pull secrets %v, want %v", got.Spec.Template.Spec.ImagePullSecrets, want)
+	}
+}
@@ -0,0 +1,239 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"github.com/kcp-dev/kcp/pkg/logicalcluster"
+	"golang.org/x/net/http2"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+// retryPeriod is how long the syncer waits to open its tunnel again once it
+// closed.
+const retryPeriod = 10 * time.Second
+
+// subresourcePath matches the paths of the subresources of pods reached
+// through the tunnels; nothing else of the clusters is.
+var subresourcePath = regexp.MustCompile(`^/api/v1/namespaces/[^/]+/pods/[^/]+/(log|exec|attach|portforward)$`)
+
+// Run keeps the tunnel of the Cluster of the given workspace open, from kcp,
+// reached with from, which may reach the logical cluster, to the cluster
+// reached with to, opening it again whenever it closes, until ctx is done.
+func Run(ctx context.Context, from, to *rest.Config, workspace, cluster string) error {
+	handler, err := newProxy(to)
+	if err != nil {
+		return err
+	}
+	logger := logging.New("tunnel").WithValues(logging.WorkspaceKey, workspace, logging.ClusterKey, cluster)
+	ctx = logging.NewContext(ctx, logger)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := serve(ctx, from, workspace, cluster, streams(handler)); err != nil {
+			logger.Error(err, "Tunnel closed")
+		}
+	}, retryPeriod)
+	return nil
+}
+
+// serve opens the tunnel to kcp and serves handler over it, until it closes.
+func serve(ctx context.Context, from *rest.Config, workspace, cluster string, handler http.Handler) error {
+	u, err := url.Parse(from.Host)
+	if err != nil {
+		return err
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), logicalcluster.Path(workspace)) + Path + workspace + "/" + cluster
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if u.Scheme == "https" {
+		tlsConfig, err := rest.TLSConfigFor(from)
+		if err != nil {
+			return err
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		// The connection is upgraded, which HTTP/2 doesn't do.
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		conn = tlsConn
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", protocol)
+	token := from.BearerToken
+	if token == "" && from.BearerTokenFile != "" {
+		b, err := ioutil.ReadFile(from.BearerTokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return fmt.Errorf("kcp refused the tunnel: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	logging.FromContext(ctx).V(2).Info("Opened tunnel")
+	(&http2.Server{}).ServeConn(&bufferedConn{Conn: conn, r: br}, &http2.ServeConnOpts{Handler: handler})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return errors.New("connection to kcp lost")
+}
+
+// streams returns a handler serving the CONNECT streams of the tunnel as
+// connections to handler.
+func streams(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodConnect {
+			http.Error(w, "the tunnel only opens streams", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "the stream can't be flushed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		done := make(chan struct{})
+		conn := bridge(req.Body, flushWriter{w: w, f: flusher}, func() { close(done) })
+		go func() {
+			_ = (&http.Server{Handler: handler}).Serve(&connListener{conn: conn, done: done})
+		}()
+		<-done
+	})
+}
+
+// newProxy returns a handler proxying the requests for the subresources of
+// pods to the cluster reached with to, with its credentials.
+func newProxy(to *rest.Config) (http.Handler, error) {
+	location, err := url.Parse(to.Host)
+	if err != nil {
+		return nil, err
+	}
+	rt, upgrade, err := TransportsFor(to)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !subresourcePath.MatchString(req.URL.Path) {
+			http.Error(w, "only the logs of pods, and exec, attach and port-forward to them, are reached through the tunnel", http.StatusForbidden)
+			return
+		}
+		loc := *location
+		loc.Path = strings.TrimSuffix(loc.Path, "/") + req.URL.Path
+		loc.RawQuery = req.URL.RawQuery
+		out := req.Clone(req.Context())
+		out.Header.Del("Authorization")
+		for k := range out.Header {
+			if strings.HasPrefix(k, "Impersonate-") {
+				out.Header.Del(k)
+			}
+		}
+		h := proxy.NewUpgradeAwareHandler(&loc, rt, false, false, responder{})
+		h.UpgradeTransport = upgrade
+		h.ServeHTTP(w, out)
+	}), nil
+}
+
+// responder writes the errors reaching the cluster.
+type responder struct{}
+
+func (responder) Error(w http.ResponseWriter, req *http.Request, err error) {
+	http.Error(w, fmt.Sprintf("error reaching the cluster: %v", err), http.StatusBadGateway)
+}
+
+// flushWriter flushes everything written to a stream right away.
+type flushWriter struct {
+	w io.Writer
+	f http.Flusher
+}
+
+func (w flushWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.f.Flush()
+	return n, err
+}
+
+// connListener accepts a single connection, then waits for it to be done.
+type connListener struct {
+	conn net.Conn
+	done <-chan struct{}
+	once sync.Once
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() { conn = l.conn })
+	if conn != nil {
+		return conn, nil
+	}
+	<-l.done
+	return nil, io.EOF
+}
+
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
@@ -0,0 +1,221 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kcp-dev/kcp/pkg/logging"
+	"golang.org/x/net/http2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// pingPeriod is how often the tunnels are checked to still be open.
+	pingPeriod = 30 * time.Second
+	// pingTimeout is how long a syncer has to answer.
+	pingTimeout = 15 * time.Second
+)
+
+// Server accepts the tunnels of syncers, and dials the API servers of their
+// clusters through them.
+type Server struct {
+	canTunnel func(ctx context.Context, u user.Info, workspace, cluster string) bool
+
+	lock    sync.Mutex
+	tunnels map[string]*http2.ClientConn
+}
+
+// NewServer returns a Server accepting the tunnels of the users, as the
+// context of their requests has them, that canTunnel lets open one for the
+// named Cluster of the workspace.
+func NewServer(canTunnel func(ctx context.Context, u user.Info, workspace, cluster string) bool) *Server {
+	return &Server{
+		canTunnel: canTunnel,
+		tunnels:   map[string]*http2.ClientConn{},
+	}
+}
+
+func tunnelKey(workspace, cluster string) string {
+	return workspace + "/" + cluster
+}
+
+// WithTunnels returns a handler accepting the tunnels opened at Path, and
+// passing the other requests on to handler.
+func (s *Server) WithTunnels(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, Path) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		s.accept(w, req)
+	})
+}
+
+// accept upgrades the connection of req to the tunnel of the Cluster its path
+// names, replacing the Cluster's current tunnel, if any.
+func (s *Server) accept(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, Path), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, req)
+		return
+	}
+	workspace, cluster := parts[0], parts[1]
+	u, ok := request.UserFrom(req.Context())
+	if !ok {
+		http.Error(w, "tunnels are only opened by authenticated syncers", http.StatusUnauthorized)
+		return
+	}
+	if !s.canTunnel(req.Context(), u, workspace, cluster) {
+		http.Error(w, fmt.Sprintf("%s can't open the tunnel of cluster %s of workspace %s", u.GetName(), cluster, workspace), http.StatusForbidden)
+		return
+	}
+	if !strings.EqualFold(req.Header.Get("Upgrade"), protocol) {
+		http.Error(w, "tunnels are opened by upgrading to "+protocol, http.StatusUpgradeRequired)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunnels are opened over HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	logger := logging.New("tunnel").WithValues(logging.WorkspaceKey, workspace, logging.ClusterKey, cluster)
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		logger.Error(err, "Error opening tunnel")
+		return
+	}
+	if _, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: " + protocol + "\r\n\r\n"); err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		logger.Error(err, "Error opening tunnel")
+		conn.Close()
+		return
+	}
+	cc, err := (&http2.Transport{}).NewClientConn(&bufferedConn{Conn: conn, r: rw.Reader})
+	if err != nil {
+		logger.Error(err, "Error opening tunnel")
+		conn.Close()
+		return
+	}
+
+	key := tunnelKey(workspace, cluster)
+	s.lock.Lock()
+	if previous, ok := s.tunnels[key]; ok {
+		previous.Close()
+	}
+	s.tunnels[key] = cc
+	s.lock.Unlock()
+	logger.Info("Opened tunnel")
+	go s.watch(key, cc, logger)
+}
+
+// IsServiceAccount returns whether u is the named ServiceAccount of the
+// logical cluster client reaches. ServiceAccounts are named alike in every
+// logical cluster, and their tokens are signed with the same key, so it
+// checks the UID their tokens carry rather than the name alone.
+func IsServiceAccount(ctx context.Context, client kubernetes.Interface, u user.Info, namespace, name string) bool {
+	if u.GetName() != serviceaccount.MakeUsername(namespace, name) || u.GetUID() == "" {
+		return false
+	}
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+	return err == nil && string(sa.UID) == u.GetUID()
+}
+
+// watch pings the tunnel until it's closed or replaced, then forgets it.
+func (s *Server) watch(key string, cc *http2.ClientConn, logger logr.Logger) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.lock.Lock()
+		current := s.tunnels[key] == cc
+		s.lock.Unlock()
+		if !current {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := cc.Ping(ctx)
+		cancel()
+		if err != nil {
+			logger.Info("Closing tunnel", "reason", err.Error())
+			break
+		}
+	}
+	s.lock.Lock()
+	if s.tunnels[key] == cc {
+		delete(s.tunnels, key)
+	}
+	s.lock.Unlock()
+	cc.Close()
+}
+
+// Dialer returns the dial function reaching the API server of the Cluster of
+// the workspace through its tunnel, or nil if it has none. The API server is
+// reached over plain HTTP, with the syncer's credentials, and whatever the
+// address dialed. A nil Server has no tunnels.
+func (s *Server) Dialer(workspace, cluster string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	if s == nil {
+		return nil
+	}
+	key := tunnelKey(workspace, cluster)
+	s.lock.Lock()
+	_, ok := s.tunnels[key]
+	s.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return s.dial(key)
+	}
+}
+
+// dial opens a stream of the tunnel with the given key.
+func (s *Server) dial(key string) (net.Conn, error) {
+	s.lock.Lock()
+	cc, ok := s.tunnels[key]
+	s.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("the tunnel of cluster %s is closed", key)
+	}
+	// The stream outlives the request it's dialed for, kept alive by the
+	// transport dialing it, so it isn't bound to its context.
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodConnect, "https://"+key, pr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cc.RoundTrip(req)
+	if err != nil {
+		pw.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		pw.Close()
+		return nil, fmt.Errorf("the tunnel of cluster %s refused the stream: %s", key, resp.Status)
+	}
+	return bridge(resp.Body, pw, func() { pw.Close() }), nil
+}
+
+// bufferedConn is a connection whose reads start with what was buffered of it.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,82 @@
+// Package tunnel lets kcp reach the API servers of the physical clusters it
+// has no inbound access to, for the logs of their pods and to exec into,
+// attach to and port-forward to them.
+//
+// The syncer of such a cluster, which dials out to kcp anyway, opens a
+// tunnel: it upgrades a connection to kcp's Path, and serves HTTP/2 over it,
+// with kcp as the client. Each connection kcp needs to the cluster is then
+// a CONNECT stream of the tunnel, over which the syncer serves a proxy to
+// its API server, restricted to the subresources of pods and authenticated
+// with its own credentials.
+package tunnel
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+const (
+	// Path is where kcp accepts tunnels, under <workspace>/<cluster>.
+	Path = "/tunnels/"
+
+	// protocol is the Upgrade of the connections tunnels are opened on.
+	protocol = "kcp-tunnel"
+)
+
+// TransportsFor returns the round trippers reaching a cluster with cfg, for
+// plain requests and for upgraded ones, like those of exec and
+// port-forward, as kubectl proxy does.
+func TransportsFor(cfg *rest.Config) (http.RoundTripper, proxy.UpgradeRequestRoundTripper, error) {
+	rt, err := rest.TransportFor(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	transportConfig, err := cfg.TransportConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig, err := transport.TLSConfigFor(transportConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	connection := utilnet.SetOldTransportDefaults(&http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext:     (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	})
+	// Upgraded connections skip the transport's wrappers, so the request
+	// is given the cluster's credentials beforehand.
+	upgrader, err := transport.HTTPWrappersForConfig(transportConfig, proxy.MirrorRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rt, proxy.NewUpgradeRequestRoundTripper(connection, upgrader), nil
+}
+
+// bridge returns a connection reading what r reads and writing to w, for the
+// streams of a tunnel to be used as connections. The returned connection
+// supports deadlines, which the HTTP clients and servers using it rely on.
+func bridge(r io.ReadCloser, w io.Writer, closeWrite func()) net.Conn {
+	local, remote := net.Pipe()
+	go func() {
+		_, _ = io.Copy(remote, r)
+		r.Close()
+		remote.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(w, remote)
+		closeWrite()
+		remote.Close()
+	}()
+	return local
+}
@@ -0,0 +1,138 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/authorization"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestTunnel(t *testing.T) {
+	var gotPath, gotAuthorization string
+	physical := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotPath, gotAuthorization = req.URL.Path+"?"+req.URL.RawQuery, req.Header.Get("Authorization")
+		fmt.Fprint(w, "hello from us-east")
+	}))
+	defer physical.Close()
+
+	authn := authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+		if req.Header.Get("Authorization") != "Bearer syncer" {
+			return nil, false, nil
+		}
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "syncer-of-us-east"}}, true, nil
+	})
+	s := NewServer(func(_ context.Context, u user.Info, workspace, cluster string) bool {
+		return workspace == "admin" && u.GetName() == "syncer-of-"+cluster
+	})
+	kcp := httptest.NewServer(authorization.WithAuthentication(s.WithTunnels(http.NotFoundHandler()), authn))
+	defer kcp.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	from := &rest.Config{Host: kcp.URL + "/clusters/admin", BearerToken: "syncer"}
+	to := &rest.Config{Host: physical.URL, BearerToken: "cluster-token"}
+	go func() {
+		if err := Run(ctx, from, to, "admin", "us-east"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if err := serve(ctx, from, "admin", "us-west", http.NotFoundHandler()); err == nil {
+		t.Error("opened the tunnel of another cluster")
+	}
+	if err := serve(ctx, from, "other", "us-east", http.NotFoundHandler()); err == nil {
+		t.Error("opened the tunnel of the cluster of another workspace")
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return s.Dialer("admin", "us-east") != nil, nil
+	}); err != nil {
+		t.Fatal("the tunnel wasn't opened")
+	}
+	if s.Dialer("admin", "us-west") != nil || s.Dialer("other", "us-east") != nil {
+		t.Error("got the tunnel of another cluster")
+	}
+
+	client := &http.Client{Transport: &http.Transport{DialContext: s.Dialer("admin", "us-east")}}
+	for _, tc := range []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/api/v1/namespaces/web/pods/web-1/log?container=web", http.StatusOK},
+		{"/api/v1/namespaces/web/secrets/token", http.StatusForbidden},
+	} {
+		gotPath, gotAuthorization = "", ""
+		req, err := http.NewRequest(http.MethodGet, "http://us-east"+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer kcp-token")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("%s: got status %d: %s", tc.path, resp.StatusCode, body)
+			continue
+		}
+		if tc.wantStatus != http.StatusOK {
+			if gotPath != "" {
+				t.Errorf("%s: reached the cluster at %s", tc.path, gotPath)
+			}
+			continue
+		}
+		if string(body) != "hello from us-east" || gotPath != tc.path {
+			t.Errorf("%s: reached the cluster at %s, got %q", tc.path, gotPath, body)
+		}
+		if gotAuthorization != "Bearer cluster-token" {
+			t.Errorf("%s: reached the cluster with %q", tc.path, gotAuthorization)
+		}
+	}
+}
+
+func TestIsServiceAccount(t *testing.T) {
+	syncerOf := func(uid string) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "kcp-syncers", Name: "us-east", UID: types.UID(uid)}}
+	}
+	// Both workspaces have a Cluster us-east, so a ServiceAccount named
+	// alike, whose tokens only differ by its UID.
+	admin := kubefake.NewSimpleClientset(syncerOf("admin-uid"))
+	other := kubefake.NewSimpleClientset(syncerOf("other-uid"))
+	name := serviceaccount.MakeUsername("kcp-syncers", "us-east")
+
+	for _, tc := range []struct {
+		desc string
+		u    user.Info
+		want bool
+	}{
+		{"the syncer of the workspace", &user.DefaultInfo{Name: name, UID: "admin-uid"}, true},
+		{"the syncer of another workspace", &user.DefaultInfo{Name: name, UID: "other-uid"}, false},
+		{"a token without a UID", &user.DefaultInfo{Name: name}, false},
+		{"another ServiceAccount", &user.DefaultInfo{Name: serviceaccount.MakeUsername("kcp-syncers", "us-west"), UID: "admin-uid"}, false},
+	} {
+		if got := IsServiceAccount(context.Background(), admin, tc.u, "kcp-syncers", "us-east"); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.desc, got, tc.want)
+		}
+	}
+	if !IsServiceAccount(context.Background(), other, &user.DefaultInfo{Name: name, UID: "other-uid"}, "kcp-syncers", "us-east") {
+		t.Error("the syncer of the other workspace can't open its own tunnel")
+	}
+	if IsServiceAccount(context.Background(), kubefake.NewSimpleClientset(), &user.DefaultInfo{Name: name, UID: "admin-uid"}, "kcp-syncers", "us-east") {
+		t.Error("a workspace without the ServiceAccount let its token through")
+	}
+}
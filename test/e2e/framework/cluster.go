@@ -0,0 +1,125 @@
+// +build e2e
+
+package framework
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// clusterToken is the token the users of the physical clusters authenticate
+// with, as members of system:masters.
+const clusterToken = "e2e-admin-token"
+
+// PhysicalCluster is a Kubernetes API server, with an etcd of its own, kcp
+// syncs to. It has no nodes, nor controllers: the objects synced to it are
+// only stored, with whatever status the tests set.
+type PhysicalCluster struct {
+	// KubeconfigData reaches the cluster, for its Cluster in kcp.
+	KubeconfigData string
+
+	KubeClient kubernetes.Interface
+
+	etcd, apiserver *exec.Cmd
+}
+
+// StartPhysicalCluster runs a physical cluster until the test ends, or it's
+// stopped, and waits for it to be ready.
+func StartPhysicalCluster(t *testing.T, name string) *PhysicalCluster {
+	assets := assetsDir(t)
+	dir := artifactsDir(t, name)
+
+	etcdPort, peerPort := freePort(t), freePort(t)
+	etcd := start(t, dir, filepath.Join(assets, "etcd"),
+		"--data-dir", filepath.Join(dir, "etcd"),
+		"--listen-client-urls", fmt.Sprintf("http://127.0.0.1:%d", etcdPort),
+		"--advertise-client-urls", fmt.Sprintf("http://127.0.0.1:%d", etcdPort),
+		"--listen-peer-urls", fmt.Sprintf("http://127.0.0.1:%d", peerPort),
+		"--initial-advertise-peer-urls", fmt.Sprintf("http://127.0.0.1:%d", peerPort),
+		"--initial-cluster", fmt.Sprintf("default=http://127.0.0.1:%d", peerPort),
+	)
+
+	tokenFile := filepath.Join(dir, "tokens.csv")
+	if err := ioutil.WriteFile(tokenFile, []byte(clusterToken+",admin,admin,system:masters\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "sa.key")
+	if err := writeServiceAccountKey(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	apiserver := start(t, dir, filepath.Join(assets, "kube-apiserver"),
+		"--etcd-servers", fmt.Sprintf("http://127.0.0.1:%d", etcdPort),
+		"--bind-address", "127.0.0.1",
+		"--advertise-address", "127.0.0.1",
+		"--secure-port", strconv.Itoa(port),
+		"--cert-dir", filepath.Join(dir, "certs"),
+		"--token-auth-file", tokenFile,
+		"--authorization-mode", "AlwaysAllow",
+		"--service-cluster-ip-range", "10.0.0.0/24",
+		"--service-account-issuer", "https://kubernetes.default.svc",
+		"--service-account-key-file", keyFile,
+		"--service-account-signing-key-file", keyFile,
+		"--disable-admission-plugins", "ServiceAccount",
+	)
+
+	kubeconfig := clientcmdapi.NewConfig()
+	kubeconfig.Clusters[name] = &clientcmdapi.Cluster{
+		Server: fmt.Sprintf("https://127.0.0.1:%d", port),
+		// The API server serves with a certificate it signed itself.
+		InsecureSkipTLSVerify: true,
+	}
+	kubeconfig.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: clusterToken}
+	kubeconfig.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	kubeconfig.CurrentContext = name
+	data, err := clientcmd.Write(*kubeconfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc := &PhysicalCluster{KubeconfigData: string(data), KubeClient: kubeClient, etcd: etcd, apiserver: apiserver}
+	Eventually(t, time.Minute, func() (bool, error) {
+		_, err := kubeClient.Discovery().RESTClient().Get().AbsPath("/readyz").DoRaw(context.Background())
+		return err == nil, err
+	}, "cluster %s to be ready", name)
+	return pc
+}
+
+// Stop stops the cluster, which kcp can't reach anymore.
+func (pc *PhysicalCluster) Stop() {
+	stop(pc.apiserver)
+	stop(pc.etcd)
+}
+
+// writeServiceAccountKey writes a new RSA key for the API server to sign and
+// verify ServiceAccount tokens with.
+func writeServiceAccountKey(path string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	return ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600)
+}
@@ -0,0 +1,135 @@
+// +build e2e
+
+// Package framework runs kcp, the physical clusters it places workloads on
+// and the controllers in between as processes of their own, for the
+// end-to-end tests to drive them through their APIs.
+//
+// kcp and its controllers are run from the binaries make build writes to
+// bin, or to $KCP_E2E_BIN. The physical clusters are API servers alone, an
+// etcd and a kube-apiserver each, as envtest runs them, from the binaries in
+// $KUBEBUILDER_ASSETS: nothing runs the pods of their workloads, whose status
+// the tests set themselves.
+package framework
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// PollInterval is how often Eventually checks its condition.
+const PollInterval = time.Second
+
+// binDir returns the directory of the kcp binaries.
+func binDir() string {
+	if dir := os.Getenv("KCP_E2E_BIN"); dir != "" {
+		return dir
+	}
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "bin")
+}
+
+// assetsDir returns the directory of the etcd and kube-apiserver binaries.
+func assetsDir(t *testing.T) string {
+	dir := os.Getenv("KUBEBUILDER_ASSETS")
+	if dir == "" {
+		t.Skip("KUBEBUILDER_ASSETS isn't set to the directory of the etcd and kube-apiserver binaries")
+	}
+	return dir
+}
+
+// artifactsDir returns a directory for the data and logs of a process of the
+// test, kept after it for the logs to be looked at.
+func artifactsDir(t *testing.T, name string) string {
+	parent := os.Getenv("KCP_E2E_ARTIFACTS")
+	if parent != "" {
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir, err := ioutil.TempDir(parent, "kcp-e2e-"+name+"-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// start runs the binary with the given arguments until the test ends,
+// logging to the given directory.
+func start(t *testing.T, dir, binary string, args ...string) *exec.Cmd {
+	name := filepath.Base(binary)
+	logFile, err := os.Create(filepath.Join(dir, name+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting %s: %v", name, err)
+	}
+	t.Logf("Started %s, logging to %s", name, logFile.Name())
+	t.Cleanup(func() {
+		stop(cmd)
+		logFile.Close()
+	})
+	return cmd
+}
+
+// stop kills the process, if it's still running, and waits for it to exit.
+func stop(cmd *exec.Cmd) {
+	if cmd.ProcessState != nil {
+		return
+	}
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+}
+
+// freePort returns a port of localhost nothing listens on.
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Eventually fails the test unless condition is met within timeout, checked
+// every PollInterval, reporting what was waited for. Errors condition
+// returns are retried, and the last one reported.
+func Eventually(t *testing.T, timeout time.Duration, condition func() (bool, error), format string, args ...interface{}) {
+	t.Helper()
+	var last error
+	err := wait.PollImmediate(PollInterval, timeout, func() (bool, error) {
+		done, err := condition()
+		if err != nil {
+			last = err
+			return false, nil
+		}
+		return done, nil
+	})
+	if err != nil {
+		msg := fmt.Sprintf(format, args...)
+		if last != nil {
+			t.Fatalf("timed out waiting for %s: %v", msg, last)
+		}
+		t.Fatalf("timed out waiting for %s", msg)
+	}
+}
+
+// Context returns a context for the requests of the test, canceled once it
+// ends.
+func Context(t *testing.T) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return ctx
+}
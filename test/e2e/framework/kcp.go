@@ -0,0 +1,113 @@
+// +build e2e
+
+package framework
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/pkg/apis/cluster/v1alpha1"
+	clusterclient "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// EvictionToleration is how long kcp lets a cluster of the tests stay
+// NotReady before moving its workloads to the others.
+const EvictionToleration = 10 * time.Second
+
+// KCP is a kcp server run for a test, with the Cluster Controller and
+// syncers pushing deployments to the clusters registered with it.
+type KCP struct {
+	// Kubeconfig is the path of the admin kubeconfig of the server.
+	Kubeconfig string
+	// Config reaches the admin logical cluster.
+	Config *rest.Config
+
+	KubeClient    kubernetes.Interface
+	ClusterClient clusterclient.Interface
+
+	dir string
+}
+
+// StartKCP runs kcp until the test ends, and waits for it to serve
+// deployments. kcp listens on port 6443, so only one runs at a time.
+func StartKCP(t *testing.T) *KCP {
+	dir := artifactsDir(t, "kcp")
+	start(t, dir, filepath.Join(binDir(), "kcp"), "start",
+		"--root_directory", dir,
+		"--install_cluster_controller",
+		"--resources_to_sync", "deployments",
+		"--eviction_toleration", EvictionToleration.String(),
+	)
+
+	k := &KCP{Kubeconfig: filepath.Join(dir, "data", "admin.kubeconfig"), dir: dir}
+	Eventually(t, time.Minute, func() (bool, error) {
+		if _, err := os.Stat(k.Kubeconfig); err != nil {
+			return false, err
+		}
+		cfg, err := clientcmd.BuildConfigFromFlags("", k.Kubeconfig)
+		if err != nil {
+			return false, err
+		}
+		k.Config = cfg
+		return true, nil
+	}, "kcp to write %s", k.Kubeconfig)
+
+	var err error
+	if k.KubeClient, err = kubernetes.NewForConfig(k.Config); err != nil {
+		t.Fatal(err)
+	}
+	if k.ClusterClient, err = clusterclient.NewForConfig(k.Config); err != nil {
+		t.Fatal(err)
+	}
+	Eventually(t, time.Minute, func() (bool, error) {
+		_, err := k.ClusterClient.ClusterV1alpha1().Clusters().List(context.Background(), metav1.ListOptions{})
+		return err == nil, err
+	}, "kcp to serve clusters")
+	return k
+}
+
+// StartSplitter runs the deployment splitter against kcp until the test
+// ends. It only splits Deployments: the other resources it splits are served
+// by CRDs the tests don't apply.
+func (k *KCP) StartSplitter(t *testing.T) {
+	start(t, k.dir, filepath.Join(binDir(), "deployment-splitter"),
+		"--kubeconfig", k.Kubeconfig,
+		"--metrics_addr", "",
+		"--split_statefulsets=false",
+	)
+}
+
+// RegisterCluster registers the physical cluster with kcp under the given
+// name, for the Cluster Controller to push to with its kubeconfig, and waits
+// for it to be Ready and for kcp to serve the deployments it imported from
+// it.
+func (k *KCP) RegisterCluster(t *testing.T, name string, pc *PhysicalCluster) *v1alpha1.Cluster {
+	ctx := Context(t)
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1alpha1.ClusterSpec{KubeConfig: pc.KubeconfigData},
+	}
+	if _, err := k.ClusterClient.ClusterV1alpha1().Clusters().Create(ctx, cluster, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("registering cluster %s: %v", name, err)
+	}
+	Eventually(t, 2*time.Minute, func() (bool, error) {
+		var err error
+		cluster, err = k.ClusterClient.ClusterV1alpha1().Clusters().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return cluster.Status.Conditions.IsReady(), nil
+	}, "cluster %s to be Ready", name)
+	Eventually(t, time.Minute, func() (bool, error) {
+		_, err := k.KubeClient.Discovery().ServerResourcesForGroupVersion("apps/v1")
+		return err == nil, err
+	}, "kcp to serve deployments")
+	return cluster
+}
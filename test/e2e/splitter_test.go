@@ -0,0 +1,119 @@
+// +build e2e
+
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/kcp/test/e2e/framework"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestSplitter runs kcp, the deployment splitter and two physical clusters,
+// and follows a root Deployment as it is split across the clusters, its
+// status aggregated from theirs, and moved off the one that goes away.
+func TestSplitter(t *testing.T) {
+	kcp := framework.StartKCP(t)
+	east := framework.StartPhysicalCluster(t, "us-east")
+	west := framework.StartPhysicalCluster(t, "us-west")
+	kcp.RegisterCluster(t, "us-east", east)
+	kcp.RegisterCluster(t, "us-west", west)
+	kcp.StartSplitter(t)
+
+	ctx := framework.Context(t)
+	deployments := kcp.KubeClient.AppsV1().Deployments("default")
+	labels := map[string]string{"app": "web"}
+	replicas := int32(4)
+	root := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+			},
+		},
+	}
+	if _, err := deployments.Create(ctx, root, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// replicasOf returns the replicas of the leaf of the root on the cluster,
+	// or -1 if it has none.
+	replicasOf := func(clusterName string) (int32, error) {
+		leaf, err := deployments.Get(ctx, "web--"+clusterName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return -1, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return *leaf.Spec.Replicas, nil
+	}
+
+	t.Run("replicas are split across the clusters", func(t *testing.T) {
+		framework.Eventually(t, time.Minute, func() (bool, error) {
+			for _, name := range []string{"us-east", "us-west"} {
+				if replicas, err := replicasOf(name); err != nil || replicas != 2 {
+					return false, err
+				}
+			}
+			return true, nil
+		}, "2 replicas of web on each cluster")
+
+		for name, pc := range map[string]*framework.PhysicalCluster{"us-east": east, "us-west": west} {
+			leafs := pc.KubeClient.AppsV1().Deployments("default")
+			framework.Eventually(t, time.Minute, func() (bool, error) {
+				_, err := leafs.Get(ctx, "web--"+name, metav1.GetOptions{})
+				return err == nil, err
+			}, "the leaf of web to be synced to %s", name)
+		}
+	})
+
+	t.Run("status is aggregated from the clusters", func(t *testing.T) {
+		for name, pc := range map[string]*framework.PhysicalCluster{"us-east": east, "us-west": west} {
+			leafs := pc.KubeClient.AppsV1().Deployments("default")
+			framework.Eventually(t, time.Minute, func() (bool, error) {
+				leaf, err := leafs.Get(ctx, "web--"+name, metav1.GetOptions{})
+				if err != nil {
+					return false, err
+				}
+				// Nothing runs the pods of the clusters, so their
+				// Deployments are reported ready here.
+				leaf.Status.Replicas = 2
+				leaf.Status.UpdatedReplicas = 2
+				leaf.Status.ReadyReplicas = 2
+				leaf.Status.AvailableReplicas = 2
+				_, err = leafs.UpdateStatus(ctx, leaf, metav1.UpdateOptions{})
+				return err == nil, err
+			}, "the status of the leaf on %s to be set", name)
+		}
+
+		framework.Eventually(t, time.Minute, func() (bool, error) {
+			root, err := deployments.Get(ctx, "web", metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return root.Status.ReadyReplicas == 4 && root.Status.AvailableReplicas == 4, nil
+		}, "4 ready replicas of the root")
+	})
+
+	t.Run("replicas move off a cluster that goes away", func(t *testing.T) {
+		west.Stop()
+		// kcp notices on its next health check of the cluster, then
+		// tolerates it being NotReady for a while.
+		framework.Eventually(t, 3*time.Minute+framework.EvictionToleration, func() (bool, error) {
+			east, err := replicasOf("us-east")
+			if err != nil || east != 4 {
+				return false, err
+			}
+			west, err := replicasOf("us-west")
+			return west <= 0, err
+		}, "the 4 replicas of web to be moved to us-east")
+	})
+}